@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/pkg/cli"
+	"github.com/rivine/rivine/pkg/client"
+	rivinetypes "github.com/rivine/rivine/types"
+	"github.com/threefoldfoundation/tfchain/pkg/persist"
+
+	tfapi "github.com/threefoldfoundation/tfchain/pkg/api"
+
+	"github.com/spf13/cobra"
+)
+
+func createAtomicSwapSubCmds(client *client.CommandLineClient) {
+	atomicSwapSubCmds := &atomicSwapSubCmds{cli: client}
+
+	// define commands
+	var (
+		watchCmd = &cobra.Command{
+			Use:   "watch <outputid>",
+			Short: "Wait until an atomic swap contract is redeemed or can be refunded.",
+			Long: `Watch an atomic swap contract, using the explorer of the daemon,
+until the contract is redeemed, refunded, or until its refund window is open.
+The secret is returned as soon as the contract is redeemed.
+
+A contract that is not (yet) known by the explorer
+is waited for as well, until the contract is found or the watch times out.
+
+Returned status codes:
+
+  0: contract is redeemed, refunded or the refund window is open
+  1: generic error, automatically recovering is not possible or recommended
+  5: the watch timed out while the contract was still open
+  64: misusage of the command, see --help on how to use the command
+
+Example STDOUT output when using the '--encoding json' flag:
+
+  {
+    "outputid": "4abc9c18e03b0e9f35e636d8294c8de1fd823dcaa17bce115d1452df6198cdd7",
+    "transactionid": "3faf9e80cb33b572830824b2b3341c4f0f86ca34fcad1e6604ad112c008f5fbc",
+    "value": "100000000000",
+    "contract": {
+      "sender": "01b49da2ff193f46ee0fc684d7a6121a8b8e324144dffc7327471a4da79f1730960edcb2ce737f",
+      "receiver": "019e9b6f2d43a44046b62836ce8d75c935ff66cbba1e624b3e9755b98ac176a08dac5267b2c8ee",
+      "hashedsecret": "c22267f0f118282b15098e0b5e3a8027af64f0cce7afa19b274abb21b5555626",
+      "timelock": 1530169858
+    },
+    "contractid": "020ce1011596c7bf7aa63e15a6b3d1eb44087358b0670efa50d34d0509625b13d2b82887cd5bbb",
+    "creationheight": 4242,
+    // one of: open, expired, redeemed, refunded
+    "status": "redeemed",
+    // only defined for redeemed and refunded contracts
+    "spendtransactionid": "8f8cf5a3b7f6c1d2a0b7ca1ab9ac2a3d3d0ec8a1b59b0dd6e0c0ad9b3a4ab9f2",
+    "spendheight": 4250,
+    // only defined for redeemed contracts
+    "secret": "6f0e3b2cdd82da7c4ddc20f03be25765fb885fb59af316cb3bbf8649c82d046d"
+  }
+
+Note that this output is only returned in case the command
+was successful, and thus exited with status code 0.
+`,
+			Run: atomicSwapSubCmds.watch,
+		}
+	)
+
+	// add commands as atomic swap sub commands
+	client.AtomicSwapCmd.AddCommand(
+		watchCmd,
+	)
+
+	// register flags,
+	// the encoding flag is inherited from the atomic swap root command
+	watchCmd.Flags().DurationVar(
+		&atomicSwapSubCmds.watchCfg.Interval, "interval", time.Second*10,
+		"the interval in which the explorer is polled for the state of the contract")
+	watchCmd.Flags().DurationVar(
+		&atomicSwapSubCmds.watchCfg.Timeout, "timeout", 0,
+		"optional maximum duration of the watch, watching until the contract is no longer open if not defined")
+}
+
+type atomicSwapSubCmds struct {
+	cli      *client.CommandLineClient
+	watchCfg struct {
+		Interval time.Duration
+		Timeout  time.Duration
+	}
+}
+
+func (atomicSwapSubCmds *atomicSwapSubCmds) watch(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		os.Exit(cli.ExitCodeUsage)
+	}
+	var outputID rivinetypes.CoinOutputID
+	err := outputID.LoadString(args[0])
+	if err != nil {
+		cli.DieWithExitCode(cli.ExitCodeUsage, "failed to parse outputid-argument:", err)
+	}
+	if atomicSwapSubCmds.watchCfg.Interval <= 0 {
+		cli.DieWithExitCode(cli.ExitCodeUsage, "interval has to be greater than 0")
+	}
+	encodingType := cli.EncodingTypeHuman
+	if flag := cmd.Flags().Lookup("encoding"); flag != nil && flag.Value.String() == "json" {
+		encodingType = cli.EncodingTypeJSON
+	}
+
+	var deadline <-chan time.Time
+	if atomicSwapSubCmds.watchCfg.Timeout > 0 {
+		deadline = time.After(atomicSwapSubCmds.watchCfg.Timeout)
+	}
+	ticker := time.NewTicker(atomicSwapSubCmds.watchCfg.Interval)
+	defer ticker.Stop()
+
+	var (
+		result    tfapi.AtomicSwapDBGetAtomicSwapContract
+		lastFound *bool
+	)
+	for {
+		err = atomicSwapSubCmds.cli.GetAPI("/explorer/atomicswaps/"+outputID.String(), &result)
+		if err == nil && result.Status != persist.AtomicSwapContractStatusOpen {
+			break
+		}
+		if err != nil && err != api.ErrStatusNotFound {
+			cli.DieWithError("failed to get atomic swap contract from the explorer:", err)
+		}
+		// report only once per known state of the contract
+		if found := err == nil; encodingType == cli.EncodingTypeHuman && (lastFound == nil || *lastFound != found) {
+			lastFound = &found
+			if !found {
+				fmt.Println("atomic swap contract not found (yet), waiting for it to be confirmed...")
+			} else {
+				fmt.Printf("atomic swap contract is open, waiting until it is redeemed or until %s...\n",
+					time.Unix(int64(result.Contract.TimeLock), 0).Format(time.RFC822))
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			cli.DieWithExitCode(cli.ExitCodeTemporaryError, "timed out while watching atomic swap contract")
+		}
+	}
+
+	if encodingType == cli.EncodingTypeJSON {
+		err = json.NewEncoder(os.Stdout).Encode(result)
+		if err != nil {
+			cli.DieWithError("failed to encode atomic swap contract", err)
+		}
+		return
+	}
+	switch result.Status {
+	case persist.AtomicSwapContractStatusRedeemed:
+		fmt.Printf("atomic swap contract was redeemed in transaction %s at block height %d\n",
+			result.SpendTransactionID.String(), result.SpendHeight)
+		fmt.Println("secret:", result.Secret.String())
+	case persist.AtomicSwapContractStatusRefunded:
+		fmt.Printf("atomic swap contract was refunded in transaction %s at block height %d\n",
+			result.SpendTransactionID.String(), result.SpendHeight)
+	case persist.AtomicSwapContractStatusExpired:
+		fmt.Println("refund window of the atomic swap contract is open, the sender can refund it now")
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/threefoldfoundation/tfchain/pkg/api"
+	"github.com/threefoldfoundation/tfchain/pkg/modules/stream"
+
+	rivineapi "github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/pkg/cli"
+	"github.com/rivine/rivine/pkg/client"
+	rivinetypes "github.com/rivine/rivine/types"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// maxServerSentEventSize is the maximum size of a single line of the event stream,
+	// large enough to contain a block with many transactions
+	maxServerSentEventSize = 32 * 1024 * 1024
+	// watchReconnectDelay is the time waited prior to reconnecting to the event stream
+	watchReconnectDelay = 5 * time.Second
+)
+
+func createWatchCmd(client *client.CommandLineClient) {
+	watchCmd := &watchCmd{cli: client}
+
+	// define command
+	cmd := &cobra.Command{
+		Use:   "watch [address...]",
+		Short: "Watch the chain activity, as streamed by the daemon",
+		Long: `Watch the chain activity, as streamed by the daemon using its stream module,
+printing a line per event: applied and reverted blocks, confirmed, reverted and unconfirmed transactions,
+and mint condition changes. Consensus change lines print the ID to resume from using --since.
+
+If addresses are given, only the transactions sending to or spending from those addresses are printed.
+The stream is resumed automatically if the daemon closed it because it wasn't consumed fast enough.
+`,
+		Run: watchCmd.watch,
+	}
+	client.RootCmd.AddCommand(cmd)
+
+	// register flags
+	cmd.Flags().StringVar(
+		&watchCmd.since, "since", "",
+		"resume from the consensus change with the given ID, or use \"beginning\" to replay the entire chain")
+	cmd.Flags().StringSliceVar(
+		&watchCmd.types, "types", nil,
+		"only print the events of the given types (e.g. block.applied,transaction.confirmed)")
+	cmd.Flags().StringSliceVar(
+		&watchCmd.versions, "versions", nil,
+		"only print the transactions of the given versions (e.g. 1,129)")
+	cmd.Flags().BoolVar(
+		&watchCmd.json, "json", false,
+		"print each event as a JSON object, instead of a human-readable line")
+	cmd.Flags().BoolVar(
+		&watchCmd.reconnect, "reconnect", false,
+		"reconnect to the daemon when the connection is lost, resuming from the last consensus change received")
+}
+
+type watchCmd struct {
+	cli       *client.CommandLineClient
+	since     string
+	types     []string
+	versions  []string
+	json      bool
+	reconnect bool
+}
+
+// errEventStreamClosed is returned when the daemon closed the event stream,
+// with the given reason, such that it can be resumed
+type errEventStreamClosed struct {
+	reason string
+}
+
+func (err errEventStreamClosed) Error() string {
+	return "event stream closed by daemon: " + err.reason
+}
+
+func (watchCmd *watchCmd) watch(cmd *cobra.Command, args []string) {
+	query := url.Values{}
+	for _, arg := range args {
+		var uh rivinetypes.UnlockHash
+		if err := uh.LoadString(arg); err != nil {
+			cmd.UsageFunc()(cmd)
+			cli.Die("failed to parse address:", err)
+		}
+	}
+	if len(args) > 0 {
+		query.Set("unlockhashes", strings.Join(args, ","))
+	}
+	for _, t := range watchCmd.types {
+		if !stream.EventType(t).IsValid() {
+			cmd.UsageFunc()(cmd)
+			cli.Die("invalid event type:", t)
+		}
+	}
+	if len(watchCmd.types) > 0 {
+		query.Set("types", strings.Join(watchCmd.types, ","))
+	}
+	for _, version := range watchCmd.versions {
+		if _, err := strconv.ParseUint(version, 10, 8); err != nil {
+			cmd.UsageFunc()(cmd)
+			cli.Die("invalid transaction version:", version)
+		}
+	}
+	if len(watchCmd.versions) > 0 {
+		query.Set("versions", strings.Join(watchCmd.versions, ","))
+	}
+
+	since := watchCmd.since
+	for {
+		err := watchCmd.consumeStream(query, &since)
+		switch err.(type) {
+		case nil:
+			err = errors.New("event stream ended unexpectedly")
+		case errEventStreamClosed:
+			if since != "" {
+				fmt.Fprintf(os.Stderr, "%v, resuming from consensus change %s\n", err, since)
+				continue
+			}
+		case rivineapi.Error:
+			// the daemon refused the request, retrying won't help
+			cli.DieWithError("failed to watch the chain activity:", err)
+		}
+		if !watchCmd.reconnect {
+			cli.DieWithError("failed to watch the chain activity:", err)
+		}
+		fmt.Fprintf(os.Stderr, "%v, reconnecting in %v\n", err, watchReconnectDelay)
+		time.Sleep(watchReconnectDelay)
+	}
+}
+
+// consumeStream consumes the event stream until it ends,
+// keeping track of the last consensus change received
+func (watchCmd *watchCmd) consumeStream(query url.Values, since *string) error {
+	if *since != "" {
+		query.Set("since", *since)
+	}
+	resp, err := rivineapi.HTTPGet(watchCmd.cli.RootURL+"/events?"+query.Encode(), watchCmd.cli.UserAgent)
+	if err != nil {
+		return fmt.Errorf("no response from daemon: %v", err)
+	}
+	defer resp.Body.Close()
+	if rivineapi.Non2xx(resp.StatusCode) {
+		if resp.StatusCode == http.StatusNotFound {
+			return rivineapi.Error{Message: "event stream not served by daemon, make sure its stream module is loaded"}
+		}
+		return rivineapi.DecodeError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxServerSentEventSize)
+	var id, eventType, data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// an empty line dispatches the event
+			if eventType == api.EventStreamErrorEvent {
+				var apiErr rivineapi.Error
+				json.Unmarshal([]byte(data), &apiErr)
+				return errEventStreamClosed{reason: apiErr.Message}
+			}
+			if data != "" {
+				if err := watchCmd.printEvent(data); err != nil {
+					return err
+				}
+			}
+			if id != "" {
+				*since = id
+			}
+			id, eventType, data = "", "", ""
+		case strings.HasPrefix(line, ":"):
+			// comment, used to keep the connection alive
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	return scanner.Err()
+}
+
+// printEvent prints a single event, as received from the event stream
+func (watchCmd *watchCmd) printEvent(data string) error {
+	if watchCmd.json {
+		fmt.Println(data)
+		return nil
+	}
+	var event stream.Event
+	err := json.Unmarshal([]byte(data), &event)
+	if err != nil {
+		return fmt.Errorf("failed to decode event: %v", err)
+	}
+	switch {
+	case event.Block != nil:
+		fmt.Printf("%-24s #%d %s (%d transaction(s), %s)\n", event.Type, event.Block.Height, event.Block.ID.String(),
+			len(event.Block.TransactionIDs), time.Unix(int64(event.Block.Timestamp), 0).UTC().Format(time.RFC3339))
+	case event.Transaction != nil:
+		if event.Transaction.BlockID != nil {
+			fmt.Printf("%-24s %s v%d in block #%d\n", event.Type, event.Transaction.ID.String(),
+				event.Transaction.Transaction.Version, event.Transaction.Height)
+		} else {
+			fmt.Printf("%-24s %s v%d\n", event.Type, event.Transaction.ID.String(), event.Transaction.Transaction.Version)
+		}
+	case event.MintCondition != nil:
+		condition, err := json.Marshal(event.MintCondition.MintCondition)
+		if err != nil {
+			return fmt.Errorf("failed to encode mint condition: %v", err)
+		}
+		state := "defined"
+		if event.MintCondition.Reverted {
+			state = "reverted"
+		}
+		fmt.Printf("%-24s %s by %s in block #%d: %s\n", event.Type, state,
+			event.MintCondition.TransactionID.String(), event.MintCondition.Height, condition)
+	case event.ConsensusChange != nil:
+		fmt.Printf("%-24s %s at height %d (synced: %v)\n", event.Type, event.ConsensusChange.ID.String(),
+			event.ConsensusChange.Height, event.ConsensusChange.Synced)
+	}
+	return nil
+}
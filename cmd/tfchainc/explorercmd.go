@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/rivine/rivine/encoding"
 	"github.com/rivine/rivine/pkg/cli"
@@ -30,17 +31,46 @@ or the one for the given block height.
 `,
 			Run: explorerSubCmds.getMintCondition,
 		}
+		getSupplyCmd = &cobra.Command{
+			Use:   "supply [height]",
+			Short: "Get a breakdown of the coin supply",
+			Long: `Get a breakdown of the coin supply in liquid, time-locked and atomic-swap-locked coins,
+either for the current block height, or for the given block height.
+A projection of when the time-locked coins unlock is included as well.
+`,
+			Run: explorerSubCmds.getSupply,
+		}
+		exportCmd = &cobra.Command{
+			Use:   "export <address>",
+			Short: "Export the history of all coin movements of an address",
+			Long: `Export the confirmed history of all coin movements of an address, as CSV or JSON,
+listing for each movement its timestamp, block height, transaction ID and version,
+its type (transfer, mint, blockreward, fee or atomicswap), its counterparties,
+the (net) amount of coins received and sent (fees included), the fee paid and the running balance.
+
+All amounts are expressed in the OneCoin unit.
+`,
+			Run: explorerSubCmds.export,
+		}
 	)
 
 	// add commands as wallet sub commands
 	client.ExploreCmd.AddCommand(
 		getMintConditionCmd,
+		getSupplyCmd,
+		exportCmd,
 	)
 
 	// register flags
 	getMintConditionCmd.Flags().Var(
 		cli.NewEncodingTypeFlag(0, &explorerSubCmds.getMintConditionCfg.EncodingType, 0), "encoding",
 		cli.EncodingTypeFlagDescription(0))
+	getSupplyCmd.Flags().Var(
+		cli.NewEncodingTypeFlag(0, &explorerSubCmds.getSupplyCfg.EncodingType, cli.EncodingTypeHuman|cli.EncodingTypeJSON), "encoding",
+		cli.EncodingTypeFlagDescription(cli.EncodingTypeHuman|cli.EncodingTypeJSON))
+	exportCmd.Flags().StringVar(
+		&explorerSubCmds.exportCfg.Format, "format", "csv",
+		"format of the export (csv or json)")
 }
 
 type explorerSubCmds struct {
@@ -48,6 +78,12 @@ type explorerSubCmds struct {
 	getMintConditionCfg struct {
 		EncodingType cli.EncodingType
 	}
+	getSupplyCfg struct {
+		EncodingType cli.EncodingType
+	}
+	exportCfg struct {
+		Format string
+	}
 }
 
 func (explorerSubCmds *explorerSubCmds) getMintCondition(cmd *cobra.Command, args []string) {
@@ -106,3 +142,87 @@ func (explorerSubCmds *explorerSubCmds) getMintCondition(cmd *cobra.Command, arg
 		cli.DieWithError("failed to encode mint condition", err)
 	}
 }
+
+func (explorerSubCmds *explorerSubCmds) getSupply(cmd *cobra.Command, args []string) {
+	var (
+		result api.SupplyDBGetSupplyBreakdown
+		err    error
+	)
+
+	switch len(args) {
+	case 0:
+		// get the supply breakdown for the latest block height
+		err = explorerSubCmds.cli.GetAPI("/explorer/supply/breakdown", &result)
+		if err != nil {
+			cli.DieWithError("failed to get the supply breakdown from the explorer", err)
+		}
+
+	case 1:
+		// get the supply breakdown for a given block height
+		height, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			cmd.UsageFunc()
+			cli.DieWithError("invalid block height given", err)
+		}
+		err = explorerSubCmds.cli.GetAPI(fmt.Sprintf("/explorer/supply/breakdown/%d", height), &result)
+		if err != nil {
+			cli.DieWithError("failed to get the supply breakdown from explorer at the given block height", err)
+		}
+
+	default:
+		cmd.UsageFunc()
+		cli.Die("Invalid amount of arguments. One optional pos argument can be given, a valid block height.")
+	}
+
+	if explorerSubCmds.getSupplyCfg.EncodingType == cli.EncodingTypeJSON {
+		err = json.NewEncoder(os.Stdout).Encode(result)
+		if err != nil {
+			cli.DieWithError("failed to encode supply breakdown", err)
+		}
+		return
+	}
+
+	// print the breakdown in a human-readable format
+	currencyConvertor := explorerSubCmds.cli.CreateCurrencyConvertor()
+	fmt.Printf("Supply at block height %d (%s):\n",
+		result.Height, time.Unix(int64(result.Timestamp), 0).UTC().Format(time.RFC822))
+	fmt.Printf("  Total:              %s\n", currencyConvertor.ToCoinStringWithUnit(result.Total))
+	fmt.Printf("  Liquid:             %s\n", currencyConvertor.ToCoinStringWithUnit(result.Liquid))
+	fmt.Printf("  Time-locked:        %s\n", currencyConvertor.ToCoinStringWithUnit(result.TimeLocked))
+	fmt.Printf("  Atomic-swap-locked: %s\n", currencyConvertor.ToCoinStringWithUnit(result.AtomicSwapLocked))
+	if len(result.Unlocks) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println("Projected unlocks:")
+	for _, unlock := range result.Unlocks {
+		lockTime := time.Unix(int64(unlock.EstimatedTimestamp), 0).UTC().Format(time.RFC822)
+		if unlock.LockTime < rivinetypes.LockTimeMinTimestampValue {
+			lockTime = fmt.Sprintf("block %d (~%s)", unlock.LockTime, lockTime)
+		}
+		fmt.Printf("  %s: +%s (liquid: %s)\n", lockTime,
+			currencyConvertor.ToCoinStringWithUnit(unlock.Value),
+			currencyConvertor.ToCoinStringWithUnit(unlock.Liquid))
+	}
+}
+
+func (explorerSubCmds *explorerSubCmds) export(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <address>")
+	}
+	var uh rivinetypes.UnlockHash
+	err := uh.LoadString(args[0])
+	if err != nil {
+		cmd.UsageFunc()(cmd)
+		cli.Die("failed to parse address:", err)
+	}
+	entries, err := fetchHistory(explorerSubCmds.cli, "/explorer/history/"+uh.String())
+	if err != nil {
+		cli.DieWithError("failed to get address history:", err)
+	}
+	err = writeHistory(os.Stdout, explorerSubCmds.exportCfg.Format, entries, explorerSubCmds.cli.CreateCurrencyConvertor())
+	if err != nil {
+		cli.DieWithError("failed to export address history:", err)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/threefoldfoundation/tfchain/pkg/api"
+	"github.com/threefoldfoundation/tfchain/pkg/fees"
+	"github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/pkg/cli"
+	rivinetypes "github.com/rivine/rivine/types"
+
+	"github.com/spf13/cobra"
+)
+
+// feeAuto is the value of the --fee flag to estimate the miner fee using the daemon
+const feeAuto = "auto"
+
+// feeEstimationRounds is the maximum amount of times the fee of a transaction is estimated,
+// as the size of a transaction can grow because of the estimated fee
+const feeEstimationRounds = 3
+
+// feeCfg defines the miner fee of a transaction created or sent by the CLI
+type feeCfg struct {
+	Fee          string
+	TargetBlocks uint64
+}
+
+// registerFeeFlags registers the flags defining the miner fee of the transaction(s) created or sent by the given command
+func registerFeeFlags(cmd *cobra.Command, cfg *feeCfg) {
+	cmd.Flags().StringVar(
+		&cfg.Fee, "fee", "",
+		"miner fee to pay (at least the minimum transaction fee, which is paid by default), or "+feeAuto+
+			" to estimate the fee required to be confirmed within --fee-blocks blocks")
+	cmd.Flags().Uint64Var(
+		&cfg.TargetBlocks, "fee-blocks", fees.DefaultTargetBlocks,
+		"amount of blocks the transaction is to be confirmed within, only used when using --fee "+feeAuto)
+}
+
+// isAuto returns true in case the miner fee is to be estimated
+func (cfg feeCfg) isAuto() bool {
+	return cfg.Fee == feeAuto
+}
+
+// fixedMinerFee returns the miner fee defined by the --fee flag, the minimum transaction fee if none is defined,
+// and should not be called when the fee is to be estimated
+func (walletSubCmds *walletSubCmds) fixedMinerFee(cfg feeCfg) rivinetypes.Currency {
+	if cfg.Fee == "" {
+		return walletSubCmds.cli.Config.MinimumTransactionFee
+	}
+	fee, err := walletSubCmds.cli.CreateCurrencyConvertor().ParseCoinString(cfg.Fee)
+	if err != nil {
+		cli.DieWithExitCode(cli.ExitCodeUsage, fmt.Sprintf("invalid fee %q: has to be an amount of coins or %s: %v", cfg.Fee, feeAuto, err))
+	}
+	if fee.Cmp(walletSubCmds.cli.Config.MinimumTransactionFee) < 0 {
+		cli.DieWithExitCode(cli.ExitCodeUsage, fmt.Sprintf("invalid fee %q: has to be at least the minimum transaction fee of %s",
+			cfg.Fee, walletSubCmds.cli.CreateCurrencyConvertor().ToCoinStringWithUnit(walletSubCmds.cli.Config.MinimumTransactionFee)))
+	}
+	return fee
+}
+
+// minerFee returns the miner fee to pay for the given transaction, as defined by the --fee flag.
+// The fee is estimated by the daemon when using --fee auto, in which case the transaction
+// has to be signed or have its fulfillments reserved (see fees.ReservedFulfillment),
+// such that its size equals the size of the signed transaction.
+func (walletSubCmds *walletSubCmds) minerFee(cfg feeCfg, txn rivinetypes.Transaction) rivinetypes.Currency {
+	if !cfg.isAuto() {
+		return walletSubCmds.fixedMinerFee(cfg)
+	}
+	fee := walletSubCmds.cli.Config.MinimumTransactionFee
+	for round := 0; round < feeEstimationRounds; round++ {
+		txn.MinerFees = []rivinetypes.Currency{fee}
+		estimate := walletSubCmds.estimateFee(cfg, uint64(len(encoding.Marshal(txn))))
+		if estimate.Fee.Cmp(fee) <= 0 {
+			break
+		}
+		fee = estimate.Fee
+	}
+	return fee
+}
+
+// estimateFee estimates the fee of a transaction of the given size, using the daemon
+func (walletSubCmds *walletSubCmds) estimateFee(cfg feeCfg, size uint64) api.TransactionPoolGetFeeResponse {
+	var estimate api.TransactionPoolGetFeeResponse
+	err := walletSubCmds.cli.GetAPI(fmt.Sprintf("/transactionpool/fee?blocks=%d&size=%d", cfg.TargetBlocks, size), &estimate)
+	if err != nil {
+		cli.Die("failed to estimate the miner fee:", err)
+	}
+	return estimate
+}
+
+// mintMinerFee returns the miner fee to pay for the given unsigned coin creation or minter definition transaction,
+// as defined by the --fee flag, reserving room for its mint fulfillment in case the fee is to be estimated.
+func (walletSubCmds *walletSubCmds) mintMinerFee(cfg feeCfg, txn rivinetypes.Transaction) rivinetypes.Currency {
+	if !cfg.isAuto() {
+		return walletSubCmds.fixedMinerFee(cfg)
+	}
+	switch ext := txn.Extension.(type) {
+	case *types.CoinCreationTransactionExtension:
+		reserved := *ext
+		reserved.MintFulfillment = walletSubCmds.reservedMintFulfillment()
+		txn.Extension = &reserved
+	case *types.MinterDefinitionTransactionExtension:
+		reserved := *ext
+		reserved.MintFulfillment = walletSubCmds.reservedMintFulfillment()
+		txn.Extension = &reserved
+	}
+	return walletSubCmds.minerFee(cfg, txn)
+}
+
+// reservedFeePlaceholder is used as miner fee while computing the size of a transaction
+// whose fee is yet to be estimated, reserving room for any fee that fits in 64 bits
+var reservedFeePlaceholder = rivinetypes.NewCurrency(new(big.Int).Lsh(big.NewInt(1), 64))
+
+// reservedMintFulfillment returns a fulfillment as big as a mint fulfillment signed by all signers
+// of the active mint condition, used to compute the size of an unsigned coin creation or minter definition transaction,
+// once it is signed
+func (walletSubCmds *walletSubCmds) reservedMintFulfillment() rivinetypes.UnlockFulfillmentProxy {
+	mintCondition, err := (&cliMintConditionGetter{client: walletSubCmds.cli}).GetActiveMintCondition()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to get the active mint condition, reserving space for 10 signatures:", err)
+		return fees.ReservedFulfillment(10)
+	}
+	return fees.ReservedFulfillment(len(types.ConditionUnlockHashes(mintCondition)))
+}
+
+// reservedMultiSigFulfillments returns a copy of the given (partially signed) multisig transaction,
+// with the fulfillment of each coin input padded up to the given amount of signatures,
+// such that its size equals the size of the fully signed transaction
+func reservedMultiSigFulfillments(txn rivinetypes.Transaction, signatures uint64) rivinetypes.Transaction {
+	pair := rivinetypes.PublicKeySignaturePair{
+		PublicKey: rivinetypes.Ed25519PublicKey(crypto.PublicKey{}),
+		Signature: make(rivinetypes.ByteSlice, crypto.SignatureSize),
+	}
+	inputs := make([]rivinetypes.CoinInput, len(txn.CoinInputs))
+	for i, ci := range txn.CoinInputs {
+		fulfillment := &rivinetypes.MultiSignatureFulfillment{}
+		if ms, ok := ci.Fulfillment.Fulfillment.(*rivinetypes.MultiSignatureFulfillment); ok {
+			fulfillment.Pairs = append(fulfillment.Pairs, ms.Pairs...)
+		}
+		for uint64(len(fulfillment.Pairs)) < signatures {
+			fulfillment.Pairs = append(fulfillment.Pairs, pair)
+		}
+		inputs[i] = rivinetypes.CoinInput{ParentID: ci.ParentID, Fulfillment: rivinetypes.NewFulfillment(fulfillment)}
+	}
+	txn.CoinInputs = inputs
+	return txn
+}
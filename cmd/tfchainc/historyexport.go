@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/threefoldfoundation/tfchain/pkg/api"
+
+	"github.com/rivine/rivine/pkg/client"
+)
+
+// historyPageSize is the amount of history entries fetched per request
+const historyPageSize = 500
+
+// historyExportColumns are the columns of an exported history, in order
+var historyExportColumns = []string{
+	"timestamp", "blockheight", "transactionid", "version", "type",
+	"counterparties", "in", "out", "fee", "balance",
+}
+
+// fetchHistory fetches all history entries of the given (paginated) history endpoint
+func fetchHistory(cli *client.CommandLineClient, endpoint string) ([]api.HistoryEntry, error) {
+	var (
+		entries []api.HistoryEntry
+		query   = url.Values{"limit": {strconv.Itoa(historyPageSize)}}
+	)
+	for {
+		var page api.HistoryGET
+		err := cli.GetAPI(endpoint+"?"+query.Encode(), &page)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, page.Entries...)
+		if page.Next == "" {
+			return entries, nil
+		}
+		query.Set("cursor", page.Next)
+	}
+}
+
+// writeHistory writes the given history entries to the given writer, in the given format (csv or json),
+// with all amounts expressed in the OneCoin unit.
+func writeHistory(w io.Writer, format string, entries []api.HistoryEntry, currencyConvertor client.CurrencyConvertor) error {
+	records := make([][]string, 0, len(entries))
+	for _, entry := range entries {
+		counterparties := make([]string, 0, len(entry.Counterparties))
+		for _, uh := range entry.Counterparties {
+			counterparties = append(counterparties, uh.String())
+		}
+		records = append(records, []string{
+			time.Unix(int64(entry.Timestamp), 0).UTC().Format(time.RFC3339),
+			strconv.FormatUint(uint64(entry.BlockHeight), 10),
+			entry.TransactionID.String(),
+			strconv.FormatUint(uint64(entry.Version), 10),
+			string(entry.Type),
+			strings.Join(counterparties, " "),
+			currencyConvertor.ToCoinString(entry.In),
+			currencyConvertor.ToCoinString(entry.Out),
+			currencyConvertor.ToCoinString(entry.Fee),
+			currencyConvertor.ToCoinString(entry.Balance),
+		})
+	}
+
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Write(historyExportColumns)
+		writer.WriteAll(records)
+		return writer.Error()
+	case "json":
+		objects := make([]map[string]interface{}, 0, len(records))
+		for idx, record := range records {
+			object := make(map[string]interface{}, len(record))
+			for i, column := range historyExportColumns {
+				object[column] = record[i]
+			}
+			object["blockheight"] = entries[idx].BlockHeight
+			object["version"] = entries[idx].Version
+			object["counterparties"] = entries[idx].Counterparties
+			objects = append(objects, object)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(objects)
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/rivine/rivine/modules"
 	"github.com/rivine/rivine/pkg/client"
+	"github.com/threefoldfoundation/tfchain/pkg/api"
 	"github.com/threefoldfoundation/tfchain/pkg/config"
 	"github.com/threefoldfoundation/tfchain/pkg/types"
 )
@@ -29,9 +30,25 @@ func main() {
 	createConsensusSubCmds(cliClient)
 	createExplorerSubCmds(cliClient)
 	createWalletSubCmds(cliClient)
+	createWalletWatchSubCmds(cliClient)
+	createAtomicSwapSubCmds(cliClient)
+	createProposalSubCmds(cliClient)
+	createPSTXSubCmds(cliClient)
+	createTransactionSubCmds(cliClient)
+	createSignerSubCmds(cliClient)
+	createWatchCmd(cliClient)
+
+	// allow a named wallet to be selected
+	var walletName string
+	cliClient.RootCmd.PersistentFlags().StringVar(&walletName, "wallet", api.DefaultWalletName,
+		"name of the wallet to use for all wallet commands, as served by the daemon using /wallets/:name")
 
 	// define preRun function
 	cliClient.PreRunE = func(cfg *client.Config) (*client.Config, error) {
+		if err := selectWallet(cliClient, walletName); err != nil {
+			return nil, err
+		}
+
 		if cfg == nil {
 			bchainInfo := config.GetBlockchainInfo()
 			chainConstants := config.GetStandardnetGenesis()
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/threefoldfoundation/tfchain/pkg/config"
+	"github.com/threefoldfoundation/tfchain/pkg/signer"
+	"github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/bgentry/speakeasy"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/client"
+	rivinetypes "github.com/rivine/rivine/types"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// offlineCmd makes the given command work fully offline,
+// replacing the pre-run logic of the client (which fetches the config from the daemon)
+// with logic that creates the config locally, for the network given by the --network flag.
+func offlineCmd(cliClient *client.CommandLineClient, cmd *cobra.Command) {
+	var network string
+	cmd.Flags().StringVar(
+		&network, "network", config.NetworkNameStandard,
+		fmt.Sprintf("the network the command is used for, one of: %s, %s, %s",
+			config.NetworkNameStandard, config.NetworkNameTest, config.NetworkNameDev))
+	cmd.PersistentPreRunE = func(*cobra.Command, []string) error {
+		bchainInfo := config.GetBlockchainInfo()
+		bchainInfo.NetworkName = network
+		chainConstants, err := networkChainConstants(network)
+		if err != nil {
+			return err
+		}
+		cfg := client.ConfigFromDaemonConstants(modules.NewDaemonConstants(bchainInfo, chainConstants))
+		newCfg, err := cliClient.PreRunE(&cfg)
+		if err != nil {
+			return fmt.Errorf("user-defined pre-run callback failed: %v", err)
+		}
+		cliClient.Config = newCfg
+		return nil
+	}
+}
+
+// networkChainConstants returns the chain constants of the given network
+func networkChainConstants(network string) (rivinetypes.ChainConstants, error) {
+	switch network {
+	case config.NetworkNameStandard:
+		return config.GetStandardnetGenesis(), nil
+	case config.NetworkNameTest:
+		return config.GetTestnetGenesis(), nil
+	case config.NetworkNameDev:
+		return config.GetDevnetGenesis(), nil
+	default:
+		return rivinetypes.ChainConstants{}, fmt.Errorf("network name %q not recognized", network)
+	}
+}
+
+// offlineKeysCfg defines where the keys used for offline signing come from,
+// which is either a keyfile, a seed file, a mnemonic entered at a prompt,
+// or an external signer, in which case the keys never enter this process.
+type offlineKeysCfg struct {
+	KeyFile    string
+	SeedFile   string
+	KeyCount   uint64
+	Signer     string
+	SignerArgs []string
+}
+
+// registerFlags registers the flags used to define the offline keys
+func (cfg *offlineKeysCfg) registerFlags(flags *pflag.FlagSet) {
+	flags.StringVar(
+		&cfg.KeyFile, "keyfile", "",
+		"file containing the hex-encoded ed25519 secret keys to sign with, one per line")
+	flags.StringVar(
+		&cfg.SeedFile, "seedfile", "",
+		"file containing the bip39 mnemonic or the hex-encoded seed to derive the keys to sign with from")
+	flags.Uint64Var(
+		&cfg.KeyCount, "keycount", modules.PublicKeysPerSeed,
+		"the amount of keys to derive from the seed")
+	flags.StringVar(
+		&cfg.Signer, "signer", "",
+		"external signer to sign with, instead of local keys, as unix:<socket path> or exec:<command>")
+	flags.StringArrayVar(
+		&cfg.SignerArgs, "signer-arg", nil,
+		"argument passed to the command of an executable external signer, repeat the flag for multiple arguments")
+}
+
+// signers returns a signer for each key to sign with,
+// using the external signer if defined, and the local keys otherwise
+func (cfg *offlineKeysCfg) signers() ([]types.Signer, error) {
+	if cfg.Signer == "" {
+		keys, err := cfg.load()
+		if err != nil {
+			return nil, err
+		}
+		signers := make([]types.Signer, 0, len(keys))
+		for _, sk := range keys {
+			signers = append(signers, types.NewKeySigner(sk))
+		}
+		return signers, nil
+	}
+	if cfg.KeyFile != "" || cfg.SeedFile != "" {
+		return nil, errors.New("an external signer cannot be used together with a keyfile or seedfile")
+	}
+	signerClient, err := signer.Open(cfg.Signer, cfg.SignerArgs...)
+	if err != nil {
+		return nil, err
+	}
+	signers, err := signerClient.Signers()
+	if err != nil {
+		return nil, err
+	}
+	if len(signers) == 0 {
+		return nil, errors.New("external signer has no keys to sign with")
+	}
+	return signers, nil
+}
+
+// load the secret keys, from the keyfile or seed file if defined,
+// prompting for the mnemonic otherwise
+func (cfg *offlineKeysCfg) load() ([]crypto.SecretKey, error) {
+	if cfg.KeyFile != "" {
+		if cfg.SeedFile != "" {
+			return nil, errors.New("a keyfile and seedfile cannot be used at the same time")
+		}
+		return signer.ReadKeyFile(cfg.KeyFile)
+	}
+
+	var seedStr string
+	if cfg.SeedFile != "" {
+		b, err := ioutil.ReadFile(cfg.SeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read seed file: %v", err)
+		}
+		seedStr = string(b)
+	} else {
+		var err error
+		seedStr, err = speakeasy.Ask("Enter the mnemonic of the seed to sign with: ")
+		if err != nil {
+			return nil, fmt.Errorf("reading mnemonic failed: %v", err)
+		}
+	}
+	seed, err := parseSeed(strings.TrimSpace(seedStr))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.KeyCount == 0 {
+		return nil, errors.New("key count has to be greater than 0")
+	}
+	keys := make([]crypto.SecretKey, 0, cfg.KeyCount)
+	for index := uint64(0); index < cfg.KeyCount; index++ {
+		// derive the keys the same way as the wallet does
+		sk, _ := crypto.GenerateKeyPairDeterministic(crypto.HashAll(seed, index))
+		keys = append(keys, sk)
+	}
+	return keys, nil
+}
+
+// parseSeed parses a seed, given as a bip39 mnemonic or as a hex-encoded string
+func parseSeed(str string) (modules.Seed, error) {
+	if b, err := hex.DecodeString(str); err == nil {
+		var seed modules.Seed
+		if len(b) != len(seed) {
+			return modules.Seed{}, fmt.Errorf("invalid seed length %d, expected %d bytes", len(b), len(seed))
+		}
+		copy(seed[:], b)
+		return seed, nil
+	}
+	seed, err := modules.InitialSeedFromMnemonic(str)
+	if err != nil {
+		return modules.Seed{}, fmt.Errorf("seed is neither a valid mnemonic nor a hex-encoded seed: %v", err)
+	}
+	return seed, nil
+}
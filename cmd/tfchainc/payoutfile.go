@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	rivinetypes "github.com/rivine/rivine/types"
+)
+
+// payoutRow is a single row of a payout CSV file,
+// defining an amount of coins to be paid to an address
+type payoutRow struct {
+	// Line is the (1-indexed) line of the payout file the row starts at
+	Line    int
+	Address rivinetypes.UnlockHash
+	Value   rivinetypes.Currency
+	Memo    string
+}
+
+// readPayoutFile reads all rows of a payout file, given as a file path, or as "-" for the STDIN,
+// using the given format (csv or json). If no format is given,
+// it is derived from the file extension, defaulting to csv.
+func readPayoutFile(path, format string, parseCurrency parseCurrencyString) ([]payoutRow, error) {
+	if format == "" {
+		format = "csv"
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			format = "json"
+		}
+	}
+	switch strings.ToLower(format) {
+	case "csv":
+		return readPayoutCSV(path, parseCurrency)
+	case "json":
+		return readPayoutJSON(path, parseCurrency)
+	default:
+		return nil, fmt.Errorf("unsupported payout file format %q", format)
+	}
+}
+
+// readPayoutCSV reads all rows of a payout CSV file, given as a file path, or as "-" for the STDIN.
+// Each row consists of an address, an amount (expressed in the OneCoin unit) and an optional memo.
+// An optional header row (starting with "address") is skipped, as are empty lines and lines starting with '#'.
+// Each row has to fit on a single line.
+//
+// All rows are validated, and all invalid rows are reported at once, as a single error.
+func readPayoutCSV(path string, parseCurrency parseCurrencyString) ([]payoutRow, error) {
+	r, err := openPayoutFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var (
+		rows    []payoutRow
+		invalid []string
+		line    int
+		first   = true
+	)
+	// the lines are read one by one, rather than all records by a single CSV reader,
+	// such that the line of each record is known, as comments and empty lines are skipped
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		reader := csv.NewReader(strings.NewReader(text))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		record, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: line %d: %v", line, err)
+		}
+		if first {
+			first = false
+			if strings.EqualFold(strings.TrimSpace(record[0]), "address") {
+				continue // header
+			}
+		}
+		if len(record) < 2 || len(record) > 3 {
+			invalid = append(invalid, fmt.Sprintf("line %d: expected 2 or 3 fields (address, amount, memo), got %d", line, len(record)))
+			continue
+		}
+		row := payoutRow{Line: line}
+		err = row.Address.LoadString(strings.TrimSpace(record[0]))
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("line %d: invalid address %q: %v", line, record[0], err))
+			continue
+		}
+		row.Value, err = parseCurrency(strings.TrimSpace(record[1]))
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("line %d: invalid amount %q: %v", line, record[1], err))
+			continue
+		}
+		if row.Value.IsZero() {
+			invalid = append(invalid, fmt.Sprintf("line %d: amount cannot be zero", line))
+			continue
+		}
+		if len(record) == 3 {
+			row.Memo = strings.TrimSpace(record[2])
+		}
+		rows = append(rows, row)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %v", err)
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("%d invalid row(s):\n  %s", len(invalid), strings.Join(invalid, "\n  "))
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no rows found in %s", path)
+	}
+	return rows, nil
+}
+
+// readPayoutJSON reads all rows of a payout JSON file, given as a file path, or as "-" for the STDIN.
+// The file contains a single JSON array, with each element an object
+// defining an address, an amount (expressed in the OneCoin unit, as a string) and an optional memo.
+//
+// All rows are validated, and all invalid rows are reported at once, as a single error.
+func readPayoutJSON(path string, parseCurrency parseCurrencyString) ([]payoutRow, error) {
+	r, err := openPayoutFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	type payoutRecord struct {
+		Address string `json:"address"`
+		Amount  string `json:"amount"`
+		Memo    string `json:"memo"`
+	}
+	// decode the elements one by one, such that the line each element starts at is known
+	br := bytes.NewReader(b)
+	decoder := json.NewDecoder(br)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, errors.New("failed to decode JSON: expected an array of payouts")
+	}
+
+	var (
+		rows    []payoutRow
+		invalid []string
+	)
+	for decoder.More() {
+		// the offset of the next element is the amount of bytes read by the decoder,
+		// minus those it buffered but didn't consume yet
+		buffered, err := ioutil.ReadAll(decoder.Buffered())
+		if err != nil {
+			return nil, err
+		}
+		offset := int64(len(b)-br.Len()) - int64(len(buffered))
+		for offset < int64(len(b)) && strings.IndexByte(" \t\r\n,", b[offset]) >= 0 {
+			offset++
+		}
+		var record payoutRecord
+		err = decoder.Decode(&record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode JSON: %v", err)
+		}
+		row := payoutRow{Line: 1 + bytes.Count(b[:offset], []byte{'\n'}), Memo: record.Memo}
+		err = row.Address.LoadString(record.Address)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("line %d: invalid address %q: %v", row.Line, record.Address, err))
+			continue
+		}
+		row.Value, err = parseCurrency(record.Amount)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("line %d: invalid amount %q: %v", row.Line, record.Amount, err))
+			continue
+		}
+		if row.Value.IsZero() {
+			invalid = append(invalid, fmt.Sprintf("line %d: amount cannot be zero", row.Line))
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("%d invalid row(s):\n  %s", len(invalid), strings.Join(invalid, "\n  "))
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no rows found in %s", path)
+	}
+	return rows, nil
+}
+
+// openPayoutFile opens the given payout file, or the STDIN in case the path is "-"
+func openPayoutFile(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rivine/rivine/pkg/client"
+	rivinetypes "github.com/rivine/rivine/types"
+)
+
+func TestReadPayoutFileLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "payoutfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var uh1, uh2 rivinetypes.UnlockHash
+	uh1.Type, uh1.Hash[0] = rivinetypes.UnlockTypePubKey, 1
+	uh2.Type, uh2.Hash[0] = rivinetypes.UnlockTypePubKey, 2
+	addr1, addr2 := uh1.String(), uh2.String()
+	parseCurrency := client.NewCurrencyConvertor(rivinetypes.DefaultCurrencyUnits(), "TFT").ParseCoinString
+
+	testCases := []struct {
+		Name    string
+		Content string
+		// Lines of the rows, or of the invalid rows if Invalid is true
+		Lines   []int
+		Invalid bool
+	}{
+		{
+			Name: "payouts.csv",
+			Content: "# payouts of this month\n" +
+				"address,amount,memo\n" +
+				"\n" +
+				addr1 + ",10,first\n" +
+				"   # indented comment\n" +
+				"\n" +
+				"\n" +
+				addr2 + ", 2.5\n",
+			Lines: []int{4, 8},
+		},
+		{
+			Name: "invalid.csv",
+			Content: "\n" +
+				"# comment\n" +
+				addr1 + ",10\n" +
+				"\n" +
+				addr2 + ",0\n" +
+				"# comment\n" +
+				"foo,1\n",
+			Lines:   []int{5, 7},
+			Invalid: true,
+		},
+		{
+			Name: "payouts.json",
+			Content: "\n[\n" +
+				"  {\"address\": \"" + addr1 + "\", \"amount\": \"10\", \"memo\": \"first\"},\n" +
+				"\n" +
+				"  {\n    \"address\": \"" + addr2 + "\",\n    \"amount\": \"2.5\"\n  }\n" +
+				"\n" +
+				"  , {\"address\": \"" + addr1 + "\", \"amount\": \"1\"}\n" +
+				"]\n",
+			Lines: []int{3, 5, 10},
+		},
+		{
+			Name: "invalid.json",
+			Content: "[\n" +
+				"  {\"address\": \"" + addr1 + "\", \"amount\": \"10\"},\n" +
+				"\n" +
+				"  {\"address\": \"foo\", \"amount\": \"1\"},\n" +
+				"  {\"address\": \"" + addr2 + "\",\n    \"amount\": \"0\"}\n" +
+				"]",
+			Lines:   []int{4, 5},
+			Invalid: true,
+		},
+	}
+	for _, testCase := range testCases {
+		path := filepath.Join(dir, testCase.Name)
+		if err = ioutil.WriteFile(path, []byte(testCase.Content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := readPayoutFile(path, "", parseCurrency)
+		if testCase.Invalid {
+			if err == nil {
+				t.Errorf("%s: expected invalid rows", testCase.Name)
+				continue
+			}
+			var lines []int
+			for _, msg := range strings.Split(err.Error(), "\n")[1:] {
+				var line int
+				fmt.Sscanf(strings.TrimSpace(msg), "line %d:", &line)
+				lines = append(lines, line)
+			}
+			if !reflect.DeepEqual(lines, testCase.Lines) {
+				t.Errorf("%s: expected invalid rows at lines %v, not %v: %v", testCase.Name, testCase.Lines, lines, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", testCase.Name, err)
+			continue
+		}
+		var lines []int
+		for _, row := range rows {
+			lines = append(lines, row.Line)
+		}
+		if !reflect.DeepEqual(lines, testCase.Lines) {
+			t.Errorf("%s: expected rows at lines %v, not %v", testCase.Name, testCase.Lines, lines)
+		}
+	}
+}
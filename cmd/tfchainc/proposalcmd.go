@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/threefoldfoundation/tfchain/pkg/api"
+	"github.com/threefoldfoundation/tfchain/pkg/modules/proposals"
+
+	rivineapi "github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/pkg/cli"
+	"github.com/rivine/rivine/pkg/client"
+	rivinetypes "github.com/rivine/rivine/types"
+
+	"github.com/spf13/cobra"
+)
+
+func createProposalSubCmds(client *client.CommandLineClient) {
+	proposalSubCmds := &proposalSubCmds{cli: client}
+
+	// define commands
+	var (
+		rootCmd = &cobra.Command{
+			Use:   "proposal",
+			Short: "Create, list and co-sign transaction proposals",
+			Long: `Create, list and co-sign transaction proposals,
+using the proposal pool module of the daemon.
+
+A proposal is an unsigned (or partially signed) transaction,
+such as a coin creation, minter definition or multisig transaction,
+which is broadcasted by the daemon as soon as all its co-signers signed it.
+`,
+		}
+		submitCmd = &cobra.Command{
+			Use:   "submit <txnjson>",
+			Short: "Submit an unsigned transaction as a new proposal",
+			Long: `Submit an unsigned (or partially signed) transaction as a new proposal,
+such that it can be signed by all its co-signers.
+The ID of the created proposal is printed to the STDOUT.
+`,
+			Run: proposalSubCmds.submit,
+		}
+		listCmd = &cobra.Command{
+			Use:   "list",
+			Short: "List the pending proposals relevant to this wallet",
+			Long: `List all pending proposals which can be signed by one
+or multiple addresses of this wallet. Use the --all flag to list
+all proposals known by the proposal pool instead.
+`,
+			Run: proposalSubCmds.list,
+		}
+		getCmd = &cobra.Command{
+			Use:   "get <id>",
+			Short: "Get a single proposal",
+			Long:  "Get a single proposal as a JSON object, including who can sign it and who already signed it.",
+			Run:   proposalSubCmds.get,
+		}
+		signCmd = &cobra.Command{
+			Use:   "sign <id>",
+			Short: "Sign a pending proposal using this wallet",
+			Long: `Sign a pending proposal using all keys of this wallet that can sign it,
+adding the signatures to the proposal. The proposal is broadcasted by the daemon
+as soon as all its conditions are fulfilled.
+`,
+			Run: proposalSubCmds.sign,
+		}
+	)
+
+	// add proposal commands as a new root command
+	rootCmd.AddCommand(
+		submitCmd,
+		listCmd,
+		getCmd,
+		signCmd,
+	)
+	client.RootCmd.AddCommand(rootCmd)
+
+	// register flags
+	submitCmd.Flags().StringVar(
+		&proposalSubCmds.submitCfg.Description, "description", "",
+		"optionally add a description to the proposal, informing the co-signers about its purpose")
+	submitCmd.Flags().DurationVar(
+		&proposalSubCmds.submitCfg.Lifetime, "lifetime", 0,
+		fmt.Sprintf("optional lifetime of the proposal, defaults to %v if not defined", proposals.DefaultLifetime))
+	listCmd.Flags().BoolVar(
+		&proposalSubCmds.listCfg.All, "all", false,
+		"list all proposals, instead of only the pending proposals which can be signed by this wallet")
+}
+
+type proposalSubCmds struct {
+	cli       *client.CommandLineClient
+	submitCfg struct {
+		Description string
+		Lifetime    time.Duration
+	}
+	listCfg struct {
+		All bool
+	}
+}
+
+func (proposalSubCmds *proposalSubCmds) submit(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <txnjson>")
+	}
+	body := api.ProposalsPostProposal{
+		Description: proposalSubCmds.submitCfg.Description,
+		Lifetime:    uint64(proposalSubCmds.submitCfg.Lifetime / time.Second),
+	}
+	err := json.Unmarshal([]byte(args[0]), &body.Transaction)
+	if err != nil {
+		cmd.UsageFunc()(cmd)
+		cli.Die("failed to parse transaction:", err)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		cli.Die("failed to create/marshal JSON body:", err)
+	}
+
+	var resp api.ProposalsGetProposal
+	err = proposalSubCmds.cli.PostResp("/proposals", string(data), &resp)
+	if err != nil {
+		cli.DieWithError("failed to submit proposal:", err)
+	}
+	if resp.Status == proposals.StatusBroadcasted {
+		fmt.Fprintf(os.Stderr, "proposal was already fully signed and broadcasted as transaction %s\n", resp.TransactionID.String())
+	}
+	fmt.Println(resp.ID.String())
+}
+
+func (proposalSubCmds *proposalSubCmds) list(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. No arguments are expected.")
+	}
+
+	var result []proposals.Proposal
+	if proposalSubCmds.listCfg.All {
+		var resp api.ProposalsGetProposals
+		err := proposalSubCmds.cli.GetAPI("/proposals", &resp)
+		if err != nil {
+			cli.DieWithError("failed to get proposals:", err)
+		}
+		result = resp.Proposals
+	} else {
+		var addresses rivineapi.WalletAddressesGET
+		err := proposalSubCmds.cli.GetAPI("/wallet/addresses", &addresses)
+		if err != nil {
+			cli.DieWithError("failed to get wallet addresses:", err)
+		}
+		known := make(map[string]struct{})
+		for _, address := range addresses.Addresses {
+			query := url.Values{}
+			query.Set("unlockhash", address.String())
+			query.Set("status", string(proposals.StatusPending))
+			var resp api.ProposalsGetProposals
+			err = proposalSubCmds.cli.GetAPI("/proposals?"+query.Encode(), &resp)
+			if err != nil {
+				cli.DieWithError("failed to get proposals for "+address.String()+":", err)
+			}
+			for _, proposal := range resp.Proposals {
+				if _, ok := known[proposal.ID.String()]; ok {
+					continue
+				}
+				known[proposal.ID.String()] = struct{}{}
+				result = append(result, proposal)
+			}
+		}
+	}
+	if len(result) == 0 {
+		fmt.Println("No proposals found.")
+		return
+	}
+
+	fmt.Println("                                                            [id]       [status] [fulfilled]            [expires]  [description]")
+	for _, proposal := range result {
+		var fulfilled int
+		for _, requirement := range proposal.Requirements {
+			if requirement.Fulfilled {
+				fulfilled++
+			}
+		}
+		fmt.Printf("%64s %14s %11s %20s  %s\n", proposal.ID.String(), proposal.Status,
+			fmt.Sprintf("%d/%d", fulfilled, len(proposal.Requirements)),
+			time.Unix(int64(proposal.Expires), 0).Format(time.RFC822), proposal.Description)
+	}
+}
+
+func (proposalSubCmds *proposalSubCmds) get(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <id>")
+	}
+	proposal := proposalSubCmds.getProposal(args[0])
+	err := json.NewEncoder(os.Stdout).Encode(proposal)
+	if err != nil {
+		cli.DieWithError("failed to encode proposal", err)
+	}
+}
+
+func (proposalSubCmds *proposalSubCmds) sign(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <id>")
+	}
+	proposal := proposalSubCmds.getProposal(args[0])
+	if proposal.Status != proposals.StatusPending {
+		cli.Die(fmt.Sprintf("cannot sign proposal with status %q", proposal.Status))
+	}
+
+	// sign the proposed transaction using the wallet
+	data, err := json.Marshal(proposal.Transaction)
+	if err != nil {
+		cli.Die("failed to marshal proposed transaction:", err)
+	}
+	var signed rivinetypes.Transaction
+	err = proposalSubCmds.cli.PostResp("/wallet/sign", string(data), &signed)
+	if err != nil {
+		cli.DieWithError("failed to sign proposed transaction:", err)
+	}
+
+	// add the signatures to the proposal
+	data, err = json.Marshal(api.ProposalsPostFulfillments{
+		Transaction: signed,
+	})
+	if err != nil {
+		cli.Die("failed to create/marshal JSON body:", err)
+	}
+	var resp api.ProposalsGetProposal
+	err = proposalSubCmds.cli.PostResp("/proposals/"+proposal.ID.String()+"/fulfillments", string(data), &resp)
+	if err != nil {
+		cli.DieWithError("failed to add signatures to proposal:", err)
+	}
+	switch {
+	case resp.Status == proposals.StatusBroadcasted:
+		fmt.Printf("proposal was fully signed and broadcasted as transaction %s\n", resp.TransactionID.String())
+	case resp.Error != "":
+		fmt.Println("proposal was signed, but could not be broadcasted:", resp.Error)
+	default:
+		fmt.Println("proposal was signed, but still requires more signatures")
+	}
+}
+
+func (proposalSubCmds *proposalSubCmds) getProposal(str string) api.ProposalsGetProposal {
+	var resp api.ProposalsGetProposal
+	err := proposalSubCmds.cli.GetAPI("/proposals/"+str, &resp)
+	if err != nil {
+		if err == rivineapi.ErrStatusNotFound {
+			cli.DieWithExitCode(cli.ExitCodeNotFound, "proposal not found")
+		}
+		cli.DieWithError("failed to get proposal:", err)
+	}
+	return resp
+}
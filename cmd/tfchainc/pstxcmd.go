@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/pkg/cli"
+	"github.com/rivine/rivine/pkg/client"
+	rivinetypes "github.com/rivine/rivine/types"
+
+	"github.com/spf13/cobra"
+)
+
+func createPSTXSubCmds(client *client.CommandLineClient) {
+	pstxSubCmds := &pstxSubCmds{cli: client}
+
+	// define commands
+	var (
+		rootCmd = &cobra.Command{
+			Use:   "pstx",
+			Short: "Create, inspect, sign, combine and finalize partially signed transactions",
+			Long: `Create, inspect, sign, combine and finalize partially signed transaction (PSTX) containers.
+
+A PSTX container wraps an unsigned (or partially signed) transaction,
+together with the parent outputs of all its inputs, the mint condition for
+minter definition and coin creation transactions, labels for the signers
+and an optional description of the intent of the transaction.
+
+Only creating a container requires a daemon, all other commands work fully offline.
+Containers can be given as a JSON string, a file path, or '-' to read from STDIN.
+`,
+		}
+		createCmd = &cobra.Command{
+			Use:   "create <txnjson>",
+			Short: "Create a new PSTX container for a transaction",
+			Long: `Create a new PSTX container for the given (unsigned) transaction,
+resolving the parent outputs of all inputs, and the active mint condition if required,
+using the daemon. The container is printed to the STDOUT.
+`,
+			Run: pstxSubCmds.create,
+		}
+		inspectCmd = &cobra.Command{
+			Use:   "inspect <pstx>",
+			Short: "Inspect a PSTX container",
+			Long: `Inspect a PSTX container, listing for each fulfillment who can sign it,
+who already signed it, and whether or not it is fulfilled.
+`,
+			Run: pstxSubCmds.inspect,
+		}
+		signCmd = &cobra.Command{
+			Use:   "sign <pstx>",
+			Short: "Sign a PSTX container offline",
+			Long: `Sign all fulfillments of a PSTX container which can be signed using the given keys.
+
+The keys are read from a keyfile, containing one hex-encoded ed25519 secret key per line
+(empty lines and lines starting with '#' are ignored), or derived from a seed,
+given as a seed file (containing a mnemonic or hex-encoded seed) or as a mnemonic entered at the prompt.
+The signed container is printed to the STDOUT.
+`,
+			Run: pstxSubCmds.sign,
+		}
+		combineCmd = &cobra.Command{
+			Use:   "combine <pstx> <pstx> [<pstx>...]",
+			Short: "Combine the signatures of multiple PSTX containers",
+			Long: `Combine the signatures and signer labels of multiple PSTX containers,
+which all wrap the same transaction. The combined container is printed to the STDOUT.
+`,
+			Run: pstxSubCmds.combine,
+		}
+		finalizeCmd = &cobra.Command{
+			Use:   "finalize <pstx>",
+			Short: "Extract the fully signed transaction from a PSTX container",
+			Long: `Extract the transaction from a PSTX container, in case all its fulfillments are fulfilled.
+The transaction is printed to the STDOUT, and can be published using 'wallet send transaction'.
+
+As the block height isn't known offline, time locks defined as a block height are not checked,
+while time locks defined as a timestamp are checked against the current time.
+`,
+			Run: pstxSubCmds.finalize,
+		}
+	)
+
+	// add pstx commands as a new root command
+	rootCmd.AddCommand(
+		createCmd,
+		inspectCmd,
+		signCmd,
+		combineCmd,
+		finalizeCmd,
+	)
+	client.RootCmd.AddCommand(rootCmd)
+
+	// all commands, except for the create command, work fully offline
+	for _, cmd := range []*cobra.Command{inspectCmd, signCmd, combineCmd, finalizeCmd} {
+		offlineCmd(client, cmd)
+	}
+
+	// register flags
+	createCmd.Flags().StringVar(
+		&pstxSubCmds.createCfg.Description, "description", "",
+		"optionally describe the intent of the transaction for its signers")
+	createCmd.Flags().StringSliceVar(
+		&pstxSubCmds.createCfg.Labels, "label", nil,
+		"optionally label a signer, using the format <address>=<label>, can be given multiple times")
+	pstxSubCmds.signCfg.registerFlags(signCmd.Flags())
+}
+
+type pstxSubCmds struct {
+	cli       *client.CommandLineClient
+	createCfg struct {
+		Description string
+		Labels      []string
+	}
+	signCfg offlineKeysCfg
+}
+
+func (pstxSubCmds *pstxSubCmds) create(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <txnjson>")
+	}
+	var txn rivinetypes.Transaction
+	err := json.Unmarshal([]byte(args[0]), &txn)
+	if err != nil {
+		cmd.UsageFunc()(cmd)
+		cli.Die("failed to parse transaction:", err)
+	}
+
+	pstx := types.NewPartiallySignedTransaction(txn)
+	pstx.Description = pstxSubCmds.createCfg.Description
+	for _, label := range pstxSubCmds.createCfg.Labels {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) != 2 {
+			cli.DieWithExitCode(cli.ExitCodeUsage, fmt.Sprintf("invalid label %q, expected format <address>=<label>", label))
+		}
+		var uh rivinetypes.UnlockHash
+		err = uh.LoadString(parts[0])
+		if err != nil {
+			cli.DieWithExitCode(cli.ExitCodeUsage, fmt.Sprintf("invalid address in label %q: %v", label, err))
+		}
+		pstx.SetLabel(uh, parts[1])
+	}
+
+	// resolve the parent outputs and mint condition using the daemon
+	for _, ci := range txn.CoinInputs {
+		var resp api.ConsensusGetUnspentCoinOutput
+		err = pstxSubCmds.cli.GetAPI("/consensus/unspent/coinoutputs/"+ci.ParentID.String(), &resp)
+		if err != nil {
+			cli.DieWithError("failed to get parent output "+ci.ParentID.String()+" of coin input:", err)
+		}
+		pstx.CoinInputs = append(pstx.CoinInputs, types.PSTXParentOutput{
+			Value:     resp.Output.Value,
+			Condition: resp.Output.Condition,
+		})
+	}
+	for _, bsi := range txn.BlockStakeInputs {
+		var resp api.ConsensusGetUnspentBlockstakeOutput
+		err = pstxSubCmds.cli.GetAPI("/consensus/unspent/blockstakeoutputs/"+bsi.ParentID.String(), &resp)
+		if err != nil {
+			cli.DieWithError("failed to get parent output "+bsi.ParentID.String()+" of block stake input:", err)
+		}
+		pstx.BlockStakeInputs = append(pstx.BlockStakeInputs, types.PSTXParentOutput{
+			Value:     resp.Output.Value,
+			Condition: resp.Output.Condition,
+		})
+	}
+	switch txn.Version {
+	case types.TransactionVersionMinterDefinition, types.TransactionVersionCoinCreation:
+		mintConditionGetter := &cliMintConditionGetter{client: pstxSubCmds.cli}
+		mintCondition, err := mintConditionGetter.GetActiveMintCondition()
+		if err != nil {
+			cli.DieWithError("failed to get mint condition:", err)
+		}
+		pstx.MintCondition = &mintCondition
+	}
+
+	pstxSubCmds.encode(pstx)
+}
+
+func (pstxSubCmds *pstxSubCmds) inspect(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <pstx>")
+	}
+	pstx := readPSTX(args[0])
+	fulfillments, err := pstx.Fulfillments(offlineFulfillableContext())
+	if err != nil {
+		cli.DieWithError("failed to inspect PSTX:", err)
+	}
+
+	currencyConvertor := pstxSubCmds.cli.CreateCurrencyConvertor()
+	if types.TransactionHasNilFulfillment(pstx.Transaction) {
+		fmt.Printf("transaction: ID unknown until fully signed (version %d)\n", pstx.Transaction.Version)
+	} else {
+		fmt.Printf("transaction: %s (version %d)\n", pstx.Transaction.ID().String(), pstx.Transaction.Version)
+	}
+	if pstx.Description != "" {
+		fmt.Println("description:", pstx.Description)
+	}
+	var inputs, outputs, fees rivinetypes.Currency
+	for _, parent := range pstx.CoinInputs {
+		inputs = inputs.Add(parent.Value)
+	}
+	for _, co := range pstx.Transaction.CoinOutputs {
+		outputs = outputs.Add(co.Value)
+	}
+	for _, fee := range pstx.Transaction.MinerFees {
+		fees = fees.Add(fee)
+	}
+	fmt.Printf("coins: %s in, %s out, %s miner fees\n",
+		currencyConvertor.ToCoinStringWithUnit(inputs),
+		currencyConvertor.ToCoinStringWithUnit(outputs),
+		currencyConvertor.ToCoinStringWithUnit(fees))
+	for _, co := range pstx.Transaction.CoinOutputs {
+		fmt.Printf("  -> %s to %s\n", currencyConvertor.ToCoinStringWithUnit(co.Value), co.Condition.UnlockHash().String())
+	}
+
+	var fulfilled int
+	for _, fulfillment := range fulfillments {
+		status := "NOT fulfilled"
+		if fulfillment.Fulfilled {
+			status = "fulfilled"
+			fulfilled++
+		}
+		fmt.Printf("%s #%d: %s\n", fulfillment.Kind, fulfillment.Index, status)
+		for _, uh := range fulfillment.Signers {
+			signed := " "
+			for _, suh := range fulfillment.Signed {
+				if suh.Cmp(uh) == 0 {
+					signed = "x"
+					break
+				}
+			}
+			label := pstx.Label(uh)
+			if label != "" {
+				label = " (" + label + ")"
+			}
+			fmt.Printf("  [%s] %s%s\n", signed, uh.String(), label)
+		}
+	}
+	fmt.Printf("%d out of %d fulfillment(s) fulfilled\n", fulfilled, len(fulfillments))
+}
+
+func (pstxSubCmds *pstxSubCmds) sign(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <pstx>")
+	}
+	pstx := readPSTX(args[0])
+	signers, err := pstxSubCmds.signCfg.signers()
+	if err != nil {
+		cli.DieWithError("failed to load keys:", err)
+	}
+	signatures, err := signPSTX(&pstx, signers)
+	if err != nil {
+		cli.DieWithError("failed to sign PSTX:", err)
+	}
+	fmt.Fprintf(os.Stderr, "added %d signature(s)\n", signatures)
+	pstxSubCmds.encode(pstx)
+}
+
+func (pstxSubCmds *pstxSubCmds) combine(cmd *cobra.Command, args []string) {
+	if len(args) < 2 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. At least two arguments have to be given: <pstx> <pstx> [<pstx>...]")
+	}
+	pstx := readPSTX(args[0])
+	for idx, arg := range args[1:] {
+		err := pstx.Combine(readPSTX(arg))
+		if err != nil {
+			cli.DieWithError(fmt.Sprintf("failed to combine PSTX #%d:", idx+2), err)
+		}
+	}
+	pstxSubCmds.encode(pstx)
+}
+
+func (pstxSubCmds *pstxSubCmds) finalize(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <pstx>")
+	}
+	pstx := readPSTX(args[0])
+	txn, err := pstx.Finalize(offlineFulfillableContext())
+	if err != nil {
+		cli.DieWithError("failed to finalize PSTX:", err)
+	}
+	json.NewEncoder(os.Stdout).Encode(txn)
+}
+
+func (pstxSubCmds *pstxSubCmds) encode(pstx types.PartiallySignedTransaction) {
+	err := pstx.Encode(os.Stdout)
+	if err != nil {
+		cli.DieWithError("failed to encode PSTX:", err)
+	}
+}
+
+// readPSTX reads a PSTX container, given as a JSON string,
+// a file path, or '-' in order to read it from STDIN
+func readPSTX(arg string) types.PartiallySignedTransaction {
+	var r io.Reader
+	switch {
+	case arg == "-":
+		r = os.Stdin
+	case strings.HasPrefix(strings.TrimSpace(arg), "{"):
+		r = bytes.NewBufferString(arg)
+	default:
+		file, err := os.Open(arg)
+		if err != nil {
+			cli.DieWithError("failed to open PSTX file:", err)
+		}
+		defer file.Close()
+		r = file
+	}
+	pstx, err := types.DecodePartiallySignedTransaction(r)
+	if err != nil {
+		cli.DieWithError("invalid PSTX:", err)
+	}
+	return pstx
+}
+
+// signPSTX signs the given PSTX with all given signers,
+// returning the total amount of added signatures
+func signPSTX(pstx *types.PartiallySignedTransaction, signers []types.Signer) (uint64, error) {
+	var signatures uint64
+	for _, signer := range signers {
+		n, err := pstx.SignWith(signer)
+		if err != nil {
+			return 0, err
+		}
+		signatures += n
+	}
+	return signatures, nil
+}
+
+// offlineFulfillableContext returns the context used to check if fulfillments are fulfilled offline,
+// as the block height isn't known, time locks defined as a block height are considered unlocked
+func offlineFulfillableContext() rivinetypes.FulfillableContext {
+	return rivinetypes.FulfillableContext{
+		BlockHeight: rivinetypes.BlockHeight(math.MaxUint64),
+		BlockTime:   rivinetypes.CurrentTimestamp(),
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/threefoldfoundation/tfchain/pkg/signer"
+	"github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/pkg/cli"
+	"github.com/rivine/rivine/pkg/client"
+
+	"github.com/spf13/cobra"
+)
+
+func createSignerSubCmds(client *client.CommandLineClient) {
+	signerSubCmds := &signerSubCmds{cli: client}
+
+	// define commands
+	var (
+		rootCmd = &cobra.Command{
+			Use:   "signer",
+			Short: "Run the reference file-based external signer",
+			Long: `Run the reference file-based external signer,
+which signs using the keys of a keyfile, in a process separate from the one creating the signatures.
+
+Commands that sign transactions can use an external signer using the --signer flag.
+The signer can either run as a daemon, listening on a unix socket (--signer unix:<socket path>),
+or be executed for each request (--signer exec:tfchainc --signer-arg signer --signer-arg exec ...).
+`,
+			// Run field is not set, as the signer command itself is not a valid command.
+			// A subcommand must be provided.
+		}
+		serveCmd = &cobra.Command{
+			Use:   "serve <socket path>",
+			Short: "Serve sign requests received over a unix socket",
+			Run:   signerSubCmds.serve,
+		}
+		execCmd = &cobra.Command{
+			Use:   "exec",
+			Short: "Serve a single sign request received over the STDIN",
+			Run:   signerSubCmds.exec,
+		}
+	)
+
+	// add signer commands as a new root command
+	rootCmd.AddCommand(
+		serveCmd,
+		execCmd,
+	)
+	client.RootCmd.AddCommand(rootCmd)
+
+	// the signer never requires the daemon
+	rootCmd.PersistentPreRunE = func(*cobra.Command, []string) error { return nil }
+
+	// register flags
+	for _, cmd := range []*cobra.Command{serveCmd, execCmd} {
+		cmd.Flags().StringVar(
+			&signerSubCmds.keyFile, "keyfile", "",
+			"file containing the hex-encoded ed25519 secret keys to sign with, one per line (required)")
+	}
+	serveCmd.Flags().BoolVar(
+		&signerSubCmds.serveCfg.Confirm, "confirm", false,
+		"require each sign request to be confirmed on the STDIN, after reviewing its summary")
+}
+
+type signerSubCmds struct {
+	cli      *client.CommandLineClient
+	keyFile  string
+	serveCfg struct {
+		Confirm bool
+	}
+}
+
+func (signerSubCmds *signerSubCmds) serve(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <socket path>")
+	}
+	fileSigner := signerSubCmds.fileSigner()
+	stdin := bufio.NewReader(os.Stdin)
+	fileSigner.Review = func(request types.SignRequest) error {
+		fmt.Fprintf(os.Stderr, "sign request for %s:\n%s\n", request.PublicKey.String(), request.Summary)
+		if !signerSubCmds.serveCfg.Confirm {
+			return nil
+		}
+		fmt.Fprint(os.Stderr, "sign? [y/N]: ")
+		answer, err := stdin.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			return errors.New("declined by user")
+		}
+		return nil
+	}
+
+	listener, err := net.Listen("unix", args[0])
+	if err != nil {
+		cli.DieWithError("failed to listen on unix socket:", err)
+	}
+	server := signer.NewServer(listener, fileSigner)
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		server.Close()
+	}()
+	fmt.Fprintf(os.Stderr, "serving sign requests on %s\n", args[0])
+	err = server.Serve()
+	if err != nil {
+		cli.DieWithError("failed to serve sign requests:", err)
+	}
+}
+
+func (signerSubCmds *signerSubCmds) exec(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. No arguments can be given.")
+	}
+	err := signer.Serve(os.Stdin, os.Stdout, signerSubCmds.fileSigner())
+	if err != nil {
+		cli.DieWithError("failed to serve sign request:", err)
+	}
+}
+
+func (signerSubCmds *signerSubCmds) fileSigner() *signer.FileSigner {
+	if signerSubCmds.keyFile == "" {
+		cli.DieWithExitCode(cli.ExitCodeUsage, "a keyfile is required")
+	}
+	fileSigner, err := signer.NewFileSigner(signerSubCmds.keyFile)
+	if err != nil {
+		cli.DieWithError("failed to load keyfile:", err)
+	}
+	return fileSigner
+}
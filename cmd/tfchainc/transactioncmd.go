@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/threefoldfoundation/tfchain/pkg/api"
+	"github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/pkg/cli"
+	"github.com/rivine/rivine/pkg/client"
+	rivinetypes "github.com/rivine/rivine/types"
+
+	"github.com/spf13/cobra"
+)
+
+func createTransactionSubCmds(client *client.CommandLineClient) {
+	transactionSubCmds := &transactionSubCmds{cli: client}
+
+	// define commands
+	var (
+		rootCmd = &cobra.Command{
+			Use:   "transaction",
+			Short: "Work with raw transactions",
+			// Run field is not set, as the transaction command itself is not a valid command.
+			// A subcommand must be provided.
+		}
+		signCmd = &cobra.Command{
+			Use:   "sign <txnjson>",
+			Short: "Sign a raw transaction fully offline",
+			Long: `Sign a raw (regular, minter definition or coin creation) transaction fully offline,
+without requiring a daemon or an unlocked wallet.
+
+As the parent outputs cannot be looked up offline, the condition of the parent output
+of each coin input has to be given, in order, using the --parent flag.
+Block stake inputs require the same, using the --blockstakeparent flag,
+while minter definition and coin creation transactions require the (active) mint condition,
+using the --mintcondition flag. Conditions are given as an address or a JSON-encoded condition.
+
+The keys are read from a keyfile, containing one hex-encoded ed25519 secret key per line
+(empty lines and lines starting with '#' are ignored), or derived from a seed,
+given as a seed file (containing a mnemonic or hex-encoded seed) or as a mnemonic entered at the prompt.
+Alternatively the signing can be delegated to an external signer, using the --signer flag,
+such that the keys never have to be loaded into this process.
+
+The signed transaction is printed to the STDOUT.
+`,
+			Run: transactionSubCmds.sign,
+		}
+		inspectCmd = &cobra.Command{
+			Use:   "inspect <json|hex>",
+			Short: "Inspect a transaction prior to signing it",
+			Long: `Decode a (JSON or hex-encoded) transaction of any version, and print all its details,
+such as its outputs (with addresses and values), the total amount of minted coins and the fees,
+as well as which (mint condition) signers already signed it.
+
+When the daemon is reachable the transaction is validated against it,
+and anomalies are flagged, such as spent inputs, outputs to never-seen addresses
+or a mint fulfillment signed for a mint condition other than the active one.
+Offline, the mint condition to check the mint fulfillment against can be given using the --mintcondition flag.
+`,
+			Run: transactionSubCmds.inspect,
+		}
+		encodeCmd = &cobra.Command{
+			Use:   "encode <txnjson>",
+			Short: "Encode a JSON-encoded transaction as hex-encoded binary, fully offline",
+			Long: `Encode a JSON-encoded transaction of any version as a hex-encoded binary string, fully offline,
+printing the binary encoding to the STDOUT. Nil fulfillments have no binary encoding,
+such that only (partially) signed transactions can be encoded.
+`,
+			Run: transactionSubCmds.encode,
+		}
+		decodeCmd = &cobra.Command{
+			Use:   "decode <hex>",
+			Short: "Decode a hex-encoded binary transaction as JSON, fully offline",
+			Long: `Decode a hex-encoded binary transaction of any version, fully offline,
+printing the decoded transaction, its ID, its size and the signature hashes of its inputs
+(and mint fulfillment) as JSON to the STDOUT.
+`,
+			Run: transactionSubCmds.decode,
+		}
+	)
+
+	// add transaction commands as a new root command
+	rootCmd.AddCommand(
+		signCmd,
+		inspectCmd,
+		encodeCmd,
+		decodeCmd,
+	)
+	client.RootCmd.AddCommand(rootCmd)
+
+	// the sign, encode and decode commands never touch the network
+	offlineCmd(client, signCmd)
+	offlineCmd(client, encodeCmd)
+	offlineCmd(client, decodeCmd)
+
+	// register flags
+	signCmd.Flags().StringArrayVar(
+		&transactionSubCmds.signCfg.Parents, "parent", nil,
+		"the condition of the parent output of a coin input, given once per coin input, in order")
+	signCmd.Flags().StringArrayVar(
+		&transactionSubCmds.signCfg.BlockStakeParents, "blockstakeparent", nil,
+		"the condition of the parent output of a block stake input, given once per block stake input, in order")
+	signCmd.Flags().StringVar(
+		&transactionSubCmds.signCfg.MintCondition, "mintcondition", "",
+		"the active mint condition, required for minter definition and coin creation transactions")
+	transactionSubCmds.signCfg.Keys.registerFlags(signCmd.Flags())
+	inspectCmd.Flags().StringVar(
+		&transactionSubCmds.inspectCfg.MintCondition, "mintcondition", "",
+		"the mint condition to check the mint fulfillment against, by default the active mint condition of the daemon is used")
+	inspectCmd.Flags().IntVar(
+		&transactionSubCmds.inspectCfg.MaxOutputs, "max-outputs", 100,
+		"the amount of coin outputs above which the amount of coin outputs is flagged as unusual")
+	inspectCmd.Flags().Var(
+		cli.NewEncodingTypeFlag(cli.EncodingTypeHuman, &transactionSubCmds.inspectCfg.EncodingType, cli.EncodingTypeHuman|cli.EncodingTypeJSON), "encoding",
+		cli.EncodingTypeFlagDescription(cli.EncodingTypeHuman|cli.EncodingTypeJSON))
+}
+
+type transactionSubCmds struct {
+	cli     *client.CommandLineClient
+	signCfg struct {
+		Parents           []string
+		BlockStakeParents []string
+		MintCondition     string
+		Keys              offlineKeysCfg
+	}
+	inspectCfg struct {
+		MintCondition string
+		MaxOutputs    int
+		EncodingType  cli.EncodingType
+	}
+}
+
+func (transactionSubCmds *transactionSubCmds) sign(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <txnjson>")
+	}
+	var txn rivinetypes.Transaction
+	err := json.Unmarshal([]byte(args[0]), &txn)
+	if err != nil {
+		cmd.UsageFunc()(cmd)
+		cli.Die("failed to parse transaction:", err)
+	}
+
+	// wrap the transaction, together with the given metadata, in a PSTX
+	pstx := types.NewPartiallySignedTransaction(txn)
+	for idx, str := range transactionSubCmds.signCfg.Parents {
+		condition, err := parseConditionString(str)
+		if err != nil {
+			cli.DieWithExitCode(cli.ExitCodeUsage, fmt.Sprintf("invalid parent condition #%d: %v", idx, err))
+		}
+		pstx.CoinInputs = append(pstx.CoinInputs, types.PSTXParentOutput{Condition: condition})
+	}
+	for idx, str := range transactionSubCmds.signCfg.BlockStakeParents {
+		condition, err := parseConditionString(str)
+		if err != nil {
+			cli.DieWithExitCode(cli.ExitCodeUsage, fmt.Sprintf("invalid block stake parent condition #%d: %v", idx, err))
+		}
+		pstx.BlockStakeInputs = append(pstx.BlockStakeInputs, types.PSTXParentOutput{Condition: condition})
+	}
+	if str := transactionSubCmds.signCfg.MintCondition; str != "" {
+		condition, err := parseConditionString(str)
+		if err != nil {
+			cli.DieWithExitCode(cli.ExitCodeUsage, fmt.Sprintf("invalid mint condition: %v", err))
+		}
+		pstx.MintCondition = &condition
+	}
+	err = pstx.Validate()
+	if err != nil {
+		cli.DieWithExitCode(cli.ExitCodeUsage, "missing or invalid metadata:", err)
+	}
+
+	signers, err := transactionSubCmds.signCfg.Keys.signers()
+	if err != nil {
+		cli.DieWithError("failed to load keys:", err)
+	}
+	signatures, err := signPSTX(&pstx, signers)
+	if err != nil {
+		cli.DieWithError("failed to sign transaction:", err)
+	}
+	fulfillments, err := pstx.Fulfillments(offlineFulfillableContext())
+	if err != nil {
+		cli.DieWithError("failed to check fulfillments of transaction:", err)
+	}
+	var fulfilled int
+	for _, fulfillment := range fulfillments {
+		if fulfillment.Fulfilled {
+			fulfilled++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "added %d signature(s), %d out of %d fulfillment(s) fulfilled\n",
+		signatures, fulfilled, len(fulfillments))
+	json.NewEncoder(os.Stdout).Encode(pstx.Transaction)
+}
+
+func (transactionSubCmds *transactionSubCmds) inspect(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <json|hex>")
+	}
+	txn, err := decodeTransactionString(args[0])
+	if err != nil {
+		cmd.UsageFunc()(cmd)
+		cli.Die("failed to decode transaction:", err)
+	}
+	inspector := &transactionInspector{
+		cli:        transactionSubCmds.cli,
+		maxOutputs: transactionSubCmds.inspectCfg.MaxOutputs,
+	}
+	if str := transactionSubCmds.inspectCfg.MintCondition; str != "" {
+		condition, err := parseConditionString(str)
+		if err != nil {
+			cli.DieWithExitCode(cli.ExitCodeUsage, fmt.Sprintf("invalid mint condition: %v", err))
+		}
+		inspector.mintCondition = &condition
+	}
+	inspection, err := inspector.inspect(txn)
+	if err != nil {
+		cli.DieWithError("failed to inspect transaction:", err)
+	}
+
+	if transactionSubCmds.inspectCfg.EncodingType == cli.EncodingTypeJSON {
+		err = json.NewEncoder(os.Stdout).Encode(inspection)
+		if err != nil {
+			cli.DieWithError("failed to encode transaction inspection:", err)
+		}
+		return
+	}
+	inspection.printHuman(transactionSubCmds.cli.CreateCurrencyConvertor())
+}
+
+func (transactionSubCmds *transactionSubCmds) encode(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <txnjson>")
+	}
+	var txn rivinetypes.Transaction
+	err := json.Unmarshal([]byte(args[0]), &txn)
+	if err != nil {
+		cmd.UsageFunc()(cmd)
+		cli.Die("failed to parse transaction:", err)
+	}
+	result, err := api.EncodeTransactionHex(txn)
+	if err != nil {
+		cli.DieWithError("failed to encode transaction:", err)
+	}
+	fmt.Println(result.Hex)
+}
+
+func (transactionSubCmds *transactionSubCmds) decode(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <hex>")
+	}
+	result, err := api.DecodeTransactionHex(args[0])
+	if err != nil {
+		cmd.UsageFunc()(cmd)
+		cli.Die("failed to decode transaction:", err)
+	}
+	json.NewEncoder(os.Stdout).Encode(result)
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/pkg/client"
+	rivinetypes "github.com/rivine/rivine/types"
+)
+
+type (
+	// transactionInspection is the result of inspecting a transaction,
+	// containing everything a signer needs to know prior to signing it
+	transactionInspection struct {
+		// ID is only defined for transactions without nil fulfillments,
+		// as the ID of a transaction is only known once it is fully signed
+		ID          *rivinetypes.TransactionID     `json:"id,omitempty"`
+		Version     rivinetypes.TransactionVersion `json:"version"`
+		Kind        string                         `json:"kind"`
+		Nonce       *types.TransactionNonce        `json:"nonce,omitempty"`
+		Description string                         `json:"description,omitempty"`
+
+		CoinInputs        []inspectedInput  `json:"coininputs,omitempty"`
+		CoinOutputs       []inspectedOutput `json:"coinoutputs,omitempty"`
+		BlockStakeInputs  []inspectedInput  `json:"blockstakeinputs,omitempty"`
+		BlockStakeOutputs []inspectedOutput `json:"blockstakeoutputs,omitempty"`
+		// TotalMinted is only defined for coin creation transactions
+		TotalMinted *rivinetypes.Currency `json:"totalminted,omitempty"`
+		MinerFees   rivinetypes.Currency  `json:"minerfees"`
+
+		// MintCondition is the (active) mint condition the mint fulfillment is checked against,
+		// only defined for minter definition and coin creation transactions, if known
+		MintCondition *inspectedCondition `json:"mintcondition,omitempty"`
+		// MintFulfillment is only defined for minter definition and coin creation transactions
+		MintFulfillment *inspectedFulfillment `json:"mintfulfillment,omitempty"`
+		// NewMintCondition is only defined for minter definition transactions
+		NewMintCondition *inspectedCondition `json:"newmintcondition,omitempty"`
+
+		// Validated defines whether or not the transaction was validated against the daemon,
+		// ValidationError is only defined in case it was validated and found to be invalid
+		Validated       bool     `json:"validated"`
+		ValidationError string   `json:"validationerror,omitempty"`
+		Anomalies       []string `json:"anomalies"`
+	}
+
+	inspectedInput struct {
+		ParentID string `json:"parentid"`
+		// Parent is only defined if the parent output was found
+		Parent *inspectedOutput `json:"parent,omitempty"`
+		// Fulfillment is only defined if the parent output was found
+		Fulfillment *inspectedFulfillment `json:"fulfillment,omitempty"`
+	}
+
+	inspectedOutput struct {
+		Address  rivinetypes.UnlockHash `json:"address"`
+		Value    rivinetypes.Currency   `json:"value"`
+		LockTime uint64                 `json:"locktime,omitempty"`
+		// Known is only defined if it was checked against the explorer
+		Known *bool `json:"known,omitempty"`
+	}
+
+	inspectedCondition struct {
+		Condition         rivinetypes.UnlockConditionProxy `json:"condition"`
+		Address           rivinetypes.UnlockHash           `json:"address"`
+		Signers           []rivinetypes.UnlockHash         `json:"signers"`
+		MinimumSignatures uint64                           `json:"minimumsignatures"`
+		LockTime          uint64                           `json:"locktime,omitempty"`
+	}
+
+	inspectedFulfillment struct {
+		Signed []rivinetypes.UnlockHash `json:"signed"`
+		// Fulfilled and Error are only defined if the condition to fulfill is known
+		Fulfilled bool   `json:"fulfilled"`
+		Error     string `json:"error,omitempty"`
+	}
+)
+
+// decodeTransactionString decodes a transaction given as a JSON string,
+// or as a hex-encoded binary string
+func decodeTransactionString(str string) (rivinetypes.Transaction, error) {
+	var txn rivinetypes.Transaction
+	str = strings.TrimSpace(str)
+	if strings.HasPrefix(str, "{") {
+		err := json.Unmarshal([]byte(str), &txn)
+		if err != nil {
+			return rivinetypes.Transaction{}, fmt.Errorf("invalid JSON-encoded transaction: %v", err)
+		}
+		return txn, nil
+	}
+	b, err := hex.DecodeString(str)
+	if err != nil {
+		return rivinetypes.Transaction{}, errors.New("transaction is neither JSON-encoded nor hex-encoded")
+	}
+	err = encoding.Unmarshal(b, &txn)
+	if err != nil {
+		return rivinetypes.Transaction{}, fmt.Errorf("invalid hex-encoded transaction: %v", err)
+	}
+	return txn, nil
+}
+
+// transactionInspector inspects transactions,
+// optionally validating them against the daemon, if it is reachable
+type transactionInspector struct {
+	cli *client.CommandLineClient
+	// mintCondition is used for the mint fulfillment if defined,
+	// the active mint condition of the daemon is used otherwise
+	mintCondition *rivinetypes.UnlockConditionProxy
+	// maxOutputs defines the amount of coin outputs above which it is considered unusual
+	maxOutputs int
+}
+
+func (ti *transactionInspector) inspect(txn rivinetypes.Transaction) (*transactionInspection, error) {
+	inspection := &transactionInspection{
+		Version:   txn.Version,
+		Anomalies: []string{},
+	}
+	if !types.TransactionHasNilFulfillment(txn) {
+		id := txn.ID()
+		inspection.ID = &id
+	}
+	flag := func(format string, args ...interface{}) {
+		inspection.Anomalies = append(inspection.Anomalies, fmt.Sprintf(format, args...))
+	}
+
+	// check if the daemon is reachable, as all other validation is optional
+	var (
+		consensus   api.ConsensusGET
+		online      = ti.cli.GetAPI("/consensus", &consensus) == nil
+		fulfillCtx  = rivinetypes.FulfillContext{Transaction: txn}
+		fundCtx     rivinetypes.FundValidationContext
+		parentCoins = make(map[rivinetypes.CoinOutputID]rivinetypes.CoinOutput)
+		parentBS    = make(map[rivinetypes.BlockStakeOutputID]rivinetypes.BlockStakeOutput)
+	)
+	if online {
+		fulfillCtx.BlockHeight = consensus.Height
+		fulfillCtx.BlockTime = rivinetypes.CurrentTimestamp()
+		fundCtx = rivinetypes.FundValidationContext{BlockHeight: fulfillCtx.BlockHeight, BlockTime: fulfillCtx.BlockTime}
+	}
+
+	// decode the version-specific properties
+	var (
+		mintFulfillment    *rivinetypes.UnlockFulfillmentProxy
+		arbitraryData      = txn.ArbitraryData
+		coinOutputs        = txn.CoinOutputs
+		minerFees          = txn.MinerFees
+		newMintCondition   *rivinetypes.UnlockConditionProxy
+		requiresMintSigner bool
+	)
+	switch txn.Version {
+	case rivinetypes.TransactionVersionZero:
+		inspection.Kind = "legacy transaction"
+		flag("transaction uses the legacy transaction version %d", txn.Version)
+	case rivinetypes.TransactionVersionOne:
+		inspection.Kind = "regular transaction"
+	case types.TransactionVersionMinterDefinition:
+		inspection.Kind = "minter definition transaction"
+		mdtx, err := types.MinterDefinitionTransactionFromTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
+		inspection.Nonce = &mdtx.Nonce
+		mintFulfillment, arbitraryData, minerFees = &mdtx.MintFulfillment, mdtx.ArbitraryData, mdtx.MinerFees
+		newMintCondition = &mdtx.MintCondition
+		inspection.NewMintCondition = inspectCondition(mdtx.MintCondition)
+		requiresMintSigner = true
+	case types.TransactionVersionCoinCreation:
+		inspection.Kind = "coin creation transaction"
+		cctx, err := types.CoinCreationTransactionFromTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
+		inspection.Nonce = &cctx.Nonce
+		mintFulfillment, arbitraryData, minerFees, coinOutputs = &cctx.MintFulfillment, cctx.ArbitraryData, cctx.MinerFees, cctx.CoinOutputs
+		var total rivinetypes.Currency
+		for _, co := range cctx.CoinOutputs {
+			total = total.Add(co.Value)
+		}
+		inspection.TotalMinted = &total
+		requiresMintSigner = true
+		if len(cctx.CoinOutputs) == 0 {
+			flag("coin creation transaction creates no coin outputs")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported transaction version %d", txn.Version)
+	}
+	inspection.Description = string(arbitraryData)
+
+	// inputs
+	for idx, ci := range txn.CoinInputs {
+		input := inspectedInput{ParentID: ci.ParentID.String()}
+		if online {
+			var resp api.ConsensusGetUnspentCoinOutput
+			err := ti.cli.GetAPI("/consensus/unspent/coinoutputs/"+ci.ParentID.String(), &resp)
+			if err != nil {
+				flag("coin input #%d spends an unknown or already spent coin output", idx)
+			} else {
+				parentCoins[ci.ParentID] = resp.Output
+				input.Parent = inspectOutput(resp.Output.Value, resp.Output.Condition)
+				input.Fulfillment = inspectFulfillment(ci.Fulfillment, &resp.Output.Condition, uint64(idx), fulfillCtx)
+			}
+		}
+		inspection.CoinInputs = append(inspection.CoinInputs, input)
+	}
+	for idx, bsi := range txn.BlockStakeInputs {
+		input := inspectedInput{ParentID: bsi.ParentID.String()}
+		if online {
+			var resp api.ConsensusGetUnspentBlockstakeOutput
+			err := ti.cli.GetAPI("/consensus/unspent/blockstakeoutputs/"+bsi.ParentID.String(), &resp)
+			if err != nil {
+				flag("block stake input #%d spends an unknown or already spent block stake output", idx)
+			} else {
+				parentBS[bsi.ParentID] = resp.Output
+				input.Parent = inspectOutput(resp.Output.Value, resp.Output.Condition)
+				input.Fulfillment = inspectFulfillment(bsi.Fulfillment, &resp.Output.Condition, uint64(idx), fulfillCtx)
+			}
+		}
+		inspection.BlockStakeInputs = append(inspection.BlockStakeInputs, input)
+	}
+
+	// outputs
+	seen := make(map[rivinetypes.UnlockHash]int)
+	for idx, co := range coinOutputs {
+		output := inspectOutput(co.Value, co.Condition)
+		if co.Value.IsZero() {
+			flag("coin output #%d has no value", idx)
+		}
+		if other, ok := seen[output.Address]; ok {
+			flag("coin outputs #%d and #%d are sent to the same address %s", other, idx, output.Address.String())
+		} else {
+			seen[output.Address] = idx
+		}
+		if online {
+			output.Known = ti.knownAddress(output.Address)
+			if output.Known != nil && !*output.Known {
+				flag("coin output #%d is sent to never-seen address %s", idx, output.Address.String())
+			}
+		}
+		inspection.CoinOutputs = append(inspection.CoinOutputs, *output)
+	}
+	if ti.maxOutputs > 0 && len(coinOutputs) > ti.maxOutputs {
+		flag("unusual amount of coin outputs: %d (more than %d)", len(coinOutputs), ti.maxOutputs)
+	}
+	for _, bso := range txn.BlockStakeOutputs {
+		inspection.BlockStakeOutputs = append(inspection.BlockStakeOutputs, *inspectOutput(bso.Value, bso.Condition))
+	}
+
+	// fees
+	for _, fee := range minerFees {
+		inspection.MinerFees = inspection.MinerFees.Add(fee)
+	}
+	if minFee := ti.cli.Config.MinimumTransactionFee; inspection.MinerFees.Cmp(minFee) < 0 {
+		flag("total miner fee is lower than the minimum miner fee of %s",
+			ti.cli.CreateCurrencyConvertor().ToCoinStringWithUnit(minFee))
+	} else if inspection.MinerFees.Cmp(minFee.Mul64(100)) > 0 {
+		flag("total miner fee is more than 100 times the minimum miner fee")
+	}
+
+	// mint fulfillment
+	if requiresMintSigner {
+		mintCondition := ti.mintCondition
+		if online {
+			active, err := (&cliMintConditionGetter{client: ti.cli}).GetActiveMintCondition()
+			if err != nil {
+				// not fatal, the mint fulfillment is inspected against the given mint condition, if any
+				flag("the active mint condition could not be fetched from the daemon: %v", err)
+			} else {
+				if mintCondition != nil && !mintCondition.Equal(active) {
+					flag("the given mint condition differs from the active mint condition")
+				}
+				if mintCondition == nil {
+					mintCondition = &active
+				}
+				if newMintCondition != nil && newMintCondition.Equal(active) {
+					flag("the new mint condition equals the active mint condition")
+				}
+			}
+		}
+		inspection.MintFulfillment = inspectFulfillment(*mintFulfillment, mintCondition, 0, fulfillCtx)
+		if mintCondition != nil {
+			inspection.MintCondition = inspectCondition(*mintCondition)
+			for _, signed := range inspection.MintFulfillment.Signed {
+				if !containsUnlockHash(inspection.MintCondition.Signers, signed) {
+					flag("mint fulfillment is signed by %s, which is not a signer of the mint condition, "+
+						"the transaction was most likely signed for a different mint condition", signed.String())
+				}
+			}
+		}
+	}
+
+	// validate the transaction against the daemon
+	if online {
+		inspection.Validated = true
+		err := ti.validate(txn, parentCoins, parentBS, fundCtx)
+		if err != nil {
+			inspection.ValidationError = err.Error()
+		}
+	}
+	return inspection, nil
+}
+
+// validate the transaction the same way the consensus does,
+// using the parent outputs and mint condition as found by the daemon
+func (ti *transactionInspector) validate(txn rivinetypes.Transaction,
+	parentCoins map[rivinetypes.CoinOutputID]rivinetypes.CoinOutput,
+	parentBS map[rivinetypes.BlockStakeOutputID]rivinetypes.BlockStakeOutput,
+	ctx rivinetypes.FundValidationContext) error {
+	chainConstants, err := networkChainConstants(ti.cli.Config.NetworkName)
+	if err != nil {
+		return err
+	}
+	var daemonConstants modules.DaemonConstants
+	if ti.cli.GetAPI("/daemon/constants", &daemonConstants) == nil {
+		chainConstants.BlockSizeLimit = daemonConstants.BlockSizeLimit
+		chainConstants.MinimumTransactionFee = daemonConstants.MinimumTransactionFee
+	}
+	err = txn.ValidateTransaction(rivinetypes.ValidationContext{
+		BlockHeight: ctx.BlockHeight,
+		BlockTime:   ctx.BlockTime,
+	}, rivinetypes.TransactionValidationConstants{
+		BlockSizeLimit:         chainConstants.BlockSizeLimit,
+		ArbitraryDataSizeLimit: chainConstants.ArbitraryDataSizeLimit,
+		MinimumMinerFee:        chainConstants.MinimumTransactionFee,
+	})
+	if err != nil {
+		return err
+	}
+	if len(parentCoins) != len(txn.CoinInputs) || len(parentBS) != len(txn.BlockStakeInputs) {
+		return errors.New("not all parent outputs could be found")
+	}
+	err = txn.ValidateCoinOutputs(ctx, parentCoins)
+	if err != nil {
+		return err
+	}
+	return txn.ValidateBlockStakeOutputs(ctx, parentBS)
+}
+
+// knownAddress checks if the explorer knows the given address,
+// nil is returned in case it could not be checked
+func (ti *transactionInspector) knownAddress(uh rivinetypes.UnlockHash) *bool {
+	var resp api.ExplorerHashGET
+	err := ti.cli.GetAPI("/explorer/hashes/"+uh.String(), &resp)
+	known := err == nil
+	if err != nil {
+		// the explorer returns a bad request for unknown hashes,
+		// any other error means the address could not be checked (e.g. no explorer)
+		if httpErr, ok := err.(*api.HTTPError); !ok || httpErr.HTTPStatusCode() != http.StatusBadRequest {
+			return nil
+		}
+	}
+	return &known
+}
+
+func inspectOutput(value rivinetypes.Currency, condition rivinetypes.UnlockConditionProxy) *inspectedOutput {
+	output := &inspectedOutput{
+		Address: condition.UnlockHash(),
+		Value:   value,
+	}
+	if tlc, ok := condition.Condition.(*rivinetypes.TimeLockCondition); ok {
+		output.LockTime = tlc.LockTime
+	}
+	return output
+}
+
+func inspectCondition(condition rivinetypes.UnlockConditionProxy) *inspectedCondition {
+	inspected := &inspectedCondition{
+		Condition:         condition,
+		Address:           condition.UnlockHash(),
+		Signers:           types.ConditionUnlockHashes(condition),
+		MinimumSignatures: 1,
+	}
+	inner := condition.Condition
+	if tlc, ok := inner.(*rivinetypes.TimeLockCondition); ok {
+		inspected.LockTime = tlc.LockTime
+		inner = tlc.Condition
+	}
+	if msc, ok := inner.(*rivinetypes.MultiSignatureCondition); ok {
+		inspected.MinimumSignatures = msc.MinimumSignatureCount
+	}
+	return inspected
+}
+
+func inspectFulfillment(fulfillment rivinetypes.UnlockFulfillmentProxy, condition *rivinetypes.UnlockConditionProxy, idx uint64, ctx rivinetypes.FulfillContext) *inspectedFulfillment {
+	inspected := &inspectedFulfillment{Signed: []rivinetypes.UnlockHash{}}
+	for _, pk := range types.FulfillmentPublicKeys(fulfillment) {
+		inspected.Signed = append(inspected.Signed, rivinetypes.NewPubKeyUnlockHash(pk))
+	}
+	if condition == nil {
+		return inspected
+	}
+	ctx.InputIndex = idx
+	if ctx.BlockHeight == 0 {
+		// used offline, consider time locks as unlocked
+		offlineCtx := offlineFulfillableContext()
+		ctx.BlockHeight, ctx.BlockTime = offlineCtx.BlockHeight, offlineCtx.BlockTime
+	}
+	err := condition.Fulfill(fulfillment, ctx)
+	inspected.Fulfilled = err == nil
+	if err != nil {
+		inspected.Error = err.Error()
+	}
+	return inspected
+}
+
+func containsUnlockHash(uhs []rivinetypes.UnlockHash, uh rivinetypes.UnlockHash) bool {
+	for _, other := range uhs {
+		if other.Cmp(uh) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// printHuman prints the inspection in a human-readable format
+func (inspection *transactionInspection) printHuman(cc client.CurrencyConvertor) {
+	fmt.Printf("%s (version %d)\n", strings.Title(inspection.Kind), inspection.Version)
+	if inspection.ID != nil {
+		fmt.Printf("  ID:          %s\n", inspection.ID.String())
+	} else {
+		fmt.Println("  ID:          unknown until fully signed")
+	}
+	if inspection.Nonce != nil {
+		fmt.Printf("  Nonce:       %s\n", hex.EncodeToString(inspection.Nonce[:]))
+	}
+	if inspection.Description != "" {
+		fmt.Printf("  Description: %s\n", inspection.Description)
+	}
+
+	if len(inspection.CoinInputs) > 0 {
+		fmt.Printf("\nCoin inputs (%d):\n", len(inspection.CoinInputs))
+		printInspectedInputs(inspection.CoinInputs, cc.ToCoinStringWithUnit)
+	}
+	if len(inspection.CoinOutputs) > 0 {
+		fmt.Printf("\nCoin outputs (%d):\n", len(inspection.CoinOutputs))
+		printInspectedOutputs(inspection.CoinOutputs, cc.ToCoinStringWithUnit)
+	}
+	if len(inspection.BlockStakeInputs) > 0 {
+		fmt.Printf("\nBlock stake inputs (%d):\n", len(inspection.BlockStakeInputs))
+		printInspectedInputs(inspection.BlockStakeInputs, blockStakeString)
+	}
+	if len(inspection.BlockStakeOutputs) > 0 {
+		fmt.Printf("\nBlock stake outputs (%d):\n", len(inspection.BlockStakeOutputs))
+		printInspectedOutputs(inspection.BlockStakeOutputs, blockStakeString)
+	}
+	fmt.Println()
+	if inspection.TotalMinted != nil {
+		fmt.Printf("Total minted: %s\n", cc.ToCoinStringWithUnit(*inspection.TotalMinted))
+	}
+	fmt.Printf("Miner fees:   %s\n", cc.ToCoinStringWithUnit(inspection.MinerFees))
+
+	if inspection.NewMintCondition != nil {
+		fmt.Println("\nNew mint condition:")
+		printInspectedCondition(inspection.NewMintCondition, nil)
+	}
+	if inspection.MintFulfillment != nil {
+		fmt.Println()
+		if inspection.MintCondition == nil {
+			fmt.Println("Mint fulfillment (mint condition unknown):")
+			for _, signed := range inspection.MintFulfillment.Signed {
+				fmt.Printf("  [x] %s\n", signed.String())
+			}
+		} else {
+			fmt.Printf("Mint fulfillment (%d out of minimum %d signature(s), fulfilled: %v):\n",
+				len(inspection.MintFulfillment.Signed), inspection.MintCondition.MinimumSignatures,
+				inspection.MintFulfillment.Fulfilled)
+			printInspectedCondition(inspection.MintCondition, inspection.MintFulfillment.Signed)
+		}
+	}
+
+	fmt.Println()
+	switch {
+	case !inspection.Validated:
+		fmt.Println("Validation:   skipped, daemon not reachable")
+	case inspection.ValidationError != "":
+		fmt.Printf("Validation:   invalid: %s\n", inspection.ValidationError)
+	default:
+		fmt.Println("Validation:   valid")
+	}
+	if len(inspection.Anomalies) > 0 {
+		fmt.Printf("\nAnomalies (%d):\n", len(inspection.Anomalies))
+		for _, anomaly := range inspection.Anomalies {
+			fmt.Printf("  - %s\n", anomaly)
+		}
+	}
+}
+
+func printInspectedInputs(inputs []inspectedInput, valueString func(rivinetypes.Currency) string) {
+	for idx, input := range inputs {
+		if input.Parent == nil {
+			fmt.Printf("  #%d %s (parent output unknown)\n", idx, input.ParentID)
+			continue
+		}
+		fmt.Printf("  #%d %s: %s from %s%s (signed by %d, fulfilled: %v)\n", idx, input.ParentID,
+			valueString(input.Parent.Value), input.Parent.Address.String(), lockTimeString(input.Parent.LockTime),
+			len(input.Fulfillment.Signed), input.Fulfillment.Fulfilled)
+	}
+}
+
+func printInspectedOutputs(outputs []inspectedOutput, valueString func(rivinetypes.Currency) string) {
+	for idx, output := range outputs {
+		var known string
+		if output.Known != nil && !*output.Known {
+			known = " (never seen)"
+		}
+		fmt.Printf("  #%d %s to %s%s%s\n", idx, valueString(output.Value),
+			output.Address.String(), lockTimeString(output.LockTime), known)
+	}
+}
+
+func printInspectedCondition(condition *inspectedCondition, signed []rivinetypes.UnlockHash) {
+	fmt.Printf("  Address: %s%s\n", condition.Address.String(), lockTimeString(condition.LockTime))
+	fmt.Printf("  Minimum signatures: %d out of %d\n", condition.MinimumSignatures, len(condition.Signers))
+	for _, signer := range condition.Signers {
+		if signed == nil {
+			fmt.Printf("  - %s\n", signer.String())
+			continue
+		}
+		mark := " "
+		if containsUnlockHash(signed, signer) {
+			mark = "x"
+		}
+		fmt.Printf("  [%s] %s\n", mark, signer.String())
+	}
+}
+
+func lockTimeString(lockTime uint64) string {
+	if lockTime == 0 {
+		return ""
+	}
+	return " (" + lockTimeDescription(lockTime) + ")"
+}
+
+// lockTimeDescription describes a (non-zero) lock time, as a block height or timestamp
+func lockTimeDescription(lockTime uint64) string {
+	if lockTime < rivinetypes.LockTimeMinTimestampValue {
+		return fmt.Sprintf("locked until height %d", lockTime)
+	}
+	return fmt.Sprintf("locked until %s", time.Unix(int64(lockTime), 0).UTC().Format(time.RFC822))
+}
+
+func blockStakeString(c rivinetypes.Currency) string {
+	return c.String() + " BS"
+}
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
+	"github.com/threefoldfoundation/tfchain/pkg/api"
+	"github.com/threefoldfoundation/tfchain/pkg/modules/payouts"
 	"github.com/threefoldfoundation/tfchain/pkg/types"
 
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
 	"github.com/rivine/rivine/pkg/cli"
 	"github.com/rivine/rivine/pkg/client"
 	rivinetypes "github.com/rivine/rivine/types"
@@ -31,6 +40,24 @@ The returned (raw) MinterDefinitionTransaction still has to be signed, prior to
 	`,
 			Run: walletSubCmds.createMinterDefinitionTxCmd,
 		}
+		createMinterSetTxCmd = &cobra.Command{
+			Use:   "mintersettransaction <address> [<address>...]",
+			Short: "Create a new minter definition transaction for a set of minters",
+			Long: `Create a new minter definition transaction, defining the given addresses as the new set of minters.
+The mint condition is built from the given addresses, the minimum amount of signatures (--signatures)
+and an optional lock time (--locktime), such that it doesn't have to be written by hand.
+
+A single address with a single signature results in a single signature condition,
+while multiple addresses result in a multisig condition, the order of the addresses is irrelevant.
+The lock time is interpreted as a block height if less than 500000000, and as a unix epoch timestamp otherwise.
+
+The mint condition is validated locally, and the changes compared to the active mint condition,
+as known by the explorer, are printed to the STDERR.
+
+The returned (raw) MinterDefinitionTransaction still has to be signed, prior to sending.
+	`,
+			Run: walletSubCmds.createMinterSetTxCmd,
+		}
 		createCoinCreationTxCmd = &cobra.Command{
 			Use:   "coincreationtransaction <dest>|<rawCondition> <amount> [<dest>|<rawCondition> <amount>]...",
 			Short: "Create a new coin creation transaction",
@@ -44,33 +71,173 @@ Decimals are possible and have to be defined using the decimal point.
 The Minimum Miner Fee will be added on top of the total given amount automatically.
 
 The returned (raw) CoinCreationTransaction still has to be signed, prior to sending.
+
+Instead of giving the outputs as arguments, they can be read from a CSV file, using the --from-csv flag,
+with one row per output, consisting of an address, an amount and an optional memo
+(which is only used for your own bookkeeping and isn't stored in the transaction).
+As a single transaction can only be so big, the outputs are split over as many transactions as required,
+each one printed on its own line, and each with a description suffixed with its batch number.
+A reconciliation summary is printed to the STDERR.
 	`,
 			Run: walletSubCmds.createCoinCreationTxCmd,
 		}
+		sendMultiSigCmd = &cobra.Command{
+			Use:   "multisig <address> <dest>|<rawCondition> <amount> [<dest>|<rawCondition> <amount>]...",
+			Short: "Send coins from a co-owned multisig wallet",
+			Long: `Send coins from a multisig wallet co-owned by this wallet,
+to one or multiple addresses.
+The inputs are selected from the spendable outputs of the multisig wallet,
+and the change is sent back to that same multisig wallet.
+
+Amounts have to be given expressed in the OneCoin unit, and without the unit of currency.
+Decimals are possible and have to be defined using the decimal point.
+
+The Minimum Miner Fee will be added on top of the total given amount automatically.
+
+The transaction is signed only with the keys of this wallet, and is not sent.
+The returned (partially signed) transaction has to be signed by the co-signers,
+using 'wallet sign', as long as the minimum amount of signatures isn't reached,
+after which it can be sent using 'wallet send transaction'.
+	`,
+			Run: walletSubCmds.sendMultiSigCmd,
+		}
+		sendBatchCmd = &cobra.Command{
+			Use:   "batch <file>",
+			Short: "Send coins to many addresses, as a batch of payouts",
+			Long: `Send coins from this wallet to many addresses, as a batch of payouts,
+read from a CSV or JSON file (or - for STDIN).
+
+A CSV file has one address,amount[,memo] row per payout,
+while a JSON file contains an array of {"address", "amount", "memo"} objects.
+Amounts have to be given expressed in the OneCoin unit, and without the unit of currency.
+Memos are only used for your own bookkeeping and aren't stored in the transactions.
+
+The payouts are split over as many transactions as required for them to fit in a block,
+using as few inputs as possible. The planned transactions are shown first,
+and are only signed and sent once confirmed (or when --yes is given).
+
+Each batch is recorded by the daemon using its batch ID, which is derived from the payouts
+and description if not given explicitly. A batch that partially failed can be resumed
+by sending it again using the same ID (e.g. by running the same command again),
+in which case payouts that are already paid, or pending, are never paid again.
+	`,
+			Run: walletSubCmds.sendBatchCmd,
+		}
+		exportCmd = &cobra.Command{
+			Use:   "export",
+			Short: "Export the history of all coin movements of the wallet",
+			Long: `Export the confirmed history of all coin movements of the wallet, as CSV or JSON,
+listing for each movement its timestamp, block height, transaction ID and version,
+its type (transfer, mint, blockreward, fee or atomicswap), its counterparties,
+the (net) amount of coins received and sent (fees included), the fee paid and the running balance.
+
+All amounts are expressed in the OneCoin unit.
+`,
+			Run: walletSubCmds.exportCmd,
+		}
+		listMultiSigTxnsCmd = &cobra.Command{
+			Use:   "multisigtransactions <address>",
+			Short: "List the transactions of a co-owned multisig wallet",
+			Long: `List all confirmed and unconfirmed transactions related to a multisig wallet
+co-owned by this wallet, providing the net flow of coins of the multisig wallet for each transaction.
+`,
+			Run: walletSubCmds.listMultiSigTxnsCmd,
+		}
 	)
 
 	// add commands as wallet sub commands
 	cli.WalletCmd.RootCmdCreate.AddCommand(
 		createMinterDefinitionTxCmd,
+		createMinterSetTxCmd,
 		createCoinCreationTxCmd,
 	)
+	cli.WalletCmd.RootCmdSend.AddCommand(
+		sendMultiSigCmd,
+		sendBatchCmd,
+	)
+	cli.WalletCmd.RootCmdList.AddCommand(
+		listMultiSigTxnsCmd,
+	)
+	cli.WalletCmd.AddCommand(
+		exportCmd,
+	)
 
 	// register flags
 	createMinterDefinitionTxCmd.Flags().StringVar(
 		&walletSubCmds.minterDefinitionTxCfg.Description, "description", "",
 		"optionally add a description to describe the reasons of transfer of minting power, added as arbitrary data")
+	createMinterSetTxCmd.Flags().Uint64Var(
+		&walletSubCmds.minterSetTxCfg.Signatures, "signatures", 0,
+		"the minimum amount of signatures required to mint and to redefine the minters (required)")
+	createMinterSetTxCmd.Flags().Uint64Var(
+		&walletSubCmds.minterSetTxCfg.LockTime, "locktime", 0,
+		"optionally lock the mint condition until the given block height or unix epoch timestamp")
+	createMinterSetTxCmd.Flags().StringVar(
+		&walletSubCmds.minterSetTxCfg.Description, "description", "",
+		"optionally add a description to describe the reasons of transfer of minting power, added as arbitrary data")
 	createCoinCreationTxCmd.Flags().StringVar(
 		&walletSubCmds.coinCreationTxCfg.Description, "description", "",
 		"optionally add a description to describe the origins of the coin creation, added as arbitrary data")
+	createCoinCreationTxCmd.Flags().StringVar(
+		&walletSubCmds.coinCreationTxCfg.CSVFile, "from-csv", "",
+		"create the outputs from a CSV file (or - for STDIN), with one address,amount[,memo] row per output")
+	sendMultiSigCmd.Flags().StringVar(
+		&walletSubCmds.sendMultiSigCfg.Description, "description", "",
+		"optionally add a description to the transaction, added as arbitrary data")
+	registerFeeFlags(createMinterDefinitionTxCmd, &walletSubCmds.minterDefinitionTxCfg.Fee)
+	registerFeeFlags(createMinterSetTxCmd, &walletSubCmds.minterSetTxCfg.Fee)
+	registerFeeFlags(createCoinCreationTxCmd, &walletSubCmds.coinCreationTxCfg.Fee)
+	registerFeeFlags(sendMultiSigCmd, &walletSubCmds.sendMultiSigCfg.Fee)
+	sendBatchCmd.Flags().StringVar(
+		&walletSubCmds.sendBatchCfg.ID, "id", "",
+		"optionally define the batch ID, derived from the payouts and description if not given")
+	sendBatchCmd.Flags().StringVar(
+		&walletSubCmds.sendBatchCfg.Description, "description", "",
+		"optionally add a description to all transactions of the batch, added as arbitrary data")
+	sendBatchCmd.Flags().StringVar(
+		&walletSubCmds.sendBatchCfg.Format, "format", "",
+		"format of the payout file (csv or json), derived from the file extension if not given")
+	sendBatchCmd.Flags().BoolVar(
+		&walletSubCmds.sendBatchCfg.DryRun, "dry-run", false,
+		"only show the planned transactions, without signing or sending anything")
+	sendBatchCmd.Flags().BoolVar(
+		&walletSubCmds.sendBatchCfg.Yes, "yes", false,
+		"sign and send the planned transactions without asking for confirmation")
+	exportCmd.Flags().StringVar(
+		&walletSubCmds.exportCfg.Format, "format", "csv",
+		"format of the export (csv or json)")
 }
 
 type walletSubCmds struct {
 	cli                   *client.CommandLineClient
 	minterDefinitionTxCfg struct {
 		Description string
+		Fee         feeCfg
+	}
+	minterSetTxCfg struct {
+		Signatures  uint64
+		LockTime    uint64
+		Description string
+		Fee         feeCfg
 	}
 	coinCreationTxCfg struct {
 		Description string
+		CSVFile     string
+		Fee         feeCfg
+	}
+	sendMultiSigCfg struct {
+		Description string
+		Fee         feeCfg
+	}
+	sendBatchCfg struct {
+		ID          string
+		Description string
+		Format      string
+		DryRun      bool
+		Yes         bool
+	}
+	exportCfg struct {
+		Format string
 	}
 }
 
@@ -80,10 +247,9 @@ func (walletSubCmds *walletSubCmds) createMinterDefinitionTxCmd(cmd *cobra.Comma
 		cli.Die("Invalid amount of arguments. One argume has to be given: <dest>|<rawCondition>")
 	}
 
-	// create a minter definition tx with a random nonce and the minimum required miner fee
+	// create a minter definition tx with a random nonce
 	tx := types.MinterDefinitionTransaction{
-		Nonce:     types.RandomTransactionNonce(),
-		MinerFees: []rivinetypes.Currency{walletSubCmds.cli.Config.MinimumTransactionFee},
+		Nonce: types.RandomTransactionNonce(),
 	}
 
 	// parse the given mint condition
@@ -99,14 +265,130 @@ func (walletSubCmds *walletSubCmds) createMinterDefinitionTxCmd(cmd *cobra.Comma
 		tx.ArbitraryData = make([]byte, n)
 		copy(tx.ArbitraryData[:], walletSubCmds.minterDefinitionTxCfg.Description[:])
 	}
+	tx.MinerFees = []rivinetypes.Currency{walletSubCmds.mintMinerFee(walletSubCmds.minterDefinitionTxCfg.Fee, tx.Transaction())}
 
 	// encode the transaction as a JSON-encoded string and print it to the STDOUT
 	json.NewEncoder(os.Stdout).Encode(tx.Transaction())
 }
 
+func (walletSubCmds *walletSubCmds) createMinterSetTxCmd(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. At least one address has to be given: <address> [<address>...]")
+	}
+
+	// parse the addresses, sorted such that the order in which they are given doesn't matter
+	addresses := make(rivinetypes.UnlockHashSlice, 0, len(args))
+	for _, arg := range args {
+		var uh rivinetypes.UnlockHash
+		err := uh.LoadString(arg)
+		if err != nil {
+			cmd.UsageFunc()(cmd)
+			cli.Die(fmt.Sprintf("invalid address %q: %v", arg, err))
+		}
+		if uh.Type != rivinetypes.UnlockTypePubKey {
+			cli.DieWithExitCode(cli.ExitCodeUsage, fmt.Sprintf(
+				"address %s cannot be a minter: only addresses of a single public key can sign", arg))
+		}
+		if containsUnlockHash(addresses, uh) {
+			cli.DieWithExitCode(cli.ExitCodeUsage, fmt.Sprintf("address %s is given more than once", arg))
+		}
+		addresses = append(addresses, uh)
+	}
+	sort.Sort(addresses)
+
+	// build the mint condition
+	signatures := walletSubCmds.minterSetTxCfg.Signatures
+	if signatures == 0 || signatures > uint64(len(addresses)) {
+		cli.DieWithExitCode(cli.ExitCodeUsage, fmt.Sprintf(
+			"the amount of required signatures (--signatures) has to be at least 1 and at most %d", len(addresses)))
+	}
+	var condition rivinetypes.MarshalableUnlockCondition
+	if len(addresses) == 1 {
+		condition = rivinetypes.NewUnlockHashCondition(addresses[0])
+	} else {
+		condition = rivinetypes.NewMultiSignatureCondition(addresses, signatures)
+	}
+	if lockTime := walletSubCmds.minterSetTxCfg.LockTime; lockTime != 0 {
+		condition = rivinetypes.NewTimeLockCondition(lockTime, condition)
+	}
+	mintCondition := rivinetypes.NewCondition(condition)
+	err := types.ValidateMintCondition(mintCondition)
+	if err != nil {
+		cli.DieWithError("invalid mint condition:", err)
+	}
+
+	// compare the mint condition with the active mint condition
+	var result api.TransactionDBGetMintCondition
+	err = walletSubCmds.cli.GetAPI("/explorer/mintcondition", &result)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to get the active mint condition from the explorer, cannot compare:", err)
+	} else {
+		if result.MintCondition.Equal(mintCondition) {
+			cli.Die("the new mint condition equals the active mint condition")
+		}
+		printMintConditionDiff(result.MintCondition, mintCondition)
+	}
+
+	// create a minter definition tx with a random nonce
+	tx := types.MinterDefinitionTransaction{
+		Nonce:         types.RandomTransactionNonce(),
+		MintCondition: mintCondition,
+	}
+	if n := len(walletSubCmds.minterSetTxCfg.Description); n > 0 {
+		tx.ArbitraryData = make([]byte, n)
+		copy(tx.ArbitraryData[:], walletSubCmds.minterSetTxCfg.Description[:])
+	}
+	tx.MinerFees = []rivinetypes.Currency{walletSubCmds.mintMinerFee(walletSubCmds.minterSetTxCfg.Fee, tx.Transaction())}
+	json.NewEncoder(os.Stdout).Encode(tx.Transaction())
+}
+
+// printMintConditionDiff prints the changes of the new mint condition,
+// compared to the active mint condition, to the STDERR
+func printMintConditionDiff(active, proposed rivinetypes.UnlockConditionProxy) {
+	activeCondition, newCondition := inspectCondition(active), inspectCondition(proposed)
+	fmt.Fprintln(os.Stderr, "Changes compared to the active mint condition:")
+	for _, uh := range activeCondition.Signers {
+		if containsUnlockHash(newCondition.Signers, uh) {
+			fmt.Fprintf(os.Stderr, "    %s\n", uh.String())
+		} else {
+			fmt.Fprintf(os.Stderr, "  - %s (removed)\n", uh.String())
+		}
+	}
+	for _, uh := range newCondition.Signers {
+		if !containsUnlockHash(activeCondition.Signers, uh) {
+			fmt.Fprintf(os.Stderr, "  + %s (added)\n", uh.String())
+		}
+	}
+	fmt.Fprintf(os.Stderr, "  minimum signatures: %d out of %d -> %d out of %d\n",
+		activeCondition.MinimumSignatures, len(activeCondition.Signers),
+		newCondition.MinimumSignatures, len(newCondition.Signers))
+	if activeCondition.LockTime != newCondition.LockTime {
+		fmt.Fprintf(os.Stderr, "  lock time: %s -> %s\n",
+			lockTimeDiffString(activeCondition.LockTime), lockTimeDiffString(newCondition.LockTime))
+	}
+	fmt.Fprintf(os.Stderr, "  address: %s -> %s\n", activeCondition.Address.String(), newCondition.Address.String())
+}
+
+func lockTimeDiffString(lockTime uint64) string {
+	if lockTime == 0 {
+		return "none"
+	}
+	return lockTimeDescription(lockTime)
+}
+
 func (walletSubCmds *walletSubCmds) createCoinCreationTxCmd(cmd *cobra.Command, args []string) {
 	currencyConvertor := walletSubCmds.cli.CreateCurrencyConvertor()
 
+	if path := walletSubCmds.coinCreationTxCfg.CSVFile; path != "" {
+		if len(args) != 0 {
+			cmd.UsageFunc()(cmd)
+			cli.Die("Invalid arguments. No outputs can be given as arguments when using --from-csv.")
+		}
+		walletSubCmds.createCoinCreationTxsFromCSV(path)
+		return
+	}
+
 	// Check that the remaining args are condition + value pairs
 	if len(args)%2 != 0 {
 		cmd.UsageFunc()
@@ -121,8 +403,7 @@ func (walletSubCmds *walletSubCmds) createCoinCreationTxCmd(cmd *cobra.Command,
 	}
 
 	tx := types.CoinCreationTransaction{
-		Nonce:     types.RandomTransactionNonce(),
-		MinerFees: []rivinetypes.Currency{walletSubCmds.cli.Config.MinimumTransactionFee},
+		Nonce: types.RandomTransactionNonce(),
 	}
 	if n := len(walletSubCmds.coinCreationTxCfg.Description); n > 0 {
 		tx.ArbitraryData = make([]byte, n)
@@ -134,9 +415,374 @@ func (walletSubCmds *walletSubCmds) createCoinCreationTxCmd(cmd *cobra.Command,
 			Condition: pair.Condition,
 		})
 	}
+	tx.MinerFees = []rivinetypes.Currency{walletSubCmds.mintMinerFee(walletSubCmds.coinCreationTxCfg.Fee, tx.Transaction())}
 	json.NewEncoder(os.Stdout).Encode(tx.Transaction())
 }
 
+// createCoinCreationTxsFromCSV creates as many coin creation transactions as required
+// to pay out all rows of the given CSV file, such that each transaction, once signed,
+// still fits in a block and is accepted by the transaction pool.
+// The transactions are printed to the STDOUT, one per line,
+// while the reconciliation summary is printed to the STDERR.
+func (walletSubCmds *walletSubCmds) createCoinCreationTxsFromCSV(path string) {
+	currencyConvertor := walletSubCmds.cli.CreateCurrencyConvertor()
+	rows, err := readPayoutCSV(path, currencyConvertor.ParseCoinString)
+	if err != nil {
+		cli.DieWithError("invalid CSV file:", err)
+	}
+
+	// the size of a transaction is limited by both the block size and the transaction pool
+	chainConstants, err := networkChainConstants(walletSubCmds.cli.Config.NetworkName)
+	if err != nil {
+		cli.DieWithError("failed to get chain constants:", err)
+	}
+	var daemonConstants modules.DaemonConstants
+	if walletSubCmds.cli.GetAPI("/daemon/constants", &daemonConstants) == nil {
+		chainConstants.BlockSizeLimit = daemonConstants.BlockSizeLimit
+	}
+	sizeLimit := chainConstants.BlockSizeLimit - 5e3 // see rivinetypes.TransactionFitsInABlock
+	if limit := uint64(chainConstants.TransactionPool.TransactionSizeLimit); limit < sizeLimit {
+		sizeLimit = limit
+	}
+
+	// reserve space for the mint fulfillment, as the transactions are not signed yet,
+	// as well as for the miner fee in case it is yet to be estimated
+	mintFulfillment := walletSubCmds.reservedMintFulfillment()
+	feeCfg := walletSubCmds.coinCreationTxCfg.Fee
+	minerFee := reservedFeePlaceholder
+	if !feeCfg.isAuto() {
+		minerFee = walletSubCmds.fixedMinerFee(feeCfg)
+	}
+
+	// split the rows into batches, reserving room for the batch suffix in the description
+	description := walletSubCmds.coinCreationTxCfg.Description
+	newTx := func(description string) types.CoinCreationTransaction {
+		return types.CoinCreationTransaction{
+			Nonce:           types.RandomTransactionNonce(),
+			MintFulfillment: mintFulfillment,
+			MinerFees:       []rivinetypes.Currency{minerFee},
+			ArbitraryData:   []byte(description),
+		}
+	}
+	reserved := batchDescription(description, len(rows), len(rows))
+	// each output adds its encoded size to the size of the transaction,
+	// such that the size is tracked incrementally instead of encoding the transaction for each row,
+	// starting from the size of a transaction without outputs, as measured using the first row
+	payoutOutput := func(row payoutRow) rivinetypes.CoinOutput {
+		return rivinetypes.CoinOutput{
+			Value:     row.Value,
+			Condition: rivinetypes.NewCondition(rivinetypes.NewUnlockHashCondition(row.Address)),
+		}
+	}
+	tx := newTx(reserved)
+	tx.CoinOutputs = []rivinetypes.CoinOutput{payoutOutput(rows[0])}
+	baseSize := uint64(len(encoding.Marshal(tx.Transaction())) - len(encoding.Marshal(tx.CoinOutputs[0])))
+	var (
+		batches [][]payoutRow
+		batch   []payoutRow
+		size    = baseSize
+	)
+	for _, row := range rows {
+		outputSize := uint64(len(encoding.Marshal(payoutOutput(row))))
+		if baseSize+outputSize > sizeLimit {
+			cli.Die(fmt.Sprintf("the row at line %d cannot fit in a transaction of at most %d bytes", row.Line, sizeLimit))
+		}
+		if size+outputSize > sizeLimit {
+			batches = append(batches, batch)
+			batch, size = nil, baseSize
+		}
+		batch = append(batch, row)
+		size += outputSize
+	}
+	batches = append(batches, batch)
+
+	// create and print the (unsigned) transactions
+	var total, totalFees rivinetypes.Currency
+	summaries := make([]string, 0, len(batches))
+	for idx, batch := range batches {
+		tx := newTx(description)
+		if len(batches) > 1 {
+			tx.ArbitraryData = []byte(batchDescription(description, idx+1, len(batches)))
+		}
+		var batchTotal rivinetypes.Currency
+		for _, row := range batch {
+			tx.CoinOutputs = append(tx.CoinOutputs, rivinetypes.CoinOutput{
+				Value:     row.Value,
+				Condition: rivinetypes.NewCondition(rivinetypes.NewUnlockHashCondition(row.Address)),
+			})
+			batchTotal = batchTotal.Add(row.Value)
+		}
+		if feeCfg.isAuto() {
+			tx.MinerFees = []rivinetypes.Currency{walletSubCmds.minerFee(feeCfg, tx.Transaction())}
+		}
+		tx.MintFulfillment = rivinetypes.UnlockFulfillmentProxy{}
+		total = total.Add(batchTotal)
+		totalFees = totalFees.Add(tx.MinerFees[0])
+		txn := tx.Transaction()
+		json.NewEncoder(os.Stdout).Encode(txn)
+		// the ID of a coin creation transaction depends on its mint fulfillment
+		id := "ID unknown until fully signed"
+		if !types.TransactionHasNilFulfillment(txn) {
+			id = txn.ID().String()
+		}
+		summaries = append(summaries, fmt.Sprintf("  transaction %d/%d (%s): lines %d-%d, %d output(s), %s",
+			idx+1, len(batches), id, batch[0].Line, batch[len(batch)-1].Line,
+			len(batch), currencyConvertor.ToCoinStringWithUnit(batchTotal)))
+	}
+
+	// print the reconciliation summary
+	fmt.Fprintf(os.Stderr, "Reconciliation summary of %s:\n", path)
+	fmt.Fprintf(os.Stderr, "  rows:         %d\n", len(rows))
+	fmt.Fprintf(os.Stderr, "  total:        %s\n", currencyConvertor.ToCoinStringWithUnit(total))
+	fmt.Fprintf(os.Stderr, "  transactions: %d (at most %d bytes each, once signed)\n", len(batches), sizeLimit)
+	fmt.Fprintf(os.Stderr, "  miner fees:   %s\n", currencyConvertor.ToCoinStringWithUnit(totalFees))
+	for _, summary := range summaries {
+		fmt.Fprintln(os.Stderr, summary)
+	}
+}
+
+// batchDescription returns the description of a single batch out of multiple batches
+func batchDescription(description string, batch, batches int) string {
+	if description == "" {
+		return fmt.Sprintf("batch %d/%d", batch, batches)
+	}
+	return fmt.Sprintf("%s (batch %d/%d)", description, batch, batches)
+}
+
+func (walletSubCmds *walletSubCmds) sendMultiSigCmd(cmd *cobra.Command, args []string) {
+	currencyConvertor := walletSubCmds.cli.CreateCurrencyConvertor()
+
+	// Check that the remaining args are condition + value pairs
+	if len(args) < 3 || len(args)%2 != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid arguments. Arguments must be of the form <address> <dest>|<rawCondition> <amount> [<dest>|<rawCondition> <amount>]...")
+	}
+	var address rivinetypes.UnlockHash
+	err := address.LoadString(args[0])
+	if err != nil {
+		cmd.UsageFunc()(cmd)
+		cli.Die("failed to parse multisig address:", err)
+	}
+
+	// parse the remainder as output coditions and values
+	pairs, err := parsePairedOutputs(args[1:], currencyConvertor.ParseCoinString)
+	if err != nil {
+		cmd.UsageFunc()(cmd)
+		cli.Die(err)
+	}
+	feeCfg := walletSubCmds.sendMultiSigCfg.Fee
+	body := api.WalletPostMultiSigTransaction{
+		ArbitraryData: []byte(walletSubCmds.sendMultiSigCfg.Description),
+	}
+	if !feeCfg.isAuto() {
+		body.MinerFee = walletSubCmds.fixedMinerFee(feeCfg)
+	}
+	for _, pair := range pairs {
+		body.CoinOutputs = append(body.CoinOutputs, rivinetypes.CoinOutput{
+			Value:     pair.Value,
+			Condition: pair.Condition,
+		})
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		cli.Die("failed to create/marshal JSON body:", err)
+	}
+
+	var resp api.WalletPostMultiSigTransactionResponse
+	err = walletSubCmds.cli.PostResp("/wallet/multisig/"+address.String()+"/transaction", string(data), &resp)
+	if err != nil {
+		cli.DieWithError("failed to create multisig transaction:", err)
+	}
+
+	// recreate the transaction as long as it doesn't pay the estimated fee,
+	// estimated for the transaction once it is signed by the required amount of co-signers
+	for round := 0; feeCfg.isAuto() && round < feeEstimationRounds; round++ {
+		fee := walletSubCmds.minerFee(feeCfg, reservedMultiSigFulfillments(resp.Transaction, resp.MinimumSignatures))
+		if fee.Cmp(resp.Transaction.MinerFees[0]) <= 0 {
+			break
+		}
+		body.MinerFee = fee
+		data, err = json.Marshal(body)
+		if err != nil {
+			cli.Die("failed to create/marshal JSON body:", err)
+		}
+		err = walletSubCmds.cli.PostResp("/wallet/multisig/"+address.String()+"/transaction", string(data), &resp)
+		if err != nil {
+			cli.DieWithError("failed to create multisig transaction:", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "signed with %d out of %d required signature(s)\n", resp.Signatures, resp.MinimumSignatures)
+	json.NewEncoder(os.Stdout).Encode(resp.Transaction)
+}
+
+func (walletSubCmds *walletSubCmds) sendBatchCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <file>")
+	}
+	currencyConvertor := walletSubCmds.cli.CreateCurrencyConvertor()
+	rows, err := readPayoutFile(args[0], walletSubCmds.sendBatchCfg.Format, currencyConvertor.ParseCoinString)
+	if err != nil {
+		cli.DieWithError("invalid payout file:", err)
+	}
+	body := api.WalletPostPayouts{
+		ID:          walletSubCmds.sendBatchCfg.ID,
+		Description: walletSubCmds.sendBatchCfg.Description,
+		DryRun:      true,
+	}
+	for _, row := range rows {
+		body.Payouts = append(body.Payouts, payouts.Payout{
+			Address: row.Address,
+			Value:   row.Value,
+			Memo:    row.Memo,
+		})
+	}
+	if body.ID == "" {
+		// derive the ID from the payouts, such that the same file resumes the same batch
+		hash := crypto.HashAll(body.Description, body.Payouts)
+		body.ID = hex.EncodeToString(hash[:16])
+	}
+
+	// plan the batch first
+	batch := walletSubCmds.postPayouts(body)
+	printPayoutBatch(batch, currencyConvertor)
+	if batch.Completed {
+		fmt.Fprintln(os.Stderr, "All payouts of this batch are already paid.")
+		return
+	}
+	var planned int
+	for _, txn := range batch.Transactions {
+		if txn.Status == payouts.StatusPlanned || txn.Status == payouts.StatusFailed {
+			planned++
+		}
+	}
+	if planned == 0 {
+		fmt.Fprintln(os.Stderr, "All payouts of this batch are paid or pending, nothing to send.")
+		return
+	}
+	if walletSubCmds.sendBatchCfg.DryRun {
+		return
+	}
+	if !walletSubCmds.sendBatchCfg.Yes {
+		fmt.Fprint(os.Stderr, "Sign and send? [y/N]: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			cli.Die("Batch not sent.")
+		}
+	}
+
+	// sign and send it for real
+	body.DryRun = false
+	batch = walletSubCmds.postPayouts(body)
+	printPayoutBatch(batch, currencyConvertor)
+	for _, txn := range batch.Transactions {
+		if txn.Status == payouts.StatusFailed {
+			cli.Die(fmt.Sprintf("Not all transactions were sent, resume the batch by sending it again using --id %s", batch.ID))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Batch %s sent successfully.\n", batch.ID)
+}
+
+// postPayouts posts the given payouts to the daemon, returning the (planned or sent) batch
+func (walletSubCmds *walletSubCmds) postPayouts(body api.WalletPostPayouts) payouts.Batch {
+	data, err := json.Marshal(body)
+	if err != nil {
+		cli.Die("failed to create/marshal JSON body:", err)
+	}
+	var resp api.WalletGetPayoutBatch
+	err = walletSubCmds.cli.PostResp("/wallet/payouts", string(data), &resp)
+	if err != nil {
+		cli.DieWithError("failed to send payouts:", err)
+	}
+	return resp.Batch
+}
+
+// printPayoutBatch prints a summary of the given batch and its transactions to the STDERR
+func printPayoutBatch(batch payouts.Batch, currencyConvertor client.CurrencyConvertor) {
+	fmt.Fprintf(os.Stderr, "Batch %s:\n", batch.ID)
+	fmt.Fprintf(os.Stderr, "  payouts:      %d\n", len(batch.Payouts))
+	fmt.Fprintf(os.Stderr, "  total:        %s\n", currencyConvertor.ToCoinStringWithUnit(batch.Total))
+	fmt.Fprintf(os.Stderr, "  transactions: %d\n", len(batch.Transactions))
+	for idx, txn := range batch.Transactions {
+		fmt.Fprintf(os.Stderr, "  %d. %-11s %d payout(s), %s + %s fee, %d inputs, %d bytes",
+			idx+1, txn.Status, len(txn.Payouts), currencyConvertor.ToCoinStringWithUnit(txn.Value),
+			currencyConvertor.ToCoinStringWithUnit(txn.Fee), len(txn.Transaction.CoinInputs), txn.Size)
+		if txn.Status != payouts.StatusPlanned {
+			fmt.Fprintf(os.Stderr, " (%s)", txn.TransactionID.String())
+		}
+		fmt.Fprintln(os.Stderr)
+		if txn.Error != "" {
+			fmt.Fprintf(os.Stderr, "     %s\n", txn.Error)
+		}
+	}
+}
+
+func (walletSubCmds *walletSubCmds) exportCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. No arguments can be given.")
+	}
+	entries, err := fetchHistory(walletSubCmds.cli, "/wallet/history")
+	if err != nil {
+		cli.DieWithError("failed to get wallet history:", err)
+	}
+	err = writeHistory(os.Stdout, walletSubCmds.exportCfg.Format, entries, walletSubCmds.cli.CreateCurrencyConvertor())
+	if err != nil {
+		cli.DieWithError("failed to export wallet history:", err)
+	}
+}
+
+func (walletSubCmds *walletSubCmds) listMultiSigTxnsCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <address>")
+	}
+	var address rivinetypes.UnlockHash
+	err := address.LoadString(args[0])
+	if err != nil {
+		cmd.UsageFunc()(cmd)
+		cli.Die("failed to parse multisig address:", err)
+	}
+
+	var resp api.WalletGetMultiSigTransactions
+	err = walletSubCmds.cli.GetAPI("/wallet/multisig/"+address.String()+"/transactions", &resp)
+	if err != nil {
+		cli.DieWithError("failed to get multisig transactions:", err)
+	}
+	txns := append(resp.ConfirmedTransactions, resp.UnconfirmedTransactions...)
+	if len(txns) == 0 {
+		fmt.Println("This multisig wallet has no transaction related to it.")
+		return
+	}
+
+	currencyConvertor := walletSubCmds.cli.CreateCurrencyConvertor()
+	fmt.Println("    [height]                                                   [transaction id]       [net coins]")
+	for i, txn := range txns {
+		var incoming, outgoing rivinetypes.Currency
+		for _, input := range txn.Inputs {
+			if input.FundType == rivinetypes.SpecifierCoinInput && input.RelatedAddress.Cmp(address) == 0 {
+				outgoing = outgoing.Add(input.Value)
+			}
+		}
+		for _, output := range txn.Outputs {
+			if output.FundType == rivinetypes.SpecifierCoinOutput && output.RelatedAddress.Cmp(address) == 0 {
+				incoming = incoming.Add(output.Value)
+			}
+		}
+		height := fmt.Sprintf("%12d", txn.ConfirmationHeight)
+		if i >= len(resp.ConfirmedTransactions) {
+			height = "unconfirmed"
+		}
+		if incoming.Cmp(outgoing) >= 0 {
+			fmt.Printf("%12s %67v %17s\n", height, txn.TransactionID,
+				currencyConvertor.ToCoinStringWithUnit(incoming.Sub(outgoing)))
+		} else {
+			fmt.Printf("%12s %67v %17s\n", height, txn.TransactionID,
+				"-"+currencyConvertor.ToCoinStringWithUnit(outgoing.Sub(incoming)))
+		}
+	}
+}
+
 type (
 	// parseCurrencyString takes the string representation of a currency value
 	parseCurrencyString func(string) (rivinetypes.Currency, error)
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/threefoldfoundation/tfchain/pkg/api"
+
+	"github.com/rivine/rivine/pkg/client"
+)
+
+// selectWallet ensures all API calls of the given client are served using the wallet with the given name,
+// by prefixing its root URL with /wallets/:name, under which the daemon serves its complete API
+// using that wallet for all /wallet endpoints, a no-op for the default wallet.
+func selectWallet(cli *client.CommandLineClient, name string) error {
+	if name == "" || name == api.DefaultWalletName {
+		return nil
+	}
+	if err := api.ValidateWalletName(name); err != nil {
+		return err
+	}
+	cli.RootURL = strings.TrimRight(cli.RootURL, "/") + "/wallets/" + name
+	return nil
+}
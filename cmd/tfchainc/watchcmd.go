@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/threefoldfoundation/tfchain/pkg/api"
+	"github.com/threefoldfoundation/tfchain/pkg/persist"
+
+	"github.com/rivine/rivine/pkg/cli"
+	"github.com/rivine/rivine/pkg/client"
+	rivinetypes "github.com/rivine/rivine/types"
+
+	"github.com/spf13/cobra"
+)
+
+func createWalletWatchSubCmds(client *client.CommandLineClient) {
+	watchSubCmds := &watchSubCmds{cli: client}
+
+	// define commands
+	var (
+		rootCmd = &cobra.Command{
+			Use:   "watch",
+			Short: "Manage the watch-only entries of the wallet",
+			Long: `Manage the watch-only entries of the wallet.
+
+A watch-only entry is an address (or full condition) for which the wallet doesn't own the keys,
+such as a cold storage address or a multisig wallet this wallet isn't a co-owner of.
+The wallet tracks its balances and history, and can create unsigned transactions spending from it,
+which can be signed offline (e.g. using 'pstx sign').
+`,
+		}
+		addCmd = &cobra.Command{
+			Use:   "add <address>|<rawCondition>",
+			Short: "Add a watch-only entry to the wallet",
+			Long: `Add a watch-only entry to the wallet, using an address or a raw (JSON-encoded) condition.
+Giving the full condition allows the wallet to report the signers of a multisig address,
+and to send the change of created transactions back to that same condition.
+
+A new entry is rescanned in the background, starting at the (optional) start height,
+and is reported as rescanning until it caught up, which can take a while.
+`,
+			Run: watchSubCmds.addCmd,
+		}
+		listCmd = &cobra.Command{
+			Use:   "list",
+			Short: "List all watch-only entries of the wallet, with their balances",
+			Run:   watchSubCmds.listCmd,
+		}
+		getCmd = &cobra.Command{
+			Use:   "get <address>",
+			Short: "Get a watch-only entry of the wallet, with its balances and outputs",
+			Long: `Get a watch-only entry of the wallet, with its balances and outputs,
+listing for each output whether it is spendable, locked, pending (spent by an unconfirmed transaction) or spent.
+`,
+			Run: watchSubCmds.getCmd,
+		}
+		removeCmd = &cobra.Command{
+			Use:   "remove <address>",
+			Short: "Remove a watch-only entry from the wallet",
+			Run:   watchSubCmds.removeCmd,
+		}
+		historyCmd = &cobra.Command{
+			Use:   "history <address>",
+			Short: "Export the history of all coin movements of a watch-only entry",
+			Long: `Export the confirmed history of all coin movements of a watch-only entry, as CSV or JSON,
+in the same format as 'wallet export'.
+`,
+			Run: watchSubCmds.historyCmd,
+		}
+		transactionCmd = &cobra.Command{
+			Use:   "transaction <address> <dest>|<rawCondition> <amount> [<dest>|<rawCondition> <amount>]...",
+			Short: "Create an unsigned transaction spending coins of a watch-only entry",
+			Long: `Create an unsigned transaction, sending coins from a watch-only entry
+to one or multiple addresses. The inputs are selected from the spendable outputs of the entry,
+and the change is sent back to the entry.
+
+Amounts have to be given expressed in the OneCoin unit, and without the unit of currency.
+Decimals are possible and have to be defined using the decimal point.
+
+The Minimum Miner Fee will be added on top of the total given amount automatically.
+
+The transaction is printed to the STDOUT as a PSTX container,
+which can be signed offline using 'pstx sign', and finalized using 'pstx finalize',
+after which it can be sent using 'wallet send transaction'.
+`,
+			Run: watchSubCmds.transactionCmd,
+		}
+	)
+
+	// add commands as wallet sub commands
+	rootCmd.AddCommand(
+		addCmd,
+		listCmd,
+		getCmd,
+		removeCmd,
+		historyCmd,
+		transactionCmd,
+	)
+	client.WalletCmd.AddCommand(rootCmd)
+
+	// register flags
+	addCmd.Flags().StringVar(
+		&watchSubCmds.addCfg.Label, "label", "",
+		"optionally label the entry")
+	addCmd.Flags().Uint64Var(
+		&watchSubCmds.addCfg.StartHeight, "start-height", 0,
+		"optionally define the block height from which the entry is scanned, ignoring all prior outputs and transactions")
+	historyCmd.Flags().StringVar(
+		&watchSubCmds.historyCfg.Format, "format", "csv",
+		"format of the export (csv or json)")
+	transactionCmd.Flags().StringVar(
+		&watchSubCmds.transactionCfg.Description, "description", "",
+		"optionally add a description to the transaction, added as arbitrary data")
+}
+
+type watchSubCmds struct {
+	cli    *client.CommandLineClient
+	addCfg struct {
+		Label       string
+		StartHeight uint64
+	}
+	historyCfg struct {
+		Format string
+	}
+	transactionCfg struct {
+		Description string
+	}
+}
+
+func (watchSubCmds *watchSubCmds) addCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <address>|<rawCondition>")
+	}
+	body := api.WalletPostWatch{
+		Label:       watchSubCmds.addCfg.Label,
+		StartHeight: rivinetypes.BlockHeight(watchSubCmds.addCfg.StartHeight),
+	}
+	var uh rivinetypes.UnlockHash
+	if err := uh.LoadString(args[0]); err == nil {
+		body.UnlockHash = &uh
+	} else {
+		var condition rivinetypes.UnlockConditionProxy
+		err = condition.UnmarshalJSON([]byte(args[0]))
+		if err != nil {
+			cmd.UsageFunc()(cmd)
+			cli.Die(fmt.Sprintf("entry has to be an address or JSON-encoded condition, %q is neither", args[0]))
+		}
+		body.Condition = &condition
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		cli.Die("failed to create/marshal JSON body:", err)
+	}
+	err = watchSubCmds.cli.Post("/wallet/watch", string(data))
+	if err != nil {
+		cli.DieWithError("failed to add watch-only entry:", err)
+	}
+	fmt.Println("Watch-only entry added.")
+}
+
+func (watchSubCmds *watchSubCmds) listCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. No arguments can be given.")
+	}
+	var resp api.WalletGetWatch
+	err := watchSubCmds.cli.GetAPI("/wallet/watch", &resp)
+	if err != nil {
+		cli.DieWithError("failed to get watch-only entries:", err)
+	}
+	if len(resp.Entries) == 0 {
+		fmt.Println("No watch-only entries.")
+		return
+	}
+	currencyConvertor := watchSubCmds.cli.CreateCurrencyConvertor()
+	for idx, entry := range resp.Entries {
+		if idx > 0 {
+			fmt.Println()
+		}
+		printWatchEntry(entry, currencyConvertor)
+	}
+}
+
+func (watchSubCmds *watchSubCmds) getCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <address>")
+	}
+	uh := parseWatchAddress(cmd, args[0])
+	var resp api.WalletGetWatchEntry
+	err := watchSubCmds.cli.GetAPI("/wallet/watch/"+uh.String(), &resp)
+	if err != nil {
+		cli.DieWithError("failed to get watch-only entry:", err)
+	}
+	currencyConvertor := watchSubCmds.cli.CreateCurrencyConvertor()
+	printWatchEntry(resp.WalletWatchEntry, currencyConvertor)
+	if len(resp.Outputs) == 0 {
+		return
+	}
+	fmt.Println("Outputs:")
+	for _, output := range resp.Outputs {
+		value := output.Value.String() + " BS"
+		if output.Kind == persist.WatchOutputKindCoin {
+			value = currencyConvertor.ToCoinStringWithUnit(output.Value)
+		}
+		fmt.Printf("  %s  %-9s  %s (height %d)\n", output.ID.String(), output.Status, value, output.CreationHeight)
+	}
+}
+
+func (watchSubCmds *watchSubCmds) removeCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <address>")
+	}
+	uh := parseWatchAddress(cmd, args[0])
+	err := watchSubCmds.cli.Post("/wallet/watch/"+uh.String()+"/remove", "")
+	if err != nil {
+		cli.DieWithError("failed to remove watch-only entry:", err)
+	}
+	fmt.Println("Watch-only entry removed.")
+}
+
+func (watchSubCmds *watchSubCmds) historyCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid amount of arguments. One argument has to be given: <address>")
+	}
+	uh := parseWatchAddress(cmd, args[0])
+	entries, err := fetchHistory(watchSubCmds.cli, "/wallet/watch/"+uh.String()+"/history")
+	if err != nil {
+		cli.DieWithError("failed to get watch-only entry history:", err)
+	}
+	err = writeHistory(os.Stdout, watchSubCmds.historyCfg.Format, entries, watchSubCmds.cli.CreateCurrencyConvertor())
+	if err != nil {
+		cli.DieWithError("failed to export watch-only entry history:", err)
+	}
+}
+
+func (watchSubCmds *watchSubCmds) transactionCmd(cmd *cobra.Command, args []string) {
+	// Check that the remaining args are condition + value pairs
+	if len(args) < 3 || len(args)%2 != 1 {
+		cmd.UsageFunc()(cmd)
+		cli.Die("Invalid arguments. Arguments must be of the form <address> <dest>|<rawCondition> <amount> [<dest>|<rawCondition> <amount>]...")
+	}
+	uh := parseWatchAddress(cmd, args[0])
+
+	// parse the remainder as output coditions and values
+	pairs, err := parsePairedOutputs(args[1:], watchSubCmds.cli.CreateCurrencyConvertor().ParseCoinString)
+	if err != nil {
+		cmd.UsageFunc()(cmd)
+		cli.Die(err)
+	}
+	body := api.WalletPostWatchTransaction{
+		ArbitraryData: []byte(watchSubCmds.transactionCfg.Description),
+	}
+	for _, pair := range pairs {
+		body.CoinOutputs = append(body.CoinOutputs, rivinetypes.CoinOutput{
+			Value:     pair.Value,
+			Condition: pair.Condition,
+		})
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		cli.Die("failed to create/marshal JSON body:", err)
+	}
+
+	var resp api.WalletPostWatchTransactionResponse
+	err = watchSubCmds.cli.PostResp("/wallet/watch/"+uh.String()+"/transaction", string(data), &resp)
+	if err != nil {
+		cli.DieWithError("failed to create watch-only transaction:", err)
+	}
+	err = resp.Transaction.Encode(os.Stdout)
+	if err != nil {
+		cli.DieWithError("failed to encode PSTX:", err)
+	}
+}
+
+// parseWatchAddress parses the address of a watch-only entry, dying if it is invalid
+func parseWatchAddress(cmd *cobra.Command, str string) rivinetypes.UnlockHash {
+	var uh rivinetypes.UnlockHash
+	err := uh.LoadString(str)
+	if err != nil {
+		cmd.UsageFunc()(cmd)
+		cli.Die("failed to parse address:", err)
+	}
+	return uh
+}
+
+// printWatchEntry prints a watch-only entry and its balances to the STDOUT
+func printWatchEntry(entry api.WalletWatchEntry, currencyConvertor client.CurrencyConvertor) {
+	fmt.Println(entry.UnlockHash.String())
+	if entry.Label != "" {
+		fmt.Printf("  label:                %s\n", entry.Label)
+	}
+	if entry.StartHeight > 0 {
+		fmt.Printf("  start height:         %d\n", entry.StartHeight)
+	}
+	if entry.Rescanning {
+		fmt.Println("  rescanning:           balances and outputs are incomplete until the rescan completes")
+	}
+	if len(entry.Signers) > 0 {
+		fmt.Printf("  signatures required:  %d of %d\n", entry.MinimumSignatures, len(entry.Signers))
+		for _, signer := range entry.Signers {
+			fmt.Printf("    %s\n", signer.String())
+		}
+	}
+	fmt.Printf("  confirmed balance:    %s\n", currencyConvertor.ToCoinStringWithUnit(entry.ConfirmedCoinBalance))
+	fmt.Printf("  locked balance:       %s\n", currencyConvertor.ToCoinStringWithUnit(entry.ConfirmedLockedCoinBalance))
+	fmt.Printf("  unconfirmed outgoing: %s\n", currencyConvertor.ToCoinStringWithUnit(entry.UnconfirmedOutgoingCoins))
+	fmt.Printf("  unconfirmed incoming: %s\n", currencyConvertor.ToCoinStringWithUnit(entry.UnconfirmedIncomingCoins))
+	if !entry.BlockStakeBalance.IsZero() || !entry.LockedBlockStakeBalance.IsZero() {
+		fmt.Printf("  block stakes:         %s BS (%s BS locked)\n", entry.BlockStakeBalance.String(), entry.LockedBlockStakeBalance.String())
+	}
+}
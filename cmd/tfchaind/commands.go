@@ -7,7 +7,9 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
+	"github.com/threefoldfoundation/tfchain/pkg/api"
 	"github.com/threefoldfoundation/tfchain/pkg/config"
 	"github.com/threefoldfoundation/tfchain/pkg/persist"
 	"github.com/threefoldfoundation/tfchain/pkg/types"
@@ -17,13 +19,71 @@ import (
 
 	"github.com/bgentry/speakeasy"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 type commands struct {
 	cfg           daemon.Config
+	tfchainCfg    tfchainConfig
 	moduleSetFlag daemon.ModuleSetFlag
 }
 
+// tfchainConfig contains the tfchain-specific daemon configuration,
+// extending the Rivine daemon configuration.
+type tfchainConfig struct {
+	// Wallets are the names of the named wallets to load,
+	// next to the default wallet, only used when the wallet module is loaded
+	Wallets []string
+	// Metrics enables the /metrics endpoint,
+	// exposing the metrics of the daemon in the Prometheus text format
+	Metrics bool
+	// Readiness configures the checks of the /daemon/ready endpoint
+	Readiness api.DaemonReadinessConfig
+	// RosettaAddr is the address the Rosetta API is served on,
+	// only used when the rosetta module is loaded
+	RosettaAddr string
+}
+
+// registerFlags registers the tfchain-specific daemon configuration as flags
+func (cfg *tfchainConfig) registerFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringSliceVar(&cfg.Wallets, "wallets", nil,
+		"names of the additional wallets to load, served using /wallets/:name next to the default wallet")
+	flagSet.BoolVar(&cfg.Metrics, "metrics", false,
+		"serve the metrics of the daemon in the Prometheus text format using the /metrics endpoint")
+	flagSet.DurationVar(&cfg.Readiness.MaxBlockAge, "ready-max-block-age", time.Hour,
+		"maximum age of the current block for the daemon to be reported as ready by /daemon/ready, 0 to disable")
+	flagSet.IntVar(&cfg.Readiness.MinPeers, "ready-min-peers", 1,
+		"minimum amount of connected peers for the daemon to be reported as ready by /daemon/ready")
+	flagSet.BoolVar(&cfg.Readiness.RequireUnlockedWallet, "ready-require-unlocked-wallet", false,
+		"require the wallet to be unlocked for the daemon to be reported as ready by /daemon/ready")
+	flagSet.StringVar(&cfg.RosettaAddr, "rosetta-addr", "localhost:8080",
+		"address to serve the Rosetta API on, only used when the rosetta module is loaded")
+}
+
+// validate the tfchain-specific daemon configuration
+func (cfg *tfchainConfig) validate() error {
+	if cfg.Readiness.MaxBlockAge < 0 {
+		return errors.New("maximum block age cannot be negative")
+	}
+	if cfg.Readiness.MinPeers < 0 {
+		return errors.New("minimum amount of peers cannot be negative")
+	}
+	names := make(map[string]struct{}, len(cfg.Wallets))
+	for _, name := range cfg.Wallets {
+		if err := api.ValidateWalletName(name); err != nil {
+			return err
+		}
+		if name == api.DefaultWalletName {
+			return fmt.Errorf("wallet name %q is reserved for the default wallet", name)
+		}
+		if _, ok := names[name]; ok {
+			return fmt.Errorf("wallet name %q is defined multiple times", name)
+		}
+		names[name] = struct{}{}
+	}
+	return nil
+}
+
 func (cmds *commands) rootCommand(*cobra.Command, []string) {
 	var err error
 
@@ -52,8 +112,13 @@ func (cmds *commands) rootCommand(*cobra.Command, []string) {
 	// Process the config variables, cleaning up slightly invalid values
 	cmds.cfg = daemon.ProcessConfig(cmds.cfg)
 
+	err = cmds.tfchainCfg.validate()
+	if err != nil {
+		cli.DieWithError("invalid daemon configuration", err)
+	}
+
 	// run daemon
-	err = runDaemon(cmds.cfg, cmds.moduleSetFlag.ModuleIdentifiers())
+	err = runDaemon(cmds.cfg, cmds.tfchainCfg, cmds.moduleSetFlag.ModuleIdentifiers())
 	if err != nil {
 		cli.DieWithError("daemon failed", err)
 	}
@@ -9,6 +9,13 @@ import (
 	"time"
 
 	"github.com/threefoldfoundation/tfchain/pkg/api"
+	"github.com/threefoldfoundation/tfchain/pkg/jsonrpc"
+	"github.com/threefoldfoundation/tfchain/pkg/modules/atomicswapagent"
+	"github.com/threefoldfoundation/tfchain/pkg/modules/proposals"
+	"github.com/threefoldfoundation/tfchain/pkg/modules/rosetta"
+	"github.com/threefoldfoundation/tfchain/pkg/modules/stream"
+	"github.com/threefoldfoundation/tfchain/pkg/modules/webhooks"
+	"github.com/threefoldfoundation/tfchain/pkg/persist"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/rivine/rivine/modules"
@@ -17,12 +24,11 @@ import (
 	"github.com/rivine/rivine/modules/explorer"
 	"github.com/rivine/rivine/modules/gateway"
 	"github.com/rivine/rivine/modules/transactionpool"
-	"github.com/rivine/rivine/modules/wallet"
 	rivineapi "github.com/rivine/rivine/pkg/api"
 	"github.com/rivine/rivine/pkg/daemon"
 )
 
-func runDaemon(cfg daemon.Config, moduleIdentifiers daemon.ModuleIdentifierSet) error {
+func runDaemon(cfg daemon.Config, tfchainCfg tfchainConfig, moduleIdentifiers daemon.ModuleIdentifierSet) error {
 	// Print a startup message.
 	fmt.Println("Loading...")
 	loadStart := time.Now()
@@ -30,10 +36,12 @@ func runDaemon(cfg daemon.Config, moduleIdentifiers daemon.ModuleIdentifierSet)
 	var (
 		i             int
 		modulesToLoad = moduleIdentifiers.Len()
+		loadedModules []string
 	)
 	printModuleIsLoading := func(name string) {
 		fmt.Printf("Loading %s (%d/%d)...\r\n", name, i, modulesToLoad)
 		i++
+		loadedModules = append(loadedModules, name)
 	}
 
 	// create our server already, this way we can fail early if the API addr is already bound
@@ -47,9 +55,6 @@ func runDaemon(cfg daemon.Config, moduleIdentifiers daemon.ModuleIdentifierSet)
 		servErrs <- srv.Serve()
 	}()
 
-	// router to register all endpoints to
-	router := httprouter.New()
-
 	// create and validate network config, and the transactionDB as well
 	// txdb is on index 0, as it is not manually loaded
 	printModuleIsLoading("(auto) transaction db")
@@ -68,7 +73,20 @@ func runDaemon(cfg daemon.Config, moduleIdentifiers daemon.ModuleIdentifierSet)
 	if err != nil {
 		return fmt.Errorf("failed to validate network config: %v", err)
 	}
+
+	// router to register all endpoints to,
+	// instrumented in case the metrics are enabled
+	var (
+		router daemonRouter = httprouter.New()
+		dm     *daemonMetrics
+	)
+	if tfchainCfg.Metrics {
+		dm = newDaemonMetrics(networkCfg.Constants)
+		router = dm.instrumentRouter(router.(*httprouter.Router))
+		dm.registerTransactionDB(txdb)
+	}
 	api.RegisterTransactionDBHTTPHandlers(router, txdb)
+	api.RegisterTransactionsHTTPHandlers(router)
 
 	// Initialize the Rivine modules
 	var g modules.Gateway
@@ -81,6 +99,9 @@ func runDaemon(cfg daemon.Config, moduleIdentifiers daemon.ModuleIdentifierSet)
 			return err
 		}
 		rivineapi.RegisterGatewayHTTPHandlers(router, g, cfg.APIPassword)
+		if dm != nil {
+			dm.registerGateway(g)
+		}
 		defer func() {
 			fmt.Println("Closing gateway...")
 			err := g.Close()
@@ -111,6 +132,16 @@ func runDaemon(cfg daemon.Config, moduleIdentifiers daemon.ModuleIdentifierSet)
 		if err != nil {
 			return fmt.Errorf("failed to subscribe earlier created transactionDB to the consensus created just now: %v", err)
 		}
+		if dm != nil {
+			err = dm.registerConsensusSet(cs)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				fmt.Println("Closing metrics...")
+				dm.Close()
+			}()
+		}
 	}
 	var tpool modules.TransactionPool
 	if moduleIdentifiers.Contains(daemon.TransactionPoolModule.Identifier()) {
@@ -122,6 +153,10 @@ func runDaemon(cfg daemon.Config, moduleIdentifiers daemon.ModuleIdentifierSet)
 			return err
 		}
 		rivineapi.RegisterTransactionPoolHTTPHandlers(router, cs, tpool, cfg.APIPassword)
+		api.RegisterTransactionPoolHTTPHandlers(router, cs, tpool, txdb, networkCfg.Constants)
+		if dm != nil {
+			dm.registerTransactionPool(tpool)
+		}
 		defer func() {
 			fmt.Println("Closing transaction pool...")
 			err := tpool.Close()
@@ -133,21 +168,48 @@ func runDaemon(cfg daemon.Config, moduleIdentifiers daemon.ModuleIdentifierSet)
 	var w modules.Wallet
 	if moduleIdentifiers.Contains(daemon.WalletModule.Identifier()) {
 		printModuleIsLoading("wallet")
-		w, err = wallet.New(cs, tpool,
-			filepath.Join(cfg.RootPersistentDir, modules.WalletDir),
-			cfg.BlockchainInfo, networkCfg.Constants)
+		var namedWallets []api.NamedWallet
+		loadWallet := func(name, persistDir string) (*walletModules, error) {
+			wm, err := loadWalletModules(name, persistDir, cs, tpool, cfg, networkCfg.Constants)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load %s wallet: %v", name, err)
+			}
+			// each wallet serves its /wallet endpoints on its own router,
+			// such that it can be served as /wallets/:name
+			walletRouter := httprouter.New()
+			wm.registerHTTPHandlers(walletRouter, cs, tpool, networkCfg.Constants, cfg.APIPassword)
+			namedWallets = append(namedWallets, api.NamedWallet{Name: name, Wallet: wm.wallet, Handler: walletRouter})
+			return wm, nil
+		}
+
+		// the default wallet is used by all other modules,
+		// and is served using the /wallet endpoints as well
+		wm, err := loadWallet(api.DefaultWalletName, cfg.RootPersistentDir)
 		if err != nil {
 			return err
 		}
-		rivineapi.RegisterWalletHTTPHandlers(router, w, cfg.APIPassword)
+		w = wm.wallet
+		wm.registerHTTPHandlers(router, cs, tpool, networkCfg.Constants, cfg.APIPassword)
 		defer func() {
-			fmt.Println("Closing wallet...")
-			err := w.Close()
+			err := wm.Close()
 			if err != nil {
-				fmt.Println("Error during wallet shutdown:", err)
+				fmt.Println("Error during default wallet shutdown:", err)
 			}
 		}()
 
+		for _, name := range tfchainCfg.Wallets {
+			wm, err := loadWallet(name, filepath.Join(cfg.RootPersistentDir, namedWalletsDir, name))
+			if err != nil {
+				return err
+			}
+			defer func() {
+				err := wm.Close()
+				if err != nil {
+					fmt.Printf("Error during %s wallet shutdown: %v\n", wm.name, err)
+				}
+			}()
+		}
+		api.RegisterNamedWalletsHTTPHandlers(router, router, namedWallets, cfg.APIPassword)
 	}
 	var b modules.BlockCreator
 	if moduleIdentifiers.Contains(daemon.BlockCreatorModule.Identifier()) {
@@ -177,6 +239,10 @@ func runDaemon(cfg daemon.Config, moduleIdentifiers daemon.ModuleIdentifierSet)
 			return err
 		}
 		rivineapi.RegisterExplorerHTTPHandlers(router, cs, e, tpool)
+		api.RegisterExplorerHistoryHTTPHandlers(router, e, networkCfg.Constants)
+		if tpool != nil {
+			api.RegisterTransactionPoolFeeHTTPHandlers(router, e, tpool, txdb, networkCfg.Constants)
+		}
 		defer func() {
 			fmt.Println("Closing explorer...")
 			err := e.Close()
@@ -184,6 +250,145 @@ func runDaemon(cfg daemon.Config, moduleIdentifiers daemon.ModuleIdentifierSet)
 				fmt.Println("Error during explorer shutdown:", err)
 			}
 		}()
+
+		// the supply db is an extension of the explorer,
+		// and is thus only loaded when the explorer module is loaded
+		sdb, err := persist.NewSupplyDB(cfg.RootPersistentDir, networkCfg.Constants)
+		if err != nil {
+			return fmt.Errorf("failed to create supply db: %v", err)
+		}
+		defer func() {
+			fmt.Println("Closing supply db...")
+			err := sdb.Close()
+			if err != nil {
+				fmt.Println("Error during supplydb shutdown:", err)
+			}
+		}()
+		err = sdb.SubscribeToConsensusSet(cs)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe supplyDB to the consensus: %v", err)
+		}
+		api.RegisterSupplyDBHTTPHandlers(router, sdb)
+	}
+
+	// the atomic swap db is an extension of the explorer,
+	// but is required by the atomic swap agent as well
+	var asdb *persist.AtomicSwapDB
+	if moduleIdentifiers.Contains(daemon.ExplorerModule.Identifier()) || moduleIdentifiers.Contains(atomicSwapAgentModule.Identifier()) {
+		asdb, err = persist.NewAtomicSwapDB(cfg.RootPersistentDir)
+		if err != nil {
+			return fmt.Errorf("failed to create atomic swap db: %v", err)
+		}
+		defer func() {
+			fmt.Println("Closing atomic swap db...")
+			err := asdb.Close()
+			if err != nil {
+				fmt.Println("Error during atomicswapdb shutdown:", err)
+			}
+		}()
+		err = asdb.SubscribeToConsensusSet(cs)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe atomicSwapDB to the consensus: %v", err)
+		}
+		if moduleIdentifiers.Contains(daemon.ExplorerModule.Identifier()) {
+			api.RegisterAtomicSwapDBHTTPHandlers(router, asdb)
+		}
+	}
+	if moduleIdentifiers.Contains(atomicSwapAgentModule.Identifier()) {
+		printModuleIsLoading("atomic swap agent")
+		agent, err := atomicswapagent.New(cfg.RootPersistentDir, asdb, cs, tpool, w, networkCfg.Constants)
+		if err != nil {
+			return err
+		}
+		api.RegisterAtomicSwapAgentHTTPHandlers(router, agent, cfg.APIPassword)
+		defer func() {
+			fmt.Println("Closing atomic swap agent...")
+			err := agent.Close()
+			if err != nil {
+				fmt.Println("Error during atomic swap agent shutdown:", err)
+			}
+		}()
+	}
+	if moduleIdentifiers.Contains(proposalPoolModule.Identifier()) {
+		printModuleIsLoading("proposal pool")
+		pool, err := proposals.New(cfg.RootPersistentDir, cs, tpool, txdb)
+		if err != nil {
+			return err
+		}
+		api.RegisterProposalPoolHTTPHandlers(router, pool, cfg.APIPassword)
+		defer func() {
+			fmt.Println("Closing proposal pool...")
+			err := pool.Close()
+			if err != nil {
+				fmt.Println("Error during proposal pool shutdown:", err)
+			}
+		}()
+	}
+
+	// the stream is shared with the JSON-RPC interface
+	var s *stream.Stream
+	if moduleIdentifiers.Contains(streamModule.Identifier()) {
+		printModuleIsLoading("stream")
+		s, err = stream.New(cs, tpool)
+		if err != nil {
+			return err
+		}
+		api.RegisterStreamHTTPHandlers(router, s)
+		defer func() {
+			fmt.Println("Closing stream...")
+			err := s.Close()
+			if err != nil {
+				fmt.Println("Error during stream shutdown:", err)
+			}
+		}()
+	}
+	if moduleIdentifiers.Contains(notifierModule.Identifier()) {
+		printModuleIsLoading("notifier")
+		n, err := webhooks.New(cfg.RootPersistentDir, cs, tpool)
+		if err != nil {
+			return err
+		}
+		api.RegisterWebhookNotifierHTTPHandlers(router, n, cfg.APIPassword)
+		defer func() {
+			fmt.Println("Closing notifier...")
+			err := n.Close()
+			if err != nil {
+				fmt.Println("Error during notifier shutdown:", err)
+			}
+		}()
+	}
+	if moduleIdentifiers.Contains(rosettaModule.Identifier()) {
+		printModuleIsLoading("rosetta")
+		rs, err := rosetta.New(cfg.RootPersistentDir, cs, e, tpool, g, cfg.BlockchainInfo, networkCfg.Constants)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			fmt.Println("Closing rosetta index...")
+			err := rs.Close()
+			if err != nil {
+				fmt.Println("Error during rosetta index shutdown:", err)
+			}
+		}()
+		// the Rosetta API is served on its own address, without requiring a user agent,
+		// as Rosetta clients are generic
+		rosettaSrv, err := daemon.NewHTTPServer(tfchainCfg.RosettaAddr)
+		if err != nil {
+			return fmt.Errorf("failed to bind the Rosetta API address: %v", err)
+		}
+		rosettaSrv.Handle("/", rs)
+		go func() {
+			if err := rosettaSrv.Serve(); err != nil {
+				servErrs <- err
+			}
+		}()
+		defer func() {
+			fmt.Println("Closing rosetta...")
+			err := rosettaSrv.Close()
+			if err != nil {
+				fmt.Println("Error during rosetta shutdown:", err)
+			}
+		}()
 	}
 
 	fmt.Println("Setting up root HTTP API handler...")
@@ -214,6 +419,34 @@ func runDaemon(cfg daemon.Config, moduleIdentifiers daemon.ModuleIdentifierSet)
 			servErrs <- err
 		}
 	})
+	api.RegisterDaemonHealthHTTPHandlers(router, api.DaemonHealthModules{
+		Loaded:        loadedModules,
+		Gateway:       g,
+		ConsensusSet:  cs,
+		Wallet:        w,
+		TransactionDB: txdb,
+	}, tfchainCfg.Readiness)
+
+	// serve all routes over JSON-RPC as well, notifying websocket clients of new blocks
+	// using the stream, which is created for the JSON-RPC interface only if its module isn't loaded
+	if s == nil && cs != nil && tpool != nil {
+		s, err = stream.New(cs, tpool)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err := s.Close()
+			if err != nil {
+				fmt.Println("Error during JSON-RPC stream shutdown:", err)
+			}
+		}()
+	}
+	api.RegisterJSONRPCHTTPHandlers(router, jsonrpc.NewServer(router, s))
+	if dm != nil {
+		router.GET("/metrics", func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+			dm.registry.ServeHTTP(w, req)
+		})
+	}
 
 	// handle all our endpoints over a router,
 	// which requires a user agent should one be configured
@@ -18,7 +18,7 @@ func main() {
 	cmds.cfg.BlockchainInfo = config.GetBlockchainInfo()
 
 	// load default config flag
-	cmds.moduleSetFlag = daemon.DefaultModuleSetFlag()
+	cmds.moduleSetFlag = newModuleSetFlag()
 
 	// create the root command and add the flags to the root command
 	root := &cobra.Command{
@@ -30,6 +30,7 @@ func main() {
 		Run: cmds.rootCommand,
 	}
 	cmds.cfg.RegisterAsFlags(root.Flags())
+	cmds.tfchainCfg.registerFlags(root.Flags())
 	// also add our modules as a flag
 	cmds.moduleSetFlag.RegisterFlag(root.Flags(), fmt.Sprintf("%s modules", os.Args[0]))
 
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/threefoldfoundation/tfchain/pkg/metrics"
+	"github.com/threefoldfoundation/tfchain/pkg/persist"
+	tftypes "github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// daemonRouter is the router all HTTP endpoints of the daemon are registered to.
+type daemonRouter interface {
+	GET(path string, handle httprouter.Handle)
+	POST(path string, handle httprouter.Handle)
+	http.Handler
+}
+
+// daemonMetrics collects the metrics of the daemon and its modules,
+// served in the Prometheus text exposition format using the /metrics endpoint.
+type daemonMetrics struct {
+	registry  *metrics.Registry
+	constants types.ChainConstants
+
+	apiRequests     *metrics.Counter
+	apiLatency      *metrics.Histogram
+	blockLatency    *metrics.Histogram
+	reorgs          *metrics.Counter
+	reorgDepth      *metrics.Histogram
+	revertedBlocks  *metrics.Counter
+	txdbDuration    *metrics.Histogram
+	txdbBlocks      *metrics.Counter
+	mintedSupply    *metrics.Gauge
+	mintedSupplySum types.Currency
+
+	cs   modules.ConsensusSet
+	stop chan struct{}
+	// live is false as long as the consensus set subscriber is processing past consensus changes,
+	// such that those changes only update the minted supply, and not the live block metrics
+	live bool
+	mu   sync.Mutex
+}
+
+// newDaemonMetrics creates the metrics of the daemon itself,
+// the metrics of modules are registered once they are loaded.
+func newDaemonMetrics(constants types.ChainConstants) *daemonMetrics {
+	r := metrics.NewRegistry()
+	return &daemonMetrics{
+		registry:  r,
+		constants: constants,
+		apiRequests: r.NewCounter("tfchain_api_requests_total",
+			"Total amount of API requests, per method, route and status code.", "method", "route", "code"),
+		apiLatency: r.NewHistogram("tfchain_api_request_duration_seconds",
+			"Duration of API requests, per method and route.", nil, "method", "route"),
+		blockLatency: r.NewHistogram("tfchain_block_acceptance_latency_seconds",
+			"Duration between the timestamp of a block and its acceptance by the consensus set, once synced.",
+			[]float64{1, 5, 10, 30, 60, 120, 300, 600}),
+		reorgs: r.NewCounter("tfchain_consensus_reorgs_total",
+			"Total amount of reorgs, consensus changes which reverted blocks."),
+		reorgDepth: r.NewHistogram("tfchain_consensus_reorg_depth",
+			"Amount of blocks reverted by a reorg.", []float64{1, 2, 3, 5, 10, 25, 50, 100}),
+		revertedBlocks: r.NewCounter("tfchain_consensus_reverted_blocks_total",
+			"Total amount of blocks reverted by reorgs."),
+		txdbDuration: r.NewHistogram("tfchain_transactiondb_duration_seconds",
+			"Duration of applying or reverting the blocks of a consensus change in the TransactionDB.", nil, "operation"),
+		txdbBlocks: r.NewCounter("tfchain_transactiondb_blocks_total",
+			"Total amount of blocks applied or reverted by the TransactionDB.", "operation"),
+		mintedSupply: r.NewGauge("tfchain_minted_supply_coins",
+			"Total amount of coins created by coin creation transactions."),
+		stop: make(chan struct{}),
+	}
+}
+
+// instrumentRouter wraps the given router, such that the amount and duration
+// of the requests are measured for each registered route.
+func (dm *daemonMetrics) instrumentRouter(router *httprouter.Router) daemonRouter {
+	return &instrumentedRouter{Router: router, metrics: dm}
+}
+
+// registerGateway registers the metrics of the gateway.
+func (dm *daemonMetrics) registerGateway(g modules.Gateway) {
+	dm.registry.NewGaugeFunc("tfchain_gateway_peers", "Amount of connected peers.", func() float64 {
+		return float64(len(g.Peers()))
+	})
+}
+
+// registerTransactionPool registers the metrics of the transaction pool.
+func (dm *daemonMetrics) registerTransactionPool(tpool modules.TransactionPool) {
+	dm.registry.NewGaugeFunc("tfchain_transactionpool_transactions", "Amount of transactions in the transaction pool.", func() float64 {
+		return float64(len(tpool.TransactionList()))
+	})
+	dm.registry.NewGaugeFunc("tfchain_transactionpool_bytes", "Size in bytes of all transactions in the transaction pool.", func() float64 {
+		var size int
+		for _, txn := range tpool.TransactionList() {
+			size += len(encoding.Marshal(txn))
+		}
+		return float64(size)
+	})
+}
+
+// registerTransactionDB registers the metrics of the TransactionDB,
+// it has to be called prior to subscribing the TransactionDB to the consensus set.
+func (dm *daemonMetrics) registerTransactionDB(txdb *persist.TransactionDB) {
+	txdb.SetDurationObserver(func(op persist.TransactionDBOperation, blocks int, duration time.Duration) {
+		dm.txdbDuration.Observe(duration.Seconds(), string(op))
+		dm.txdbBlocks.Add(float64(blocks), string(op))
+	})
+	mintConditionGauge := func(fn func(condition types.UnlockConditionProxy) float64) func() float64 {
+		return func() float64 {
+			condition, err := txdb.GetActiveMintCondition()
+			if err != nil {
+				return 0
+			}
+			return fn(condition)
+		}
+	}
+	dm.registry.NewGaugeFunc("tfchain_mint_condition_signers", "Amount of signers of the active mint condition.",
+		mintConditionGauge(func(condition types.UnlockConditionProxy) float64 {
+			return float64(len(tftypes.ConditionUnlockHashes(condition)))
+		}))
+	dm.registry.NewGaugeFunc("tfchain_mint_condition_minimum_signatures", "Minimum amount of signatures required by the active mint condition.",
+		mintConditionGauge(func(condition types.UnlockConditionProxy) float64 {
+			if ms, ok := condition.Condition.(*types.MultiSignatureCondition); ok {
+				return float64(ms.MinimumSignatureCount)
+			}
+			if len(tftypes.ConditionUnlockHashes(condition)) > 0 {
+				return 1
+			}
+			return 0
+		}))
+}
+
+// registerConsensusSet registers the metrics of the consensus set,
+// and subscribes to it, as to compute the minted supply
+// and observe all blocks accepted from now on.
+func (dm *daemonMetrics) registerConsensusSet(cs modules.ConsensusSet) error {
+	dm.registry.NewGaugeFunc("tfchain_consensus_height", "Height of the current block.", func() float64 {
+		return float64(cs.Height())
+	})
+	dm.registry.NewGaugeFunc("tfchain_consensus_synced", "1 if the consensus set is synced, 0 otherwise.", func() float64 {
+		if cs.Synced() {
+			return 1
+		}
+		return 0
+	})
+	// the minted supply is computed from the entire chain,
+	// hence the subscription replays all past consensus changes
+	err := cs.ConsensusSetSubscribe(dm, modules.ConsensusChangeBeginning, dm.stop)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe metrics to the consensus set: %v", err)
+	}
+	dm.cs = cs
+	dm.mu.Lock()
+	dm.live = true
+	dm.mu.Unlock()
+	return nil
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber.
+func (dm *daemonMetrics) ProcessConsensusChange(css modules.ConsensusChange) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	for _, block := range css.RevertedBlocks {
+		dm.mintedSupplySum = dm.mintedSupplySum.Sub(mintedCoins(block))
+	}
+	for _, block := range css.AppliedBlocks {
+		dm.mintedSupplySum = dm.mintedSupplySum.Add(mintedCoins(block))
+	}
+	supply, _ := new(big.Rat).SetFrac(dm.mintedSupplySum.Big(), dm.constants.CurrencyUnits.OneCoin.Big()).Float64()
+	dm.mintedSupply.Set(supply)
+
+	if !dm.live {
+		return // past consensus changes are not observed
+	}
+	if n := len(css.RevertedBlocks); n > 0 {
+		dm.reorgs.Inc()
+		dm.reorgDepth.Observe(float64(n))
+		dm.revertedBlocks.Add(float64(n))
+	}
+	if css.Synced {
+		now := time.Now()
+		for _, block := range css.AppliedBlocks {
+			latency := now.Sub(time.Unix(int64(block.Timestamp), 0)).Seconds()
+			if latency < 0 {
+				latency = 0 // block timestamps can be in the future, within limits
+			}
+			dm.blockLatency.Observe(latency)
+		}
+	}
+}
+
+// Close unsubscribes the metrics from the consensus set, if subscribed.
+func (dm *daemonMetrics) Close() {
+	close(dm.stop)
+	if dm.cs != nil {
+		dm.cs.Unsubscribe(dm)
+	}
+}
+
+// mintedCoins returns the sum of all coins created by the coin creation transactions of the given block
+func mintedCoins(block types.Block) (minted types.Currency) {
+	for _, txn := range block.Transactions {
+		if txn.Version != tftypes.TransactionVersionCoinCreation {
+			continue
+		}
+		for _, co := range txn.CoinOutputs {
+			minted = minted.Add(co.Value)
+		}
+	}
+	return
+}
+
+// instrumentedRouter measures the amount and duration of the requests of all its routes.
+type instrumentedRouter struct {
+	*httprouter.Router
+	metrics *daemonMetrics
+}
+
+// GET implements rivineapi.Router.GET
+func (router *instrumentedRouter) GET(path string, handle httprouter.Handle) {
+	router.Router.GET(path, router.instrument(http.MethodGet, path, handle))
+}
+
+// POST implements rivineapi.Router.POST
+func (router *instrumentedRouter) POST(path string, handle httprouter.Handle) {
+	router.Router.POST(path, router.instrument(http.MethodPost, path, handle))
+}
+
+func (router *instrumentedRouter) instrument(method, route string, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		start := time.Now()
+		rw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		handle(rw, req, ps)
+		router.metrics.apiLatency.Observe(time.Since(start).Seconds(), method, route)
+		router.metrics.apiRequests.Inc(method, route, strconv.Itoa(rw.status))
+	}
+}
+
+// statusResponseWriter records the status code written to a response writer
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter.WriteHeader
+func (rw *statusResponseWriter) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher.Flush,
+// a no-op in case the underlying response writer can't be flushed
+func (rw *statusResponseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.Hijack,
+// such that websocket connections can be served on instrumented routes
+func (rw *statusResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer cannot be hijacked")
+	}
+	rw.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
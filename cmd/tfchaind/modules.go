@@ -0,0 +1,99 @@
+package main
+
+import (
+	"github.com/rivine/rivine/pkg/daemon"
+)
+
+// all tfchain-specific modules,
+// which can be loaded on top of the modules that ship with Rivine
+var (
+	atomicSwapAgentModule = &daemon.Module{
+		Name: "Atomic Swap Agent",
+		Description: `The atomic swap agent watches the atomic swap contracts of the wallet,
+redeeming them automatically as soon as the secret is known,
+and refunding them automatically once their timelock expired.
+The wallet has to be unlocked in order for the agent to do its work.`,
+		Dependencies: daemon.ForceNewIdentifierSet(
+			daemon.ConsensusSetModule.Identifier(),
+			daemon.TransactionPoolModule.Identifier(),
+			daemon.WalletModule.Identifier(),
+		),
+	}
+	proposalPoolModule = &daemon.Module{
+		Name: "Proposal Pool",
+		Description: `The proposal pool collects unsigned transactions, such as coin creation,
+minter definition and multisig transactions, as proposals to be signed by their co-signers.
+Each co-signer can list the proposals relevant to their keys and add their signature,
+after which the pool broadcasts the transaction as soon as it is fully signed.
+Proposals that aren't fully signed prior to their expiration are marked as expired.`,
+		Dependencies: daemon.ForceNewIdentifierSet(
+			daemon.ConsensusSetModule.Identifier(),
+			daemon.TransactionPoolModule.Identifier(),
+		),
+	}
+	streamModule = &daemon.Module{
+		Name: "Stream",
+		Description: `The stream streams the chain activity as server-sent events (using /events),
+such as applied and reverted blocks, confirmed and unconfirmed transactions
+(optionally filtered by unlock hash or transaction version) and mint condition changes.
+Clients can resume the stream from the last consensus change they received.`,
+		Dependencies: daemon.ForceNewIdentifierSet(
+			daemon.ConsensusSetModule.Identifier(),
+			daemon.TransactionPoolModule.Identifier(),
+		),
+	}
+	notifierModule = &daemon.Module{
+		Name: "Notifier",
+		Description: `The notifier notifies registered webhooks of the unconfirmed, confirmed and reverted transactions
+relevant to them (filtered by unlock hash or transaction version), by POSTing signed JSON notifications.
+Failed deliveries are retried with backoff, using a persistent delivery queue.`,
+		Dependencies: daemon.ForceNewIdentifierSet(
+			daemon.ConsensusSetModule.Identifier(),
+			daemon.TransactionPoolModule.Identifier(),
+		),
+	}
+	rosettaModule = &daemon.Module{
+		Name: "Rosetta",
+		Description: `The rosetta module serves the Rosetta (Mesh) Data and Construction API on its own address,
+such that exchanges can integrate tfchain using their generic Rosetta tooling.
+Blocks, balances and the transaction pool are exposed using the coin (UTXO) model,
+while transactions spending the coins of single public key addresses can be constructed offline.`,
+		Dependencies: daemon.ForceNewIdentifierSet(
+			daemon.ConsensusSetModule.Identifier(),
+			daemon.TransactionPoolModule.Identifier(),
+			daemon.ExplorerModule.Identifier(),
+		),
+	}
+)
+
+// newModuleSetFlag creates the module set flag for tfchaind,
+// using the default Rivine module set, extended with all tfchain-specific modules,
+// while the default identifiers stay the same as the default Rivine ones.
+func newModuleSetFlag() daemon.ModuleSetFlag {
+	set := daemon.DefaultModuleSet()
+	for _, mod := range []*daemon.Module{
+		atomicSwapAgentModule,
+		proposalPoolModule,
+		streamModule,
+		notifierModule,
+		rosettaModule,
+	} {
+		err := set.Append(mod)
+		if err != nil {
+			panic(err)
+		}
+	}
+	msFlag, err := daemon.NewModuleSetFlag("modules", "M",
+		daemon.ForceNewIdentifierSet(
+			daemon.ConsensusSetModule.Identifier(),
+			daemon.GatewayModule.Identifier(),
+			daemon.TransactionPoolModule.Identifier(),
+			daemon.WalletModule.Identifier(),
+			daemon.BlockCreatorModule.Identifier(),
+		),
+		set)
+	if err != nil {
+		panic(err)
+	}
+	return msFlag
+}
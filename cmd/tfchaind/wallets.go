@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/threefoldfoundation/tfchain/pkg/api"
+	"github.com/threefoldfoundation/tfchain/pkg/modules/payouts"
+	"github.com/threefoldfoundation/tfchain/pkg/persist"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/modules/wallet"
+	rivineapi "github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/pkg/daemon"
+	"github.com/rivine/rivine/types"
+)
+
+// namedWalletsDir is the directory (within the root persistent directory)
+// in which the named wallets are stored, each in a directory with its own name
+const namedWalletsDir = "wallets"
+
+// walletModules groups a wallet together with all its tfchain extensions,
+// each wallet having its own seed, encryption and lock state.
+type walletModules struct {
+	name    string
+	wallet  modules.Wallet
+	payouts *payouts.Manager
+	watchDB *persist.WatchDB
+}
+
+// loadWalletModules creates a wallet and all its tfchain extensions,
+// storing all of them in the given persistent directory.
+func loadWalletModules(name, persistDir string, cs modules.ConsensusSet, tpool modules.TransactionPool, cfg daemon.Config, constants types.ChainConstants) (*walletModules, error) {
+	wm := &walletModules{name: name}
+	var err error
+	wm.wallet, err = wallet.New(cs, tpool,
+		filepath.Join(persistDir, modules.WalletDir),
+		cfg.BlockchainInfo, constants)
+	if err != nil {
+		return nil, err
+	}
+
+	// the payout manager and watch DB are extensions of the wallet,
+	// and are thus only loaded when the wallet module is loaded
+	wm.payouts, err = payouts.New(persistDir, cs, tpool, wm.wallet, constants)
+	if err != nil {
+		return nil, build.ComposeErrors(fmt.Errorf("failed to create payout manager: %v", err), wm.Close())
+	}
+	wm.watchDB, err = persist.NewWatchDB(persistDir, constants)
+	if err != nil {
+		return nil, build.ComposeErrors(fmt.Errorf("failed to create watch DB: %v", err), wm.Close())
+	}
+	err = wm.watchDB.SubscribeToConsensusSet(cs)
+	if err != nil {
+		return nil, build.ComposeErrors(fmt.Errorf("failed to subscribe watch DB to the consensus set: %v", err), wm.Close())
+	}
+	return wm, nil
+}
+
+// registerHTTPHandlers registers all /wallet HTTP endpoints of the wallet and its extensions.
+func (wm *walletModules) registerHTTPHandlers(router rivineapi.Router, cs modules.ConsensusSet, tpool modules.TransactionPool, constants types.ChainConstants, requiredPassword string) {
+	rivineapi.RegisterWalletHTTPHandlers(router, wm.wallet, requiredPassword)
+	api.RegisterWalletHTTPHandlers(router, cs, tpool, wm.wallet, constants, requiredPassword)
+	api.RegisterWalletPayoutsHTTPHandlers(router, wm.payouts, requiredPassword)
+	api.RegisterWalletWatchHTTPHandlers(router, tpool, wm.watchDB, constants, requiredPassword)
+}
+
+// Close the wallet and all its extensions, in the reverse order they were created in.
+func (wm *walletModules) Close() error {
+	var errs []error
+	if wm.watchDB != nil {
+		fmt.Printf("Closing watch DB of %s wallet...\n", wm.name)
+		if err := wm.watchDB.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("watch DB shutdown: %v", err))
+		}
+	}
+	if wm.payouts != nil {
+		fmt.Printf("Closing payout manager of %s wallet...\n", wm.name)
+		if err := wm.payouts.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("payout manager shutdown: %v", err))
+		}
+	}
+	fmt.Printf("Closing %s wallet...\n", wm.name)
+	if err := wm.wallet.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("wallet shutdown: %v", err))
+	}
+	return build.ComposeErrors(errs...)
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/threefoldfoundation/tfchain/pkg/modules/atomicswapagent"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type (
+	// AtomicSwapAgentGetStatus contains the status of the atomic swap agent.
+	AtomicSwapAgentGetStatus struct {
+		atomicswapagent.Status
+	}
+	// AtomicSwapAgentGetContracts contains all atomic swap contracts of the wallet,
+	// watched by the atomic swap agent.
+	AtomicSwapAgentGetContracts struct {
+		Contracts []atomicswapagent.Contract `json:"contracts"`
+	}
+	// AtomicSwapAgentPostSecret is the body of a call to /atomicswapagent/secrets,
+	// registering a secret that can be used to redeem contracts of the wallet.
+	AtomicSwapAgentPostSecret struct {
+		Secret types.AtomicSwapSecret `json:"secret"`
+	}
+)
+
+// RegisterAtomicSwapAgentHTTPHandlers registers the handlers for all atomic swap agent HTTP endpoints.
+func RegisterAtomicSwapAgentHTTPHandlers(router api.Router, agent *atomicswapagent.Agent, requiredPassword string) {
+	if agent == nil {
+		panic("no atomic swap agent given")
+	}
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.GET("/atomicswapagent", NewAtomicSwapAgentGetStatusHandler(agent))
+	router.GET("/atomicswapagent/contracts", api.RequirePasswordHandler(NewAtomicSwapAgentGetContractsHandler(agent), requiredPassword))
+	router.POST("/atomicswapagent/secrets", api.RequirePasswordHandler(NewAtomicSwapAgentPostSecretHandler(agent), requiredPassword))
+}
+
+// NewAtomicSwapAgentGetStatusHandler creates a handler to handle the API calls to /atomicswapagent.
+func NewAtomicSwapAgentGetStatusHandler(agent *atomicswapagent.Agent) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		api.WriteJSON(w, AtomicSwapAgentGetStatus{
+			Status: agent.Status(),
+		})
+	}
+}
+
+// NewAtomicSwapAgentGetContractsHandler creates a handler to handle the API calls to /atomicswapagent/contracts.
+func NewAtomicSwapAgentGetContractsHandler(agent *atomicswapagent.Agent) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		contracts, err := agent.Contracts()
+		if err != nil {
+			if err == modules.ErrLockedWallet {
+				api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+				return
+			}
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		api.WriteJSON(w, AtomicSwapAgentGetContracts{
+			Contracts: contracts,
+		})
+	}
+}
+
+// NewAtomicSwapAgentPostSecretHandler creates a handler to handle the API calls to /atomicswapagent/secrets.
+func NewAtomicSwapAgentPostSecretHandler(agent *atomicswapagent.Agent) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		var body AtomicSwapAgentPostSecret
+		err := json.NewDecoder(req.Body).Decode(&body)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("error decoding the supplied secret: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		err = agent.RegisterSecret(body.Secret)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		api.WriteSuccess(w)
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/threefoldfoundation/tfchain/pkg/persist"
+
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type (
+	// AtomicSwapDBGetAtomicSwapContracts contains all atomic swap contracts
+	// which match the (optional) filters given as query parameters.
+	AtomicSwapDBGetAtomicSwapContracts struct {
+		Contracts []persist.AtomicSwapContract `json:"contracts"`
+	}
+	// AtomicSwapDBGetAtomicSwapContract contains a requested atomic swap contract.
+	AtomicSwapDBGetAtomicSwapContract struct {
+		persist.AtomicSwapContract
+	}
+)
+
+// RegisterAtomicSwapDBHTTPHandlers registers the handlers for all AtomicSwapDB HTTP endpoints.
+func RegisterAtomicSwapDBHTTPHandlers(router api.Router, asdb *persist.AtomicSwapDB) {
+	if asdb == nil {
+		panic("no atomic swap DB given")
+	}
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.GET("/explorer/atomicswaps", NewAtomicSwapDBGetAtomicSwapContractsHandler(asdb))
+	router.GET("/explorer/atomicswaps/:id", NewAtomicSwapDBGetAtomicSwapContractHandler(asdb))
+}
+
+// NewAtomicSwapDBGetAtomicSwapContractsHandler creates a handler to handle the API calls to /explorer/atomicswaps,
+// optionally filtered by the sender, receiver, hashedsecret and status query parameters.
+func NewAtomicSwapDBGetAtomicSwapContractsHandler(asdb *persist.AtomicSwapDB) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		var filter persist.AtomicSwapContractFilter
+		query := req.URL.Query()
+		if str := query.Get("sender"); str != "" {
+			filter.Sender = new(types.UnlockHash)
+			err := filter.Sender.LoadString(str)
+			if err != nil {
+				api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid sender unlock hash given: %v", err)}, http.StatusBadRequest)
+				return
+			}
+		}
+		if str := query.Get("receiver"); str != "" {
+			filter.Receiver = new(types.UnlockHash)
+			err := filter.Receiver.LoadString(str)
+			if err != nil {
+				api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid receiver unlock hash given: %v", err)}, http.StatusBadRequest)
+				return
+			}
+		}
+		if str := query.Get("hashedsecret"); str != "" {
+			filter.HashedSecret = new(types.AtomicSwapHashedSecret)
+			err := filter.HashedSecret.LoadString(str)
+			if err != nil {
+				api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid hashed secret given: %v", err)}, http.StatusBadRequest)
+				return
+			}
+		}
+		if str := query.Get("status"); str != "" {
+			status := persist.AtomicSwapContractStatus(str)
+			switch status {
+			case persist.AtomicSwapContractStatusOpen, persist.AtomicSwapContractStatusExpired,
+				persist.AtomicSwapContractStatusRedeemed, persist.AtomicSwapContractStatusRefunded:
+				filter.Status = &status
+			default:
+				api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid contract status given: %q", str)}, http.StatusBadRequest)
+				return
+			}
+		}
+
+		contracts, err := asdb.GetAtomicSwapContracts(filter)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		api.WriteJSON(w, AtomicSwapDBGetAtomicSwapContracts{
+			Contracts: contracts,
+		})
+	}
+}
+
+// NewAtomicSwapDBGetAtomicSwapContractHandler creates a handler to handle the API calls to /explorer/atomicswaps/:id.
+func NewAtomicSwapDBGetAtomicSwapContractHandler(asdb *persist.AtomicSwapDB) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		var id types.CoinOutputID
+		err := id.LoadString(ps.ByName("id"))
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid coin output ID given: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		contract, found, err := asdb.GetAtomicSwapContract(id)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("no atomic swap contract found for coin output %s", id.String())}, http.StatusNoContent)
+			return
+		}
+		api.WriteJSON(w, AtomicSwapDBGetAtomicSwapContract{
+			AtomicSwapContract: contract,
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/threefoldfoundation/tfchain/pkg/persist"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/api"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type (
+	// DaemonCheck contains the result of a single health or readiness check.
+	DaemonCheck struct {
+		Name    string `json:"name"`
+		OK      bool   `json:"ok"`
+		Message string `json:"message,omitempty"`
+	}
+
+	// DaemonGetHealth contains the result of all health checks,
+	// healthy only if all checks passed.
+	DaemonGetHealth struct {
+		Healthy bool          `json:"healthy"`
+		Checks  []DaemonCheck `json:"checks"`
+	}
+
+	// DaemonGetReady contains the result of all readiness checks,
+	// ready only if all checks passed.
+	DaemonGetReady struct {
+		Ready  bool          `json:"ready"`
+		Checks []DaemonCheck `json:"checks"`
+	}
+
+	// DaemonHealthModules contains the modules checked by the health and readiness endpoints,
+	// any module that isn't loaded can be nil.
+	DaemonHealthModules struct {
+		// Loaded contains the names of all loaded modules
+		Loaded        []string
+		Gateway       modules.Gateway
+		ConsensusSet  modules.ConsensusSet
+		Wallet        modules.Wallet
+		TransactionDB *persist.TransactionDB
+	}
+
+	// DaemonReadinessConfig configures the checks of the readiness endpoint.
+	DaemonReadinessConfig struct {
+		// MaxBlockAge is the maximum age of the current block, disabled if 0
+		MaxBlockAge time.Duration
+		// MinPeers is the minimum amount of connected peers
+		MinPeers int
+		// RequireUnlockedWallet requires the (default) wallet to be unlocked
+		RequireUnlockedWallet bool
+	}
+)
+
+// DaemonHealthModuleTimeout is the time each loaded module has to respond to the health check.
+const DaemonHealthModuleTimeout = 5 * time.Second
+
+// RegisterDaemonHealthHTTPHandlers registers the handlers for the /daemon/health and /daemon/ready HTTP endpoints.
+func RegisterDaemonHealthHTTPHandlers(router api.Router, dm DaemonHealthModules, cfg DaemonReadinessConfig) {
+	if dm.TransactionDB == nil {
+		panic("no transaction DB given")
+	}
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.GET("/daemon/health", NewDaemonGetHealthHandler(dm))
+	router.GET("/daemon/ready", NewDaemonGetReadyHandler(dm, cfg))
+}
+
+// NewDaemonGetHealthHandler creates a handler to handle the API calls to /daemon/health,
+// reporting the daemon as healthy if it is alive, all its loaded modules respond and its transaction db can be read.
+// Responds with status 200 if healthy, 503 otherwise.
+func NewDaemonGetHealthHandler(dm DaemonHealthModules) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		checks := []DaemonCheck{
+			checkModules(dm, DaemonHealthModuleTimeout),
+			checkTransactionDB(dm.TransactionDB),
+		}
+		resp := DaemonGetHealth{Healthy: allChecksOK(checks), Checks: checks}
+		writeCheckResults(w, resp, resp.Healthy)
+	}
+}
+
+// NewDaemonGetReadyHandler creates a handler to handle the API calls to /daemon/ready,
+// reporting the daemon as ready if its consensus set is synced, its current block isn't too old,
+// it is connected to enough peers, its transaction db is in sync with the consensus set
+// and its wallet is unlocked, should that be required.
+// Responds with status 200 if ready, 503 otherwise.
+func NewDaemonGetReadyHandler(dm DaemonHealthModules, cfg DaemonReadinessConfig) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		var checks []DaemonCheck
+		if dm.ConsensusSet == nil {
+			checks = append(checks, DaemonCheck{Name: "synced", Message: "consensus set module is not loaded"})
+		} else {
+			checks = append(checks, checkSynced(dm.ConsensusSet))
+			if cfg.MaxBlockAge > 0 {
+				checks = append(checks, checkBlockAge(dm.ConsensusSet, cfg.MaxBlockAge))
+			}
+			checks = append(checks, checkTransactionDBSynced(dm.TransactionDB, dm.ConsensusSet))
+		}
+		if cfg.MinPeers > 0 {
+			checks = append(checks, checkPeers(dm.Gateway, cfg.MinPeers))
+		}
+		if cfg.RequireUnlockedWallet {
+			checks = append(checks, checkWalletUnlocked(dm.Wallet))
+		}
+		resp := DaemonGetReady{Ready: allChecksOK(checks), Checks: checks}
+		writeCheckResults(w, resp, resp.Ready)
+	}
+}
+
+// checkModules checks that all loaded modules respond within the given timeout,
+// which a module that is stuck (e.g. while holding its lock) doesn't
+func checkModules(dm DaemonHealthModules, timeout time.Duration) DaemonCheck {
+	var probes []moduleProbe
+	if dm.Gateway != nil {
+		probes = append(probes, moduleProbe{name: "gateway", probe: func() { dm.Gateway.Address() }})
+	}
+	if dm.ConsensusSet != nil {
+		probes = append(probes, moduleProbe{name: "consensus set", probe: func() { dm.ConsensusSet.Height() }})
+	}
+	if dm.Wallet != nil {
+		probes = append(probes, moduleProbe{name: "wallet", probe: func() { dm.Wallet.Unlocked() }})
+	}
+	ch := make(chan int, len(probes))
+	for idx := range probes {
+		go func(idx int) {
+			probes[idx].probe()
+			ch <- idx
+		}(idx)
+	}
+	responded := make([]bool, len(probes))
+	deadline := time.After(timeout)
+	for n := 0; n < len(probes); n++ {
+		select {
+		case idx := <-ch:
+			responded[idx] = true
+		case <-deadline:
+			var stuck []string
+			for idx, probe := range probes {
+				if !responded[idx] {
+					stuck = append(stuck, probe.name)
+				}
+			}
+			return DaemonCheck{Name: "modules", Message: fmt.Sprintf(
+				"%s did not respond within %v", strings.Join(stuck, ", "), timeout)}
+		}
+	}
+	return DaemonCheck{Name: "modules", OK: true, Message: fmt.Sprintf("loaded: %v", dm.Loaded)}
+}
+
+// moduleProbe calls a method of a loaded module, which requires its lock
+type moduleProbe struct {
+	name  string
+	probe func()
+}
+
+func checkTransactionDB(txdb *persist.TransactionDB) DaemonCheck {
+	check := DaemonCheck{Name: "transactiondb"}
+	if _, err := txdb.Stats(); err != nil {
+		check.Message = err.Error()
+		return check
+	}
+	check.OK = true
+	return check
+}
+
+func checkSynced(cs modules.ConsensusSet) DaemonCheck {
+	check := DaemonCheck{Name: "synced", OK: cs.Synced()}
+	if !check.OK {
+		check.Message = "consensus set is not synced"
+	}
+	return check
+}
+
+func checkBlockAge(cs modules.ConsensusSet, maxAge time.Duration) DaemonCheck {
+	age := time.Since(time.Unix(int64(cs.CurrentBlock().Timestamp), 0))
+	if age < 0 {
+		age = 0 // block timestamps can be in the future, within limits
+	}
+	age -= age % time.Second
+	check := DaemonCheck{Name: "blockage", OK: age <= maxAge}
+	if check.OK {
+		check.Message = fmt.Sprintf("current block is %v old", age)
+	} else {
+		check.Message = fmt.Sprintf("current block is %v old, exceeding the maximum of %v", age, maxAge)
+	}
+	return check
+}
+
+func checkTransactionDBSynced(txdb *persist.TransactionDB, cs modules.ConsensusSet) DaemonCheck {
+	check := DaemonCheck{Name: "transactiondb"}
+	stats, err := txdb.Stats()
+	if err != nil {
+		check.Message = err.Error()
+		return check
+	}
+	// the transaction db counts the genesis block as well
+	height := cs.Height() + 1
+	check.OK = stats.BlockHeight == height
+	if check.OK {
+		check.Message = fmt.Sprintf("at height %d", cs.Height())
+	} else {
+		check.Message = fmt.Sprintf("processed %d blocks, while the consensus set has %d blocks", stats.BlockHeight, height)
+	}
+	return check
+}
+
+func checkPeers(g modules.Gateway, minPeers int) DaemonCheck {
+	check := DaemonCheck{Name: "peers"}
+	if g == nil {
+		check.Message = "gateway module is not loaded"
+		return check
+	}
+	peers := len(g.Peers())
+	check.OK = peers >= minPeers
+	check.Message = fmt.Sprintf("%d peer(s) connected, %d required", peers, minPeers)
+	return check
+}
+
+func checkWalletUnlocked(wallet modules.Wallet) DaemonCheck {
+	check := DaemonCheck{Name: "wallet"}
+	switch {
+	case wallet == nil:
+		check.Message = "wallet module is not loaded"
+	case !wallet.Unlocked():
+		check.Message = "wallet is locked"
+	default:
+		check.OK = true
+	}
+	return check
+}
+
+func allChecksOK(checks []DaemonCheck) bool {
+	for _, check := range checks {
+		if !check.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// writeCheckResults writes the given check results as JSON,
+// using status 200 if ok, and status 503 otherwise
+func writeCheckResults(w http.ResponseWriter, obj interface{}, ok bool) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(obj) // ignore error, as the status code is already written
+}
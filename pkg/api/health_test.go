@@ -0,0 +1,34 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+func TestCheckModules(t *testing.T) {
+	cs := &testStuckConsensusSet{release: make(chan struct{})}
+	defer close(cs.release)
+	dm := DaemonHealthModules{Loaded: []string{"consensus set"}, ConsensusSet: cs}
+
+	check := checkModules(dm, 10*time.Millisecond)
+	if check.OK || check.Message != "consensus set did not respond within 10ms" {
+		t.Errorf("expected the stuck consensus set to fail the check: %+v", check)
+	}
+	if check = checkModules(DaemonHealthModules{}, 10*time.Millisecond); !check.OK {
+		t.Errorf("expected the check to pass without any loaded modules: %+v", check)
+	}
+}
+
+// testStuckConsensusSet doesn't respond until released
+type testStuckConsensusSet struct {
+	modules.ConsensusSet
+	release chan struct{}
+}
+
+func (cs *testStuckConsensusSet) Height() types.BlockHeight {
+	<-cs.release
+	return 0
+}
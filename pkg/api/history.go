@@ -0,0 +1,505 @@
+package api
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	tftypes "github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// HistoryEntryType classifies a history entry.
+type HistoryEntryType string
+
+// All possible history entry types.
+const (
+	// HistoryEntryTypeTransfer is the type of a regular transfer of coins.
+	HistoryEntryTypeTransfer HistoryEntryType = "transfer"
+	// HistoryEntryTypeMint is the type of a coin creation (or minter definition) transaction.
+	HistoryEntryTypeMint HistoryEntryType = "mint"
+	// HistoryEntryTypeBlockReward is the type of the block creator fee, paid to the creator of a block.
+	HistoryEntryTypeBlockReward HistoryEntryType = "blockreward"
+	// HistoryEntryTypeFee is the type of the transaction fees collected by a block.
+	HistoryEntryTypeFee HistoryEntryType = "fee"
+	// HistoryEntryTypeAtomicSwap is the type of a transaction creating, redeeming or refunding an atomic swap contract.
+	HistoryEntryTypeAtomicSwap HistoryEntryType = "atomicswap"
+)
+
+const (
+	// DefaultHistoryLimit is the amount of history entries returned,
+	// in case no limit is given.
+	DefaultHistoryLimit = 100
+	// MaxHistoryLimit is the maximum amount of history entries returned at once.
+	MaxHistoryLimit = 1000
+)
+
+type (
+	// HistoryEntry is a single movement of coins, from the point of view of an address or wallet.
+	HistoryEntry struct {
+		Timestamp   types.Timestamp   `json:"timestamp"`
+		BlockHeight types.BlockHeight `json:"blockheight"`
+		// TransactionID is the ID of the block in case of a block reward or fee
+		TransactionID types.TransactionID      `json:"transactionid"`
+		Version       types.TransactionVersion `json:"version"`
+		Type          HistoryEntryType         `json:"type"`
+		// Counterparties are the receivers of outgoing coins,
+		// or the senders of incoming coins
+		Counterparties []types.UnlockHash `json:"counterparties"`
+		// In and Out are the net amount of coins received and sent, only one of both is non-zero.
+		// Out includes the fee paid.
+		In  types.Currency `json:"in"`
+		Out types.Currency `json:"out"`
+		Fee types.Currency `json:"fee"`
+		// Balance is the running balance, after this entry
+		Balance types.Currency `json:"balance"`
+	}
+
+	// HistoryGET contains a page of the confirmed history of an address or wallet,
+	// sorted from old to new.
+	HistoryGET struct {
+		Entries []HistoryEntry `json:"entries"`
+		// Next is the cursor used to fetch the next page,
+		// and is only defined if this page isn't the last one
+		Next string `json:"next,omitempty"`
+	}
+)
+
+// RegisterExplorerHistoryHTTPHandlers registers the handlers for the explorer history HTTP endpoints.
+func RegisterExplorerHistoryHTTPHandlers(router api.Router, explorer modules.Explorer, constants types.ChainConstants) {
+	if explorer == nil {
+		panic("no explorer module given")
+	}
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.GET("/explorer/history/:unlockhash", NewExplorerGetHistoryHandler(explorer, constants))
+}
+
+// NewExplorerGetHistoryHandler creates a handler to handle the API calls to /explorer/history/:unlockhash.
+func NewExplorerGetHistoryHandler(explorer modules.Explorer, constants types.ChainConstants) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		var uh types.UnlockHash
+		err := uh.LoadString(ps.ByName("unlockhash"))
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid unlock hash given: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		cursor, limit, ok := loadHistoryPage(w, req)
+		if !ok {
+			return
+		}
+		txns, err := explorerHistory(explorer, uh, cursor.startHeight())
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		writeHistoryPage(w, txns, constants, cursor, limit)
+	}
+}
+
+// NewWalletGetHistoryHandler creates a handler to handle the API calls to /wallet/history.
+func NewWalletGetHistoryHandler(cs modules.ConsensusSet, wallet modules.Wallet, constants types.ChainConstants) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		cursor, limit, ok := loadHistoryPage(w, req)
+		if !ok {
+			return
+		}
+		height := cs.Height()
+		if cursor.startHeight() > height {
+			api.WriteError(w, api.Error{Message: "invalid cursor given: block height is beyond the current block height"}, http.StatusBadRequest)
+			return
+		}
+		pts, err := wallet.Transactions(cursor.startHeight(), height)
+		if err != nil {
+			if err == modules.ErrLockedWallet {
+				api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+				return
+			}
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to get wallet transactions: %v", err)}, http.StatusInternalServerError)
+			return
+		}
+		writeHistoryPage(w, walletHistory(pts), constants, cursor, limit)
+	}
+}
+
+// historyTransaction is a confirmed transaction (or the miner payouts of a block),
+// reduced to the coin movements relevant for a history
+type historyTransaction struct {
+	// load is used to load the coin movements of the transaction, if defined,
+	// such that only the transactions of the requested page have to be loaded
+	load func(*historyTransaction) error
+
+	ID          types.TransactionID
+	BlockHeight types.BlockHeight
+	Timestamp   types.Timestamp
+	Version     types.TransactionVersion
+	// MinerPayouts is true in case the outputs are the miner payouts of a block
+	MinerPayouts bool
+	AtomicSwap   bool
+	Inputs       []historyFund
+	Outputs      []historyFund
+	MinerFees    []types.Currency
+}
+
+// historyFund is a single coin input or output of a history transaction
+type historyFund struct {
+	Address types.UnlockHash
+	Value   types.Currency
+	// Own defines if the fund belongs to the address or wallet the history is created for
+	Own bool
+}
+
+// historyCursor points to the last transaction of a history page,
+// such that the next page starts right after it.
+// The running balance is part of the cursor, as it would otherwise
+// require all previous transactions to be processed for each page.
+type historyCursor struct {
+	BlockHeight   types.BlockHeight
+	TransactionID types.TransactionID
+	Balance       types.Currency
+}
+
+// String returns the cursor as an opaque hex-encoded string
+func (cursor historyCursor) String() string {
+	return hex.EncodeToString(encoding.Marshal(cursor))
+}
+
+// LoadString loads the cursor from an opaque hex-encoded string
+func (cursor *historyCursor) LoadString(str string) error {
+	b, err := hex.DecodeString(str)
+	if err != nil {
+		return err
+	}
+	return encoding.Unmarshal(b, cursor)
+}
+
+// startHeight returns the block height the page of the cursor starts at,
+// which is 0 in case no cursor is defined
+func (cursor *historyCursor) startHeight() types.BlockHeight {
+	if cursor == nil {
+		return 0
+	}
+	return cursor.BlockHeight
+}
+
+// newHistoryPage creates the history entries of a single page, for the given (sorted) transactions,
+// which start at the block height of the given cursor. Transactions up to and including
+// the one of the cursor are skipped, and only whole transactions are added to the page.
+func newHistoryPage(txns []historyTransaction, constants types.ChainConstants, cursor *historyCursor, limit int) (HistoryGET, error) {
+	page := HistoryGET{Entries: []HistoryEntry{}}
+	var balance types.Currency
+	if cursor != nil {
+		balance = cursor.Balance
+		found := false
+		for idx := range txns {
+			if txns[idx].BlockHeight != cursor.BlockHeight {
+				break
+			}
+			if txns[idx].ID == cursor.TransactionID {
+				txns, found = txns[idx+1:], true
+				break
+			}
+		}
+		if !found {
+			return HistoryGET{}, errors.New("invalid cursor given: transaction is not part of the history")
+		}
+	}
+	for idx := range txns {
+		txn := &txns[idx]
+		if txn.load != nil {
+			err := txn.load(txn)
+			if err != nil {
+				return HistoryGET{}, err
+			}
+		}
+		txnBalance := balance
+		entries := newHistoryEntries(*txn, constants, &txnBalance)
+		if len(page.Entries) > 0 && len(page.Entries)+len(entries) > limit {
+			page.Next = historyCursor{
+				BlockHeight:   txns[idx-1].BlockHeight,
+				TransactionID: txns[idx-1].ID,
+				Balance:       balance,
+			}.String()
+			break
+		}
+		page.Entries = append(page.Entries, entries...)
+		balance = txnBalance
+	}
+	return page, nil
+}
+
+// writeHistoryPage writes the history page for the given (sorted) transactions
+func writeHistoryPage(w http.ResponseWriter, txns []historyTransaction, constants types.ChainConstants, cursor *historyCursor, limit int) {
+	page, err := newHistoryPage(txns, constants, cursor, limit)
+	if err != nil {
+		api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	api.WriteJSON(w, page)
+}
+
+// newHistoryEntries creates the history entries for the given transaction,
+// updating the running balance as it goes
+func newHistoryEntries(txn historyTransaction, constants types.ChainConstants, balance *types.Currency) []HistoryEntry {
+	var entries []HistoryEntry
+	appendEntry := func(entry HistoryEntry) {
+		*balance = balance.Add(entry.In).Sub(entry.Out)
+		entry.Balance = *balance
+		if entry.Counterparties == nil {
+			entry.Counterparties = []types.UnlockHash{}
+		}
+		entries = append(entries, entry)
+	}
+	entry := HistoryEntry{
+		Timestamp:     txn.Timestamp,
+		BlockHeight:   txn.BlockHeight,
+		TransactionID: txn.ID,
+		Version:       txn.Version,
+	}
+	if txn.MinerPayouts {
+		// the block creator fee (if any) is the first miner payout,
+		// while the collected transaction fees (if any) are paid by the last one
+		for idx, output := range txn.Outputs {
+			if !output.Own {
+				continue
+			}
+			payout := entry
+			payout.Type = HistoryEntryTypeFee
+			if idx == 0 && !constants.BlockCreatorFee.IsZero() {
+				payout.Type = HistoryEntryTypeBlockReward
+			}
+			payout.In = output.Value
+			appendEntry(payout)
+		}
+		return entries
+	}
+
+	var ownIn, ownOut types.Currency
+	for _, input := range txn.Inputs {
+		if input.Own {
+			ownIn = ownIn.Add(input.Value)
+		}
+	}
+	for _, output := range txn.Outputs {
+		if output.Own {
+			ownOut = ownOut.Add(output.Value)
+		}
+	}
+	if ownOut.Cmp(ownIn) >= 0 {
+		entry.In = ownOut.Sub(ownIn)
+	} else {
+		entry.Out = ownIn.Sub(ownOut)
+	}
+	if !ownIn.IsZero() {
+		for _, fee := range txn.MinerFees {
+			entry.Fee = entry.Fee.Add(fee)
+		}
+	}
+	if entry.In.IsZero() && entry.Out.IsZero() && entry.Fee.IsZero() {
+		return nil // no coins moved for this address or wallet
+	}
+
+	switch {
+	case txn.AtomicSwap:
+		entry.Type = HistoryEntryTypeAtomicSwap
+	case txn.Version == tftypes.TransactionVersionCoinCreation || txn.Version == tftypes.TransactionVersionMinterDefinition:
+		entry.Type = HistoryEntryTypeMint
+	default:
+		entry.Type = HistoryEntryTypeTransfer
+	}
+	counterparties := txn.Inputs
+	if !ownIn.IsZero() {
+		counterparties = txn.Outputs
+	}
+	seen := make(map[types.UnlockHash]struct{})
+	for _, fund := range counterparties {
+		if _, ok := seen[fund.Address]; ok || fund.Own {
+			continue
+		}
+		seen[fund.Address] = struct{}{}
+		entry.Counterparties = append(entry.Counterparties, fund.Address)
+	}
+	appendEntry(entry)
+	return entries
+}
+
+// walletHistory converts the confirmed transactions of the wallet to history transactions
+func walletHistory(pts []modules.ProcessedTransaction) []historyTransaction {
+	txns := make([]historyTransaction, 0, len(pts))
+	for _, pt := range pts {
+		txn := historyTransaction{
+			ID:          pt.TransactionID,
+			BlockHeight: pt.ConfirmationHeight,
+			Timestamp:   pt.ConfirmationTimestamp,
+			Version:     pt.Transaction.Version,
+			AtomicSwap:  tftypes.IsAtomicSwapTransaction(pt.Transaction),
+			MinerFees:   pt.Transaction.MinerFees,
+		}
+		for _, input := range pt.Inputs {
+			if input.FundType != types.SpecifierCoinInput {
+				continue
+			}
+			txn.Inputs = append(txn.Inputs, historyFund{
+				Address: input.RelatedAddress,
+				Value:   input.Value,
+				Own:     input.WalletAddress,
+			})
+		}
+		for _, output := range pt.Outputs {
+			switch output.FundType {
+			case types.SpecifierMinerPayout:
+				txn.MinerPayouts = true
+			case types.SpecifierCoinOutput:
+			default:
+				continue
+			}
+			txn.Outputs = append(txn.Outputs, historyFund{
+				Address: output.RelatedAddress,
+				Value:   output.Value,
+				Own:     output.WalletAddress,
+			})
+		}
+		txns = append(txns, txn)
+	}
+	return txns
+}
+
+// explorerHistory collects all confirmed transactions of the given unlock hash from the explorer,
+// starting at the given block height, sorted in the order they were applied to the chain.
+// Only their position is looked up, the transactions themselves are loaded once they are part of a page.
+func explorerHistory(explorer modules.Explorer, uh types.UnlockHash, startHeight types.BlockHeight) ([]historyTransaction, error) {
+	type sortable struct {
+		historyTransaction
+		index int
+	}
+	var txns []sortable
+	for _, id := range explorer.UnlockHash(uh) {
+		block, height, ok := explorer.Transaction(id)
+		if !ok {
+			return nil, fmt.Errorf("transaction %s of %s not found", id.String(), uh.String())
+		}
+		if height < startHeight {
+			continue
+		}
+		txn := sortable{
+			historyTransaction: historyTransaction{
+				ID:          id,
+				BlockHeight: height,
+				Timestamp:   block.Timestamp,
+				load: func(txn *historyTransaction) error {
+					return loadExplorerHistoryTransaction(explorer, uh, txn)
+				},
+			},
+			index: -1, // miner payouts come first
+		}
+		if types.TransactionID(block.ID()) != id {
+			found := false
+			for idx, t := range block.Transactions {
+				if t.ID() == id {
+					txn.index, found = idx, true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("transaction %s not found in block %s", id.String(), block.ID().String())
+			}
+		}
+		txns = append(txns, txn)
+	}
+	sort.SliceStable(txns, func(i, j int) bool {
+		if txns[i].BlockHeight != txns[j].BlockHeight {
+			return txns[i].BlockHeight < txns[j].BlockHeight
+		}
+		return txns[i].index < txns[j].index
+	})
+	result := make([]historyTransaction, 0, len(txns))
+	for _, txn := range txns {
+		result = append(result, txn.historyTransaction)
+	}
+	return result, nil
+}
+
+// loadExplorerHistoryTransaction loads the coin movements of a history transaction of the given unlock hash,
+// of which the ID, block height and timestamp are already known
+func loadExplorerHistoryTransaction(explorer modules.Explorer, uh types.UnlockHash, txn *historyTransaction) error {
+	block, _, ok := explorer.Transaction(txn.ID)
+	if !ok {
+		return fmt.Errorf("transaction %s of %s not found", txn.ID.String(), uh.String())
+	}
+	if types.TransactionID(block.ID()) == txn.ID {
+		txn.MinerPayouts = true
+		for _, mp := range block.MinerPayouts {
+			txn.Outputs = append(txn.Outputs, historyFund{
+				Address: mp.UnlockHash,
+				Value:   mp.Value,
+				Own:     mp.UnlockHash.Cmp(uh) == 0,
+			})
+		}
+		return nil
+	}
+	for _, t := range block.Transactions {
+		if t.ID() != txn.ID {
+			continue
+		}
+		txn.Version = t.Version
+		txn.AtomicSwap = tftypes.IsAtomicSwapTransaction(t)
+		txn.MinerFees = t.MinerFees
+		for _, ci := range t.CoinInputs {
+			co, ok := explorer.CoinOutput(ci.ParentID)
+			if !ok {
+				return fmt.Errorf("parent output %s of transaction %s not found", ci.ParentID.String(), txn.ID.String())
+			}
+			address := co.Condition.UnlockHash()
+			txn.Inputs = append(txn.Inputs, historyFund{
+				Address: address,
+				Value:   co.Value,
+				Own:     address.Cmp(uh) == 0,
+			})
+		}
+		for _, co := range t.CoinOutputs {
+			address := co.Condition.UnlockHash()
+			txn.Outputs = append(txn.Outputs, historyFund{
+				Address: address,
+				Value:   co.Value,
+				Own:     address.Cmp(uh) == 0,
+			})
+		}
+		return nil
+	}
+	return fmt.Errorf("transaction %s not found in block %s", txn.ID.String(), block.ID().String())
+}
+
+// loadHistoryPage loads the cursor and limit query parameters of a history request,
+// writing an error to the response writer and returning false if they are invalid.
+// The cursor is nil in case the first page is requested.
+func loadHistoryPage(w http.ResponseWriter, req *http.Request) (cursor *historyCursor, limit int, ok bool) {
+	limit = DefaultHistoryLimit
+	if str := req.FormValue("cursor"); str != "" {
+		cursor = new(historyCursor)
+		err := cursor.LoadString(str)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid cursor given: %v", err)}, http.StatusBadRequest)
+			return nil, 0, false
+		}
+	}
+	if str := req.FormValue("limit"); str != "" {
+		n, err := strconv.ParseUint(str, 10, 32)
+		if err != nil || n == 0 || n > MaxHistoryLimit {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid limit given: has to be in the range [1, %d]", MaxHistoryLimit)}, http.StatusBadRequest)
+			return nil, 0, false
+		}
+		limit = int(n)
+	}
+	return cursor, limit, true
+}
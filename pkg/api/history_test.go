@@ -0,0 +1,86 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rivine/rivine/types"
+)
+
+func TestHistoryPages(t *testing.T) {
+	var own, other types.UnlockHash
+	own.Type, own.Hash[0] = types.UnlockTypePubKey, 1
+	other.Type, other.Hash[0] = types.UnlockTypePubKey, 2
+	fund := func(uh types.UnlockHash, value uint64) historyFund {
+		return historyFund{Address: uh, Value: types.NewCurrency64(value), Own: uh == own}
+	}
+	constants := types.DefaultChainConstants()
+
+	// 2 transactions per block, of which the last one of each 3rd block doesn't move any coins of the owner
+	var txns []historyTransaction
+	for i := 0; i < 20; i++ {
+		txn := historyTransaction{BlockHeight: types.BlockHeight(i / 2)}
+		txn.ID[0] = byte(i)
+		switch {
+		case i%2 == 0:
+			txn.MinerPayouts = true
+			txn.Outputs = []historyFund{fund(own, 10), fund(own, 1)}
+		case i%6 == 5:
+			txn.Inputs = []historyFund{fund(other, 5)}
+			txn.Outputs = []historyFund{fund(other, 5)}
+		default:
+			txn.Inputs = []historyFund{fund(own, 10)}
+			txn.Outputs = []historyFund{fund(other, 3), fund(own, 6)}
+			txn.MinerFees = []types.Currency{types.NewCurrency64(1)}
+		}
+		txns = append(txns, txn)
+	}
+	all, err := newHistoryPage(txns, constants, nil, MaxHistoryLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.Next != "" || len(all.Entries) != 27 {
+		t.Fatalf("expected a single page of 27 entries, got %d entries (next: %q)", len(all.Entries), all.Next)
+	}
+
+	// fetching the history page by page, starting each page at the block height of the cursor,
+	// should result in the same entries and running balance
+	for _, limit := range []int{1, 2, 3, 5} {
+		var (
+			entries []HistoryEntry
+			cursor  *historyCursor
+		)
+		for {
+			var start int
+			for start < len(txns) && txns[start].BlockHeight < cursor.startHeight() {
+				start++
+			}
+			page, err := newHistoryPage(txns[start:], constants, cursor, limit)
+			if err != nil {
+				t.Fatalf("limit %d: %v", limit, err)
+			}
+			// a page only exceeds the limit if it contains the entries of a single transaction
+			if len(page.Entries) > limit && len(page.Entries) > 2 {
+				t.Errorf("limit %d: got a page of %d entries", limit, len(page.Entries))
+			}
+			entries = append(entries, page.Entries...)
+			if page.Next == "" {
+				break
+			}
+			cursor = new(historyCursor)
+			err = cursor.LoadString(page.Next)
+			if err != nil {
+				t.Fatalf("limit %d: %v", limit, err)
+			}
+		}
+		if !reflect.DeepEqual(entries, all.Entries) {
+			t.Errorf("limit %d: paged entries differ from the entries of a single page", limit)
+		}
+	}
+
+	// a cursor of a transaction that isn't part of the history is invalid
+	cursor := &historyCursor{BlockHeight: 1}
+	if _, err = newHistoryPage(txns[2:], constants, cursor, 1); err == nil {
+		t.Error("expected a cursor of an unknown transaction to be invalid")
+	}
+}
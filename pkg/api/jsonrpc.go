@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/threefoldfoundation/tfchain/pkg/jsonrpc"
+
+	"github.com/rivine/rivine/pkg/api"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// RegisterJSONRPCHTTPHandlers registers the handlers for the JSON-RPC 2.0 interface,
+// served over HTTP using POST /rpc and over a websocket using GET /rpc/ws.
+func RegisterJSONRPCHTTPHandlers(router api.Router, srv *jsonrpc.Server) {
+	if srv == nil {
+		panic("no JSON-RPC server given")
+	}
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.POST("/rpc", func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		srv.ServeHTTP(w, req)
+	})
+	router.GET("/rpc/ws", func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		srv.ServeWebsocket(w, req)
+	})
+}
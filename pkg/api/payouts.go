@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/threefoldfoundation/tfchain/pkg/modules/payouts"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/api"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type (
+	// WalletPostPayouts is the body of a call to /wallet/payouts,
+	// paying a batch of payouts from the wallet.
+	WalletPostPayouts struct {
+		// ID of the batch, a random ID is generated if none is given.
+		// Sending a batch using the ID of an earlier batch resumes that batch,
+		// in which case the payouts and description have to be the same.
+		ID          string           `json:"id,omitempty"`
+		Description string           `json:"description,omitempty"`
+		Payouts     []payouts.Payout `json:"payouts"`
+		// DryRun returns the planned transactions,
+		// without signing, broadcasting or storing anything
+		DryRun bool `json:"dryrun,omitempty"`
+	}
+	// WalletGetPayoutBatch contains a single payout batch.
+	WalletGetPayoutBatch struct {
+		payouts.Batch
+	}
+	// WalletGetPayoutBatches contains all payout batches.
+	WalletGetPayoutBatches struct {
+		Batches []payouts.Batch `json:"batches"`
+	}
+)
+
+// RegisterWalletPayoutsHTTPHandlers registers the handlers for all wallet payout HTTP endpoints.
+// All endpoints require the API password, if one is configured.
+func RegisterWalletPayoutsHTTPHandlers(router api.Router, manager *payouts.Manager, requiredPassword string) {
+	if manager == nil {
+		panic("no payout manager given")
+	}
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.POST("/wallet/payouts", api.RequirePasswordHandler(NewWalletPostPayoutsHandler(manager), requiredPassword))
+	router.GET("/wallet/payouts", api.RequirePasswordHandler(NewWalletGetPayoutBatchesHandler(manager), requiredPassword))
+	router.GET("/wallet/payouts/:id", api.RequirePasswordHandler(NewWalletGetPayoutBatchHandler(manager), requiredPassword))
+}
+
+// NewWalletPostPayoutsHandler creates a handler to handle the API calls to /wallet/payouts.
+func NewWalletPostPayoutsHandler(manager *payouts.Manager) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		var body WalletPostPayouts
+		err := json.NewDecoder(req.Body).Decode(&body)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("error decoding the supplied payouts: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		batch, err := manager.Send(body.ID, body.Payouts, body.Description, body.DryRun)
+		if err != nil {
+			if err == payouts.ErrBatchMismatch {
+				api.WriteError(w, api.Error{Message: err.Error()}, http.StatusConflict)
+				return
+			}
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		api.WriteJSON(w, WalletGetPayoutBatch{
+			Batch: batch,
+		})
+	}
+}
+
+// NewWalletGetPayoutBatchesHandler creates a handler to handle the API calls to /wallet/payouts.
+func NewWalletGetPayoutBatchesHandler(manager *payouts.Manager) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		batches, err := manager.Batches()
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		api.WriteJSON(w, WalletGetPayoutBatches{
+			Batches: batches,
+		})
+	}
+}
+
+// NewWalletGetPayoutBatchHandler creates a handler to handle the API calls to /wallet/payouts/:id.
+func NewWalletGetPayoutBatchHandler(manager *payouts.Manager) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		batch, err := manager.Batch(ps.ByName("id"))
+		if err != nil {
+			if err == payouts.ErrBatchNotFound {
+				api.WriteError(w, api.Error{Message: err.Error()}, http.StatusNoContent)
+				return
+			}
+			if err == modules.ErrLockedWallet {
+				api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+				return
+			}
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		api.WriteJSON(w, WalletGetPayoutBatch{
+			Batch: batch,
+		})
+	}
+}
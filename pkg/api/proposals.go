@@ -0,0 +1,178 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/threefoldfoundation/tfchain/pkg/modules/proposals"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type (
+	// ProposalsPostProposal is the body of a call to /proposals,
+	// submitting an unsigned (or partially signed) transaction as a new proposal.
+	ProposalsPostProposal struct {
+		Transaction types.Transaction `json:"transaction"`
+		Description string            `json:"description,omitempty"`
+		// Lifetime of the proposal in seconds,
+		// the default lifetime is used if not defined
+		Lifetime uint64 `json:"lifetime,omitempty"`
+	}
+	// ProposalsGetProposal contains a single proposal.
+	ProposalsGetProposal struct {
+		proposals.Proposal
+	}
+	// ProposalsGetProposals contains all proposals matching the given query.
+	ProposalsGetProposals struct {
+		Proposals []proposals.Proposal `json:"proposals"`
+	}
+	// ProposalsPostFulfillments is the body of a call to /proposals/:id/fulfillments,
+	// containing the proposed transaction signed by one or multiple co-signers.
+	ProposalsPostFulfillments struct {
+		Transaction types.Transaction `json:"transaction"`
+	}
+)
+
+// RegisterProposalPoolHTTPHandlers registers the handlers for all proposal pool HTTP endpoints.
+// All endpoints require the API password, if one is configured.
+func RegisterProposalPoolHTTPHandlers(router api.Router, pool *proposals.Pool, requiredPassword string) {
+	if pool == nil {
+		panic("no proposal pool given")
+	}
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.POST("/proposals", api.RequirePasswordHandler(NewProposalsPostProposalHandler(pool), requiredPassword))
+	router.GET("/proposals", api.RequirePasswordHandler(NewProposalsGetProposalsHandler(pool), requiredPassword))
+	router.GET("/proposals/:id", api.RequirePasswordHandler(NewProposalsGetProposalHandler(pool), requiredPassword))
+	router.POST("/proposals/:id/fulfillments", api.RequirePasswordHandler(NewProposalsPostFulfillmentsHandler(pool), requiredPassword))
+}
+
+// NewProposalsPostProposalHandler creates a handler to handle the API calls to /proposals.
+func NewProposalsPostProposalHandler(pool *proposals.Pool) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		var body ProposalsPostProposal
+		err := json.NewDecoder(req.Body).Decode(&body)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("error decoding the supplied proposal: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		if body.Lifetime > uint64(proposals.MaxLifetime/time.Second) {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("lifetime cannot exceed %v", proposals.MaxLifetime)}, http.StatusBadRequest)
+			return
+		}
+		proposal, err := pool.Submit(body.Transaction, body.Description, time.Duration(body.Lifetime)*time.Second)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		api.WriteJSON(w, ProposalsGetProposal{
+			Proposal: proposal,
+		})
+	}
+}
+
+// NewProposalsGetProposalsHandler creates a handler to handle the API calls to /proposals.
+func NewProposalsGetProposalsHandler(pool *proposals.Pool) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		var filter proposals.Filter
+		if str := req.FormValue("unlockhash"); str != "" {
+			filter.UnlockHash = new(types.UnlockHash)
+			err := filter.UnlockHash.LoadString(str)
+			if err != nil {
+				api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid unlock hash given: %v", err)}, http.StatusBadRequest)
+				return
+			}
+		}
+		if str := req.FormValue("status"); str != "" {
+			status := proposals.Status(str)
+			switch status {
+			case proposals.StatusPending, proposals.StatusBroadcasted, proposals.StatusExpired:
+				filter.Status = &status
+			default:
+				api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid proposal status %q given", str)}, http.StatusBadRequest)
+				return
+			}
+		}
+		result, err := pool.Proposals(filter)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		api.WriteJSON(w, ProposalsGetProposals{
+			Proposals: result,
+		})
+	}
+}
+
+// NewProposalsGetProposalHandler creates a handler to handle the API calls to /proposals/:id.
+func NewProposalsGetProposalHandler(pool *proposals.Pool) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		id, ok := loadProposalID(w, ps.ByName("id"))
+		if !ok {
+			return
+		}
+		proposal, err := pool.Proposal(id)
+		if err != nil {
+			writeProposalError(w, err)
+			return
+		}
+		api.WriteJSON(w, ProposalsGetProposal{
+			Proposal: proposal,
+		})
+	}
+}
+
+// NewProposalsPostFulfillmentsHandler creates a handler to handle the API calls to /proposals/:id/fulfillments.
+func NewProposalsPostFulfillmentsHandler(pool *proposals.Pool) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		id, ok := loadProposalID(w, ps.ByName("id"))
+		if !ok {
+			return
+		}
+		var body ProposalsPostFulfillments
+		err := json.NewDecoder(req.Body).Decode(&body)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("error decoding the supplied transaction: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		proposal, err := pool.Sign(id, body.Transaction)
+		if err != nil {
+			writeProposalError(w, err)
+			return
+		}
+		api.WriteJSON(w, ProposalsGetProposal{
+			Proposal: proposal,
+		})
+	}
+}
+
+// loadProposalID loads the given proposal ID,
+// writing an error to the response writer and returning false if it is invalid
+func loadProposalID(w http.ResponseWriter, str string) (crypto.Hash, bool) {
+	var id crypto.Hash
+	err := id.LoadString(str)
+	if err != nil {
+		api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid proposal ID given: %v", err)}, http.StatusBadRequest)
+		return crypto.Hash{}, false
+	}
+	return id, true
+}
+
+// writeProposalError writes the given proposal pool error to the response writer,
+// using the status code matching the error
+func writeProposalError(w http.ResponseWriter, err error) {
+	if err == proposals.ErrProposalNotFound {
+		api.WriteError(w, api.Error{Message: err.Error()}, http.StatusNoContent)
+		return
+	}
+	api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/threefoldfoundation/tfchain/pkg/modules/stream"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// EventStreamKeepAliveInterval defines how often a comment is sent on an idle event stream,
+// such that proxies and clients don't close the connection.
+const EventStreamKeepAliveInterval = 30 * time.Second
+
+// EventStreamErrorEvent is the type of the server-sent event sent prior to closing an event stream,
+// because the subscription was closed by the stream, containing an api.Error.
+const EventStreamErrorEvent = "error"
+
+// RegisterStreamHTTPHandlers registers the handlers for the event stream HTTP endpoint.
+func RegisterStreamHTTPHandlers(router api.Router, s *stream.Stream) {
+	if s == nil {
+		panic("no stream given")
+	}
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.GET("/events", NewStreamGetEventsHandler(s))
+}
+
+// NewStreamGetEventsHandler creates a handler to handle the API calls to /events,
+// streaming the chain activity as server-sent events, the type of each event is its event type
+// and its data is the JSON-encoded stream.Event. Only consensus change events define an ID,
+// which can be used to resume the stream, using the Last-Event-ID header or the since query parameter.
+//
+// Supported query parameters (all optional):
+//   - since: the ID of the last consensus change received, or "beginning" to receive all consensus changes,
+//     only new consensus changes are received if not given;
+//   - types: comma-separated list of event types to receive;
+//   - unlockhashes: comma-separated list of unlock hashes to receive transaction events for;
+//   - versions: comma-separated list of transaction versions to receive transaction events for;
+func NewStreamGetEventsHandler(s *stream.Stream) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			api.WriteError(w, api.Error{Message: "streaming is not supported"}, http.StatusInternalServerError)
+			return
+		}
+		start, filter, err := loadEventStreamRequest(req)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		sub, err := s.Subscribe(start, filter)
+		if err == modules.ErrInvalidConsensusChangeID {
+			api.WriteError(w, api.Error{Message: "unknown consensus change ID to resume from"}, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(EventStreamKeepAliveInterval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-req.Context().Done():
+				return
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case event, ok := <-sub.Events():
+				if !ok {
+					if err := sub.Err(); err != nil {
+						writeServerSentEvent(w, "", EventStreamErrorEvent, api.Error{Message: err.Error()})
+						flusher.Flush()
+					}
+					return
+				}
+				var id string
+				if event.ConsensusChange != nil {
+					id = event.ConsensusChange.ID.String()
+				}
+				writeServerSentEvent(w, id, string(event.Type), event)
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// loadEventStreamRequest loads the consensus change to start from and the filter of an event stream request
+func loadEventStreamRequest(req *http.Request) (start modules.ConsensusChangeID, filter stream.Filter, err error) {
+	start = modules.ConsensusChangeRecent
+	since := req.Header.Get("Last-Event-ID")
+	if since == "" {
+		since = req.FormValue("since")
+	}
+	switch since {
+	case "":
+	case "beginning":
+		start = modules.ConsensusChangeBeginning
+	default:
+		var id crypto.Hash
+		if err = id.LoadString(since); err != nil {
+			return start, filter, fmt.Errorf("invalid consensus change ID to resume from: %v", err)
+		}
+		start = modules.ConsensusChangeID(id)
+	}
+
+	for _, s := range splitQueryList(req.FormValue("types")) {
+		t := stream.EventType(s)
+		if !t.IsValid() {
+			return start, filter, fmt.Errorf("invalid event type %q", s)
+		}
+		filter.Types = append(filter.Types, t)
+	}
+	for _, s := range splitQueryList(req.FormValue("unlockhashes")) {
+		var uh types.UnlockHash
+		if err = uh.LoadString(s); err != nil {
+			return start, filter, fmt.Errorf("invalid unlock hash %q: %v", s, err)
+		}
+		filter.UnlockHashes = append(filter.UnlockHashes, uh)
+	}
+	for _, s := range splitQueryList(req.FormValue("versions")) {
+		version, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return start, filter, fmt.Errorf("invalid transaction version %q: %v", s, err)
+		}
+		filter.Versions = append(filter.Versions, types.TransactionVersion(version))
+	}
+	return start, filter, nil
+}
+
+// splitQueryList splits a comma-separated query parameter value, ignoring empty elements
+func splitQueryList(value string) []string {
+	var elements []string
+	for _, element := range strings.Split(value, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+// writeServerSentEvent writes a single server-sent event,
+// the ID is omitted if empty, while the data is JSON-encoded on a single line
+func writeServerSentEvent(w http.ResponseWriter, id, event string, data interface{}) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		b, _ = json.Marshal(api.Error{Message: fmt.Sprintf("failed to encode event: %v", err)})
+		event = EventStreamErrorEvent
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+}
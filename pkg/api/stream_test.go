@@ -0,0 +1,72 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/threefoldfoundation/tfchain/pkg/modules/stream/streamtest"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestStreamGetEventsResume(t *testing.T) {
+	const n = streamtest.PastChanges
+	s, err := streamtest.NewStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	router := httprouter.New()
+	RegisterStreamHTTPHandlers(router, s)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ids := receiveStreamEventIDs(t, server.URL+"/events?since=beginning&types=consensus.change", "", n)
+	resumed := receiveStreamEventIDs(t, server.URL+"/events?types=consensus.change", ids[999], n-1000)
+	for idx, id := range resumed {
+		if id != ids[1000+idx] {
+			t.Fatalf("resumed event #%d: expected ID %s, not %s", idx, ids[1000+idx], id)
+		}
+	}
+}
+
+// receiveStreamEventIDs receives the IDs of the given amount of server-sent events,
+// resuming from the given last event ID if not empty
+func receiveStreamEventIDs(t *testing.T, url, lastEventID string, n int) []string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %s", resp.Status)
+	}
+
+	ids := make([]string, 0, n)
+	scanner := bufio.NewScanner(resp.Body)
+	for len(ids) < n && scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			ids = append(ids, strings.TrimPrefix(line, "id: "))
+		case line == "event: "+EventStreamErrorEvent:
+			scanner.Scan()
+			t.Fatalf("stream closed after %d events: %s", len(ids), scanner.Text())
+		}
+	}
+	if len(ids) < n {
+		t.Fatalf("received only %d events: %v", len(ids), scanner.Err())
+	}
+	return ids
+}
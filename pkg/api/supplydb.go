@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/threefoldfoundation/tfchain/pkg/persist"
+
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type (
+	// SupplyDBGetSupplyBreakdown contains a requested supply breakdown,
+	// either the one of the current block height or the one of the given block height.
+	SupplyDBGetSupplyBreakdown struct {
+		persist.SupplyBreakdown
+	}
+)
+
+// RegisterSupplyDBHTTPHandlers registers the handlers for all SupplyDB HTTP endpoints.
+func RegisterSupplyDBHTTPHandlers(router api.Router, sdb *persist.SupplyDB) {
+	if sdb == nil {
+		panic("no supply DB given")
+	}
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.GET("/explorer/supply/breakdown", NewSupplyDBGetSupplyBreakdownHandler(sdb))
+	router.GET("/explorer/supply/breakdown/:height", NewSupplyDBGetSupplyBreakdownAtHandler(sdb))
+}
+
+// NewSupplyDBGetSupplyBreakdownHandler creates a handler to handle the API calls to /explorer/supply/breakdown.
+func NewSupplyDBGetSupplyBreakdownHandler(sdb *persist.SupplyDB) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		breakdown, err := sdb.GetSupplyBreakdown()
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		api.WriteJSON(w, SupplyDBGetSupplyBreakdown{
+			SupplyBreakdown: breakdown,
+		})
+	}
+}
+
+// NewSupplyDBGetSupplyBreakdownAtHandler creates a handler to handle the API calls to /explorer/supply/breakdown/:height.
+func NewSupplyDBGetSupplyBreakdownAtHandler(sdb *persist.SupplyDB) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		heightStr := ps.ByName("height")
+		height, err := strconv.ParseUint(heightStr, 10, 64)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid block height given: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		breakdown, err := sdb.GetSupplyBreakdownAt(types.BlockHeight(height))
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		api.WriteJSON(w, SupplyDBGetSupplyBreakdown{
+			SupplyBreakdown: breakdown,
+		})
+	}
+}
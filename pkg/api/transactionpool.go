@@ -0,0 +1,474 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/threefoldfoundation/tfchain/pkg/fees"
+	"github.com/threefoldfoundation/tfchain/pkg/persist"
+	tftypes "github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// TransactionValidationOutputSource defines where the output spent by an input was found.
+type TransactionValidationOutputSource string
+
+// All possible sources of an output spent by an input.
+const (
+	// TransactionValidationOutputSourceConfirmed is the source of an unspent output created by a confirmed transaction
+	TransactionValidationOutputSourceConfirmed TransactionValidationOutputSource = "confirmed"
+	// TransactionValidationOutputSourceUnconfirmed is the source of an output created by an unconfirmed transaction
+	TransactionValidationOutputSourceUnconfirmed TransactionValidationOutputSource = "unconfirmed"
+	// TransactionValidationOutputSourceUnknown is the source of an output which isn't known as an unspent output,
+	// meaning it is either already spent or it doesn't exist
+	TransactionValidationOutputSourceUnknown TransactionValidationOutputSource = "unknown"
+)
+
+type (
+	// TransactionPoolPostValidateResponse is the report returned by a call to /transactionpool/validate,
+	// reporting the result of each validation check of the given transaction.
+	// The transaction is valid, and would thus be accepted by the transaction pool,
+	// only if all checks are valid.
+	TransactionPoolPostValidateResponse struct {
+		// TransactionID is only defined for transactions which don't have any nil fulfillments
+		TransactionID types.TransactionID      `json:"transactionid"`
+		Version       types.TransactionVersion `json:"version"`
+		Valid         bool                     `json:"valid"`
+
+		// BlockHeight and BlockTime of the current block,
+		// the context the transaction was validated in
+		BlockHeight types.BlockHeight `json:"blockheight"`
+		BlockTime   types.Timestamp   `json:"blocktime"`
+
+		// Standalone reports the validation of the transaction on its own,
+		// as defined by its version, which includes the validation of its mint fulfillment, if any
+		Standalone TransactionValidationCheck `json:"standalone"`
+		// MintFulfillment reports whether the mint fulfillment fulfills the mint condition of the current block height,
+		// only defined for coin creation and minter definition transactions
+		MintFulfillment *TransactionValidationCheck `json:"mintfulfillment,omitempty"`
+		// CoinInputs and BlockStakeInputs report, for each input,
+		// whether it spends a known output, and whether it fulfills the condition of that output
+		CoinInputs       []TransactionValidationInput `json:"coininputs"`
+		BlockStakeInputs []TransactionValidationInput `json:"blockstakeinputs"`
+		// Coins and BlockStakes report whether the outputs are funded by the inputs,
+		// as validated by the consensus set, which by default requires all inputs to be fulfilled as well
+		Coins       TransactionValidationFunds `json:"coins"`
+		BlockStakes TransactionValidationFunds `json:"blockstakes"`
+		// TransactionPool reports whether the transaction conflicts with the unconfirmed transactions,
+		// either because it is already part of the pool, or because it spends an output already spent by the pool
+		TransactionPool TransactionValidationCheck `json:"transactionpool"`
+	}
+
+	// TransactionValidationCheck is the result of a single validation check.
+	TransactionValidationCheck struct {
+		Valid bool   `json:"valid"`
+		Error string `json:"error,omitempty"`
+	}
+	// TransactionValidationInput is the result of the validation of a single (coin or block stake) input.
+	TransactionValidationInput struct {
+		TransactionValidationCheck
+		ParentID crypto.Hash                       `json:"parentid"`
+		Source   TransactionValidationOutputSource `json:"source"`
+		// Value and Condition of the spent output, only defined if the output is known
+		Value     types.Currency             `json:"value"`
+		Condition types.UnlockConditionProxy `json:"condition"`
+	}
+	// TransactionValidationFunds is the result of the validation of the funds of a transaction,
+	// for either coins or block stakes.
+	TransactionValidationFunds struct {
+		TransactionValidationCheck
+		// InputSum is the sum of all known outputs spent by the inputs,
+		// OutputSum the sum of all outputs (and miner fees in case of coins)
+		InputSum  types.Currency `json:"inputsum"`
+		OutputSum types.Currency `json:"outputsum"`
+	}
+)
+
+// RegisterTransactionPoolHTTPHandlers registers the handlers for the tfchain-specific TransactionPool HTTP endpoints.
+func RegisterTransactionPoolHTTPHandlers(router api.Router, cs modules.ConsensusSet, tpool modules.TransactionPool, txdb *persist.TransactionDB, constants types.ChainConstants) {
+	if cs == nil {
+		panic("no consensus set module given")
+	}
+	if tpool == nil {
+		panic("no transaction pool module given")
+	}
+	if txdb == nil {
+		panic("no transaction DB given")
+	}
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.POST("/transactionpool/validate", NewTransactionPoolPostValidateHandler(cs, tpool, txdb, constants))
+}
+
+// NewTransactionPoolPostValidateHandler creates a handler to handle the API calls to /transactionpool/validate.
+func NewTransactionPoolPostValidateHandler(cs modules.ConsensusSet, tpool modules.TransactionPool, txdb *persist.TransactionDB, constants types.ChainConstants) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		var txn types.Transaction
+		err := json.NewDecoder(req.Body).Decode(&txn)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("error decoding the supplied transaction: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		api.WriteJSON(w, validateTransaction(txn, cs, tpool, txdb, constants))
+	}
+}
+
+// validateTransaction validates the given transaction within the context of the current block,
+// in the same way the transaction pool would, without adding it to the pool.
+func validateTransaction(txn types.Transaction, cs modules.ConsensusSet, tpool modules.TransactionPool, txdb *persist.TransactionDB, constants types.ChainConstants) TransactionPoolPostValidateResponse {
+	block := cs.CurrentBlock()
+	report := TransactionPoolPostValidateResponse{
+		Version:     txn.Version,
+		BlockHeight: cs.Height(),
+		BlockTime:   block.Timestamp,
+	}
+	// the ID and size of a transaction which isn't fully signed cannot be computed,
+	// the inputs and funds are still validated, such that it can be validated prior to signing it
+	unsigned := tftypes.TransactionHasNilFulfillment(txn)
+	if !unsigned {
+		report.TransactionID = txn.ID()
+	}
+
+	// validate the transaction on its own, in the same context as the transaction pool does
+	report.Standalone = newTransactionValidationCheck(func() error {
+		if unsigned {
+			return types.ErrNilFulfillmentType
+		}
+		if size := len(encoding.Marshal(txn)); size > constants.TransactionPool.TransactionSizeLimit {
+			return modules.ErrLargeTransaction
+		}
+		return txn.ValidateTransaction(types.ValidationContext{
+			Confirmed:   false,
+			BlockHeight: report.BlockHeight,
+			BlockTime:   report.BlockTime,
+		}, types.TransactionValidationConstants{
+			BlockSizeLimit:         constants.BlockSizeLimit,
+			ArbitraryDataSizeLimit: constants.ArbitraryDataSizeLimit,
+			MinimumMinerFee:        constants.MinimumTransactionFee,
+		})
+	}())
+
+	// validate the mint fulfillment separately, as it is the only fulfillment
+	// of coin creation and minter definition transactions
+	var mintFulfillment *types.UnlockFulfillmentProxy
+	switch txn.Version {
+	case tftypes.TransactionVersionCoinCreation:
+		cctx, err := tftypes.CoinCreationTransactionFromTransaction(txn)
+		if err == nil {
+			mintFulfillment = &cctx.MintFulfillment
+		}
+	case tftypes.TransactionVersionMinterDefinition:
+		mdtx, err := tftypes.MinterDefinitionTransactionFromTransaction(txn)
+		if err == nil {
+			mintFulfillment = &mdtx.MintFulfillment
+		}
+	}
+	if mintFulfillment != nil {
+		check := newTransactionValidationCheck(func() error {
+			mintCondition, err := txdb.GetMintConditionAt(report.BlockHeight)
+			if err != nil {
+				return fmt.Errorf("failed to get mint condition at block height %d: %v", report.BlockHeight, err)
+			}
+			return mintCondition.Fulfill(*mintFulfillment, types.FulfillContext{
+				InputIndex:  0, // InputIndex is ignored for the mint fulfillment
+				BlockHeight: report.BlockHeight,
+				BlockTime:   report.BlockTime,
+				Transaction: txn,
+			})
+		}())
+		report.MintFulfillment = &check
+	}
+
+	// collect the outputs created and spent by the unconfirmed transactions,
+	// such that transactions which spend unconfirmed outputs can be validated as well
+	var (
+		unconfirmedCoinOutputs       = make(map[types.CoinOutputID]types.CoinOutput)
+		unconfirmedBlockStakeOutputs = make(map[types.BlockStakeOutputID]types.BlockStakeOutput)
+		unconfirmedSpenders          = make(map[crypto.Hash]types.TransactionID)
+		inPool                       bool
+	)
+	for _, utxn := range tpool.TransactionList() {
+		utxnID := utxn.ID()
+		if utxnID == report.TransactionID {
+			inPool = true
+			continue
+		}
+		for i, co := range utxn.CoinOutputs {
+			unconfirmedCoinOutputs[utxn.CoinOutputID(uint64(i))] = co
+		}
+		for i, bso := range utxn.BlockStakeOutputs {
+			unconfirmedBlockStakeOutputs[utxn.BlockStakeOutputID(uint64(i))] = bso
+		}
+		for _, ci := range utxn.CoinInputs {
+			unconfirmedSpenders[crypto.Hash(ci.ParentID)] = utxnID
+		}
+		for _, bsi := range utxn.BlockStakeInputs {
+			unconfirmedSpenders[crypto.Hash(bsi.ParentID)] = utxnID
+		}
+	}
+
+	// validate each input, and the funds of the transaction
+	fundCtx := types.FundValidationContext{
+		BlockHeight: report.BlockHeight,
+		BlockTime:   report.BlockTime,
+	}
+	coinInputs := make(map[types.CoinOutputID]types.CoinOutput, len(txn.CoinInputs))
+	report.CoinInputs = make([]TransactionValidationInput, 0, len(txn.CoinInputs))
+	for index, ci := range txn.CoinInputs {
+		input := TransactionValidationInput{
+			ParentID: crypto.Hash(ci.ParentID),
+			Source:   TransactionValidationOutputSourceConfirmed,
+		}
+		co, err := cs.GetCoinOutput(ci.ParentID)
+		if err != nil {
+			var ok bool
+			co, ok = unconfirmedCoinOutputs[ci.ParentID]
+			if ok {
+				input.Source = TransactionValidationOutputSourceUnconfirmed
+			} else {
+				input.Source = TransactionValidationOutputSourceUnknown
+			}
+		}
+		if input.Source != TransactionValidationOutputSourceUnknown {
+			coinInputs[ci.ParentID] = co
+			input.Value, input.Condition = co.Value, co.Condition
+			report.Coins.InputSum = report.Coins.InputSum.Add(co.Value)
+		}
+		input.TransactionValidationCheck = validateInput(input, ci.Fulfillment, uint64(index), txn, fundCtx)
+		report.CoinInputs = append(report.CoinInputs, input)
+	}
+	report.Coins.OutputSum = txn.CoinOutputSum()
+	report.Coins.TransactionValidationCheck = newTransactionValidationCheck(txn.ValidateCoinOutputs(fundCtx, coinInputs))
+
+	blockStakeInputs := make(map[types.BlockStakeOutputID]types.BlockStakeOutput, len(txn.BlockStakeInputs))
+	report.BlockStakeInputs = make([]TransactionValidationInput, 0, len(txn.BlockStakeInputs))
+	for index, bsi := range txn.BlockStakeInputs {
+		input := TransactionValidationInput{
+			ParentID: crypto.Hash(bsi.ParentID),
+			Source:   TransactionValidationOutputSourceConfirmed,
+		}
+		bso, err := cs.GetBlockStakeOutput(bsi.ParentID)
+		if err != nil {
+			var ok bool
+			bso, ok = unconfirmedBlockStakeOutputs[bsi.ParentID]
+			if ok {
+				input.Source = TransactionValidationOutputSourceUnconfirmed
+			} else {
+				input.Source = TransactionValidationOutputSourceUnknown
+			}
+		}
+		if input.Source != TransactionValidationOutputSourceUnknown {
+			blockStakeInputs[bsi.ParentID] = bso
+			input.Value, input.Condition = bso.Value, bso.Condition
+			report.BlockStakes.InputSum = report.BlockStakes.InputSum.Add(bso.Value)
+		}
+		input.TransactionValidationCheck = validateInput(input, bsi.Fulfillment, uint64(index), txn, fundCtx)
+		report.BlockStakeInputs = append(report.BlockStakeInputs, input)
+	}
+	for _, bso := range txn.BlockStakeOutputs {
+		report.BlockStakes.OutputSum = report.BlockStakes.OutputSum.Add(bso.Value)
+	}
+	report.BlockStakes.TransactionValidationCheck = newTransactionValidationCheck(txn.ValidateBlockStakeOutputs(fundCtx, blockStakeInputs))
+
+	inputs := append(append([]TransactionValidationInput{}, report.CoinInputs...), report.BlockStakeInputs...)
+
+	// ensure the transaction doesn't conflict with the transaction pool
+	report.TransactionPool = newTransactionValidationCheck(func() error {
+		if inPool {
+			return modules.ErrDuplicateTransactionSet
+		}
+		for _, input := range inputs {
+			if spender, ok := unconfirmedSpenders[input.ParentID]; ok {
+				return fmt.Errorf("output %s is already spent by unconfirmed transaction %s", input.ParentID.String(), spender.String())
+			}
+		}
+		return nil
+	}())
+
+	report.Valid = report.Standalone.Valid &&
+		(report.MintFulfillment == nil || report.MintFulfillment.Valid) &&
+		report.Coins.Valid && report.BlockStakes.Valid && report.TransactionPool.Valid
+	for _, input := range inputs {
+		report.Valid = report.Valid && input.Valid
+	}
+	return report
+}
+
+// validateInput validates whether the given input spends a known output,
+// and whether its fulfillment fulfills the condition of that output.
+func validateInput(input TransactionValidationInput, fulfillment types.UnlockFulfillmentProxy, index uint64, txn types.Transaction, ctx types.FundValidationContext) TransactionValidationCheck {
+	if input.Source == TransactionValidationOutputSourceUnknown {
+		return newTransactionValidationCheck(fmt.Errorf("output %s is not an unspent output, it is either spent or doesn't exist", input.ParentID.String()))
+	}
+	return newTransactionValidationCheck(input.Condition.Fulfill(fulfillment, types.FulfillContext{
+		InputIndex:  index,
+		BlockHeight: ctx.BlockHeight,
+		BlockTime:   ctx.BlockTime,
+		Transaction: txn,
+	}))
+}
+
+// newTransactionValidationCheck creates the result of a validation check from its error, if any
+func newTransactionValidationCheck(err error) TransactionValidationCheck {
+	if err != nil {
+		return TransactionValidationCheck{Error: err.Error()}
+	}
+	return TransactionValidationCheck{Valid: true}
+}
+
+// TransactionPoolGetFeeResponse is the response of a call to /transactionpool/fee,
+// estimating the miner fee a transaction has to pay in order to be confirmed within the target amount of blocks.
+type TransactionPoolGetFeeResponse struct {
+	fees.Estimate
+	// Size (in bytes) of the signed transaction the fee is estimated for,
+	// either as given or the size of a typical transaction of the given version
+	Size uint64 `json:"size"`
+	// Fee is the miner fee the transaction has to pay, at least the minimum transaction fee
+	Fee types.Currency `json:"fee"`
+}
+
+// RegisterTransactionPoolFeeHTTPHandlers registers the handlers for the fee estimation of the TransactionPool,
+// which uses the block facts of the explorer to take the fill rate of the recent blocks into account.
+func RegisterTransactionPoolFeeHTTPHandlers(router api.Router, explorer modules.Explorer, tpool modules.TransactionPool, txdb *persist.TransactionDB, constants types.ChainConstants) {
+	if explorer == nil {
+		panic("no explorer module given")
+	}
+	if tpool == nil {
+		panic("no transaction pool module given")
+	}
+	if txdb == nil {
+		panic("no transaction DB given")
+	}
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.GET("/transactionpool/fee", NewTransactionPoolGetFeeHandler(explorer, tpool, txdb, constants))
+}
+
+// NewTransactionPoolGetFeeHandler creates a handler to handle the API calls to /transactionpool/fee?blocks=<n>&size=<bytes>&version=<v>.
+func NewTransactionPoolGetFeeHandler(explorer modules.Explorer, tpool modules.TransactionPool, txdb *persist.TransactionDB, constants types.ChainConstants) httprouter.Handle {
+	estimator := fees.Estimator{
+		BlockSizeLimit: constants.BlockSizeLimit,
+		MinimumFee:     constants.MinimumTransactionFee,
+	}
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		targetBlocks := uint64(fees.DefaultTargetBlocks)
+		if str := req.FormValue("blocks"); str != "" {
+			n, err := strconv.ParseUint(str, 10, 64)
+			if err != nil || n == 0 || n > fees.MaxTargetBlocks {
+				api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid target blocks given: has to be in the range [1, %d]", fees.MaxTargetBlocks)}, http.StatusBadRequest)
+				return
+			}
+			targetBlocks = n
+		}
+		var size uint64
+		if str := req.FormValue("size"); str != "" {
+			n, err := strconv.ParseUint(str, 10, 64)
+			if err != nil || n == 0 {
+				api.WriteError(w, api.Error{Message: "invalid size given: has to be a positive amount of bytes"}, http.StatusBadRequest)
+				return
+			}
+			size = n
+		} else {
+			version := constants.DefaultTransactionVersion
+			if str := req.FormValue("version"); str != "" {
+				n, err := strconv.ParseUint(str, 10, 8)
+				if err != nil {
+					api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid version given: %v", err)}, http.StatusBadRequest)
+					return
+				}
+				version = types.TransactionVersion(n)
+			}
+			txn, err := feeTemplateTransaction(version, txdb, constants)
+			if err != nil {
+				api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+				return
+			}
+			size = uint64(len(encoding.Marshal(txn)))
+		}
+
+		unconfirmed := tpool.TransactionList()
+		pool := make([]fees.Transaction, 0, len(unconfirmed))
+		for _, txn := range unconfirmed {
+			pool = append(pool, fees.NewTransaction(txn))
+		}
+		estimate := estimator.Estimate(targetBlocks, size, pool, recentFeeBlocks(explorer, fees.DefaultRecentBlocks))
+		api.WriteJSON(w, TransactionPoolGetFeeResponse{
+			Estimate: estimate,
+			Size:     size,
+			Fee:      estimate.Fee(size),
+		})
+	}
+}
+
+// recentFeeBlocks returns (at most) the given amount of most recent blocks, excluding the genesis block,
+// located using the block facts of the explorer
+func recentFeeBlocks(explorer modules.Explorer, n int) []fees.Block {
+	blocks := make([]fees.Block, 0, n)
+	for height := explorer.LatestBlockFacts().Height; height > 0 && len(blocks) < n; height-- {
+		facts, ok := explorer.BlockFacts(height)
+		if !ok {
+			break
+		}
+		block, _, ok := explorer.Block(facts.BlockID)
+		if !ok {
+			break
+		}
+		blocks = append(blocks, fees.NewBlock(block))
+	}
+	return blocks
+}
+
+// feeTemplateTransaction returns a transaction of the given version, as big as a typical signed transaction of that version,
+// used to estimate the fee when no size is given. Regular transactions spend a single-signature coin output,
+// paying one coin output and sending the change back, while the mint fulfillment of coin creation
+// and minter definition transactions is signed by all signers of the active mint condition.
+func feeTemplateTransaction(version types.TransactionVersion, txdb *persist.TransactionDB, constants types.ChainConstants) (types.Transaction, error) {
+	output := types.CoinOutput{
+		Value:     constants.MinimumTransactionFee,
+		Condition: types.NewCondition(types.NewUnlockHashCondition(types.UnlockHash{Type: types.UnlockTypePubKey})),
+	}
+	switch version {
+	case types.TransactionVersionZero, types.TransactionVersionOne:
+		return types.Transaction{
+			Version:     version,
+			CoinInputs:  []types.CoinInput{{Fulfillment: fees.ReservedFulfillment(1)}},
+			CoinOutputs: []types.CoinOutput{output, output},
+			MinerFees:   []types.Currency{constants.MinimumTransactionFee},
+		}, nil
+	case tftypes.TransactionVersionMinterDefinition, tftypes.TransactionVersionCoinCreation:
+		mintCondition, err := txdb.GetActiveMintCondition()
+		if err != nil {
+			return types.Transaction{}, fmt.Errorf("failed to get the active mint condition: %v", err)
+		}
+		mintFulfillment := fees.ReservedFulfillment(len(tftypes.ConditionUnlockHashes(mintCondition)))
+		if version == tftypes.TransactionVersionMinterDefinition {
+			tx := tftypes.MinterDefinitionTransaction{
+				MintFulfillment: mintFulfillment,
+				MintCondition:   mintCondition,
+				MinerFees:       []types.Currency{constants.MinimumTransactionFee},
+			}
+			return tx.Transaction(), nil
+		}
+		tx := tftypes.CoinCreationTransaction{
+			MintFulfillment: mintFulfillment,
+			CoinOutputs:     []types.CoinOutput{output},
+			MinerFees:       []types.Currency{constants.MinimumTransactionFee},
+		}
+		return tx.Transaction(), nil
+	default:
+		return types.Transaction{}, fmt.Errorf("no typical size known for transactions of version %d, the size has to be given", version)
+	}
+}
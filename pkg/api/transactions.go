@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	tftypes "github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type (
+	// TransactionsPostDecode is the body of a call to /transactions/decode.
+	TransactionsPostDecode struct {
+		// Hex is the hex-encoded binary encoding of the transaction to decode
+		Hex string `json:"hex"`
+	}
+	// TransactionsPostDecodeResponse is the response of a call to /transactions/decode,
+	// containing the decoded transaction, its ID and the signature hashes of its inputs.
+	TransactionsPostDecodeResponse struct {
+		TransactionID types.TransactionID          `json:"transactionid"`
+		Transaction   types.Transaction            `json:"transaction"`
+		Size          int                          `json:"size"`
+		SigHashes     tftypes.TransactionSigHashes `json:"sighashes"`
+	}
+
+	// TransactionsPostEncodeResponse is the response of a call to /transactions/encode,
+	// containing the hex-encoded binary encoding of the given (JSON-encoded) transaction.
+	TransactionsPostEncodeResponse struct {
+		TransactionID types.TransactionID `json:"transactionid"`
+		Hex           string              `json:"hex"`
+	}
+)
+
+// RegisterTransactionsHTTPHandlers registers the handlers to encode and decode transactions,
+// of all versions registered for the network of the daemon.
+func RegisterTransactionsHTTPHandlers(router api.Router) {
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.POST("/transactions/decode", NewTransactionsPostDecodeHandler())
+	router.POST("/transactions/encode", NewTransactionsPostEncodeHandler())
+}
+
+// NewTransactionsPostDecodeHandler creates a handler to handle the API calls to /transactions/decode.
+func NewTransactionsPostDecodeHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		var body TransactionsPostDecode
+		err := json.NewDecoder(req.Body).Decode(&body)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("error decoding the supplied body: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		resp, err := DecodeTransactionHex(body.Hex)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		api.WriteJSON(w, resp)
+	}
+}
+
+// NewTransactionsPostEncodeHandler creates a handler to handle the API calls to /transactions/encode.
+func NewTransactionsPostEncodeHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		var txn types.Transaction
+		err := json.NewDecoder(req.Body).Decode(&txn)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("error decoding the supplied transaction: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		resp, err := EncodeTransactionHex(txn)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		api.WriteJSON(w, resp)
+	}
+}
+
+// DecodeTransactionHex decodes a hex-encoded binary transaction,
+// using the transaction controllers registered for its version.
+func DecodeTransactionHex(str string) (TransactionsPostDecodeResponse, error) {
+	b, err := hex.DecodeString(strings.TrimSpace(str))
+	if err != nil {
+		return TransactionsPostDecodeResponse{}, fmt.Errorf("invalid hex-encoded transaction: %v", err)
+	}
+	txn, err := tftypes.DecodeTransaction(b)
+	if err != nil {
+		return TransactionsPostDecodeResponse{}, fmt.Errorf("invalid binary-encoded transaction: %v", err)
+	}
+	sigHashes, err := tftypes.NewTransactionSigHashes(txn)
+	if err != nil {
+		return TransactionsPostDecodeResponse{}, fmt.Errorf("failed to compute signature hashes: %v", err)
+	}
+	return TransactionsPostDecodeResponse{
+		TransactionID: txn.ID(),
+		Transaction:   txn,
+		Size:          len(b),
+		SigHashes:     sigHashes,
+	}, nil
+}
+
+// EncodeTransactionHex encodes a transaction as a hex-encoded binary string,
+// using the transaction controllers registered for its version.
+// An error is returned for transactions with nil fulfillments, which have no binary encoding,
+// as well as if the encoded transaction cannot be decoded.
+func EncodeTransactionHex(txn types.Transaction) (TransactionsPostEncodeResponse, error) {
+	if tftypes.TransactionHasNilFulfillment(txn) {
+		return TransactionsPostEncodeResponse{}, fmt.Errorf("transaction cannot be binary-encoded: %v", types.ErrNilFulfillmentType)
+	}
+	b := encoding.Marshal(txn)
+	if _, err := tftypes.DecodeTransaction(b); err != nil {
+		return TransactionsPostEncodeResponse{}, fmt.Errorf("transaction cannot be binary-encoded: %v", err)
+	}
+	return TransactionsPostEncodeResponse{
+		TransactionID: txn.ID(),
+		Hex:           hex.EncodeToString(b),
+	}, nil
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type (
+	// WalletPostMultiSigTransaction is the body of a call to /wallet/multisig/:address/transaction,
+	// defining the outputs to be funded by the co-owned multisig wallet.
+	WalletPostMultiSigTransaction struct {
+		CoinOutputs   []types.CoinOutput `json:"coinoutputs"`
+		ArbitraryData []byte             `json:"arbitrarydata,omitempty"`
+		// MinerFee optionally defines the miner fee to pay, the minimum transaction fee if not defined
+		MinerFee types.Currency `json:"minerfee"`
+	}
+	// WalletPostMultiSigTransactionResponse contains the created transaction,
+	// funded by the co-owned multisig wallet and signed with all keys of this wallet
+	// that are owners of the multisig wallet. The transaction is not yet fully signed
+	// in case more signatures are required, in which case it has to be signed by the co-signers.
+	WalletPostMultiSigTransactionResponse struct {
+		Transaction types.Transaction `json:"transaction"`
+		// Signatures is the amount of signatures added to each coin input by this wallet
+		Signatures uint64 `json:"signatures"`
+		// MinimumSignatures is the amount of signatures required for each coin input
+		MinimumSignatures uint64 `json:"minimumsignatures"`
+	}
+	// WalletGetMultiSigTransactions contains the transaction history
+	// of a co-owned multisig wallet.
+	WalletGetMultiSigTransactions struct {
+		api.WalletTransactionsGET
+	}
+)
+
+// RegisterWalletHTTPHandlers registers the handlers for all tfchain-specific Wallet HTTP endpoints,
+// extending the default Rivine Wallet HTTP endpoints.
+func RegisterWalletHTTPHandlers(router api.Router, cs modules.ConsensusSet, tpool modules.TransactionPool, wallet modules.Wallet, constants types.ChainConstants, requiredPassword string) {
+	if cs == nil {
+		panic("no consensus set module given")
+	}
+	if tpool == nil {
+		panic("no transaction pool module given")
+	}
+	if wallet == nil {
+		panic("no wallet module given")
+	}
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.POST("/wallet/multisig/:address/transaction", api.RequirePasswordHandler(NewWalletPostMultiSigTransactionHandler(cs, tpool, wallet, constants), requiredPassword))
+	router.GET("/wallet/multisig/:address/transactions", api.RequirePasswordHandler(NewWalletGetMultiSigTransactionsHandler(wallet), requiredPassword))
+	router.GET("/wallet/history", api.RequirePasswordHandler(NewWalletGetHistoryHandler(cs, wallet, constants), requiredPassword))
+}
+
+// NewWalletPostMultiSigTransactionHandler creates a handler to handle the API calls to /wallet/multisig/:address/transaction.
+func NewWalletPostMultiSigTransactionHandler(cs modules.ConsensusSet, tpool modules.TransactionPool, wallet modules.Wallet, constants types.ChainConstants) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		msw, ok := getCoOwnedMultiSigWallet(w, wallet, ps.ByName("address"))
+		if !ok {
+			return
+		}
+		var body WalletPostMultiSigTransaction
+		err := json.NewDecoder(req.Body).Decode(&body)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("error decoding the supplied outputs: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		if len(body.CoinOutputs) == 0 {
+			api.WriteError(w, api.Error{Message: "no coin outputs given"}, http.StatusBadRequest)
+			return
+		}
+
+		fee, err := minerFee(body.MinerFee, constants)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		txn, err := fundMultiSigTransaction(cs, tpool, msw, constants, body.CoinOutputs, body.ArbitraryData, fee)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		txn, err = wallet.GreedySign(txn)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to sign multisig transaction: %v", err)}, http.StatusInternalServerError)
+			return
+		}
+		var signatures uint64
+		if ms, ok := txn.CoinInputs[0].Fulfillment.Fulfillment.(*types.MultiSignatureFulfillment); ok {
+			signatures = uint64(len(ms.Pairs))
+		}
+		api.WriteJSON(w, WalletPostMultiSigTransactionResponse{
+			Transaction:       txn,
+			Signatures:        signatures,
+			MinimumSignatures: msw.MinSigs,
+		})
+	}
+}
+
+// NewWalletGetMultiSigTransactionsHandler creates a handler to handle the API calls to /wallet/multisig/:address/transactions.
+func NewWalletGetMultiSigTransactionsHandler(wallet modules.Wallet) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		msw, ok := getCoOwnedMultiSigWallet(w, wallet, ps.ByName("address"))
+		if !ok {
+			return
+		}
+		confirmedTxns, err := wallet.AddressTransactions(msw.Address)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to get confirmed multisig transactions: %v", err)}, http.StatusInternalServerError)
+			return
+		}
+		unconfirmedTxns, err := wallet.AddressUnconfirmedTransactions(msw.Address)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to get unconfirmed multisig transactions: %v", err)}, http.StatusInternalServerError)
+			return
+		}
+		api.WriteJSON(w, WalletGetMultiSigTransactions{
+			WalletTransactionsGET: api.WalletTransactionsGET{
+				ConfirmedTransactions:   confirmedTxns,
+				UnconfirmedTransactions: unconfirmedTxns,
+			},
+		})
+	}
+}
+
+// getCoOwnedMultiSigWallet returns the multisig wallet co-owned by the wallet for the given address,
+// writing an error to the response writer and returning false if it couldn't be found
+func getCoOwnedMultiSigWallet(w http.ResponseWriter, wallet modules.Wallet, addressStr string) (modules.MultiSigWallet, bool) {
+	var address types.UnlockHash
+	err := address.LoadString(addressStr)
+	if err != nil {
+		api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid multisig address given: %v", err)}, http.StatusBadRequest)
+		return modules.MultiSigWallet{}, false
+	}
+	if address.Type != types.UnlockTypeMultiSig {
+		api.WriteError(w, api.Error{Message: fmt.Sprintf("address %s is not a multisig address", address.String())}, http.StatusBadRequest)
+		return modules.MultiSigWallet{}, false
+	}
+	msws, err := wallet.MultiSigWallets()
+	if err != nil {
+		if err == modules.ErrLockedWallet {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+			return modules.MultiSigWallet{}, false
+		}
+		api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to get multisig wallets: %v", err)}, http.StatusInternalServerError)
+		return modules.MultiSigWallet{}, false
+	}
+	for _, msw := range msws {
+		if msw.Address.Cmp(address) == 0 {
+			return msw, true
+		}
+	}
+	api.WriteError(w, api.Error{Message: fmt.Sprintf("multisig address %s is not co-owned by this wallet", address.String())}, http.StatusNoContent)
+	return modules.MultiSigWallet{}, false
+}
+
+// fundMultiSigTransaction creates an unsigned transaction, funding the given outputs and miner fee
+// using the spendable coin outputs of the given multisig wallet, sending the change back to the same multisig wallet.
+// Coin outputs that are already spent by unconfirmed transactions are not used.
+func fundMultiSigTransaction(cs modules.ConsensusSet, tpool modules.TransactionPool, msw modules.MultiSigWallet, constants types.ChainConstants, outputs []types.CoinOutput, data []byte, fee types.Currency) (types.Transaction, error) {
+	// collect all spendable coin outputs of the multisig wallet
+	ctx := types.FulfillableContext{
+		BlockHeight: cs.Height(),
+		BlockTime:   cs.CurrentBlock().Timestamp,
+	}
+	var candidates []fundingCandidate
+	for _, id := range msw.CoinOutputIDs {
+		co, err := cs.GetCoinOutput(id)
+		if err != nil || !co.Condition.Fulfillable(ctx) {
+			continue
+		}
+		candidates = append(candidates, fundingCandidate{ID: id, Output: co})
+	}
+	changeCondition := types.NewCondition(types.NewMultiSignatureCondition(msw.Owners, msw.MinSigs))
+	txn, _, err := fundTransaction(tpool, constants, candidates, changeCondition, outputs, data, fee)
+	if err != nil {
+		return types.Transaction{}, fmt.Errorf("multisig wallet %s: %v", msw.Address.String(), err)
+	}
+	return txn, nil
+}
+
+// fundingCandidate is a spendable coin output, which can be used to fund a transaction.
+type fundingCandidate struct {
+	ID     types.CoinOutputID
+	Output types.CoinOutput
+}
+
+// fundTransaction creates an unsigned transaction, funding the given outputs and miner fee
+// using the given spendable coin outputs, sending the change to the given condition.
+// Coin outputs that are already spent by unconfirmed transactions are not used.
+// The parent outputs of the coin inputs are returned together with the transaction, in the same order.
+func fundTransaction(tpool modules.TransactionPool, constants types.ChainConstants, candidates []fundingCandidate, changeCondition types.UnlockConditionProxy, outputs []types.CoinOutput, data []byte, fee types.Currency) (types.Transaction, []types.CoinOutput, error) {
+	txn := types.Transaction{
+		Version:       constants.DefaultTransactionVersion,
+		CoinOutputs:   outputs,
+		MinerFees:     []types.Currency{fee},
+		ArbitraryData: data,
+	}
+	required := fee
+	for _, co := range outputs {
+		if co.Value.IsZero() {
+			return types.Transaction{}, nil, errors.New("coin outputs cannot have a zero value")
+		}
+		required = required.Add(co.Value)
+	}
+
+	// collect all coin outputs which are already spent by unconfirmed transactions
+	unconfirmedSpent := make(map[types.CoinOutputID]struct{})
+	for _, utxn := range tpool.TransactionList() {
+		for _, ci := range utxn.CoinInputs {
+			unconfirmedSpent[ci.ParentID] = struct{}{}
+		}
+	}
+	available := make([]fundingCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		if _, ok := unconfirmedSpent[c.ID]; !ok {
+			available = append(available, c)
+		}
+	}
+	// use the biggest outputs first, keeping the amount of inputs (and thus signatures) small
+	sort.Slice(available, func(i, j int) bool {
+		return available[i].Output.Value.Cmp(available[j].Output.Value) > 0
+	})
+
+	var (
+		funded  types.Currency
+		parents []types.CoinOutput
+	)
+	for _, c := range available {
+		if funded.Cmp(required) >= 0 {
+			break
+		}
+		txn.CoinInputs = append(txn.CoinInputs, types.CoinInput{ParentID: c.ID})
+		parents = append(parents, c.Output)
+		funded = funded.Add(c.Output.Value)
+	}
+	if funded.Cmp(required) < 0 {
+		return types.Transaction{}, nil, fmt.Errorf(
+			"insufficient spendable coins: %s required, while only %s is available",
+			required.String(), funded.String())
+	}
+
+	// send the change back to the given condition
+	if change := funded.Sub(required); !change.IsZero() {
+		txn.CoinOutputs = append(txn.CoinOutputs, types.CoinOutput{
+			Value:     change,
+			Condition: changeCondition,
+		})
+	}
+	return txn, parents, nil
+}
+
+// minerFee returns the given miner fee, or the minimum transaction fee if no fee is given,
+// returning an error if the given fee is lower than the minimum transaction fee
+func minerFee(fee types.Currency, constants types.ChainConstants) (types.Currency, error) {
+	if fee.IsZero() {
+		return constants.MinimumTransactionFee, nil
+	}
+	if fee.Cmp(constants.MinimumTransactionFee) < 0 {
+		return types.Currency{}, fmt.Errorf("miner fee has to be at least the minimum transaction fee of %s", constants.MinimumTransactionFee.String())
+	}
+	return fee, nil
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestWalletPostMultiSigTransaction(t *testing.T) {
+	constants := types.DefaultChainConstants()
+	coins := func(n uint64) types.Currency { return constants.CurrencyUnits.OneCoin.Mul64(n) }
+	fee := constants.MinimumTransactionFee
+
+	var owner1, owner2 types.UnlockHash
+	owner1.Type, owner1.Hash[0] = types.UnlockTypePubKey, 1
+	owner2.Type, owner2.Hash[0] = types.UnlockTypePubKey, 2
+	msCondition := types.NewMultiSignatureCondition(types.UnlockHashSlice{owner1, owner2}, 1)
+	msw := modules.MultiSigWallet{
+		Address: msCondition.UnlockHash(),
+		Owners:  msCondition.UnlockHashes,
+		MinSigs: msCondition.MinimumSignatureCount,
+	}
+	// 3 spendable outputs of 30, 20 and 5 coins, and a locked output of 100 coins
+	cs := &testWalletConsensusSet{outputs: make(map[types.CoinOutputID]types.CoinOutput)}
+	for idx, value := range []uint64{5, 30, 100, 20} {
+		var id types.CoinOutputID
+		id[0] = byte(idx + 1)
+		condition := types.NewCondition(msCondition)
+		if value == 100 {
+			condition = types.NewCondition(types.NewTimeLockCondition(1000, msCondition))
+		}
+		cs.outputs[id] = types.CoinOutput{Value: coins(value), Condition: condition}
+		msw.CoinOutputIDs = append(msw.CoinOutputIDs, id)
+	}
+	id5, id30, id20 := msw.CoinOutputIDs[0], msw.CoinOutputIDs[1], msw.CoinOutputIDs[3]
+	// an unconfirmed transaction spending the output of 30 coins
+	spent30 := []types.Transaction{{CoinInputs: []types.CoinInput{{ParentID: id30}}}}
+
+	testCases := []struct {
+		Name        string
+		Value       types.Currency
+		Unconfirmed []types.Transaction
+		Inputs      []types.CoinOutputID
+		Change      types.Currency
+		Error       string
+	}{
+		{
+			Name:   "exact amount",
+			Value:  coins(50).Sub(fee),
+			Inputs: []types.CoinOutputID{id30, id20},
+		},
+		{
+			Name:   "change",
+			Value:  coins(25),
+			Inputs: []types.CoinOutputID{id30},
+			Change: coins(5).Sub(fee),
+		},
+		{
+			Name:   "all spendable outputs",
+			Value:  coins(54),
+			Inputs: []types.CoinOutputID{id30, id20, id5},
+		},
+		{
+			Name:  "insufficient funds",
+			Value: coins(55),
+			Error: "insufficient spendable coins",
+		},
+		{
+			Name:        "outputs spent in the pool",
+			Value:       coins(20),
+			Unconfirmed: spent30,
+			Inputs:      []types.CoinOutputID{id20, id5},
+			Change:      coins(4),
+		},
+		{
+			Name:        "insufficient funds without outputs spent in the pool",
+			Value:       coins(25),
+			Unconfirmed: spent30,
+			Error:       "insufficient spendable coins",
+		},
+	}
+	for _, testCase := range testCases {
+		router := httprouter.New()
+		router.POST("/wallet/multisig/:address/transaction", NewWalletPostMultiSigTransactionHandler(
+			cs, &testWalletTransactionPool{txns: testCase.Unconfirmed}, &testMultiSigWallet{msw: msw}, constants))
+		recipient := types.CoinOutput{Value: testCase.Value, Condition: types.NewCondition(types.NewUnlockHashCondition(owner1))}
+		body, err := json.Marshal(WalletPostMultiSigTransaction{CoinOutputs: []types.CoinOutput{recipient}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/wallet/multisig/"+msw.Address.String()+"/transaction", bytes.NewReader(body)))
+
+		if testCase.Error != "" {
+			var apiErr api.Error
+			json.NewDecoder(rec.Body).Decode(&apiErr)
+			if rec.Code != http.StatusBadRequest || !strings.Contains(apiErr.Message, testCase.Error) {
+				t.Errorf("%s: expected error %q, got status %d: %v", testCase.Name, testCase.Error, rec.Code, apiErr.Message)
+			}
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: unexpected status %d: %s", testCase.Name, rec.Code, rec.Body.String())
+			continue
+		}
+		var resp WalletPostMultiSigTransactionResponse
+		if err = json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: %v", testCase.Name, err)
+		}
+		txn := resp.Transaction
+		var inputs []types.CoinOutputID
+		for _, ci := range txn.CoinInputs {
+			inputs = append(inputs, ci.ParentID)
+		}
+		if !reflect.DeepEqual(inputs, testCase.Inputs) {
+			t.Errorf("%s: expected inputs %v, not %v", testCase.Name, testCase.Inputs, inputs)
+		}
+		if len(txn.MinerFees) != 1 || !txn.MinerFees[0].Equals(fee) {
+			t.Errorf("%s: unexpected miner fees %v", testCase.Name, txn.MinerFees)
+		}
+		outputs := []types.CoinOutput{recipient}
+		if !testCase.Change.IsZero() {
+			outputs = append(outputs, types.CoinOutput{Value: testCase.Change, Condition: types.NewCondition(msCondition)})
+		}
+		if !reflect.DeepEqual(txn.CoinOutputs, outputs) {
+			t.Errorf("%s: expected outputs %v, not %v", testCase.Name, outputs, txn.CoinOutputs)
+		}
+		if resp.Signatures != 1 || resp.MinimumSignatures != 1 {
+			t.Errorf("%s: unexpected signatures %d/%d", testCase.Name, resp.Signatures, resp.MinimumSignatures)
+		}
+	}
+}
+
+func TestWalletGetMultiSigTransactions(t *testing.T) {
+	var owner1, owner2 types.UnlockHash
+	owner1.Type, owner1.Hash[0] = types.UnlockTypePubKey, 1
+	owner2.Type, owner2.Hash[0] = types.UnlockTypePubKey, 2
+	msCondition := types.NewMultiSignatureCondition(types.UnlockHashSlice{owner1, owner2}, 1)
+	other := types.NewMultiSignatureCondition(types.UnlockHashSlice{owner1, owner2}, 2).UnlockHash()
+	wallet := &testMultiSigWallet{
+		msw: modules.MultiSigWallet{Address: msCondition.UnlockHash()},
+		confirmed: []modules.ProcessedTransaction{
+			{ConfirmationHeight: 1},
+			{ConfirmationHeight: 2},
+		},
+		unconfirmed: []modules.ProcessedTransaction{{}},
+	}
+	router := httprouter.New()
+	router.GET("/wallet/multisig/:address/transactions", NewWalletGetMultiSigTransactionsHandler(wallet))
+
+	testCases := []struct {
+		Address string
+		Status  int
+	}{
+		{msCondition.UnlockHash().String(), http.StatusOK},
+		{"foo", http.StatusBadRequest},
+		// not a multisig address
+		{owner1.String(), http.StatusBadRequest},
+		// not co-owned by the wallet
+		{other.String(), http.StatusNoContent},
+	}
+	for _, testCase := range testCases {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wallet/multisig/"+testCase.Address+"/transactions", nil))
+		if rec.Code != testCase.Status {
+			t.Errorf("address %s: expected status %d, not %d: %s", testCase.Address, testCase.Status, rec.Code, rec.Body.String())
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+		var resp WalletGetMultiSigTransactions
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.ConfirmedTransactions) != 2 || len(resp.UnconfirmedTransactions) != 1 {
+			t.Errorf("unexpected transactions: %d confirmed, %d unconfirmed", len(resp.ConfirmedTransactions), len(resp.UnconfirmedTransactions))
+		}
+	}
+}
+
+// testWalletConsensusSet knows the given coin outputs
+type testWalletConsensusSet struct {
+	modules.ConsensusSet
+	outputs map[types.CoinOutputID]types.CoinOutput
+}
+
+func (cs *testWalletConsensusSet) Height() types.BlockHeight { return 10 }
+
+func (cs *testWalletConsensusSet) CurrentBlock() types.Block { return types.Block{Timestamp: 1000} }
+
+func (cs *testWalletConsensusSet) GetCoinOutput(id types.CoinOutputID) (types.CoinOutput, error) {
+	co, ok := cs.outputs[id]
+	if !ok {
+		return types.CoinOutput{}, errors.New("coin output not found")
+	}
+	return co, nil
+}
+
+// testWalletTransactionPool contains the given unconfirmed transactions
+type testWalletTransactionPool struct {
+	modules.TransactionPool
+	txns []types.Transaction
+}
+
+func (tpool *testWalletTransactionPool) TransactionList() []types.Transaction { return tpool.txns }
+
+// testMultiSigWallet co-owns a single multisig wallet, adding a single signature to each coin input
+type testMultiSigWallet struct {
+	modules.Wallet
+	msw                    modules.MultiSigWallet
+	confirmed, unconfirmed []modules.ProcessedTransaction
+}
+
+func (w *testMultiSigWallet) AddressTransactions(types.UnlockHash) ([]modules.ProcessedTransaction, error) {
+	return w.confirmed, nil
+}
+
+func (w *testMultiSigWallet) AddressUnconfirmedTransactions(types.UnlockHash) ([]modules.ProcessedTransaction, error) {
+	return w.unconfirmed, nil
+}
+
+func (w *testMultiSigWallet) MultiSigWallets() ([]modules.MultiSigWallet, error) {
+	return []modules.MultiSigWallet{w.msw}, nil
+}
+
+func (w *testMultiSigWallet) GreedySign(txn types.Transaction) (types.Transaction, error) {
+	for idx := range txn.CoinInputs {
+		txn.CoinInputs[idx].Fulfillment = types.NewFulfillment(&types.MultiSignatureFulfillment{
+			Pairs: []types.PublicKeySignaturePair{{
+				PublicKey: types.Ed25519PublicKey(crypto.PublicKey{}),
+				Signature: make([]byte, crypto.SignatureSize),
+			}},
+		})
+	}
+	return txn, nil
+}
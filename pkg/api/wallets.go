@@ -0,0 +1,108 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/api"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// DefaultWalletName is the name of the default wallet,
+// which is served using the /wallet endpoints as well.
+const DefaultWalletName = "default"
+
+// validWalletName defines the format of a wallet name
+var validWalletName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+// ValidateWalletName returns an error if the given name can't be used as a wallet name.
+func ValidateWalletName(name string) error {
+	if !validWalletName.MatchString(name) {
+		return fmt.Errorf("invalid wallet name %q: has to consist of 1 to 32 alphanumeric, '_' or '-' characters", name)
+	}
+	return nil
+}
+
+type (
+	// NamedWallet is a named wallet, together with the handler
+	// which serves all its /wallet HTTP endpoints.
+	NamedWallet struct {
+		Name    string
+		Wallet  modules.Wallet
+		Handler http.Handler
+	}
+
+	// WalletsGET contains the names and states of all wallets of the daemon.
+	WalletsGET struct {
+		Wallets []WalletsGETWallet `json:"wallets"`
+	}
+	// WalletsGETWallet contains the name and state of a single wallet.
+	WalletsGETWallet struct {
+		Name      string `json:"name"`
+		Encrypted bool   `json:"encrypted"`
+		Unlocked  bool   `json:"unlocked"`
+	}
+)
+
+// RegisterNamedWalletsHTTPHandlers registers the handlers for the /wallets HTTP endpoints,
+// serving all calls to /wallets/:name/... using the given handler of the complete API,
+// except for the /wallet/... endpoints, which are served by the wallet with that name.
+func RegisterNamedWalletsHTTPHandlers(router api.Router, handler http.Handler, wallets []NamedWallet, requiredPassword string) {
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+	walletsByName := make(map[string]NamedWallet, len(wallets))
+	for _, wallet := range wallets {
+		if err := ValidateWalletName(wallet.Name); err != nil {
+			panic(err)
+		}
+		if _, ok := walletsByName[wallet.Name]; ok {
+			panic(fmt.Sprintf("duplicate wallet name %q", wallet.Name))
+		}
+		walletsByName[wallet.Name] = wallet
+	}
+
+	router.GET("/wallets", api.RequirePasswordHandler(NewWalletsGetHandler(wallets), requiredPassword))
+	routeHandler := NewNamedWalletHandler(handler, walletsByName)
+	router.GET("/wallets/:name/*path", routeHandler)
+	router.POST("/wallets/:name/*path", routeHandler)
+}
+
+// NewWalletsGetHandler creates a handler to handle the API calls to /wallets.
+func NewWalletsGetHandler(wallets []NamedWallet) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		resp := WalletsGET{Wallets: make([]WalletsGETWallet, 0, len(wallets))}
+		for _, wallet := range wallets {
+			resp.Wallets = append(resp.Wallets, WalletsGETWallet{
+				Name:      wallet.Name,
+				Encrypted: wallet.Wallet.Encrypted(),
+				Unlocked:  wallet.Wallet.Unlocked(),
+			})
+		}
+		api.WriteJSON(w, resp)
+	}
+}
+
+// NewNamedWalletHandler creates a handler to handle the API calls to /wallets/:name/...,
+// serving the /wallet/... endpoints using the wallet with the given name,
+// and all other endpoints using the given handler.
+func NewNamedWalletHandler(handler http.Handler, wallets map[string]NamedWallet) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		wallet, ok := wallets[ps.ByName("name")]
+		if !ok {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("wallet %q does not exist", ps.ByName("name"))}, http.StatusBadRequest)
+			return
+		}
+		path := ps.ByName("path")
+		req.URL.Path, req.URL.RawPath = path, ""
+		if path == "/wallet" || strings.HasPrefix(path, "/wallet/") {
+			wallet.Handler.ServeHTTP(w, req)
+			return
+		}
+		handler.ServeHTTP(w, req)
+	}
+}
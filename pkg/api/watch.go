@@ -0,0 +1,438 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/threefoldfoundation/tfchain/pkg/persist"
+	tftypes "github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// WatchOutputStatus defines the spendability status of an output of a watch-only entry.
+type WatchOutputStatus string
+
+// All possible spendability statuses of an output of a watch-only entry.
+const (
+	// WatchOutputStatusSpendable is the status of an unspent output which can be spent right now
+	WatchOutputStatusSpendable WatchOutputStatus = "spendable"
+	// WatchOutputStatusLocked is the status of an unspent output which is time-locked,
+	// or a miner payout which hasn't matured yet
+	WatchOutputStatusLocked WatchOutputStatus = "locked"
+	// WatchOutputStatusPending is the status of an output that is spent by an unconfirmed transaction
+	WatchOutputStatusPending WatchOutputStatus = "pending"
+	// WatchOutputStatusSpent is the status of an output that is spent by a confirmed transaction
+	WatchOutputStatusSpent WatchOutputStatus = "spent"
+)
+
+type (
+	// WalletPostWatch is the body of a call to /wallet/watch,
+	// defining the watch-only entry to add, using either an unlock hash or a full condition.
+	WalletPostWatch struct {
+		UnlockHash *types.UnlockHash           `json:"unlockhash,omitempty"`
+		Condition  *types.UnlockConditionProxy `json:"condition,omitempty"`
+		Label      string                      `json:"label,omitempty"`
+		// StartHeight optionally defines the block height from which the entry is scanned
+		StartHeight types.BlockHeight `json:"startheight,omitempty"`
+	}
+	// WalletGetWatch contains all watch-only entries, together with their balances.
+	WalletGetWatch struct {
+		Entries []WalletWatchEntry `json:"entries"`
+	}
+	// WalletGetWatchEntry contains a single watch-only entry, together with its balances
+	// and all its outputs.
+	WalletGetWatchEntry struct {
+		WalletWatchEntry
+		Outputs []WalletWatchOutput `json:"outputs"`
+	}
+	// WalletPostWatchTransaction is the body of a call to /wallet/watch/:unlockhash/transaction,
+	// defining the outputs to be funded by the watch-only entry.
+	WalletPostWatchTransaction struct {
+		CoinOutputs   []types.CoinOutput `json:"coinoutputs"`
+		ArbitraryData []byte             `json:"arbitrarydata,omitempty"`
+		// MinerFee optionally defines the miner fee to pay, the minimum transaction fee if not defined
+		MinerFee types.Currency `json:"minerfee"`
+	}
+	// WalletPostWatchTransactionResponse contains the created unsigned transaction,
+	// funded by the watch-only entry, wrapped in a PSTX such that it can be signed offline.
+	WalletPostWatchTransactionResponse struct {
+		Transaction tftypes.PartiallySignedTransaction `json:"transaction"`
+	}
+
+	// WalletWatchEntry is a watch-only entry, together with its balances.
+	WalletWatchEntry struct {
+		persist.WatchEntry
+		// Signers are the addresses that can sign to spend the outputs of this entry,
+		// only known if a condition was given for the entry, or if it is a (single signature) address
+		Signers []types.UnlockHash `json:"signers,omitempty"`
+		// MinimumSignatures is the amount of signatures required to spend the outputs of this entry,
+		// only known if Signers is known
+		MinimumSignatures uint64 `json:"minimumsignatures,omitempty"`
+
+		ConfirmedCoinBalance       types.Currency `json:"confirmedcoinbalance"`
+		ConfirmedLockedCoinBalance types.Currency `json:"confirmedlockedcoinbalance"`
+		UnconfirmedOutgoingCoins   types.Currency `json:"unconfirmedoutgoingcoins"`
+		UnconfirmedIncomingCoins   types.Currency `json:"unconfirmedincomingcoins"`
+
+		BlockStakeBalance       types.Currency `json:"blockstakebalance"`
+		LockedBlockStakeBalance types.Currency `json:"lockedblockstakebalance"`
+	}
+	// WalletWatchOutput is an output of a watch-only entry, together with its spendability status.
+	WalletWatchOutput struct {
+		persist.WatchOutput
+		Status WatchOutputStatus `json:"status"`
+	}
+)
+
+// RegisterWalletWatchHTTPHandlers registers the handlers for the watch-only Wallet HTTP endpoints.
+func RegisterWalletWatchHTTPHandlers(router api.Router, tpool modules.TransactionPool, watchDB *persist.WatchDB, constants types.ChainConstants, requiredPassword string) {
+	if tpool == nil {
+		panic("no transaction pool module given")
+	}
+	if watchDB == nil {
+		panic("no watchDB given")
+	}
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.POST("/wallet/watch", api.RequirePasswordHandler(NewWalletPostWatchHandler(watchDB), requiredPassword))
+	router.GET("/wallet/watch", NewWalletGetWatchHandler(tpool, watchDB))
+	router.GET("/wallet/watch/:unlockhash", NewWalletGetWatchEntryHandler(tpool, watchDB))
+	router.POST("/wallet/watch/:unlockhash/remove", api.RequirePasswordHandler(NewWalletPostWatchRemoveHandler(watchDB), requiredPassword))
+	router.GET("/wallet/watch/:unlockhash/history", NewWalletGetWatchHistoryHandler(watchDB, constants))
+	router.POST("/wallet/watch/:unlockhash/transaction", NewWalletPostWatchTransactionHandler(tpool, watchDB, constants))
+}
+
+// NewWalletPostWatchHandler creates a handler to handle the API calls to /wallet/watch.
+func NewWalletPostWatchHandler(watchDB *persist.WatchDB) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		var body WalletPostWatch
+		err := json.NewDecoder(req.Body).Decode(&body)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("error decoding the supplied watch entry: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		entry := persist.WatchEntry{Label: body.Label, StartHeight: body.StartHeight}
+		switch {
+		case body.Condition != nil && body.Condition.ConditionType() != types.ConditionTypeNil:
+			entry.Condition = *body.Condition
+			entry.UnlockHash = body.Condition.UnlockHash()
+			if body.UnlockHash != nil && body.UnlockHash.Cmp(entry.UnlockHash) != 0 {
+				api.WriteError(w, api.Error{Message: fmt.Sprintf(
+					"condition has unlock hash %s, while unlock hash %s was given",
+					entry.UnlockHash.String(), body.UnlockHash.String())}, http.StatusBadRequest)
+				return
+			}
+		case body.UnlockHash != nil:
+			entry.UnlockHash = *body.UnlockHash
+		default:
+			api.WriteError(w, api.Error{Message: "no unlock hash or condition given"}, http.StatusBadRequest)
+			return
+		}
+		if entry.UnlockHash.Type == types.UnlockTypeNil {
+			api.WriteError(w, api.Error{Message: "cannot watch the nil unlock hash"}, http.StatusBadRequest)
+			return
+		}
+
+		err = watchDB.AddEntry(entry)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to add watch entry: %v", err)}, http.StatusInternalServerError)
+			return
+		}
+		api.WriteSuccess(w)
+	}
+}
+
+// NewWalletGetWatchHandler creates a handler to handle the API calls to /wallet/watch.
+func NewWalletGetWatchHandler(tpool modules.TransactionPool, watchDB *persist.WatchDB) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		entries, err := watchDB.Entries()
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to get watch entries: %v", err)}, http.StatusInternalServerError)
+			return
+		}
+		unconfirmed := newUnconfirmedActivity(tpool)
+		resp := WalletGetWatch{Entries: make([]WalletWatchEntry, 0, len(entries))}
+		for _, entry := range entries {
+			outputs, err := watchDB.Outputs(entry.UnlockHash)
+			if err != nil {
+				api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to get outputs of watch entry %s: %v", entry.UnlockHash.String(), err)}, http.StatusInternalServerError)
+				return
+			}
+			info, _ := newWalletWatchEntry(entry, outputs, watchDB.FulfillableContext(), unconfirmed)
+			resp.Entries = append(resp.Entries, info)
+		}
+		api.WriteJSON(w, resp)
+	}
+}
+
+// NewWalletGetWatchEntryHandler creates a handler to handle the API calls to /wallet/watch/:unlockhash.
+func NewWalletGetWatchEntryHandler(tpool modules.TransactionPool, watchDB *persist.WatchDB) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		entry, ok := getWatchEntry(w, watchDB, ps.ByName("unlockhash"))
+		if !ok {
+			return
+		}
+		outputs, err := watchDB.Outputs(entry.UnlockHash)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to get outputs of watch entry: %v", err)}, http.StatusInternalServerError)
+			return
+		}
+		info, watchOutputs := newWalletWatchEntry(entry, outputs, watchDB.FulfillableContext(), newUnconfirmedActivity(tpool))
+		api.WriteJSON(w, WalletGetWatchEntry{
+			WalletWatchEntry: info,
+			Outputs:          watchOutputs,
+		})
+	}
+}
+
+// NewWalletPostWatchRemoveHandler creates a handler to handle the API calls to /wallet/watch/:unlockhash/remove.
+func NewWalletPostWatchRemoveHandler(watchDB *persist.WatchDB) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		entry, ok := getWatchEntry(w, watchDB, ps.ByName("unlockhash"))
+		if !ok {
+			return
+		}
+		err := watchDB.RemoveEntry(entry.UnlockHash)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to remove watch entry: %v", err)}, http.StatusInternalServerError)
+			return
+		}
+		api.WriteSuccess(w)
+	}
+}
+
+// NewWalletGetWatchHistoryHandler creates a handler to handle the API calls to /wallet/watch/:unlockhash/history.
+func NewWalletGetWatchHistoryHandler(watchDB *persist.WatchDB, constants types.ChainConstants) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		entry, ok := getWatchEntry(w, watchDB, ps.ByName("unlockhash"))
+		if !ok {
+			return
+		}
+		cursor, limit, ok := loadHistoryPage(w, req)
+		if !ok {
+			return
+		}
+		txns, err := watchDB.Transactions(entry.UnlockHash, cursor.startHeight())
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to get transactions of watch entry: %v", err)}, http.StatusInternalServerError)
+			return
+		}
+		writeHistoryPage(w, watchHistory(entry.UnlockHash, txns), constants, cursor, limit)
+	}
+}
+
+// NewWalletPostWatchTransactionHandler creates a handler to handle the API calls to /wallet/watch/:unlockhash/transaction.
+func NewWalletPostWatchTransactionHandler(tpool modules.TransactionPool, watchDB *persist.WatchDB, constants types.ChainConstants) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		entry, ok := getWatchEntry(w, watchDB, ps.ByName("unlockhash"))
+		if !ok {
+			return
+		}
+		var body WalletPostWatchTransaction
+		err := json.NewDecoder(req.Body).Decode(&body)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("error decoding the supplied outputs: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		if len(body.CoinOutputs) == 0 {
+			api.WriteError(w, api.Error{Message: "no coin outputs given"}, http.StatusBadRequest)
+			return
+		}
+		fee, err := minerFee(body.MinerFee, constants)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		outputs, err := watchDB.Outputs(entry.UnlockHash)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to get outputs of watch entry: %v", err)}, http.StatusInternalServerError)
+			return
+		}
+
+		// collect all spendable coin outputs of the watch-only entry
+		ctx := watchDB.FulfillableContext()
+		var candidates []fundingCandidate
+		for _, output := range outputs {
+			if output.Kind != persist.WatchOutputKindCoin || watchOutputStatus(output, ctx, nil) != WatchOutputStatusSpendable {
+				continue
+			}
+			candidates = append(candidates, fundingCandidate{
+				ID:     types.CoinOutputID(output.ID),
+				Output: types.CoinOutput{Value: output.Value, Condition: output.Condition},
+			})
+		}
+		if len(candidates) == 0 {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("watch entry %s has no spendable coin outputs", entry.UnlockHash.String())}, http.StatusBadRequest)
+			return
+		}
+		// send the change back to the watched condition, or the condition of the first output if no condition is known,
+		// unwrapping time lock conditions, as to not lock the change
+		changeCondition := entry.Condition
+		if changeCondition.ConditionType() == types.ConditionTypeNil {
+			changeCondition = candidates[0].Output.Condition
+		}
+		if tl, ok := changeCondition.Condition.(*types.TimeLockCondition); ok {
+			changeCondition = types.NewCondition(tl.Condition)
+		}
+
+		txn, parents, err := fundTransaction(tpool, constants, candidates, changeCondition, body.CoinOutputs, body.ArbitraryData, fee)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("watch entry %s: %v", entry.UnlockHash.String(), err)}, http.StatusBadRequest)
+			return
+		}
+		pstx := tftypes.NewPartiallySignedTransaction(txn)
+		for _, parent := range parents {
+			pstx.CoinInputs = append(pstx.CoinInputs, tftypes.PSTXParentOutput{
+				Value:     parent.Value,
+				Condition: parent.Condition,
+			})
+		}
+		if entry.Label != "" {
+			for _, uh := range tftypes.ConditionUnlockHashes(changeCondition) {
+				pstx.Signers = append(pstx.Signers, tftypes.PSTXSigner{UnlockHash: uh, Label: entry.Label})
+			}
+		}
+		api.WriteJSON(w, WalletPostWatchTransactionResponse{Transaction: pstx})
+	}
+}
+
+// getWatchEntry returns the watch-only entry for the given unlock hash,
+// writing an error to the response writer and returning false if it couldn't be found
+func getWatchEntry(w http.ResponseWriter, watchDB *persist.WatchDB, uhStr string) (persist.WatchEntry, bool) {
+	var uh types.UnlockHash
+	err := uh.LoadString(uhStr)
+	if err != nil {
+		api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid unlock hash given: %v", err)}, http.StatusBadRequest)
+		return persist.WatchEntry{}, false
+	}
+	entry, err := watchDB.Entry(uh)
+	if err != nil {
+		if err == persist.ErrWatchEntryNotFound {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("unlock hash %s is not watched by this wallet", uh.String())}, http.StatusNoContent)
+			return persist.WatchEntry{}, false
+		}
+		api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to get watch entry: %v", err)}, http.StatusInternalServerError)
+		return persist.WatchEntry{}, false
+	}
+	return entry, true
+}
+
+// unconfirmedActivity collects the coin outputs spent and created by unconfirmed transactions
+type unconfirmedActivity struct {
+	spent   map[types.OutputID]struct{}
+	created []types.CoinOutput
+}
+
+func newUnconfirmedActivity(tpool modules.TransactionPool) *unconfirmedActivity {
+	activity := &unconfirmedActivity{spent: make(map[types.OutputID]struct{})}
+	for _, txn := range tpool.TransactionList() {
+		for _, ci := range txn.CoinInputs {
+			activity.spent[types.OutputID(ci.ParentID)] = struct{}{}
+		}
+		for _, bsi := range txn.BlockStakeInputs {
+			activity.spent[types.OutputID(bsi.ParentID)] = struct{}{}
+		}
+		activity.created = append(activity.created, txn.CoinOutputs...)
+	}
+	return activity
+}
+
+// newWalletWatchEntry computes the balances and signers of the given watch-only entry,
+// returning its outputs together with their spendability status
+func newWalletWatchEntry(entry persist.WatchEntry, outputs []persist.WatchOutput, ctx types.FulfillableContext, unconfirmed *unconfirmedActivity) (WalletWatchEntry, []WalletWatchOutput) {
+	info := WalletWatchEntry{WatchEntry: entry}
+	condition := entry.Condition
+	if condition.ConditionType() == types.ConditionTypeNil && entry.UnlockHash.Type == types.UnlockTypePubKey {
+		condition = types.NewCondition(types.NewUnlockHashCondition(entry.UnlockHash))
+	}
+	if info.Signers = tftypes.ConditionUnlockHashes(condition); len(info.Signers) > 0 {
+		info.MinimumSignatures = 1
+		if ms, ok := condition.Condition.(*types.MultiSignatureCondition); ok {
+			info.MinimumSignatures = ms.MinimumSignatureCount
+		}
+	}
+
+	watchOutputs := make([]WalletWatchOutput, 0, len(outputs))
+	for _, output := range outputs {
+		status := watchOutputStatus(output, ctx, unconfirmed.spent)
+		watchOutputs = append(watchOutputs, WalletWatchOutput{WatchOutput: output, Status: status})
+		if status == WatchOutputStatusSpent {
+			continue
+		}
+		switch output.Kind {
+		case persist.WatchOutputKindCoin:
+			if status == WatchOutputStatusLocked {
+				info.ConfirmedLockedCoinBalance = info.ConfirmedLockedCoinBalance.Add(output.Value)
+			} else {
+				info.ConfirmedCoinBalance = info.ConfirmedCoinBalance.Add(output.Value)
+			}
+			if status == WatchOutputStatusPending {
+				info.UnconfirmedOutgoingCoins = info.UnconfirmedOutgoingCoins.Add(output.Value)
+			}
+		case persist.WatchOutputKindBlockStake:
+			if status == WatchOutputStatusLocked {
+				info.LockedBlockStakeBalance = info.LockedBlockStakeBalance.Add(output.Value)
+			} else {
+				info.BlockStakeBalance = info.BlockStakeBalance.Add(output.Value)
+			}
+		}
+	}
+	for _, co := range unconfirmed.created {
+		if co.Condition.UnlockHash().Cmp(entry.UnlockHash) == 0 {
+			info.UnconfirmedIncomingCoins = info.UnconfirmedIncomingCoins.Add(co.Value)
+		}
+	}
+	return info, watchOutputs
+}
+
+// watchOutputStatus returns the spendability status of the given output of a watch-only entry
+func watchOutputStatus(output persist.WatchOutput, ctx types.FulfillableContext, unconfirmedSpent map[types.OutputID]struct{}) WatchOutputStatus {
+	if output.Spent {
+		return WatchOutputStatusSpent
+	}
+	if _, ok := unconfirmedSpent[output.ID]; ok {
+		return WatchOutputStatusPending
+	}
+	if output.MaturityHeight > ctx.BlockHeight || !output.Condition.Fulfillable(ctx) {
+		return WatchOutputStatusLocked
+	}
+	return WatchOutputStatusSpendable
+}
+
+// watchHistory converts the transactions of a watch-only entry into history transactions
+func watchHistory(uh types.UnlockHash, txns []persist.WatchTransaction) []historyTransaction {
+	toFunds := func(wfs []persist.WatchFund) []historyFund {
+		funds := make([]historyFund, 0, len(wfs))
+		for _, wf := range wfs {
+			funds = append(funds, historyFund{
+				Address: wf.Address,
+				Value:   wf.Value,
+				Own:     wf.Address.Cmp(uh) == 0,
+			})
+		}
+		return funds
+	}
+	htxns := make([]historyTransaction, 0, len(txns))
+	for _, txn := range txns {
+		htxns = append(htxns, historyTransaction{
+			ID:           txn.ID,
+			BlockHeight:  txn.BlockHeight,
+			Timestamp:    txn.Timestamp,
+			Version:      txn.Version,
+			MinerPayouts: txn.MinerPayouts,
+			AtomicSwap:   txn.AtomicSwap,
+			Inputs:       toFunds(txn.Inputs),
+			Outputs:      toFunds(txn.Outputs),
+			MinerFees:    txn.MinerFees,
+		})
+	}
+	return htxns
+}
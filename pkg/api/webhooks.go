@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/threefoldfoundation/tfchain/pkg/modules/webhooks"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type (
+	// WebhooksPostWebhook is the body of a call to /webhooks,
+	// registering a new webhook.
+	WebhooksPostWebhook struct {
+		URL          string                     `json:"url"`
+		UnlockHashes []types.UnlockHash         `json:"unlockhashes,omitempty"`
+		Versions     []types.TransactionVersion `json:"versions,omitempty"`
+		// Events defaults to all events if not defined
+		Events []webhooks.EventType `json:"events,omitempty"`
+		// Confirmations defaults to webhooks.DefaultConfirmations if not defined
+		Confirmations types.BlockHeight `json:"confirmations,omitempty"`
+	}
+	// WebhooksGetWebhook contains a single webhook,
+	// its secret is only defined as a response to its registration.
+	WebhooksGetWebhook struct {
+		webhooks.Webhook
+	}
+	// WebhooksGetWebhooks contains all registered webhooks.
+	WebhooksGetWebhooks struct {
+		Webhooks []webhooks.Webhook `json:"webhooks"`
+	}
+	// WebhooksGetDeliveries contains the queued (and failed) deliveries of a single webhook.
+	WebhooksGetDeliveries struct {
+		Deliveries []webhooks.Delivery `json:"deliveries"`
+	}
+)
+
+// RegisterWebhookNotifierHTTPHandlers registers the handlers for all webhook notifier HTTP endpoints.
+// All endpoints require the API password, if one is configured.
+func RegisterWebhookNotifierHTTPHandlers(router api.Router, n *webhooks.Notifier, requiredPassword string) {
+	if n == nil {
+		panic("no webhook notifier given")
+	}
+	if router == nil {
+		panic("no httprouter Router given")
+	}
+
+	router.POST("/webhooks", api.RequirePasswordHandler(NewWebhooksPostWebhookHandler(n), requiredPassword))
+	router.GET("/webhooks", api.RequirePasswordHandler(NewWebhooksGetWebhooksHandler(n), requiredPassword))
+	router.GET("/webhooks/:id", api.RequirePasswordHandler(NewWebhooksGetWebhookHandler(n), requiredPassword))
+	router.GET("/webhooks/:id/deliveries", api.RequirePasswordHandler(NewWebhooksGetDeliveriesHandler(n), requiredPassword))
+	router.POST("/webhooks/:id/remove", api.RequirePasswordHandler(NewWebhooksPostRemoveHandler(n), requiredPassword))
+}
+
+// NewWebhooksPostWebhookHandler creates a handler to handle the API calls to /webhooks,
+// responding with the registered webhook, including the secret used to sign its notifications.
+func NewWebhooksPostWebhookHandler(n *webhooks.Notifier) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		var body WebhooksPostWebhook
+		err := json.NewDecoder(req.Body).Decode(&body)
+		if err != nil {
+			api.WriteError(w, api.Error{Message: fmt.Sprintf("error decoding the supplied webhook: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		webhook, err := n.Register(webhooks.Webhook{
+			URL:           body.URL,
+			UnlockHashes:  body.UnlockHashes,
+			Versions:      body.Versions,
+			Events:        body.Events,
+			Confirmations: body.Confirmations,
+		})
+		if err != nil {
+			api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		api.WriteJSON(w, WebhooksGetWebhook{
+			Webhook: webhook,
+		})
+	}
+}
+
+// NewWebhooksGetWebhooksHandler creates a handler to handle the API calls to /webhooks.
+func NewWebhooksGetWebhooksHandler(n *webhooks.Notifier) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		api.WriteJSON(w, WebhooksGetWebhooks{
+			Webhooks: n.Webhooks(),
+		})
+	}
+}
+
+// NewWebhooksGetWebhookHandler creates a handler to handle the API calls to /webhooks/:id.
+func NewWebhooksGetWebhookHandler(n *webhooks.Notifier) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		id, ok := loadWebhookID(w, ps.ByName("id"))
+		if !ok {
+			return
+		}
+		webhook, err := n.Webhook(id)
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		api.WriteJSON(w, WebhooksGetWebhook{
+			Webhook: webhook,
+		})
+	}
+}
+
+// NewWebhooksGetDeliveriesHandler creates a handler to handle the API calls to /webhooks/:id/deliveries.
+func NewWebhooksGetDeliveriesHandler(n *webhooks.Notifier) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		id, ok := loadWebhookID(w, ps.ByName("id"))
+		if !ok {
+			return
+		}
+		deliveries, err := n.Deliveries(id)
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		api.WriteJSON(w, WebhooksGetDeliveries{
+			Deliveries: deliveries,
+		})
+	}
+}
+
+// NewWebhooksPostRemoveHandler creates a handler to handle the API calls to /webhooks/:id/remove.
+func NewWebhooksPostRemoveHandler(n *webhooks.Notifier) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		id, ok := loadWebhookID(w, ps.ByName("id"))
+		if !ok {
+			return
+		}
+		err := n.Remove(id)
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		api.WriteSuccess(w)
+	}
+}
+
+// loadWebhookID loads the given webhook ID,
+// writing an error to the response writer and returning false if it is invalid
+func loadWebhookID(w http.ResponseWriter, str string) (crypto.Hash, bool) {
+	var id crypto.Hash
+	err := id.LoadString(str)
+	if err != nil {
+		api.WriteError(w, api.Error{Message: fmt.Sprintf("invalid webhook ID given: %v", err)}, http.StatusBadRequest)
+		return crypto.Hash{}, false
+	}
+	return id, true
+}
+
+// writeWebhookError writes the given notifier error to the response writer,
+// using the status code matching the error
+func writeWebhookError(w http.ResponseWriter, err error) {
+	if err == webhooks.ErrWebhookNotFound {
+		api.WriteError(w, api.Error{Message: err.Error()}, http.StatusNoContent)
+		return
+	}
+	api.WriteError(w, api.Error{Message: err.Error()}, http.StatusInternalServerError)
+}
@@ -0,0 +1,205 @@
+// Package client provides a typed Go client for the HTTP API of tfchaind,
+// covering the Rivine consensus, explorer, wallet, transaction pool and gateway endpoints,
+// as well as the tfchain-specific mint condition endpoints.
+//
+// All requests take a context, such that they can be cancelled or given a deadline,
+// and all errors returned by the daemon are returned as an *Error,
+// containing the HTTP status code and the message returned by the daemon.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rivine/rivine/pkg/api"
+)
+
+const (
+	// DefaultAddress is the address the daemon API is served on by default.
+	DefaultAddress = "http://localhost:23110"
+	// DefaultUserAgent is the user agent required by the daemon by default.
+	DefaultUserAgent = "Rivine-Agent"
+)
+
+// Config configures a Client.
+type Config struct {
+	// Address of the daemon API, DefaultAddress if not defined,
+	// http is used as scheme in case none is defined.
+	Address string
+	// UserAgent sent with each request, DefaultUserAgent if not defined,
+	// has to contain the user agent required by the daemon.
+	UserAgent string
+	// Password used to authenticate,
+	// only required if the daemon requires an API password.
+	Password string
+	// HTTPClient used to send the requests, http.DefaultClient if not defined.
+	HTTPClient *http.Client
+}
+
+// Client is a typed client for the HTTP API of tfchaind.
+// It is safe for concurrent use.
+type Client struct {
+	address    string
+	userAgent  string
+	password   string
+	httpClient *http.Client
+}
+
+// New creates a new client for the daemon API at the configured address.
+func New(cfg Config) (*Client, error) {
+	address := cfg.Address
+	if address == "" {
+		address = DefaultAddress
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid daemon address %q: %v", cfg.Address, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid daemon address %q: unsupported scheme %q", cfg.Address, u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid daemon address %q: no host defined", cfg.Address)
+	}
+	c := &Client{
+		address:    strings.TrimRight(u.String(), "/"),
+		userAgent:  cfg.UserAgent,
+		password:   cfg.Password,
+		httpClient: cfg.HTTPClient,
+	}
+	if c.userAgent == "" {
+		c.userAgent = DefaultUserAgent
+	}
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	return c, nil
+}
+
+// Address returns the address of the daemon API used by this client.
+func (c *Client) Address() string {
+	return c.address
+}
+
+// Error is returned for each request the daemon responded to with an error status code,
+// or with 204 (No Content) where content was expected,
+// which is what the daemon uses to indicate the requested object doesn't exist.
+type Error struct {
+	// Method and Path of the failed request
+	Method string
+	Path   string
+	// StatusCode returned by the daemon
+	StatusCode int
+	// Message returned by the daemon
+	Message string
+}
+
+// Error implements error.Error
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s %s: %d %s: %s", err.Method, err.Path,
+		err.StatusCode, http.StatusText(err.StatusCode), err.Message)
+}
+
+// IsNotFound returns true if the error indicates the requested object
+// (or the requested endpoint) doesn't exist.
+func IsNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && (e.StatusCode == http.StatusNoContent || e.StatusCode == http.StatusNotFound)
+}
+
+// IsUnauthorized returns true if the error indicates the request wasn't authenticated,
+// because of a missing or wrong API password.
+func IsUnauthorized(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == http.StatusUnauthorized
+}
+
+// get sends a GET request, decoding the response into the given result
+func (c *Client) get(ctx context.Context, path string, query url.Values, result interface{}) error {
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.do(ctx, http.MethodGet, path, "", nil, result)
+}
+
+// postForm sends a POST request with the given form values as body,
+// decoding the response into the given result, if any
+func (c *Client) postForm(ctx context.Context, path string, form url.Values, result interface{}) error {
+	return c.do(ctx, http.MethodPost, path, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), result)
+}
+
+// postJSON sends a POST request with the given JSON-encoded body,
+// decoding the response into the given result, if any
+func (c *Client) postJSON(ctx context.Context, path string, body, result interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode body of POST %s: %v", path, err)
+	}
+	return c.do(ctx, http.MethodPost, path, "application/json", strings.NewReader(string(b)), result)
+}
+
+// do sends a request to the daemon, decoding the (JSON) response into the given result, if any
+func (c *Client) do(ctx context.Context, method, path, contentType string, body io.Reader, result interface{}) error {
+	if ctx == nil {
+		return errors.New("no context given")
+	}
+	req, err := http.NewRequest(method, c.address+path, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", c.userAgent)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.password != "" {
+		req.SetBasicAuth("", c.password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// return the context error as-is, such that it can be compared against
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr api.Error
+		if json.NewDecoder(resp.Body).Decode(&apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return &Error{
+			Method:     method,
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Message:    apiErr.Message,
+		}
+	}
+	if result == nil {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return &Error{
+			Method:     method,
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Message:    "not found",
+		}
+	}
+	err = json.NewDecoder(resp.Body).Decode(result)
+	if err != nil {
+		return fmt.Errorf("failed to decode response of %s %s: %v", method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,469 @@
+package client_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	tfapi "github.com/threefoldfoundation/tfchain/pkg/api"
+	"github.com/threefoldfoundation/tfchain/pkg/client"
+	"github.com/threefoldfoundation/tfchain/pkg/client/clienttest"
+	"github.com/threefoldfoundation/tfchain/pkg/config"
+	"github.com/threefoldfoundation/tfchain/pkg/fees"
+	tftypes "github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	rivineapi "github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+)
+
+func newTestNode(t *testing.T, opts clienttest.Options) (*clienttest.Node, func()) {
+	dir, err := ioutil.TempDir("", "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := clienttest.NewNode(dir, opts)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return node, func() {
+		if err := node.Close(); err != nil {
+			t.Error(err)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+// initGenesisWallet initializes and unlocks the wallet of the node using the genesis seed
+func initGenesisWallet(t *testing.T, c *client.Client) {
+	ctx := context.Background()
+	seed, err := modules.InitialSeedFromMnemonic(clienttest.GenesisMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnemonic, err := c.WalletInit(ctx, "pass", seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mnemonic != clienttest.GenesisMnemonic {
+		t.Errorf("unexpected primary seed: %q", mnemonic)
+	}
+	err = c.WalletUnlock(ctx, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		Address         string
+		ExpectedAddress string
+		Valid           bool
+	}{
+		{"", client.DefaultAddress, true},
+		{"localhost:23110", "http://localhost:23110", true},
+		{"http://localhost:23110/", "http://localhost:23110", true},
+		{"https://example.com/api", "https://example.com/api", true},
+		{"ftp://localhost:23110", "", false},
+		{"http://", "", false},
+	}
+	for idx, testCase := range testCases {
+		c, err := client.New(client.Config{Address: testCase.Address})
+		if !testCase.Valid {
+			if err == nil {
+				t.Errorf("test case #%d: expected address %q to be invalid", idx, testCase.Address)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test case #%d: unexpected error: %v", idx, err)
+			continue
+		}
+		if c.Address() != testCase.ExpectedAddress {
+			t.Errorf("test case #%d: unexpected address: %q != %q", idx, c.Address(), testCase.ExpectedAddress)
+		}
+	}
+}
+
+func TestChainState(t *testing.T) {
+	node, cleanup := newTestNode(t, clienttest.Options{})
+	defer cleanup()
+	c, ctx := node.Client, context.Background()
+
+	cg, err := c.Consensus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cg.Height != 0 {
+		t.Errorf("unexpected consensus height: %d", cg.Height)
+	}
+	eg, err := c.Explorer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eg.BlockID != cg.CurrentBlock {
+		t.Errorf("explorer and consensus disagree on the current block: %v != %v", eg.BlockID, cg.CurrentBlock)
+	}
+	block, err := c.ExplorerBlock(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.BlockID != cg.CurrentBlock {
+		t.Errorf("unexpected genesis block: %v != %v", block.BlockID, cg.CurrentBlock)
+	}
+	hg, err := c.ExplorerHash(ctx, cg.CurrentBlock.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hg.HashType != "blockid" {
+		t.Errorf("unexpected hash type: %q", hg.HashType)
+	}
+
+	constants, err := c.DaemonConstants(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if constants.ChainInfo.NetworkName != config.NetworkNameDev {
+		t.Errorf("unexpected network name: %q", constants.ChainInfo.NetworkName)
+	}
+	version, err := c.DaemonVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.ChainVersion.String() != config.Version.String() {
+		t.Errorf("unexpected chain version: %v", version.ChainVersion)
+	}
+
+	mintCondition, err := c.MintCondition(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mintCondition.UnlockHash() != config.GetDevnetGenesisMintCondition().UnlockHash() {
+		t.Errorf("unexpected mint condition: %v", mintCondition.UnlockHash())
+	}
+	mintCondition, err = c.MintConditionAt(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mintCondition.UnlockHash() != config.GetDevnetGenesisMintCondition().UnlockHash() {
+		t.Errorf("unexpected mint condition at height 0: %v", mintCondition.UnlockHash())
+	}
+
+	gg, err := c.Gateway(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gg.Peers) != 0 {
+		t.Errorf("unexpected peers: %v", gg.Peers)
+	}
+	// the test node never connects to peers
+	err = c.GatewayConnect(ctx, "127.0.0.1:23112")
+	if _, ok := err.(*client.Error); !ok {
+		t.Errorf("expected the gateway to refuse connecting, got: %v", err)
+	}
+}
+
+func TestWalletSendCoins(t *testing.T) {
+	node, cleanup := newTestNode(t, clienttest.Options{Password: "secret"})
+	defer cleanup()
+	c, ctx := node.Client, context.Background()
+
+	initGenesisWallet(t, c)
+	wg, err := c.Wallet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wg.Unlocked {
+		t.Fatal("expected wallet to be unlocked")
+	}
+	if wg.ConfirmedCoinBalance.IsZero() {
+		t.Fatal("expected wallet to own the genesis coins")
+	}
+
+	addr, err := c.WalletAddress(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amount := config.GetCurrencyUnits().OneCoin.Mul64(42)
+	txn, err := c.WalletSendCoins(ctx, amount,
+		types.NewCondition(types.NewUnlockHashCondition(addr)), []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	txns, err := c.TransactionPoolTransactionsFor(ctx, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 1 || txns[0].ID() != txn.ID() {
+		t.Fatalf("expected sent transaction %v to be in the transaction pool, found: %v", txn.ID(), txns)
+	}
+
+	// re-adding the same transaction is refused by the transaction pool
+	_, err = c.TransactionPoolAdd(ctx, txn)
+	if e, ok := err.(*client.Error); !ok || e.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a bad request error, got: %v", err)
+	}
+}
+
+func TestTransactionPoolValidate(t *testing.T) {
+	node, cleanup := newTestNode(t, clienttest.Options{})
+	defer cleanup()
+	c, ctx := node.Client, context.Background()
+	initGenesisWallet(t, c)
+
+	unlocked, err := c.WalletUnlockedOutputs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unlocked.UnlockedCoinOutputs) == 0 {
+		t.Fatal("expected wallet to own unlocked coin outputs")
+	}
+	parent := unlocked.UnlockedCoinOutputs[0]
+	constants, err := c.DaemonConstants(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn, err := c.WalletCreateTransaction(ctx, rivineapi.WalletCreateTransactionPOST{
+		CoinInputs: []types.CoinOutputID{parent.ID},
+		CoinOutputs: []types.CoinOutput{{
+			Value:     parent.Output.Value.Sub(constants.MinimumTransactionFee),
+			Condition: parent.Output.Condition,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an unsigned transaction doesn't fulfill the condition of its input
+	report, err := c.TransactionPoolValidate(ctx, txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || len(report.CoinInputs) != 1 || report.CoinInputs[0].Valid || report.CoinInputs[0].Error == "" {
+		t.Errorf("expected the input of the unsigned transaction to be invalid: %+v", report)
+	}
+	if !report.Coins.InputSum.Equals(parent.Output.Value) || !report.Coins.OutputSum.Equals(parent.Output.Value) {
+		t.Errorf("expected the unsigned transaction to be funded: %+v", report.Coins)
+	}
+
+	// a signed transaction is valid
+	txn, err = c.WalletSign(ctx, txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = c.TransactionPoolValidate(ctx, txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.TransactionID != txn.ID() || report.MintFulfillment != nil {
+		t.Errorf("expected the signed transaction to be valid: %+v", report)
+	}
+	if len(report.CoinInputs) != 1 || report.CoinInputs[0].Source != tfapi.TransactionValidationOutputSourceConfirmed {
+		t.Errorf("expected the input to spend a confirmed output: %+v", report.CoinInputs)
+	}
+	txns, err := c.TransactionPoolTransactions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 0 {
+		t.Errorf("expected validated transaction not to be added to the transaction pool: %v", txns)
+	}
+
+	// once added to the pool, it conflicts with the pool
+	_, err = c.TransactionPoolAdd(ctx, txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = c.TransactionPoolValidate(ctx, txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.TransactionPool.Valid || !report.Standalone.Valid || !report.CoinInputs[0].Valid {
+		t.Errorf("expected only the transaction pool check to fail: %+v", report)
+	}
+
+	// a coin creation transaction without mint fulfillment is invalid
+	cctx := tftypes.CoinCreationTransaction{
+		Nonce: tftypes.RandomTransactionNonce(),
+		CoinOutputs: []types.CoinOutput{{
+			Value:     constants.MinimumTransactionFee,
+			Condition: parent.Output.Condition,
+		}},
+		MinerFees: []types.Currency{constants.MinimumTransactionFee},
+	}
+	report, err = c.TransactionPoolValidate(ctx, cctx.Transaction())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.Standalone.Valid || report.MintFulfillment == nil || report.MintFulfillment.Valid {
+		t.Errorf("expected the mint fulfillment to be invalid: %+v", report)
+	}
+
+	// an input spending an unknown output is invalid
+	txn = types.Transaction{
+		Version:     types.TransactionVersionOne,
+		CoinInputs:  []types.CoinInput{{ParentID: types.CoinOutputID{1}}},
+		CoinOutputs: []types.CoinOutput{{Value: constants.MinimumTransactionFee, Condition: parent.Output.Condition}},
+		MinerFees:   []types.Currency{constants.MinimumTransactionFee},
+	}
+	report, err = c.TransactionPoolValidate(ctx, txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.Coins.Valid || report.CoinInputs[0].Source != tfapi.TransactionValidationOutputSourceUnknown {
+		t.Errorf("expected the input to spend an unknown output: %+v", report)
+	}
+}
+
+func TestTransactionPoolFee(t *testing.T) {
+	node, cleanup := newTestNode(t, clienttest.Options{})
+	defer cleanup()
+	c, ctx := node.Client, context.Background()
+	constants, err := c.DaemonConstants(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// on an empty chain the minimum fee suffices
+	estimate, err := c.TransactionPoolFee(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estimate.TargetBlocks != fees.DefaultTargetBlocks || estimate.Size == 0 || !estimate.FeeRate.IsZero() {
+		t.Errorf("unexpected estimate: %+v", estimate)
+	}
+	if !estimate.Fee.Equals(constants.MinimumTransactionFee) {
+		t.Errorf("expected minimum fee %v, not %v", constants.MinimumTransactionFee, estimate.Fee)
+	}
+	estimate, err = c.TransactionPoolFee(ctx, 1, 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estimate.TargetBlocks != 1 || estimate.Size != 1234 {
+		t.Errorf("unexpected estimate: %+v", estimate)
+	}
+
+	// coin creation transactions are estimated using the size of the mint fulfillment of the active mint condition
+	cctxEstimate, err := c.TransactionPoolFeeForVersion(ctx, 0, tftypes.TransactionVersionCoinCreation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cctx := tftypes.CoinCreationTransaction{
+		MintFulfillment: fees.ReservedFulfillment(1),
+		CoinOutputs: []types.CoinOutput{{
+			Value:     constants.MinimumTransactionFee,
+			Condition: types.NewCondition(types.NewUnlockHashCondition(types.UnlockHash{Type: types.UnlockTypePubKey})),
+		}},
+		MinerFees: []types.Currency{constants.MinimumTransactionFee},
+	}
+	if size := uint64(len(encoding.Marshal(cctx.Transaction()))); cctxEstimate.Size != size {
+		t.Errorf("expected coin creation size of %d bytes, not %d", size, cctxEstimate.Size)
+	}
+
+	_, err = c.TransactionPoolFee(ctx, fees.MaxTargetBlocks+1, 0)
+	if e, ok := err.(*client.Error); !ok || e.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a bad request error for too many target blocks, got: %v", err)
+	}
+	_, err = c.TransactionPoolFeeForVersion(ctx, 0, 42)
+	if e, ok := err.(*client.Error); !ok || e.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a bad request error for an unknown version, got: %v", err)
+	}
+}
+
+func TestTransactionsCodec(t *testing.T) {
+	node, cleanup := newTestNode(t, clienttest.Options{})
+	defer cleanup()
+	c, ctx := node.Client, context.Background()
+
+	// coin creation transactions are encoded using the controller registered by the node
+	cctx := tftypes.CoinCreationTransaction{
+		Nonce: tftypes.RandomTransactionNonce(),
+		CoinOutputs: []types.CoinOutput{{
+			Value:     types.NewCurrency64(42),
+			Condition: types.NewCondition(types.NewUnlockHashCondition(types.UnlockHash{Type: types.UnlockTypePubKey})),
+		}},
+		MinerFees: []types.Currency{types.NewCurrency64(1)},
+	}
+	// nil fulfillments have no binary encoding
+	_, err := c.TransactionsEncode(ctx, cctx.Transaction())
+	if e, ok := err.(*client.Error); !ok || e.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a bad request error for a transaction without mint fulfillment, got: %v", err)
+	}
+	cctx.MintFulfillment = fees.ReservedFulfillment(2)
+	txn := cctx.Transaction()
+	b, err := c.TransactionsEncode(ctx, txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := encoding.Marshal(txn); string(b) != string(expected) {
+		t.Errorf("unexpected binary encoding: %x, expected: %x", b, expected)
+	}
+
+	decoded, err := c.TransactionsDecode(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.TransactionID != txn.ID() || decoded.Transaction.ID() != txn.ID() || decoded.Size != len(b) {
+		t.Errorf("unexpected decoded transaction: %+v", decoded)
+	}
+	sigHash, err := txn.InputSigHash(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mf := decoded.SigHashes.MintFulfillment; mf == nil || mf.SigHash != sigHash || len(mf.PublicKeys) != 2 {
+		t.Errorf("unexpected signature hashes: %+v", decoded.SigHashes)
+	}
+
+	_, err = c.TransactionsDecode(ctx, b[:len(b)-1])
+	if e, ok := err.(*client.Error); !ok || e.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a bad request error for a truncated transaction, got: %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	node, cleanup := newTestNode(t, clienttest.Options{Password: "secret", UserAgent: "tfchain-test"})
+	defer cleanup()
+	ctx := context.Background()
+
+	// non-existing objects are reported as not found
+	_, err := node.Client.UnspentCoinOutput(ctx, types.CoinOutputID{1})
+	if !client.IsNotFound(err) {
+		t.Errorf("expected a not found error, got: %v", err)
+	}
+
+	// a wrong password is reported as unauthorized
+	c, err := client.New(client.Config{Address: node.Address(), UserAgent: "tfchain-test", Password: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.WalletAddress(ctx)
+	if !client.IsUnauthorized(err) {
+		t.Errorf("expected an unauthorized error, got: %v", err)
+	}
+
+	// a missing user agent is refused
+	c, err = client.New(client.Config{Address: node.Address()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Consensus(ctx)
+	if e, ok := err.(*client.Error); !ok || e.StatusCode != http.StatusBadRequest || e.Message == "" {
+		t.Errorf("expected a bad request error, got: %v", err)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	node, cleanup := newTestNode(t, clienttest.Options{})
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := node.Client.Consensus(ctx)
+	if err != context.Canceled {
+		t.Errorf("expected the request to be cancelled, got: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package clienttest
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/rivine/rivine/modules"
+)
+
+// errOffline is returned by the offlineGateway for all peer-to-peer interactions
+var errOffline = errors.New("test node is offline and cannot connect to peers")
+
+// offlineGateway is a gateway which never connects to any peer.
+//
+// It is used instead of the Rivine gateway, as that gateway tries to discover
+// UPnP devices in the background, which delays its shutdown for a long time
+// unless the binary is built for testing purposes.
+type offlineGateway struct {
+	mu       sync.Mutex
+	rpcs     map[string]modules.RPCFunc
+	connects map[string]modules.RPCFunc
+}
+
+func newOfflineGateway() *offlineGateway {
+	return &offlineGateway{
+		rpcs:     make(map[string]modules.RPCFunc),
+		connects: make(map[string]modules.RPCFunc),
+	}
+}
+
+// Connect implements modules.Gateway.Connect
+func (g *offlineGateway) Connect(modules.NetAddress) error { return errOffline }
+
+// Disconnect implements modules.Gateway.Disconnect
+func (g *offlineGateway) Disconnect(modules.NetAddress) error { return errOffline }
+
+// Address implements modules.Gateway.Address
+func (g *offlineGateway) Address() modules.NetAddress { return "" }
+
+// Peers implements modules.Gateway.Peers
+func (g *offlineGateway) Peers() []modules.Peer { return nil }
+
+// RegisterRPC implements modules.Gateway.RegisterRPC
+func (g *offlineGateway) RegisterRPC(name string, fn modules.RPCFunc) {
+	g.mu.Lock()
+	g.rpcs[name] = fn
+	g.mu.Unlock()
+}
+
+// UnregisterRPC implements modules.Gateway.UnregisterRPC
+func (g *offlineGateway) UnregisterRPC(name string) {
+	g.mu.Lock()
+	delete(g.rpcs, name)
+	g.mu.Unlock()
+}
+
+// RegisterConnectCall implements modules.Gateway.RegisterConnectCall
+func (g *offlineGateway) RegisterConnectCall(name string, fn modules.RPCFunc) {
+	g.mu.Lock()
+	g.connects[name] = fn
+	g.mu.Unlock()
+}
+
+// UnregisterConnectCall implements modules.Gateway.UnregisterConnectCall
+func (g *offlineGateway) UnregisterConnectCall(name string) {
+	g.mu.Lock()
+	delete(g.connects, name)
+	g.mu.Unlock()
+}
+
+// RPC implements modules.Gateway.RPC
+func (g *offlineGateway) RPC(modules.NetAddress, string, modules.RPCFunc) error { return errOffline }
+
+// Broadcast implements modules.Gateway.Broadcast
+func (g *offlineGateway) Broadcast(string, interface{}, []modules.Peer) {}
+
+// Online implements modules.Gateway.Online
+func (g *offlineGateway) Online() bool { return false }
+
+// Close implements modules.Gateway.Close
+func (g *offlineGateway) Close() error { return nil }
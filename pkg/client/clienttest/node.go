@@ -0,0 +1,195 @@
+// Package clienttest provides an in-process tfchain devnet node,
+// serving the daemon HTTP API on a local test server,
+// such that the client package can be tested (and used in tests) without an external daemon.
+package clienttest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+
+	"github.com/threefoldfoundation/tfchain/pkg/api"
+	"github.com/threefoldfoundation/tfchain/pkg/client"
+	"github.com/threefoldfoundation/tfchain/pkg/config"
+	"github.com/threefoldfoundation/tfchain/pkg/jsonrpc"
+	"github.com/threefoldfoundation/tfchain/pkg/persist"
+	tftypes "github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/modules/consensus"
+	"github.com/rivine/rivine/modules/explorer"
+	"github.com/rivine/rivine/modules/transactionpool"
+	"github.com/rivine/rivine/modules/wallet"
+	rivineapi "github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/pkg/daemon"
+)
+
+// GenesisMnemonic is the (public) mnemonic of the seed
+// which owns all genesis coins and block stakes of the devnet.
+const GenesisMnemonic = "carbon boss inject cover mountain fetch fiber fit tornado cloth wing dinosaur proof joy intact fabric thumb rebel borrow poet chair network expire else"
+
+// Options configure a Node.
+type Options struct {
+	// Password required by the API of the node, none if not defined.
+	Password string
+	// UserAgent required by the API of the node, client.DefaultUserAgent if not defined.
+	UserAgent string
+}
+
+// Node is an in-process devnet node, without peers and without a block creator,
+// serving the same HTTP API endpoints as tfchaind for the gateway, consensus set,
+// transaction pool, wallet and explorer modules, as well as the transaction DB.
+type Node struct {
+	// Client connected to the API of the node,
+	// configured with the password and user agent the node requires
+	Client *client.Client
+
+	Gateway         modules.Gateway
+	ConsensusSet    modules.ConsensusSet
+	TransactionPool modules.TransactionPool
+	Wallet          modules.Wallet
+	Explorer        modules.Explorer
+	TransactionDB   *persist.TransactionDB
+
+	server *httptest.Server
+}
+
+// NewNode creates a new devnet node, persisting all its modules in the given directory.
+// The node has to be closed when no longer needed.
+func NewNode(dir string, opts Options) (_ *Node, err error) {
+	if opts.UserAgent == "" {
+		opts.UserAgent = client.DefaultUserAgent
+	}
+	bcInfo := config.GetBlockchainInfo()
+	bcInfo.NetworkName = config.NetworkNameDev
+	constants := config.GetDevnetGenesis()
+
+	node := new(Node)
+	defer func() {
+		if err != nil {
+			err = build.ComposeErrors(err, node.Close())
+		}
+	}()
+
+	// modules are only assigned to the node once created successfully,
+	// such that Close doesn't have to deal with typed nil values
+	txdb, err := persist.NewTransactionDB(dir, config.GetDevnetGenesisMintCondition())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction db: %v", err)
+	}
+	node.TransactionDB = txdb
+	tftypes.RegisterTransactionTypesForDevNetwork(node.TransactionDB)
+	tftypes.RegisterBlockHeightLimitedMultiSignatureCondition(0)
+
+	node.Gateway = newOfflineGateway()
+	cs, err := consensus.New(node.Gateway, false,
+		filepath.Join(dir, modules.ConsensusDir), bcInfo, constants)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consensus set: %v", err)
+	}
+	node.ConsensusSet = cs
+	err = node.TransactionDB.SubscribeToConsensusSet(node.ConsensusSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe transaction db to consensus set: %v", err)
+	}
+	tpool, err := transactionpool.New(node.ConsensusSet, node.Gateway,
+		filepath.Join(dir, modules.TransactionPoolDir), bcInfo, constants)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction pool: %v", err)
+	}
+	node.TransactionPool = tpool
+	w, err := wallet.New(node.ConsensusSet, node.TransactionPool,
+		filepath.Join(dir, modules.WalletDir), bcInfo, constants)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wallet: %v", err)
+	}
+	node.Wallet = w
+	e, err := explorer.New(node.ConsensusSet,
+		filepath.Join(dir, modules.ExplorerDir), bcInfo, constants)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create explorer: %v", err)
+	}
+	node.Explorer = e
+
+	// register the same endpoints as tfchaind does for these modules
+	router := httprouter.New()
+	api.RegisterTransactionDBHTTPHandlers(router, node.TransactionDB)
+	api.RegisterTransactionsHTTPHandlers(router)
+	rivineapi.RegisterGatewayHTTPHandlers(router, node.Gateway, opts.Password)
+	rivineapi.RegisterConsensusHTTPHandlers(router, node.ConsensusSet)
+	rivineapi.RegisterTransactionPoolHTTPHandlers(router, node.ConsensusSet, node.TransactionPool, opts.Password)
+	api.RegisterTransactionPoolHTTPHandlers(router, node.ConsensusSet, node.TransactionPool, node.TransactionDB, constants)
+	rivineapi.RegisterWalletHTTPHandlers(router, node.Wallet, opts.Password)
+	api.RegisterWalletHTTPHandlers(router, node.ConsensusSet, node.TransactionPool, node.Wallet, constants, opts.Password)
+	rivineapi.RegisterExplorerHTTPHandlers(router, node.ConsensusSet, node.Explorer, node.TransactionPool)
+	api.RegisterTransactionPoolFeeHTTPHandlers(router, node.Explorer, node.TransactionPool, node.TransactionDB, constants)
+	router.GET("/daemon/constants", func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+		rivineapi.WriteJSON(w, modules.NewDaemonConstants(bcInfo, constants))
+	})
+	router.GET("/daemon/version", func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+		rivineapi.WriteJSON(w, daemon.Version{
+			ChainVersion:    bcInfo.ChainVersion,
+			ProtocolVersion: bcInfo.ProtocolVersion,
+		})
+	})
+	// the JSON-RPC interface is served without stream, as the node creates no blocks
+	api.RegisterJSONRPCHTTPHandlers(router, jsonrpc.NewServer(router, nil))
+	node.server = httptest.NewServer(rivineapi.RequireUserAgentHandler(router, opts.UserAgent))
+
+	node.Client, err = client.New(client.Config{
+		Address:   node.server.URL,
+		UserAgent: opts.UserAgent,
+		Password:  opts.Password,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %v", err)
+	}
+	return node, nil
+}
+
+// Address returns the address the API of the node is served on.
+func (node *Node) Address() string {
+	return node.server.URL
+}
+
+// Close the API server and all modules of the node, in the reverse order they were created in.
+func (node *Node) Close() error {
+	var errs []error
+	if node.server != nil {
+		node.server.Close()
+	}
+	if node.Explorer != nil {
+		if err := node.Explorer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("explorer shutdown: %v", err))
+		}
+	}
+	if node.Wallet != nil {
+		if err := node.Wallet.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("wallet shutdown: %v", err))
+		}
+	}
+	if node.TransactionPool != nil {
+		if err := node.TransactionPool.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("transaction pool shutdown: %v", err))
+		}
+	}
+	if node.ConsensusSet != nil {
+		if err := node.ConsensusSet.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("consensus set shutdown: %v", err))
+		}
+	}
+	if node.Gateway != nil {
+		if err := node.Gateway.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("gateway shutdown: %v", err))
+		}
+	}
+	if node.TransactionDB != nil {
+		if err := node.TransactionDB.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("transaction db shutdown: %v", err))
+		}
+	}
+	return build.ComposeErrors(errs...)
+}
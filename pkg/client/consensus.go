@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	tfapi "github.com/threefoldfoundation/tfchain/pkg/api"
+	tftypes "github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+)
+
+// Consensus returns the current state of the consensus set, using /consensus.
+func (c *Client) Consensus(ctx context.Context) (api.ConsensusGET, error) {
+	var result api.ConsensusGET
+	err := c.get(ctx, "/consensus", nil, &result)
+	return result, err
+}
+
+// ConsensusTransaction returns a confirmed transaction, using /consensus/transactions/:id.
+func (c *Client) ConsensusTransaction(ctx context.Context, id types.TransactionID) (api.ConsensusGetTransaction, error) {
+	var result api.ConsensusGetTransaction
+	err := c.get(ctx, "/consensus/transactions/"+id.String(), nil, &result)
+	return result, err
+}
+
+// UnspentCoinOutput returns an unspent coin output, using /consensus/unspent/coinoutputs/:id.
+func (c *Client) UnspentCoinOutput(ctx context.Context, id types.CoinOutputID) (types.CoinOutput, error) {
+	var result api.ConsensusGetUnspentCoinOutput
+	err := c.get(ctx, "/consensus/unspent/coinoutputs/"+id.String(), nil, &result)
+	return result.Output, err
+}
+
+// UnspentBlockStakeOutput returns an unspent block stake output, using /consensus/unspent/blockstakeoutputs/:id.
+func (c *Client) UnspentBlockStakeOutput(ctx context.Context, id types.BlockStakeOutputID) (types.BlockStakeOutput, error) {
+	var result api.ConsensusGetUnspentBlockstakeOutput
+	err := c.get(ctx, "/consensus/unspent/blockstakeoutputs/"+id.String(), nil, &result)
+	return result.Output, err
+}
+
+// MintCondition returns the active mint condition, using /consensus/mintcondition.
+func (c *Client) MintCondition(ctx context.Context) (types.UnlockConditionProxy, error) {
+	var result tfapi.TransactionDBGetMintCondition
+	err := c.get(ctx, "/consensus/mintcondition", nil, &result)
+	return result.MintCondition, err
+}
+
+// MintConditionAt returns the mint condition active at the given block height,
+// using /consensus/mintcondition/:height.
+func (c *Client) MintConditionAt(ctx context.Context, height types.BlockHeight) (types.UnlockConditionProxy, error) {
+	var result tfapi.TransactionDBGetMintCondition
+	err := c.get(ctx, fmt.Sprintf("/consensus/mintcondition/%d", height), nil, &result)
+	return result.MintCondition, err
+}
+
+// MintConditionGetter returns a MintConditionGetter fetching the mint conditions from the daemon,
+// using the given context for all its requests, such that mint-type transactions can be validated.
+func (c *Client) MintConditionGetter(ctx context.Context) tftypes.MintConditionGetter {
+	return mintConditionGetter{client: c, ctx: ctx}
+}
+
+// mintConditionGetter implements tftypes.MintConditionGetter using the client
+type mintConditionGetter struct {
+	client *Client
+	ctx    context.Context
+}
+
+// GetActiveMintCondition implements tftypes.MintConditionGetter.GetActiveMintCondition
+func (mcg mintConditionGetter) GetActiveMintCondition() (types.UnlockConditionProxy, error) {
+	return mcg.client.MintCondition(mcg.ctx)
+}
+
+// GetMintConditionAt implements tftypes.MintConditionGetter.GetMintConditionAt
+func (mcg mintConditionGetter) GetMintConditionAt(height types.BlockHeight) (types.UnlockConditionProxy, error) {
+	return mcg.client.MintConditionAt(mcg.ctx, height)
+}
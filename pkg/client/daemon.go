@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/daemon"
+)
+
+// DaemonConstants returns the constants of the chain served by the daemon, using /daemon/constants.
+func (c *Client) DaemonConstants(ctx context.Context) (modules.DaemonConstants, error) {
+	var result modules.DaemonConstants
+	err := c.get(ctx, "/daemon/constants", nil, &result)
+	return result, err
+}
+
+// DaemonVersion returns the chain and protocol version of the daemon, using /daemon/version.
+func (c *Client) DaemonVersion(ctx context.Context) (daemon.Version, error) {
+	var result daemon.Version
+	err := c.get(ctx, "/daemon/version", nil, &result)
+	return result, err
+}
+
+// DaemonStop stops the daemon, using /daemon/stop.
+func (c *Client) DaemonStop(ctx context.Context) error {
+	return c.postForm(ctx, "/daemon/stop", nil, nil)
+}
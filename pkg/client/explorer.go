@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+)
+
+// Explorer returns the facts of the current block, using /explorer.
+func (c *Client) Explorer(ctx context.Context) (api.ExplorerGET, error) {
+	var result api.ExplorerGET
+	err := c.get(ctx, "/explorer", nil, &result)
+	return result, err
+}
+
+// ExplorerBlock returns the block at the given height, using /explorer/blocks/:height.
+func (c *Client) ExplorerBlock(ctx context.Context, height types.BlockHeight) (api.ExplorerBlock, error) {
+	var result api.ExplorerBlockGET
+	err := c.get(ctx, fmt.Sprintf("/explorer/blocks/%d", height), nil, &result)
+	return result.Block, err
+}
+
+// ExplorerHash looks up the object identified by the given hash,
+// which can be a block ID, transaction ID, output ID or unlock hash, using /explorer/hashes/:hash.
+// The type of the object found is defined by the HashType of the result.
+func (c *Client) ExplorerHash(ctx context.Context, hash string) (api.ExplorerHashGET, error) {
+	var result api.ExplorerHashGET
+	err := c.get(ctx, "/explorer/hashes/"+url.PathEscape(hash), nil, &result)
+	return result, err
+}
+
+// ExplorerHistoryStats returns the chain statistics of the given amount of most recent blocks,
+// using /explorer/stats/history.
+func (c *Client) ExplorerHistoryStats(ctx context.Context, history types.BlockHeight) (modules.ChainStats, error) {
+	var result modules.ChainStats
+	err := c.get(ctx, "/explorer/stats/history", url.Values{
+		"history": {fmt.Sprint(history)},
+	}, &result)
+	return result, err
+}
+
+// ExplorerRangeStats returns the chain statistics of the blocks in the given height range,
+// using /explorer/stats/range.
+func (c *Client) ExplorerRangeStats(ctx context.Context, start, end types.BlockHeight) (modules.ChainStats, error) {
+	var result modules.ChainStats
+	err := c.get(ctx, "/explorer/stats/range", url.Values{
+		"start": {fmt.Sprint(start)},
+		"end":   {fmt.Sprint(end)},
+	}, &result)
+	return result, err
+}
+
+// ExplorerConstants returns the constants of the chain, as known by the explorer, using /explorer/constants.
+func (c *Client) ExplorerConstants(ctx context.Context) (modules.DaemonConstants, error) {
+	var result modules.DaemonConstants
+	err := c.get(ctx, "/explorer/constants", nil, &result)
+	return result, err
+}
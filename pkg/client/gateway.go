@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/api"
+)
+
+// Gateway returns the address and connected peers of the gateway, using /gateway.
+func (c *Client) Gateway(ctx context.Context) (api.GatewayGET, error) {
+	var result api.GatewayGET
+	err := c.get(ctx, "/gateway", nil, &result)
+	return result, err
+}
+
+// GatewayConnect connects the gateway to the given peer, using /gateway/connect/:netaddress.
+func (c *Client) GatewayConnect(ctx context.Context, addr modules.NetAddress) error {
+	return c.postForm(ctx, "/gateway/connect/"+url.PathEscape(string(addr)), nil, nil)
+}
+
+// GatewayDisconnect disconnects the gateway from the given peer, using /gateway/disconnect/:netaddress.
+func (c *Client) GatewayDisconnect(ctx context.Context, addr modules.NetAddress) error {
+	return c.postForm(ctx, "/gateway/disconnect/"+url.PathEscape(string(addr)), nil, nil)
+}
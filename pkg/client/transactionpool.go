@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	tfapi "github.com/threefoldfoundation/tfchain/pkg/api"
+
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+)
+
+// TransactionPoolTransactions returns all unconfirmed transactions, using /transactionpool/transactions.
+func (c *Client) TransactionPoolTransactions(ctx context.Context) ([]types.Transaction, error) {
+	var result api.TransactionPoolGET
+	err := c.get(ctx, "/transactionpool/transactions", nil, &result)
+	return result.Transactions, err
+}
+
+// TransactionPoolTransactionsFor returns the unconfirmed transactions
+// which send to or spend from the given unlock hash, using /transactionpool/transactions.
+func (c *Client) TransactionPoolTransactionsFor(ctx context.Context, uh types.UnlockHash) ([]types.Transaction, error) {
+	var result api.TransactionPoolGET
+	err := c.get(ctx, "/transactionpool/transactions", url.Values{
+		"unlockhash": {uh.String()},
+	}, &result)
+	return result.Transactions, err
+}
+
+// TransactionPoolAdd adds a (signed) transaction to the transaction pool, using /transactionpool/transactions,
+// returning its ID.
+func (c *Client) TransactionPoolAdd(ctx context.Context, txn types.Transaction) (types.TransactionID, error) {
+	var result api.TransactionPoolPOST
+	err := c.postJSON(ctx, "/transactionpool/transactions", txn, &result)
+	return result.TransactionID, err
+}
+
+// TransactionPoolValidate validates a transaction as the transaction pool would, without adding it to the pool,
+// using /transactionpool/validate. The returned report is valid only if the transaction would be accepted.
+func (c *Client) TransactionPoolValidate(ctx context.Context, txn types.Transaction) (tfapi.TransactionPoolPostValidateResponse, error) {
+	var result tfapi.TransactionPoolPostValidateResponse
+	err := c.postJSON(ctx, "/transactionpool/validate", txn, &result)
+	return result, err
+}
+
+// TransactionPoolFee estimates the fee a transaction of the given size (in bytes) has to pay,
+// in order to be confirmed within the target amount of blocks, using /transactionpool/fee.
+// A zero target or size uses the default target of the daemon, respectively the size of a typical transaction.
+func (c *Client) TransactionPoolFee(ctx context.Context, targetBlocks, size uint64) (tfapi.TransactionPoolGetFeeResponse, error) {
+	query := url.Values{}
+	if targetBlocks != 0 {
+		query.Set("blocks", strconv.FormatUint(targetBlocks, 10))
+	}
+	if size != 0 {
+		query.Set("size", strconv.FormatUint(size, 10))
+	}
+	var result tfapi.TransactionPoolGetFeeResponse
+	err := c.get(ctx, "/transactionpool/fee", query, &result)
+	return result, err
+}
+
+// TransactionPoolFeeForVersion estimates the fee a typical transaction of the given version has to pay,
+// in order to be confirmed within the target amount of blocks, using /transactionpool/fee.
+func (c *Client) TransactionPoolFeeForVersion(ctx context.Context, targetBlocks uint64, version types.TransactionVersion) (tfapi.TransactionPoolGetFeeResponse, error) {
+	query := url.Values{"version": {strconv.FormatUint(uint64(version), 10)}}
+	if targetBlocks != 0 {
+		query.Set("blocks", strconv.FormatUint(targetBlocks, 10))
+	}
+	var result tfapi.TransactionPoolGetFeeResponse
+	err := c.get(ctx, "/transactionpool/fee", query, &result)
+	return result, err
+}
@@ -0,0 +1,29 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+
+	tfapi "github.com/threefoldfoundation/tfchain/pkg/api"
+
+	"github.com/rivine/rivine/types"
+)
+
+// TransactionsDecode decodes a binary-encoded transaction of any version, using /transactions/decode,
+// returning the decoded transaction together with its ID and the signature hashes of its inputs.
+func (c *Client) TransactionsDecode(ctx context.Context, b []byte) (tfapi.TransactionsPostDecodeResponse, error) {
+	var result tfapi.TransactionsPostDecodeResponse
+	err := c.postJSON(ctx, "/transactions/decode", tfapi.TransactionsPostDecode{Hex: hex.EncodeToString(b)}, &result)
+	return result, err
+}
+
+// TransactionsEncode encodes a transaction of any version using /transactions/encode,
+// returning its binary encoding.
+func (c *Client) TransactionsEncode(ctx context.Context, txn types.Transaction) ([]byte, error) {
+	var result tfapi.TransactionsPostEncodeResponse
+	err := c.postJSON(ctx, "/transactions/encode", txn, &result)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(result.Hex)
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+)
+
+// Wallet returns the state and balances of the wallet, using /wallet.
+func (c *Client) Wallet(ctx context.Context) (api.WalletGET, error) {
+	var result api.WalletGET
+	err := c.get(ctx, "/wallet", nil, &result)
+	return result, err
+}
+
+// WalletBlockStakeStats returns the block stake statistics of the wallet, using /wallet/blockstakestats.
+func (c *Client) WalletBlockStakeStats(ctx context.Context) (api.WalletBlockStakeStatsGET, error) {
+	var result api.WalletBlockStakeStatsGET
+	err := c.get(ctx, "/wallet/blockstakestats", nil, &result)
+	return result, err
+}
+
+// WalletAddress generates a new address of the wallet, using /wallet/address.
+func (c *Client) WalletAddress(ctx context.Context) (types.UnlockHash, error) {
+	var result api.WalletAddressGET
+	err := c.get(ctx, "/wallet/address", nil, &result)
+	return result.Address, err
+}
+
+// WalletAddresses returns all addresses of the wallet, using /wallet/addresses.
+func (c *Client) WalletAddresses(ctx context.Context) ([]types.UnlockHash, error) {
+	var result api.WalletAddressesGET
+	err := c.get(ctx, "/wallet/addresses", nil, &result)
+	return result.Addresses, err
+}
+
+// WalletBackup backs up the wallet to the given destination on the daemon's file system,
+// using /wallet/backup.
+func (c *Client) WalletBackup(ctx context.Context, destination string) error {
+	return c.get(ctx, "/wallet/backup", url.Values{
+		"destination": {destination},
+	}, nil)
+}
+
+// WalletInit initializes the wallet, encrypting it using the given passphrase, using /wallet/init.
+// A new seed is generated by the daemon if the given seed is the zero seed.
+// The primary seed is returned as a mnemonic.
+func (c *Client) WalletInit(ctx context.Context, passphrase string, seed modules.Seed) (string, error) {
+	form := url.Values{"passphrase": {passphrase}}
+	if seed != (modules.Seed{}) {
+		form.Set("seed", seed.String())
+	}
+	var result api.WalletInitPOST
+	err := c.postForm(ctx, "/wallet/init", form, &result)
+	return result.PrimarySeed, err
+}
+
+// WalletLoadSeed adds the seed, given as a mnemonic, to the wallet, using /wallet/seed.
+func (c *Client) WalletLoadSeed(ctx context.Context, passphrase, mnemonic string) error {
+	return c.postForm(ctx, "/wallet/seed", url.Values{
+		"passphrase": {passphrase},
+		"mnemonic":   {mnemonic},
+	}, nil)
+}
+
+// WalletSeeds returns the seeds of the wallet, as mnemonics, using /wallet/seeds.
+func (c *Client) WalletSeeds(ctx context.Context) (api.WalletSeedsGET, error) {
+	var result api.WalletSeedsGET
+	err := c.get(ctx, "/wallet/seeds", nil, &result)
+	return result, err
+}
+
+// WalletKey returns the key pair of an address of the wallet, using /wallet/key/:unlockhash.
+func (c *Client) WalletKey(ctx context.Context, uh types.UnlockHash) (api.WalletKeyGet, error) {
+	var result api.WalletKeyGet
+	err := c.get(ctx, "/wallet/key/"+uh.String(), nil, &result)
+	return result, err
+}
+
+// WalletLock locks the wallet, using /wallet/lock.
+func (c *Client) WalletLock(ctx context.Context) error {
+	return c.postForm(ctx, "/wallet/lock", nil, nil)
+}
+
+// WalletUnlock unlocks the wallet using the given passphrase, using /wallet/unlock.
+func (c *Client) WalletUnlock(ctx context.Context, passphrase string) error {
+	return c.postForm(ctx, "/wallet/unlock", url.Values{
+		"passphrase": {passphrase},
+	}, nil)
+}
+
+// WalletSendCoins sends the given amount of coins to the given condition,
+// optionally with arbitrary data, using /wallet/transaction.
+// The created (and broadcasted) transaction is returned.
+func (c *Client) WalletSendCoins(ctx context.Context, amount types.Currency, condition types.UnlockConditionProxy, data []byte) (types.Transaction, error) {
+	var result api.WalletTransactionPOSTResponse
+	err := c.postJSON(ctx, "/wallet/transaction", api.WalletTransactionPOST{
+		Condition: condition,
+		Amount:    amount,
+		Data:      string(data),
+	}, &result)
+	return result.Transaction, err
+}
+
+// WalletSendCoinOutputs sends coins to the given coin outputs, using /wallet/coins,
+// returning the ID of the created (and broadcasted) transaction.
+func (c *Client) WalletSendCoinOutputs(ctx context.Context, outputs []types.CoinOutput) (types.TransactionID, error) {
+	var result api.WalletCoinsPOSTResp
+	err := c.postJSON(ctx, "/wallet/coins", api.WalletCoinsPOST{
+		CoinOutputs: outputs,
+	}, &result)
+	return result.TransactionID, err
+}
+
+// WalletSendBlockStakeOutputs sends block stakes to the given block stake outputs, using /wallet/blockstakes,
+// returning the ID of the created (and broadcasted) transaction.
+func (c *Client) WalletSendBlockStakeOutputs(ctx context.Context, outputs []types.BlockStakeOutput) (types.TransactionID, error) {
+	var result api.WalletBlockStakesPOSTResp
+	err := c.postJSON(ctx, "/wallet/blockstakes", api.WalletBlockStakesPOST{
+		BlockStakeOutputs: outputs,
+	}, &result)
+	return result.TransactionID, err
+}
+
+// WalletSendData sends arbitrary data to the given address, using /wallet/data,
+// returning the ID of the created (and broadcasted) transaction.
+func (c *Client) WalletSendData(ctx context.Context, destination types.UnlockHash, data []byte) (types.TransactionID, error) {
+	var result api.WalletCoinsPOSTResp
+	err := c.postForm(ctx, "/wallet/data", url.Values{
+		"destination": {destination.String()},
+		"data":        {base64.StdEncoding.EncodeToString(data)},
+	}, &result)
+	return result.TransactionID, err
+}
+
+// WalletTransaction returns a transaction of the wallet, using /wallet/transaction/:id.
+func (c *Client) WalletTransaction(ctx context.Context, id types.TransactionID) (modules.ProcessedTransaction, error) {
+	var result api.WalletTransactionGETid
+	err := c.get(ctx, "/wallet/transaction/"+id.String(), nil, &result)
+	return result.Transaction, err
+}
+
+// WalletTransactions returns the confirmed transactions of the wallet within the given height range,
+// as well as all its unconfirmed transactions, using /wallet/transactions.
+func (c *Client) WalletTransactions(ctx context.Context, start, end types.BlockHeight) (api.WalletTransactionsGET, error) {
+	var result api.WalletTransactionsGET
+	err := c.get(ctx, "/wallet/transactions", url.Values{
+		"startheight": {fmt.Sprint(start)},
+		"endheight":   {fmt.Sprint(end)},
+	}, &result)
+	return result, err
+}
+
+// WalletAddressTransactions returns the transactions of the wallet related to the given address,
+// using /wallet/transactions/:addr.
+func (c *Client) WalletAddressTransactions(ctx context.Context, uh types.UnlockHash) (api.WalletTransactionsGETaddr, error) {
+	var result api.WalletTransactionsGETaddr
+	err := c.get(ctx, "/wallet/transactions/"+uh.String(), nil, &result)
+	return result, err
+}
+
+// WalletUnlockedOutputs returns the unspent outputs of the wallet which can be spent, using /wallet/unlocked.
+func (c *Client) WalletUnlockedOutputs(ctx context.Context) (api.WalletListUnlockedGET, error) {
+	var result api.WalletListUnlockedGET
+	err := c.get(ctx, "/wallet/unlocked", nil, &result)
+	return result, err
+}
+
+// WalletLockedOutputs returns the unspent outputs of the wallet which are still locked, using /wallet/locked.
+func (c *Client) WalletLockedOutputs(ctx context.Context) (api.WalletListLockedGET, error) {
+	var result api.WalletListLockedGET
+	err := c.get(ctx, "/wallet/locked", nil, &result)
+	return result, err
+}
+
+// WalletCreateTransaction creates an unsigned transaction spending the given inputs to the given outputs,
+// using /wallet/create/transaction.
+func (c *Client) WalletCreateTransaction(ctx context.Context, body api.WalletCreateTransactionPOST) (types.Transaction, error) {
+	var result api.WalletCreateTransactionRESP
+	err := c.postJSON(ctx, "/wallet/create/transaction", body, &result)
+	return result.Transaction, err
+}
+
+// WalletSign signs all inputs of the given transaction the wallet has the keys for, using /wallet/sign.
+func (c *Client) WalletSign(ctx context.Context, txn types.Transaction) (types.Transaction, error) {
+	var result types.Transaction
+	err := c.postJSON(ctx, "/wallet/sign", txn, &result)
+	return result, err
+}
@@ -0,0 +1,216 @@
+// Package fees estimates the miner fee a transaction has to pay in order to be confirmed
+// within a target amount of blocks, based on the unconfirmed transactions
+// and the fill rate of the most recent blocks.
+//
+// The estimation assumes block creators prefer transactions paying the highest fee per byte,
+// such that a transaction has to outbid the unconfirmed transactions which wouldn't fit
+// in the target amount of blocks, and the transactions which barely made it in the recent full blocks.
+// As long as blocks aren't full, the minimum transaction fee suffices.
+package fees
+
+import (
+	"math"
+	"sort"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/types"
+)
+
+const (
+	// DefaultTargetBlocks is the amount of blocks a transaction is to be confirmed within,
+	// if no target is defined.
+	DefaultTargetBlocks = 3
+	// MaxTargetBlocks is the maximum amount of blocks a transaction can be targeted to be confirmed within.
+	MaxTargetBlocks = 100
+	// DefaultRecentBlocks is the amount of most recent blocks
+	// whose fill rate is taken into account by default.
+	DefaultRecentBlocks = 20
+
+	// FullBlockFill is the fill rate from which a block is considered full.
+	FullBlockFill = 0.9
+
+	// blockOverhead is the size of a block reserved for everything but its transactions,
+	// see types.TransactionFitsInABlock
+	blockOverhead = 5e3
+)
+
+type (
+	// Transaction is a transaction as taken into account by the estimation,
+	// either unconfirmed or part of a recent block.
+	Transaction struct {
+		// Size of the binary-encoded transaction, in bytes
+		Size uint64
+		// Fee is the sum of all miner fees paid by the transaction
+		Fee types.Currency
+	}
+
+	// Block is a recent block as taken into account by the estimation.
+	Block struct {
+		// Size of the binary-encoded block, in bytes
+		Size         uint64
+		Transactions []Transaction
+	}
+
+	// Estimator estimates the fee of transactions for a chain.
+	Estimator struct {
+		BlockSizeLimit uint64
+		MinimumFee     types.Currency
+	}
+
+	// Estimate is the result of a fee estimation.
+	Estimate struct {
+		// TargetBlocks is the amount of blocks the transaction is to be confirmed within
+		TargetBlocks uint64 `json:"targetblocks"`
+		// FeeRate is the fee to pay per byte, in order to be confirmed within the target amount of blocks,
+		// zero if the minimum fee suffices
+		FeeRate types.Currency `json:"feerate"`
+		// MinimumFee is the minimum fee each transaction has to pay
+		MinimumFee types.Currency `json:"minimumfee"`
+
+		// PoolTransactions and PoolSize define the amount and total size (in bytes)
+		// of the unconfirmed transactions
+		PoolTransactions int    `json:"pooltransactions"`
+		PoolSize         uint64 `json:"poolsize"`
+		// RecentBlocks is the amount of recent blocks taken into account,
+		// of which FullBlocks were full, and which are filled for AverageBlockFill (between 0 and 1) on average
+		RecentBlocks     int     `json:"recentblocks"`
+		FullBlocks       int     `json:"fullblocks"`
+		AverageBlockFill float64 `json:"averageblockfill"`
+	}
+)
+
+// NewTransaction creates the estimation representation of a transaction.
+func NewTransaction(txn types.Transaction) Transaction {
+	t := Transaction{Size: uint64(len(encoding.Marshal(txn)))}
+	for _, fee := range txn.MinerFees {
+		t.Fee = t.Fee.Add(fee)
+	}
+	return t
+}
+
+// FeeRate returns the fee paid by the transaction per byte.
+func (t Transaction) FeeRate() types.Currency {
+	if t.Size == 0 {
+		return t.Fee
+	}
+	return t.Fee.Div64(t.Size)
+}
+
+// NewBlock creates the estimation representation of a block.
+func NewBlock(b types.Block) Block {
+	block := Block{
+		Size:         uint64(len(encoding.Marshal(b))),
+		Transactions: make([]Transaction, 0, len(b.Transactions)),
+	}
+	for _, txn := range b.Transactions {
+		block.Transactions = append(block.Transactions, NewTransaction(txn))
+	}
+	return block
+}
+
+// ReservedFulfillment returns a fulfillment as big as a (multi)signature fulfillment signed by the given amount of signers,
+// used to compute the size of an unsigned transaction, once it is signed.
+func ReservedFulfillment(signers int) types.UnlockFulfillmentProxy {
+	pk := types.Ed25519PublicKey(crypto.PublicKey{})
+	signature := make(types.ByteSlice, crypto.SignatureSize)
+	if signers <= 1 {
+		return types.NewFulfillment(&types.SingleSignatureFulfillment{PublicKey: pk, Signature: signature})
+	}
+	fulfillment := &types.MultiSignatureFulfillment{}
+	for i := 0; i < signers; i++ {
+		fulfillment.Pairs = append(fulfillment.Pairs, types.PublicKeySignaturePair{PublicKey: pk, Signature: signature})
+	}
+	return types.NewFulfillment(fulfillment)
+}
+
+// Fee returns the fee a transaction of the given size (in bytes) has to pay,
+// which is at least the minimum fee.
+func (e Estimate) Fee(size uint64) types.Currency {
+	fee := e.FeeRate.Mul64(size)
+	if fee.Cmp(e.MinimumFee) < 0 {
+		return e.MinimumFee
+	}
+	return fee
+}
+
+// Estimate the fee rate a transaction of the given size has to pay in order to be confirmed
+// within the target amount of blocks, given the unconfirmed transactions and the most recent blocks.
+func (e Estimator) Estimate(targetBlocks, size uint64, pool []Transaction, recent []Block) Estimate {
+	if targetBlocks == 0 {
+		targetBlocks = DefaultTargetBlocks
+	} else if targetBlocks > MaxTargetBlocks {
+		targetBlocks = MaxTargetBlocks
+	}
+	estimate := Estimate{
+		TargetBlocks:     targetBlocks,
+		MinimumFee:       e.MinimumFee,
+		PoolTransactions: len(pool),
+		RecentBlocks:     len(recent),
+	}
+
+	// outbid the unconfirmed transactions which wouldn't fit in the target amount of blocks,
+	// should block creators take the transactions with the highest fee rate first
+	var capacity uint64
+	if e.BlockSizeLimit > blockOverhead {
+		capacity = (e.BlockSizeLimit - blockOverhead) * targetBlocks
+	}
+	sorted := make([]Transaction, len(pool))
+	copy(sorted, pool)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].FeeRate().Cmp(sorted[j].FeeRate()) > 0
+	})
+	filled := size
+	var poolRate types.Currency
+	for _, txn := range sorted {
+		estimate.PoolSize += txn.Size
+		filled += txn.Size
+		if filled > capacity && poolRate.IsZero() {
+			poolRate = txn.FeeRate().Add(types.NewCurrency64(1))
+		}
+	}
+
+	// take into account the lowest fee rate which still made it in the recent full blocks,
+	// in case it is likely that all blocks within the target are full as well
+	var (
+		totalFill float64
+		fullRates []types.Currency
+	)
+	for _, block := range recent {
+		fill := 1.0
+		if e.BlockSizeLimit > 0 {
+			fill = math.Min(1, float64(block.Size)/float64(e.BlockSizeLimit))
+		}
+		totalFill += fill
+		if fill < FullBlockFill || len(block.Transactions) == 0 {
+			continue
+		}
+		estimate.FullBlocks++
+		lowest := block.Transactions[0].FeeRate()
+		for _, txn := range block.Transactions[1:] {
+			if rate := txn.FeeRate(); rate.Cmp(lowest) < 0 {
+				lowest = rate
+			}
+		}
+		fullRates = append(fullRates, lowest)
+	}
+	var blockRate types.Currency
+	if len(recent) > 0 {
+		estimate.AverageBlockFill = totalFill / float64(len(recent))
+		// the chance that all blocks within the target are full,
+		// assuming they are as likely to be full as the recent blocks
+		pFull := math.Pow(float64(estimate.FullBlocks)/float64(len(recent)), float64(targetBlocks))
+		if pFull > 0.5 && len(fullRates) > 0 {
+			sort.Slice(fullRates, func(i, j int) bool {
+				return fullRates[i].Cmp(fullRates[j]) < 0
+			})
+			blockRate = fullRates[len(fullRates)/2]
+		}
+	}
+
+	estimate.FeeRate = poolRate
+	if blockRate.Cmp(estimate.FeeRate) > 0 {
+		estimate.FeeRate = blockRate
+	}
+	return estimate
+}
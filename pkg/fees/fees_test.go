@@ -0,0 +1,92 @@
+package fees
+
+import (
+	"testing"
+
+	"github.com/rivine/rivine/types"
+)
+
+func TestEstimateEmptyChain(t *testing.T) {
+	e := Estimator{BlockSizeLimit: 2e6, MinimumFee: types.NewCurrency64(1e9)}
+	estimate := e.Estimate(0, 500, nil, nil)
+	if estimate.TargetBlocks != DefaultTargetBlocks {
+		t.Errorf("expected default target of %d blocks, not %d", DefaultTargetBlocks, estimate.TargetBlocks)
+	}
+	if !estimate.FeeRate.IsZero() {
+		t.Errorf("expected zero fee rate, not %v", estimate.FeeRate)
+	}
+	if fee := estimate.Fee(500); !fee.Equals64(1e9) {
+		t.Errorf("expected minimum fee, not %v", fee)
+	}
+	if estimate = e.Estimate(MaxTargetBlocks+1, 500, nil, nil); estimate.TargetBlocks != MaxTargetBlocks {
+		t.Errorf("expected target to be capped at %d blocks, not %d", MaxTargetBlocks, estimate.TargetBlocks)
+	}
+}
+
+func TestEstimateFullPool(t *testing.T) {
+	// room for 2 transactions of 1000 bytes per block
+	e := Estimator{BlockSizeLimit: 7e3, MinimumFee: types.NewCurrency64(1e3)}
+	pool := []Transaction{
+		{Size: 1000, Fee: types.NewCurrency64(1e3)},  // 1/byte
+		{Size: 1000, Fee: types.NewCurrency64(5e3)},  // 5/byte
+		{Size: 1000, Fee: types.NewCurrency64(10e3)}, // 10/byte
+		{Size: 1000, Fee: types.NewCurrency64(2e3)},  // 2/byte
+	}
+
+	// within 1 block only the 10/byte transaction would still be included next to ours
+	estimate := e.Estimate(1, 1000, pool, nil)
+	if !estimate.FeeRate.Equals64(6) {
+		t.Errorf("expected fee rate of 6, not %v", estimate.FeeRate)
+	}
+	if fee := estimate.Fee(1000); !fee.Equals64(6e3) {
+		t.Errorf("expected fee of 6000, not %v", fee)
+	}
+	if estimate.PoolTransactions != 4 || estimate.PoolSize != 4000 {
+		t.Errorf("unexpected pool stats: %d transactions, %d bytes", estimate.PoolTransactions, estimate.PoolSize)
+	}
+
+	// within 2 blocks only the 1/byte transaction has to be outbid
+	if estimate = e.Estimate(2, 1000, pool, nil); !estimate.FeeRate.Equals64(2) {
+		t.Errorf("expected fee rate of 2, not %v", estimate.FeeRate)
+	}
+	// within 3 blocks all transactions fit
+	if estimate = e.Estimate(3, 1000, pool, nil); !estimate.FeeRate.IsZero() {
+		t.Errorf("expected zero fee rate, not %v", estimate.FeeRate)
+	}
+	if fee := estimate.Fee(1000); !fee.Equals64(1e3) {
+		t.Errorf("expected minimum fee, not %v", fee)
+	}
+}
+
+func TestEstimateFullBlocks(t *testing.T) {
+	e := Estimator{BlockSizeLimit: 10e3, MinimumFee: types.NewCurrency64(1)}
+	fullBlock := func(lowestRate uint64) Block {
+		return Block{
+			Size: 9500,
+			Transactions: []Transaction{
+				{Size: 1000, Fee: types.NewCurrency64(100e3)},
+				{Size: 1000, Fee: types.NewCurrency64(lowestRate * 1000)},
+			},
+		}
+	}
+	emptyBlock := Block{Size: 200}
+
+	recent := []Block{fullBlock(3), fullBlock(7), fullBlock(5), fullBlock(9)}
+	estimate := e.Estimate(2, 1000, nil, recent)
+	if estimate.FullBlocks != 4 || estimate.RecentBlocks != 4 {
+		t.Errorf("unexpected block stats: %d out of %d blocks full", estimate.FullBlocks, estimate.RecentBlocks)
+	}
+	if estimate.AverageBlockFill != 0.95 {
+		t.Errorf("expected average block fill of 0.95, not %v", estimate.AverageBlockFill)
+	}
+	// median of the lowest rates 3, 5, 7 and 9
+	if !estimate.FeeRate.Equals64(7) {
+		t.Errorf("expected fee rate of 7, not %v", estimate.FeeRate)
+	}
+
+	// when only some recent blocks are full, it is unlikely that all blocks within the target will be
+	recent = []Block{fullBlock(3), emptyBlock, fullBlock(5), emptyBlock}
+	if estimate = e.Estimate(2, 1000, nil, recent); !estimate.FeeRate.IsZero() {
+		t.Errorf("expected zero fee rate, not %v", estimate.FeeRate)
+	}
+}
@@ -0,0 +1,207 @@
+// Package jsonrpc serves the daemon HTTP API as a JSON-RPC 2.0 interface,
+// over HTTP (POST /rpc) as well as over a websocket (GET /rpc/ws).
+//
+// Each method maps onto the REST route serving the same functionality (see DefaultMethods),
+// such that both interfaces always behave the same, including the routes which require the API password.
+// Batch requests and notifications are supported, while websocket clients can
+// subscribe to new blocks, using the rpc_subscribe and rpc_unsubscribe methods,
+// resuming a subscription since the last consensus change they received.
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Version is the JSON-RPC version implemented.
+const Version = "2.0"
+
+// All error codes returned.
+const (
+	// CodeParseError is returned for invalid JSON.
+	CodeParseError = -32700
+	// CodeInvalidRequest is returned for a JSON object that isn't a valid request.
+	CodeInvalidRequest = -32600
+	// CodeMethodNotFound is returned for a method that doesn't exist or isn't available,
+	// as the module serving it isn't loaded.
+	CodeMethodNotFound = -32601
+	// CodeInvalidParams is returned for invalid method params,
+	// including params rejected by the REST route (400 Bad Request).
+	CodeInvalidParams = -32602
+	// CodeInternalError is returned for internal errors of the JSON-RPC server itself.
+	CodeInternalError = -32603
+
+	// CodeServerError is returned for all other errors returned by a REST route.
+	CodeServerError = -32000
+	// CodeUnauthorized is returned when the API password is required but not given or invalid (401 Unauthorized).
+	CodeUnauthorized = -32001
+	// CodeForbidden is returned when the action isn't allowed, such as when the wallet is locked (403 Forbidden).
+	CodeForbidden = -32003
+	// CodeNotFound is returned when the requested object doesn't exist (404 Not Found).
+	CodeNotFound = -32004
+)
+
+type (
+	// Request is a JSON-RPC request, or a notification in case it has no ID.
+	Request struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id,omitempty"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params,omitempty"`
+	}
+
+	// Response is the JSON-RPC response to a request,
+	// defining either a result or an error.
+	Response struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  json.RawMessage `json:"result,omitempty"`
+		Error   *Error          `json:"error,omitempty"`
+	}
+
+	// Error is the error of a JSON-RPC response.
+	Error struct {
+		Code    int        `json:"code"`
+		Message string     `json:"message"`
+		Data    *ErrorData `json:"data,omitempty"`
+	}
+
+	// ErrorData is the data of an error returned by a REST route.
+	ErrorData struct {
+		// HTTPStatus is the status code returned by the REST route
+		HTTPStatus int `json:"httpstatus"`
+	}
+
+	// Notification is a JSON-RPC notification sent by the server,
+	// used to deliver the events of a subscription to a websocket client.
+	Notification struct {
+		JSONRPC string      `json:"jsonrpc"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params"`
+	}
+)
+
+// Error implements error.Error
+func (err *Error) Error() string {
+	return fmt.Sprintf("JSON-RPC error %d: %s", err.Code, err.Message)
+}
+
+func newError(code int, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// caller calls a method with the given (raw) params, returning its result.
+// An error which isn't an *Error is returned as an internal error.
+type caller func(method string, params json.RawMessage) (interface{}, error)
+
+// handleMessage handles a single or batch request message, calling each method using the given caller,
+// returning the encoded response, or nil in case there is nothing to respond as only notifications were received.
+func handleMessage(msg []byte, call caller) []byte {
+	msg = bytes.TrimSpace(msg)
+	if len(msg) > 0 && msg[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(msg, &batch); err != nil {
+			return encodeResponse(Response{Error: newError(CodeParseError, "invalid JSON: %v", err)})
+		}
+		if len(batch) == 0 {
+			return encodeResponse(Response{Error: newError(CodeInvalidRequest, "empty batch")})
+		}
+		var responses []Response
+		for _, raw := range batch {
+			if resp, ok := handleRequest(raw, call); ok {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return encodeResponse(responses)
+	}
+	if !json.Valid(msg) {
+		return encodeResponse(Response{Error: newError(CodeParseError, "invalid JSON")})
+	}
+	resp, ok := handleRequest(msg, call)
+	if !ok {
+		return nil
+	}
+	return encodeResponse(resp)
+}
+
+// handleRequest handles a single request, returning false in case it is a notification
+func handleRequest(raw json.RawMessage, call caller) (Response, bool) {
+	req, isNotification, err := decodeRequest(raw)
+	if err != nil {
+		return Response{ID: req.ID, Error: err}, true
+	}
+	result, callErr := call(req.Method, req.Params)
+	if isNotification {
+		return Response{}, false
+	}
+	if callErr != nil {
+		rpcErr, ok := callErr.(*Error)
+		if !ok {
+			rpcErr = newError(CodeInternalError, "%v", callErr)
+		}
+		return Response{ID: req.ID, Error: rpcErr}, true
+	}
+	b, ok := result.(json.RawMessage)
+	if !ok {
+		b, err := json.Marshal(result)
+		if err != nil {
+			return Response{ID: req.ID, Error: newError(CodeInternalError, "failed to encode result: %v", err)}, true
+		}
+		return Response{ID: req.ID, Result: b}, true
+	}
+	if len(b) == 0 {
+		b = json.RawMessage("null")
+	}
+	return Response{ID: req.ID, Result: b}, true
+}
+
+// decodeRequest decodes and validates a single request,
+// a request without ID (which is not the same as a null ID) is a notification
+func decodeRequest(raw json.RawMessage) (req Request, isNotification bool, err *Error) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(raw, &fields) != nil {
+		return Request{}, false, newError(CodeInvalidRequest, "request has to be a JSON object")
+	}
+	id, hasID := fields["id"]
+	if hasID {
+		switch trimmed := bytes.TrimSpace(id); {
+		case len(trimmed) == 0:
+		case trimmed[0] == '"', trimmed[0] == '-', trimmed[0] >= '0' && trimmed[0] <= '9', string(trimmed) == "null":
+			req.ID = trimmed
+		default:
+			return Request{}, false, newError(CodeInvalidRequest, "ID has to be a string, number or null")
+		}
+	}
+	if json.Unmarshal(raw, &req) != nil {
+		return Request{ID: req.ID}, false, newError(CodeInvalidRequest, "invalid request")
+	}
+	if req.JSONRPC != Version {
+		return req, false, newError(CodeInvalidRequest, "jsonrpc has to be %q", Version)
+	}
+	if req.Method == "" {
+		return req, false, newError(CodeInvalidRequest, "no method given")
+	}
+	return req, !hasID, nil
+}
+
+func encodeResponse(resp interface{}) []byte {
+	switch r := resp.(type) {
+	case Response:
+		r.JSONRPC = Version
+		resp = r
+	case []Response:
+		for i := range r {
+			r[i].JSONRPC = Version
+		}
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		// cannot happen, as all results are valid JSON
+		panic(err)
+	}
+	return b
+}
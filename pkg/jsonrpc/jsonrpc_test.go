@@ -0,0 +1,421 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/threefoldfoundation/tfchain/pkg/modules/stream"
+	"github.com/threefoldfoundation/tfchain/pkg/modules/stream/streamtest"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/pkg/api"
+	"github.com/rivine/rivine/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const testPassword = "secret"
+
+// newTestRouter creates a router serving a few routes in the same way as the daemon does
+func newTestRouter() *httprouter.Router {
+	router := httprouter.New()
+	router.GET("/consensus", func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+		api.WriteJSON(w, map[string]interface{}{"height": 42})
+	})
+	router.GET("/consensus/transactions/:id", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		if ps.ByName("id") == "unknown" {
+			api.WriteError(w, api.Error{Message: "transaction not found"}, http.StatusNotFound)
+			return
+		}
+		api.WriteJSON(w, map[string]string{"id": ps.ByName("id"), "versions": req.FormValue("versions")})
+	})
+	router.POST("/transactionpool/transactions", api.RequirePasswordHandler(
+		func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+			var txn map[string]interface{}
+			if err := json.NewDecoder(req.Body).Decode(&txn); err != nil {
+				api.WriteError(w, api.Error{Message: err.Error()}, http.StatusBadRequest)
+				return
+			}
+			api.WriteJSON(w, txn)
+		}, testPassword))
+	router.POST("/wallet/lock", func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+		api.WriteSuccess(w)
+	})
+	return router
+}
+
+func postRPC(srv *Server, body string, password string) (int, string) {
+	req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+	if password != "" {
+		req.SetBasicAuth("", password)
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec.Code, strings.TrimSpace(rec.Body.String())
+}
+
+func TestServeHTTP(t *testing.T) {
+	srv := NewServer(newTestRouter(), nil)
+	testCases := []struct {
+		Body     string
+		Password string
+		Response string
+	}{
+		{
+			`{"jsonrpc":"2.0","id":1,"method":"consensus_getState"}`, "",
+			`{"jsonrpc":"2.0","id":1,"result":{"height":42}}`,
+		},
+		// named and positional path params, arrays are comma-separated in the query
+		{
+			`{"jsonrpc":"2.0","id":"a","method":"consensus_getTransaction","params":{"id":"abc","versions":[1,129]}}`, "",
+			`{"jsonrpc":"2.0","id":"a","result":{"id":"abc","versions":"1,129"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":2,"method":"consensus_getTransaction","params":["abc"]}`, "",
+			`{"jsonrpc":"2.0","id":2,"result":{"id":"abc","versions":""}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":3,"method":"consensus_getTransaction","params":["abc","def"]}`, "",
+			`{"jsonrpc":"2.0","id":3,"error":{"code":-32602,"message":"at most 1 positional params can be given, use named params instead"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":4,"method":"consensus_getTransaction"}`, "",
+			`{"jsonrpc":"2.0","id":4,"error":{"code":-32602,"message":"missing param \"id\""}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":5,"method":"consensus_getTransaction","params":{"id":"unknown"}}`, "",
+			`{"jsonrpc":"2.0","id":5,"error":{"code":-32004,"message":"transaction not found","data":{"httpstatus":404}}}`,
+		},
+		// the API password is required by the route
+		{
+			`{"jsonrpc":"2.0","id":6,"method":"transactionpool_addTransaction","params":{"transaction":{"version":1}}}`, "",
+			`{"jsonrpc":"2.0","id":6,"error":{"code":-32001,"message":"API authentication failed.","data":{"httpstatus":401}}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":7,"method":"transactionpool_addTransaction","params":[{"version":1}]}`, testPassword,
+			`{"jsonrpc":"2.0","id":7,"result":{"version":1}}`,
+		},
+		// routes which aren't registered or don't exist
+		{
+			`{"jsonrpc":"2.0","id":8,"method":"explorer_getState"}`, "",
+			`{"jsonrpc":"2.0","id":8,"error":{"code":-32601,"message":"method \"explorer_getState\" is not available, as the module serving it is not loaded"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":9,"method":"foo"}`, "",
+			`{"jsonrpc":"2.0","id":9,"error":{"code":-32601,"message":"method \"foo\" not found"}}`,
+		},
+		// a route without response body
+		{
+			`{"jsonrpc":"2.0","id":null,"method":"wallet_lock"}`, "",
+			`{"jsonrpc":"2.0","id":null,"result":null}`,
+		},
+		// invalid requests
+		{
+			`{"jsonrpc":"2.0","id":1,"method":`, "",
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"invalid JSON"}}`,
+		},
+		{
+			`{"jsonrpc":"1.0","id":1,"method":"consensus_getState"}`, "",
+			`{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"jsonrpc has to be \"2.0\""}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":{},"method":"consensus_getState"}`, "",
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"ID has to be a string, number or null"}}`,
+		},
+		{
+			`[]`, "",
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"empty batch"}}`,
+		},
+		// batches, without responses for notifications
+		{
+			`[{"jsonrpc":"2.0","id":1,"method":"consensus_getState"},{"jsonrpc":"2.0","method":"consensus_getState"},1]`, "",
+			`[{"jsonrpc":"2.0","id":1,"result":{"height":42}},{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"request has to be a JSON object"}}]`,
+		},
+	}
+	for idx, testCase := range testCases {
+		status, resp := postRPC(srv, testCase.Body, testCase.Password)
+		if status != http.StatusOK {
+			t.Errorf("test case #%d: unexpected status %d", idx, status)
+		}
+		if resp != testCase.Response {
+			t.Errorf("test case #%d: unexpected response:\n%s\nexpected:\n%s", idx, resp, testCase.Response)
+		}
+	}
+
+	// nothing is returned when only notifications are received
+	status, resp := postRPC(srv, `[{"jsonrpc":"2.0","method":"consensus_getState"},{"jsonrpc":"2.0","method":"foo"}]`, "")
+	if status != http.StatusNoContent || resp != "" {
+		t.Errorf("unexpected response to notifications: %d %q", status, resp)
+	}
+}
+
+func TestServeWebsocket(t *testing.T) {
+	router := newTestRouter()
+	srv := NewServer(router, nil)
+	router.GET("/rpc/ws", func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		srv.ServeWebsocket(w, req)
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// a plain HTTP request is not a websocket handshake
+	resp, err := http.Get(ts.URL + "/rpc/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %d for a plain HTTP request, not %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	conn, r := dialTestWebsocket(t, ts.URL+"/rpc/ws")
+	defer conn.Close()
+
+	testCases := []struct {
+		Request  string
+		Response string
+	}{
+		{
+			`{"jsonrpc":"2.0","id":1,"method":"consensus_getState"}`,
+			`{"jsonrpc":"2.0","id":1,"result":{"height":42}}`,
+		},
+		// a large batch request, fragmented in multiple frames
+		{
+			`[` + strings.Repeat(`{"jsonrpc":"2.0","method":"consensus_getState"},`, 2000) + `{"jsonrpc":"2.0","id":2,"method":"consensus_getState"}]`,
+			`[{"jsonrpc":"2.0","id":2,"result":{"height":42}}]`,
+		},
+		// no subscriptions without stream
+		{
+			`{"jsonrpc":"2.0","id":3,"method":"rpc_subscribe","params":["newBlocks"]}`,
+			`{"jsonrpc":"2.0","id":3,"error":{"code":-32601,"message":"subscriptions are not available, as the daemon has no transaction pool"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":4,"method":"rpc_subscribe","params":{"topic":"newTransactions"}}`,
+			`{"jsonrpc":"2.0","id":4,"error":{"code":-32602,"message":"unknown topic \"newTransactions\", only \"newBlocks\" is supported"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":5,"method":"rpc_unsubscribe","params":["0x1"]}`,
+			`{"jsonrpc":"2.0","id":5,"result":false}`,
+		},
+	}
+	for idx, testCase := range testCases {
+		// a ping is answered first
+		writeTestFrame(t, conn, true, opPing, []byte("ping"))
+		msg := []byte(testCase.Request)
+		half := len(msg) / 2
+		writeTestFrame(t, conn, false, opText, msg[:half])
+		writeTestFrame(t, conn, true, opContinuation, msg[half:])
+
+		if opcode, payload := readTestFrame(t, r); opcode != opPong || string(payload) != "ping" {
+			t.Fatalf("test case #%d: expected pong, not frame %d: %q", idx, opcode, payload)
+		}
+		if opcode, payload := readTestFrame(t, r); opcode != opText || string(payload) != testCase.Response {
+			t.Errorf("test case #%d: unexpected response (opcode %d):\n%s\nexpected:\n%s", idx, opcode, payload, testCase.Response)
+		}
+	}
+
+	// the close frame is echoed, after which the connection is closed
+	writeTestFrame(t, conn, true, opClose, []byte{0x03, 0xe8})
+	if opcode, payload := readTestFrame(t, r); opcode != opClose || !bytes.Equal(payload, []byte{0x03, 0xe8}) {
+		t.Errorf("expected close frame, not frame %d: %q", opcode, payload)
+	}
+	if b, err := ioutil.ReadAll(r); err != nil || len(b) != 0 {
+		t.Errorf("expected connection to be closed, read %q (err: %v)", b, err)
+	}
+}
+
+func TestServeWebsocketSubscriptionResume(t *testing.T) {
+	const n = streamtest.PastChanges
+	s, err := streamtest.NewStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	router := newTestRouter()
+	srv := NewServer(router, s)
+	router.GET("/rpc/ws", func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		srv.ServeWebsocket(w, req)
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	conn, r := dialTestWebsocket(t, ts.URL+"/rpc/ws")
+	defer conn.Close()
+
+	heights, changes := subscribeTestBlocks(t, conn, r, `{"jsonrpc":"2.0","id":1,"method":"rpc_subscribe","params":{"topic":"newBlocks","since":"beginning"}}`, n)
+	for idx, height := range heights {
+		if height != types.BlockHeight(idx) {
+			t.Fatalf("block #%d: unexpected height %d", idx, height)
+		}
+		if changes[idx] == nil {
+			t.Fatalf("block #%d: no consensus change ID notified", idx)
+		}
+	}
+	writeTestFrame(t, conn, true, opText, []byte(`{"jsonrpc":"2.0","id":2,"method":"rpc_unsubscribe","params":["0x1"]}`))
+	if _, payload := readTestFrame(t, r); string(payload) != `{"jsonrpc":"2.0","id":2,"result":true}` {
+		t.Fatalf("unexpected response to rpc_unsubscribe: %s", payload)
+	}
+
+	// resume more than a queue of consensus changes back, by position
+	resumed, resumedChanges := subscribeTestBlocks(t, conn, r, `{"jsonrpc":"2.0","id":3,"method":"rpc_subscribe","params":["newBlocks","`+changes[999].String()+`"]}`, n-1000)
+	for idx, height := range resumed {
+		if height != heights[1000+idx] || *resumedChanges[idx] != *changes[1000+idx] {
+			t.Fatalf("resumed block #%d: expected height %d (%s), not %d (%v)", idx, heights[1000+idx], changes[1000+idx], height, resumedChanges[idx])
+		}
+	}
+
+	testCases := []struct {
+		Request  string
+		Response string
+	}{
+		{
+			`{"jsonrpc":"2.0","id":4,"method":"rpc_subscribe","params":{"topic":"newBlocks","since":"` + crypto.HashObject("unknown").String() + `"}}`,
+			`{"jsonrpc":"2.0","id":4,"error":{"code":-32602,"message":"unknown consensus change ID to resume from"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":5,"method":"rpc_subscribe","params":["newBlocks","recent","beginning"]}`,
+			`{"jsonrpc":"2.0","id":5,"error":{"code":-32602,"message":"expected 1 to 2 positional params"}}`,
+		},
+	}
+	for idx, testCase := range testCases {
+		writeTestFrame(t, conn, true, opText, []byte(testCase.Request))
+		if _, payload := readTestFrame(t, r); string(payload) != testCase.Response {
+			t.Errorf("test case #%d: unexpected response:\n%s\nexpected:\n%s", idx, payload, testCase.Response)
+		}
+	}
+}
+
+// dialTestWebsocket connects to the given websocket URL, returning the connection and its reader
+func dialTestWebsocket(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	u := strings.TrimPrefix(url, "http://")
+	host, path := u[:strings.Index(u, "/")], u[strings.Index(u, "/"):]
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	const key = "dGhlIHNhbXBsZSBub25jZQ=="
+	io.WriteString(conn, "GET "+path+" HTTP/1.1\r\nHost: localhost\r\nConnection: keep-alive, Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: "+key+"\r\n\r\n")
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		conn.Close()
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		t.Fatalf("expected status %d, not %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	// example of RFC 6455, section 1.3
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected Sec-WebSocket-Accept header %q", accept)
+	}
+	return conn, r
+}
+
+// subscribeTestBlocks sends the given rpc_subscribe request, returning the heights of the given amount of blocks notified,
+// as well as the consensus change ID notified with each block, if any
+func subscribeTestBlocks(t *testing.T, conn net.Conn, r io.Reader, request string, n int) ([]types.BlockHeight, []*crypto.Hash) {
+	t.Helper()
+	writeTestFrame(t, conn, true, opText, []byte(request))
+	var (
+		subscribed bool
+		heights    = make([]types.BlockHeight, 0, n)
+		changes    = make([]*crypto.Hash, 0, n)
+	)
+	// notifications can be received before the response
+	for !subscribed || len(heights) < n {
+		_, payload := readTestFrame(t, r)
+		var msg struct {
+			Method string `json:"method"`
+			Error  *Error `json:"error"`
+			Params struct {
+				Result          *stream.BlockEvent `json:"result"`
+				ConsensusChange *crypto.Hash       `json:"consensuschange"`
+				Error           *Error             `json:"error"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			t.Fatalf("invalid message %q: %v", payload, err)
+		}
+		switch {
+		case msg.Method == "":
+			if msg.Error != nil {
+				t.Fatalf("failed to subscribe: %s", payload)
+			}
+			subscribed = true
+		case msg.Params.Error != nil || msg.Params.Result == nil:
+			t.Fatalf("subscription closed after %d blocks: %s", len(heights), payload)
+		default:
+			heights = append(heights, msg.Params.Result.Height)
+			changes = append(changes, msg.Params.ConsensusChange)
+		}
+	}
+	return heights, changes
+}
+
+// writeTestFrame writes a masked frame, as clients do
+func writeTestFrame(t *testing.T, w io.Writer, fin bool, opcode byte, payload []byte) {
+	var header []byte
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	switch {
+	case len(payload) <= 125:
+		header = []byte{first, 0x80 | byte(len(payload))}
+	case len(payload) <= 0xffff:
+		header = []byte{first, 0x80 | 126, 0, 0}
+		binary.BigEndian.PutUint16(header[2:], uint16(len(payload)))
+	default:
+		header = make([]byte, 10)
+		header[0], header[1] = first, 0x80|127
+		binary.BigEndian.PutUint64(header[2:], uint64(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	masked := make([]byte, len(payload))
+	for i := range payload {
+		masked[i] = payload[i] ^ mask[i%4]
+	}
+	if _, err := w.Write(append(append(header, mask...), masked...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readTestFrame reads an unmasked frame, as servers send
+func readTestFrame(t *testing.T, r io.Reader) (byte, []byte) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			t.Fatal(err)
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			t.Fatal(err)
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0f, payload
+}
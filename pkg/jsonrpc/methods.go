@@ -0,0 +1,124 @@
+package jsonrpc
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Method maps a JSON-RPC method onto the REST route (of the daemon HTTP API) serving it.
+//
+// Params named after a placeholder of the path (e.g. id for /consensus/transactions/:id) are used as that path parameter,
+// while the other params are sent as the JSON body of the route in case Body is true,
+// or as query parameters otherwise (which are also read by routes using form values).
+// In case BodyParam is defined, the value of that param is sent as the JSON body instead.
+// Positional params are only supported for the path params (in order) and the body param (as last param).
+type Method struct {
+	HTTPMethod string
+	Path       string
+	Body       bool
+	BodyParam  string
+}
+
+// positionalParams returns the names of the params which can be given by position, in order
+func (m Method) positionalParams() []string {
+	var names []string
+	for _, part := range strings.Split(m.Path, "/") {
+		if strings.HasPrefix(part, ":") {
+			names = append(names, part[1:])
+		}
+	}
+	if m.BodyParam != "" {
+		names = append(names, m.BodyParam)
+	}
+	return names
+}
+
+func get(path string) Method {
+	return Method{HTTPMethod: http.MethodGet, Path: path}
+}
+
+func post(path string) Method {
+	return Method{HTTPMethod: http.MethodPost, Path: path}
+}
+
+func postBody(path string) Method {
+	return Method{HTTPMethod: http.MethodPost, Path: path, Body: true}
+}
+
+func postBodyParam(path, param string) Method {
+	return Method{HTTPMethod: http.MethodPost, Path: path, BodyParam: param}
+}
+
+// DefaultMethods returns the JSON-RPC methods served by default,
+// covering the chain queries, mint condition lookups, transaction submission and wallet operations of the REST API.
+// Methods whose route requires the API password require it over JSON-RPC as well,
+// while methods of modules which aren't loaded return a method not found error.
+func DefaultMethods() map[string]Method {
+	return map[string]Method{
+		// daemon
+		"daemon_getConstants": get("/daemon/constants"),
+		"daemon_getVersion":   get("/daemon/version"),
+		"daemon_getHealth":    get("/daemon/health"),
+		"daemon_getReady":     get("/daemon/ready"),
+
+		// gateway
+		"gateway_getState": get("/gateway"),
+
+		// consensus set
+		"consensus_getState":                   get("/consensus"),
+		"consensus_getTransaction":             get("/consensus/transactions/:id"),
+		"consensus_getUnspentCoinOutput":       get("/consensus/unspent/coinoutputs/:id"),
+		"consensus_getUnspentBlockStakeOutput": get("/consensus/unspent/blockstakeoutputs/:id"),
+		"consensus_getMintCondition":           get("/consensus/mintcondition"),
+		"consensus_getMintConditionAt":         get("/consensus/mintcondition/:height"),
+
+		// explorer
+		"explorer_getState":               get("/explorer"),
+		"explorer_getConstants":           get("/explorer/constants"),
+		"explorer_getBlock":               get("/explorer/blocks/:height"),
+		"explorer_getHash":                get("/explorer/hashes/:hash"),
+		"explorer_getHistory":             get("/explorer/history/:unlockhash"),
+		"explorer_getHistoryStats":        get("/explorer/stats/history"),
+		"explorer_getRangeStats":          get("/explorer/stats/range"),
+		"explorer_getSupplyBreakdown":     get("/explorer/supply/breakdown"),
+		"explorer_getSupplyBreakdownAt":   get("/explorer/supply/breakdown/:height"),
+		"explorer_getAtomicSwapContracts": get("/explorer/atomicswaps"),
+		"explorer_getAtomicSwapContract":  get("/explorer/atomicswaps/:id"),
+
+		// transaction pool
+		"transactionpool_getTransactions":     get("/transactionpool/transactions"),
+		"transactionpool_addTransaction":      postBodyParam("/transactionpool/transactions", "transaction"),
+		"transactionpool_validateTransaction": postBodyParam("/transactionpool/validate", "transaction"),
+		"transactionpool_getFee":              get("/transactionpool/fee"),
+
+		// transactions
+		"transactions_decode": postBody("/transactions/decode"),
+		"transactions_encode": postBodyParam("/transactions/encode", "transaction"),
+
+		// wallet
+		"wallet_getState":                  get("/wallet"),
+		"wallet_getBlockStakeStats":        get("/wallet/blockstakestats"),
+		"wallet_getAddress":                get("/wallet/address"),
+		"wallet_getAddresses":              get("/wallet/addresses"),
+		"wallet_getSeeds":                  get("/wallet/seeds"),
+		"wallet_getKey":                    get("/wallet/key/:unlockhash"),
+		"wallet_init":                      post("/wallet/init"),
+		"wallet_recoverSeed":               post("/wallet/seed"),
+		"wallet_unlock":                    post("/wallet/unlock"),
+		"wallet_lock":                      post("/wallet/lock"),
+		"wallet_getUnlocked":               get("/wallet/unlocked"),
+		"wallet_getLocked":                 get("/wallet/locked"),
+		"wallet_getTransaction":            get("/wallet/transaction/:id"),
+		"wallet_getTransactions":           get("/wallet/transactions"),
+		"wallet_getAddressTransactions":    get("/wallet/transactions/:addr"),
+		"wallet_getHistory":                get("/wallet/history"),
+		"wallet_sendCoins":                 postBody("/wallet/coins"),
+		"wallet_sendBlockStakes":           postBody("/wallet/blockstakes"),
+		"wallet_sendData":                  post("/wallet/data"),
+		"wallet_createTransaction":         postBody("/wallet/create/transaction"),
+		"wallet_sendCoinsToCondition":      postBody("/wallet/transaction"),
+		"wallet_signTransaction":           postBodyParam("/wallet/sign", "transaction"),
+		"wallet_getMultiSigTransactions":   get("/wallet/multisig/:address/transactions"),
+		"wallet_createMultiSigTransaction": postBody("/wallet/multisig/:address/transaction"),
+	}
+}
@@ -0,0 +1,303 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/threefoldfoundation/tfchain/pkg/modules/stream"
+
+	"github.com/rivine/rivine/pkg/api"
+)
+
+// MaxMessageSize is the maximum size of a single (batch) request message.
+const MaxMessageSize = 4 << 20
+
+// MethodListMethods lists the names of all methods served, including the subscription methods (websocket only).
+const MethodListMethods = "rpc_methods"
+
+// Server serves the JSON-RPC interface, calling the REST routes of the given handler.
+type Server struct {
+	handler http.Handler
+	methods map[string]Method
+	stream  *stream.Stream
+}
+
+// NewServer creates a new JSON-RPC server, serving the default methods using the given handler,
+// which is expected to serve the REST routes of the daemon HTTP API.
+// The stream is optional and used for websocket subscriptions, which are unavailable if not given.
+func NewServer(handler http.Handler, s *stream.Stream) *Server {
+	if handler == nil {
+		panic("no HTTP handler given")
+	}
+	return &Server{
+		handler: handler,
+		methods: DefaultMethods(),
+		stream:  s,
+	}
+}
+
+// ServeHTTP serves a single or batch request, POSTed as the body of the HTTP request.
+// The Authorization header of the HTTP request is used to call the routes which require the API password.
+// No content is returned in case only notifications are received.
+func (srv *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	msg, err := ioutil.ReadAll(io.LimitReader(req.Body, MaxMessageSize+1))
+	if err != nil {
+		api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to read request: %v", err)}, http.StatusBadRequest)
+		return
+	}
+	if len(msg) > MaxMessageSize {
+		api.WriteError(w, api.Error{Message: fmt.Sprintf("request exceeds the maximum size of %d bytes", MaxMessageSize)},
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+	resp := handleMessage(msg, func(method string, params json.RawMessage) (interface{}, error) {
+		return srv.call(req, method, params)
+	})
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// methodNames returns the sorted names of all methods served
+func (srv *Server) methodNames() []string {
+	names := []string{MethodListMethods, MethodSubscribe, MethodUnsubscribe}
+	for name := range srv.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// call a method, by calling the REST route it maps onto,
+// using the context and authorization of the original HTTP request
+func (srv *Server) call(orig *http.Request, method string, params json.RawMessage) (interface{}, error) {
+	if method == MethodListMethods {
+		return srv.methodNames(), nil
+	}
+	m, ok := srv.methods[method]
+	if !ok {
+		return nil, newError(CodeMethodNotFound, "method %q not found", method)
+	}
+	req, err := m.newRequest(orig.Context(), params)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range []string{"Authorization", "User-Agent"} {
+		if value := orig.Header.Get(header); value != "" {
+			req.Header.Set(header, value)
+		}
+	}
+	rec := &responseRecorder{header: make(http.Header)}
+	srv.handler.ServeHTTP(rec, req)
+	return rec.result(method)
+}
+
+// newRequest creates the HTTP request of the REST route, using the given params
+func (m Method) newRequest(ctx context.Context, raw json.RawMessage) (*http.Request, error) {
+	params, err := m.loadParams(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// fill in the path params
+	parts := strings.Split(m.Path, "/")
+	for i, part := range parts {
+		if !strings.HasPrefix(part, ":") {
+			continue
+		}
+		name := part[1:]
+		value, ok := params[name]
+		if !ok {
+			return nil, newError(CodeInvalidParams, "missing param %q", name)
+		}
+		delete(params, name)
+		str, err := paramString(value)
+		if err != nil || str == "" {
+			return nil, newError(CodeInvalidParams, "invalid param %q: has to be a non-empty string or number", name)
+		}
+		parts[i] = url.PathEscape(str)
+	}
+	path := strings.Join(parts, "/")
+
+	// define the body (if any) and the query params
+	var body []byte
+	switch {
+	case m.BodyParam != "":
+		value, ok := params[m.BodyParam]
+		if !ok {
+			return nil, newError(CodeInvalidParams, "missing param %q", m.BodyParam)
+		}
+		delete(params, m.BodyParam)
+		body = value
+	case m.Body:
+		body, err = json.Marshal(params)
+		if err != nil {
+			return nil, newError(CodeInvalidParams, "invalid params: %v", err)
+		}
+		params = nil
+	}
+	query := url.Values{}
+	for name, value := range params {
+		str, err := paramString(value)
+		if err != nil {
+			return nil, newError(CodeInvalidParams, "invalid param %q: %v", name, err)
+		}
+		query.Set(name, str)
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(m.HTTPMethod, path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req.WithContext(ctx), nil
+}
+
+// loadParams loads the params by name, given either by name or by position,
+// null params are ignored as if they weren't given
+func (m Method) loadParams(raw json.RawMessage) (map[string]json.RawMessage, error) {
+	params := make(map[string]json.RawMessage)
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return params, nil
+	}
+	if raw[0] == '[' {
+		var positional []json.RawMessage
+		if err := json.Unmarshal(raw, &positional); err != nil {
+			return nil, newError(CodeInvalidParams, "invalid params: %v", err)
+		}
+		names := m.positionalParams()
+		if len(positional) > len(names) {
+			return nil, newError(CodeInvalidParams, "at most %d positional params can be given, use named params instead", len(names))
+		}
+		for i, value := range positional {
+			params[names[i]] = value
+		}
+	} else if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, newError(CodeInvalidParams, "params have to be an array or object")
+	}
+	for name, value := range params {
+		if string(bytes.TrimSpace(value)) == "null" {
+			delete(params, name)
+		}
+	}
+	return params, nil
+}
+
+// paramString returns the string representation of a param as used in a path or query,
+// the value of a string, the comma-separated values of an array and the JSON encoding of any other value
+func paramString(value json.RawMessage) (string, error) {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 {
+		return "", nil
+	}
+	switch value[0] {
+	case '"':
+		var str string
+		err := json.Unmarshal(value, &str)
+		return str, err
+	case '[':
+		var values []json.RawMessage
+		if err := json.Unmarshal(value, &values); err != nil {
+			return "", err
+		}
+		strs := make([]string, 0, len(values))
+		for _, value := range values {
+			str, err := paramString(value)
+			if err != nil {
+				return "", err
+			}
+			strs = append(strs, str)
+		}
+		return strings.Join(strs, ","), nil
+	default:
+		return string(value), nil
+	}
+}
+
+// responseRecorder records the response of a REST route
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header implements http.ResponseWriter.Header
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+// Write implements http.ResponseWriter.Write
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
+
+// WriteHeader implements http.ResponseWriter.WriteHeader
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+// result returns the recorded response as the result of the given method,
+// the (JSON) body of a successful response, or an error for all other responses
+func (rec *responseRecorder) result(method string) (interface{}, error) {
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	body := bytes.TrimSpace(rec.body.Bytes())
+	if status >= 200 && status < 300 {
+		if len(body) == 0 {
+			return nil, nil
+		}
+		if !json.Valid(body) {
+			return string(body), nil
+		}
+		return json.RawMessage(body), nil
+	}
+
+	var apiErr api.Error
+	if err := json.Unmarshal(body, &apiErr); err != nil || apiErr.Message == "" {
+		// only the router itself returns a non-JSON error,
+		// for routes which aren't registered as their module isn't loaded
+		if status == http.StatusNotFound || status == http.StatusMethodNotAllowed {
+			return nil, newError(CodeMethodNotFound, "method %q is not available, as the module serving it is not loaded", method)
+		}
+		apiErr.Message = string(body)
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(status)
+		}
+	}
+	code := CodeServerError
+	switch status {
+	case http.StatusBadRequest:
+		code = CodeInvalidParams
+	case http.StatusUnauthorized:
+		code = CodeUnauthorized
+	case http.StatusForbidden:
+		code = CodeForbidden
+	case http.StatusNotFound:
+		code = CodeNotFound
+	}
+	return nil, &Error{Code: code, Message: apiErr.Message, Data: &ErrorData{HTTPStatus: status}}
+}
@@ -0,0 +1,256 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/threefoldfoundation/tfchain/pkg/modules/stream"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+)
+
+// All subscription methods, only available over a websocket connection.
+const (
+	// MethodSubscribe subscribes to a topic, returning the ID of the subscription.
+	MethodSubscribe = "rpc_subscribe"
+	// MethodUnsubscribe closes a subscription, given its ID.
+	MethodUnsubscribe = "rpc_unsubscribe"
+	// MethodSubscription is the method of the notifications sent for each event of a subscription.
+	MethodSubscription = "rpc_subscription"
+)
+
+// TopicNewBlocks is the topic to subscribe to in order to be notified of each block applied to the chain,
+// the result of each notification being a stream.BlockEvent.
+const TopicNewBlocks = "newBlocks"
+
+// SubscribeSinceBeginning can be given as the consensus change to subscribe since,
+// in order to be notified of all blocks of the chain, the genesis block included.
+const SubscribeSinceBeginning = "beginning"
+
+type (
+	// SubscribeParams are the params of the rpc_subscribe method,
+	// which can be given by position as well.
+	SubscribeParams struct {
+		Topic string `json:"topic"`
+		// Since optionally defines the ID of the last consensus change received,
+		// in order to resume a subscription, or SubscribeSinceBeginning.
+		// Only new blocks are notified if not given.
+		Since string `json:"since,omitempty"`
+	}
+
+	// UnsubscribeParams are the params of the rpc_unsubscribe method,
+	// which can be given by position as well.
+	UnsubscribeParams struct {
+		Subscription string `json:"subscription"`
+	}
+
+	// SubscriptionParams are the params of a rpc_subscription notification,
+	// defining the result of an event, or the error the subscription was closed for.
+	// The notification of the last block applied by a consensus change defines the ID of that change,
+	// which can be given as the since param of rpc_subscribe to resume the subscription.
+	SubscriptionParams struct {
+		Subscription    string       `json:"subscription"`
+		Result          interface{}  `json:"result,omitempty"`
+		ConsensusChange *crypto.Hash `json:"consensuschange,omitempty"`
+		Error           *Error       `json:"error,omitempty"`
+	}
+)
+
+// websocketSession serves the requests received over a single websocket connection,
+// and the subscriptions created by them
+type websocketSession struct {
+	srv  *Server
+	conn *websocketConn
+	// the websocket handshake, whose authorization is used for all calls
+	req *http.Request
+
+	mu            sync.Mutex
+	subscriptions map[string]*stream.Subscription
+	nextID        uint64
+}
+
+// ServeWebsocket serves the JSON-RPC interface over a websocket connection,
+// each (batch) request being a single text message, answered using a single text message.
+// The Authorization header of the websocket handshake is used to call the routes which require the API password.
+// Next to all methods served over HTTP, clients can subscribe to new blocks using rpc_subscribe.
+func (srv *Server) ServeWebsocket(w http.ResponseWriter, req *http.Request) {
+	conn, ok := upgradeWebsocket(w, req)
+	if !ok {
+		return
+	}
+	session := &websocketSession{
+		srv:           srv,
+		conn:          conn,
+		req:           req,
+		subscriptions: make(map[string]*stream.Subscription),
+	}
+	defer session.close()
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		resp := handleMessage(msg, session.call)
+		if resp == nil {
+			continue
+		}
+		if err = conn.WriteMessage(resp); err != nil {
+			return
+		}
+	}
+}
+
+// call a method, handling the subscription methods itself
+func (session *websocketSession) call(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case MethodSubscribe:
+		var p SubscribeParams
+		if err := loadStructParams(params, &p, &p.Topic, &p.Since); err != nil {
+			return nil, err
+		}
+		return session.subscribe(p.Topic, p.Since)
+	case MethodUnsubscribe:
+		var p UnsubscribeParams
+		if err := loadStructParams(params, &p, &p.Subscription); err != nil {
+			return nil, err
+		}
+		return session.unsubscribe(p.Subscription)
+	default:
+		return session.srv.call(session.req, method, params)
+	}
+}
+
+// subscribe to the given topic since the given consensus change, returning the ID of the subscription
+func (session *websocketSession) subscribe(topic, since string) (string, error) {
+	if topic != TopicNewBlocks {
+		return "", newError(CodeInvalidParams, "unknown topic %q, only %q is supported", topic, TopicNewBlocks)
+	}
+	start := modules.ConsensusChangeRecent
+	switch since {
+	case "":
+	case SubscribeSinceBeginning:
+		start = modules.ConsensusChangeBeginning
+	default:
+		var id crypto.Hash
+		if err := id.LoadString(since); err != nil {
+			return "", newError(CodeInvalidParams, "invalid consensus change ID to resume from: %v", err)
+		}
+		start = modules.ConsensusChangeID(id)
+	}
+	if session.srv.stream == nil {
+		return "", newError(CodeMethodNotFound, "subscriptions are not available, as the daemon has no transaction pool")
+	}
+	sub, err := session.srv.stream.Subscribe(start, stream.Filter{
+		Types: []stream.EventType{stream.EventTypeBlockApplied},
+	})
+	if err == modules.ErrInvalidConsensusChangeID {
+		return "", newError(CodeInvalidParams, "unknown consensus change ID to resume from")
+	}
+	if err != nil {
+		return "", newError(CodeServerError, "failed to subscribe: %v", err)
+	}
+
+	session.mu.Lock()
+	session.nextID++
+	id := fmt.Sprintf("0x%x", session.nextID)
+	session.subscriptions[id] = sub
+	session.mu.Unlock()
+
+	go session.threadedNotify(id, sub)
+	return id, nil
+}
+
+// unsubscribe closes the subscription with the given ID, returning false if it doesn't exist
+func (session *websocketSession) unsubscribe(id string) (bool, error) {
+	session.mu.Lock()
+	sub, ok := session.subscriptions[id]
+	delete(session.subscriptions, id)
+	session.mu.Unlock()
+	if ok {
+		sub.Close()
+	}
+	return ok, nil
+}
+
+// threadedNotify notifies the client of all block events of a subscription,
+// and of the error it was closed for by the stream, if any.
+// The blocks of a consensus change are notified once its consensus change event is received,
+// such that the notification of its last block can define the ID to resume from.
+func (session *websocketSession) threadedNotify(id string, sub *stream.Subscription) {
+	var blocks []*stream.BlockEvent
+	for event := range sub.Events() {
+		if event.Block != nil {
+			blocks = append(blocks, event.Block)
+			continue
+		}
+		// consensus change events are always delivered
+		for idx, block := range blocks {
+			params := SubscriptionParams{Subscription: id, Result: block}
+			if idx == len(blocks)-1 {
+				params.ConsensusChange = &event.ConsensusChange.ID
+			}
+			session.notify(params)
+		}
+		blocks = blocks[:0]
+	}
+	if err := sub.Err(); err != nil {
+		session.mu.Lock()
+		delete(session.subscriptions, id)
+		session.mu.Unlock()
+		session.notify(SubscriptionParams{Subscription: id, Error: newError(CodeServerError, "%v", err)})
+	}
+}
+
+// notify the client, ignoring any error as it is returned to the read loop as well
+func (session *websocketSession) notify(params SubscriptionParams) {
+	b, err := json.Marshal(Notification{JSONRPC: Version, Method: MethodSubscription, Params: params})
+	if err != nil {
+		return
+	}
+	session.conn.WriteMessage(b)
+}
+
+// close all subscriptions and the connection of the session
+func (session *websocketSession) close() {
+	session.mu.Lock()
+	subscriptions := session.subscriptions
+	session.subscriptions = nil
+	session.mu.Unlock()
+	for _, sub := range subscriptions {
+		sub.Close()
+	}
+	session.conn.Close()
+}
+
+// loadStructParams loads the params of a subscription method, given either as an object of named params,
+// or by position, in which case the first param is required and all others are optional
+func loadStructParams(raw json.RawMessage, named interface{}, positional ...interface{}) error {
+	raw = bytes.TrimSpace(raw)
+	var err error
+	if len(raw) > 0 && raw[0] == '[' {
+		var params []json.RawMessage
+		if err = json.Unmarshal(raw, &params); err == nil {
+			if len(params) == 0 || len(params) > len(positional) {
+				if len(positional) == 1 {
+					return newError(CodeInvalidParams, "expected a single positional param")
+				}
+				return newError(CodeInvalidParams, "expected 1 to %d positional params", len(positional))
+			}
+			for idx, param := range params {
+				if err = json.Unmarshal(param, positional[idx]); err != nil {
+					break
+				}
+			}
+		}
+	} else {
+		err = json.Unmarshal(raw, named)
+	}
+	if err != nil {
+		return newError(CodeInvalidParams, "invalid params: %v", err)
+	}
+	return nil
+}
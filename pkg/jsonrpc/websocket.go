@@ -0,0 +1,268 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rivine/rivine/pkg/api"
+)
+
+// websocketGUID is the GUID used to compute the Sec-WebSocket-Accept header (RFC 6455, section 1.3)
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// websocketWriteTimeout is the maximum duration of writing a single websocket frame,
+// such that a client which doesn't read its messages cannot block the connection forever
+const websocketWriteTimeout = 10 * time.Second
+
+// websocket opcodes (RFC 6455, section 5.2)
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// websocket close status codes (RFC 6455, section 7.4.1)
+const (
+	closeNormal        = 1000
+	closeProtocolError = 1002
+	closeTooBig        = 1009
+)
+
+var (
+	errWebsocketClosed = errors.New("websocket connection closed by peer")
+	errMessageTooBig   = fmt.Errorf("websocket message exceeds the maximum size of %d bytes", MaxMessageSize)
+)
+
+// websocketConn is a minimal server-side websocket connection (RFC 6455),
+// supporting (fragmented) text and binary messages, ping and close frames, without extensions.
+type websocketConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	mu sync.Mutex // protects writes, as notifications are written concurrently
+}
+
+// upgradeWebsocket upgrades the HTTP connection to a websocket connection,
+// writing an error response and returning false in case the request is not a valid websocket handshake
+func upgradeWebsocket(w http.ResponseWriter, req *http.Request) (*websocketConn, bool) {
+	if req.Method != http.MethodGet ||
+		!headerContainsToken(req.Header, "Connection", "upgrade") ||
+		!headerContainsToken(req.Header, "Upgrade", "websocket") {
+		api.WriteError(w, api.Error{Message: "websocket handshake required"}, http.StatusBadRequest)
+		return nil, false
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		api.WriteError(w, api.Error{Message: "unsupported websocket version"}, http.StatusUpgradeRequired)
+		return nil, false
+	}
+	key := strings.TrimSpace(req.Header.Get("Sec-WebSocket-Key"))
+	if key == "" {
+		api.WriteError(w, api.Error{Message: "no websocket key given"}, http.StatusBadRequest)
+		return nil, false
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		api.WriteError(w, api.Error{Message: "websockets are not supported"}, http.StatusInternalServerError)
+		return nil, false
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		api.WriteError(w, api.Error{Message: fmt.Sprintf("failed to hijack connection: %v", err)}, http.StatusInternalServerError)
+		return nil, false
+	}
+	fmt.Fprintf(rw.Writer, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		websocketAccept(key))
+	if err = rw.Writer.Flush(); err != nil {
+		conn.Close()
+		return nil, false
+	}
+	// clear any deadline set by the HTTP server, as the connection is long-lived
+	conn.SetDeadline(time.Time{})
+	return &websocketConn{conn: conn, r: rw.Reader}, true
+}
+
+// websocketAccept computes the Sec-WebSocket-Accept header value for the given key
+func websocketAccept(key string) string {
+	h := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// headerContainsToken returns true in case the comma-separated header contains the given token (case insensitive)
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage reads the next (text or binary) message,
+// answering ping frames while doing so. errWebsocketClosed is returned once the peer closed the connection.
+func (c *websocketConn) ReadMessage() ([]byte, error) {
+	var (
+		msg        []byte
+		fragmented bool
+	)
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			if err == errMessageTooBig {
+				c.writeClose(closeTooBig, err.Error())
+			} else if err != io.EOF && err != io.ErrUnexpectedEOF {
+				c.writeClose(closeProtocolError, err.Error())
+			}
+			return nil, err
+		}
+		switch opcode {
+		case opPing:
+			if err = c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			status := closeNormal
+			if len(payload) >= 2 {
+				status = int(binary.BigEndian.Uint16(payload))
+			}
+			c.writeClose(status, "")
+			return nil, errWebsocketClosed
+		case opText, opBinary:
+			if fragmented {
+				c.writeClose(closeProtocolError, "expected continuation frame")
+				return nil, errors.New("expected continuation frame")
+			}
+			msg = payload
+		case opContinuation:
+			if !fragmented {
+				c.writeClose(closeProtocolError, "unexpected continuation frame")
+				return nil, errors.New("unexpected continuation frame")
+			}
+			if len(msg)+len(payload) > MaxMessageSize {
+				c.writeClose(closeTooBig, errMessageTooBig.Error())
+				return nil, errMessageTooBig
+			}
+			msg = append(msg, payload...)
+		default:
+			c.writeClose(closeProtocolError, "unknown opcode")
+			return nil, fmt.Errorf("unknown websocket opcode %d", opcode)
+		}
+		if fin {
+			return msg, nil
+		}
+		fragmented = true
+	}
+}
+
+// readFrame reads a single frame, which is required to be masked, as all client frames are
+func (c *websocketConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.r, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, errors.New("reserved bits are set, while no extensions are supported")
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, errors.New("client frames have to be masked")
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= opClose && (!fin || length > 125) {
+		return false, 0, nil, errors.New("control frames cannot be fragmented or exceed 125 bytes")
+	}
+	if length > MaxMessageSize {
+		return false, 0, nil, errMessageTooBig
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.r, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.r, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage writes a single text message.
+func (c *websocketConn) WriteMessage(msg []byte) error {
+	return c.writeFrame(opText, msg)
+}
+
+// writeFrame writes a single (unmasked) frame, as all server frames are
+func (c *websocketConn) writeFrame(opcode byte, payload []byte) error {
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		header = header[:4]
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header[1] = 127
+		header = header[:10]
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeClose writes a close frame, ignoring any error as the connection is closed anyhow
+func (c *websocketConn) writeClose(status int, reason string) {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(status))
+	c.writeFrame(opClose, append(payload, reason...))
+}
+
+// Close the underlying connection.
+func (c *websocketConn) Close() error {
+	return c.conn.Close()
+}
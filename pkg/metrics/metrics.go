@@ -0,0 +1,306 @@
+// Package metrics implements a minimal metrics registry,
+// which exposes its metrics using the Prometheus text exposition format (version 0.0.4).
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the default histogram buckets,
+// tailored to measure durations in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metric types, as defined by the Prometheus text exposition format
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+type (
+	// Registry contains all registered metrics,
+	// and writes them in the Prometheus text exposition format.
+	// It is safe for concurrent use.
+	Registry struct {
+		mu      sync.Mutex
+		metrics []*metric
+		names   map[string]struct{}
+	}
+
+	// Counter is a metric which can only increase, optionally partitioned by labels.
+	Counter struct {
+		m *metric
+	}
+	// Gauge is a metric which can be set to any value, optionally partitioned by labels.
+	Gauge struct {
+		m *metric
+	}
+	// Histogram counts observations in configurable buckets, optionally partitioned by labels.
+	Histogram struct {
+		m *metric
+	}
+
+	metric struct {
+		name, help, typ string
+		labelNames      []string
+		buckets         []float64
+		// collect is only defined for metrics which compute their value when written
+		collect func() float64
+
+		mu     sync.Mutex
+		series map[string]*series
+	}
+	series struct {
+		labelValues []string
+		value       float64
+		// only used for histograms
+		bucketCounts []uint64
+		count        uint64
+	}
+)
+
+// NewRegistry creates a new, empty, registry.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]struct{})}
+}
+
+// NewCounter registers and returns a new counter, partitioned by the given label names.
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{m: r.register(&metric{name: name, help: help, typ: typeCounter, labelNames: labelNames})}
+}
+
+// NewGauge registers and returns a new gauge, partitioned by the given label names.
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{m: r.register(&metric{name: name, help: help, typ: typeGauge, labelNames: labelNames})}
+}
+
+// NewGaugeFunc registers a new gauge (without labels), which value is computed
+// using the given function each time the metrics are written.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&metric{name: name, help: help, typ: typeGauge, collect: fn})
+}
+
+// NewHistogram registers and returns a new histogram, using the given (sorted) upper bounds as buckets,
+// partitioned by the given label names. DefaultBuckets are used if no buckets are given.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("buckets of histogram %s are not sorted", name))
+	}
+	return &Histogram{m: r.register(&metric{name: name, help: help, typ: typeHistogram, labelNames: labelNames, buckets: buckets})}
+}
+
+// Add the given (non-negative) value to the counter with the given label values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("counter %s cannot decrease", c.m.name))
+	}
+	c.m.update(labelValues, func(s *series) { s.value += v })
+}
+
+// Inc increments the counter with the given label values by one.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Set the gauge with the given label values to the given value.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.m.update(labelValues, func(s *series) { s.value = v })
+}
+
+// Observe adds a single observation to the histogram with the given label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.m.update(labelValues, func(s *series) {
+		if s.bucketCounts == nil {
+			s.bucketCounts = make([]uint64, len(h.m.buckets))
+		}
+		for i, upperBound := range h.m.buckets {
+			if v <= upperBound {
+				s.bucketCounts[i]++
+			}
+		}
+		s.value += v
+		s.count++
+	})
+}
+
+// WriteTo writes all registered metrics, in the order they were registered,
+// to the given writer using the Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := make([]*metric, len(r.metrics))
+	copy(metrics, r.metrics)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, m := range metrics {
+		m.writeTo(cw)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.(*bufio.Writer).Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP implements http.Handler, serving all registered metrics.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteTo(w)
+}
+
+// register the given metric, panicking if its name is invalid or already registered
+func (r *Registry) register(m *metric) *metric {
+	if !isValidName(m.name) {
+		panic(fmt.Sprintf("invalid metric name %q", m.name))
+	}
+	for _, name := range m.labelNames {
+		if !isValidName(name) || strings.Contains(name, ":") || name == "le" {
+			panic(fmt.Sprintf("invalid label name %q for metric %s", name, m.name))
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.names[m.name]; ok {
+		panic(fmt.Sprintf("metric %s is already registered", m.name))
+	}
+	r.names[m.name] = struct{}{}
+	m.series = make(map[string]*series)
+	r.metrics = append(r.metrics, m)
+	return m
+}
+
+// update the series with the given label values, creating it if it doesn't exist yet
+func (m *metric) update(labelValues []string, fn func(*series)) {
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Sprintf("metric %s requires %d label value(s), while %d were given",
+			m.name, len(m.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		m.series[key] = s
+	}
+	fn(s)
+}
+
+// writeTo writes the metric with all its series, sorted by their label values
+func (m *metric) writeTo(w *countingWriter) {
+	w.printf("# HELP %s %s\n", m.name, escapeHelp(m.help))
+	w.printf("# TYPE %s %s\n", m.name, m.typ)
+	if m.collect != nil {
+		w.printf("%s %s\n", m.name, formatFloat(m.collect()))
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 && len(m.labelNames) == 0 && m.typ != typeHistogram {
+		// a metric without labels is always defined
+		w.printf("%s 0\n", m.name)
+		return
+	}
+	bucketLabelNames := append(append([]string(nil), m.labelNames...), "le")
+	for _, key := range keys {
+		s := m.series[key]
+		labels := formatLabels(m.labelNames, s.labelValues)
+		if m.typ != typeHistogram {
+			w.printf("%s%s %s\n", m.name, labels, formatFloat(s.value))
+			continue
+		}
+		bucketLabelValues := append(append([]string(nil), s.labelValues...), "")
+		for i, upperBound := range m.buckets {
+			bucketLabelValues[len(s.labelValues)] = formatFloat(upperBound)
+			w.printf("%s_bucket%s %d\n", m.name, formatLabels(bucketLabelNames, bucketLabelValues), s.bucketCounts[i])
+		}
+		bucketLabelValues[len(s.labelValues)] = "+Inf"
+		w.printf("%s_bucket%s %d\n", m.name, formatLabels(bucketLabelNames, bucketLabelValues), s.count)
+		w.printf("%s_sum%s %s\n", m.name, labels, formatFloat(s.value))
+		w.printf("%s_count%s %d\n", m.name, labels, s.count)
+	}
+}
+
+// formatLabels formats the given label pairs as {name="value",...},
+// or an empty string if no labels are given
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names))
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabelValue(values[i])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string       { return helpEscaper.Replace(s) }
+func escapeLabelValue(s string) string { return labelValueEscaper.Replace(s) }
+
+// isValidName returns true if the given name is a valid metric (or label) name
+func isValidName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_' || r == ':' || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// countingWriter writes formatted strings, keeping track of the amount of bytes written,
+// and the first error that occurred, after which it no longer writes
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) printf(format string, args ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
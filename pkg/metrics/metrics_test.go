@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("api_requests_total", "Total API requests.", "method", "route")
+	height := r.NewGauge("height", "Block height.")
+	r.NewGaugeFunc("peers", "Connected\npeers.", func() float64 { return 3 })
+	latency := r.NewHistogram("latency_seconds", "Latency.", []float64{1, 5}, "route")
+
+	requests.Inc("POST", `/wallet/"x"`)
+	requests.Add(2, "GET", "/wallet")
+	height.Set(42)
+	latency.Observe(0.5, "/a")
+	latency.Observe(3, "/a")
+	latency.Observe(10, "/a")
+
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("expected %d bytes to be written, while %d were reported", buf.Len(), n)
+	}
+	const expected = `# HELP api_requests_total Total API requests.
+# TYPE api_requests_total counter
+api_requests_total{method="GET",route="/wallet"} 2
+api_requests_total{method="POST",route="/wallet/\"x\""} 1
+# HELP height Block height.
+# TYPE height gauge
+height 42
+# HELP peers Connected\npeers.
+# TYPE peers gauge
+peers 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="1"} 1
+latency_seconds_bucket{route="/a",le="5"} 2
+latency_seconds_bucket{route="/a",le="+Inf"} 3
+latency_seconds_sum{route="/a"} 13.5
+latency_seconds_count{route="/a"} 3
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestRegistryInvalidUsage(t *testing.T) {
+	expectPanic := func(description string, fn func()) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected a panic for %s", description)
+			}
+		}()
+		fn()
+	}
+	r := NewRegistry()
+	counter := r.NewCounter("counter", "", "label")
+	expectPanic("a duplicate metric", func() { r.NewGauge("counter", "") })
+	expectPanic("an invalid metric name", func() { r.NewGauge("0gauge", "") })
+	expectPanic("a reserved label name", func() { r.NewHistogram("histogram", "", nil, "le") })
+	expectPanic("unsorted buckets", func() { r.NewHistogram("histogram", "", []float64{2, 1}) })
+	expectPanic("missing label values", func() { counter.Inc() })
+	expectPanic("a decreasing counter", func() { counter.Add(-1, "value") })
+}
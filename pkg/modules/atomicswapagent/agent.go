@@ -0,0 +1,535 @@
+package atomicswapagent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/threefoldfoundation/tfchain/pkg/persist"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	rivinepersist "github.com/rivine/rivine/persist"
+	rivinesync "github.com/rivine/rivine/sync"
+	"github.com/rivine/rivine/types"
+
+	bolt "github.com/rivine/bbolt"
+)
+
+// Agent I/O constants
+const (
+	AgentDir      = "atomicswapagent"
+	AgentFilename = AgentDir + ".db"
+)
+
+// RetryDelay defines the amount of blocks the agent waits,
+// before retrying to spend a contract which is still unspent,
+// after a spend attempt was previously made.
+const RetryDelay types.BlockHeight = 6
+
+// internal bucket database keys used for the agent
+var (
+	// bucketSecrets stores all secrets given to the agent via the API,
+	// indexed by their hashed secret
+	bucketSecrets = []byte("secrets")
+	// bucketAttempts stores the last attempt made to spend a contract,
+	// indexed by the ID of the coin output which defines the contract
+	bucketAttempts = []byte("attempts")
+)
+
+// Role defines the role the wallet has within an atomic swap contract.
+type Role string
+
+// All possible roles of the wallet within an atomic swap contract.
+const (
+	// RoleReceiver is the role of the wallet if it owns the receiver's address,
+	// and can thus redeem the contract once the secret is known.
+	RoleReceiver Role = "receiver"
+	// RoleSender is the role of the wallet if it owns the sender's address,
+	// and can thus refund the contract once its refund window is open.
+	RoleSender Role = "sender"
+)
+
+// Action defines an action the agent can take for an atomic swap contract.
+type Action string
+
+// All possible actions the agent can take for an atomic swap contract.
+const (
+	// ActionNone is defined when the agent cannot take any action (yet).
+	ActionNone Action = "none"
+	// ActionRedeem is defined when the agent redeems the contract.
+	ActionRedeem Action = "redeem"
+	// ActionRefund is defined when the agent refunds the contract.
+	ActionRefund Action = "refund"
+)
+
+type (
+	// Agent watches the atomic swap contracts of the wallet,
+	// redeeming them automatically as soon as the secret is known, either because
+	// it was published on the chain or because it was given via the API,
+	// and refunding them automatically as soon as their refund window is open.
+	Agent struct {
+		// The Agent's ThreadGroup tells tracked functions to shut down and
+		// blocks until they have all exited before returning from Close.
+		tg rivinesync.ThreadGroup
+
+		db *rivinepersist.BoltDatabase
+
+		asdb   *persist.AtomicSwapDB
+		cs     modules.ConsensusSet
+		tpool  modules.TransactionPool
+		wallet modules.Wallet
+
+		txVersion types.TransactionVersion
+		minerFee  types.Currency
+
+		// trigger is used to signal the agent that it has to
+		// check the state of all atomic swap contracts of the wallet
+		trigger    chan struct{}
+		subscriber *agentCSSubscriber
+
+		// mu ensures only one check happens at a time,
+		// and protects the status of the agent
+		mu     sync.Mutex
+		status Status
+	}
+
+	// Status defines the status of the agent.
+	Status struct {
+		// WalletUnlocked defines if the wallet was unlocked during the last check,
+		// the agent can only spend contracts while the wallet is unlocked
+		WalletUnlocked bool `json:"walletunlocked"`
+		// LastCheckHeight defines the block height at which the agent last checked
+		// the contracts of the wallet
+		LastCheckHeight types.BlockHeight `json:"lastcheckheight"`
+		// LastError is only defined if the last check failed
+		LastError string `json:"lasterror,omitempty"`
+	}
+
+	// implements modules.ConsensusSetSubscriber,
+	// triggering the agent for every consensus change
+	agentCSSubscriber struct {
+		agent *Agent
+	}
+
+	// Contract is an atomic swap contract in which the wallet participates,
+	// as either the sender, the receiver or both.
+	Contract struct {
+		persist.AtomicSwapContract
+		// Roles the wallet has within the contract
+		Roles []Role `json:"roles"`
+		// Action the agent will take, or has taken, for this contract
+		Action Action `json:"action"`
+		// LastAttempt is only defined if the agent already
+		// attempted to spend this contract
+		LastAttempt *Attempt `json:"lastattempt,omitempty"`
+	}
+
+	// Attempt defines an attempt of the agent to spend an atomic swap contract.
+	Attempt struct {
+		Action        Action              `json:"action"`
+		TransactionID types.TransactionID `json:"transactionid"`
+		Height        types.BlockHeight   `json:"height"`
+		// Error is only defined if the attempt failed
+		Error string `json:"error,omitempty"`
+	}
+)
+
+// New creates a new atomic swap agent, using the given atomic swap DB to find contracts,
+// and the given wallet and transaction pool to spend the contracts of the wallet.
+// The given root directory is used to store its (single) persistent BoltDB file.
+func New(rootDir string, asdb *persist.AtomicSwapDB, cs modules.ConsensusSet, tpool modules.TransactionPool, w modules.Wallet, constants types.ChainConstants) (*Agent, error) {
+	if asdb == nil {
+		return nil, errors.New("atomic swap agent requires an atomic swap DB")
+	}
+	if cs == nil {
+		return nil, errors.New("atomic swap agent requires a consensus set")
+	}
+	if tpool == nil {
+		return nil, errors.New("atomic swap agent requires a transaction pool")
+	}
+	if w == nil {
+		return nil, errors.New("atomic swap agent requires a wallet")
+	}
+
+	persistDir := path.Join(rootDir, AgentDir)
+	// Create the directory if it doesn't exist.
+	err := os.MkdirAll(persistDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	agent := &Agent{
+		asdb:      asdb,
+		cs:        cs,
+		tpool:     tpool,
+		wallet:    w,
+		txVersion: constants.DefaultTransactionVersion,
+		minerFee:  constants.MinimumTransactionFee,
+		trigger:   make(chan struct{}, 1),
+	}
+	err = agent.openDB(path.Join(persistDir, AgentFilename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the atomic swap agent DB: %v", err)
+	}
+
+	// only recent consensus changes are of interest, as they are merely used as a trigger
+	agent.subscriber = &agentCSSubscriber{agent: agent}
+	err = cs.ConsensusSetSubscribe(agent.subscriber, modules.ConsensusChangeRecent, agent.tg.StopChan())
+	if err != nil {
+		agent.db.Close()
+		return nil, fmt.Errorf("failed to subscribe to consensus set: %v", err)
+	}
+
+	go agent.threadedRun()
+	return agent, nil
+}
+
+// RegisterSecret registers a secret with the agent,
+// such that the agent can redeem all contracts of the wallet
+// that are locked with the hash of the given secret.
+func (agent *Agent) RegisterSecret(secret types.AtomicSwapSecret) error {
+	if secret == (types.AtomicSwapSecret{}) {
+		return errors.New("secret cannot be all-nil")
+	}
+	hashedSecret := types.NewAtomicSwapHashedSecret(secret)
+	err := agent.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSecrets).Put(encoding.Marshal(hashedSecret), encoding.Marshal(secret))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store secret: %v", err)
+	}
+	agent.triggerCheck()
+	return nil
+}
+
+// Contracts returns all unspent atomic swap contracts in which the wallet participates,
+// as well as all spent contracts for which the agent made a spend attempt.
+// An error is returned if the wallet is locked.
+func (agent *Agent) Contracts() ([]Contract, error) {
+	contracts, _, err := agent.walletContracts(true)
+	return contracts, err
+}
+
+// Status returns the status of the agent.
+func (agent *Agent) Status() Status {
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+	return agent.status
+}
+
+// Close the agent,
+// meaning the agent will be unsubscribed from the consensus set,
+// as well the threadgroup will be stopped and the internal bolt db will be closed.
+func (agent *Agent) Close() error {
+	if agent.db == nil {
+		return errors.New("atomic swap agent is already closed or was never created")
+	}
+
+	agent.cs.Unsubscribe(agent.subscriber)
+	// stop thread group
+	tgErr := agent.tg.Stop()
+	if tgErr != nil {
+		tgErr = fmt.Errorf("failed to stop the threadgroup of the atomic swap agent: %v", tgErr)
+	}
+	// close database
+	dbErr := agent.db.Close()
+	if dbErr != nil {
+		dbErr = fmt.Errorf("failed to close the internal bolt db of the atomic swap agent: %v", dbErr)
+	}
+	agent.db = nil
+
+	return build.ComposeErrors(tgErr, dbErr)
+}
+
+// openDB loads the set database and populates it with the necessary buckets
+func (agent *Agent) openDB(filename string) (err error) {
+	var (
+		dbMetadata = rivinepersist.Metadata{
+			Header:  "TFChain Atomic Swap Agent Database",
+			Version: "1.0.0",
+		}
+	)
+
+	agent.db, err = rivinepersist.OpenDatabase(dbMetadata, filename)
+	if err != nil {
+		return fmt.Errorf("error opening tfchain atomic swap agent database: %v", err)
+	}
+	return agent.db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{bucketSecrets, bucketAttempts} {
+			_, err := tx.CreateBucketIfNotExists(bucket)
+			if err != nil {
+				return fmt.Errorf("failed to create bucket %s: %v", string(bucket), err)
+			}
+		}
+		return nil
+	})
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber,
+// triggering the agent to check the atomic swap contracts of the wallet.
+func (sub *agentCSSubscriber) ProcessConsensusChange(modules.ConsensusChange) {
+	sub.agent.triggerCheck()
+}
+
+// triggerCheck triggers the agent to check the atomic swap contracts of the wallet,
+// without blocking, as a single pending trigger is sufficient
+func (agent *Agent) triggerCheck() {
+	select {
+	case agent.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// threadedRun checks the atomic swap contracts of the wallet
+// each time the agent is triggered, until the agent is closed.
+// Spending contracts is done on this separate goroutine,
+// as the transaction pool cannot be used from within a consensus change.
+func (agent *Agent) threadedRun() {
+	if err := agent.tg.Add(); err != nil {
+		return
+	}
+	defer agent.tg.Done()
+
+	for {
+		select {
+		case <-agent.tg.StopChan():
+			return
+		case <-agent.trigger:
+			agent.check()
+		}
+	}
+}
+
+// check spends all atomic swap contracts of the wallet which can be spent,
+// and for which no recent spend attempt was made yet.
+func (agent *Agent) check() {
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	height := agent.cs.Height()
+	agent.status = Status{
+		WalletUnlocked:  agent.wallet.Unlocked(),
+		LastCheckHeight: height,
+	}
+	if !agent.status.WalletUnlocked {
+		return // nothing we can do
+	}
+	contracts, secrets, err := agent.walletContracts(false)
+	if err != nil {
+		agent.status.WalletUnlocked = err != modules.ErrLockedWallet
+		if agent.status.WalletUnlocked {
+			agent.status.LastError = fmt.Sprintf("failed to collect the contracts of the wallet: %v", err)
+		}
+		return
+	}
+	for _, contract := range contracts {
+		if contract.Action == ActionNone {
+			continue
+		}
+		if attempt := contract.LastAttempt; attempt != nil && attempt.Action == contract.Action && attempt.Height+RetryDelay > height {
+			continue // wait for the last attempt to confirm
+		}
+		attempt := Attempt{
+			Action: contract.Action,
+			Height: height,
+		}
+		attempt.TransactionID, err = agent.spendContract(contract, secrets[contract.Contract.HashedSecret])
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+		err = agent.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(bucketAttempts).Put(encoding.Marshal(contract.OutputID), encoding.Marshal(attempt))
+		})
+		if err != nil {
+			agent.status.LastError = fmt.Sprintf("failed to store spend attempt for contract %s: %v", contract.OutputID.String(), err)
+		}
+	}
+}
+
+// walletContracts collects all atomic swap contracts in which the wallet participates,
+// which are still unspent, or optionally also those which are spent and which
+// the agent attempted to spend. The known secrets are returned as well,
+// mapped by their hashed secret, such that the receiver can redeem.
+func (agent *Agent) walletContracts(includeSpent bool) ([]Contract, map[types.AtomicSwapHashedSecret]types.AtomicSwapSecret, error) {
+	addresses, err := agent.wallet.AllAddresses()
+	if err != nil {
+		return nil, nil, err
+	}
+	owned := make(map[types.UnlockHash]struct{}, len(addresses))
+	for _, address := range addresses {
+		owned[address] = struct{}{}
+	}
+
+	// collect all contracts of the wallet, as receiver and/or sender
+	results, err := agent.asdb.GetAtomicSwapContractsOf(addresses)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get atomic swap contracts of the wallet: %v", err)
+	}
+	contracts := make([]Contract, 0, len(results))
+	for _, result := range results {
+		contract := Contract{
+			AtomicSwapContract: result,
+			Action:             ActionNone,
+		}
+		if _, ok := owned[result.Contract.Receiver]; ok {
+			contract.Roles = append(contract.Roles, RoleReceiver)
+		}
+		if _, ok := owned[result.Contract.Sender]; ok {
+			contract.Roles = append(contract.Roles, RoleSender)
+		}
+		contracts = append(contracts, contract)
+	}
+
+	// collect the last attempts of all contracts, and the secrets registered with the agent
+	secrets := make(map[types.AtomicSwapHashedSecret]types.AtomicSwapSecret)
+	var unknownSecrets []types.AtomicSwapHashedSecret
+	err = agent.db.View(func(tx *bolt.Tx) error {
+		secretsBucket, attemptsBucket := tx.Bucket(bucketSecrets), tx.Bucket(bucketAttempts)
+		for i := range contracts {
+			contract := &contracts[i]
+			if b := attemptsBucket.Get(encoding.Marshal(contract.OutputID)); len(b) > 0 {
+				contract.LastAttempt = new(Attempt)
+				err := encoding.Unmarshal(b, contract.LastAttempt)
+				if err != nil {
+					return fmt.Errorf("failed to decode spend attempt of contract %s: %v", contract.OutputID.String(), err)
+				}
+			}
+			if contract.spent() || !contract.hasRole(RoleReceiver) {
+				continue
+			}
+			hashedSecret := contract.Contract.HashedSecret
+			if _, ok := secrets[hashedSecret]; ok {
+				continue
+			}
+			b := secretsBucket.Get(encoding.Marshal(hashedSecret))
+			if len(b) == 0 {
+				unknownSecrets = append(unknownSecrets, hashedSecret)
+				continue
+			}
+			var secret types.AtomicSwapSecret
+			err := encoding.Unmarshal(b, &secret)
+			if err != nil {
+				return fmt.Errorf("failed to decode secret for hashed secret %s: %v", hashedSecret.String(), err)
+			}
+			secrets[hashedSecret] = secret
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// look up the secrets not registered with the agent,
+	// in the contracts redeemed on the chain
+	if len(unknownSecrets) > 0 {
+		published, err := agent.asdb.GetAtomicSwapSecrets(unknownSecrets)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get the secrets of redeemed contracts: %v", err)
+		}
+		for hashedSecret, secret := range published {
+			secrets[hashedSecret] = secret
+		}
+	}
+
+	// define the action of all contracts
+	result := make([]Contract, 0, len(contracts))
+	for _, contract := range contracts {
+		if contract.spent() {
+			if !includeSpent || contract.LastAttempt == nil {
+				continue
+			}
+			contract.Action = contract.LastAttempt.Action
+		} else {
+			_, secretKnown := secrets[contract.Contract.HashedSecret]
+			contract.Action = contract.action(secretKnown)
+		}
+		result = append(result, contract)
+	}
+	return result, secrets, nil
+}
+
+// action returns the action the agent can take for an unspent contract,
+// redeeming it as the receiver as soon as the secret is known,
+// and refunding it as the sender as soon as its refund window is open otherwise
+func (contract *Contract) action(secretKnown bool) Action {
+	if contract.hasRole(RoleReceiver) && secretKnown {
+		return ActionRedeem
+	}
+	if contract.hasRole(RoleSender) && contract.Status == persist.AtomicSwapContractStatusExpired {
+		return ActionRefund
+	}
+	return ActionNone
+}
+
+// spent returns true if the contract was already redeemed or refunded
+func (contract *Contract) spent() bool {
+	return contract.Status != persist.AtomicSwapContractStatusOpen && contract.Status != persist.AtomicSwapContractStatusExpired
+}
+
+// spendContract redeems or refunds the given contract, depending on its action,
+// sending the locked coins, minus the miner fee, to the address of the wallet that was used to spend.
+func (agent *Agent) spendContract(contract Contract, secret types.AtomicSwapSecret) (types.TransactionID, error) {
+	var uh types.UnlockHash
+	switch contract.Action {
+	case ActionRedeem:
+		uh = contract.Contract.Receiver
+	case ActionRefund:
+		uh = contract.Contract.Sender
+		secret = types.AtomicSwapSecret{} // a refund requires no secret
+	default:
+		return types.TransactionID{}, fmt.Errorf("unsupported atomic swap agent action %q", contract.Action)
+	}
+	if contract.Value.Cmp(agent.minerFee) != 1 {
+		return types.TransactionID{}, errors.New("contract locks a value less than or equal to the minimum transaction fee")
+	}
+
+	pk, sk, err := agent.wallet.GetKey(uh)
+	if err != nil {
+		return types.TransactionID{}, fmt.Errorf("failed to get key pair for %s: %v", uh.String(), err)
+	}
+	txn := types.Transaction{
+		Version: agent.txVersion,
+		CoinInputs: []types.CoinInput{
+			{
+				ParentID: contract.OutputID,
+				Fulfillment: types.NewFulfillment(&types.AtomicSwapFulfillment{
+					PublicKey: pk,
+					Secret:    secret,
+				}),
+			},
+		},
+		CoinOutputs: []types.CoinOutput{
+			{
+				Condition: types.NewCondition(types.NewUnlockHashCondition(uh)),
+				Value:     contract.Value.Sub(agent.minerFee),
+			},
+		},
+		MinerFees: []types.Currency{agent.minerFee},
+	}
+	err = txn.CoinInputs[0].Fulfillment.Sign(types.FulfillmentSignContext{
+		InputIndex:  0,
+		Transaction: txn,
+		Key:         sk,
+	})
+	if err != nil {
+		return types.TransactionID{}, fmt.Errorf("failed to sign transaction: %v", err)
+	}
+	err = agent.tpool.AcceptTransactionSet([]types.Transaction{txn})
+	if err != nil {
+		return txn.ID(), fmt.Errorf("transaction pool did not accept transaction: %v", err)
+	}
+	return txn.ID(), nil
+}
+
+func (contract *Contract) hasRole(role Role) bool {
+	for _, r := range contract.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
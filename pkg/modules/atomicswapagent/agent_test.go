@@ -0,0 +1,178 @@
+package atomicswapagent
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/threefoldfoundation/tfchain/pkg/persist"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+func TestContractAction(t *testing.T) {
+	testCases := []struct {
+		roles       []Role
+		status      persist.AtomicSwapContractStatus
+		secretKnown bool
+		action      Action
+	}{
+		{[]Role{RoleReceiver}, persist.AtomicSwapContractStatusOpen, false, ActionNone},
+		{[]Role{RoleReceiver}, persist.AtomicSwapContractStatusOpen, true, ActionRedeem},
+		// an expired contract can still be redeemed
+		{[]Role{RoleReceiver}, persist.AtomicSwapContractStatusExpired, true, ActionRedeem},
+		{[]Role{RoleReceiver}, persist.AtomicSwapContractStatusExpired, false, ActionNone},
+		{[]Role{RoleSender}, persist.AtomicSwapContractStatusOpen, true, ActionNone},
+		{[]Role{RoleSender}, persist.AtomicSwapContractStatusExpired, false, ActionRefund},
+		// the sender cannot redeem, even if it knows the secret
+		{[]Role{RoleSender}, persist.AtomicSwapContractStatusExpired, true, ActionRefund},
+		// redeeming is preferred over refunding, when the wallet has both roles
+		{[]Role{RoleReceiver, RoleSender}, persist.AtomicSwapContractStatusExpired, true, ActionRedeem},
+		{[]Role{RoleReceiver, RoleSender}, persist.AtomicSwapContractStatusExpired, false, ActionRefund},
+	}
+	for idx, tc := range testCases {
+		contract := Contract{Roles: tc.roles}
+		contract.Status = tc.status
+		if action := contract.action(tc.secretKnown); action != tc.action {
+			t.Errorf("test case #%d: expected action %q, not %q", idx, tc.action, action)
+		}
+	}
+}
+
+func TestWalletContracts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atomicswapagent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		walletAddress, otherAddress types.UnlockHash
+		secret, otherSecret         types.AtomicSwapSecret
+	)
+	walletAddress.Type, walletAddress.Hash[0] = types.UnlockTypePubKey, 1
+	otherAddress.Type, otherAddress.Hash[0] = types.UnlockTypePubKey, 2
+	secret[0], otherSecret[0] = 1, 2
+	const t0 = types.Timestamp(1e9)
+	contract := func(sender, receiver types.UnlockHash, secret types.AtomicSwapSecret, timeLock types.Timestamp) types.CoinOutput {
+		return types.CoinOutput{
+			Value: types.NewCurrency64(1e9),
+			Condition: types.NewCondition(&types.AtomicSwapCondition{
+				Sender:       sender,
+				Receiver:     receiver,
+				HashedSecret: types.NewAtomicSwapHashedSecret(secret),
+				TimeLock:     timeLock,
+			}),
+		}
+	}
+	txn := types.Transaction{
+		CoinOutputs: []types.CoinOutput{
+			// redeemable using the secret registered with the agent
+			contract(otherAddress, walletAddress, secret, t0+1000),
+			// redeemable using the secret published on the chain
+			contract(otherAddress, walletAddress, otherSecret, t0+1000),
+			// redeemed on the chain, publishing its secret
+			contract(walletAddress, otherAddress, otherSecret, t0+1000),
+			// refundable
+			contract(walletAddress, otherAddress, secret, t0+5),
+			// not refundable yet
+			contract(walletAddress, otherAddress, secret, t0+1000),
+			// not a contract of the wallet
+			contract(otherAddress, otherAddress, secret, t0+5),
+		},
+	}
+	blocks := []types.Block{
+		{Timestamp: t0, Transactions: []types.Transaction{txn}},
+		{
+			Timestamp: t0 + 10,
+			Transactions: []types.Transaction{{
+				CoinInputs: []types.CoinInput{{
+					ParentID:    txn.CoinOutputID(2),
+					Fulfillment: types.NewFulfillment(&types.AtomicSwapFulfillment{Secret: otherSecret}),
+				}},
+			}},
+		},
+	}
+	blocks[1].ParentID = blocks[0].ID()
+
+	asdb, err := persist.NewAtomicSwapDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer asdb.Close()
+	err = asdb.SubscribeToConsensusSet(&testConsensusSet{changes: []modules.ConsensusChange{{AppliedBlocks: blocks}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	agent := &Agent{
+		asdb:    asdb,
+		wallet:  &testWallet{addresses: []types.UnlockHash{walletAddress}},
+		trigger: make(chan struct{}, 1),
+	}
+	err = agent.openDB(path.Join(dir, AgentFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer agent.db.Close()
+	err = agent.RegisterSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contracts, secrets, err := agent.walletContracts(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[types.CoinOutputID]Action{
+		txn.CoinOutputID(0): ActionRedeem,
+		txn.CoinOutputID(1): ActionRedeem,
+		txn.CoinOutputID(3): ActionRefund,
+		txn.CoinOutputID(4): ActionNone,
+	}
+	if len(contracts) != len(expected) {
+		t.Fatalf("expected %d contracts, not %d", len(expected), len(contracts))
+	}
+	for _, contract := range contracts {
+		action, ok := expected[contract.OutputID]
+		if !ok {
+			t.Errorf("unexpected contract %s", contract.OutputID.String())
+			continue
+		}
+		if contract.Action != action {
+			t.Errorf("expected action %q for contract %s, not %q", action, contract.OutputID.String(), contract.Action)
+		}
+	}
+	for _, s := range []types.AtomicSwapSecret{secret, otherSecret} {
+		if secrets[types.NewAtomicSwapHashedSecret(s)] != s {
+			t.Errorf("expected secret %s to be known", s.String())
+		}
+	}
+}
+
+// testConsensusSet applies the given consensus changes on subscription
+type testConsensusSet struct {
+	modules.ConsensusSet
+	changes []modules.ConsensusChange
+}
+
+func (cs *testConsensusSet) ConsensusSetSubscribe(subscriber modules.ConsensusSetSubscriber, _ modules.ConsensusChangeID, _ <-chan struct{}) error {
+	for _, change := range cs.changes {
+		subscriber.ProcessConsensusChange(change)
+	}
+	return nil
+}
+
+func (cs *testConsensusSet) Unsubscribe(modules.ConsensusSetSubscriber) {}
+
+// testWallet owns the given addresses
+type testWallet struct {
+	modules.Wallet
+	addresses []types.UnlockHash
+}
+
+func (w *testWallet) AllAddresses() ([]types.UnlockHash, error) {
+	return w.addresses, nil
+}
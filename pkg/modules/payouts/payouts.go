@@ -0,0 +1,489 @@
+package payouts
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/NebulousLabs/fastrand"
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	rivinepersist "github.com/rivine/rivine/persist"
+	rivinesync "github.com/rivine/rivine/sync"
+	"github.com/rivine/rivine/types"
+
+	bolt "github.com/rivine/bbolt"
+)
+
+// Manager I/O constants
+const (
+	ManagerDir      = "payouts"
+	ManagerFilename = ManagerDir + ".db"
+)
+
+// internal bucket database keys used for the manager
+var (
+	// bucketBatches stores all batches, indexed by their ID, JSON-encoded
+	bucketBatches = []byte("batches")
+)
+
+// Errors returned by the manager.
+var (
+	ErrBatchNotFound = errors.New("batch not found")
+	ErrBatchMismatch = errors.New("batch ID is already used for a different payout list")
+	ErrInvalidID     = errors.New("batch ID has to consist of 1 up to 64 alphanumeric, '.', '_' or '-' characters")
+)
+
+// validID matches all valid batch IDs, which are safe to be used as part of an URL
+var validID = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// Status defines the status of a single transaction of a batch.
+type Status string
+
+// All possible statuses of a batch transaction.
+const (
+	// StatusPlanned is the status of a transaction which is only planned,
+	// as part of a dry run. It is unsigned and never stored.
+	StatusPlanned Status = "planned"
+	// StatusPending is the status of a signed transaction, which is stored,
+	// but not broadcasted yet. In case the daemon stopped prior to broadcasting it,
+	// it is broadcasted when the batch is resumed, the same way as a failed transaction.
+	StatusPending Status = "pending"
+	// StatusBroadcasted is the status of a signed transaction,
+	// which was accepted by the transaction pool.
+	StatusBroadcasted Status = "broadcasted"
+	// StatusConfirmed is the status of a transaction which is part of the blockchain.
+	StatusConfirmed Status = "confirmed"
+	// StatusFailed is the status of a signed transaction which wasn't accepted by the transaction pool,
+	// or which disappeared from it. As long as its inputs remain unspent,
+	// the same transaction is broadcasted again when the batch is resumed.
+	StatusFailed Status = "failed"
+	// StatusDropped is the status of a signed transaction which can no longer be confirmed,
+	// as (some of) its inputs were spent by another transaction.
+	// Its payouts are planned again in a new transaction when the batch is resumed.
+	StatusDropped Status = "dropped"
+)
+
+type (
+	// Manager pays out batches of payouts from the wallet,
+	// splitting each batch in as many transactions as required for them to fit in a block.
+	// All batches are stored, such that a partially failed batch can be resumed,
+	// without paying any payout twice.
+	Manager struct {
+		// The Manager's ThreadGroup tells tracked functions to shut down and
+		// blocks until they have all exited before returning from Close.
+		tg rivinesync.ThreadGroup
+
+		db *rivinepersist.BoltDatabase
+
+		cs        modules.ConsensusSet
+		tpool     modules.TransactionPool
+		wallet    modules.Wallet
+		constants types.ChainConstants
+
+		// mu ensures batches are sent one at a time
+		mu sync.Mutex
+	}
+
+	// Payout defines a single amount of coins to be paid to an address.
+	Payout struct {
+		Address types.UnlockHash `json:"address"`
+		Value   types.Currency   `json:"value"`
+		// Memo is only used for bookkeeping, and isn't stored in the transaction
+		Memo string `json:"memo,omitempty"`
+	}
+
+	// Batch is a list of payouts, paid by one or multiple transactions.
+	Batch struct {
+		ID          string          `json:"id"`
+		Description string          `json:"description,omitempty"`
+		Payouts     []Payout        `json:"payouts"`
+		Created     types.Timestamp `json:"created"`
+		// Transactions lists all transactions created for this batch,
+		// including the ones that were dropped, and thus replaced by newer transactions
+		Transactions []Transaction `json:"transactions"`
+		// Completed is true when all payouts are confirmed
+		Completed bool `json:"completed"`
+		// Total is the sum of the values of all payouts
+		Total types.Currency `json:"total"`
+		// Hash is the hash of the description and payouts,
+		// used to ensure a batch is only resumed using the same payout list
+		Hash crypto.Hash `json:"hash"`
+	}
+
+	// Transaction is a single transaction of a batch.
+	Transaction struct {
+		Transaction   types.Transaction   `json:"transaction"`
+		TransactionID types.TransactionID `json:"transactionid"`
+		Status        Status              `json:"status"`
+		// Payouts contains the indices of the batch payouts paid by this transaction
+		Payouts []int          `json:"payouts"`
+		Value   types.Currency `json:"value"`
+		Fee     types.Currency `json:"fee"`
+		// Size is the (estimated, in case the transaction is unsigned) size of the transaction in bytes
+		Size uint64 `json:"size"`
+		// Error is only defined if the last broadcast attempt failed
+		Error string `json:"error,omitempty"`
+	}
+)
+
+// New creates a new payout manager, paying the payouts using the given wallet,
+// broadcasting the transactions using the given transaction pool
+// and looking up the status of sent transactions using the given consensus set.
+// The given root directory is used to store its (single) persistent BoltDB file.
+func New(rootDir string, cs modules.ConsensusSet, tpool modules.TransactionPool, wallet modules.Wallet, constants types.ChainConstants) (*Manager, error) {
+	if cs == nil {
+		return nil, errors.New("payout manager requires a consensus set")
+	}
+	if tpool == nil {
+		return nil, errors.New("payout manager requires a transaction pool")
+	}
+	if wallet == nil {
+		return nil, errors.New("payout manager requires a wallet")
+	}
+
+	persistDir := path.Join(rootDir, ManagerDir)
+	// Create the directory if it doesn't exist.
+	err := os.MkdirAll(persistDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	manager := &Manager{
+		cs:        cs,
+		tpool:     tpool,
+		wallet:    wallet,
+		constants: constants,
+	}
+	err = manager.openDB(path.Join(persistDir, ManagerFilename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the payout manager DB: %v", err)
+	}
+	return manager, nil
+}
+
+// Send the given payouts as a batch with the given ID, generating a random ID if none is given.
+// In case the batch was sent before, using the same payouts and description, it is resumed:
+// transactions that failed are broadcasted again, while the payouts of dropped transactions
+// are paid using new transactions. Payouts of broadcasted or confirmed transactions are never paid again.
+//
+// In case dryRun is true, nothing is signed, broadcasted or stored,
+// and the returned batch contains the transactions that would be created, as planned transactions.
+func (manager *Manager) Send(id string, payouts []Payout, description string, dryRun bool) (Batch, error) {
+	if err := manager.tg.Add(); err != nil {
+		return Batch{}, err
+	}
+	defer manager.tg.Done()
+
+	batch, err := newBatch(id, payouts, description)
+	if err != nil {
+		return Batch{}, err
+	}
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	stored, err := manager.getBatch(batch.ID)
+	switch err {
+	case nil:
+		if stored.Hash != batch.Hash {
+			return Batch{}, ErrBatchMismatch
+		}
+		batch = stored
+	case ErrBatchNotFound:
+	default:
+		return Batch{}, err
+	}
+
+	// update the status of all transactions sent previously,
+	// broadcasting the failed ones again, unless this is a dry run
+	manager.updateStatuses(&batch, !dryRun)
+
+	// plan new transactions for all payouts which aren't paid yet
+	planned, err := manager.plan(batch)
+	if err != nil {
+		return Batch{}, err
+	}
+	if dryRun {
+		batch.Transactions = append(batch.Transactions, planned...)
+		return batch, nil
+	}
+
+	// sign and broadcast the new transactions, storing each signed transaction prior to broadcasting it,
+	// such that a transaction which might have been broadcasted is never forgotten,
+	// and thus its payouts are never paid again, even if the daemon stops in between
+	for _, txn := range planned {
+		txn.Transaction, err = manager.finalize(txn.Transaction)
+		if err != nil {
+			return Batch{}, err
+		}
+		txn.TransactionID = txn.Transaction.ID()
+		txn.Size = uint64(len(encoding.Marshal(txn.Transaction)))
+		txn.Status = StatusPending
+		batch.Transactions = append(batch.Transactions, txn)
+		err = manager.storeBatch(batch)
+		if err != nil {
+			return Batch{}, err
+		}
+		manager.broadcast(&batch.Transactions[len(batch.Transactions)-1])
+		err = manager.storeBatch(batch)
+		if err != nil {
+			return Batch{}, err
+		}
+	}
+	batch.Completed = isCompleted(batch)
+	err = manager.storeBatch(batch)
+	if err != nil {
+		return Batch{}, err
+	}
+	return batch, nil
+}
+
+// Batch returns the batch for the given ID, with the status of its transactions updated,
+// returning ErrBatchNotFound if it doesn't exist.
+func (manager *Manager) Batch(id string) (Batch, error) {
+	if err := manager.tg.Add(); err != nil {
+		return Batch{}, err
+	}
+	defer manager.tg.Done()
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	batch, err := manager.getBatch(id)
+	if err != nil {
+		return Batch{}, err
+	}
+	manager.updateStatuses(&batch, false)
+	return batch, nil
+}
+
+// Batches returns all stored batches, sorted by creation time.
+// The status of their transactions isn't updated.
+func (manager *Manager) Batches() ([]Batch, error) {
+	if err := manager.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer manager.tg.Done()
+
+	var batches []Batch
+	err := manager.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketBatches).ForEach(func(k, v []byte) error {
+			var batch Batch
+			err := json.Unmarshal(v, &batch)
+			if err != nil {
+				return fmt.Errorf("failed to decode batch %s: %v", string(k), err)
+			}
+			batches = append(batches, batch)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(batches, func(i, j int) bool {
+		return batches[i].Created < batches[j].Created
+	})
+	return batches, nil
+}
+
+// Close the manager,
+// meaning the threadgroup will be stopped and the internal bolt db will be closed.
+func (manager *Manager) Close() error {
+	if manager.db == nil {
+		return errors.New("payout manager is already closed or was never created")
+	}
+
+	// stop thread group
+	tgErr := manager.tg.Stop()
+	if tgErr != nil {
+		tgErr = fmt.Errorf("failed to stop the threadgroup of the payout manager: %v", tgErr)
+	}
+	// close database
+	dbErr := manager.db.Close()
+	if dbErr != nil {
+		dbErr = fmt.Errorf("failed to close the internal bolt db of the payout manager: %v", dbErr)
+	}
+	manager.db = nil
+
+	return build.ComposeErrors(tgErr, dbErr)
+}
+
+// newBatch validates the given payouts and creates a new batch for them,
+// generating a random ID if none is given
+func newBatch(id string, payouts []Payout, description string) (Batch, error) {
+	if id == "" {
+		id = hex.EncodeToString(fastrand.Bytes(16))
+	} else if !validID.MatchString(id) {
+		return Batch{}, ErrInvalidID
+	}
+	if len(payouts) == 0 {
+		return Batch{}, errors.New("no payouts given")
+	}
+	batch := Batch{
+		ID:          id,
+		Description: description,
+		Payouts:     payouts,
+		Created:     types.CurrentTimestamp(),
+	}
+	for idx, payout := range payouts {
+		if payout.Address.Type == types.UnlockTypeNil {
+			return Batch{}, fmt.Errorf("payout %d has no address", idx)
+		}
+		if payout.Value.IsZero() {
+			return Batch{}, fmt.Errorf("payout %d has a zero value", idx)
+		}
+		batch.Total = batch.Total.Add(payout.Value)
+	}
+	batch.Hash = crypto.HashAll(description, payouts)
+	return batch, nil
+}
+
+// isCompleted returns true if all payouts of the given batch are paid by confirmed transactions
+func isCompleted(batch Batch) bool {
+	var confirmed int
+	for _, txn := range batch.Transactions {
+		if txn.Status == StatusConfirmed {
+			confirmed += len(txn.Payouts)
+		}
+	}
+	return confirmed == len(batch.Payouts)
+}
+
+// updateStatuses updates the status of all signed transactions of the given batch,
+// optionally broadcasting failed transactions again
+func (manager *Manager) updateStatuses(batch *Batch, broadcast bool) {
+	unconfirmed := make(map[types.TransactionID]struct{})
+	unconfirmedSpent := make(map[types.CoinOutputID]struct{})
+	for _, utxn := range manager.tpool.TransactionList() {
+		unconfirmed[utxn.ID()] = struct{}{}
+		for _, ci := range utxn.CoinInputs {
+			unconfirmedSpent[ci.ParentID] = struct{}{}
+		}
+	}
+	for idx := range batch.Transactions {
+		txn := &batch.Transactions[idx]
+		switch txn.Status {
+		case StatusConfirmed, StatusDropped, StatusPlanned:
+			continue
+		}
+		if _, confirmed, err := manager.wallet.Transaction(txn.TransactionID); err == nil && confirmed {
+			txn.Status = StatusConfirmed
+			txn.Error = ""
+			continue
+		}
+		if _, ok := unconfirmed[txn.TransactionID]; ok {
+			txn.Status = StatusBroadcasted
+			continue
+		}
+		// the transaction is neither confirmed nor unconfirmed,
+		// it can only be broadcasted again as long as all its inputs are unspent
+		spendable := true
+		for _, ci := range txn.Transaction.CoinInputs {
+			_, spent := unconfirmedSpent[ci.ParentID]
+			if _, err := manager.cs.GetCoinOutput(ci.ParentID); err != nil || spent {
+				spendable = false
+				break
+			}
+		}
+		if spendable {
+			txn.Status = StatusFailed
+			if broadcast {
+				manager.broadcast(txn)
+			}
+			continue
+		}
+		// a locked wallet can't tell us whether or not the transaction was confirmed,
+		// in which case we leave it as it is
+		if manager.wallet.Unlocked() {
+			txn.Status = StatusDropped
+			txn.Error = "inputs were spent by another transaction"
+		}
+	}
+	batch.Completed = isCompleted(*batch)
+}
+
+// broadcast the signed transaction, updating its status accordingly
+func (manager *Manager) broadcast(txn *Transaction) {
+	err := manager.tpool.AcceptTransactionSet([]types.Transaction{txn.Transaction})
+	if err != nil && err != modules.ErrDuplicateTransactionSet {
+		txn.Status = StatusFailed
+		txn.Error = fmt.Sprintf("transaction pool did not accept transaction: %v", err)
+		return
+	}
+	txn.Status = StatusBroadcasted
+	txn.Error = ""
+}
+
+// finalize a planned transaction, by replacing its change condition with a new wallet address
+// and signing all its inputs.
+func (manager *Manager) finalize(txn types.Transaction) (types.Transaction, error) {
+	if n := len(txn.CoinOutputs); n > 0 && txn.CoinOutputs[n-1].Condition.UnlockHash().Cmp(changePlaceholder) == 0 {
+		uh, err := manager.wallet.NextAddress()
+		if err != nil {
+			return types.Transaction{}, fmt.Errorf("failed to create change address: %v", err)
+		}
+		txn.CoinOutputs[n-1].Condition = types.NewCondition(types.NewUnlockHashCondition(uh))
+	}
+	signedTxn, err := manager.wallet.GreedySign(txn)
+	if err != nil {
+		return types.Transaction{}, fmt.Errorf("failed to sign transaction: %v", err)
+	}
+	return signedTxn, nil
+}
+
+// openDB loads the set database and populates it with the necessary buckets
+func (manager *Manager) openDB(filename string) (err error) {
+	var (
+		dbMetadata = rivinepersist.Metadata{
+			Header:  "TFChain Payout Manager Database",
+			Version: "1.0.0",
+		}
+	)
+
+	manager.db, err = rivinepersist.OpenDatabase(dbMetadata, filename)
+	if err != nil {
+		return fmt.Errorf("error opening tfchain payout manager database: %v", err)
+	}
+	return manager.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketBatches)
+		if err != nil {
+			return fmt.Errorf("failed to create bucket %s: %v", string(bucketBatches), err)
+		}
+		return nil
+	})
+}
+
+// getBatch fetches a single batch from the database
+func (manager *Manager) getBatch(id string) (batch Batch, err error) {
+	err = manager.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketBatches).Get([]byte(id))
+		if len(b) == 0 {
+			return ErrBatchNotFound
+		}
+		return json.Unmarshal(b, &batch)
+	})
+	return
+}
+
+// storeBatch stores (or overwrites) a single batch in the database
+func (manager *Manager) storeBatch(batch Batch) error {
+	b, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch %s: %v", batch.ID, err)
+	}
+	err = manager.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketBatches).Put([]byte(batch.ID), b)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store batch %s: %v", batch.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package payouts
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+func TestSendStoresTransactionsPriorToBroadcasting(t *testing.T) {
+	wallet := newTestWallet(5, 100)
+	tpool := &testTransactionPool{}
+	manager, cleanup := newTestManager(t, wallet, tpool)
+	defer cleanup()
+
+	// each transaction has to be stored as pending when it is broadcasted,
+	// such that it is never paid twice, should the daemon stop while broadcasting
+	var broadcasted int
+	tpool.accept = func(txn types.Transaction) error {
+		broadcasted++
+		batch, err := manager.getBatch("batch")
+		if err != nil {
+			t.Fatal("batch not stored prior to broadcasting: ", err)
+		}
+		last := batch.Transactions[len(batch.Transactions)-1]
+		if last.TransactionID != txn.ID() || last.Status != StatusPending {
+			t.Errorf("expected transaction %s to be stored as pending prior to broadcasting it", txn.ID().String())
+		}
+		return nil
+	}
+	batch, err := manager.Send("batch", testPayouts(12), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Transactions) < 2 || broadcasted != len(batch.Transactions) {
+		t.Fatalf("expected multiple transactions to be broadcasted, got %d broadcasted out of %d", broadcasted, len(batch.Transactions))
+	}
+	for idx, txn := range batch.Transactions {
+		if txn.Status != StatusBroadcasted {
+			t.Errorf("expected transaction #%d to be broadcasted, not %s", idx, txn.Status)
+		}
+	}
+}
+
+func TestSendResume(t *testing.T) {
+	wallet := newTestWallet(5, 100)
+	tpool := &testTransactionPool{}
+	manager, cleanup := newTestManager(t, wallet, tpool)
+	defer cleanup()
+	payouts := testPayouts(12)
+
+	// the transaction pool rejects all but the first transaction
+	tpool.accept = func(types.Transaction) error {
+		if len(tpool.transactions) > 0 {
+			return errors.New("rejected")
+		}
+		return nil
+	}
+	batch, err := manager.Send("batch", payouts, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(batch.Transactions)
+	if n < 3 {
+		t.Fatalf("expected at least 3 transactions, not %d", n)
+	}
+	for idx, txn := range batch.Transactions[1:] {
+		if txn.Status != StatusFailed {
+			t.Errorf("expected transaction #%d to have failed, not %s", idx+1, txn.Status)
+		}
+	}
+
+	// simulate a daemon which stopped prior to broadcasting the last transaction
+	batch.Transactions[n-1].Status = StatusPending
+	err = manager.storeBatch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// resuming the batch broadcasts the same transactions again, without paying anything twice
+	tpool.accept = nil
+	resumed, err := manager.Send("batch", payouts, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResumedBatch(t, batch, resumed, StatusBroadcasted)
+	if len(tpool.transactions) != n {
+		t.Errorf("expected %d transactions to be broadcasted, not %d", n, len(tpool.transactions))
+	}
+
+	// once confirmed, resuming the batch is a no-op
+	for _, txn := range tpool.transactions {
+		wallet.confirm(txn)
+	}
+	tpool.transactions = nil
+	resumed, err = manager.Send("batch", payouts, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResumedBatch(t, batch, resumed, StatusConfirmed)
+	if !resumed.Completed || len(tpool.transactions) != 0 {
+		t.Errorf("expected the batch to be completed, without broadcasting any transaction")
+	}
+
+	// a batch ID can only be resumed using the same payouts
+	if _, err = manager.Send("batch", payouts[1:], "", false); err != ErrBatchMismatch {
+		t.Errorf("expected %v, not %v", ErrBatchMismatch, err)
+	}
+}
+
+func TestSendResumeDropped(t *testing.T) {
+	wallet := newTestWallet(5, 10)
+	tpool := &testTransactionPool{accept: func(types.Transaction) error { return errors.New("rejected") }}
+	manager, cleanup := newTestManager(t, wallet, tpool)
+	defer cleanup()
+	payouts := testPayouts(3)
+
+	batch, err := manager.Send("batch", payouts, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Transactions) != 1 || batch.Transactions[0].Status != StatusFailed {
+		t.Fatalf("expected a single failed transaction: %+v", batch.Transactions)
+	}
+
+	// spend an input of the failed transaction, such that it can no longer be confirmed
+	delete(wallet.outputs, batch.Transactions[0].Transaction.CoinInputs[0].ParentID)
+	tpool.accept = nil
+	resumed, err := manager.Send("batch", payouts, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.Transactions) != 2 {
+		t.Fatalf("expected the dropped transaction to be replaced, got %d transactions", len(resumed.Transactions))
+	}
+	dropped, replacement := resumed.Transactions[0], resumed.Transactions[1]
+	if dropped.Status != StatusDropped || replacement.Status != StatusBroadcasted {
+		t.Errorf("expected a dropped and broadcasted transaction, not %s and %s", dropped.Status, replacement.Status)
+	}
+	if len(replacement.Payouts) != len(payouts) {
+		t.Errorf("expected all %d payouts to be paid by the replacement, not %d", len(payouts), len(replacement.Payouts))
+	}
+}
+
+func checkResumedBatch(t *testing.T, batch, resumed Batch, status Status) {
+	t.Helper()
+	if len(resumed.Transactions) != len(batch.Transactions) {
+		t.Fatalf("expected no new transactions, got %d instead of %d", len(resumed.Transactions), len(batch.Transactions))
+	}
+	for idx, txn := range resumed.Transactions {
+		if txn.TransactionID != batch.Transactions[idx].TransactionID {
+			t.Errorf("expected transaction #%d to be the same transaction as before", idx)
+		}
+		if txn.Status != status {
+			t.Errorf("expected transaction #%d to be %s, not %s", idx, status, txn.Status)
+		}
+	}
+}
+
+// newTestManager creates a manager in a temporary directory,
+// returning a func which closes the manager and removes its directory
+func newTestManager(t *testing.T, wallet *testWallet, tpool *testTransactionPool) (*Manager, func()) {
+	dir, err := ioutil.TempDir("", "payouts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager, err := New(dir, &testConsensusSet{wallet: wallet}, tpool, wallet, testChainConstants())
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return manager, func() {
+		manager.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// testChainConstants limits the size of transactions,
+// such that a handful of payouts already requires multiple transactions
+func testChainConstants() types.ChainConstants {
+	constants := types.DefaultChainConstants()
+	constants.TransactionPool.TransactionSizeLimit = 500
+	return constants
+}
+
+func testPayouts(n int) []Payout {
+	payouts := make([]Payout, 0, n)
+	for i := 0; i < n; i++ {
+		payouts = append(payouts, Payout{
+			Address: testAddress(byte(i + 1)),
+			Value:   types.DefaultChainConstants().CurrencyUnits.OneCoin.Mul64(uint64(i + 1)),
+		})
+	}
+	return payouts
+}
+
+func testAddress(b byte) types.UnlockHash {
+	uh := types.UnlockHash{Type: types.UnlockTypePubKey}
+	uh.Hash[0] = b
+	return uh
+}
+
+// testWallet owns n outputs of the given amount of coins,
+// signing all inputs using a dummy signature
+type testWallet struct {
+	modules.Wallet
+	outputs   map[types.CoinOutputID]types.CoinOutput
+	confirmed map[types.TransactionID]struct{}
+}
+
+func newTestWallet(n int, coins uint64) *testWallet {
+	wallet := &testWallet{
+		outputs:   make(map[types.CoinOutputID]types.CoinOutput),
+		confirmed: make(map[types.TransactionID]struct{}),
+	}
+	for i := 0; i < n; i++ {
+		wallet.outputs[types.CoinOutputID{byte(i + 1)}] = types.CoinOutput{
+			Value:     types.DefaultChainConstants().CurrencyUnits.OneCoin.Mul64(coins),
+			Condition: types.NewCondition(types.NewUnlockHashCondition(testAddress(0xff))),
+		}
+	}
+	return wallet
+}
+
+// confirm the given transaction, spending its inputs
+func (w *testWallet) confirm(txn types.Transaction) {
+	w.confirmed[txn.ID()] = struct{}{}
+	for _, ci := range txn.CoinInputs {
+		delete(w.outputs, ci.ParentID)
+	}
+}
+
+func (w *testWallet) Unlocked() bool { return true }
+
+func (w *testWallet) NextAddress() (types.UnlockHash, error) { return testAddress(0xff), nil }
+
+func (w *testWallet) UnlockedUnspendOutputs() (map[types.CoinOutputID]types.CoinOutput, map[types.BlockStakeOutputID]types.BlockStakeOutput, error) {
+	outputs := make(map[types.CoinOutputID]types.CoinOutput, len(w.outputs))
+	for id, co := range w.outputs {
+		outputs[id] = co
+	}
+	return outputs, nil, nil
+}
+
+func (w *testWallet) GreedySign(txn types.Transaction) (types.Transaction, error) {
+	for idx := range txn.CoinInputs {
+		txn.CoinInputs[idx].Fulfillment = types.NewFulfillment(&types.SingleSignatureFulfillment{
+			PublicKey: types.Ed25519PublicKey(crypto.PublicKey{}),
+			Signature: make(types.ByteSlice, crypto.SignatureSize),
+		})
+	}
+	return txn, nil
+}
+
+func (w *testWallet) Transaction(id types.TransactionID) (modules.ProcessedTransaction, bool, error) {
+	_, ok := w.confirmed[id]
+	return modules.ProcessedTransaction{}, ok, nil
+}
+
+// testConsensusSet contains the unspent outputs of the given wallet
+type testConsensusSet struct {
+	modules.ConsensusSet
+	wallet *testWallet
+}
+
+func (cs *testConsensusSet) GetCoinOutput(id types.CoinOutputID) (types.CoinOutput, error) {
+	co, ok := cs.wallet.outputs[id]
+	if !ok {
+		return types.CoinOutput{}, errors.New("coin output not found")
+	}
+	return co, nil
+}
+
+// testTransactionPool accepts all transactions, unless the accept callback returns an error
+type testTransactionPool struct {
+	modules.TransactionPool
+	accept       func(types.Transaction) error
+	transactions []types.Transaction
+}
+
+func (tpool *testTransactionPool) TransactionList() []types.Transaction {
+	return tpool.transactions
+}
+
+func (tpool *testTransactionPool) AcceptTransactionSet(txns []types.Transaction) error {
+	for _, txn := range txns {
+		if tpool.accept != nil {
+			if err := tpool.accept(txn); err != nil {
+				return err
+			}
+		}
+		tpool.transactions = append(tpool.transactions, txn)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package payouts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// changePlaceholder is the address used for the change output of planned transactions,
+// replaced by a new wallet address once the transaction is finalized
+var changePlaceholder = types.NewPubKeyUnlockHash(types.Ed25519PublicKey(crypto.PublicKey{}))
+
+// fundingOutput is a spendable coin output of the wallet, which can fund a planned transaction
+type fundingOutput struct {
+	id    types.CoinOutputID
+	value types.Currency
+}
+
+// plan creates the unsigned transactions required to pay all payouts of the given batch,
+// which aren't paid yet by one of its transactions that weren't dropped.
+// Each transaction is kept as big as possible, while still fitting in a block,
+// and uses the biggest spendable outputs of the wallet first, keeping the amount of inputs small.
+func (manager *Manager) plan(batch Batch) ([]Transaction, error) {
+	// collect all payouts that still have to be paid,
+	// as well as the inputs that are reserved by transactions that can still be broadcasted
+	paid := make(map[int]struct{})
+	reserved := make(map[types.CoinOutputID]struct{})
+	for _, txn := range batch.Transactions {
+		if txn.Status == StatusDropped {
+			continue
+		}
+		for _, idx := range txn.Payouts {
+			paid[idx] = struct{}{}
+		}
+		for _, ci := range txn.Transaction.CoinInputs {
+			reserved[ci.ParentID] = struct{}{}
+		}
+	}
+	var unpaid []int
+	for idx := range batch.Payouts {
+		if _, ok := paid[idx]; !ok {
+			unpaid = append(unpaid, idx)
+		}
+	}
+	if len(unpaid) == 0 {
+		return nil, nil
+	}
+
+	funding, err := manager.fundingOutputs(reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	sizeLimit := manager.constants.BlockSizeLimit - 5e3 // see types.TransactionFitsInABlock
+	if limit := uint64(manager.constants.TransactionPool.TransactionSizeLimit); limit < sizeLimit {
+		sizeLimit = limit
+	}
+	if uint64(len(batch.Description)) > manager.constants.ArbitraryDataSizeLimit {
+		return nil, errors.New("description is too large to be added as arbitrary data")
+	}
+	fee := manager.constants.MinimumTransactionFee
+
+	var planned []Transaction
+	for len(unpaid) > 0 {
+		var (
+			txn = Transaction{
+				Status: StatusPlanned,
+				Fee:    fee,
+			}
+			inputs []fundingOutput
+			funded types.Currency
+		)
+		for len(unpaid) > 0 {
+			payout := batch.Payouts[unpaid[0]]
+
+			// fund the payout, on top of the payouts already added
+			required := txn.Value.Add(payout.Value).Add(fee)
+			n := len(inputs)
+			fundedWithPayout := funded
+			for fundedWithPayout.Cmp(required) < 0 && len(funding) > len(inputs)-n {
+				input := funding[len(inputs)-n]
+				inputs = append(inputs, input)
+				fundedWithPayout = fundedWithPayout.Add(input.value)
+			}
+			if fundedWithPayout.Cmp(required) < 0 {
+				return nil, fmt.Errorf("insufficient spendable coins in wallet to pay payout %d and onwards", unpaid[0])
+			}
+
+			// ensure the transaction still fits, once signed
+			size := estimateSize(manager.constants, batch, append(txn.Payouts, unpaid[0]), inputs, fundedWithPayout.Sub(required))
+			if size > sizeLimit {
+				inputs = inputs[:n]
+				if len(txn.Payouts) == 0 {
+					return nil, fmt.Errorf("payout %d cannot fit in a transaction of at most %d bytes", unpaid[0], sizeLimit)
+				}
+				break
+			}
+			funding = funding[len(inputs)-n:]
+			funded = fundedWithPayout
+			txn.Payouts = append(txn.Payouts, unpaid[0])
+			txn.Value = txn.Value.Add(payout.Value)
+			txn.Size = size
+			unpaid = unpaid[1:]
+		}
+		txn.Transaction = buildTransaction(manager.constants, batch, txn.Payouts, inputs, funded.Sub(txn.Value).Sub(fee))
+		planned = append(planned, txn)
+	}
+	return planned, nil
+}
+
+// fundingOutputs returns all spendable coin outputs of the wallet, sorted from big to small,
+// that can be signed using a single key of the wallet, and which aren't spent by unconfirmed transactions,
+// nor reserved by the given outputs.
+func (manager *Manager) fundingOutputs(reserved map[types.CoinOutputID]struct{}) ([]fundingOutput, error) {
+	outputs, _, err := manager.wallet.UnlockedUnspendOutputs()
+	if err != nil {
+		if err == modules.ErrLockedWallet {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get the spendable outputs of the wallet: %v", err)
+	}
+	for _, utxn := range manager.tpool.TransactionList() {
+		for _, ci := range utxn.CoinInputs {
+			reserved[ci.ParentID] = struct{}{}
+		}
+	}
+	var funding []fundingOutput
+	for id, co := range outputs {
+		if _, ok := reserved[id]; ok {
+			continue
+		}
+		if co.Condition.UnlockHash().Type != types.UnlockTypePubKey {
+			continue // multisig outputs require co-signers
+		}
+		funding = append(funding, fundingOutput{id: id, value: co.Value})
+	}
+	sort.Slice(funding, func(i, j int) bool {
+		if c := funding[i].value.Cmp(funding[j].value); c != 0 {
+			return c > 0
+		}
+		return funding[i].id.String() < funding[j].id.String()
+	})
+	return funding, nil
+}
+
+// buildTransaction builds the unsigned transaction, paying the given payouts of the batch,
+// funded by the given inputs, sending the change (if any) to the change placeholder address
+func buildTransaction(constants types.ChainConstants, batch Batch, payouts []int, inputs []fundingOutput, change types.Currency) types.Transaction {
+	txn := types.Transaction{
+		Version:   constants.DefaultTransactionVersion,
+		MinerFees: []types.Currency{constants.MinimumTransactionFee},
+	}
+	if batch.Description != "" {
+		txn.ArbitraryData = []byte(batch.Description)
+	}
+	for _, input := range inputs {
+		txn.CoinInputs = append(txn.CoinInputs, types.CoinInput{ParentID: input.id})
+	}
+	for _, idx := range payouts {
+		txn.CoinOutputs = append(txn.CoinOutputs, types.CoinOutput{
+			Value:     batch.Payouts[idx].Value,
+			Condition: types.NewCondition(types.NewUnlockHashCondition(batch.Payouts[idx].Address)),
+		})
+	}
+	if !change.IsZero() {
+		txn.CoinOutputs = append(txn.CoinOutputs, types.CoinOutput{
+			Value:     change,
+			Condition: types.NewCondition(types.NewUnlockHashCondition(changePlaceholder)),
+		})
+	}
+	return txn
+}
+
+// estimateSize estimates the size of the transaction, once signed,
+// by adding a single signature fulfillment to each input
+func estimateSize(constants types.ChainConstants, batch Batch, payouts []int, inputs []fundingOutput, change types.Currency) uint64 {
+	txn := buildTransaction(constants, batch, payouts, inputs, change)
+	for idx := range txn.CoinInputs {
+		txn.CoinInputs[idx].Fulfillment = types.NewFulfillment(&types.SingleSignatureFulfillment{
+			PublicKey: types.Ed25519PublicKey(crypto.PublicKey{}),
+			Signature: make(types.ByteSlice, crypto.SignatureSize),
+		})
+	}
+	return uint64(len(encoding.Marshal(txn)))
+}
@@ -0,0 +1,137 @@
+package payouts
+
+import (
+	"testing"
+
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/types"
+)
+
+func TestPlan(t *testing.T) {
+	wallet := newTestWallet(5, 10)
+	manager, cleanup := newTestManager(t, wallet, &testTransactionPool{})
+	defer cleanup()
+
+	batch, err := newBatch("batch", testPayouts(6), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	planned, err := manager.plan(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(planned) < 2 {
+		t.Fatalf("expected the payouts to be split over multiple transactions, got %d", len(planned))
+	}
+	checkPlanned(t, manager, batch, planned, nil)
+
+	// payouts of transactions that weren't dropped are paid already,
+	// and their inputs are reserved
+	batch.Transactions = planned[:1]
+	batch.Transactions[0].Status = StatusFailed
+	replanned, err := manager.plan(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPlanned(t, manager, batch, replanned, planned[0].Transaction.CoinInputs)
+
+	// payouts of dropped transactions are planned again
+	batch.Transactions[0].Status = StatusDropped
+	replanned, err = manager.plan(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPlanned(t, manager, batch, replanned, nil)
+
+	// all payouts are paid already
+	batch.Transactions = planned
+	for idx := range batch.Transactions {
+		batch.Transactions[idx].Status = StatusBroadcasted
+	}
+	replanned, err = manager.plan(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replanned) != 0 {
+		t.Errorf("expected no transactions to be planned, got %d", len(replanned))
+	}
+}
+
+func TestPlanInsufficientFunds(t *testing.T) {
+	manager, cleanup := newTestManager(t, newTestWallet(1, 10), &testTransactionPool{})
+	defer cleanup()
+
+	// the payouts are worth 1+2+3+4 coins, while the fee requires another coin
+	batch, err := newBatch("batch", testPayouts(4), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = manager.plan(batch); err == nil {
+		t.Error("expected planning to fail due to insufficient funds")
+	}
+}
+
+// checkPlanned checks that the planned transactions pay each unpaid payout exactly once,
+// are funded correctly using unreserved inputs only, and fit in a block once signed
+func checkPlanned(t *testing.T, manager *Manager, batch Batch, planned []Transaction, reserved []types.CoinInput) {
+	t.Helper()
+	paid := make(map[int]int)
+	for _, txn := range batch.Transactions {
+		if txn.Status != StatusDropped {
+			for _, idx := range txn.Payouts {
+				paid[idx]++
+			}
+		}
+	}
+	spent := make(map[types.CoinOutputID]struct{})
+	for _, ci := range reserved {
+		spent[ci.ParentID] = struct{}{}
+	}
+	sizeLimit := uint64(manager.constants.TransactionPool.TransactionSizeLimit)
+	for idx, txn := range planned {
+		if txn.Status != StatusPlanned {
+			t.Errorf("transaction #%d: expected status %s, not %s", idx, StatusPlanned, txn.Status)
+		}
+		if txn.Size > sizeLimit {
+			t.Errorf("transaction #%d: size %d exceeds the limit of %d bytes", idx, txn.Size, sizeLimit)
+		}
+		signed, err := manager.wallet.GreedySign(txn.Transaction)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size := uint64(len(encoding.Marshal(signed))); size != txn.Size {
+			t.Errorf("transaction #%d: estimated a size of %d bytes, while it is %d bytes once signed", idx, txn.Size, size)
+		}
+		var inputs, outputs types.Currency
+		for _, ci := range txn.Transaction.CoinInputs {
+			if _, ok := spent[ci.ParentID]; ok {
+				t.Errorf("transaction #%d: input %s is reserved or spent twice", idx, ci.ParentID.String())
+			}
+			spent[ci.ParentID] = struct{}{}
+			co, err := manager.cs.GetCoinOutput(ci.ParentID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inputs = inputs.Add(co.Value)
+		}
+		for _, co := range txn.Transaction.CoinOutputs {
+			outputs = outputs.Add(co.Value)
+		}
+		if !inputs.Equals(outputs.Add(txn.Fee)) {
+			t.Errorf("transaction #%d: inputs of %v do not equal the outputs of %v and fee of %v", idx, inputs, outputs, txn.Fee)
+		}
+		var value types.Currency
+		for _, p := range txn.Payouts {
+			paid[p]++
+			value = value.Add(batch.Payouts[p].Value)
+		}
+		if !value.Equals(txn.Value) {
+			t.Errorf("transaction #%d: expected a value of %v, not %v", idx, value, txn.Value)
+		}
+	}
+	for idx := range batch.Payouts {
+		if paid[idx] != 1 {
+			t.Errorf("payout %d is paid %d times", idx, paid[idx])
+		}
+	}
+}
@@ -0,0 +1,577 @@
+package proposals
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	tftypes "github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/NebulousLabs/fastrand"
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	rivinepersist "github.com/rivine/rivine/persist"
+	rivinesync "github.com/rivine/rivine/sync"
+	"github.com/rivine/rivine/types"
+
+	bolt "github.com/rivine/bbolt"
+)
+
+// Pool I/O constants
+const (
+	PoolDir      = "proposals"
+	PoolFilename = PoolDir + ".db"
+)
+
+const (
+	// DefaultLifetime is the lifetime of a proposal,
+	// used in case no lifetime is given when submitting it.
+	DefaultLifetime = 7 * 24 * time.Hour
+	// MaxLifetime is the maximum lifetime a proposal can have.
+	MaxLifetime = 30 * 24 * time.Hour
+
+	// expiryCheckInterval defines how often the pool checks for expired proposals
+	expiryCheckInterval = time.Minute
+)
+
+// internal bucket database keys used for the pool
+var (
+	// bucketProposals stores all proposals, JSON-encoded and indexed by their ID
+	bucketProposals = []byte("proposals")
+)
+
+// Errors returned by the pool.
+var (
+	ErrProposalNotFound   = errors.New("proposal not found")
+	ErrProposalNotPending = errors.New("proposal is no longer pending")
+)
+
+// Status defines the status of a proposal.
+type Status string
+
+// All possible statuses of a proposal.
+const (
+	// StatusPending is the status of a proposal which still requires signatures,
+	// or which couldn't be broadcasted yet.
+	StatusPending Status = "pending"
+	// StatusBroadcasted is the status of a proposal which was fully signed,
+	// and accepted by the transaction pool.
+	StatusBroadcasted Status = "broadcasted"
+	// StatusExpired is the status of a proposal which wasn't broadcasted prior to its expiration.
+	StatusExpired Status = "expired"
+)
+
+// RequirementType defines the type of the fulfillment a requirement applies to.
+type RequirementType string
+
+// All possible requirement types.
+const (
+	RequirementTypeCoinInput       RequirementType = "coininput"
+	RequirementTypeBlockStakeInput RequirementType = "blockstakeinput"
+	RequirementTypeMint            RequirementType = "mint"
+)
+
+type (
+	// Pool collects unsigned (or partially signed) transactions as proposals,
+	// such that co-signers can add their signatures one by one,
+	// broadcasting each proposal as soon as all its conditions are fulfilled.
+	Pool struct {
+		// The Pool's ThreadGroup tells tracked functions to shut down and
+		// blocks until they have all exited before returning from Close.
+		tg rivinesync.ThreadGroup
+
+		db *rivinepersist.BoltDatabase
+
+		cs                  modules.ConsensusSet
+		tpool               modules.TransactionPool
+		mintConditionGetter tftypes.MintConditionGetter
+
+		// mu ensures proposals are updated one at a time
+		mu sync.Mutex
+	}
+
+	// Proposal is a transaction that is collecting the signatures
+	// required for it to be broadcasted.
+	Proposal struct {
+		ID          crypto.Hash       `json:"id"`
+		Transaction types.Transaction `json:"transaction"`
+		Description string            `json:"description,omitempty"`
+		Created     types.Timestamp   `json:"created"`
+		Expires     types.Timestamp   `json:"expires"`
+		Status      Status            `json:"status"`
+		// Requirements lists the requirement for each fulfillment of the transaction,
+		// in the same order as returned by tftypes.TransactionFulfillments
+		Requirements []Requirement `json:"requirements"`
+		// TransactionID is only defined once the proposal has been broadcasted
+		TransactionID types.TransactionID `json:"transactionid,omitempty"`
+		// Error is only defined if the last broadcast attempt failed
+		Error string `json:"error,omitempty"`
+	}
+
+	// Requirement defines the condition a single fulfillment of a proposed transaction has to fulfill,
+	// who can sign it and who already signed it.
+	Requirement struct {
+		Type RequirementType `json:"type"`
+		// Index of the input, ignored for the mint requirement
+		Index     uint64                     `json:"index"`
+		Condition types.UnlockConditionProxy `json:"condition"`
+		Signers   []types.UnlockHash         `json:"signers"`
+		Signed    []types.UnlockHash         `json:"signed"`
+		Fulfilled bool                       `json:"fulfilled"`
+	}
+
+	// Filter can be used to filter the proposals returned by the pool.
+	// All defined properties have to match.
+	Filter struct {
+		// UnlockHash matches all proposals which this unlock hash can sign
+		UnlockHash *types.UnlockHash
+		Status     *Status
+	}
+)
+
+// New creates a new proposal pool, using the given consensus set to look up the conditions
+// of the parent outputs, the given mint condition getter to look up the active mint condition,
+// and the given transaction pool to broadcast fully signed proposals.
+// The given root directory is used to store its (single) persistent BoltDB file.
+func New(rootDir string, cs modules.ConsensusSet, tpool modules.TransactionPool, mintConditionGetter tftypes.MintConditionGetter) (*Pool, error) {
+	if cs == nil {
+		return nil, errors.New("proposal pool requires a consensus set")
+	}
+	if tpool == nil {
+		return nil, errors.New("proposal pool requires a transaction pool")
+	}
+	if mintConditionGetter == nil {
+		return nil, errors.New("proposal pool requires a mint condition getter")
+	}
+
+	persistDir := path.Join(rootDir, PoolDir)
+	// Create the directory if it doesn't exist.
+	err := os.MkdirAll(persistDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := &Pool{
+		cs:                  cs,
+		tpool:               tpool,
+		mintConditionGetter: mintConditionGetter,
+	}
+	err = pool.openDB(path.Join(persistDir, PoolFilename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the proposal pool DB: %v", err)
+	}
+
+	go pool.threadedExpire()
+	return pool, nil
+}
+
+// Submit a transaction as a new proposal, which expires after the given lifetime,
+// or after the DefaultLifetime if no lifetime is given.
+// The transaction can already be partially signed,
+// and is broadcasted immediately if it is already fully signed.
+func (pool *Pool) Submit(txn types.Transaction, description string, lifetime time.Duration) (Proposal, error) {
+	if lifetime <= 0 {
+		lifetime = DefaultLifetime
+	}
+	if lifetime > MaxLifetime {
+		return Proposal{}, fmt.Errorf("lifetime cannot exceed %v", MaxLifetime)
+	}
+	if err := pool.tg.Add(); err != nil {
+		return Proposal{}, err
+	}
+	defer pool.tg.Done()
+
+	requirements, err := pool.requirements(txn)
+	if err != nil {
+		return Proposal{}, err
+	}
+	err = verifyFulfillments(txn, requirements)
+	if err != nil {
+		return Proposal{}, err
+	}
+	proposal := Proposal{
+		Transaction:  txn,
+		Description:  description,
+		Created:      types.CurrentTimestamp(),
+		Expires:      types.OffsetTimestamp(lifetime),
+		Status:       StatusPending,
+		Requirements: requirements,
+	}
+	fastrand.Read(proposal.ID[:])
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.updateRequirements(&proposal)
+	pool.tryBroadcast(&proposal)
+	err = pool.storeProposal(proposal)
+	if err != nil {
+		return Proposal{}, err
+	}
+	return proposal, nil
+}
+
+// Sign a pending proposal, by merging the fulfillments of the given (signed) transaction
+// into the transaction of the proposal. The given transaction has to be equal
+// to the proposed transaction, ignoring the fulfillments.
+// The proposal is broadcasted as soon as all its conditions are fulfilled.
+func (pool *Pool) Sign(id crypto.Hash, txn types.Transaction) (Proposal, error) {
+	if err := pool.tg.Add(); err != nil {
+		return Proposal{}, err
+	}
+	defer pool.tg.Done()
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	proposal, err := pool.getProposal(id)
+	if err != nil {
+		return Proposal{}, err
+	}
+	if proposal.Status != StatusPending {
+		return Proposal{}, ErrProposalNotPending
+	}
+	if proposal.Expires < types.CurrentTimestamp() {
+		proposal.Status = StatusExpired
+		err = pool.storeProposal(proposal)
+		if err != nil {
+			return Proposal{}, err
+		}
+		return Proposal{}, ErrProposalNotPending
+	}
+
+	err = pool.refreshMintCondition(&proposal)
+	if err != nil {
+		return Proposal{}, err
+	}
+	if !tftypes.TransactionsEqualUnsigned(proposal.Transaction, txn) {
+		return Proposal{}, errors.New("signed transaction differs from the proposed transaction")
+	}
+	err = verifyFulfillments(txn, proposal.Requirements)
+	if err != nil {
+		return Proposal{}, err
+	}
+	proposal.Transaction, err = tftypes.MergeTransactionFulfillments(proposal.Transaction, txn)
+	if err != nil {
+		return Proposal{}, err
+	}
+	pool.updateRequirements(&proposal)
+	pool.tryBroadcast(&proposal)
+	err = pool.storeProposal(proposal)
+	if err != nil {
+		return Proposal{}, err
+	}
+	return proposal, nil
+}
+
+// Proposal returns the proposal for the given ID,
+// returning ErrProposalNotFound if it doesn't exist.
+func (pool *Pool) Proposal(id crypto.Hash) (Proposal, error) {
+	if err := pool.tg.Add(); err != nil {
+		return Proposal{}, err
+	}
+	defer pool.tg.Done()
+	return pool.getProposal(id)
+}
+
+// Proposals returns all proposals matching the given filter,
+// ordered from oldest to newest.
+func (pool *Pool) Proposals(filter Filter) ([]Proposal, error) {
+	if err := pool.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer pool.tg.Done()
+
+	var proposals []Proposal
+	err := pool.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketProposals).ForEach(func(k, v []byte) error {
+			var proposal Proposal
+			err := json.Unmarshal(v, &proposal)
+			if err != nil {
+				return fmt.Errorf("failed to decode proposal %x: %v", k, err)
+			}
+			if filter.match(proposal) {
+				proposals = append(proposals, proposal)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(proposals, func(i, j int) bool {
+		return proposals[i].Created < proposals[j].Created
+	})
+	return proposals, nil
+}
+
+// Close the pool,
+// meaning the threadgroup will be stopped and the internal bolt db will be closed.
+func (pool *Pool) Close() error {
+	if pool.db == nil {
+		return errors.New("proposal pool is already closed or was never created")
+	}
+
+	// stop thread group
+	tgErr := pool.tg.Stop()
+	if tgErr != nil {
+		tgErr = fmt.Errorf("failed to stop the threadgroup of the proposal pool: %v", tgErr)
+	}
+	// close database
+	dbErr := pool.db.Close()
+	if dbErr != nil {
+		dbErr = fmt.Errorf("failed to close the internal bolt db of the proposal pool: %v", dbErr)
+	}
+	pool.db = nil
+
+	return build.ComposeErrors(tgErr, dbErr)
+}
+
+// match returns true if the given proposal matches the filter.
+func (filter Filter) match(proposal Proposal) bool {
+	if filter.Status != nil && *filter.Status != proposal.Status {
+		return false
+	}
+	if filter.UnlockHash == nil {
+		return true
+	}
+	for _, requirement := range proposal.Requirements {
+		for _, uh := range requirement.Signers {
+			if uh.Cmp(*filter.UnlockHash) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// openDB loads the set database and populates it with the necessary buckets
+func (pool *Pool) openDB(filename string) (err error) {
+	var (
+		dbMetadata = rivinepersist.Metadata{
+			Header:  "TFChain Proposal Pool Database",
+			Version: "1.0.0",
+		}
+	)
+
+	pool.db, err = rivinepersist.OpenDatabase(dbMetadata, filename)
+	if err != nil {
+		return fmt.Errorf("error opening tfchain proposal pool database: %v", err)
+	}
+	return pool.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketProposals)
+		if err != nil {
+			return fmt.Errorf("failed to create bucket %s: %v", string(bucketProposals), err)
+		}
+		return nil
+	})
+}
+
+// getProposal fetches a single proposal from the database
+func (pool *Pool) getProposal(id crypto.Hash) (proposal Proposal, err error) {
+	err = pool.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketProposals).Get(id[:])
+		if len(b) == 0 {
+			return ErrProposalNotFound
+		}
+		return json.Unmarshal(b, &proposal)
+	})
+	return
+}
+
+// storeProposal stores (or overwrites) a single proposal in the database
+func (pool *Pool) storeProposal(proposal Proposal) error {
+	b, err := json.Marshal(proposal)
+	if err != nil {
+		return fmt.Errorf("failed to encode proposal %s: %v", proposal.ID.String(), err)
+	}
+	err = pool.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketProposals).Put(proposal.ID[:], b)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store proposal %s: %v", proposal.ID.String(), err)
+	}
+	return nil
+}
+
+// requirements creates the requirements for all fulfillments of the given transaction,
+// looking up the condition of each parent output, as well as the active mint condition if required.
+func (pool *Pool) requirements(txn types.Transaction) ([]Requirement, error) {
+	var requirements []Requirement
+	for idx, ci := range txn.CoinInputs {
+		co, err := pool.cs.GetCoinOutput(ci.ParentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parent output %s of coin input #%d: %v", ci.ParentID.String(), idx, err)
+		}
+		requirements = append(requirements, Requirement{
+			Type:      RequirementTypeCoinInput,
+			Index:     uint64(idx),
+			Condition: co.Condition,
+		})
+	}
+	for idx, bsi := range txn.BlockStakeInputs {
+		bso, err := pool.cs.GetBlockStakeOutput(bsi.ParentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parent output %s of block stake input #%d: %v", bsi.ParentID.String(), idx, err)
+		}
+		requirements = append(requirements, Requirement{
+			Type:      RequirementTypeBlockStakeInput,
+			Index:     uint64(idx),
+			Condition: bso.Condition,
+		})
+	}
+	switch txn.Extension.(type) {
+	case *tftypes.CoinCreationTransactionExtension, *tftypes.MinterDefinitionTransactionExtension:
+		mintCondition, err := pool.mintConditionGetter.GetActiveMintCondition()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the active mint condition: %v", err)
+		}
+		requirements = append(requirements, Requirement{
+			Type:      RequirementTypeMint,
+			Condition: mintCondition,
+		})
+	}
+	if len(requirements) == 0 {
+		return nil, errors.New("transaction has nothing that requires signing")
+	}
+	for idx := range requirements {
+		requirements[idx].Signers = tftypes.ConditionUnlockHashes(requirements[idx].Condition)
+	}
+	return requirements, nil
+}
+
+// refreshMintCondition updates the mint requirement of the proposal, if it has one,
+// to the active mint condition, as the minters might have been redefined since the proposal was submitted.
+func (pool *Pool) refreshMintCondition(proposal *Proposal) error {
+	for idx := range proposal.Requirements {
+		requirement := &proposal.Requirements[idx]
+		if requirement.Type != RequirementTypeMint {
+			continue
+		}
+		mintCondition, err := pool.mintConditionGetter.GetActiveMintCondition()
+		if err != nil {
+			return fmt.Errorf("failed to get the active mint condition: %v", err)
+		}
+		requirement.Condition = mintCondition
+		requirement.Signers = tftypes.ConditionUnlockHashes(mintCondition)
+	}
+	return nil
+}
+
+// verifyFulfillments verifies all signatures of the given transaction,
+// against the conditions of the given requirements, such that no invalid signature is ever stored.
+func verifyFulfillments(txn types.Transaction, requirements []Requirement) error {
+	fulfillments := tftypes.TransactionFulfillments(&txn)
+	for idx, requirement := range requirements {
+		err := tftypes.VerifyFulfillmentSignatures(txn, requirement.Index, *fulfillments[idx], requirement.Condition)
+		if err != nil {
+			return fmt.Errorf("invalid %s fulfillment #%d: %v", requirement.Type, requirement.Index, err)
+		}
+	}
+	return nil
+}
+
+// updateRequirements updates who signed each requirement of the proposal,
+// and whether or not each requirement is fulfilled.
+func (pool *Pool) updateRequirements(proposal *Proposal) {
+	ctx := types.FulfillContext{
+		BlockHeight: pool.cs.Height(),
+		BlockTime:   pool.cs.CurrentBlock().Timestamp,
+		Transaction: proposal.Transaction,
+	}
+	txn := proposal.Transaction
+	fulfillments := tftypes.TransactionFulfillments(&txn)
+	for idx := range proposal.Requirements {
+		requirement := &proposal.Requirements[idx]
+		fulfillment := *fulfillments[idx]
+		requirement.Signed = nil
+		for _, pk := range tftypes.FulfillmentPublicKeys(fulfillment) {
+			requirement.Signed = append(requirement.Signed, types.NewPubKeyUnlockHash(pk))
+		}
+		ctx.InputIndex = requirement.Index
+		requirement.Fulfilled = requirement.Condition.Fulfill(fulfillment, ctx) == nil
+	}
+}
+
+// tryBroadcast broadcasts the transaction of the given proposal,
+// in case all its requirements are fulfilled.
+func (pool *Pool) tryBroadcast(proposal *Proposal) {
+	for _, requirement := range proposal.Requirements {
+		if !requirement.Fulfilled {
+			return
+		}
+	}
+	err := pool.tpool.AcceptTransactionSet([]types.Transaction{proposal.Transaction})
+	if err != nil && err != modules.ErrDuplicateTransactionSet {
+		proposal.Error = fmt.Sprintf("transaction pool did not accept transaction: %v", err)
+		return
+	}
+	proposal.Status = StatusBroadcasted
+	proposal.TransactionID = proposal.Transaction.ID()
+	proposal.Error = ""
+}
+
+// threadedExpire marks all pending proposals as expired once their lifetime has passed,
+// until the pool is closed.
+func (pool *Pool) threadedExpire() {
+	if err := pool.tg.Add(); err != nil {
+		return
+	}
+	defer pool.tg.Done()
+
+	ticker := time.NewTicker(expiryCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pool.tg.StopChan():
+			return
+		case <-ticker.C:
+			pool.expire()
+		}
+	}
+}
+
+// expire marks all pending proposals as expired, for which the expiration time has passed.
+func (pool *Pool) expire() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	now := types.CurrentTimestamp()
+	err := pool.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketProposals)
+		var expired []Proposal
+		err := bucket.ForEach(func(k, v []byte) error {
+			var proposal Proposal
+			err := json.Unmarshal(v, &proposal)
+			if err != nil {
+				return fmt.Errorf("failed to decode proposal %x: %v", k, err)
+			}
+			if proposal.Status == StatusPending && proposal.Expires < now {
+				proposal.Status = StatusExpired
+				expired = append(expired, proposal)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, proposal := range expired {
+			b, err := json.Marshal(proposal)
+			if err != nil {
+				return fmt.Errorf("failed to encode proposal %s: %v", proposal.ID.String(), err)
+			}
+			err = bucket.Put(proposal.ID[:], b)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		build.Severe(fmt.Sprintf("failed to expire proposals: %v", err))
+	}
+}
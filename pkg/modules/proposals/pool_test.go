@@ -0,0 +1,230 @@
+package proposals
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	tftypes "github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+func TestPoolSignMultiSignature(t *testing.T) {
+	keys := newTestKeys(3)
+	parentID := types.CoinOutputID{1}
+	cs := &testConsensusSet{coinOutputs: map[types.CoinOutputID]types.CoinOutput{
+		parentID: {
+			Value: types.NewCurrency64(100),
+			Condition: types.NewCondition(types.NewMultiSignatureCondition(
+				types.UnlockHashSlice{keys[0].unlockHash(), keys[1].unlockHash()}, 2)),
+		},
+	}}
+	tpool := new(testTransactionPool)
+	pool, cleanup := newTestPool(t, cs, tpool, &testMintConditionGetter{})
+	defer cleanup()
+
+	txn := types.Transaction{
+		Version:     types.TransactionVersionOne,
+		CoinInputs:  []types.CoinInput{{ParentID: parentID}},
+		CoinOutputs: []types.CoinOutput{{Value: types.NewCurrency64(99), Condition: types.NewCondition(types.NewUnlockHashCondition(keys[2].unlockHash()))}},
+		MinerFees:   []types.Currency{types.NewCurrency64(1)},
+	}
+	proposal, err := pool.Submit(txn, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proposal.Status != StatusPending || len(proposal.Requirements) != 1 || len(proposal.Requirements[0].Signers) != 2 {
+		t.Fatalf("unexpected proposal: %+v", proposal)
+	}
+
+	// signatures of keys which cannot sign, or which are invalid, are never stored
+	if _, err = pool.Sign(proposal.ID, keys[2].signMultiSignature(t, txn)); err == nil {
+		t.Error("expected signature of a key which cannot sign the condition to be rejected")
+	}
+	invalid := keys[0].signMultiSignature(t, txn)
+	invalid.CoinInputs[0].Fulfillment.Fulfillment.(*types.MultiSignatureFulfillment).Pairs[0].Signature[0]++
+	if _, err = pool.Sign(proposal.ID, invalid); err == nil {
+		t.Error("expected invalid signature to be rejected")
+	}
+	if proposal, err = pool.Proposal(proposal.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(proposal.Requirements[0].Signed) != 0 {
+		t.Fatalf("expected no signatures to be stored, not %d", len(proposal.Requirements[0].Signed))
+	}
+
+	// the proposal is broadcasted as soon as both keys signed
+	proposal, err = pool.Sign(proposal.ID, keys[0].signMultiSignature(t, txn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proposal.Status != StatusPending || len(proposal.Requirements[0].Signed) != 1 {
+		t.Fatalf("expected proposal to be pending with a single signature: %+v", proposal)
+	}
+	proposal, err = pool.Sign(proposal.ID, keys[1].signMultiSignature(t, txn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proposal.Status != StatusBroadcasted || len(tpool.transactions) != 1 {
+		t.Fatalf("expected proposal to be broadcasted: %+v", proposal)
+	}
+	if id := tpool.transactions[0].ID(); id != proposal.TransactionID {
+		t.Errorf("expected transaction %s to be broadcasted, not %s", proposal.TransactionID.String(), id.String())
+	}
+	if _, err = pool.Sign(proposal.ID, keys[1].signMultiSignature(t, txn)); err != ErrProposalNotPending {
+		t.Errorf("expected %v, not %v", ErrProposalNotPending, err)
+	}
+}
+
+func TestPoolSignRedefinedMintCondition(t *testing.T) {
+	keys := newTestKeys(2)
+	mintConditionGetter := &testMintConditionGetter{
+		condition: types.NewCondition(types.NewUnlockHashCondition(keys[0].unlockHash())),
+	}
+	tftypes.RegisterTransactionTypesForDevNetwork(mintConditionGetter)
+	tpool := new(testTransactionPool)
+	pool, cleanup := newTestPool(t, &testConsensusSet{}, tpool, mintConditionGetter)
+	defer cleanup()
+
+	cctx := tftypes.CoinCreationTransaction{
+		CoinOutputs: []types.CoinOutput{{Value: types.NewCurrency64(100), Condition: types.NewCondition(types.NewUnlockHashCondition(keys[1].unlockHash()))}},
+		MinerFees:   []types.Currency{types.NewCurrency64(1)},
+	}
+	txn := cctx.Transaction()
+	proposal, err := pool.Submit(txn, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// redefine the minters, such that the original minter can no longer sign
+	mintConditionGetter.condition = types.NewCondition(types.NewUnlockHashCondition(keys[1].unlockHash()))
+	if _, err = pool.Sign(proposal.ID, keys[0].signMint(t, txn)); err == nil {
+		t.Error("expected signature of the redefined minter to be rejected")
+	}
+	proposal, err = pool.Sign(proposal.ID, keys[1].signMint(t, txn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proposal.Status != StatusBroadcasted {
+		t.Fatalf("expected proposal to be broadcasted: %+v", proposal)
+	}
+	if uh := proposal.Requirements[0].Condition.UnlockHash(); uh.Cmp(keys[1].unlockHash()) != 0 {
+		t.Errorf("expected mint requirement to be updated to the active mint condition, not %s", uh.String())
+	}
+}
+
+// newTestPool creates a pool in a temporary directory,
+// returning a func which closes the pool and removes its directory
+func newTestPool(t *testing.T, cs modules.ConsensusSet, tpool modules.TransactionPool, mintConditionGetter tftypes.MintConditionGetter) (*Pool, func()) {
+	dir, err := ioutil.TempDir("", "proposals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := New(dir, cs, tpool, mintConditionGetter)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return pool, func() {
+		pool.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+type testKey struct {
+	pk types.SiaPublicKey
+	sk crypto.SecretKey
+}
+
+func newTestKeys(n int) []testKey {
+	keys := make([]testKey, 0, n)
+	for i := 0; i < n; i++ {
+		sk, pk := crypto.GenerateKeyPairDeterministic([crypto.EntropySize]byte{byte(i)})
+		keys = append(keys, testKey{pk: types.Ed25519PublicKey(pk), sk: sk})
+	}
+	return keys
+}
+
+func (key testKey) unlockHash() types.UnlockHash {
+	return types.NewPubKeyUnlockHash(key.pk)
+}
+
+// signMultiSignature returns a copy of the given transaction,
+// of which the first coin input is signed using a multisig fulfillment
+func (key testKey) signMultiSignature(t *testing.T, txn types.Transaction) types.Transaction {
+	fulfillment := new(types.MultiSignatureFulfillment)
+	err := fulfillment.Sign(types.FulfillmentSignContext{
+		InputIndex:  0,
+		Transaction: txn,
+		Key:         types.KeyPair{PublicKey: key.pk, PrivateKey: key.sk[:]},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn.CoinInputs = []types.CoinInput{{ParentID: txn.CoinInputs[0].ParentID, Fulfillment: types.NewFulfillment(fulfillment)}}
+	return txn
+}
+
+// signMint returns a copy of the given transaction,
+// of which the mint fulfillment is signed using a single signature fulfillment
+func (key testKey) signMint(t *testing.T, txn types.Transaction) types.Transaction {
+	fulfillment := &types.SingleSignatureFulfillment{PublicKey: key.pk}
+	err := fulfillment.Sign(types.FulfillmentSignContext{
+		InputIndex:  0,
+		Transaction: txn,
+		Key:         key.sk,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fulfillments := tftypes.TransactionFulfillments(&txn)
+	*fulfillments[len(fulfillments)-1] = types.NewFulfillment(fulfillment)
+	return txn
+}
+
+// testConsensusSet is a consensus set of a single block, containing the given coin outputs
+type testConsensusSet struct {
+	modules.ConsensusSet
+	coinOutputs map[types.CoinOutputID]types.CoinOutput
+}
+
+func (cs *testConsensusSet) GetCoinOutput(id types.CoinOutputID) (types.CoinOutput, error) {
+	co, ok := cs.coinOutputs[id]
+	if !ok {
+		return types.CoinOutput{}, errors.New("coin output not found")
+	}
+	return co, nil
+}
+
+func (cs *testConsensusSet) Height() types.BlockHeight { return 0 }
+
+func (cs *testConsensusSet) CurrentBlock() types.Block {
+	return types.Block{Timestamp: types.CurrentTimestamp()}
+}
+
+// testTransactionPool accepts all transactions
+type testTransactionPool struct {
+	modules.TransactionPool
+	transactions []types.Transaction
+}
+
+func (tpool *testTransactionPool) AcceptTransactionSet(txns []types.Transaction) error {
+	tpool.transactions = append(tpool.transactions, txns...)
+	return nil
+}
+
+type testMintConditionGetter struct {
+	condition types.UnlockConditionProxy
+}
+
+func (getter *testMintConditionGetter) GetActiveMintCondition() (types.UnlockConditionProxy, error) {
+	return getter.condition, nil
+}
+
+func (getter *testMintConditionGetter) GetMintConditionAt(types.BlockHeight) (types.UnlockConditionProxy, error) {
+	return getter.condition, nil
+}
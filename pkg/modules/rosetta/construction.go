@@ -0,0 +1,411 @@
+package rosetta
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// constructionTransaction is the (unsigned or signed) transaction exchanged by the Construction API,
+// containing the spent coins next to the transaction itself, such that it can be parsed offline.
+type constructionTransaction struct {
+	Transaction types.Transaction   `json:"transaction"`
+	Inputs      []constructionInput `json:"inputs"`
+}
+
+// constructionInput is a coin spent by a construction transaction
+type constructionInput struct {
+	ParentID types.CoinOutputID `json:"parentid"`
+	Address  types.UnlockHash   `json:"address"`
+	Value    types.Currency     `json:"value"`
+}
+
+// construction intent, parsed from the given operations
+type constructionIntent struct {
+	inputs  []constructionInput
+	outputs []types.CoinOutput
+}
+
+// ConstructionDerive returns the address of a single ed25519 public key.
+func (s *Server) ConstructionDerive(req ConstructionDeriveRequest) (*ConstructionDeriveResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	spk, rerr := parsePublicKey(req.PublicKey)
+	if rerr != nil {
+		return nil, rerr
+	}
+	return &ConstructionDeriveResponse{
+		AccountIdentifier: AccountIdentifier{Address: types.NewPubKeyUnlockHash(spk).String()},
+	}, nil
+}
+
+// ConstructionPreprocess validates the given operations,
+// returning the coins to look up and the public keys required to spend them.
+func (s *Server) ConstructionPreprocess(req ConstructionPreprocessRequest) (*ConstructionPreprocessResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	intent, rerr := s.parseOperations(req.Operations)
+	if rerr != nil {
+		return nil, rerr
+	}
+	coins := make([]string, 0, len(intent.inputs))
+	accounts := make([]AccountIdentifier, 0, len(intent.inputs))
+	for _, input := range intent.inputs {
+		coins = append(coins, input.ParentID.String())
+		accounts = append(accounts, AccountIdentifier{Address: input.Address.String()})
+	}
+	return &ConstructionPreprocessResponse{
+		Options: map[string]interface{}{
+			"coins": coins,
+		},
+		RequiredPublicKeys: accounts,
+	}, nil
+}
+
+// ConstructionMetadata ensures all coins to be spent are unspent,
+// and returns the minimum transaction fee as suggested fee.
+func (s *Server) ConstructionMetadata(req ConstructionMetadataRequest) (*ConstructionMetadataResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	coins, ok := req.Options["coins"].([]interface{})
+	if !ok {
+		return nil, ErrInvalidRequest.withReason("options require a list of coins")
+	}
+	for _, coin := range coins {
+		str, ok := coin.(string)
+		if !ok {
+			return nil, ErrInvalidRequest.withReason("invalid coin %v", coin)
+		}
+		var id types.CoinOutputID
+		if err := id.LoadString(str); err != nil {
+			return nil, ErrInvalidRequest.withReason("invalid coin %s: %v", str, err)
+		}
+		if _, err := s.cs.GetCoinOutput(id); err != nil {
+			return nil, ErrCoinNotSpendable.withReason("coin %s: %v", str, err)
+		}
+	}
+	fee := s.constants.MinimumTransactionFee
+	return &ConstructionMetadataResponse{
+		Metadata: map[string]interface{}{
+			"minimum_fee": fee.String(),
+		},
+		SuggestedFee: []Amount{*s.amount(fee, false)},
+	}, nil
+}
+
+// ConstructionPayloads creates an unsigned transaction from the given operations,
+// the difference between the inputs and outputs being paid as transaction fee.
+// A payload to sign is returned for each input.
+func (s *Server) ConstructionPayloads(req ConstructionPayloadsRequest) (*ConstructionPayloadsResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	intent, rerr := s.parseOperations(req.Operations)
+	if rerr != nil {
+		return nil, rerr
+	}
+	publicKeys := make(map[types.UnlockHash]types.SiaPublicKey, len(req.PublicKeys))
+	for _, pk := range req.PublicKeys {
+		spk, rerr := parsePublicKey(pk)
+		if rerr != nil {
+			return nil, rerr
+		}
+		publicKeys[types.NewPubKeyUnlockHash(spk)] = spk
+	}
+
+	ctxn := constructionTransaction{
+		Transaction: types.Transaction{
+			Version:     types.TransactionVersionOne,
+			CoinOutputs: intent.outputs,
+		},
+		Inputs: intent.inputs,
+	}
+	var inputSum, outputSum types.Currency
+	for _, input := range intent.inputs {
+		spk, ok := publicKeys[input.Address]
+		if !ok {
+			return nil, ErrInvalidPublicKey.withReason("no public key given for address %s", input.Address.String())
+		}
+		ctxn.Transaction.CoinInputs = append(ctxn.Transaction.CoinInputs, types.CoinInput{
+			ParentID:    input.ParentID,
+			Fulfillment: types.NewFulfillment(types.NewSingleSignatureFulfillment(spk)),
+		})
+		inputSum = inputSum.Add(input.Value)
+	}
+	for _, co := range intent.outputs {
+		outputSum = outputSum.Add(co.Value)
+	}
+	if inputSum.Cmp(outputSum) < 0 {
+		return nil, ErrInvalidOperations.withReason("outputs (%s) exceed inputs (%s)", outputSum.String(), inputSum.String())
+	}
+	fee := inputSum.Sub(outputSum)
+	if fee.Cmp(s.constants.MinimumTransactionFee) < 0 {
+		return nil, ErrInvalidOperations.withReason("fee %s is less than the minimum fee %s", fee.String(), s.constants.MinimumTransactionFee.String())
+	}
+	ctxn.Transaction.MinerFees = []types.Currency{fee}
+
+	payloads := make([]SigningPayload, 0, len(intent.inputs))
+	for i, input := range intent.inputs {
+		sigHash, err := ctxn.Transaction.InputSigHash(uint64(i))
+		if err != nil {
+			return nil, ErrInternal.withReason("failed to compute signature hash of input %d: %v", i, err)
+		}
+		payloads = append(payloads, SigningPayload{
+			AccountIdentifier: &AccountIdentifier{Address: input.Address.String()},
+			HexBytes:          hex.EncodeToString(sigHash[:]),
+			SignatureType:     SignatureTypeEd25519,
+		})
+	}
+	unsigned, err := json.Marshal(ctxn)
+	if err != nil {
+		return nil, ErrInternal.withReason("failed to encode transaction: %v", err)
+	}
+	return &ConstructionPayloadsResponse{
+		UnsignedTransaction: string(unsigned),
+		Payloads:            payloads,
+	}, nil
+}
+
+// ConstructionParse returns the operations of an unsigned or signed transaction,
+// and the signers of a signed transaction.
+func (s *Server) ConstructionParse(req ConstructionParseRequest) (*ConstructionParseResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	ctxn, rerr := parseConstructionTransaction(req.Transaction)
+	if rerr != nil {
+		return nil, rerr
+	}
+	resp := &ConstructionParseResponse{
+		Operations: make([]Operation, 0, len(ctxn.Inputs)+len(ctxn.Transaction.CoinOutputs)),
+	}
+	inputs := make([]OperationIdentifier, 0, len(ctxn.Inputs))
+	for _, input := range ctxn.Inputs {
+		op := Operation{
+			OperationIdentifier: OperationIdentifier{Index: int64(len(resp.Operations))},
+			Type:                OperationTypeInput,
+			Account:             &AccountIdentifier{Address: input.Address.String()},
+			Amount:              s.amount(input.Value, true),
+			CoinChange: &CoinChange{
+				CoinIdentifier: CoinIdentifier{Identifier: input.ParentID.String()},
+				CoinAction:     CoinActionSpent,
+			},
+		}
+		inputs = append(inputs, op.OperationIdentifier)
+		resp.Operations = append(resp.Operations, op)
+		if req.Signed {
+			resp.AccountIdentifierSigners = append(resp.AccountIdentifierSigners, *op.Account)
+		}
+	}
+	for _, co := range ctxn.Transaction.CoinOutputs {
+		resp.Operations = append(resp.Operations, Operation{
+			OperationIdentifier: OperationIdentifier{Index: int64(len(resp.Operations))},
+			RelatedOperations:   inputs,
+			Type:                OperationTypeOutput,
+			Account:             &AccountIdentifier{Address: co.Condition.UnlockHash().String()},
+			Amount:              s.amount(co.Value, false),
+		})
+	}
+	return resp, nil
+}
+
+// ConstructionCombine adds the given signatures to an unsigned transaction,
+// requiring a valid signature for each input.
+func (s *Server) ConstructionCombine(req ConstructionCombineRequest) (*ConstructionCombineResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	ctxn, rerr := parseConstructionTransaction(req.UnsignedTransaction)
+	if rerr != nil {
+		return nil, rerr
+	}
+	for _, signature := range req.Signatures {
+		if signature.SignatureType != SignatureTypeEd25519 {
+			return nil, ErrInvalidSignature.withReason("unsupported signature type %q", signature.SignatureType)
+		}
+		spk, rerr := parsePublicKey(signature.PublicKey)
+		if rerr != nil {
+			return nil, rerr
+		}
+		rawSig, err := hex.DecodeString(signature.HexBytes)
+		if err != nil || len(rawSig) != crypto.SignatureSize {
+			return nil, ErrInvalidSignature.withReason("signature has to be %d hex-encoded bytes", crypto.SignatureSize)
+		}
+		var (
+			pk  crypto.PublicKey
+			sig crypto.Signature
+		)
+		copy(pk[:], spk.Key)
+		copy(sig[:], rawSig)
+
+		signed := false
+		for i, ci := range ctxn.Transaction.CoinInputs {
+			ss, ok := ci.Fulfillment.Fulfillment.(*types.SingleSignatureFulfillment)
+			if !ok || len(ss.Signature) != 0 || types.NewPubKeyUnlockHash(ss.PublicKey) != types.NewPubKeyUnlockHash(spk) {
+				continue
+			}
+			sigHash, err := ctxn.Transaction.InputSigHash(uint64(i))
+			if err != nil {
+				return nil, ErrInternal.withReason("failed to compute signature hash of input %d: %v", i, err)
+			}
+			if hex.EncodeToString(sigHash[:]) != signature.SigningPayload.HexBytes {
+				continue
+			}
+			if err := crypto.VerifyHash(sigHash, pk, sig); err != nil {
+				return nil, ErrInvalidSignature.withReason("input %d: %v", i, err)
+			}
+			ss.Signature = rawSig
+			signed = true
+			break
+		}
+		if !signed {
+			return nil, ErrInvalidSignature.withReason("signature of payload %s doesn't match any unsigned input", signature.SigningPayload.HexBytes)
+		}
+	}
+	for i, ci := range ctxn.Transaction.CoinInputs {
+		if ss, ok := ci.Fulfillment.Fulfillment.(*types.SingleSignatureFulfillment); !ok || len(ss.Signature) == 0 {
+			return nil, ErrInvalidSignature.withReason("input %d is not signed", i)
+		}
+	}
+	signedTxn, err := json.Marshal(ctxn)
+	if err != nil {
+		return nil, ErrInternal.withReason("failed to encode transaction: %v", err)
+	}
+	return &ConstructionCombineResponse{SignedTransaction: string(signedTxn)}, nil
+}
+
+// ConstructionHash returns the ID of a signed transaction.
+func (s *Server) ConstructionHash(req ConstructionHashRequest) (*TransactionIdentifierResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	ctxn, rerr := parseConstructionTransaction(req.SignedTransaction)
+	if rerr != nil {
+		return nil, rerr
+	}
+	return &TransactionIdentifierResponse{
+		TransactionIdentifier: TransactionIdentifier{Hash: ctxn.Transaction.ID().String()},
+	}, nil
+}
+
+// ConstructionSubmit submits a signed transaction to the transaction pool,
+// submitting a transaction already in the transaction pool is not considered an error.
+func (s *Server) ConstructionSubmit(req ConstructionSubmitRequest) (*TransactionIdentifierResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	ctxn, rerr := parseConstructionTransaction(req.SignedTransaction)
+	if rerr != nil {
+		return nil, rerr
+	}
+	err := s.tpool.AcceptTransactionSet([]types.Transaction{ctxn.Transaction})
+	if err != nil && err != modules.ErrDuplicateTransactionSet {
+		return nil, ErrTransactionRejected.withReason("%v", err)
+	}
+	return &TransactionIdentifierResponse{
+		TransactionIdentifier: TransactionIdentifier{Hash: ctxn.Transaction.ID().String()},
+	}, nil
+}
+
+// parseOperations parses the INPUT and OUTPUT operations used to construct a transaction,
+// inputs can only spend coins of single public key addresses
+func (s *Server) parseOperations(ops []Operation) (constructionIntent, *Error) {
+	var intent constructionIntent
+	for _, op := range ops {
+		if op.Account == nil || op.Amount == nil {
+			return constructionIntent{}, ErrInvalidOperations.withReason("operation %d requires an account and amount", op.OperationIdentifier.Index)
+		}
+		var uh types.UnlockHash
+		if err := uh.LoadString(op.Account.Address); err != nil {
+			return constructionIntent{}, ErrInvalidAddress.withReason("operation %d: %v", op.OperationIdentifier.Index, err)
+		}
+		if op.Amount.Currency != s.currency {
+			return constructionIntent{}, ErrInvalidOperations.withReason("operation %d: unsupported currency %s", op.OperationIdentifier.Index, op.Amount.Currency.Symbol)
+		}
+		value, ok := new(big.Int).SetString(op.Amount.Value, 10)
+		if !ok {
+			return constructionIntent{}, ErrInvalidOperations.withReason("operation %d: invalid amount %q", op.OperationIdentifier.Index, op.Amount.Value)
+		}
+
+		switch op.Type {
+		case OperationTypeInput:
+			if uh.Type != types.UnlockTypePubKey {
+				return constructionIntent{}, ErrInvalidAddress.withReason("operation %d: only coins of single public key addresses can be spent", op.OperationIdentifier.Index)
+			}
+			if value.Sign() >= 0 {
+				return constructionIntent{}, ErrInvalidOperations.withReason("operation %d: input amount has to be negative", op.OperationIdentifier.Index)
+			}
+			if op.CoinChange == nil || op.CoinChange.CoinAction != CoinActionSpent {
+				return constructionIntent{}, ErrInvalidOperations.withReason("operation %d: input has to spend a coin", op.OperationIdentifier.Index)
+			}
+			var id types.CoinOutputID
+			if err := id.LoadString(op.CoinChange.CoinIdentifier.Identifier); err != nil {
+				return constructionIntent{}, ErrInvalidOperations.withReason("operation %d: invalid coin: %v", op.OperationIdentifier.Index, err)
+			}
+			intent.inputs = append(intent.inputs, constructionInput{
+				ParentID: id,
+				Address:  uh,
+				Value:    types.NewCurrency(value.Neg(value)),
+			})
+
+		case OperationTypeOutput:
+			if value.Sign() <= 0 {
+				return constructionIntent{}, ErrInvalidOperations.withReason("operation %d: output amount has to be positive", op.OperationIdentifier.Index)
+			}
+			if op.CoinChange != nil {
+				return constructionIntent{}, ErrInvalidOperations.withReason("operation %d: output cannot define a coin change", op.OperationIdentifier.Index)
+			}
+			intent.outputs = append(intent.outputs, types.CoinOutput{
+				Value:     types.NewCurrency(value),
+				Condition: types.NewCondition(types.NewUnlockHashCondition(uh)),
+			})
+
+		default:
+			return constructionIntent{}, ErrInvalidOperations.withReason("operation %d: unsupported operation type %q", op.OperationIdentifier.Index, op.Type)
+		}
+	}
+	if len(intent.inputs) == 0 || len(intent.outputs) == 0 {
+		return constructionIntent{}, ErrInvalidOperations.withReason("at least one input and one output are required")
+	}
+	return intent, nil
+}
+
+// parsePublicKey parses a hex-encoded ed25519 public key
+func parsePublicKey(pk PublicKey) (types.SiaPublicKey, *Error) {
+	if pk.CurveType != CurveTypeEdwards25519 {
+		return types.SiaPublicKey{}, ErrInvalidPublicKey.withReason("unsupported curve type %q", pk.CurveType)
+	}
+	b, err := hex.DecodeString(pk.HexBytes)
+	if err != nil || len(b) != crypto.PublicKeySize {
+		return types.SiaPublicKey{}, ErrInvalidPublicKey.withReason("public key has to be %d hex-encoded bytes", crypto.PublicKeySize)
+	}
+	var key crypto.PublicKey
+	copy(key[:], b)
+	return types.Ed25519PublicKey(key), nil
+}
+
+// parseConstructionTransaction decodes a transaction created by the Construction API,
+// ensuring the spent coins match the inputs of the transaction
+func parseConstructionTransaction(str string) (constructionTransaction, *Error) {
+	var ctxn constructionTransaction
+	if err := json.Unmarshal([]byte(str), &ctxn); err != nil {
+		return constructionTransaction{}, ErrInvalidTransaction.withReason("%v", err)
+	}
+	if len(ctxn.Inputs) != len(ctxn.Transaction.CoinInputs) {
+		return constructionTransaction{}, ErrInvalidTransaction.withReason("transaction has %d inputs, while %d coins are defined",
+			len(ctxn.Transaction.CoinInputs), len(ctxn.Inputs))
+	}
+	for i, ci := range ctxn.Transaction.CoinInputs {
+		if ci.ParentID != ctxn.Inputs[i].ParentID {
+			return constructionTransaction{}, ErrInvalidTransaction.withReason("input %d doesn't spend coin %s", i, ctxn.Inputs[i].ParentID.String())
+		}
+	}
+	return ctxn, nil
+}
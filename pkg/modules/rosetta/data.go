@@ -0,0 +1,416 @@
+package rosetta
+
+import (
+	tftypes "github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/types"
+)
+
+// NetworkList lists the network served, which is only the network of this node.
+func (s *Server) NetworkList(MetadataRequest) (*NetworkListResponse, *Error) {
+	return &NetworkListResponse{
+		NetworkIdentifiers: []NetworkIdentifier{s.network},
+	}, nil
+}
+
+// NetworkOptions returns the versions and supported features of this implementation.
+func (s *Server) NetworkOptions(req NetworkRequest) (*NetworkOptionsResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	return &NetworkOptionsResponse{
+		Version: Version{
+			RosettaVersion: RosettaVersion,
+			NodeVersion:    s.info.ChainVersion.String(),
+		},
+		Allow: Allow{
+			OperationStatuses: []OperationStatus{
+				{Status: OperationStatusSuccess, Successful: true},
+			},
+			OperationTypes: []string{
+				OperationTypeInput,
+				OperationTypeOutput,
+				OperationTypeMinerPayout,
+				OperationTypeMint,
+				OperationTypeMinterDefinition,
+			},
+			Errors: allErrors,
+		},
+	}, nil
+}
+
+// NetworkStatus returns the current block, genesis block, sync status and peers of this node.
+func (s *Server) NetworkStatus(req NetworkRequest) (*NetworkStatusResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	genesis, ok := s.cs.BlockAtHeight(0)
+	if !ok {
+		return nil, ErrInternal.withReason("genesis block not found")
+	}
+	height := s.cs.Height()
+	current := s.cs.CurrentBlock()
+	peers := []Peer{}
+	if s.gateway != nil {
+		for _, peer := range s.gateway.Peers() {
+			peers = append(peers, Peer{PeerID: string(peer.NetAddress)})
+		}
+	}
+	return &NetworkStatusResponse{
+		CurrentBlockIdentifier: blockIdentifier(current, height),
+		CurrentBlockTimestamp:  timestamp(current.Timestamp),
+		GenesisBlockIdentifier: blockIdentifier(genesis, 0),
+		SyncStatus: &SyncStatus{
+			CurrentIndex: int64(height),
+			Synced:       s.cs.Synced(),
+		},
+		Peers: peers,
+	}, nil
+}
+
+// Block returns the block identified by index or hash, or the current block if neither is given.
+func (s *Server) Block(req BlockRequest) (*BlockResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	block, height, rerr := s.lookupBlock(req.BlockIdentifier)
+	if rerr != nil {
+		return nil, rerr
+	}
+	parent := blockIdentifier(block, height)
+	if height > 0 {
+		parentBlock, ok := s.cs.BlockAtHeight(height - 1)
+		if !ok {
+			return nil, ErrInternal.withReason("parent of block %d not found", height)
+		}
+		parent = blockIdentifier(parentBlock, height-1)
+	}
+
+	transactions := make([]Transaction, 0, len(block.Transactions)+1)
+	if len(block.MinerPayouts) > 0 {
+		transactions = append(transactions, s.minerPayoutTransaction(block))
+	}
+	for _, txn := range block.Transactions {
+		transaction, rerr := s.transaction(txn, s.spentCoinOutput, true)
+		if rerr != nil {
+			return nil, rerr
+		}
+		transactions = append(transactions, transaction)
+	}
+	return &BlockResponse{
+		Block: &Block{
+			BlockIdentifier:       blockIdentifier(block, height),
+			ParentBlockIdentifier: parent,
+			Timestamp:             timestamp(block.Timestamp),
+			Transactions:          transactions,
+		},
+	}, nil
+}
+
+// BlockTransaction returns a single transaction of the given block,
+// the miner payouts being identified by the ID of the block.
+func (s *Server) BlockTransaction(req BlockTransactionRequest) (*BlockTransactionResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	hash := req.BlockIdentifier.Hash
+	index := req.BlockIdentifier.Index
+	block, _, rerr := s.lookupBlock(PartialBlockIdentifier{Hash: &hash, Index: &index})
+	if rerr != nil {
+		return nil, rerr
+	}
+	var txid types.TransactionID
+	if err := txid.LoadString(req.TransactionIdentifier.Hash); err != nil {
+		return nil, ErrInvalidRequest.withReason("invalid transaction hash: %v", err)
+	}
+	if types.TransactionID(block.ID()) == txid && len(block.MinerPayouts) > 0 {
+		return &BlockTransactionResponse{Transaction: s.minerPayoutTransaction(block)}, nil
+	}
+	for _, txn := range block.Transactions {
+		if txn.ID() != txid {
+			continue
+		}
+		transaction, rerr := s.transaction(txn, s.spentCoinOutput, true)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return &BlockTransactionResponse{Transaction: transaction}, nil
+	}
+	return nil, ErrTransactionNotFound.withReason("transaction %s is not part of block %s", txid.String(), hash)
+}
+
+// AccountBalance returns the confirmed coin balance of an address at the current block,
+// including the miner payouts which haven't matured yet.
+func (s *Server) AccountBalance(req AccountBalanceRequest) (*AccountBalanceResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	if req.BlockIdentifier != nil && (req.BlockIdentifier.Index != nil || req.BlockIdentifier.Hash != nil) {
+		return nil, &ErrHistoricalBalance
+	}
+	current, coins, rerr := s.unspentCoins(req.AccountIdentifier)
+	if rerr != nil {
+		return nil, rerr
+	}
+	var balance types.Currency
+	for _, coin := range coins {
+		balance = balance.Add(coin.Value)
+	}
+	return &AccountBalanceResponse{
+		BlockIdentifier: current,
+		Balances:        []Amount{*s.amount(balance, false)},
+	}, nil
+}
+
+// AccountCoins returns the confirmed unspent coins of an address at the current block,
+// including the miner payouts which haven't matured yet.
+func (s *Server) AccountCoins(req AccountCoinsRequest) (*AccountCoinsResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	if req.IncludeMempool {
+		return nil, ErrInvalidRequest.withReason("mempool coins are not supported")
+	}
+	current, coins, rerr := s.unspentCoins(req.AccountIdentifier)
+	if rerr != nil {
+		return nil, rerr
+	}
+	resp := &AccountCoinsResponse{
+		BlockIdentifier: current,
+		Coins:           make([]Coin, 0, len(coins)),
+	}
+	for _, coin := range coins {
+		resp.Coins = append(resp.Coins, Coin{
+			CoinIdentifier: CoinIdentifier{Identifier: coin.ID.String()},
+			Amount:         *s.amount(coin.Value, false),
+		})
+	}
+	return resp, nil
+}
+
+// Mempool lists the IDs of all transactions in the transaction pool.
+func (s *Server) Mempool(req NetworkRequest) (*MempoolResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	txns := s.tpool.TransactionList()
+	resp := &MempoolResponse{
+		TransactionIdentifiers: make([]TransactionIdentifier, 0, len(txns)),
+	}
+	for _, txn := range txns {
+		resp.TransactionIdentifiers = append(resp.TransactionIdentifiers, TransactionIdentifier{Hash: txn.ID().String()})
+	}
+	return resp, nil
+}
+
+// MempoolTransaction returns a single transaction of the transaction pool.
+func (s *Server) MempoolTransaction(req MempoolTransactionRequest) (*MempoolTransactionResponse, *Error) {
+	if err := s.checkNetwork(req.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+	var txid types.TransactionID
+	if err := txid.LoadString(req.TransactionIdentifier.Hash); err != nil {
+		return nil, ErrInvalidRequest.withReason("invalid transaction hash: %v", err)
+	}
+	txn, err := s.tpool.Transaction(txid)
+	if err != nil {
+		return nil, ErrTransactionNotFound.withReason("%v", err)
+	}
+	// parent outputs are either unspent in the consensus set, or created by another unconfirmed transaction
+	unconfirmed := make(map[types.CoinOutputID]types.CoinOutput)
+	for _, pooled := range s.tpool.TransactionList() {
+		for i, co := range pooled.CoinOutputs {
+			unconfirmed[pooled.CoinOutputID(uint64(i))] = co
+		}
+	}
+	parent := func(id types.CoinOutputID) (types.CoinOutput, bool) {
+		if co, err := s.cs.GetCoinOutput(id); err == nil {
+			return co, true
+		}
+		co, ok := unconfirmed[id]
+		return co, ok
+	}
+	transaction, rerr := s.transaction(txn, parent, false)
+	if rerr != nil {
+		return nil, rerr
+	}
+	return &MempoolTransactionResponse{Transaction: transaction}, nil
+}
+
+// lookupBlock returns the block, part of the current path, identified by the given partial identifier
+func (s *Server) lookupBlock(identifier PartialBlockIdentifier) (types.Block, types.BlockHeight, *Error) {
+	if identifier.Hash != nil {
+		var hash crypto.Hash
+		if err := hash.LoadString(*identifier.Hash); err != nil {
+			return types.Block{}, 0, ErrInvalidRequest.withReason("invalid block hash: %v", err)
+		}
+		block, height, ok := s.explorer.Block(types.BlockID(hash))
+		if !ok || !s.cs.InCurrentPath(types.BlockID(hash)) {
+			return types.Block{}, 0, ErrBlockNotFound.withReason("block %s not found", *identifier.Hash)
+		}
+		if identifier.Index != nil && types.BlockHeight(*identifier.Index) != height {
+			return types.Block{}, 0, ErrBlockNotFound.withReason("block %s is not at index %d", *identifier.Hash, *identifier.Index)
+		}
+		return block, height, nil
+	}
+	if identifier.Index != nil {
+		if *identifier.Index < 0 {
+			return types.Block{}, 0, ErrInvalidRequest.withReason("negative block index %d", *identifier.Index)
+		}
+		height := types.BlockHeight(*identifier.Index)
+		block, ok := s.cs.BlockAtHeight(height)
+		if !ok {
+			return types.Block{}, 0, ErrBlockNotFound.withReason("no block at index %d", height)
+		}
+		return block, height, nil
+	}
+	return s.cs.CurrentBlock(), s.cs.Height(), nil
+}
+
+// minerPayoutTransaction represents the miner payouts of a block as a transaction identified by the ID of the block
+func (s *Server) minerPayoutTransaction(block types.Block) Transaction {
+	transaction := Transaction{
+		TransactionIdentifier: TransactionIdentifier{Hash: block.ID().String()},
+		Operations:            make([]Operation, 0, len(block.MinerPayouts)),
+	}
+	for i, mp := range block.MinerPayouts {
+		transaction.Operations = append(transaction.Operations, Operation{
+			OperationIdentifier: OperationIdentifier{Index: int64(i)},
+			Type:                OperationTypeMinerPayout,
+			Status:              statusSuccess(),
+			Account:             &AccountIdentifier{Address: mp.UnlockHash.String()},
+			Amount:              s.amount(mp.Value, false),
+			CoinChange: &CoinChange{
+				CoinIdentifier: CoinIdentifier{Identifier: block.MinerPayoutID(uint64(i)).String()},
+				CoinAction:     CoinActionCreated,
+			},
+		})
+	}
+	return transaction
+}
+
+// transaction represents the coin inputs and outputs of a transaction as operations,
+// the outputs of a CoinCreationTransaction as MINT operations,
+// and the new mint condition of a MinterDefinitionTransaction as a MINTER_DEFINITION operation.
+// The status is only defined for transactions which are part of a block.
+func (s *Server) transaction(txn types.Transaction, parent func(types.CoinOutputID) (types.CoinOutput, bool), confirmed bool) (Transaction, *Error) {
+	var status *string
+	if confirmed {
+		status = statusSuccess()
+	}
+	transaction := Transaction{
+		TransactionIdentifier: TransactionIdentifier{Hash: txn.ID().String()},
+		Operations:            make([]Operation, 0, len(txn.CoinInputs)+len(txn.CoinOutputs)),
+		Metadata: map[string]interface{}{
+			"version": txn.Version,
+		},
+	}
+	if len(txn.ArbitraryData) > 0 {
+		transaction.Metadata["arbitrary_data"] = txn.ArbitraryData
+	}
+	nextIndex := func() OperationIdentifier {
+		return OperationIdentifier{Index: int64(len(transaction.Operations))}
+	}
+
+	inputs := make([]OperationIdentifier, 0, len(txn.CoinInputs))
+	for _, ci := range txn.CoinInputs {
+		co, ok := parent(ci.ParentID)
+		if !ok {
+			return Transaction{}, ErrInternal.withReason("parent output %s of transaction %s not found", ci.ParentID.String(), txn.ID().String())
+		}
+		op := Operation{
+			OperationIdentifier: nextIndex(),
+			Type:                OperationTypeInput,
+			Status:              status,
+			Account:             &AccountIdentifier{Address: co.Condition.UnlockHash().String()},
+			Amount:              s.amount(co.Value, true),
+			CoinChange: &CoinChange{
+				CoinIdentifier: CoinIdentifier{Identifier: ci.ParentID.String()},
+				CoinAction:     CoinActionSpent,
+			},
+		}
+		inputs = append(inputs, op.OperationIdentifier)
+		transaction.Operations = append(transaction.Operations, op)
+	}
+
+	outputType := OperationTypeOutput
+	if txn.Version == tftypes.TransactionVersionCoinCreation {
+		outputType = OperationTypeMint
+	}
+	for i, co := range txn.CoinOutputs {
+		transaction.Operations = append(transaction.Operations, Operation{
+			OperationIdentifier: nextIndex(),
+			RelatedOperations:   inputs,
+			Type:                outputType,
+			Status:              status,
+			Account:             &AccountIdentifier{Address: co.Condition.UnlockHash().String()},
+			Amount:              s.amount(co.Value, false),
+			CoinChange: &CoinChange{
+				CoinIdentifier: CoinIdentifier{Identifier: txn.CoinOutputID(uint64(i)).String()},
+				CoinAction:     CoinActionCreated,
+			},
+		})
+	}
+
+	if txn.Version == tftypes.TransactionVersionMinterDefinition {
+		mdtx, err := tftypes.MinterDefinitionTransactionFromTransaction(txn)
+		if err != nil {
+			return Transaction{}, ErrInternal.withReason("invalid minter definition transaction %s: %v", txn.ID().String(), err)
+		}
+		transaction.Operations = append(transaction.Operations, Operation{
+			OperationIdentifier: nextIndex(),
+			Type:                OperationTypeMinterDefinition,
+			Status:              status,
+			Account:             &AccountIdentifier{Address: mdtx.MintCondition.UnlockHash().String()},
+			Metadata: map[string]interface{}{
+				"mint_condition": mdtx.MintCondition,
+			},
+		})
+	}
+	return transaction, nil
+}
+
+// spentCoinOutput looks up a (possibly spent) coin output using the explorer
+func (s *Server) spentCoinOutput(id types.CoinOutputID) (types.CoinOutput, bool) {
+	return s.explorer.CoinOutput(id)
+}
+
+// unspentCoin is a coin output which is unspent as of the last indexed block
+type unspentCoin struct {
+	ID    types.CoinOutputID
+	Value types.Currency
+}
+
+// unspentCoins returns all unspent coin outputs of the given account,
+// using the unspent coin index, as well as the last block applied to it
+func (s *Server) unspentCoins(account AccountIdentifier) (BlockIdentifier, []unspentCoin, *Error) {
+	var uh types.UnlockHash
+	if err := uh.LoadString(account.Address); err != nil {
+		return BlockIdentifier{}, nil, ErrInvalidAddress.withReason("%v", err)
+	}
+	current, coins, err := s.indexedUnspentCoins(uh)
+	if err != nil {
+		return BlockIdentifier{}, nil, ErrInternal.withReason("%v", err)
+	}
+	return current, coins, nil
+}
+
+// blockIdentifier returns the identifier of the given block
+func blockIdentifier(block types.Block, height types.BlockHeight) BlockIdentifier {
+	return BlockIdentifier{
+		Index: int64(height),
+		Hash:  block.ID().String(),
+	}
+}
+
+// timestamp converts a block timestamp into milliseconds since the Unix epoch
+func timestamp(ts types.Timestamp) int64 {
+	return int64(ts) * 1000
+}
+
+// statusSuccess returns a new reference to the success status
+func statusSuccess() *string {
+	status := OperationStatusSuccess
+	return &status
+}
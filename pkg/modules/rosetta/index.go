@@ -0,0 +1,256 @@
+package rosetta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	rivinepersist "github.com/rivine/rivine/persist"
+	"github.com/rivine/rivine/types"
+
+	bolt "github.com/rivine/bbolt"
+)
+
+// Rosetta I/O constants
+const (
+	RosettaDir      = "rosetta"
+	RosettaFilename = RosettaDir + ".db"
+)
+
+// internal bucket database keys used for the unspent coin index
+var (
+	bucketInternal         = []byte("internal")
+	bucketInternalKeyStats = []byte("stats")
+	// bucketUnspentCoins stores the value of all unspent coin outputs,
+	// indexed by the address of the output followed by its ID
+	bucketUnspentCoins = []byte("unspentcoins")
+)
+
+type (
+	// implements modules.ConsensusSetSubscriber
+	rosettaCSSubscriber struct {
+		s *Server
+	}
+	indexStats struct {
+		ConsensusChangeID modules.ConsensusChangeID
+		// BlockCount defines the amount of applied blocks,
+		// the genesis block included, such that the
+		// height of the last applied block equals BlockCount-1
+		BlockCount uint64
+		// BlockID is the ID of the last applied block
+		BlockID types.BlockID
+	}
+)
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber,
+// calling s.processConsensusChange, so that the Server
+// does not expose its interface implementation outside this package.
+func (sub *rosettaCSSubscriber) ProcessConsensusChange(cc modules.ConsensusChange) {
+	sub.s.processConsensusChange(cc)
+}
+
+// processConsensusChange updates the unspent coin index using the blocks reverted and applied by the given change.
+// Miner payouts are indexed as soon as their block is applied, as the balance includes the payouts which haven't matured yet.
+func (s *Server) processConsensusChange(cc modules.ConsensusChange) {
+	if err := s.tg.Add(); err != nil {
+		// The Server should gracefully reject updates from the consensus set
+		// that are sent after the Server's Close method has closed its ThreadGroup.
+		return
+	}
+	defer s.tg.Done()
+
+	coinOutputs := consensusChangeCoinOutputs(cc)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var stats indexStats
+		err := encoding.Unmarshal(tx.Bucket(bucketInternal).Get(bucketInternalKeyStats), &stats)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal structured stats value: %v", err)
+		}
+		unspent := tx.Bucket(bucketUnspentCoins)
+		for _, block := range cc.RevertedBlocks {
+			err = revertBlockCoins(unspent, block, coinOutputs)
+			if err != nil {
+				return err
+			}
+			stats.BlockCount--
+			stats.BlockID = block.ParentID
+		}
+		for _, block := range cc.AppliedBlocks {
+			err = applyBlockCoins(unspent, block, coinOutputs)
+			if err != nil {
+				return err
+			}
+			stats.BlockCount++
+			stats.BlockID = block.ID()
+		}
+		stats.ConsensusChangeID = cc.ID
+		return tx.Bucket(bucketInternal).Put(bucketInternalKeyStats, encoding.Marshal(stats))
+	})
+	if err != nil {
+		build.Critical("rosetta failed to process consensus change:", err)
+	}
+}
+
+// applyBlockCoins indexes the miner payouts and coin outputs created by the given block,
+// removing the coin outputs it spends
+func applyBlockCoins(unspent *bolt.Bucket, block types.Block, coinOutputs map[types.CoinOutputID]types.CoinOutput) error {
+	for i, mp := range block.MinerPayouts {
+		err := unspent.Put(unspentCoinKey(mp.UnlockHash, block.MinerPayoutID(uint64(i))), encoding.Marshal(mp.Value))
+		if err != nil {
+			return err
+		}
+	}
+	for _, txn := range block.Transactions {
+		for _, ci := range txn.CoinInputs {
+			co, ok := coinOutputs[ci.ParentID]
+			if !ok {
+				return fmt.Errorf("spent coin output %s not found", ci.ParentID.String())
+			}
+			err := unspent.Delete(unspentCoinKey(co.Condition.UnlockHash(), ci.ParentID))
+			if err != nil {
+				return err
+			}
+		}
+		for i, co := range txn.CoinOutputs {
+			err := unspent.Put(unspentCoinKey(co.Condition.UnlockHash(), txn.CoinOutputID(uint64(i))), encoding.Marshal(co.Value))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// revertBlockCoins undoes applyBlockCoins for the given block,
+// reverting its transactions in reverse order
+func revertBlockCoins(unspent *bolt.Bucket, block types.Block, coinOutputs map[types.CoinOutputID]types.CoinOutput) error {
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		txn := block.Transactions[i]
+		for j, co := range txn.CoinOutputs {
+			err := unspent.Delete(unspentCoinKey(co.Condition.UnlockHash(), txn.CoinOutputID(uint64(j))))
+			if err != nil {
+				return err
+			}
+		}
+		for _, ci := range txn.CoinInputs {
+			co, ok := coinOutputs[ci.ParentID]
+			if !ok {
+				return fmt.Errorf("restored coin output %s not found", ci.ParentID.String())
+			}
+			err := unspent.Put(unspentCoinKey(co.Condition.UnlockHash(), ci.ParentID), encoding.Marshal(co.Value))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for i, mp := range block.MinerPayouts {
+		err := unspent.Delete(unspentCoinKey(mp.UnlockHash, block.MinerPayoutID(uint64(i))))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexedUnspentCoins returns all indexed unspent coin outputs of the given address,
+// as well as the last block applied to the index
+func (s *Server) indexedUnspentCoins(uh types.UnlockHash) (BlockIdentifier, []unspentCoin, error) {
+	var (
+		stats indexStats
+		coins []unspentCoin
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		err := encoding.Unmarshal(tx.Bucket(bucketInternal).Get(bucketInternalKeyStats), &stats)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal structured stats value: %v", err)
+		}
+		prefix := unspentCoinPrefix(uh)
+		c := tx.Bucket(bucketUnspentCoins).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var coin unspentCoin
+			copy(coin.ID[:], k[len(prefix):])
+			err = encoding.Unmarshal(v, &coin.Value)
+			if err != nil {
+				return fmt.Errorf("corrupt rosetta DB: failed to decode unspent coin %s: %v", coin.ID.String(), err)
+			}
+			coins = append(coins, coin)
+		}
+		return nil
+	})
+	if err != nil {
+		return BlockIdentifier{}, nil, err
+	}
+	if stats.BlockCount == 0 {
+		return BlockIdentifier{}, nil, errors.New("no blocks indexed yet")
+	}
+	return BlockIdentifier{
+		Index: int64(stats.BlockCount - 1),
+		Hash:  stats.BlockID.String(),
+	}, coins, nil
+}
+
+// openDB loads the set database and populates it with the necessary buckets
+func (s *Server) openDB(filename string) (stats indexStats, err error) {
+	var (
+		dbMetadata = rivinepersist.Metadata{
+			Header:  "TFChain Rosetta Database",
+			Version: "1.0.0",
+		}
+	)
+
+	s.db, err = rivinepersist.OpenDatabase(dbMetadata, filename)
+	if err != nil {
+		return stats, fmt.Errorf("error opening tfchain rosetta database: %v", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) (err error) {
+		internalBucket := tx.Bucket(bucketInternal)
+		if internalBucket != nil {
+			// db is already created, get the stored stats
+			b := internalBucket.Get(bucketInternalKeyStats)
+			if len(b) == 0 {
+				return errors.New("structured stats value could not be found in existing rosetta db")
+			}
+			err = encoding.Unmarshal(b, &stats)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal structured stats value from existing rosetta db: %v", err)
+			}
+			return nil
+		}
+
+		// create the DB
+		buckets := [][]byte{
+			bucketInternal,
+			bucketUnspentCoins,
+		}
+		for _, bucket := range buckets {
+			_, err = tx.CreateBucket(bucket)
+			if err != nil {
+				return fmt.Errorf("failed to create rosetta DB: %v", err)
+			}
+		}
+		stats.ConsensusChangeID = modules.ConsensusChangeBeginning
+		return tx.Bucket(bucketInternal).Put(bucketInternalKeyStats, encoding.Marshal(stats))
+	})
+	return stats, err
+}
+
+// consensusChangeCoinOutputs collects all coin outputs created or spent by the given consensus change,
+// as to know the addresses of the parent outputs of the inputs of its transactions
+func consensusChangeCoinOutputs(cc modules.ConsensusChange) map[types.CoinOutputID]types.CoinOutput {
+	coinOutputs := make(map[types.CoinOutputID]types.CoinOutput, len(cc.CoinOutputDiffs))
+	for _, diff := range cc.CoinOutputDiffs {
+		coinOutputs[diff.ID] = diff.CoinOutput
+	}
+	return coinOutputs
+}
+
+func unspentCoinPrefix(uh types.UnlockHash) []byte {
+	return append([]byte{byte(uh.Type)}, uh.Hash[:]...)
+}
+
+func unspentCoinKey(uh types.UnlockHash, id types.CoinOutputID) []byte {
+	return append(unspentCoinPrefix(uh), id[:]...)
+}
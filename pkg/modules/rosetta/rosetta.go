@@ -0,0 +1,360 @@
+// Package rosetta implements the Rosetta (Mesh) Data and Construction API
+// on top of the consensus set, explorer and transaction pool,
+// such that exchanges can integrate tfchain using their generic Rosetta tooling.
+//
+// Only coins (TFT) are represented, using the UTXO (coin) model.
+// Block stakes are not represented, while the miner payouts of a block
+// are represented as a transaction identified by the ID of the block.
+// The Construction API only supports spending coins locked by a single (ed25519) public key.
+package rosetta
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/modules"
+	rivinepersist "github.com/rivine/rivine/persist"
+	rivinesync "github.com/rivine/rivine/sync"
+	"github.com/rivine/rivine/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// RosettaVersion is the version of the Rosetta specification implemented.
+const RosettaVersion = "1.4.10"
+
+// All operation types.
+const (
+	// OperationTypeInput spends a coin.
+	OperationTypeInput = "INPUT"
+	// OperationTypeOutput creates a coin.
+	OperationTypeOutput = "OUTPUT"
+	// OperationTypeMinerPayout creates a coin paid to the creator of a block,
+	// either as block reward or as the collected transaction fees.
+	OperationTypeMinerPayout = "MINER_PAYOUT"
+	// OperationTypeMint creates a coin using a CoinCreationTransaction.
+	OperationTypeMint = "MINT"
+	// OperationTypeMinterDefinition redefines the mint condition using a MinterDefinitionTransaction,
+	// the account being the address of the new mint condition.
+	OperationTypeMinterDefinition = "MINTER_DEFINITION"
+)
+
+// OperationStatusSuccess is the status of all operations included in a block.
+const OperationStatusSuccess = "SUCCESS"
+
+// All errors returned by the API.
+var (
+	ErrInvalidNetwork      = Error{Code: 1, Message: "invalid network identifier"}
+	ErrInvalidRequest      = Error{Code: 2, Message: "invalid request"}
+	ErrBlockNotFound       = Error{Code: 3, Message: "block not found", Retriable: true}
+	ErrTransactionNotFound = Error{Code: 4, Message: "transaction not found", Retriable: true}
+	ErrInvalidAddress      = Error{Code: 5, Message: "invalid address"}
+	ErrInvalidOperations   = Error{Code: 6, Message: "invalid operations"}
+	ErrInvalidPublicKey    = Error{Code: 7, Message: "invalid public key"}
+	ErrInvalidTransaction  = Error{Code: 8, Message: "invalid transaction"}
+	ErrInvalidSignature    = Error{Code: 9, Message: "invalid signature"}
+	ErrCoinNotSpendable    = Error{Code: 10, Message: "coin is not spendable"}
+	ErrTransactionRejected = Error{Code: 11, Message: "transaction rejected by the transaction pool"}
+	ErrHistoricalBalance   = Error{Code: 12, Message: "historical balance lookup is not supported"}
+	ErrInternal            = Error{Code: 13, Message: "internal error", Retriable: true}
+
+	allErrors = []Error{
+		ErrInvalidNetwork,
+		ErrInvalidRequest,
+		ErrBlockNotFound,
+		ErrTransactionNotFound,
+		ErrInvalidAddress,
+		ErrInvalidOperations,
+		ErrInvalidPublicKey,
+		ErrInvalidTransaction,
+		ErrInvalidSignature,
+		ErrCoinNotSpendable,
+		ErrTransactionRejected,
+		ErrHistoricalBalance,
+		ErrInternal,
+	}
+)
+
+// Error implements the error interface.
+func (e Error) Error() string {
+	if reason, ok := e.Details["error"]; ok {
+		return fmt.Sprintf("%s: %v", e.Message, reason)
+	}
+	return e.Message
+}
+
+// withReason returns a copy of the error, with the given reason as detail
+func (e Error) withReason(format string, args ...interface{}) *Error {
+	e.Details = map[string]interface{}{"error": fmt.Sprintf(format, args...)}
+	return &e
+}
+
+// Server serves the Rosetta Data and Construction API,
+// indexing the unspent coins of all addresses in its own persistent database.
+type Server struct {
+	// The Server's ThreadGroup tells tracked functions to shut down and
+	// blocks until they have all exited before returning from Close.
+	tg rivinesync.ThreadGroup
+
+	db           *rivinepersist.BoltDatabase
+	csSubscriber *rosettaCSSubscriber
+
+	cs       modules.ConsensusSet
+	explorer modules.Explorer
+	tpool    modules.TransactionPool
+	gateway  modules.Gateway
+
+	info      types.BlockchainInfo
+	constants types.ChainConstants
+	network   NetworkIdentifier
+	currency  Currency
+
+	router *httprouter.Router
+}
+
+// New creates a new Rosetta API server, serving the chain described by the given info and constants,
+// subscribing to the given consensus set, resuming from the last consensus change it indexed.
+// The given root directory is used to store its (single) persistent BoltDB file.
+// The gateway is optional, and only used to list the connected peers.
+func New(rootDir string, cs modules.ConsensusSet, explorer modules.Explorer, tpool modules.TransactionPool, gateway modules.Gateway,
+	info types.BlockchainInfo, constants types.ChainConstants) (*Server, error) {
+	if cs == nil {
+		return nil, errors.New("rosetta server requires a consensus set")
+	}
+	if explorer == nil {
+		return nil, errors.New("rosetta server requires an explorer")
+	}
+	if tpool == nil {
+		return nil, errors.New("rosetta server requires a transaction pool")
+	}
+	s := &Server{
+		cs:        cs,
+		explorer:  explorer,
+		tpool:     tpool,
+		gateway:   gateway,
+		info:      info,
+		constants: constants,
+		network: NetworkIdentifier{
+			Blockchain: info.Name,
+			Network:    info.NetworkName,
+		},
+		currency: Currency{
+			Symbol: info.CoinUnit,
+			// the amount of decimals is the amount of zeros of a single coin
+			Decimals: int32(len(strings.TrimLeft(constants.CurrencyUnits.OneCoin.String(), "1"))),
+		},
+		router: httprouter.New(),
+	}
+
+	// Data API
+	s.post("/network/list", func(dec *json.Decoder) (interface{}, *Error) {
+		var req MetadataRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.NetworkList(req)
+	})
+	s.post("/network/options", func(dec *json.Decoder) (interface{}, *Error) {
+		var req NetworkRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.NetworkOptions(req)
+	})
+	s.post("/network/status", func(dec *json.Decoder) (interface{}, *Error) {
+		var req NetworkRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.NetworkStatus(req)
+	})
+	s.post("/block", func(dec *json.Decoder) (interface{}, *Error) {
+		var req BlockRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.Block(req)
+	})
+	s.post("/block/transaction", func(dec *json.Decoder) (interface{}, *Error) {
+		var req BlockTransactionRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.BlockTransaction(req)
+	})
+	s.post("/account/balance", func(dec *json.Decoder) (interface{}, *Error) {
+		var req AccountBalanceRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.AccountBalance(req)
+	})
+	s.post("/account/coins", func(dec *json.Decoder) (interface{}, *Error) {
+		var req AccountCoinsRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.AccountCoins(req)
+	})
+	s.post("/mempool", func(dec *json.Decoder) (interface{}, *Error) {
+		var req NetworkRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.Mempool(req)
+	})
+	s.post("/mempool/transaction", func(dec *json.Decoder) (interface{}, *Error) {
+		var req MempoolTransactionRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.MempoolTransaction(req)
+	})
+
+	// Construction API
+	s.post("/construction/derive", func(dec *json.Decoder) (interface{}, *Error) {
+		var req ConstructionDeriveRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.ConstructionDerive(req)
+	})
+	s.post("/construction/preprocess", func(dec *json.Decoder) (interface{}, *Error) {
+		var req ConstructionPreprocessRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.ConstructionPreprocess(req)
+	})
+	s.post("/construction/metadata", func(dec *json.Decoder) (interface{}, *Error) {
+		var req ConstructionMetadataRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.ConstructionMetadata(req)
+	})
+	s.post("/construction/payloads", func(dec *json.Decoder) (interface{}, *Error) {
+		var req ConstructionPayloadsRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.ConstructionPayloads(req)
+	})
+	s.post("/construction/parse", func(dec *json.Decoder) (interface{}, *Error) {
+		var req ConstructionParseRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.ConstructionParse(req)
+	})
+	s.post("/construction/combine", func(dec *json.Decoder) (interface{}, *Error) {
+		var req ConstructionCombineRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.ConstructionCombine(req)
+	})
+	s.post("/construction/hash", func(dec *json.Decoder) (interface{}, *Error) {
+		var req ConstructionHashRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.ConstructionHash(req)
+	})
+	s.post("/construction/submit", func(dec *json.Decoder) (interface{}, *Error) {
+		var req ConstructionSubmitRequest
+		if err := dec.Decode(&req); err != nil {
+			return nil, ErrInvalidRequest.withReason("%v", err)
+		}
+		return s.ConstructionSubmit(req)
+	})
+
+	persistDir := path.Join(rootDir, RosettaDir)
+	// Create the directory if it doesn't exist.
+	err := os.MkdirAll(persistDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.openDB(path.Join(persistDir, RosettaFilename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the rosetta DB: %v", err)
+	}
+	s.csSubscriber = &rosettaCSSubscriber{s: s}
+	err = cs.ConsensusSetSubscribe(s.csSubscriber, stats.ConsensusChangeID, s.tg.StopChan())
+	if err != nil {
+		s.csSubscriber = nil
+		s.Close()
+		return nil, fmt.Errorf("failed to subscribe to consensus set: %v", err)
+	}
+	return s, nil
+}
+
+// Close the server,
+// meaning it will be unsubscribed from the consensus set,
+// as well the threadgroup will be stopped and the internal bolt db will be closed.
+func (s *Server) Close() error {
+	if s.db == nil {
+		return errors.New("rosetta server is already closed or was never created")
+	}
+
+	if s.csSubscriber != nil {
+		s.cs.Unsubscribe(s.csSubscriber)
+		s.csSubscriber = nil
+	}
+	// stop thread group
+	tgErr := s.tg.Stop()
+	if tgErr != nil {
+		tgErr = fmt.Errorf("failed to stop the threadgroup of the rosetta server: %v", tgErr)
+	}
+	// close database
+	dbErr := s.db.Close()
+	if dbErr != nil {
+		dbErr = fmt.Errorf("failed to close the internal bolt db of the rosetta server: %v", dbErr)
+	}
+	s.db = nil
+
+	return build.ComposeErrors(tgErr, dbErr)
+}
+
+// ServeHTTP implements http.Handler, serving all Rosetta endpoints.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.router.ServeHTTP(w, req)
+}
+
+// post registers a handler for the given path, decoding the request body using the given function,
+// and writing the returned response or error as JSON, errors using status 500 as defined by the specification
+func (s *Server) post(path string, handle func(*json.Decoder) (interface{}, *Error)) {
+	s.router.POST(path, func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		resp, rerr := handle(json.NewDecoder(req.Body))
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if rerr != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(rerr)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// checkNetwork returns an error if the given network identifier isn't the one served
+func (s *Server) checkNetwork(network NetworkIdentifier) *Error {
+	if network != s.network {
+		return ErrInvalidNetwork.withReason("only %s %s is served", s.network.Blockchain, s.network.Network)
+	}
+	return nil
+}
+
+// amount converts the given value into a (negative) amount
+func (s *Server) amount(value types.Currency, negative bool) *Amount {
+	str := value.String()
+	if negative && !value.IsZero() {
+		str = "-" + str
+	}
+	return &Amount{Value: str, Currency: s.currency}
+}
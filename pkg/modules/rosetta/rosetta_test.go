@@ -0,0 +1,422 @@
+package rosetta
+
+import (
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/threefoldfoundation/tfchain/pkg/config"
+	tftypes "github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// testConsensusSet is a consensus set which only knows the unspent coin outputs defined by the test,
+// replaying the consensus changes applied by the test to new subscribers
+type testConsensusSet struct {
+	modules.ConsensusSet
+	coinOutputs map[types.CoinOutputID]types.CoinOutput
+	changes     []modules.ConsensusChange
+	subscribers []modules.ConsensusSetSubscriber
+}
+
+func (cs *testConsensusSet) apply(cc modules.ConsensusChange) {
+	cc.ID[0] = byte(len(cs.changes) + 1)
+	cs.changes = append(cs.changes, cc)
+	for _, subscriber := range cs.subscribers {
+		subscriber.ProcessConsensusChange(cc)
+	}
+}
+
+func (cs *testConsensusSet) ConsensusSetSubscribe(subscriber modules.ConsensusSetSubscriber, start modules.ConsensusChangeID, cancel <-chan struct{}) error {
+	var next int
+	if start != modules.ConsensusChangeBeginning {
+		next = -1
+		for idx, cc := range cs.changes {
+			if cc.ID == start {
+				next = idx + 1
+			}
+		}
+		if next < 0 {
+			return modules.ErrInvalidConsensusChangeID
+		}
+	}
+	for _, cc := range cs.changes[next:] {
+		subscriber.ProcessConsensusChange(cc)
+	}
+	cs.subscribers = append(cs.subscribers, subscriber)
+	return nil
+}
+
+func (cs *testConsensusSet) Unsubscribe(subscriber modules.ConsensusSetSubscriber) {
+	for idx := range cs.subscribers {
+		if cs.subscribers[idx] == subscriber {
+			cs.subscribers = append(cs.subscribers[:idx], cs.subscribers[idx+1:]...)
+			return
+		}
+	}
+}
+
+func (cs *testConsensusSet) GetCoinOutput(id types.CoinOutputID) (types.CoinOutput, error) {
+	co, ok := cs.coinOutputs[id]
+	if !ok {
+		return types.CoinOutput{}, errors.New("coin output not found")
+	}
+	return co, nil
+}
+
+// testTransactionPool is a transaction pool which only records the accepted transactions
+type testTransactionPool struct {
+	modules.TransactionPool
+	accepted []types.Transaction
+}
+
+func (tpool *testTransactionPool) AcceptTransactionSet(txns []types.Transaction) error {
+	tpool.accepted = append(tpool.accepted, txns...)
+	return nil
+}
+
+// testExplorer is an explorer which isn't used by the tested endpoints
+type testExplorer struct {
+	modules.Explorer
+}
+
+// newTestServer opens a server in a temporary directory,
+// returning a func which closes the server and removes its directory
+func newTestServer(t *testing.T) (*Server, *testConsensusSet, *testTransactionPool, func()) {
+	cs := &testConsensusSet{coinOutputs: make(map[types.CoinOutputID]types.CoinOutput)}
+	tpool := new(testTransactionPool)
+	dir, cleanup := newTestDir(t)
+	s := openTestServer(t, dir, cs, tpool)
+	return s, cs, tpool, func() {
+		s.Close()
+		cleanup()
+	}
+}
+
+// newTestDir creates a temporary directory, returning a func which removes it
+func newTestDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "rosetta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func openTestServer(t *testing.T, dir string, cs *testConsensusSet, tpool *testTransactionPool) *Server {
+	types.RegisterTransactionVersion(types.TransactionVersionOne, types.DefaultTransactionController{})
+	info := config.GetBlockchainInfo()
+	info.NetworkName = config.NetworkNameDev
+	s, err := New(dir, cs, testExplorer{}, tpool, nil, info, config.GetDevnetGenesis())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAccountCoins(t *testing.T) {
+	dir, cleanup := newTestDir(t)
+	defer cleanup()
+	cs, tpool := new(testConsensusSet), new(testTransactionPool)
+	s := openTestServer(t, dir, cs, tpool)
+	// closes the reopened server as well
+	defer func() { s.Close() }()
+	a := types.UnlockHash{Type: types.UnlockTypePubKey, Hash: crypto.HashObject("a")}
+	b := types.UnlockHash{Type: types.UnlockTypePubKey, Hash: crypto.HashObject("b")}
+	output := func(uh types.UnlockHash, value uint64) types.CoinOutput {
+		return types.CoinOutput{Value: types.NewCurrency64(value), Condition: types.NewCondition(types.NewUnlockHashCondition(uh))}
+	}
+
+	// the genesis block pays a (miner payout) 10 and (output) 20, block 1 spends the output of 20 to b
+	genesis := types.Block{
+		MinerPayouts: []types.MinerPayout{{Value: types.NewCurrency64(10), UnlockHash: a}},
+		Transactions: []types.Transaction{{Version: types.TransactionVersionOne, CoinOutputs: []types.CoinOutput{output(a, 20)}}},
+	}
+	spentID := genesis.Transactions[0].CoinOutputID(0)
+	block := types.Block{
+		ParentID:  genesis.ID(),
+		Timestamp: 1,
+		Transactions: []types.Transaction{{
+			Version:     types.TransactionVersionOne,
+			CoinInputs:  []types.CoinInput{{ParentID: spentID}},
+			CoinOutputs: []types.CoinOutput{output(b, 15), output(a, 4)},
+			MinerFees:   []types.Currency{types.NewCurrency64(1)},
+		}},
+	}
+	cs.apply(modules.ConsensusChange{
+		AppliedBlocks:   []types.Block{genesis},
+		CoinOutputDiffs: []modules.CoinOutputDiff{{Direction: modules.DiffApply, ID: spentID, CoinOutput: output(a, 20)}},
+	})
+	checkAccountCoins(t, s, a, 0, genesis.ID(), 10, 20)
+	cs.apply(modules.ConsensusChange{
+		AppliedBlocks:   []types.Block{block},
+		CoinOutputDiffs: []modules.CoinOutputDiff{{Direction: modules.DiffRevert, ID: spentID, CoinOutput: output(a, 20)}},
+	})
+	checkAccountCoins(t, s, a, 1, block.ID(), 10, 4)
+	checkAccountCoins(t, s, b, 1, block.ID(), 15)
+
+	// the index is persistent, resuming from the last consensus change it indexed
+	s.Close()
+	s = openTestServer(t, dir, cs, tpool)
+	checkAccountCoins(t, s, a, 1, block.ID(), 10, 4)
+
+	// reverting block 1 restores the spent output
+	cs.apply(modules.ConsensusChange{
+		RevertedBlocks:  []types.Block{block},
+		CoinOutputDiffs: []modules.CoinOutputDiff{{Direction: modules.DiffApply, ID: spentID, CoinOutput: output(a, 20)}},
+	})
+	checkAccountCoins(t, s, a, 0, genesis.ID(), 10, 20)
+	checkAccountCoins(t, s, b, 0, genesis.ID())
+}
+
+// checkAccountCoins checks the values of the coins of the given address, in order of value,
+// as well as the block at which they are unspent
+func checkAccountCoins(t *testing.T, s *Server, uh types.UnlockHash, index int64, blockID types.BlockID, values ...uint64) {
+	t.Helper()
+	resp, rerr := s.AccountCoins(AccountCoinsRequest{NetworkIdentifier: s.network, AccountIdentifier: AccountIdentifier{Address: uh.String()}})
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	if resp.BlockIdentifier.Index != index || resp.BlockIdentifier.Hash != blockID.String() {
+		t.Errorf("expected coins at block %d (%s), not %d (%s)", index, blockID.String(), resp.BlockIdentifier.Index, resp.BlockIdentifier.Hash)
+	}
+	if len(resp.Coins) != len(values) {
+		t.Fatalf("expected %d coins, not %d: %v", len(values), len(resp.Coins), resp.Coins)
+	}
+	found := make(map[string]bool)
+	for _, coin := range resp.Coins {
+		found[coin.Amount.Value] = true
+	}
+	for _, value := range values {
+		if !found[types.NewCurrency64(value).String()] {
+			t.Errorf("expected a coin of %d: %v", value, resp.Coins)
+		}
+	}
+}
+
+func TestConstructionRoundTrip(t *testing.T) {
+	s, cs, tpool, cleanup := newTestServer(t)
+	defer cleanup()
+	network := s.network
+
+	sk, pk := crypto.GenerateKeyPair()
+	publicKey := PublicKey{HexBytes: hex.EncodeToString(pk[:]), CurveType: CurveTypeEdwards25519}
+	derived, rerr := s.ConstructionDerive(ConstructionDeriveRequest{NetworkIdentifier: network, PublicKey: publicKey})
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	sender := types.NewPubKeyUnlockHash(types.Ed25519PublicKey(pk))
+	if derived.AccountIdentifier.Address != sender.String() {
+		t.Fatalf("derived address %s, expected %s", derived.AccountIdentifier.Address, sender.String())
+	}
+
+	coinID := types.CoinOutputID(crypto.HashObject("coin"))
+	cs.coinOutputs[coinID] = types.CoinOutput{
+		Value:     types.NewCurrency64(100e9),
+		Condition: types.NewCondition(types.NewUnlockHashCondition(sender)),
+	}
+	receiver := types.UnlockHash{Type: types.UnlockTypePubKey, Hash: crypto.HashObject("receiver")}
+	ops := []Operation{
+		{
+			OperationIdentifier: OperationIdentifier{Index: 0},
+			Type:                OperationTypeInput,
+			Account:             &AccountIdentifier{Address: sender.String()},
+			Amount:              &Amount{Value: "-100000000000", Currency: s.currency},
+			CoinChange: &CoinChange{
+				CoinIdentifier: CoinIdentifier{Identifier: coinID.String()},
+				CoinAction:     CoinActionSpent,
+			},
+		},
+		{
+			OperationIdentifier: OperationIdentifier{Index: 1},
+			Type:                OperationTypeOutput,
+			Account:             &AccountIdentifier{Address: receiver.String()},
+			Amount:              &Amount{Value: "99000000000", Currency: s.currency},
+		},
+	}
+
+	preprocessed, rerr := s.ConstructionPreprocess(ConstructionPreprocessRequest{NetworkIdentifier: network, Operations: ops})
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	if len(preprocessed.RequiredPublicKeys) != 1 || preprocessed.RequiredPublicKeys[0].Address != sender.String() {
+		t.Fatalf("unexpected required public keys: %v", preprocessed.RequiredPublicKeys)
+	}
+	_, rerr = s.ConstructionMetadata(ConstructionMetadataRequest{
+		NetworkIdentifier: network,
+		Options:           map[string]interface{}{"coins": []interface{}{coinID.String()}},
+	})
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+
+	payloads, rerr := s.ConstructionPayloads(ConstructionPayloadsRequest{
+		NetworkIdentifier: network,
+		Operations:        ops,
+		PublicKeys:        []PublicKey{publicKey},
+	})
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	if len(payloads.Payloads) != 1 {
+		t.Fatalf("expected 1 payload, got %d", len(payloads.Payloads))
+	}
+	parsed, rerr := s.ConstructionParse(ConstructionParseRequest{NetworkIdentifier: network, Transaction: payloads.UnsignedTransaction})
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	if len(parsed.Operations) != 2 || parsed.Operations[0].Amount.Value != "-100000000000" || parsed.Operations[1].Amount.Value != "99000000000" {
+		t.Fatalf("unexpected parsed operations: %v", parsed.Operations)
+	}
+
+	// an invalid signature is refused
+	var sigHash crypto.Hash
+	b, _ := hex.DecodeString(payloads.Payloads[0].HexBytes)
+	copy(sigHash[:], b)
+	sig := crypto.SignHash(crypto.HashObject("other"), sk)
+	signature := Signature{
+		SigningPayload: payloads.Payloads[0],
+		PublicKey:      publicKey,
+		SignatureType:  SignatureTypeEd25519,
+		HexBytes:       hex.EncodeToString(sig[:]),
+	}
+	_, rerr = s.ConstructionCombine(ConstructionCombineRequest{
+		NetworkIdentifier:   network,
+		UnsignedTransaction: payloads.UnsignedTransaction,
+		Signatures:          []Signature{signature},
+	})
+	if rerr == nil || rerr.Code != ErrInvalidSignature.Code {
+		t.Fatalf("expected invalid signature error, got %v", rerr)
+	}
+
+	sig = crypto.SignHash(sigHash, sk)
+	signature.HexBytes = hex.EncodeToString(sig[:])
+	combined, rerr := s.ConstructionCombine(ConstructionCombineRequest{
+		NetworkIdentifier:   network,
+		UnsignedTransaction: payloads.UnsignedTransaction,
+		Signatures:          []Signature{signature},
+	})
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	hashed, rerr := s.ConstructionHash(ConstructionHashRequest{NetworkIdentifier: network, SignedTransaction: combined.SignedTransaction})
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	submitted, rerr := s.ConstructionSubmit(ConstructionSubmitRequest{NetworkIdentifier: network, SignedTransaction: combined.SignedTransaction})
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	if submitted.TransactionIdentifier != hashed.TransactionIdentifier {
+		t.Fatalf("submitted transaction %s, expected %s", submitted.TransactionIdentifier.Hash, hashed.TransactionIdentifier.Hash)
+	}
+
+	if len(tpool.accepted) != 1 {
+		t.Fatalf("expected 1 accepted transaction, got %d", len(tpool.accepted))
+	}
+	txn := tpool.accepted[0]
+	if txn.ID().String() != hashed.TransactionIdentifier.Hash {
+		t.Fatalf("accepted transaction %s, expected %s", txn.ID().String(), hashed.TransactionIdentifier.Hash)
+	}
+	if len(txn.MinerFees) != 1 || txn.MinerFees[0].Cmp(types.NewCurrency64(1e9)) != 0 {
+		t.Fatalf("unexpected miner fees: %v", txn.MinerFees)
+	}
+	err := cs.coinOutputs[coinID].Condition.Fulfill(txn.CoinInputs[0].Fulfillment, types.FulfillContext{
+		InputIndex:  0,
+		Transaction: txn,
+	})
+	if err != nil {
+		t.Fatalf("accepted transaction isn't fulfilled: %v", err)
+	}
+}
+
+func TestConstructionPayloadsValidation(t *testing.T) {
+	s, _, _, cleanup := newTestServer(t)
+	defer cleanup()
+
+	_, pk := crypto.GenerateKeyPair()
+	sender := types.NewPubKeyUnlockHash(types.Ed25519PublicKey(pk))
+	input := Operation{
+		OperationIdentifier: OperationIdentifier{Index: 0},
+		Type:                OperationTypeInput,
+		Account:             &AccountIdentifier{Address: sender.String()},
+		Amount:              &Amount{Value: "-10", Currency: s.currency},
+		CoinChange: &CoinChange{
+			CoinIdentifier: CoinIdentifier{Identifier: types.CoinOutputID{}.String()},
+			CoinAction:     CoinActionSpent,
+		},
+	}
+	output := Operation{
+		OperationIdentifier: OperationIdentifier{Index: 1},
+		Type:                OperationTypeOutput,
+		Account:             &AccountIdentifier{Address: sender.String()},
+		Amount:              &Amount{Value: "9", Currency: s.currency},
+	}
+	publicKeys := []PublicKey{{HexBytes: hex.EncodeToString(pk[:]), CurveType: CurveTypeEdwards25519}}
+
+	testCases := []struct {
+		Description string
+		Operations  []Operation
+		PublicKeys  []PublicKey
+		Code        int32
+	}{
+		{"no outputs", []Operation{input}, publicKeys, ErrInvalidOperations.Code},
+		{"fee below minimum", []Operation{input, output}, publicKeys, ErrInvalidOperations.Code},
+		{"no public key", []Operation{input, output}, nil, ErrInvalidPublicKey.Code},
+		{"unsupported operation", []Operation{input, {Type: OperationTypeMint, Account: output.Account, Amount: output.Amount}}, publicKeys, ErrInvalidOperations.Code},
+	}
+	for _, testCase := range testCases {
+		_, rerr := s.ConstructionPayloads(ConstructionPayloadsRequest{
+			NetworkIdentifier: s.network,
+			Operations:        testCase.Operations,
+			PublicKeys:        testCase.PublicKeys,
+		})
+		if rerr == nil || rerr.Code != testCase.Code {
+			t.Errorf("%s: expected error code %d, got %v", testCase.Description, testCase.Code, rerr)
+		}
+	}
+}
+
+func TestTransactionOperations(t *testing.T) {
+	s, _, _, cleanup := newTestServer(t)
+	defer cleanup()
+	tftypes.RegisterTransactionTypesForDevNetwork(nil)
+	defer func() {
+		types.RegisterTransactionVersion(tftypes.TransactionVersionCoinCreation, nil)
+		types.RegisterTransactionVersion(tftypes.TransactionVersionMinterDefinition, nil)
+	}()
+
+	minter := types.UnlockHash{Type: types.UnlockTypePubKey, Hash: crypto.HashObject("minter")}
+	cctx := tftypes.CoinCreationTransaction{
+		CoinOutputs: []types.CoinOutput{{
+			Value:     types.NewCurrency64(42),
+			Condition: types.NewCondition(types.NewUnlockHashCondition(minter)),
+		}},
+		MinerFees: []types.Currency{types.NewCurrency64(1)},
+	}
+	transaction, rerr := s.transaction(cctx.Transaction(), nil, true)
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	if len(transaction.Operations) != 1 || transaction.Operations[0].Type != OperationTypeMint ||
+		transaction.Operations[0].Amount.Value != "42" || transaction.Operations[0].Account.Address != minter.String() {
+		t.Fatalf("unexpected coin creation operations: %v", transaction.Operations)
+	}
+
+	mdtx := tftypes.MinterDefinitionTransaction{
+		MintCondition: types.NewCondition(types.NewUnlockHashCondition(minter)),
+		MinerFees:     []types.Currency{types.NewCurrency64(1)},
+	}
+	transaction, rerr = s.transaction(mdtx.Transaction(), nil, true)
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	if len(transaction.Operations) != 1 || transaction.Operations[0].Type != OperationTypeMinterDefinition ||
+		transaction.Operations[0].Amount != nil || transaction.Operations[0].Account.Address != minter.String() {
+		t.Fatalf("unexpected minter definition operations: %v", transaction.Operations)
+	}
+}
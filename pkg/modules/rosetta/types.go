@@ -0,0 +1,345 @@
+package rosetta
+
+// The Rosetta (Mesh) API models used by this implementation,
+// as defined by the Rosetta specification (https://www.rosetta-api.org/docs/Reference.html).
+
+type (
+	// NetworkIdentifier identifies the network served.
+	NetworkIdentifier struct {
+		Blockchain string `json:"blockchain"`
+		Network    string `json:"network"`
+	}
+
+	// BlockIdentifier uniquely identifies a block.
+	BlockIdentifier struct {
+		Index int64  `json:"index"`
+		Hash  string `json:"hash"`
+	}
+
+	// PartialBlockIdentifier identifies a block by index or hash,
+	// the current block is identified if neither is given.
+	PartialBlockIdentifier struct {
+		Index *int64  `json:"index,omitempty"`
+		Hash  *string `json:"hash,omitempty"`
+	}
+
+	// TransactionIdentifier uniquely identifies a transaction.
+	TransactionIdentifier struct {
+		Hash string `json:"hash"`
+	}
+
+	// AccountIdentifier identifies an account by its address (unlock hash).
+	AccountIdentifier struct {
+		Address string `json:"address"`
+	}
+
+	// Currency is the currency of an amount.
+	Currency struct {
+		Symbol   string `json:"symbol"`
+		Decimals int32  `json:"decimals"`
+	}
+
+	// Amount is a signed value in the smallest unit of its currency.
+	Amount struct {
+		Value    string   `json:"value"`
+		Currency Currency `json:"currency"`
+	}
+
+	// CoinIdentifier identifies a coin (output) by its ID.
+	CoinIdentifier struct {
+		Identifier string `json:"identifier"`
+	}
+
+	// CoinChange defines whether an operation creates or spends a coin.
+	CoinChange struct {
+		CoinIdentifier CoinIdentifier `json:"coin_identifier"`
+		CoinAction     CoinAction     `json:"coin_action"`
+	}
+
+	// Coin is an unspent coin (output) of an account.
+	Coin struct {
+		CoinIdentifier CoinIdentifier `json:"coin_identifier"`
+		Amount         Amount         `json:"amount"`
+	}
+
+	// OperationIdentifier identifies an operation within a transaction.
+	OperationIdentifier struct {
+		Index int64 `json:"index"`
+	}
+
+	// Operation is a single balance change of an account,
+	// or a change of the chain state (such as the definition of new minters).
+	Operation struct {
+		OperationIdentifier OperationIdentifier    `json:"operation_identifier"`
+		RelatedOperations   []OperationIdentifier  `json:"related_operations,omitempty"`
+		Type                string                 `json:"type"`
+		Status              *string                `json:"status,omitempty"`
+		Account             *AccountIdentifier     `json:"account,omitempty"`
+		Amount              *Amount                `json:"amount,omitempty"`
+		CoinChange          *CoinChange            `json:"coin_change,omitempty"`
+		Metadata            map[string]interface{} `json:"metadata,omitempty"`
+	}
+
+	// Transaction contains the operations of a transaction.
+	Transaction struct {
+		TransactionIdentifier TransactionIdentifier  `json:"transaction_identifier"`
+		Operations            []Operation            `json:"operations"`
+		Metadata              map[string]interface{} `json:"metadata,omitempty"`
+	}
+
+	// Block contains the transactions of a block.
+	Block struct {
+		BlockIdentifier       BlockIdentifier `json:"block_identifier"`
+		ParentBlockIdentifier BlockIdentifier `json:"parent_block_identifier"`
+		// Timestamp in milliseconds since the Unix epoch
+		Timestamp    int64         `json:"timestamp"`
+		Transactions []Transaction `json:"transactions"`
+	}
+
+	// PublicKey is a public key of the given curve type.
+	PublicKey struct {
+		HexBytes  string    `json:"hex_bytes"`
+		CurveType CurveType `json:"curve_type"`
+	}
+
+	// SigningPayload is a payload which has to be signed by the given account.
+	SigningPayload struct {
+		AccountIdentifier *AccountIdentifier `json:"account_identifier,omitempty"`
+		HexBytes          string             `json:"hex_bytes"`
+		SignatureType     SignatureType      `json:"signature_type,omitempty"`
+	}
+
+	// Signature is the signature of a signing payload.
+	Signature struct {
+		SigningPayload SigningPayload `json:"signing_payload"`
+		PublicKey      PublicKey      `json:"public_key"`
+		SignatureType  SignatureType  `json:"signature_type"`
+		HexBytes       string         `json:"hex_bytes"`
+	}
+
+	// Error is returned by all endpoints in case the request failed.
+	Error struct {
+		Code      int32                  `json:"code"`
+		Message   string                 `json:"message"`
+		Retriable bool                   `json:"retriable"`
+		Details   map[string]interface{} `json:"details,omitempty"`
+	}
+
+	// Peer is a peer the node is connected to.
+	Peer struct {
+		PeerID string `json:"peer_id"`
+	}
+
+	// SyncStatus defines the sync status of the node.
+	SyncStatus struct {
+		CurrentIndex int64 `json:"current_index"`
+		Synced       bool  `json:"synced"`
+	}
+
+	// Version contains the versions of the Rosetta specification and the node.
+	Version struct {
+		RosettaVersion string `json:"rosetta_version"`
+		NodeVersion    string `json:"node_version"`
+	}
+
+	// OperationStatus defines an operation status and whether it is successful.
+	OperationStatus struct {
+		Status     string `json:"status"`
+		Successful bool   `json:"successful"`
+	}
+
+	// Allow defines what is supported by this implementation.
+	Allow struct {
+		OperationStatuses       []OperationStatus `json:"operation_statuses"`
+		OperationTypes          []string          `json:"operation_types"`
+		Errors                  []Error           `json:"errors"`
+		HistoricalBalanceLookup bool              `json:"historical_balance_lookup"`
+		MempoolCoins            bool              `json:"mempool_coins"`
+	}
+)
+
+// CoinAction defines whether a coin is created or spent.
+type CoinAction string
+
+// All coin actions.
+const (
+	CoinActionCreated CoinAction = "coin_created"
+	CoinActionSpent   CoinAction = "coin_spent"
+)
+
+// CurveType defines the curve of a public key.
+type CurveType string
+
+// CurveTypeEdwards25519 is the only curve supported by tfchain.
+const CurveTypeEdwards25519 CurveType = "edwards25519"
+
+// SignatureType defines the type of a signature.
+type SignatureType string
+
+// SignatureTypeEd25519 is the only signature type supported by tfchain.
+const SignatureTypeEd25519 SignatureType = "ed25519"
+
+// Request and response bodies of the Data API.
+type (
+	// MetadataRequest is the body of the /network/list request.
+	MetadataRequest struct {
+		Metadata map[string]interface{} `json:"metadata,omitempty"`
+	}
+	// NetworkRequest is the body of the /network/options and /network/status requests.
+	NetworkRequest struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+	}
+	// NetworkListResponse is the response to the /network/list request.
+	NetworkListResponse struct {
+		NetworkIdentifiers []NetworkIdentifier `json:"network_identifiers"`
+	}
+	// NetworkOptionsResponse is the response to the /network/options request.
+	NetworkOptionsResponse struct {
+		Version Version `json:"version"`
+		Allow   Allow   `json:"allow"`
+	}
+	// NetworkStatusResponse is the response to the /network/status request.
+	NetworkStatusResponse struct {
+		CurrentBlockIdentifier BlockIdentifier `json:"current_block_identifier"`
+		// CurrentBlockTimestamp in milliseconds since the Unix epoch
+		CurrentBlockTimestamp  int64           `json:"current_block_timestamp"`
+		GenesisBlockIdentifier BlockIdentifier `json:"genesis_block_identifier"`
+		SyncStatus             *SyncStatus     `json:"sync_status,omitempty"`
+		Peers                  []Peer          `json:"peers"`
+	}
+	// BlockRequest is the body of the /block request.
+	BlockRequest struct {
+		NetworkIdentifier NetworkIdentifier      `json:"network_identifier"`
+		BlockIdentifier   PartialBlockIdentifier `json:"block_identifier"`
+	}
+	// BlockResponse is the response to the /block request.
+	BlockResponse struct {
+		Block *Block `json:"block,omitempty"`
+	}
+	// BlockTransactionRequest is the body of the /block/transaction request.
+	BlockTransactionRequest struct {
+		NetworkIdentifier     NetworkIdentifier     `json:"network_identifier"`
+		BlockIdentifier       BlockIdentifier       `json:"block_identifier"`
+		TransactionIdentifier TransactionIdentifier `json:"transaction_identifier"`
+	}
+	// BlockTransactionResponse is the response to the /block/transaction request.
+	BlockTransactionResponse struct {
+		Transaction Transaction `json:"transaction"`
+	}
+	// AccountBalanceRequest is the body of the /account/balance request.
+	AccountBalanceRequest struct {
+		NetworkIdentifier NetworkIdentifier       `json:"network_identifier"`
+		AccountIdentifier AccountIdentifier       `json:"account_identifier"`
+		BlockIdentifier   *PartialBlockIdentifier `json:"block_identifier,omitempty"`
+	}
+	// AccountBalanceResponse is the response to the /account/balance request.
+	AccountBalanceResponse struct {
+		BlockIdentifier BlockIdentifier `json:"block_identifier"`
+		Balances        []Amount        `json:"balances"`
+	}
+	// AccountCoinsRequest is the body of the /account/coins request.
+	AccountCoinsRequest struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+		AccountIdentifier AccountIdentifier `json:"account_identifier"`
+		IncludeMempool    bool              `json:"include_mempool"`
+	}
+	// AccountCoinsResponse is the response to the /account/coins request.
+	AccountCoinsResponse struct {
+		BlockIdentifier BlockIdentifier `json:"block_identifier"`
+		Coins           []Coin          `json:"coins"`
+	}
+	// MempoolResponse is the response to the /mempool request.
+	MempoolResponse struct {
+		TransactionIdentifiers []TransactionIdentifier `json:"transaction_identifiers"`
+	}
+	// MempoolTransactionRequest is the body of the /mempool/transaction request.
+	MempoolTransactionRequest struct {
+		NetworkIdentifier     NetworkIdentifier     `json:"network_identifier"`
+		TransactionIdentifier TransactionIdentifier `json:"transaction_identifier"`
+	}
+	// MempoolTransactionResponse is the response to the /mempool/transaction request.
+	MempoolTransactionResponse struct {
+		Transaction Transaction `json:"transaction"`
+	}
+)
+
+// Request and response bodies of the Construction API.
+type (
+	// ConstructionDeriveRequest is the body of the /construction/derive request.
+	ConstructionDeriveRequest struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+		PublicKey         PublicKey         `json:"public_key"`
+	}
+	// ConstructionDeriveResponse is the response to the /construction/derive request.
+	ConstructionDeriveResponse struct {
+		AccountIdentifier AccountIdentifier `json:"account_identifier"`
+	}
+	// ConstructionPreprocessRequest is the body of the /construction/preprocess request.
+	ConstructionPreprocessRequest struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+		Operations        []Operation       `json:"operations"`
+	}
+	// ConstructionPreprocessResponse is the response to the /construction/preprocess request.
+	ConstructionPreprocessResponse struct {
+		Options            map[string]interface{} `json:"options"`
+		RequiredPublicKeys []AccountIdentifier    `json:"required_public_keys"`
+	}
+	// ConstructionMetadataRequest is the body of the /construction/metadata request.
+	ConstructionMetadataRequest struct {
+		NetworkIdentifier NetworkIdentifier      `json:"network_identifier"`
+		Options           map[string]interface{} `json:"options"`
+		PublicKeys        []PublicKey            `json:"public_keys,omitempty"`
+	}
+	// ConstructionMetadataResponse is the response to the /construction/metadata request.
+	ConstructionMetadataResponse struct {
+		Metadata     map[string]interface{} `json:"metadata"`
+		SuggestedFee []Amount               `json:"suggested_fee"`
+	}
+	// ConstructionPayloadsRequest is the body of the /construction/payloads request.
+	ConstructionPayloadsRequest struct {
+		NetworkIdentifier NetworkIdentifier      `json:"network_identifier"`
+		Operations        []Operation            `json:"operations"`
+		Metadata          map[string]interface{} `json:"metadata,omitempty"`
+		PublicKeys        []PublicKey            `json:"public_keys"`
+	}
+	// ConstructionPayloadsResponse is the response to the /construction/payloads request.
+	ConstructionPayloadsResponse struct {
+		UnsignedTransaction string           `json:"unsigned_transaction"`
+		Payloads            []SigningPayload `json:"payloads"`
+	}
+	// ConstructionParseRequest is the body of the /construction/parse request.
+	ConstructionParseRequest struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+		Signed            bool              `json:"signed"`
+		Transaction       string            `json:"transaction"`
+	}
+	// ConstructionParseResponse is the response to the /construction/parse request.
+	ConstructionParseResponse struct {
+		Operations               []Operation         `json:"operations"`
+		AccountIdentifierSigners []AccountIdentifier `json:"account_identifier_signers,omitempty"`
+	}
+	// ConstructionCombineRequest is the body of the /construction/combine request.
+	ConstructionCombineRequest struct {
+		NetworkIdentifier   NetworkIdentifier `json:"network_identifier"`
+		UnsignedTransaction string            `json:"unsigned_transaction"`
+		Signatures          []Signature       `json:"signatures"`
+	}
+	// ConstructionCombineResponse is the response to the /construction/combine request.
+	ConstructionCombineResponse struct {
+		SignedTransaction string `json:"signed_transaction"`
+	}
+	// ConstructionHashRequest is the body of the /construction/hash request.
+	ConstructionHashRequest struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+		SignedTransaction string            `json:"signed_transaction"`
+	}
+	// ConstructionSubmitRequest is the body of the /construction/submit request.
+	ConstructionSubmitRequest struct {
+		NetworkIdentifier NetworkIdentifier `json:"network_identifier"`
+		SignedTransaction string            `json:"signed_transaction"`
+	}
+	// TransactionIdentifierResponse is the response to the /construction/hash and /construction/submit requests.
+	TransactionIdentifierResponse struct {
+		TransactionIdentifier TransactionIdentifier `json:"transaction_identifier"`
+	}
+)
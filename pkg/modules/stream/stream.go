@@ -0,0 +1,599 @@
+// Package stream implements a stream of chain activity, fed by the consensus set and transaction pool,
+// to which clients can subscribe, optionally resuming from an earlier consensus change.
+package stream
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	tftypes "github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	rivinesync "github.com/rivine/rivine/sync"
+	"github.com/rivine/rivine/types"
+)
+
+// subscriptionQueueSize is the amount of consensus changes and transaction pool updates
+// that can be queued for a live subscription, prior to it being closed for not keeping up.
+const subscriptionQueueSize = 1024
+
+// subscriptionCatchUpTimeout is the time a subscription which is catching up on past consensus changes
+// can take to make room in its full queue, prior to it being closed for not keeping up,
+// as the consensus set is locked while catching up.
+const subscriptionCatchUpTimeout = time.Minute
+
+// Errors returned by the stream.
+var (
+	// ErrSubscriptionOverflow is the error of a subscription which was closed
+	// because its client didn't consume the events fast enough,
+	// the client can resume from the last consensus change it received.
+	ErrSubscriptionOverflow = errors.New("subscription was closed as its events were not consumed fast enough")
+	// ErrStreamClosed is the error of a subscription which was closed because the stream was closed.
+	ErrStreamClosed = errors.New("stream is closed")
+)
+
+// EventType defines the type of an event.
+type EventType string
+
+// All possible event types.
+const (
+	// EventTypeBlockApplied is the type of the event of a block applied to the chain.
+	EventTypeBlockApplied EventType = "block.applied"
+	// EventTypeBlockReverted is the type of the event of a block reverted from the chain.
+	EventTypeBlockReverted EventType = "block.reverted"
+	// EventTypeTransactionConfirmed is the type of the event of a transaction confirmed as part of an applied block.
+	EventTypeTransactionConfirmed EventType = "transaction.confirmed"
+	// EventTypeTransactionReverted is the type of the event of a transaction unconfirmed as part of a reverted block.
+	EventTypeTransactionReverted EventType = "transaction.reverted"
+	// EventTypeTransactionUnconfirmed is the type of the event of a transaction added to the transaction pool.
+	EventTypeTransactionUnconfirmed EventType = "transaction.unconfirmed"
+	// EventTypeMintConditionChanged is the type of the event of a mint condition
+	// defined (or reverted) by a minter definition transaction.
+	EventTypeMintConditionChanged EventType = "mintcondition.changed"
+	// EventTypeConsensusChange is the type of the event which concludes all events of a consensus change,
+	// it is the only event which defines a consensus change ID to resume from.
+	EventTypeConsensusChange EventType = "consensus.change"
+)
+
+// IsValid returns true if the event type is known.
+func (t EventType) IsValid() bool {
+	switch t {
+	case EventTypeBlockApplied, EventTypeBlockReverted,
+		EventTypeTransactionConfirmed, EventTypeTransactionReverted, EventTypeTransactionUnconfirmed,
+		EventTypeMintConditionChanged, EventTypeConsensusChange:
+		return true
+	default:
+		return false
+	}
+}
+
+type (
+	// Stream streams the chain activity to all its subscriptions.
+	Stream struct {
+		// The Stream's ThreadGroup tells tracked functions to shut down and
+		// blocks until they have all exited before returning from Close.
+		tg rivinesync.ThreadGroup
+
+		cs    modules.ConsensusSet
+		tpool modules.TransactionPool
+
+		// implements modules.TransactionPoolSubscriber
+		tpoolSubscriber *tpoolSubscriber
+
+		mu            sync.Mutex
+		subscriptions map[*Subscription]struct{}
+		// unconfirmed contains the IDs of all transactions in the transaction pool,
+		// such that only new transactions are streamed
+		unconfirmed map[types.TransactionID]struct{}
+	}
+
+	// Filter can be used to filter the events of a subscription.
+	// All defined properties have to match.
+	Filter struct {
+		// Types matches all events of one of the given types,
+		// consensus change events are always delivered
+		Types []EventType
+		// UnlockHashes matches all transaction events of transactions
+		// which send to or spend from one of the given unlock hashes
+		UnlockHashes []types.UnlockHash
+		// Versions matches all transaction events of transactions with one of the given versions
+		Versions []types.TransactionVersion
+	}
+
+	// Event is a single event of a subscription,
+	// only the property matching the type of the event is defined.
+	Event struct {
+		Type            EventType             `json:"type"`
+		Block           *BlockEvent           `json:"block,omitempty"`
+		Transaction     *TransactionEvent     `json:"transaction,omitempty"`
+		MintCondition   *MintConditionEvent   `json:"mintcondition,omitempty"`
+		ConsensusChange *ConsensusChangeEvent `json:"consensuschange,omitempty"`
+	}
+
+	// BlockEvent describes a block applied to, or reverted from, the chain.
+	BlockEvent struct {
+		ID             types.BlockID         `json:"id"`
+		Height         types.BlockHeight     `json:"height"`
+		Timestamp      types.Timestamp       `json:"timestamp"`
+		TransactionIDs []types.TransactionID `json:"transactionids"`
+	}
+
+	// TransactionEvent describes a confirmed, reverted or unconfirmed transaction,
+	// the block ID and height are only defined for a (previously) confirmed transaction.
+	TransactionEvent struct {
+		ID          types.TransactionID `json:"id"`
+		BlockID     *types.BlockID      `json:"blockid,omitempty"`
+		Height      types.BlockHeight   `json:"height,omitempty"`
+		Transaction types.Transaction   `json:"transaction"`
+	}
+
+	// MintConditionEvent describes a mint condition defined by a minter definition transaction,
+	// or reverted together with the block of that transaction.
+	MintConditionEvent struct {
+		TransactionID types.TransactionID        `json:"transactionid"`
+		BlockID       types.BlockID              `json:"blockid"`
+		Height        types.BlockHeight          `json:"height"`
+		MintCondition types.UnlockConditionProxy `json:"mintcondition"`
+		Reverted      bool                       `json:"reverted"`
+	}
+
+	// ConsensusChangeEvent concludes all events of a consensus change,
+	// defining the ID a client can resume from after a disconnect.
+	ConsensusChangeEvent struct {
+		// ID of the consensus change, as a hash as modules.ConsensusChangeID has no string encoding
+		ID     crypto.Hash       `json:"id"`
+		Height types.BlockHeight `json:"height"`
+		Synced bool              `json:"synced"`
+	}
+
+	// Subscription receives the (filtered) events of a stream,
+	// until it is closed by its owner, the stream is closed or it didn't keep up.
+	Subscription struct {
+		stream *Stream
+		filter Filter
+
+		// implements modules.ConsensusSetSubscriber
+		csSubscriber *csSubscriber
+		// closed once the consensus set subscription returned
+		subscribed chan struct{}
+		// closed once the first consensus change was received
+		started     chan struct{}
+		startedOnce sync.Once
+
+		queue  chan update
+		events chan Event
+
+		stopOnce sync.Once
+		stop     chan struct{}
+
+		mu sync.Mutex
+		// live is false as long as past consensus changes are being received,
+		// transaction pool updates are only received once live
+		live bool
+		// catchingUp is the amount of past consensus changes which are queued but not yet processed
+		catchingUp int
+		err        error
+	}
+
+	// update is a consensus change, or the transactions added to the transaction pool,
+	// as queued for a subscription
+	update struct {
+		change      modules.ConsensusChange
+		unconfirmed []types.Transaction
+		// past is true for the consensus changes received while subscribing
+		past bool
+	}
+
+	csSubscriber struct {
+		sub *Subscription
+	}
+	tpoolSubscriber struct {
+		stream *Stream
+	}
+)
+
+// New creates a new stream, subscribing to the given transaction pool,
+// while each subscription subscribes to the given consensus set on its own.
+func New(cs modules.ConsensusSet, tpool modules.TransactionPool) (*Stream, error) {
+	if cs == nil {
+		return nil, errors.New("stream requires a consensus set")
+	}
+	if tpool == nil {
+		return nil, errors.New("stream requires a transaction pool")
+	}
+	stream := &Stream{
+		cs:            cs,
+		tpool:         tpool,
+		subscriptions: make(map[*Subscription]struct{}),
+		unconfirmed:   make(map[types.TransactionID]struct{}),
+	}
+	stream.tpoolSubscriber = &tpoolSubscriber{stream: stream}
+	tpool.TransactionPoolSubscribe(stream.tpoolSubscriber)
+	return stream, nil
+}
+
+// Subscribe creates a new subscription, receiving the events of all consensus changes after the given one,
+// or only the events of new consensus changes in case modules.ConsensusChangeRecent is given.
+// Once caught up with the consensus set, the subscription receives the events of new unconfirmed transactions as well.
+// The subscription has to be closed by the caller once it is no longer used.
+func (stream *Stream) Subscribe(start modules.ConsensusChangeID, filter Filter) (*Subscription, error) {
+	if err := stream.tg.Add(); err != nil {
+		return nil, ErrStreamClosed
+	}
+	defer stream.tg.Done()
+
+	sub := &Subscription{
+		stream:     stream,
+		filter:     filter,
+		subscribed: make(chan struct{}),
+		started:    make(chan struct{}),
+		queue:      make(chan update, subscriptionQueueSize),
+		events:     make(chan Event),
+		stop:       make(chan struct{}),
+	}
+	sub.csSubscriber = &csSubscriber{sub: sub}
+	// updates are processed prior to subscribing, such that the past consensus changes,
+	// which are queued while subscribing, can be caught up on at the pace of the client
+	go sub.threadedProcessUpdates()
+
+	// subscribing blocks until all past consensus changes are received,
+	// hence we only wait until the first one is received, or the subscription failed
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- stream.cs.ConsensusSetSubscribe(sub.csSubscriber, start, sub.stop)
+	}()
+	select {
+	case err := <-errChan:
+		close(sub.subscribed)
+		if err != nil {
+			sub.close(err)
+			return nil, err
+		}
+		sub.setLive()
+	case <-sub.started:
+		go func() {
+			err := <-errChan
+			close(sub.subscribed)
+			if err != nil {
+				sub.close(err)
+				return
+			}
+			sub.setLive()
+		}()
+	}
+
+	stream.mu.Lock()
+	stream.subscriptions[sub] = struct{}{}
+	stream.mu.Unlock()
+	return sub, nil
+}
+
+// Close the stream, closing all its subscriptions and unsubscribing from the transaction pool.
+func (stream *Stream) Close() error {
+	err := stream.tg.Stop()
+	stream.tpool.Unsubscribe(stream.tpoolSubscriber)
+	stream.mu.Lock()
+	for sub := range stream.subscriptions {
+		sub.close(ErrStreamClosed)
+	}
+	stream.mu.Unlock()
+	return err
+}
+
+// ReceiveUpdatedUnconfirmedTransactions implements modules.TransactionPoolSubscriber,
+// queueing all new transactions for all live subscriptions.
+func (sub *tpoolSubscriber) ReceiveUpdatedUnconfirmedTransactions(txns []types.Transaction, cc modules.ConsensusChange) {
+	stream := sub.stream
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	unconfirmed := make(map[types.TransactionID]struct{}, len(txns))
+	var added []types.Transaction
+	for _, txn := range txns {
+		id := txn.ID()
+		unconfirmed[id] = struct{}{}
+		if _, ok := stream.unconfirmed[id]; !ok {
+			added = append(added, txn)
+		}
+	}
+	stream.unconfirmed = unconfirmed
+	if len(added) == 0 {
+		return
+	}
+	for s := range stream.subscriptions {
+		if s.isLive() {
+			s.enqueue(update{change: cc, unconfirmed: added})
+		}
+	}
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber,
+// queueing the consensus change for the subscription.
+func (sub *csSubscriber) ProcessConsensusChange(cc modules.ConsensusChange) {
+	sub.sub.startedOnce.Do(func() { close(sub.sub.started) })
+	sub.sub.enqueue(update{change: cc})
+}
+
+// Events returns the channel the events of the subscription are received on,
+// which is closed once the subscription is closed.
+func (sub *Subscription) Events() <-chan Event {
+	return sub.events
+}
+
+// Err returns the reason the subscription was closed by the stream,
+// nil if it is still open or was closed by its owner.
+func (sub *Subscription) Err() error {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	return sub.err
+}
+
+// Close the subscription, unsubscribing it from the consensus set.
+func (sub *Subscription) Close() {
+	sub.close(nil)
+	// wait for the consensus set subscription to return,
+	// as it can only be unsubscribed once it is subscribed
+	<-sub.subscribed
+	sub.stream.cs.Unsubscribe(sub.csSubscriber)
+	sub.stream.mu.Lock()
+	delete(sub.stream.subscriptions, sub)
+	sub.stream.mu.Unlock()
+}
+
+// close the subscription for the given reason, only the first reason is kept
+func (sub *Subscription) close(err error) {
+	sub.stopOnce.Do(func() {
+		sub.mu.Lock()
+		sub.err = err
+		sub.mu.Unlock()
+		close(sub.stop)
+	})
+}
+
+func (sub *Subscription) setLive() {
+	sub.mu.Lock()
+	sub.live = true
+	sub.mu.Unlock()
+}
+
+func (sub *Subscription) isLive() bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	return sub.live
+}
+
+// enqueue an update, closing the subscription instead should its queue be full,
+// as the consensus set and transaction pool are locked while notifying.
+// As long as it is catching up on past consensus changes, it blocks until the client makes room in the queue instead,
+// such that a client can resume from any consensus change, as long as it keeps consuming.
+func (sub *Subscription) enqueue(u update) {
+	sub.mu.Lock()
+	u.past = !sub.live
+	if u.past {
+		sub.catchingUp++
+	}
+	catchingUp := sub.catchingUp > 0
+	sub.mu.Unlock()
+
+	select {
+	case <-sub.stop:
+		return
+	case sub.queue <- u:
+		return
+	default:
+		if !catchingUp {
+			sub.close(ErrSubscriptionOverflow)
+			return
+		}
+	}
+	timeout := time.NewTimer(subscriptionCatchUpTimeout)
+	defer timeout.Stop()
+	select {
+	case <-sub.stop:
+	case sub.queue <- u:
+	case <-timeout.C:
+		sub.close(ErrSubscriptionOverflow)
+	}
+}
+
+// threadedProcessUpdates converts all queued updates into events,
+// sending those that match the filter, until the subscription is closed
+func (sub *Subscription) threadedProcessUpdates() {
+	defer close(sub.events)
+	for {
+		var u update
+		select {
+		case <-sub.stop:
+			return
+		case u = <-sub.queue:
+		}
+		if u.past {
+			sub.mu.Lock()
+			sub.catchingUp--
+			sub.mu.Unlock()
+		}
+		var events []Event
+		if u.unconfirmed != nil {
+			events = sub.unconfirmedEvents(u.unconfirmed, u.change)
+		} else {
+			events = sub.consensusChangeEvents(u.change)
+		}
+		for _, event := range events {
+			if !sub.filter.matchEvent(event) {
+				continue
+			}
+			select {
+			case <-sub.stop:
+				return
+			case sub.events <- event:
+			}
+		}
+	}
+}
+
+// consensusChangeEvents converts a consensus change into events,
+// concluded by the consensus change event itself
+func (sub *Subscription) consensusChangeEvents(cc modules.ConsensusChange) []Event {
+	outputs := newSpentOutputs(cc)
+	var (
+		events []Event
+		height types.BlockHeight
+	)
+	for _, block := range cc.RevertedBlocks {
+		height = sub.blockHeight(block)
+		events = append(events, sub.blockEvents(block, height, outputs, true)...)
+		if height > 0 {
+			height--
+		}
+	}
+	for _, block := range cc.AppliedBlocks {
+		height = sub.blockHeight(block)
+		events = append(events, sub.blockEvents(block, height, outputs, false)...)
+	}
+	return append(events, Event{
+		Type: EventTypeConsensusChange,
+		ConsensusChange: &ConsensusChangeEvent{
+			ID:     crypto.Hash(cc.ID),
+			Height: height,
+			Synced: cc.Synced,
+		},
+	})
+}
+
+// blockEvents creates the events of a single applied or reverted block
+func (sub *Subscription) blockEvents(block types.Block, height types.BlockHeight, outputs spentOutputs, reverted bool) []Event {
+	blockID := block.ID()
+	blockEvent := &BlockEvent{
+		ID:             blockID,
+		Height:         height,
+		Timestamp:      block.Timestamp,
+		TransactionIDs: make([]types.TransactionID, 0, len(block.Transactions)),
+	}
+	events := []Event{{Type: EventTypeBlockApplied, Block: blockEvent}}
+	txnType := EventTypeTransactionConfirmed
+	if reverted {
+		events[0].Type, txnType = EventTypeBlockReverted, EventTypeTransactionReverted
+	}
+	for _, txn := range block.Transactions {
+		txnID := txn.ID()
+		blockEvent.TransactionIDs = append(blockEvent.TransactionIDs, txnID)
+		if sub.filter.matchTransaction(txn, outputs) {
+			events = append(events, Event{
+				Type: txnType,
+				Transaction: &TransactionEvent{
+					ID:          txnID,
+					BlockID:     &blockID,
+					Height:      height,
+					Transaction: txn,
+				},
+			})
+		}
+		if txn.Version != tftypes.TransactionVersionMinterDefinition {
+			continue
+		}
+		mdtx, err := tftypes.MinterDefinitionTransactionFromTransaction(txn)
+		if err != nil {
+			continue // cannot happen for a valid block
+		}
+		events = append(events, Event{
+			Type: EventTypeMintConditionChanged,
+			MintCondition: &MintConditionEvent{
+				TransactionID: txnID,
+				BlockID:       blockID,
+				Height:        height,
+				MintCondition: mdtx.MintCondition,
+				Reverted:      reverted,
+			},
+		})
+	}
+	return events
+}
+
+// unconfirmedEvents creates the events of transactions added to the transaction pool
+func (sub *Subscription) unconfirmedEvents(txns []types.Transaction, cc modules.ConsensusChange) []Event {
+	outputs := newSpentOutputs(cc)
+	var events []Event
+	for _, txn := range txns {
+		if !sub.filter.matchTransaction(txn, outputs) {
+			continue
+		}
+		events = append(events, Event{
+			Type: EventTypeTransactionUnconfirmed,
+			Transaction: &TransactionEvent{
+				ID:          txn.ID(),
+				Transaction: txn,
+			},
+		})
+	}
+	return events
+}
+
+// blockHeight returns the height of the given block, which is known by the consensus set
+// even if it is no longer part of the current chain
+func (sub *Subscription) blockHeight(block types.Block) types.BlockHeight {
+	height, _ := sub.stream.cs.BlockHeightOfBlock(block)
+	return height
+}
+
+// matchEvent returns true if the event matches the types of the filter,
+// the transactions are matched while creating the events
+func (filter Filter) matchEvent(event Event) bool {
+	if len(filter.Types) == 0 || event.Type == EventTypeConsensusChange {
+		return true
+	}
+	for _, t := range filter.Types {
+		if t == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTransaction returns true if the given transaction matches the versions and unlock hashes of the filter
+func (filter Filter) matchTransaction(txn types.Transaction, outputs spentOutputs) bool {
+	if len(filter.Versions) > 0 {
+		var match bool
+		for _, version := range filter.Versions {
+			if version == txn.Version {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	if len(filter.UnlockHashes) == 0 {
+		return true
+	}
+	uhs := tftypes.TransactionUnlockHashes(txn, outputs.coinOutputs, outputs.blockStakeOutputs)
+	for _, uh := range filter.UnlockHashes {
+		if _, ok := uhs[uh]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// spentOutputs contains all outputs of a consensus change,
+// used to look up the conditions of the outputs spent by its transactions
+type spentOutputs struct {
+	coinOutputs       map[types.CoinOutputID]types.CoinOutput
+	blockStakeOutputs map[types.BlockStakeOutputID]types.BlockStakeOutput
+}
+
+func newSpentOutputs(cc modules.ConsensusChange) spentOutputs {
+	outputs := spentOutputs{
+		coinOutputs:       make(map[types.CoinOutputID]types.CoinOutput, len(cc.CoinOutputDiffs)),
+		blockStakeOutputs: make(map[types.BlockStakeOutputID]types.BlockStakeOutput, len(cc.BlockStakeOutputDiffs)),
+	}
+	for _, diff := range cc.CoinOutputDiffs {
+		outputs.coinOutputs[diff.ID] = diff.CoinOutput
+	}
+	for _, diff := range cc.BlockStakeOutputDiffs {
+		outputs.blockStakeOutputs[diff.ID] = diff.BlockStakeOutput
+	}
+	return outputs
+}
@@ -0,0 +1,207 @@
+package stream
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+func TestSubscribeCatchUp(t *testing.T) {
+	// a lot more past consensus changes than can be queued
+	const n = 3 * subscriptionQueueSize
+	cs := newTestConsensusSet(n)
+	stream := newTestStream(t, cs)
+	defer stream.Close()
+	filter := Filter{Types: []EventType{EventTypeConsensusChange}}
+
+	sub, err := stream.Subscribe(modules.ConsensusChangeBeginning, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// new consensus changes are received once caught up
+	go cs.applyBlocks(10)
+	events := receiveConsensusChanges(t, sub, n+10)
+	for idx, event := range events {
+		if event.Height != types.BlockHeight(idx) {
+			t.Fatalf("event #%d: expected height %d, not %d", idx, idx, event.Height)
+		}
+	}
+	sub.Close()
+	if err = sub.Err(); err != nil {
+		t.Errorf("expected subscription to be closed without error, not: %v", err)
+	}
+
+	// resuming from far back works the same
+	sub, err = stream.Subscribe(modules.ConsensusChangeID(events[999].ID), filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	resumed := receiveConsensusChanges(t, sub, n+10-1000)
+	if resumed[0].ID != events[1000].ID {
+		t.Errorf("expected to resume from consensus change %s, not %s", events[1000].ID.String(), resumed[0].ID.String())
+	}
+}
+
+func TestSubscribeOverflow(t *testing.T) {
+	cs := newTestConsensusSet(1)
+	stream := newTestStream(t, cs)
+	defer stream.Close()
+	sub, err := stream.Subscribe(modules.ConsensusChangeRecent, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	// a live subscription which isn't consumed is closed once its queue is full,
+	// taking into account the update which is being processed
+	cs.applyBlocks(subscriptionQueueSize + 2)
+	timeout := time.After(10 * time.Second)
+	for closed := false; !closed; {
+		select {
+		case _, ok := <-sub.Events():
+			closed = !ok
+		case <-timeout:
+			t.Fatal("subscription wasn't closed")
+		}
+	}
+	if err = sub.Err(); err != ErrSubscriptionOverflow {
+		t.Errorf("expected %v, not %v", ErrSubscriptionOverflow, err)
+	}
+}
+
+func newTestStream(t *testing.T, cs modules.ConsensusSet) *Stream {
+	stream, err := New(cs, new(testTransactionPool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stream
+}
+
+// receiveConsensusChanges receives the given amount of consensus change events,
+// failing if any other event is received or the subscription is closed
+func receiveConsensusChanges(t *testing.T, sub *Subscription, n int) []ConsensusChangeEvent {
+	t.Helper()
+	events := make([]ConsensusChangeEvent, 0, n)
+	for len(events) < n {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				t.Fatalf("subscription closed after %d consensus changes: %v", len(events), sub.Err())
+			}
+			if event.ConsensusChange == nil {
+				t.Fatalf("unexpected %s event", event.Type)
+			}
+			events = append(events, *event.ConsensusChange)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("received only %d consensus changes", len(events))
+		}
+	}
+	return events
+}
+
+// testConsensusSet notifies its subscribers of a consensus change per block,
+// replaying all past consensus changes to new subscribers while locked, as the consensus set does
+type testConsensusSet struct {
+	modules.ConsensusSet
+	mu          sync.Mutex
+	changes     []modules.ConsensusChange
+	subscribers []modules.ConsensusSetSubscriber
+
+	// the heights can be looked up while locked, as the consensus set only uses a database transaction for it
+	heightsMu sync.Mutex
+	heights   map[types.BlockID]types.BlockHeight
+}
+
+func newTestConsensusSet(n int) *testConsensusSet {
+	cs := &testConsensusSet{heights: make(map[types.BlockID]types.BlockHeight)}
+	cs.applyBlocks(n)
+	return cs
+}
+
+func (cs *testConsensusSet) applyBlocks(n int) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for i := 0; i < n; i++ {
+		height := types.BlockHeight(len(cs.changes))
+		block := types.Block{Timestamp: types.Timestamp(height)}
+		if height > 0 {
+			block.ParentID = cs.changes[height-1].AppliedBlocks[0].ID()
+		}
+		cs.heightsMu.Lock()
+		cs.heights[block.ID()] = height
+		cs.heightsMu.Unlock()
+		cc := modules.ConsensusChange{
+			ID:            modules.ConsensusChangeID(crypto.HashObject(height)),
+			AppliedBlocks: []types.Block{block},
+			Synced:        true,
+		}
+		cs.changes = append(cs.changes, cc)
+		for _, subscriber := range cs.subscribers {
+			subscriber.ProcessConsensusChange(cc)
+		}
+	}
+}
+
+func (cs *testConsensusSet) ConsensusSetSubscribe(subscriber modules.ConsensusSetSubscriber, start modules.ConsensusChangeID, cancel <-chan struct{}) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	var next int
+	switch start {
+	case modules.ConsensusChangeBeginning:
+	case modules.ConsensusChangeRecent:
+		next = len(cs.changes)
+	default:
+		next = -1
+		for idx, cc := range cs.changes {
+			if cc.ID == start {
+				next = idx + 1
+			}
+		}
+		if next < 0 {
+			return modules.ErrInvalidConsensusChangeID
+		}
+	}
+	for _, cc := range cs.changes[next:] {
+		select {
+		case <-cancel:
+			return errors.New("subscription cancelled")
+		default:
+			subscriber.ProcessConsensusChange(cc)
+		}
+	}
+	cs.subscribers = append(cs.subscribers, subscriber)
+	return nil
+}
+
+func (cs *testConsensusSet) Unsubscribe(subscriber modules.ConsensusSetSubscriber) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for idx := range cs.subscribers {
+		if cs.subscribers[idx] == subscriber {
+			cs.subscribers = append(cs.subscribers[:idx], cs.subscribers[idx+1:]...)
+			return
+		}
+	}
+}
+
+func (cs *testConsensusSet) BlockHeightOfBlock(block types.Block) (types.BlockHeight, bool) {
+	cs.heightsMu.Lock()
+	defer cs.heightsMu.Unlock()
+	height, ok := cs.heights[block.ID()]
+	return height, ok
+}
+
+// testTransactionPool never has any unconfirmed transactions
+type testTransactionPool struct {
+	modules.TransactionPool
+}
+
+func (tpool *testTransactionPool) TransactionPoolSubscribe(modules.TransactionPoolSubscriber) {}
+
+func (tpool *testTransactionPool) Unsubscribe(modules.TransactionPoolSubscriber) {}
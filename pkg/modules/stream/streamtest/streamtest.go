@@ -0,0 +1,104 @@
+// Package streamtest provides a stream of past consensus changes,
+// such that the consumers of the stream package can test resuming their subscriptions
+// without running a consensus set.
+package streamtest
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/threefoldfoundation/tfchain/pkg/modules/stream"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+// PastChanges is the amount of past consensus changes of the stream created by NewStream,
+// which is a lot more than a subscription can queue.
+const PastChanges = 3000
+
+// NewStream creates a stream of PastChanges past consensus changes, a single block each,
+// and a transaction pool without unconfirmed transactions.
+func NewStream() (*stream.Stream, error) {
+	return stream.New(NewConsensusSet(PastChanges), new(TransactionPool))
+}
+
+// ConsensusSet replays its past consensus changes, a single block each, to new subscribers,
+// the block at height n having n as its timestamp.
+// No new consensus changes are applied once subscribed.
+type ConsensusSet struct {
+	modules.ConsensusSet
+	changes []modules.ConsensusChange
+	heights map[types.BlockID]types.BlockHeight
+
+	// locked while replaying, as the consensus set does
+	mu sync.Mutex
+}
+
+// NewConsensusSet creates a consensus set with the given amount of past consensus changes.
+func NewConsensusSet(n int) *ConsensusSet {
+	cs := &ConsensusSet{heights: make(map[types.BlockID]types.BlockHeight, n)}
+	for height := types.BlockHeight(0); height < types.BlockHeight(n); height++ {
+		block := types.Block{Timestamp: types.Timestamp(height)}
+		cs.heights[block.ID()] = height
+		cs.changes = append(cs.changes, modules.ConsensusChange{
+			ID:            modules.ConsensusChangeID(crypto.HashObject(height)),
+			AppliedBlocks: []types.Block{block},
+			Synced:        true,
+		})
+	}
+	return cs
+}
+
+// ConsensusSetSubscribe implements modules.ConsensusSet.ConsensusSetSubscribe,
+// replaying all consensus changes since the given one.
+func (cs *ConsensusSet) ConsensusSetSubscribe(subscriber modules.ConsensusSetSubscriber, start modules.ConsensusChangeID, cancel <-chan struct{}) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	next := len(cs.changes)
+	switch start {
+	case modules.ConsensusChangeBeginning:
+		next = 0
+	case modules.ConsensusChangeRecent:
+	default:
+		next = -1
+		for idx, cc := range cs.changes {
+			if cc.ID == start {
+				next = idx + 1
+			}
+		}
+		if next < 0 {
+			return modules.ErrInvalidConsensusChangeID
+		}
+	}
+	for _, cc := range cs.changes[next:] {
+		select {
+		case <-cancel:
+			return errors.New("subscription cancelled")
+		default:
+			subscriber.ProcessConsensusChange(cc)
+		}
+	}
+	return nil
+}
+
+// Unsubscribe implements modules.ConsensusSet.Unsubscribe
+func (cs *ConsensusSet) Unsubscribe(modules.ConsensusSetSubscriber) {}
+
+// BlockHeightOfBlock implements modules.ConsensusSet.BlockHeightOfBlock
+func (cs *ConsensusSet) BlockHeightOfBlock(block types.Block) (types.BlockHeight, bool) {
+	height, ok := cs.heights[block.ID()]
+	return height, ok
+}
+
+// TransactionPool never has any unconfirmed transactions.
+type TransactionPool struct {
+	modules.TransactionPool
+}
+
+// TransactionPoolSubscribe implements modules.TransactionPool.TransactionPoolSubscribe
+func (tpool *TransactionPool) TransactionPoolSubscribe(modules.TransactionPoolSubscriber) {}
+
+// Unsubscribe implements modules.TransactionPool.Unsubscribe
+func (tpool *TransactionPool) Unsubscribe(modules.TransactionPoolSubscriber) {}
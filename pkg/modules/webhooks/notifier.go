@@ -0,0 +1,1008 @@
+// Package webhooks implements a notifier, which notifies registered webhooks
+// of the unconfirmed, confirmed and reverted transactions relevant to them,
+// by POSTing signed JSON payloads, retrying failed deliveries with backoff.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	tftypes "github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/NebulousLabs/fastrand"
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	rivinepersist "github.com/rivine/rivine/persist"
+	rivinesync "github.com/rivine/rivine/sync"
+	"github.com/rivine/rivine/types"
+
+	bolt "github.com/rivine/bbolt"
+)
+
+// Notifier I/O constants
+const (
+	NotifierDir      = "webhooks"
+	NotifierFilename = NotifierDir + ".db"
+)
+
+const (
+	// DefaultConfirmations is the amount of blocks a transaction has to be confirmed by,
+	// prior to notifying a webhook of it, used in case no amount is given when registering the webhook.
+	DefaultConfirmations types.BlockHeight = 1
+	// MaxConfirmations is the maximum amount of confirmations a webhook can wait for.
+	MaxConfirmations types.BlockHeight = 1000
+	// MaxDeliveryAttempts is the amount of times the delivery of a notification is attempted,
+	// prior to marking it as failed.
+	MaxDeliveryAttempts = 12
+)
+
+// HTTP headers of each notification POSTed to a webhook.
+const (
+	// SignatureHeader contains the hex-encoded HMAC-SHA256 signature of the body,
+	// using the secret of the webhook as key, prefixed with "sha256=".
+	SignatureHeader = "X-Tfchain-Signature"
+	// WebhookHeader contains the ID of the webhook.
+	WebhookHeader = "X-Tfchain-Webhook"
+	// NotificationHeader contains the ID of the notification,
+	// which is the same for each delivery attempt of that notification.
+	NotificationHeader = "X-Tfchain-Notification"
+	// EventHeader contains the event type of the notification.
+	EventHeader = "X-Tfchain-Event"
+)
+
+// the delivery timings, variables such that they can be reduced for testing purposes
+var (
+	// deliveryCheckInterval defines how often the notifier checks for deliveries to (re)try
+	deliveryCheckInterval = time.Second
+	// deliveryTimeout is the maximum duration of a single delivery attempt
+	deliveryTimeout = 10 * time.Second
+	// retryBaseDelay is the delay prior to the first retry of a failed delivery,
+	// doubling for each next retry, up to retryMaxDelay
+	retryBaseDelay = 10 * time.Second
+	retryMaxDelay  = time.Hour
+)
+
+// internal bucket database keys used for the notifier
+var (
+	bucketInternal         = []byte("internal")
+	bucketInternalKeyStats = []byte("stats")
+	// bucketWebhooks stores all webhooks, JSON-encoded and indexed by their ID
+	bucketWebhooks = []byte("webhooks")
+	// bucketPending stores the confirmed transactions which don't have enough confirmations yet,
+	// indexed by the webhook ID followed by the transaction ID
+	bucketPending = []byte("pending")
+	// bucketDeliveries stores the delivery queue, JSON-encoded and indexed by their sequence
+	bucketDeliveries = []byte("deliveries")
+)
+
+// Errors returned by the notifier.
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+)
+
+// EventType defines the type of event a webhook is notified of.
+type EventType string
+
+// All possible event types.
+const (
+	// EventTypeUnconfirmed is the event of a transaction accepted by the transaction pool.
+	EventTypeUnconfirmed EventType = "unconfirmed"
+	// EventTypeConfirmed is the event of a transaction which is confirmed by
+	// the amount of blocks the webhook waits for, the block containing the transaction included.
+	EventTypeConfirmed EventType = "confirmed"
+	// EventTypeReverted is the event of a transaction whose block was reverted.
+	EventTypeReverted EventType = "reverted"
+)
+
+// IsValid returns true if the event type is known.
+func (t EventType) IsValid() bool {
+	switch t {
+	case EventTypeUnconfirmed, EventTypeConfirmed, EventTypeReverted:
+		return true
+	default:
+		return false
+	}
+}
+
+type (
+	// Notifier notifies registered webhooks of the transactions relevant to them,
+	// using a persistent delivery queue, such that no notification is lost when the daemon restarts.
+	Notifier struct {
+		// The Notifier's ThreadGroup tells tracked functions to shut down and
+		// blocks until they have all exited before returning from Close.
+		tg rivinesync.ThreadGroup
+
+		db    *rivinepersist.BoltDatabase
+		stats notifierStats
+
+		cs              modules.ConsensusSet
+		tpool           modules.TransactionPool
+		csSubscriber    *notifierCSSubscriber
+		tpoolSubscriber *notifierTPoolSubscriber
+
+		// webhooks caches all registered webhooks, as they are matched against every transaction
+		webhooks map[crypto.Hash]Webhook
+		// unconfirmed contains the IDs of all transactions in the transaction pool,
+		// such that only new unconfirmed transactions are notified
+		unconfirmed map[types.TransactionID]struct{}
+		// wake is used to trigger deliveries as soon as notifications are queued
+		wake chan struct{}
+
+		client *http.Client
+
+		// mu protects the cached state and ensures the database is updated one change at a time
+		mu sync.Mutex
+	}
+
+	// implements modules.ConsensusSetSubscriber
+	notifierCSSubscriber struct {
+		n *Notifier
+	}
+	// implements modules.TransactionPoolSubscriber
+	notifierTPoolSubscriber struct {
+		n *Notifier
+	}
+	notifierStats struct {
+		ConsensusChangeID modules.ConsensusChangeID
+		// BlockCount defines the amount of applied blocks,
+		// the genesis block included, such that the
+		// height of the last applied block equals BlockCount-1
+		BlockCount uint64
+	}
+
+	// Webhook is a URL notified of the transactions matching its filter.
+	// A transaction matches if it sends to or spends from one of its unlock hashes
+	// (or a multisig wallet one of its unlock hashes is an owner of),
+	// and if it is of one of its transaction versions.
+	// An empty list of unlock hashes or versions matches all transactions.
+	Webhook struct {
+		ID  crypto.Hash `json:"id"`
+		URL string      `json:"url"`
+		// Secret is used to sign the notifications,
+		// and is only returned when registering the webhook
+		Secret       string                     `json:"secret,omitempty"`
+		UnlockHashes []types.UnlockHash         `json:"unlockhashes,omitempty"`
+		Versions     []types.TransactionVersion `json:"versions,omitempty"`
+		Events       []EventType                `json:"events"`
+		// Confirmations is the amount of blocks a transaction has to be confirmed by,
+		// prior to the webhook being notified of it
+		Confirmations types.BlockHeight `json:"confirmations"`
+		Created       types.Timestamp   `json:"created"`
+	}
+
+	// Notification is the JSON payload POSTed to a webhook.
+	Notification struct {
+		// ID is unique for each webhook, event, transaction and block,
+		// such that receivers can detect duplicate notifications
+		ID            crypto.Hash         `json:"id"`
+		WebhookID     crypto.Hash         `json:"webhookid"`
+		Event         EventType           `json:"event"`
+		TransactionID types.TransactionID `json:"transactionid"`
+		Transaction   types.Transaction   `json:"transaction"`
+		// BlockID, BlockHeight and Confirmations are not defined for unconfirmed transactions
+		BlockID       *types.BlockID    `json:"blockid,omitempty"`
+		BlockHeight   types.BlockHeight `json:"blockheight,omitempty"`
+		Confirmations types.BlockHeight `json:"confirmations,omitempty"`
+		Timestamp     types.Timestamp   `json:"timestamp"`
+	}
+
+	// Delivery is a notification queued for delivery,
+	// removed from the queue as soon as it is delivered.
+	Delivery struct {
+		Sequence       uint64      `json:"sequence"`
+		NotificationID crypto.Hash `json:"notificationid"`
+		WebhookID      crypto.Hash `json:"webhookid"`
+		Event          EventType   `json:"event"`
+		// Payload is the JSON-encoded notification, as signed and POSTed
+		Payload     json.RawMessage `json:"payload"`
+		Attempts    int             `json:"attempts"`
+		NextAttempt time.Time       `json:"nextattempt"`
+		LastError   string          `json:"lasterror,omitempty"`
+		// Failed is true if all delivery attempts failed, in which case it is no longer retried
+		Failed bool `json:"failed"`
+	}
+
+	// pendingConfirmation is a confirmed transaction relevant to a webhook,
+	// which isn't confirmed by enough blocks yet
+	pendingConfirmation struct {
+		WebhookID   crypto.Hash       `json:"webhookid"`
+		Transaction types.Transaction `json:"transaction"`
+		BlockID     types.BlockID     `json:"blockid"`
+		BlockHeight types.BlockHeight `json:"blockheight"`
+	}
+)
+
+// New creates a new notifier, subscribing to the given consensus set and transaction pool,
+// resuming from the last consensus change it processed.
+// The given root directory is used to store its (single) persistent BoltDB file.
+func New(rootDir string, cs modules.ConsensusSet, tpool modules.TransactionPool) (*Notifier, error) {
+	if cs == nil {
+		return nil, errors.New("notifier requires a consensus set")
+	}
+	if tpool == nil {
+		return nil, errors.New("notifier requires a transaction pool")
+	}
+
+	persistDir := path.Join(rootDir, NotifierDir)
+	// Create the directory if it doesn't exist.
+	err := os.MkdirAll(persistDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Notifier{
+		cs:          cs,
+		tpool:       tpool,
+		webhooks:    make(map[crypto.Hash]Webhook),
+		unconfirmed: make(map[types.TransactionID]struct{}),
+		wake:        make(chan struct{}, 1),
+		client:      &http.Client{Timeout: deliveryTimeout},
+	}
+	err = n.openDB(path.Join(persistDir, NotifierFilename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the notifier DB: %v", err)
+	}
+
+	n.csSubscriber = &notifierCSSubscriber{n: n}
+	err = cs.ConsensusSetSubscribe(n.csSubscriber, n.stats.ConsensusChangeID, n.tg.StopChan())
+	if err != nil {
+		n.csSubscriber = nil
+		n.Close()
+		return nil, fmt.Errorf("failed to subscribe to consensus set: %v", err)
+	}
+	n.tpoolSubscriber = &notifierTPoolSubscriber{n: n}
+	tpool.TransactionPoolSubscribe(n.tpoolSubscriber)
+
+	go n.threadedDeliver()
+	return n, nil
+}
+
+// Register a new webhook, returning it with its ID and secret.
+// At least one unlock hash or transaction version is required,
+// while all events are notified if none are given.
+func (n *Notifier) Register(webhook Webhook) (Webhook, error) {
+	if err := n.tg.Add(); err != nil {
+		return Webhook{}, err
+	}
+	defer n.tg.Done()
+
+	u, err := url.Parse(webhook.URL)
+	if err != nil {
+		return Webhook{}, fmt.Errorf("invalid webhook URL: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Webhook{}, errors.New("invalid webhook URL: an absolute http or https URL is required")
+	}
+	if len(webhook.UnlockHashes) == 0 && len(webhook.Versions) == 0 {
+		return Webhook{}, errors.New("at least one unlock hash or transaction version is required")
+	}
+	if len(webhook.Events) == 0 {
+		webhook.Events = []EventType{EventTypeUnconfirmed, EventTypeConfirmed, EventTypeReverted}
+	}
+	for _, event := range webhook.Events {
+		if !event.IsValid() {
+			return Webhook{}, fmt.Errorf("invalid event type %q", event)
+		}
+	}
+	if webhook.Confirmations == 0 {
+		webhook.Confirmations = DefaultConfirmations
+	}
+	if webhook.Confirmations > MaxConfirmations {
+		return Webhook{}, fmt.Errorf("confirmations cannot exceed %d", MaxConfirmations)
+	}
+	fastrand.Read(webhook.ID[:])
+	webhook.Secret = hex.EncodeToString(fastrand.Bytes(32))
+	webhook.Created = types.CurrentTimestamp()
+
+	b, err := json.Marshal(webhook)
+	if err != nil {
+		return Webhook{}, fmt.Errorf("failed to encode webhook: %v", err)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	err = n.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketWebhooks).Put(webhook.ID[:], b)
+	})
+	if err != nil {
+		return Webhook{}, fmt.Errorf("failed to store webhook: %v", err)
+	}
+	n.webhooks[webhook.ID] = webhook
+	return webhook, nil
+}
+
+// Remove the webhook with the given ID, as well as its pending notifications,
+// returning ErrWebhookNotFound if it doesn't exist.
+func (n *Notifier) Remove(id crypto.Hash) error {
+	if err := n.tg.Add(); err != nil {
+		return err
+	}
+	defer n.tg.Done()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.webhooks[id]; !ok {
+		return ErrWebhookNotFound
+	}
+	err := n.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(bucketWebhooks).Delete(id[:])
+		if err != nil {
+			return err
+		}
+		pending := tx.Bucket(bucketPending)
+		for _, key := range prefixedKeys(pending, id[:]) {
+			if err = pending.Delete(key); err != nil {
+				return err
+			}
+		}
+		deliveries, err := webhookDeliveries(tx, id)
+		if err != nil {
+			return err
+		}
+		for _, delivery := range deliveries {
+			if err = tx.Bucket(bucketDeliveries).Delete(encodeSequence(delivery.Sequence)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove webhook %s: %v", id.String(), err)
+	}
+	delete(n.webhooks, id)
+	return nil
+}
+
+// Webhook returns the webhook for the given ID, without its secret,
+// returning ErrWebhookNotFound if it doesn't exist.
+func (n *Notifier) Webhook(id crypto.Hash) (Webhook, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	webhook, ok := n.webhooks[id]
+	if !ok {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+// Webhooks returns all registered webhooks, without their secrets,
+// ordered from oldest to newest.
+func (n *Notifier) Webhooks() []Webhook {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	webhooks := make([]Webhook, 0, len(n.webhooks))
+	for _, webhook := range n.webhooks {
+		webhook.Secret = ""
+		webhooks = append(webhooks, webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		if webhooks[i].Created != webhooks[j].Created {
+			return webhooks[i].Created < webhooks[j].Created
+		}
+		return bytes.Compare(webhooks[i].ID[:], webhooks[j].ID[:]) < 0
+	})
+	return webhooks
+}
+
+// Deliveries returns the queued (and failed) deliveries of the webhook with the given ID,
+// ordered as queued, returning ErrWebhookNotFound if the webhook doesn't exist.
+func (n *Notifier) Deliveries(id crypto.Hash) ([]Delivery, error) {
+	if err := n.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer n.tg.Done()
+
+	n.mu.Lock()
+	_, ok := n.webhooks[id]
+	n.mu.Unlock()
+	if !ok {
+		return nil, ErrWebhookNotFound
+	}
+	var deliveries []Delivery
+	err := n.db.View(func(tx *bolt.Tx) (err error) {
+		deliveries, err = webhookDeliveries(tx, id)
+		return err
+	})
+	return deliveries, err
+}
+
+// Close the notifier,
+// meaning it will be unsubscribed from the consensus set and transaction pool,
+// as well the threadgroup will be stopped and the internal bolt db will be closed.
+func (n *Notifier) Close() error {
+	if n.db == nil {
+		return errors.New("notifier is already closed or was never created")
+	}
+
+	if n.tpoolSubscriber != nil {
+		n.tpool.Unsubscribe(n.tpoolSubscriber)
+		n.tpoolSubscriber = nil
+	}
+	if n.csSubscriber != nil {
+		n.cs.Unsubscribe(n.csSubscriber)
+		n.csSubscriber = nil
+	}
+	// stop thread group
+	tgErr := n.tg.Stop()
+	if tgErr != nil {
+		tgErr = fmt.Errorf("failed to stop the threadgroup of the notifier: %v", tgErr)
+	}
+	// close database
+	dbErr := n.db.Close()
+	if dbErr != nil {
+		dbErr = fmt.Errorf("failed to close the internal bolt db of the notifier: %v", dbErr)
+	}
+	n.db = nil
+
+	return build.ComposeErrors(tgErr, dbErr)
+}
+
+// Sign returns the signature of the given payload, as sent in the SignatureHeader,
+// using the given webhook secret.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature returns true if the given signature,
+// as received in the SignatureHeader, is valid for the given payload and webhook secret.
+func VerifySignature(secret string, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, payload)), []byte(signature))
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber,
+// calling n.processConsensusChange, so that the Notifier
+// does not expose its interface implementation outside this package.
+func (sub *notifierCSSubscriber) ProcessConsensusChange(cc modules.ConsensusChange) {
+	sub.n.processConsensusChange(cc)
+}
+
+// ReceiveUpdatedUnconfirmedTransactions implements modules.TransactionPoolSubscriber,
+// calling n.processUnconfirmedTransactions, so that the Notifier
+// does not expose its interface implementation outside this package.
+func (sub *notifierTPoolSubscriber) ReceiveUpdatedUnconfirmedTransactions(txns []types.Transaction, cc modules.ConsensusChange) {
+	sub.n.processUnconfirmedTransactions(txns, cc)
+}
+
+// processConsensusChange queues the reverted notifications of the transactions of all reverted blocks,
+// and the confirmed notifications of the transactions which are now confirmed by enough blocks
+func (n *Notifier) processConsensusChange(cc modules.ConsensusChange) {
+	if err := n.tg.Add(); err != nil {
+		// The Notifier should gracefully reject updates from the consensus set
+		// that are sent after the Notifier's Close method has closed its ThreadGroup.
+		return
+	}
+	defer n.tg.Done()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	coinOutputs, blockStakeOutputs := consensusChangeOutputs(cc)
+	var queued bool
+	err := n.db.Update(func(tx *bolt.Tx) error {
+		for _, block := range cc.RevertedBlocks {
+			n.stats.BlockCount--
+			blockID := block.ID()
+			height := types.BlockHeight(n.stats.BlockCount)
+			for _, txn := range block.Transactions {
+				for _, webhook := range n.matchingWebhooks(txn, coinOutputs, blockStakeOutputs) {
+					err := tx.Bucket(bucketPending).Delete(pendingKey(webhook.ID, txn.ID()))
+					if err != nil {
+						return err
+					}
+					if !webhook.notifies(EventTypeReverted) {
+						continue
+					}
+					err = n.queueNotification(tx, webhook, Notification{
+						Event:       EventTypeReverted,
+						Transaction: txn,
+						BlockID:     &blockID,
+						BlockHeight: height,
+					})
+					if err != nil {
+						return err
+					}
+					queued = true
+				}
+			}
+		}
+		for _, block := range cc.AppliedBlocks {
+			blockID := block.ID()
+			height := types.BlockHeight(n.stats.BlockCount)
+			n.stats.BlockCount++
+			for _, txn := range block.Transactions {
+				for _, webhook := range n.matchingWebhooks(txn, coinOutputs, blockStakeOutputs) {
+					if !webhook.notifies(EventTypeConfirmed) {
+						continue
+					}
+					b, err := json.Marshal(pendingConfirmation{
+						WebhookID:   webhook.ID,
+						Transaction: txn,
+						BlockID:     blockID,
+						BlockHeight: height,
+					})
+					if err != nil {
+						return err
+					}
+					err = tx.Bucket(bucketPending).Put(pendingKey(webhook.ID, txn.ID()), b)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if len(cc.AppliedBlocks) > 0 {
+			confirmed, err := n.queueConfirmed(tx)
+			if err != nil {
+				return err
+			}
+			queued = queued || confirmed
+		}
+		n.stats.ConsensusChangeID = cc.ID
+		return tx.Bucket(bucketInternal).Put(bucketInternalKeyStats, encoding.Marshal(n.stats))
+	})
+	if err != nil {
+		build.Critical("notifier failed to process consensus change:", err)
+	}
+	if queued {
+		n.triggerDelivery()
+	}
+}
+
+// queueConfirmed queues the confirmed notifications of all pending transactions
+// which are confirmed by enough blocks, returning true if any notification was queued
+func (n *Notifier) queueConfirmed(tx *bolt.Tx) (bool, error) {
+	var confirmed [][]byte
+	height := types.BlockHeight(n.stats.BlockCount - 1)
+	pending := tx.Bucket(bucketPending)
+	err := pending.ForEach(func(k, v []byte) error {
+		var pc pendingConfirmation
+		err := json.Unmarshal(v, &pc)
+		if err != nil {
+			return fmt.Errorf("failed to decode pending confirmation %x: %v", k, err)
+		}
+		webhook, ok := n.webhooks[pc.WebhookID]
+		if !ok {
+			// webhook was removed
+			confirmed = append(confirmed, k)
+			return nil
+		}
+		confirmations := height - pc.BlockHeight + 1
+		if confirmations < webhook.Confirmations {
+			return nil
+		}
+		confirmed = append(confirmed, k)
+		return n.queueNotification(tx, webhook, Notification{
+			Event:         EventTypeConfirmed,
+			Transaction:   pc.Transaction,
+			BlockID:       &pc.BlockID,
+			BlockHeight:   pc.BlockHeight,
+			Confirmations: confirmations,
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, k := range confirmed {
+		if err = pending.Delete(k); err != nil {
+			return false, err
+		}
+	}
+	return len(confirmed) > 0, nil
+}
+
+// processUnconfirmedTransactions queues the unconfirmed notifications
+// of all transactions which weren't yet in the transaction pool
+func (n *Notifier) processUnconfirmedTransactions(txns []types.Transaction, cc modules.ConsensusChange) {
+	if err := n.tg.Add(); err != nil {
+		return
+	}
+	defer n.tg.Done()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	unconfirmed := make(map[types.TransactionID]struct{}, len(txns))
+	var added []types.Transaction
+	for _, txn := range txns {
+		id := txn.ID()
+		unconfirmed[id] = struct{}{}
+		if _, ok := n.unconfirmed[id]; !ok {
+			added = append(added, txn)
+		}
+	}
+	n.unconfirmed = unconfirmed
+	if len(added) == 0 || len(n.webhooks) == 0 {
+		return
+	}
+
+	coinOutputs, blockStakeOutputs := consensusChangeOutputs(cc)
+	var queued bool
+	err := n.db.Update(func(tx *bolt.Tx) error {
+		for _, txn := range added {
+			for _, webhook := range n.matchingWebhooks(txn, coinOutputs, blockStakeOutputs) {
+				if !webhook.notifies(EventTypeUnconfirmed) {
+					continue
+				}
+				err := n.queueNotification(tx, webhook, Notification{
+					Event:       EventTypeUnconfirmed,
+					Transaction: txn,
+				})
+				if err != nil {
+					return err
+				}
+				queued = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		build.Critical("notifier failed to process unconfirmed transactions:", err)
+	}
+	if queued {
+		n.triggerDelivery()
+	}
+}
+
+// matchingWebhooks returns all webhooks the given transaction is relevant to
+func (n *Notifier) matchingWebhooks(
+	txn types.Transaction,
+	coinOutputs map[types.CoinOutputID]types.CoinOutput,
+	blockStakeOutputs map[types.BlockStakeOutputID]types.BlockStakeOutput) []Webhook {
+	if len(n.webhooks) == 0 {
+		return nil
+	}
+	var (
+		uhs      map[types.UnlockHash]struct{}
+		webhooks []Webhook
+	)
+	for _, webhook := range n.webhooks {
+		if len(webhook.UnlockHashes) > 0 && uhs == nil {
+			// only collect the unlock hashes once, and only when needed
+			uhs = tftypes.TransactionUnlockHashes(txn, coinOutputs, blockStakeOutputs)
+		}
+		if webhook.match(txn, uhs) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks
+}
+
+// queueNotification completes the given notification for the given webhook,
+// and queues it for immediate delivery
+func (n *Notifier) queueNotification(tx *bolt.Tx, webhook Webhook, notification Notification) error {
+	var blockID types.BlockID
+	if notification.BlockID != nil {
+		blockID = *notification.BlockID
+	}
+	notification.WebhookID = webhook.ID
+	notification.TransactionID = notification.Transaction.ID()
+	notification.ID = crypto.HashAll(webhook.ID, notification.Event, notification.TransactionID, blockID)
+	notification.Timestamp = types.CurrentTimestamp()
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %v", err)
+	}
+
+	bucket := tx.Bucket(bucketDeliveries)
+	sequence, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(Delivery{
+		Sequence:       sequence,
+		NotificationID: notification.ID,
+		WebhookID:      webhook.ID,
+		Event:          notification.Event,
+		Payload:        payload,
+		NextAttempt:    time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode delivery: %v", err)
+	}
+	return bucket.Put(encodeSequence(sequence), b)
+}
+
+// triggerDelivery wakes up the delivery thread, if it isn't already triggered
+func (n *Notifier) triggerDelivery() {
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// threadedDeliver delivers the queued notifications, as soon as they are due
+func (n *Notifier) threadedDeliver() {
+	if err := n.tg.Add(); err != nil {
+		return
+	}
+	defer n.tg.Done()
+
+	ticker := time.NewTicker(deliveryCheckInterval)
+	defer ticker.Stop()
+	for {
+		n.deliverDue()
+		select {
+		case <-n.tg.StopChan():
+			return
+		case <-ticker.C:
+		case <-n.wake:
+		}
+	}
+}
+
+// deliverDue attempts to deliver all queued notifications which are due,
+// removing the delivered ones from the queue, and scheduling a retry for the others
+func (n *Notifier) deliverDue() {
+	now := time.Now()
+	var due []Delivery
+	err := n.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketDeliveries).ForEach(func(k, v []byte) error {
+			var delivery Delivery
+			err := json.Unmarshal(v, &delivery)
+			if err != nil {
+				return fmt.Errorf("failed to decode delivery %x: %v", k, err)
+			}
+			if !delivery.Failed && !delivery.NextAttempt.After(now) {
+				due = append(due, delivery)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		build.Critical("notifier failed to load the delivery queue:", err)
+		return
+	}
+
+	for _, delivery := range due {
+		select {
+		case <-n.tg.StopChan():
+			return
+		default:
+		}
+		n.mu.Lock()
+		webhook, ok := n.webhooks[delivery.WebhookID]
+		n.mu.Unlock()
+		if !ok {
+			// webhook was removed, together with its deliveries
+			continue
+		}
+		deliveryErr := n.deliver(webhook, delivery)
+
+		n.mu.Lock()
+		err = n.db.Update(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket(bucketDeliveries)
+			key := encodeSequence(delivery.Sequence)
+			if bucket.Get(key) == nil {
+				return nil // removed while delivering
+			}
+			if deliveryErr == nil {
+				return bucket.Delete(key)
+			}
+			delivery.Attempts++
+			delivery.LastError = deliveryErr.Error()
+			if delivery.Attempts >= MaxDeliveryAttempts {
+				delivery.Failed = true
+			} else {
+				delivery.NextAttempt = time.Now().Add(retryDelay(delivery.Attempts))
+			}
+			b, err := json.Marshal(delivery)
+			if err != nil {
+				return fmt.Errorf("failed to encode delivery: %v", err)
+			}
+			return bucket.Put(key, b)
+		})
+		n.mu.Unlock()
+		if err != nil {
+			build.Critical("notifier failed to update the delivery queue:", err)
+			return
+		}
+	}
+}
+
+// deliver POSTs the payload of the given delivery to the given webhook,
+// returning an error if the webhook didn't respond with a 2xx status code
+func (n *Notifier) deliver(webhook Webhook, delivery Delivery) error {
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tfchaind-webhooks")
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, delivery.Payload))
+	req.Header.Set(WebhookHeader, webhook.ID.String())
+	req.Header.Set(NotificationHeader, delivery.NotificationID.String())
+	req.Header.Set(EventHeader, string(delivery.Event))
+
+	// cancel the request when the notifier is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-n.tg.StopChan():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	resp, err := n.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// openDB loads the set database and populates it with the necessary buckets
+func (n *Notifier) openDB(filename string) (err error) {
+	var (
+		dbMetadata = rivinepersist.Metadata{
+			Header:  "TFChain Webhook Notifier Database",
+			Version: "1.0.0",
+		}
+	)
+
+	n.db, err = rivinepersist.OpenDatabase(dbMetadata, filename)
+	if err != nil {
+		return fmt.Errorf("error opening tfchain notifier database: %v", err)
+	}
+	return n.db.Update(func(tx *bolt.Tx) (err error) {
+		internalBucket := tx.Bucket(bucketInternal)
+		if internalBucket != nil {
+			// db is already created, get the stored stats
+			b := internalBucket.Get(bucketInternalKeyStats)
+			if len(b) == 0 {
+				return errors.New("structured stats value could not be found in existing notifier db")
+			}
+			err = encoding.Unmarshal(b, &n.stats)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal structured stats value from existing notifier db: %v", err)
+			}
+			// load all registered webhooks
+			return tx.Bucket(bucketWebhooks).ForEach(func(k, v []byte) error {
+				var webhook Webhook
+				err := json.Unmarshal(v, &webhook)
+				if err != nil {
+					return fmt.Errorf("corrupt notifier DB: failed to decode webhook %x: %v", k, err)
+				}
+				n.webhooks[webhook.ID] = webhook
+				return nil
+			})
+		}
+
+		// create the DB
+		buckets := [][]byte{
+			bucketInternal,
+			bucketWebhooks,
+			bucketPending,
+			bucketDeliveries,
+		}
+		for _, bucket := range buckets {
+			_, err = tx.CreateBucket(bucket)
+			if err != nil {
+				return fmt.Errorf("failed to create notifier DB: %v", err)
+			}
+		}
+		n.stats.ConsensusChangeID = modules.ConsensusChangeBeginning
+		return tx.Bucket(bucketInternal).Put(bucketInternalKeyStats, encoding.Marshal(n.stats))
+	})
+}
+
+// notifies returns true if the webhook is notified of the given event type
+func (webhook Webhook) notifies(event EventType) bool {
+	for _, t := range webhook.Events {
+		if t == event {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns true if the given transaction matches the versions and unlock hashes of the webhook,
+// the given unlock hashes being those of the transaction
+func (webhook Webhook) match(txn types.Transaction, uhs map[types.UnlockHash]struct{}) bool {
+	if len(webhook.Versions) > 0 {
+		var match bool
+		for _, version := range webhook.Versions {
+			if version == txn.Version {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	if len(webhook.UnlockHashes) == 0 {
+		return true
+	}
+	for _, uh := range webhook.UnlockHashes {
+		if _, ok := uhs[uh]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookDeliveries returns all queued deliveries of the given webhook, ordered as queued
+func webhookDeliveries(tx *bolt.Tx, id crypto.Hash) ([]Delivery, error) {
+	var deliveries []Delivery
+	err := tx.Bucket(bucketDeliveries).ForEach(func(k, v []byte) error {
+		var delivery Delivery
+		err := json.Unmarshal(v, &delivery)
+		if err != nil {
+			return fmt.Errorf("failed to decode delivery %x: %v", k, err)
+		}
+		if delivery.WebhookID == id {
+			deliveries = append(deliveries, delivery)
+		}
+		return nil
+	})
+	return deliveries, err
+}
+
+// consensusChangeOutputs collects all outputs created or spent by the given consensus change,
+// as to know the conditions of the parent outputs of the inputs of its transactions
+func consensusChangeOutputs(cc modules.ConsensusChange) (map[types.CoinOutputID]types.CoinOutput, map[types.BlockStakeOutputID]types.BlockStakeOutput) {
+	coinOutputs := make(map[types.CoinOutputID]types.CoinOutput, len(cc.CoinOutputDiffs))
+	for _, diff := range cc.CoinOutputDiffs {
+		coinOutputs[diff.ID] = diff.CoinOutput
+	}
+	blockStakeOutputs := make(map[types.BlockStakeOutputID]types.BlockStakeOutput, len(cc.BlockStakeOutputDiffs))
+	for _, diff := range cc.BlockStakeOutputDiffs {
+		blockStakeOutputs[diff.ID] = diff.BlockStakeOutput
+	}
+	return coinOutputs, blockStakeOutputs
+}
+
+// retryDelay returns the delay prior to the next attempt of a delivery,
+// which already failed the given amount of attempts
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay << uint(attempts-1)
+	if delay <= 0 || delay > retryMaxDelay {
+		return retryMaxDelay
+	}
+	return delay
+}
+
+func pendingKey(webhookID crypto.Hash, txnID types.TransactionID) []byte {
+	return append(append(make([]byte, 0, len(webhookID)+len(txnID)), webhookID[:]...), txnID[:]...)
+}
+
+func encodeSequence(sequence uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, sequence)
+	return b
+}
+
+func prefixedKeys(bucket *bolt.Bucket, prefix []byte) [][]byte {
+	var keys [][]byte
+	c := bucket.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	return keys
+}
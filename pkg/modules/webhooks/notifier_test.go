@@ -0,0 +1,340 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+func init() {
+	deliveryCheckInterval = 10 * time.Millisecond
+	retryBaseDelay = 10 * time.Millisecond
+	retryMaxDelay = 50 * time.Millisecond
+}
+
+// testConsensusSet is a consensus set which only supports subscriptions,
+// consensus changes are sent by the test itself
+type testConsensusSet struct {
+	modules.ConsensusSet
+	subscriber modules.ConsensusSetSubscriber
+}
+
+func (cs *testConsensusSet) ConsensusSetSubscribe(subscriber modules.ConsensusSetSubscriber, _ modules.ConsensusChangeID, _ <-chan struct{}) error {
+	cs.subscriber = subscriber
+	return nil
+}
+
+func (cs *testConsensusSet) Unsubscribe(modules.ConsensusSetSubscriber) {
+	cs.subscriber = nil
+}
+
+// testTransactionPool is a transaction pool which only supports subscriptions,
+// unconfirmed transactions are sent by the test itself
+type testTransactionPool struct {
+	modules.TransactionPool
+	subscriber modules.TransactionPoolSubscriber
+}
+
+func (tpool *testTransactionPool) TransactionPoolSubscribe(subscriber modules.TransactionPoolSubscriber) {
+	tpool.subscriber = subscriber
+	subscriber.ReceiveUpdatedUnconfirmedTransactions(nil, modules.ConsensusChange{})
+}
+
+func (tpool *testTransactionPool) Unsubscribe(modules.TransactionPoolSubscriber) {
+	tpool.subscriber = nil
+}
+
+// testReceiver is a local stand-in for a webhook,
+// responding with an error to the configured amount of requests first
+type testReceiver struct {
+	*httptest.Server
+	secret string
+
+	mu       sync.Mutex
+	failures int
+	received chan Notification
+}
+
+func newTestReceiver(t *testing.T) *testReceiver {
+	receiver := &testReceiver{received: make(chan Notification, 16)}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		if receiver.failures > 0 {
+			receiver.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		payload, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			t.Errorf("failed to read notification: %v", err)
+			return
+		}
+		if !VerifySignature(receiver.secret, payload, req.Header.Get(SignatureHeader)) {
+			t.Errorf("invalid signature %q for notification %s", req.Header.Get(SignatureHeader), payload)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var notification Notification
+		err = json.Unmarshal(payload, &notification)
+		if err != nil {
+			t.Errorf("failed to decode notification: %v", err)
+			return
+		}
+		if req.Header.Get(NotificationHeader) != notification.ID.String() {
+			t.Errorf("notification header %q doesn't match notification ID %s", req.Header.Get(NotificationHeader), notification.ID.String())
+		}
+		receiver.received <- notification
+	}))
+	return receiver
+}
+
+func (receiver *testReceiver) setFailures(failures int) {
+	receiver.mu.Lock()
+	receiver.failures = failures
+	receiver.mu.Unlock()
+}
+
+// expect waits for a notification of the given event and transaction
+func (receiver *testReceiver) expect(t *testing.T, event EventType, txn types.Transaction) Notification {
+	t.Helper()
+	select {
+	case notification := <-receiver.received:
+		if notification.Event != event || notification.TransactionID != txn.ID() {
+			t.Fatalf("expected %s notification of transaction %s, but received %s notification of transaction %s",
+				event, txn.ID().String(), notification.Event, notification.TransactionID.String())
+		}
+		return notification
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout while waiting for %s notification of transaction %s", event, txn.ID().String())
+		return Notification{}
+	}
+}
+
+// expectNone ensures no notification is received within a short duration
+func (receiver *testReceiver) expectNone(t *testing.T) {
+	t.Helper()
+	select {
+	case notification := <-receiver.received:
+		t.Fatalf("unexpected %s notification of transaction %s", notification.Event, notification.TransactionID.String())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func newTestNotifier(t *testing.T, dir string) (*Notifier, *testConsensusSet, *testTransactionPool) {
+	cs, tpool := new(testConsensusSet), new(testTransactionPool)
+	n, err := New(dir, cs, tpool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n, cs, tpool
+}
+
+// waitForDeliveries waits until the queued deliveries of the given webhook match the given condition
+func waitForDeliveries(t *testing.T, n *Notifier, id crypto.Hash, condition func([]Delivery) bool) []Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deliveries, err := n.Deliveries(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if condition(deliveries) {
+			return deliveries
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout while waiting for deliveries, queued: %v", deliveries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func testBlock(timestamp types.Timestamp, txns ...types.Transaction) types.Block {
+	return types.Block{Timestamp: timestamp, Transactions: txns}
+}
+
+func TestNotifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	receiver := newTestReceiver(t)
+	defer receiver.Close()
+
+	n, cs, tpool := newTestNotifier(t, dir)
+	cs.subscriber.ProcessConsensusChange(modules.ConsensusChange{
+		ID:            modules.ConsensusChangeID{1},
+		AppliedBlocks: []types.Block{testBlock(1)},
+	})
+
+	uh := types.NewPubKeyUnlockHash(types.Ed25519PublicKey(crypto.PublicKey{1}))
+	webhook, err := n.Register(Webhook{
+		URL:           receiver.URL,
+		UnlockHashes:  []types.UnlockHash{uh},
+		Confirmations: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if webhook.Secret == "" || len(webhook.Events) != 3 {
+		t.Fatalf("unexpected registered webhook: %v", webhook)
+	}
+	receiver.mu.Lock()
+	receiver.secret = webhook.Secret
+	receiver.mu.Unlock()
+
+	txn := types.Transaction{
+		Version:     types.TransactionVersionOne,
+		CoinOutputs: []types.CoinOutput{{Value: types.NewCurrency64(42), Condition: types.NewCondition(types.NewUnlockHashCondition(uh))}},
+	}
+	otherTxn := types.Transaction{
+		Version:     types.TransactionVersionOne,
+		CoinOutputs: []types.CoinOutput{{Value: types.NewCurrency64(42), Condition: types.NewCondition(types.NewUnlockHashCondition(types.UnlockHash{}))}},
+	}
+
+	// unconfirmed transactions are notified once, and only if relevant
+	tpool.subscriber.ReceiveUpdatedUnconfirmedTransactions([]types.Transaction{txn, otherTxn}, modules.ConsensusChange{})
+	receiver.expect(t, EventTypeUnconfirmed, txn)
+	tpool.subscriber.ReceiveUpdatedUnconfirmedTransactions([]types.Transaction{txn, otherTxn}, modules.ConsensusChange{})
+	receiver.expectNone(t)
+
+	// the transaction is confirmed once it is confirmed by 2 blocks
+	block := testBlock(2, txn, otherTxn)
+	cs.subscriber.ProcessConsensusChange(modules.ConsensusChange{
+		ID:            modules.ConsensusChangeID{2},
+		AppliedBlocks: []types.Block{block},
+	})
+	receiver.expectNone(t)
+	cs.subscriber.ProcessConsensusChange(modules.ConsensusChange{
+		ID:            modules.ConsensusChangeID{3},
+		AppliedBlocks: []types.Block{testBlock(3)},
+	})
+	notification := receiver.expect(t, EventTypeConfirmed, txn)
+	if notification.BlockID == nil || *notification.BlockID != block.ID() {
+		t.Errorf("expected confirmed notification for block %s, but got %v", block.ID().String(), notification.BlockID)
+	}
+	if notification.BlockHeight != 1 || notification.Confirmations != 2 {
+		t.Errorf("expected confirmation at height 1 by 2 blocks, but got height %d and %d blocks",
+			notification.BlockHeight, notification.Confirmations)
+	}
+
+	// reverting the block of the transaction is notified as well,
+	// even when the webhook fails to respond a couple of times
+	receiver.setFailures(2)
+	cs.subscriber.ProcessConsensusChange(modules.ConsensusChange{
+		ID:             modules.ConsensusChangeID{4},
+		RevertedBlocks: []types.Block{testBlock(3), block},
+	})
+	reverted := receiver.expect(t, EventTypeReverted, txn)
+	if reverted.ID == notification.ID {
+		t.Error("expected reverted notification to have a different ID than the confirmed notification")
+	}
+	waitForDeliveries(t, n, webhook.ID, func(deliveries []Delivery) bool {
+		return len(deliveries) == 0
+	})
+
+	// queued notifications survive a restart
+	receiver.setFailures(1000)
+	cs.subscriber.ProcessConsensusChange(modules.ConsensusChange{
+		ID:            modules.ConsensusChangeID{5},
+		AppliedBlocks: []types.Block{block, testBlock(4)},
+	})
+	waitForDeliveries(t, n, webhook.ID, func(deliveries []Delivery) bool {
+		return len(deliveries) == 1 && deliveries[0].Attempts > 0
+	})
+	err = n.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver.setFailures(0)
+	n, cs, _ = newTestNotifier(t, dir)
+	defer n.Close()
+	notification = receiver.expect(t, EventTypeConfirmed, txn)
+	if notification.BlockHeight != 1 || notification.Confirmations != 2 {
+		t.Errorf("expected confirmation at height 1 by 2 blocks, but got height %d and %d blocks",
+			notification.BlockHeight, notification.Confirmations)
+	}
+
+	// removed webhooks are no longer notified
+	err = n.Remove(webhook.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = n.Webhook(webhook.ID); err != ErrWebhookNotFound {
+		t.Errorf("expected removed webhook to be not found, but got: %v", err)
+	}
+	cs.subscriber.ProcessConsensusChange(modules.ConsensusChange{
+		ID:             modules.ConsensusChangeID{7},
+		RevertedBlocks: []types.Block{testBlock(4), block},
+	})
+	receiver.expectNone(t)
+}
+
+func TestNotifierDeliveryFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	receiver := newTestReceiver(t)
+	defer receiver.Close()
+	receiver.setFailures(MaxDeliveryAttempts)
+
+	n, _, tpool := newTestNotifier(t, dir)
+	defer n.Close()
+	webhook, err := n.Register(Webhook{
+		URL:      receiver.URL,
+		Versions: []types.TransactionVersion{types.TransactionVersionOne},
+		Events:   []EventType{EventTypeUnconfirmed},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpool.subscriber.ReceiveUpdatedUnconfirmedTransactions([]types.Transaction{{Version: types.TransactionVersionOne}}, modules.ConsensusChange{})
+
+	delivery := waitForDeliveries(t, n, webhook.ID, func(deliveries []Delivery) bool {
+		return len(deliveries) == 1 && deliveries[0].Failed
+	})[0]
+	if delivery.Attempts != MaxDeliveryAttempts || delivery.LastError == "" {
+		t.Errorf("unexpected failed delivery: %v", delivery)
+	}
+	receiver.expectNone(t)
+}
+
+func TestRegisterValidation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	n, _, _ := newTestNotifier(t, dir)
+	defer n.Close()
+
+	versions := []types.TransactionVersion{types.TransactionVersionOne}
+	for idx, webhook := range []Webhook{
+		{URL: "localhost:8080", Versions: versions},
+		{URL: "ftp://localhost", Versions: versions},
+		{URL: "http://localhost"},
+		{URL: "http://localhost", Versions: versions, Events: []EventType{"mined"}},
+		{URL: "http://localhost", Versions: versions, Confirmations: MaxConfirmations + 1},
+	} {
+		if _, err := n.Register(webhook); err == nil {
+			t.Errorf("#%d: expected registration of invalid webhook to fail", idx)
+		}
+	}
+	if len(n.Webhooks()) != 0 {
+		t.Errorf("expected no webhooks to be registered, but got %d", len(n.Webhooks()))
+	}
+}
@@ -0,0 +1,658 @@
+package persist
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
+	rivinesync "github.com/rivine/rivine/sync"
+	rivinetypes "github.com/rivine/rivine/types"
+
+	bolt "github.com/rivine/bbolt"
+)
+
+// AtomicSwapDB I/O constants
+const (
+	AtomicSwapDBDir      = "atomicswapdb"
+	AtomicSwapDBFilename = AtomicSwapDBDir + ".db"
+)
+
+// internal bucket database keys used for the atomicSwapDB
+var (
+	// bucketAtomicSwapContracts stores all atomic swap contracts,
+	// indexed by the ID of the coin output they're the condition of
+	bucketAtomicSwapContracts = []byte("contracts")
+	// bucketAtomicSwapBlockTimestamps stores the timestamp of each block,
+	// indexed by its (encoded) block height, such that the timestamp
+	// of the last applied block is known again once blocks are reverted
+	bucketAtomicSwapBlockTimestamps = []byte("blocktimestamps")
+
+	// index buckets, the keys of these buckets are the concatenation
+	// of the binary-encoded indexed value and the coin output ID of the contract,
+	// while the values are left empty
+	bucketAtomicSwapSenderIndex       = []byte("senders")
+	bucketAtomicSwapReceiverIndex     = []byte("receivers")
+	bucketAtomicSwapHashedSecretIndex = []byte("hashedsecrets")
+)
+
+// AtomicSwapContractStatus defines the status of an atomic swap contract.
+type AtomicSwapContractStatus string
+
+// All possible atomic swap contract statuses.
+const (
+	// AtomicSwapContractStatusOpen is the status of a contract
+	// that is not spent yet, and cannot be refunded yet.
+	AtomicSwapContractStatusOpen AtomicSwapContractStatus = "open"
+	// AtomicSwapContractStatusExpired is the status of a contract
+	// that is not spent yet, but for which the refund window is open,
+	// meaning the timelock of the contract has been reached. Note that an
+	// expired contract can still be redeemed, as long as it hasn't been refunded.
+	AtomicSwapContractStatusExpired AtomicSwapContractStatus = "expired"
+	// AtomicSwapContractStatusRedeemed is the status of a contract
+	// that has been spent by the receiver, revealing the secret.
+	AtomicSwapContractStatusRedeemed AtomicSwapContractStatus = "redeemed"
+	// AtomicSwapContractStatusRefunded is the status of a contract
+	// that has been spent by the sender, after the contract expired.
+	AtomicSwapContractStatusRefunded AtomicSwapContractStatus = "refunded"
+)
+
+type (
+	// AtomicSwapDB extends Rivine's ConsensusSet module,
+	// by keeping track of all coin outputs that have an atomic swap condition,
+	// as to be able to know the state of all atomic swap contracts ever created,
+	// and search them by sender, receiver or hashed secret.
+	AtomicSwapDB struct {
+		// The DB's ThreadGroup tells tracked functions to shut down and
+		// blocks until they have all exited before returning from Close.
+		tg rivinesync.ThreadGroup
+
+		db    *persist.BoltDatabase
+		stats atomicSwapDBStats
+
+		subscriber *atomicSwapDBCSSubscriber
+	}
+
+	// implements modules.ConsensusSetSubscriber,
+	// see transactionDBCSSubscriber for more information
+	atomicSwapDBCSSubscriber struct {
+		asdb *AtomicSwapDB
+		cs   modules.ConsensusSet
+	}
+	atomicSwapDBStats struct {
+		ConsensusChangeID modules.ConsensusChangeID
+		// BlockCount defines the amount of applied blocks,
+		// the genesis block included, such that the
+		// height of the last applied block equals BlockCount-1
+		BlockCount uint64
+		// BlockTimestamp is the timestamp of the last applied block,
+		// used to know whether or not the refund window of a contract is open
+		BlockTimestamp rivinetypes.Timestamp
+		Synced         bool
+	}
+
+	// AtomicSwapContract defines an atomic swap contract,
+	// as created by a coin output with an atomic swap condition,
+	// and its current state.
+	AtomicSwapContract struct {
+		// ID of the coin output which defines the contract
+		OutputID rivinetypes.CoinOutputID `json:"outputid"`
+		// ID of the transaction which created the coin output
+		TransactionID rivinetypes.TransactionID `json:"transactionid"`
+		// Value locked in the contract
+		Value rivinetypes.Currency `json:"value"`
+		// Contract defines the conditions of the contract
+		Contract rivinetypes.AtomicSwapCondition `json:"contract"`
+		// ContractID is the unlock hash of the contract condition
+		ContractID rivinetypes.UnlockHash `json:"contractid"`
+		// Height of the block in which the contract was created
+		CreationHeight rivinetypes.BlockHeight `json:"creationheight"`
+
+		// Status of the contract
+		Status AtomicSwapContractStatus `json:"status"`
+		// SpendTransactionID and SpendHeight are only defined
+		// for a redeemed or refunded contract
+		SpendTransactionID rivinetypes.TransactionID `json:"spendtransactionid,omitempty"`
+		SpendHeight        rivinetypes.BlockHeight   `json:"spendheight,omitempty"`
+		// Secret is only defined for a redeemed contract
+		Secret rivinetypes.AtomicSwapSecret `json:"secret,omitempty"`
+	}
+
+	// AtomicSwapContractFilter can be used to filter atomic swap contracts,
+	// only the non-nil properties are used for filtering.
+	AtomicSwapContractFilter struct {
+		Sender       *rivinetypes.UnlockHash
+		Receiver     *rivinetypes.UnlockHash
+		HashedSecret *rivinetypes.AtomicSwapHashedSecret
+		Status       *AtomicSwapContractStatus
+	}
+)
+
+// NewAtomicSwapDB creates a new AtomicSwapDB, using the given file (path) to store the (single) persistent BoltDB file.
+// A new db will be created if it doesn't exist yet.
+func NewAtomicSwapDB(rootDir string) (*AtomicSwapDB, error) {
+	persistDir := path.Join(rootDir, AtomicSwapDBDir)
+	// Create the directory if it doesn't exist.
+	err := os.MkdirAll(persistDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	asdb := new(AtomicSwapDB)
+	err = asdb.openDB(path.Join(persistDir, AtomicSwapDBFilename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the atomic swap DB: %v", err)
+	}
+	return asdb, nil
+}
+
+// SubscribeToConsensusSet subscribes the AtomicSwapDB to the given ConsensusSet,
+// allowing it to stay in sync with the blockchain, and also making it automatically unsubscribe
+// from the consensus set when the AtomicSwapDB is closed (using (*AtomicSwapDB).Close).
+func (asdb *AtomicSwapDB) SubscribeToConsensusSet(cs modules.ConsensusSet) error {
+	if asdb.subscriber != nil {
+		return errors.New("atomicSwapDB is already subscribed to a consensus set")
+	}
+
+	subscriber := &atomicSwapDBCSSubscriber{asdb: asdb, cs: cs}
+	err := cs.ConsensusSetSubscribe(
+		subscriber,
+		asdb.stats.ConsensusChangeID,
+		asdb.tg.StopChan(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to consensus set: %v", err)
+	}
+	asdb.subscriber = subscriber
+	return nil
+}
+
+// GetAtomicSwapContract returns the atomic swap contract defined by the coin output with the given ID.
+// False is returned in case no atomic swap contract exists for the given ID.
+func (asdb *AtomicSwapDB) GetAtomicSwapContract(id rivinetypes.CoinOutputID) (contract AtomicSwapContract, found bool, err error) {
+	err = asdb.db.View(func(tx *bolt.Tx) error {
+		contractsBucket := tx.Bucket(bucketAtomicSwapContracts)
+		if contractsBucket == nil {
+			return errors.New("corrupt atomic swap DB: contracts bucket does not exist")
+		}
+		b := contractsBucket.Get(encoding.Marshal(id))
+		if len(b) == 0 {
+			return nil // not found
+		}
+		found = true
+		contract, err = asdb.decodeContract(b)
+		return err
+	})
+	return
+}
+
+// GetAtomicSwapContracts returns all atomic swap contracts which match the given filter,
+// sorted by the binary-encoded key of the index used.
+func (asdb *AtomicSwapDB) GetAtomicSwapContracts(filter AtomicSwapContractFilter) (contracts []AtomicSwapContract, err error) {
+	err = asdb.db.View(func(tx *bolt.Tx) error {
+		contractsBucket := tx.Bucket(bucketAtomicSwapContracts)
+		if contractsBucket == nil {
+			return errors.New("corrupt atomic swap DB: contracts bucket does not exist")
+		}
+
+		collect := func(b []byte) error {
+			contract, err := asdb.decodeContract(b)
+			if err != nil {
+				return err
+			}
+			if filter.matches(contract) {
+				contracts = append(contracts, contract)
+			}
+			return nil
+		}
+
+		// use an index if possible, scanning all contracts only as a last resort
+		var (
+			indexBucket []byte
+			prefix      []byte
+		)
+		switch {
+		case filter.Sender != nil:
+			indexBucket, prefix = bucketAtomicSwapSenderIndex, encoding.Marshal(*filter.Sender)
+		case filter.Receiver != nil:
+			indexBucket, prefix = bucketAtomicSwapReceiverIndex, encoding.Marshal(*filter.Receiver)
+		case filter.HashedSecret != nil:
+			indexBucket, prefix = bucketAtomicSwapHashedSecretIndex, encoding.Marshal(*filter.HashedSecret)
+		default:
+			return contractsBucket.ForEach(func(_, v []byte) error {
+				return collect(v)
+			})
+		}
+
+		return scanAtomicSwapIndex(tx, indexBucket, prefix, collect)
+	})
+	return
+}
+
+// GetAtomicSwapContractsOf returns all atomic swap contracts
+// of which any of the given addresses is the sender and/or the receiver,
+// looking them all up within a single read transaction.
+func (asdb *AtomicSwapDB) GetAtomicSwapContractsOf(addresses []rivinetypes.UnlockHash) (contracts []AtomicSwapContract, err error) {
+	err = asdb.db.View(func(tx *bolt.Tx) error {
+		collected := make(map[string]struct{})
+		collect := func(b []byte) error {
+			contract, err := asdb.decodeContract(b)
+			if err != nil {
+				return err
+			}
+			key := string(encoding.Marshal(contract.OutputID))
+			if _, ok := collected[key]; ok {
+				return nil // contract between two of the given addresses
+			}
+			collected[key] = struct{}{}
+			contracts = append(contracts, contract)
+			return nil
+		}
+		for _, address := range addresses {
+			prefix := encoding.Marshal(address)
+			for _, indexBucket := range [][]byte{bucketAtomicSwapSenderIndex, bucketAtomicSwapReceiverIndex} {
+				err := scanAtomicSwapIndex(tx, indexBucket, prefix, collect)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// GetAtomicSwapSecrets returns the secrets published on the chain for the given hashed secrets,
+// by the contracts which were redeemed using them, looking them all up within a single read transaction.
+// Hashed secrets for which no contract was redeemed yet are not part of the returned map.
+func (asdb *AtomicSwapDB) GetAtomicSwapSecrets(hashedSecrets []rivinetypes.AtomicSwapHashedSecret) (secrets map[rivinetypes.AtomicSwapHashedSecret]rivinetypes.AtomicSwapSecret, err error) {
+	secrets = make(map[rivinetypes.AtomicSwapHashedSecret]rivinetypes.AtomicSwapSecret)
+	err = asdb.db.View(func(tx *bolt.Tx) error {
+		for _, hashedSecret := range hashedSecrets {
+			if _, ok := secrets[hashedSecret]; ok {
+				continue
+			}
+			err := scanAtomicSwapIndex(tx, bucketAtomicSwapHashedSecretIndex, encoding.Marshal(hashedSecret), func(b []byte) error {
+				contract, err := asdb.decodeContract(b)
+				if err != nil {
+					return err
+				}
+				if contract.Status == AtomicSwapContractStatusRedeemed {
+					secrets[hashedSecret] = contract.Secret
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// scanAtomicSwapIndex calls the given callback for all (encoded) contracts
+// indexed in the given index bucket using the given prefix
+func scanAtomicSwapIndex(tx *bolt.Tx, indexBucket, prefix []byte, cb func([]byte) error) error {
+	contractsBucket := tx.Bucket(bucketAtomicSwapContracts)
+	if contractsBucket == nil {
+		return errors.New("corrupt atomic swap DB: contracts bucket does not exist")
+	}
+	bucket := tx.Bucket(indexBucket)
+	if bucket == nil {
+		return fmt.Errorf("corrupt atomic swap DB: index bucket %s does not exist", string(indexBucket))
+	}
+	cursor := bucket.Cursor()
+	for k, _ := cursor.Seek(prefix); len(k) > 0 && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+		b := contractsBucket.Get(k[len(prefix):])
+		if len(b) == 0 {
+			return fmt.Errorf("corrupt atomic swap DB: indexed contract %x could not be found", k[len(prefix):])
+		}
+		err := cb(b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches returns true if the given contract matches all defined filter properties.
+func (filter AtomicSwapContractFilter) matches(contract AtomicSwapContract) bool {
+	if filter.Sender != nil && contract.Contract.Sender.Cmp(*filter.Sender) != 0 {
+		return false
+	}
+	if filter.Receiver != nil && contract.Contract.Receiver.Cmp(*filter.Receiver) != 0 {
+		return false
+	}
+	if filter.HashedSecret != nil && contract.Contract.HashedSecret != *filter.HashedSecret {
+		return false
+	}
+	if filter.Status != nil && contract.Status != *filter.Status {
+		return false
+	}
+	return true
+}
+
+// decodeContract decodes a stored contract,
+// updating its status to expired should its refund window be open.
+func (asdb *AtomicSwapDB) decodeContract(b []byte) (AtomicSwapContract, error) {
+	var contract AtomicSwapContract
+	err := encoding.Unmarshal(b, &contract)
+	if err != nil {
+		return AtomicSwapContract{}, fmt.Errorf("corrupt atomic swap DB: failed to decode contract: %v", err)
+	}
+	if contract.Status == AtomicSwapContractStatusOpen && contract.Contract.TimeLock < asdb.stats.BlockTimestamp {
+		contract.Status = AtomicSwapContractStatusExpired
+	}
+	return contract, nil
+}
+
+// Close the atomic swap DB,
+// meaning the db will be unsubscribed from the consensus set,
+// as well the threadgroup will be stopped and the internal bolt db will be closed.
+func (asdb *AtomicSwapDB) Close() error {
+	if asdb.db == nil {
+		return errors.New("atomicSwapDB is already closed or was never created")
+	}
+
+	// unsubscribe from the consensus set, if subscribed at all
+	if asdb.subscriber != nil {
+		asdb.subscriber.unsubscribe()
+		asdb.subscriber = nil
+	}
+	// stop thread group
+	tgErr := asdb.tg.Stop()
+	if tgErr != nil {
+		tgErr = fmt.Errorf("failed to stop the threadgroup of AtomicSwapDB: %v", tgErr)
+	}
+	// close database
+	dbErr := asdb.db.Close()
+	if dbErr != nil {
+		dbErr = fmt.Errorf("failed to close the internal bolt db of AtomicSwapDB: %v", dbErr)
+	}
+	asdb.db = nil
+
+	return build.ComposeErrors(tgErr, dbErr)
+}
+
+// openDB loads the set database and populates it with the necessary buckets
+func (asdb *AtomicSwapDB) openDB(filename string) (err error) {
+	var (
+		dbMetadata = persist.Metadata{
+			Header:  "TFChain Atomic Swap Database",
+			Version: "1.0.0",
+		}
+	)
+
+	asdb.db, err = persist.OpenDatabase(dbMetadata, filename)
+	if err != nil {
+		return fmt.Errorf("error opening tfchain atomic swap database: %v", err)
+	}
+	return asdb.db.Update(func(tx *bolt.Tx) (err error) {
+		internalBucket := tx.Bucket(bucketInternal)
+		if internalBucket != nil {
+			// db is already created, get the stored stats
+			b := internalBucket.Get(bucketInternalKeyStats)
+			if len(b) == 0 {
+				return errors.New("structured stats value could not be found in existing atomic swap db")
+			}
+			err = encoding.Unmarshal(b, &asdb.stats)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal structured stats value from existing atomic swap db: %v", err)
+			}
+			return nil // nothing to do
+		}
+
+		// create the DB
+		buckets := [][]byte{
+			bucketInternal,
+			bucketAtomicSwapContracts,
+			bucketAtomicSwapBlockTimestamps,
+			bucketAtomicSwapSenderIndex,
+			bucketAtomicSwapReceiverIndex,
+			bucketAtomicSwapHashedSecretIndex,
+		}
+		for _, bucket := range buckets {
+			_, err = tx.CreateBucket(bucket)
+			if err != nil {
+				return fmt.Errorf("failed to create atomicSwapDB: %v", err)
+			}
+		}
+		asdb.stats.ConsensusChangeID = modules.ConsensusChangeBeginning
+		return asdb.storeStats(tx)
+	})
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber,
+// calling asdb.processConsensusChange, so that the AtomicSwapDB
+// does not expose its interface implementation outside this package.
+func (sub *atomicSwapDBCSSubscriber) ProcessConsensusChange(css modules.ConsensusChange) {
+	sub.asdb.processConsensusChange(css)
+}
+
+func (sub *atomicSwapDBCSSubscriber) unsubscribe() {
+	sub.cs.Unsubscribe(sub)
+}
+
+// processConsensusChange implements modules.ConsensusSetSubscriber,
+// used to apply/revert atomic swap contracts in the internal persistent storage.
+func (asdb *AtomicSwapDB) processConsensusChange(css modules.ConsensusChange) {
+	if err := asdb.tg.Add(); err != nil {
+		// The AtomicSwapDB should gracefully reject updates from the consensus set
+		// that are sent after the AtomicSwapDB's Close method has closed its ThreadGroup.
+		return
+	}
+	defer asdb.tg.Done()
+
+	err := asdb.db.Update(func(tx *bolt.Tx) (err error) {
+		for _, block := range css.RevertedBlocks {
+			err = asdb.revertBlock(tx, block)
+			if err != nil {
+				return fmt.Errorf("failed to revert block: %v", err)
+			}
+		}
+		for _, block := range css.AppliedBlocks {
+			err = asdb.applyBlock(tx, block)
+			if err != nil {
+				return fmt.Errorf("failed to apply block: %v", err)
+			}
+		}
+		asdb.stats.BlockTimestamp, err = asdb.lastBlockTimestamp(tx)
+		if err != nil {
+			return err
+		}
+		asdb.stats.ConsensusChangeID, asdb.stats.Synced = css.ID, css.Synced
+		return asdb.storeStats(tx)
+	})
+	if err != nil {
+		build.Critical("atomicSwapDB failed to process consensus change:", err)
+	}
+}
+
+// applyBlock stores all atomic swap contracts created by the given block,
+// and updates all contracts spent by the given block
+func (asdb *AtomicSwapDB) applyBlock(tx *bolt.Tx, block rivinetypes.Block) error {
+	height := rivinetypes.BlockHeight(asdb.stats.BlockCount)
+	err := tx.Bucket(bucketAtomicSwapBlockTimestamps).Put(encodeBlockheight(height), encoding.Marshal(block.Timestamp))
+	if err != nil {
+		return fmt.Errorf("failed to store timestamp of block height %d: %v", height, err)
+	}
+	contractsBucket := tx.Bucket(bucketAtomicSwapContracts)
+	for _, txn := range block.Transactions {
+		txnID := txn.ID()
+		for _, ci := range txn.CoinInputs {
+			err := asdb.updateContract(contractsBucket, ci.ParentID, func(contract *AtomicSwapContract) {
+				contract.SpendTransactionID, contract.SpendHeight = txnID, height
+				contract.Status, contract.Secret = AtomicSwapContractStatusRefunded, rivinetypes.AtomicSwapSecret{}
+				if sg, ok := ci.Fulfillment.Fulfillment.(atomicSwapSecretGetter); ok {
+					if secret := sg.AtomicSwapSecret(); secret != (rivinetypes.AtomicSwapSecret{}) {
+						contract.Status, contract.Secret = AtomicSwapContractStatusRedeemed, secret
+					}
+				}
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for i, co := range txn.CoinOutputs {
+			if co.Condition.ConditionType() != rivinetypes.ConditionTypeAtomicSwap {
+				continue
+			}
+			condition, ok := co.Condition.Condition.(*rivinetypes.AtomicSwapCondition)
+			if !ok {
+				err := fmt.Errorf("unexpected Go-type for AtomicSwapCondition: %T", co.Condition.Condition)
+				if build.DEBUG {
+					panic(err)
+				}
+				return err
+			}
+			contract := AtomicSwapContract{
+				OutputID:       txn.CoinOutputID(uint64(i)),
+				TransactionID:  txnID,
+				Value:          co.Value,
+				Contract:       *condition,
+				ContractID:     condition.UnlockHash(),
+				CreationHeight: height,
+				Status:         AtomicSwapContractStatusOpen,
+			}
+			key := encoding.Marshal(contract.OutputID)
+			err := contractsBucket.Put(key, encoding.Marshal(contract))
+			if err != nil {
+				return fmt.Errorf("failed to store atomic swap contract %s: %v", contract.OutputID.String(), err)
+			}
+			for bucket, value := range contract.indexKeys(key) {
+				err = tx.Bucket([]byte(bucket)).Put(value, nil)
+				if err != nil {
+					return fmt.Errorf("failed to index atomic swap contract %s: %v", contract.OutputID.String(), err)
+				}
+			}
+		}
+	}
+
+	asdb.stats.BlockCount++
+	return nil
+}
+
+// revertBlock deletes all atomic swap contracts created by the given block,
+// and marks all contracts spent by the given block as open,
+// all in the reverse order in which they were applied
+func (asdb *AtomicSwapDB) revertBlock(tx *bolt.Tx, block rivinetypes.Block) error {
+	if asdb.stats.BlockCount == 0 {
+		return errors.New("cannot revert a block: no blocks are applied")
+	}
+	asdb.stats.BlockCount--
+	height := rivinetypes.BlockHeight(asdb.stats.BlockCount)
+
+	contractsBucket := tx.Bucket(bucketAtomicSwapContracts)
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		txn := &block.Transactions[i]
+		for j := range txn.CoinOutputs {
+			key := encoding.Marshal(txn.CoinOutputID(uint64(j)))
+			b := contractsBucket.Get(key)
+			if len(b) == 0 {
+				continue // not an atomic swap contract
+			}
+			var contract AtomicSwapContract
+			err := encoding.Unmarshal(b, &contract)
+			if err != nil {
+				return fmt.Errorf("corrupt atomic swap DB: failed to decode contract %x: %v", key, err)
+			}
+			for bucket, value := range contract.indexKeys(key) {
+				err = tx.Bucket([]byte(bucket)).Delete(value)
+				if err != nil {
+					return fmt.Errorf("failed to delete index of atomic swap contract %x: %v", key, err)
+				}
+			}
+			err = contractsBucket.Delete(key)
+			if err != nil {
+				return fmt.Errorf("failed to delete atomic swap contract %x: %v", key, err)
+			}
+		}
+		for _, ci := range txn.CoinInputs {
+			err := asdb.updateContract(contractsBucket, ci.ParentID, func(contract *AtomicSwapContract) {
+				contract.Status = AtomicSwapContractStatusOpen
+				contract.SpendTransactionID, contract.SpendHeight = rivinetypes.TransactionID{}, 0
+				contract.Secret = rivinetypes.AtomicSwapSecret{}
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err := tx.Bucket(bucketAtomicSwapBlockTimestamps).Delete(encodeBlockheight(height))
+	if err != nil {
+		return fmt.Errorf("failed to delete timestamp of block height %d: %v", height, err)
+	}
+	return nil
+}
+
+// lastBlockTimestamp returns the timestamp of the last applied block,
+// or zero in case no blocks are applied
+func (asdb *AtomicSwapDB) lastBlockTimestamp(tx *bolt.Tx) (rivinetypes.Timestamp, error) {
+	if asdb.stats.BlockCount == 0 {
+		return 0, nil
+	}
+	height := rivinetypes.BlockHeight(asdb.stats.BlockCount - 1)
+	b := tx.Bucket(bucketAtomicSwapBlockTimestamps).Get(encodeBlockheight(height))
+	if len(b) == 0 {
+		return 0, fmt.Errorf("corrupt atomic swap DB: no timestamp stored for block height %d", height)
+	}
+	var timestamp rivinetypes.Timestamp
+	err := encoding.Unmarshal(b, &timestamp)
+	if err != nil {
+		return 0, fmt.Errorf("corrupt atomic swap DB: failed to decode timestamp of block height %d: %v", height, err)
+	}
+	return timestamp, nil
+}
+
+// atomicSwapSecretGetter is implemented by both
+// the legacy and the regular atomic swap fulfillment
+type atomicSwapSecretGetter interface {
+	AtomicSwapSecret() rivinetypes.AtomicSwapSecret
+}
+
+// indexKeys returns the keys used to index this contract, mapped by index bucket name
+func (contract *AtomicSwapContract) indexKeys(key []byte) map[string][]byte {
+	return map[string][]byte{
+		string(bucketAtomicSwapSenderIndex):       append(encoding.Marshal(contract.Contract.Sender), key...),
+		string(bucketAtomicSwapReceiverIndex):     append(encoding.Marshal(contract.Contract.Receiver), key...),
+		string(bucketAtomicSwapHashedSecretIndex): append(encoding.Marshal(contract.Contract.HashedSecret), key...),
+	}
+}
+
+// updateContract updates an existing contract, using the given update callback,
+// it is a no-op in case no contract exists for the given ID
+func (asdb *AtomicSwapDB) updateContract(bucket *bolt.Bucket, id rivinetypes.CoinOutputID, update func(*AtomicSwapContract)) error {
+	key := encoding.Marshal(id)
+	b := bucket.Get(key)
+	if len(b) == 0 {
+		return nil // not an atomic swap contract
+	}
+	var contract AtomicSwapContract
+	err := encoding.Unmarshal(b, &contract)
+	if err != nil {
+		return fmt.Errorf("corrupt atomic swap DB: failed to decode contract %s: %v", id.String(), err)
+	}
+	update(&contract)
+	err = bucket.Put(key, encoding.Marshal(contract))
+	if err != nil {
+		return fmt.Errorf("failed to update atomic swap contract %s: %v", id.String(), err)
+	}
+	return nil
+}
+
+// storeStats stores the in-memory stats of the AtomicSwapDB in the internal bucket
+func (asdb *AtomicSwapDB) storeStats(tx *bolt.Tx) error {
+	err := tx.Bucket(bucketInternal).Put(bucketInternalKeyStats, encoding.Marshal(asdb.stats))
+	if err != nil {
+		return fmt.Errorf("failed to store atomic swap db (blocks=%d; changeID=%x; synced=%v) as a stat: %v",
+			asdb.stats.BlockCount, asdb.stats.ConsensusChangeID, asdb.stats.Synced, err)
+	}
+	return nil
+}
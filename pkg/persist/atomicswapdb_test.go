@@ -0,0 +1,63 @@
+package persist
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+func TestAtomicSwapDBContractExpiry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atomicswapdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	asdb, err := NewAtomicSwapDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer asdb.Close()
+
+	const t0 = types.Timestamp(1e9)
+	txn := types.Transaction{
+		CoinOutputs: []types.CoinOutput{
+			{Value: types.NewCurrency64(40), Condition: types.NewCondition(&types.AtomicSwapCondition{TimeLock: t0 + 10})},
+		},
+	}
+	id := txn.CoinOutputID(0)
+	blocks := []types.Block{
+		{Timestamp: t0, Transactions: []types.Transaction{txn}},
+		{Timestamp: t0 + 10},
+		{Timestamp: t0 + 11},
+	}
+	for i := range blocks {
+		blocks[i].ParentID[0] = byte(i)
+	}
+
+	checkStatus := func(expected AtomicSwapContractStatus) {
+		t.Helper()
+		contract, found, err := asdb.GetAtomicSwapContract(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found {
+			t.Fatal("contract not found")
+		}
+		if contract.Status != expected {
+			t.Errorf("expected contract status %q, not %q", expected, contract.Status)
+		}
+	}
+
+	// a contract can only be refunded once the block time exceeds its time lock
+	asdb.processConsensusChange(modules.ConsensusChange{AppliedBlocks: blocks[:2]})
+	checkStatus(AtomicSwapContractStatusOpen)
+	asdb.processConsensusChange(modules.ConsensusChange{AppliedBlocks: blocks[2:]})
+	checkStatus(AtomicSwapContractStatusExpired)
+
+	// reverting a block without applying any should restore the timestamp of the new tip
+	asdb.processConsensusChange(modules.ConsensusChange{RevertedBlocks: blocks[2:]})
+	checkStatus(AtomicSwapContractStatusOpen)
+}
@@ -0,0 +1,751 @@
+package persist
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
+	rivinesync "github.com/rivine/rivine/sync"
+	rivinetypes "github.com/rivine/rivine/types"
+
+	bolt "github.com/rivine/bbolt"
+)
+
+// SupplyDB I/O constants
+const (
+	SupplyDBDir      = "supplydb"
+	SupplyDBFilename = SupplyDBDir + ".db"
+)
+
+// internal bucket database keys used for the supplyDB
+var (
+	// bucketSupplyCoinOutputs stores all coin outputs ever created,
+	// spent or not, indexed by their coin output ID
+	bucketSupplyCoinOutputs = []byte("coinoutputs")
+	// bucketSupplySnapshots stores the supply totals of each block,
+	// indexed by its (encoded) block height
+	bucketSupplySnapshots = []byte("snapshots")
+	// bucketSupplyLocks indexes all coin outputs which are or were time-locked,
+	// spent or not, indexed by their (encoded) lock time followed by their coin output ID,
+	// such that the outputs locked at a given height can be found using a range scan
+	bucketSupplyLocks = []byte("locks")
+)
+
+type (
+	// SupplyDB extends Rivine's ConsensusSet module,
+	// by keeping track of all coin outputs ever created, as to be able
+	// to know for any given block height how much of the coin supply
+	// was liquid, time-locked or locked in an atomic swap contract.
+	//
+	// The totals are updated for each applied block and stored as a snapshot per block height,
+	// such that a breakdown never requires more than a lookup of the snapshot and
+	// a range scan over the coin outputs which are time-locked at the requested height.
+	//
+	// Spent coin outputs are never deleted (only when the block that created them is reverted),
+	// instead they are marked with the height at which they were spent,
+	// such that the unspent coin output set can be reconstructed for any block height.
+	SupplyDB struct {
+		// The DB's ThreadGroup tells tracked functions to shut down and
+		// blocks until they have all exited before returning from Close.
+		tg rivinesync.ThreadGroup
+
+		db    *persist.BoltDatabase
+		stats supplyDBStats
+
+		maturityDelay  rivinetypes.BlockHeight
+		blockFrequency rivinetypes.BlockHeight
+
+		subscriber *supplyDBCSSubscriber
+	}
+
+	// implements modules.ConsensusSetSubscriber,
+	// see transactionDBCSSubscriber for more information
+	supplyDBCSSubscriber struct {
+		sdb *SupplyDB
+		cs  modules.ConsensusSet
+	}
+	supplyDBStats struct {
+		ConsensusChangeID modules.ConsensusChangeID
+		// BlockCount defines the amount of applied blocks,
+		// the genesis block included, such that the
+		// height of the last applied block equals BlockCount-1
+		BlockCount uint64
+		Synced     bool
+	}
+
+	// supplyCoinOutput is the value stored for each coin output
+	// in the coin outputs bucket of the SupplyDB
+	supplyCoinOutput struct {
+		Value     rivinetypes.Currency
+		Condition rivinetypes.UnlockConditionProxy
+		// CreationHeight is the height of the block which created the output
+		CreationHeight rivinetypes.BlockHeight
+		// MaturityHeight is the height at which the output becomes spendable,
+		// equal to the creation height for all but miner payouts
+		MaturityHeight rivinetypes.BlockHeight
+		// SpendHeight is only defined if Spent is true
+		SpendHeight rivinetypes.BlockHeight
+		Spent       bool
+	}
+
+	// supplySnapshot is the value stored for each block height
+	// in the snapshots bucket of the SupplyDB, the liquid supply being
+	// the total supply minus the time-locked and atomic-swap-locked supply
+	supplySnapshot struct {
+		Timestamp        rivinetypes.Timestamp
+		Total            rivinetypes.Currency
+		TimeLocked       rivinetypes.Currency
+		AtomicSwapLocked rivinetypes.Currency
+	}
+
+	// supplyLock is the value stored for each coin output
+	// in the locks bucket of the SupplyDB
+	supplyLock struct {
+		Value          rivinetypes.Currency
+		CreationHeight rivinetypes.BlockHeight
+		// SpendHeight is only defined if Spent is true
+		SpendHeight rivinetypes.BlockHeight
+		Spent       bool
+	}
+)
+
+type (
+	// SupplyBreakdown classifies the unspent coin outputs at a given block height,
+	// as liquid, time-locked or atomic-swap-locked coins.
+	SupplyBreakdown struct {
+		// Height and Timestamp of the block the breakdown was computed for
+		Height    rivinetypes.BlockHeight `json:"height"`
+		Timestamp rivinetypes.Timestamp   `json:"timestamp"`
+
+		// Total is the sum of Liquid, TimeLocked and AtomicSwapLocked
+		Total rivinetypes.Currency `json:"total"`
+		// Liquid coins can be spent at the given height,
+		// given the right fulfillment
+		Liquid rivinetypes.Currency `json:"liquid"`
+		// TimeLocked coins are locked in a TimeLockCondition,
+		// for which the lock time hasn't been reached yet at the given height,
+		// immature block creator rewards are counted as time-locked coins as well
+		TimeLocked rivinetypes.Currency `json:"timelocked"`
+		// AtomicSwapLocked coins are locked in an atomic swap contract,
+		// which has neither been redeemed nor refunded at the given height
+		AtomicSwapLocked rivinetypes.Currency `json:"atomicswaplocked"`
+
+		// Unlocks projects when the time-locked coins unlock,
+		// ordered from the earliest to the latest unlock
+		Unlocks []SupplyUnlock `json:"unlocks"`
+	}
+
+	// SupplyUnlock defines a moment at which time-locked coins become liquid.
+	SupplyUnlock struct {
+		// LockTime is a block height if it is less than rivinetypes.LockTimeMinTimestampValue,
+		// otherwise it is a unix epoch timestamp expressed in seconds
+		LockTime uint64 `json:"locktime"`
+		// EstimatedTimestamp equals the LockTime for timestamp-based locks,
+		// and is estimated using the block frequency for height-based locks
+		EstimatedTimestamp rivinetypes.Timestamp `json:"estimatedtimestamp"`
+		// Value that unlocks at this lock time
+		Value rivinetypes.Currency `json:"value"`
+		// Liquid defines the total liquid supply once this value is unlocked,
+		// assuming no coins are created or locked in the meantime
+		Liquid rivinetypes.Currency `json:"liquid"`
+	}
+)
+
+// NewSupplyDB creates a new SupplyDB, using the given file (path) to store the (single) persistent BoltDB file.
+// A new db will be created if it doesn't exist yet.
+//
+// The given chain constants are used to know when miner payouts mature,
+// and to estimate when height-based time locks unlock.
+func NewSupplyDB(rootDir string, constants rivinetypes.ChainConstants) (*SupplyDB, error) {
+	persistDir := path.Join(rootDir, SupplyDBDir)
+	// Create the directory if it doesn't exist.
+	err := os.MkdirAll(persistDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	sdb := &SupplyDB{
+		maturityDelay:  constants.MaturityDelay,
+		blockFrequency: constants.BlockFrequency,
+	}
+	err = sdb.openDB(path.Join(persistDir, SupplyDBFilename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the supply DB: %v", err)
+	}
+	return sdb, nil
+}
+
+// SubscribeToConsensusSet subscribes the SupplyDB to the given ConsensusSet,
+// allowing it to stay in sync with the blockchain, and also making it automatically unsubscribe
+// from the consensus set when the SupplyDB is closed (using (*SupplyDB).Close).
+func (sdb *SupplyDB) SubscribeToConsensusSet(cs modules.ConsensusSet) error {
+	if sdb.subscriber != nil {
+		return errors.New("supplyDB is already subscribed to a consensus set")
+	}
+
+	subscriber := &supplyDBCSSubscriber{sdb: sdb, cs: cs}
+	err := cs.ConsensusSetSubscribe(
+		subscriber,
+		sdb.stats.ConsensusChangeID,
+		sdb.tg.StopChan(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to consensus set: %v", err)
+	}
+	sdb.subscriber = subscriber
+	return nil
+}
+
+// GetSupplyBreakdown returns the supply breakdown for the last applied block.
+func (sdb *SupplyDB) GetSupplyBreakdown() (SupplyBreakdown, error) {
+	var breakdown SupplyBreakdown
+	err := sdb.db.View(func(tx *bolt.Tx) (err error) {
+		if sdb.stats.BlockCount == 0 {
+			return errors.New("supply DB has not applied any blocks yet")
+		}
+		breakdown, err = sdb.supplyBreakdownAt(tx, rivinetypes.BlockHeight(sdb.stats.BlockCount-1))
+		return err
+	})
+	return breakdown, err
+}
+
+// GetSupplyBreakdownAt returns the supply breakdown for the given block height.
+func (sdb *SupplyDB) GetSupplyBreakdownAt(height rivinetypes.BlockHeight) (SupplyBreakdown, error) {
+	var breakdown SupplyBreakdown
+	err := sdb.db.View(func(tx *bolt.Tx) (err error) {
+		if uint64(height) >= sdb.stats.BlockCount {
+			return fmt.Errorf("block height %d is not (yet) applied", height)
+		}
+		breakdown, err = sdb.supplyBreakdownAt(tx, height)
+		return err
+	})
+	return breakdown, err
+}
+
+// supplyBreakdownAt returns the supply breakdown for the given height,
+// the given height is expected to be applied already.
+func (sdb *SupplyDB) supplyBreakdownAt(tx *bolt.Tx, height rivinetypes.BlockHeight) (SupplyBreakdown, error) {
+	snapshot, err := sdb.getSnapshot(tx, height)
+	if err != nil {
+		return SupplyBreakdown{}, err
+	}
+	breakdown := SupplyBreakdown{
+		Height:           height,
+		Timestamp:        snapshot.Timestamp,
+		Total:            snapshot.Total,
+		TimeLocked:       snapshot.TimeLocked,
+		AtomicSwapLocked: snapshot.AtomicSwapLocked,
+	}
+	breakdown.Liquid = snapshot.Total.Sub(snapshot.TimeLocked).Sub(snapshot.AtomicSwapLocked)
+
+	// collect the unlocks of all coin outputs that are time-locked at the given height,
+	// first those locked until a later block height, and then those locked until a later timestamp
+	unlocks := make(map[uint64]rivinetypes.Currency)
+	collect := func(lockTime uint64, lock supplyLock) {
+		if lock.CreationHeight > height || (lock.Spent && lock.SpendHeight <= height) {
+			return // output was not (or no longer) unspent at the given height
+		}
+		unlocks[lockTime] = unlocks[lockTime].Add(lock.Value)
+	}
+	err = sdb.scanLocks(tx, uint64(height)+1, rivinetypes.LockTimeMinTimestampValue-1, collect)
+	if err != nil {
+		return SupplyBreakdown{}, err
+	}
+	err = sdb.scanLocks(tx, timestampLockTime(snapshot.Timestamp+1), math.MaxUint64, collect)
+	if err != nil {
+		return SupplyBreakdown{}, err
+	}
+
+	// project the unlocks, ordered by their (estimated) unlock timestamp
+	for lockTime, value := range unlocks {
+		unlock := SupplyUnlock{
+			LockTime: lockTime,
+			Value:    value,
+		}
+		if lockTime < rivinetypes.LockTimeMinTimestampValue {
+			unlock.EstimatedTimestamp = breakdown.Timestamp + rivinetypes.Timestamp(
+				(rivinetypes.BlockHeight(lockTime)-height)*sdb.blockFrequency)
+		} else {
+			unlock.EstimatedTimestamp = rivinetypes.Timestamp(lockTime)
+		}
+		breakdown.Unlocks = append(breakdown.Unlocks, unlock)
+	}
+	sort.Slice(breakdown.Unlocks, func(i, j int) bool {
+		if breakdown.Unlocks[i].EstimatedTimestamp == breakdown.Unlocks[j].EstimatedTimestamp {
+			return breakdown.Unlocks[i].LockTime < breakdown.Unlocks[j].LockTime
+		}
+		return breakdown.Unlocks[i].EstimatedTimestamp < breakdown.Unlocks[j].EstimatedTimestamp
+	})
+	liquid := breakdown.Liquid
+	for i := range breakdown.Unlocks {
+		liquid = liquid.Add(breakdown.Unlocks[i].Value)
+		breakdown.Unlocks[i].Liquid = liquid
+	}
+
+	return breakdown, nil
+}
+
+// Close the supply DB,
+// meaning the db will be unsubscribed from the consensus set,
+// as well the threadgroup will be stopped and the internal bolt db will be closed.
+func (sdb *SupplyDB) Close() error {
+	if sdb.db == nil {
+		return errors.New("supplyDB is already closed or was never created")
+	}
+
+	// unsubscribe from the consensus set, if subscribed at all
+	if sdb.subscriber != nil {
+		sdb.subscriber.unsubscribe()
+		sdb.subscriber = nil
+	}
+	// stop thread group
+	tgErr := sdb.tg.Stop()
+	if tgErr != nil {
+		tgErr = fmt.Errorf("failed to stop the threadgroup of SupplyDB: %v", tgErr)
+	}
+	// close database
+	dbErr := sdb.db.Close()
+	if dbErr != nil {
+		dbErr = fmt.Errorf("failed to close the internal bolt db of SupplyDB: %v", dbErr)
+	}
+	sdb.db = nil
+
+	return build.ComposeErrors(tgErr, dbErr)
+}
+
+// openDB loads the set database and populates it with the necessary buckets
+func (sdb *SupplyDB) openDB(filename string) (err error) {
+	var (
+		dbMetadata = persist.Metadata{
+			Header:  "TFChain Supply Database",
+			Version: "1.0.0",
+		}
+	)
+
+	sdb.db, err = persist.OpenDatabase(dbMetadata, filename)
+	if err != nil {
+		return fmt.Errorf("error opening tfchain supply database: %v", err)
+	}
+	return sdb.db.Update(func(tx *bolt.Tx) (err error) {
+		internalBucket := tx.Bucket(bucketInternal)
+		if internalBucket != nil {
+			// db is already created, get the stored stats
+			b := internalBucket.Get(bucketInternalKeyStats)
+			if len(b) == 0 {
+				return errors.New("structured stats value could not be found in existing supply db")
+			}
+			err = encoding.Unmarshal(b, &sdb.stats)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal structured stats value from existing supply db: %v", err)
+			}
+			return nil // nothing to do
+		}
+
+		// create the DB
+		buckets := [][]byte{
+			bucketInternal,
+			bucketSupplyCoinOutputs,
+			bucketSupplySnapshots,
+			bucketSupplyLocks,
+		}
+		for _, bucket := range buckets {
+			_, err = tx.CreateBucket(bucket)
+			if err != nil {
+				return fmt.Errorf("failed to create supplyDB: %v", err)
+			}
+		}
+		sdb.stats.ConsensusChangeID = modules.ConsensusChangeBeginning
+		return sdb.storeStats(tx)
+	})
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber,
+// calling sdb.processConsensusChange, so that the SupplyDB
+// does not expose its interface implementation outside this package.
+func (sub *supplyDBCSSubscriber) ProcessConsensusChange(css modules.ConsensusChange) {
+	sub.sdb.processConsensusChange(css)
+}
+
+func (sub *supplyDBCSSubscriber) unsubscribe() {
+	sub.cs.Unsubscribe(sub)
+}
+
+// processConsensusChange implements modules.ConsensusSetSubscriber,
+// used to apply/revert coin outputs in the internal persistent storage.
+func (sdb *SupplyDB) processConsensusChange(css modules.ConsensusChange) {
+	if err := sdb.tg.Add(); err != nil {
+		// The SupplyDB should gracefully reject updates from the consensus set
+		// that are sent after the SupplyDB's Close method has closed its ThreadGroup.
+		return
+	}
+	defer sdb.tg.Done()
+
+	err := sdb.db.Update(func(tx *bolt.Tx) (err error) {
+		for _, block := range css.RevertedBlocks {
+			err = sdb.revertBlock(tx, block)
+			if err != nil {
+				return fmt.Errorf("failed to revert block: %v", err)
+			}
+		}
+		for _, block := range css.AppliedBlocks {
+			err = sdb.applyBlock(tx, block)
+			if err != nil {
+				return fmt.Errorf("failed to apply block: %v", err)
+			}
+		}
+		sdb.stats.ConsensusChangeID, sdb.stats.Synced = css.ID, css.Synced
+		return sdb.storeStats(tx)
+	})
+	if err != nil {
+		build.Critical("supplyDB failed to process consensus change:", err)
+	}
+}
+
+// applyBlock stores all coin outputs created by the given block,
+// marks all coin outputs spent by the given block as spent,
+// and stores the supply snapshot of the new block height
+func (sdb *SupplyDB) applyBlock(tx *bolt.Tx, block rivinetypes.Block) error {
+	height := rivinetypes.BlockHeight(sdb.stats.BlockCount)
+	snapshot := supplySnapshot{Timestamp: block.Timestamp}
+	if height > 0 {
+		// continue from the totals of the previous block,
+		// unlocking (or, as timestamps are not strictly increasing, relocking)
+		// the outputs of which the lock time was reached in between both blocks
+		prev, err := sdb.getSnapshot(tx, height-1)
+		if err != nil {
+			return err
+		}
+		snapshot.Total, snapshot.AtomicSwapLocked = prev.Total, prev.AtomicSwapLocked
+		snapshot.TimeLocked, err = sdb.relock(tx, height, prev, block.Timestamp)
+		if err != nil {
+			return err
+		}
+	}
+
+	coinOutputsBucket := tx.Bucket(bucketSupplyCoinOutputs)
+	for i, mp := range block.MinerPayouts {
+		err := sdb.createCoinOutput(tx, &snapshot, block.MinerPayoutID(uint64(i)), supplyCoinOutput{
+			Value:          mp.Value,
+			Condition:      rivinetypes.NewCondition(rivinetypes.NewUnlockHashCondition(mp.UnlockHash)),
+			CreationHeight: height,
+			MaturityHeight: height + sdb.maturityDelay,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store miner payout #%d of block height %d: %v", i, height, err)
+		}
+	}
+	for _, txn := range block.Transactions {
+		for _, ci := range txn.CoinInputs {
+			var sco supplyCoinOutput
+			err := sdb.updateCoinOutput(coinOutputsBucket, ci.ParentID, func(co *supplyCoinOutput) {
+				co.Spent, co.SpendHeight = true, height
+				sco = *co
+			})
+			if err != nil {
+				return err
+			}
+			snapshot.Total = snapshot.Total.Sub(sco.Value)
+			if sco.Condition.ConditionType() == rivinetypes.ConditionTypeAtomicSwap {
+				snapshot.AtomicSwapLocked = snapshot.AtomicSwapLocked.Sub(sco.Value)
+			}
+			if lockTime, ok := sco.lockTime(); ok {
+				err = sdb.updateLock(tx, lockTime, ci.ParentID, func(lock *supplyLock) {
+					lock.Spent, lock.SpendHeight = true, height
+				})
+				if err != nil {
+					return err
+				}
+				if isLocked(lockTime, height, block.Timestamp) {
+					// only possible for timestamp locks, in case the block timestamp went back in time
+					snapshot.TimeLocked = snapshot.TimeLocked.Sub(sco.Value)
+				}
+			}
+		}
+		for i, co := range txn.CoinOutputs {
+			err := sdb.createCoinOutput(tx, &snapshot, txn.CoinOutputID(uint64(i)), supplyCoinOutput{
+				Value:          co.Value,
+				Condition:      co.Condition,
+				CreationHeight: height,
+				MaturityHeight: height,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to store coin output %d of tx %s: %v", i, txn.ID().String(), err)
+			}
+		}
+	}
+
+	err := tx.Bucket(bucketSupplySnapshots).Put(encodeBlockheight(height), encoding.Marshal(snapshot))
+	if err != nil {
+		return fmt.Errorf("failed to store supply snapshot of block height %d: %v", height, err)
+	}
+	sdb.stats.BlockCount++
+	return nil
+}
+
+// relock returns the time-locked supply at the given height and timestamp,
+// computed from the time-locked supply of the previous snapshot,
+// by only scanning the outputs of which the lock time lies in between both blocks
+func (sdb *SupplyDB) relock(tx *bolt.Tx, height rivinetypes.BlockHeight, prev supplySnapshot, timestamp rivinetypes.Timestamp) (rivinetypes.Currency, error) {
+	timeLocked := prev.TimeLocked
+	// height locks can only unlock, exactly at the new height
+	err := sdb.scanLocks(tx, uint64(height), uint64(height), func(_ uint64, lock supplyLock) {
+		if !lock.Spent {
+			timeLocked = timeLocked.Sub(lock.Value)
+		}
+	})
+	if err != nil {
+		return rivinetypes.Currency{}, err
+	}
+	switch {
+	case timestamp > prev.Timestamp:
+		err = sdb.scanLocks(tx, timestampLockTime(prev.Timestamp+1), uint64(timestamp), func(_ uint64, lock supplyLock) {
+			if !lock.Spent {
+				timeLocked = timeLocked.Sub(lock.Value)
+			}
+		})
+	case timestamp < prev.Timestamp:
+		err = sdb.scanLocks(tx, timestampLockTime(timestamp+1), uint64(prev.Timestamp), func(_ uint64, lock supplyLock) {
+			if !lock.Spent {
+				timeLocked = timeLocked.Add(lock.Value)
+			}
+		})
+	}
+	if err != nil {
+		return rivinetypes.Currency{}, err
+	}
+	return timeLocked, nil
+}
+
+// createCoinOutput stores a new coin output, indexing its lock time if it has one,
+// and adds its value to the totals of the given snapshot
+func (sdb *SupplyDB) createCoinOutput(tx *bolt.Tx, snapshot *supplySnapshot, id rivinetypes.CoinOutputID, sco supplyCoinOutput) error {
+	err := tx.Bucket(bucketSupplyCoinOutputs).Put(encoding.Marshal(id), encoding.Marshal(sco))
+	if err != nil {
+		return err
+	}
+	snapshot.Total = snapshot.Total.Add(sco.Value)
+	if sco.Condition.ConditionType() == rivinetypes.ConditionTypeAtomicSwap {
+		// coins remain locked in a contract until it is either redeemed or refunded
+		snapshot.AtomicSwapLocked = snapshot.AtomicSwapLocked.Add(sco.Value)
+		return nil
+	}
+	lockTime, ok := sco.lockTime()
+	if !ok {
+		return nil
+	}
+	err = tx.Bucket(bucketSupplyLocks).Put(supplyLockKey(lockTime, id), encoding.Marshal(supplyLock{
+		Value:          sco.Value,
+		CreationHeight: sco.CreationHeight,
+	}))
+	if err != nil {
+		return err
+	}
+	if isLocked(lockTime, sco.CreationHeight, snapshot.Timestamp) {
+		snapshot.TimeLocked = snapshot.TimeLocked.Add(sco.Value)
+	}
+	return nil
+}
+
+// revertBlock deletes all coin outputs created by the given block,
+// marks all coin outputs spent by the given block as unspent,
+// all in the reverse order in which they were applied,
+// and deletes the supply snapshot of the reverted block height
+func (sdb *SupplyDB) revertBlock(tx *bolt.Tx, block rivinetypes.Block) error {
+	if sdb.stats.BlockCount == 0 {
+		return errors.New("cannot revert a block: no blocks are applied")
+	}
+	sdb.stats.BlockCount--
+	height := rivinetypes.BlockHeight(sdb.stats.BlockCount)
+
+	coinOutputsBucket := tx.Bucket(bucketSupplyCoinOutputs)
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		txn := &block.Transactions[i]
+		for j := range txn.CoinOutputs {
+			err := sdb.deleteCoinOutput(tx, txn.CoinOutputID(uint64(j)))
+			if err != nil {
+				return fmt.Errorf("failed to delete coin output %d of tx %s: %v", j, txn.ID().String(), err)
+			}
+		}
+		for _, ci := range txn.CoinInputs {
+			var sco supplyCoinOutput
+			err := sdb.updateCoinOutput(coinOutputsBucket, ci.ParentID, func(co *supplyCoinOutput) {
+				co.Spent, co.SpendHeight = false, 0
+				sco = *co
+			})
+			if err != nil {
+				return err
+			}
+			if lockTime, ok := sco.lockTime(); ok {
+				err = sdb.updateLock(tx, lockTime, ci.ParentID, func(lock *supplyLock) {
+					lock.Spent, lock.SpendHeight = false, 0
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for i := range block.MinerPayouts {
+		err := sdb.deleteCoinOutput(tx, block.MinerPayoutID(uint64(i)))
+		if err != nil {
+			return fmt.Errorf("failed to delete miner payout #%d of block height %d: %v", i, height, err)
+		}
+	}
+
+	err := tx.Bucket(bucketSupplySnapshots).Delete(encodeBlockheight(height))
+	if err != nil {
+		return fmt.Errorf("failed to delete supply snapshot of block height %d: %v", height, err)
+	}
+	return nil
+}
+
+// deleteCoinOutput deletes an existing coin output, as well as its lock, if it has one
+func (sdb *SupplyDB) deleteCoinOutput(tx *bolt.Tx, id rivinetypes.CoinOutputID) error {
+	bucket := tx.Bucket(bucketSupplyCoinOutputs)
+	key := encoding.Marshal(id)
+	b := bucket.Get(key)
+	if len(b) == 0 {
+		return fmt.Errorf("corrupt supply DB: coin output %s could not be found", id.String())
+	}
+	var sco supplyCoinOutput
+	err := encoding.Unmarshal(b, &sco)
+	if err != nil {
+		return fmt.Errorf("corrupt supply DB: failed to decode coin output %s: %v", id.String(), err)
+	}
+	if lockTime, ok := sco.lockTime(); ok {
+		err = tx.Bucket(bucketSupplyLocks).Delete(supplyLockKey(lockTime, id))
+		if err != nil {
+			return err
+		}
+	}
+	return bucket.Delete(key)
+}
+
+// updateCoinOutput updates an existing coin output, using the given update callback
+func (sdb *SupplyDB) updateCoinOutput(bucket *bolt.Bucket, id rivinetypes.CoinOutputID, update func(*supplyCoinOutput)) error {
+	key := encoding.Marshal(id)
+	b := bucket.Get(key)
+	if len(b) == 0 {
+		return fmt.Errorf("corrupt supply DB: coin output %s could not be found", id.String())
+	}
+	var sco supplyCoinOutput
+	err := encoding.Unmarshal(b, &sco)
+	if err != nil {
+		return fmt.Errorf("corrupt supply DB: failed to decode coin output %s: %v", id.String(), err)
+	}
+	update(&sco)
+	err = bucket.Put(key, encoding.Marshal(sco))
+	if err != nil {
+		return fmt.Errorf("failed to update coin output %s: %v", id.String(), err)
+	}
+	return nil
+}
+
+// updateLock updates an existing lock, using the given update callback
+func (sdb *SupplyDB) updateLock(tx *bolt.Tx, lockTime uint64, id rivinetypes.CoinOutputID, update func(*supplyLock)) error {
+	bucket := tx.Bucket(bucketSupplyLocks)
+	key := supplyLockKey(lockTime, id)
+	b := bucket.Get(key)
+	if len(b) == 0 {
+		return fmt.Errorf("corrupt supply DB: lock of coin output %s could not be found", id.String())
+	}
+	var lock supplyLock
+	err := encoding.Unmarshal(b, &lock)
+	if err != nil {
+		return fmt.Errorf("corrupt supply DB: failed to decode lock of coin output %s: %v", id.String(), err)
+	}
+	update(&lock)
+	err = bucket.Put(key, encoding.Marshal(lock))
+	if err != nil {
+		return fmt.Errorf("failed to update lock of coin output %s: %v", id.String(), err)
+	}
+	return nil
+}
+
+// scanLocks calls the given callback for all locks with a lock time within the given (inclusive) range
+func (sdb *SupplyDB) scanLocks(tx *bolt.Tx, from, to uint64, cb func(lockTime uint64, lock supplyLock)) error {
+	if from > to {
+		return nil
+	}
+	cursor := tx.Bucket(bucketSupplyLocks).Cursor()
+	for k, v := cursor.Seek(encodeBlockheight(rivinetypes.BlockHeight(from))); k != nil; k, v = cursor.Next() {
+		lockTime := binary.BigEndian.Uint64(k[:8])
+		if lockTime > to {
+			break
+		}
+		var lock supplyLock
+		err := encoding.Unmarshal(v, &lock)
+		if err != nil {
+			return fmt.Errorf("corrupt supply DB: failed to decode lock: %v", err)
+		}
+		cb(lockTime, lock)
+	}
+	return nil
+}
+
+// getSnapshot returns the stored supply snapshot of the given block height
+func (sdb *SupplyDB) getSnapshot(tx *bolt.Tx, height rivinetypes.BlockHeight) (supplySnapshot, error) {
+	b := tx.Bucket(bucketSupplySnapshots).Get(encodeBlockheight(height))
+	if len(b) == 0 {
+		return supplySnapshot{}, fmt.Errorf("corrupt supply DB: no snapshot stored for block height %d", height)
+	}
+	var snapshot supplySnapshot
+	err := encoding.Unmarshal(b, &snapshot)
+	if err != nil {
+		return supplySnapshot{}, fmt.Errorf("corrupt supply DB: failed to decode snapshot of block height %d: %v", height, err)
+	}
+	return snapshot, nil
+}
+
+// lockTime returns the lock time of the coin output, if it has one,
+// which is the maturity height for miner payouts and the lock time of time lock conditions
+func (sco *supplyCoinOutput) lockTime() (uint64, bool) {
+	if sco.MaturityHeight > sco.CreationHeight {
+		return uint64(sco.MaturityHeight), true
+	}
+	if tlc, ok := sco.Condition.Condition.(*rivinetypes.TimeLockCondition); ok {
+		return tlc.LockTime, true
+	}
+	return 0, false
+}
+
+// isLocked returns true if the given lock time isn't reached yet at the given height and timestamp
+func isLocked(lockTime uint64, height rivinetypes.BlockHeight, timestamp rivinetypes.Timestamp) bool {
+	if lockTime < rivinetypes.LockTimeMinTimestampValue {
+		return rivinetypes.BlockHeight(lockTime) > height
+	}
+	return rivinetypes.Timestamp(lockTime) > timestamp
+}
+
+// timestampLockTime returns the given timestamp as a lock time,
+// such that it never is interpreted as a block height
+func timestampLockTime(timestamp rivinetypes.Timestamp) uint64 {
+	if timestamp < rivinetypes.LockTimeMinTimestampValue {
+		return rivinetypes.LockTimeMinTimestampValue
+	}
+	return uint64(timestamp)
+}
+
+// supplyLockKey returns the key of a coin output in the locks bucket,
+// sorting the coin outputs by their lock time
+func supplyLockKey(lockTime uint64, id rivinetypes.CoinOutputID) []byte {
+	return append(encodeBlockheight(rivinetypes.BlockHeight(lockTime)), id[:]...)
+}
+
+// storeStats stores the in-memory stats of the SupplyDB in the internal bucket
+func (sdb *SupplyDB) storeStats(tx *bolt.Tx) error {
+	err := tx.Bucket(bucketInternal).Put(bucketInternalKeyStats, encoding.Marshal(sdb.stats))
+	if err != nil {
+		return fmt.Errorf("failed to store supply db (blocks=%d; changeID=%x; synced=%v) as a stat: %v",
+			sdb.stats.BlockCount, sdb.stats.ConsensusChangeID, sdb.stats.Synced, err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package persist
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+func TestSupplyDBBreakdown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "supplydb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sdb, err := NewSupplyDB(dir, types.ChainConstants{MaturityDelay: 2, BlockFrequency: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sdb.Close()
+
+	const t0 = types.Timestamp(1e9)
+	uh := types.UnlockHash{Type: types.UnlockTypePubKey}
+	uh.Hash[0] = 1
+	genesisTxn := types.Transaction{
+		CoinOutputs: []types.CoinOutput{
+			{Value: types.NewCurrency64(50), Condition: types.NewCondition(types.NewUnlockHashCondition(uh))},
+			{Value: types.NewCurrency64(20), Condition: types.NewCondition(
+				types.NewTimeLockCondition(3, types.NewUnlockHashCondition(uh)))},
+			{Value: types.NewCurrency64(30), Condition: types.NewCondition(
+				types.NewTimeLockCondition(uint64(t0+25), types.NewUnlockHashCondition(uh)))},
+			{Value: types.NewCurrency64(40), Condition: types.NewCondition(&types.AtomicSwapCondition{
+				Sender: uh, Receiver: uh, TimeLock: t0 + 1000})},
+		},
+	}
+	blocks := []types.Block{
+		{
+			Timestamp:    t0,
+			MinerPayouts: []types.MinerPayout{{Value: types.NewCurrency64(100), UnlockHash: uh}},
+			Transactions: []types.Transaction{genesisTxn},
+		},
+		{Timestamp: t0 + 10},
+		{Timestamp: t0 + 20},
+		{
+			Timestamp: t0 + 30,
+			Transactions: []types.Transaction{{
+				CoinInputs: []types.CoinInput{{ParentID: genesisTxn.CoinOutputID(3)}},
+				CoinOutputs: []types.CoinOutput{
+					{Value: types.NewCurrency64(40), Condition: types.NewCondition(types.NewUnlockHashCondition(uh))},
+				},
+			}},
+		},
+		// block timestamps are not strictly increasing, relocking the timestamp-locked output
+		{Timestamp: t0 + 20},
+	}
+	for i := range blocks {
+		blocks[i].ParentID[0] = byte(i)
+		sdb.processConsensusChange(modules.ConsensusChange{AppliedBlocks: blocks[i : i+1]})
+	}
+
+	testCases := []struct {
+		height                                      types.BlockHeight
+		total, liquid, timeLocked, atomicSwapLocked uint64
+		unlocks                                     []uint64
+	}{
+		{0, 240, 50, 150, 40, []uint64{2, uint64(t0 + 25), 3}},
+		{1, 240, 50, 150, 40, []uint64{2, uint64(t0 + 25), 3}},
+		{2, 240, 150, 50, 40, []uint64{uint64(t0 + 25), 3}},
+		{3, 240, 240, 0, 0, nil},
+		{4, 240, 210, 30, 0, []uint64{uint64(t0 + 25)}},
+	}
+	for _, tc := range testCases {
+		breakdown, err := sdb.GetSupplyBreakdownAt(tc.height)
+		if err != nil {
+			t.Fatalf("height %d: %v", tc.height, err)
+		}
+		checkSupplyBreakdown(t, breakdown, tc.height, tc.total, tc.liquid, tc.timeLocked, tc.atomicSwapLocked, tc.unlocks)
+	}
+
+	// reverting the last 2 blocks should restore the breakdown of height 2
+	sdb.processConsensusChange(modules.ConsensusChange{RevertedBlocks: []types.Block{blocks[4], blocks[3]}})
+	breakdown, err := sdb.GetSupplyBreakdown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSupplyBreakdown(t, breakdown, 2, 240, 150, 50, 40, []uint64{uint64(t0 + 25), 3})
+	if _, err = sdb.GetSupplyBreakdownAt(3); err == nil {
+		t.Error("expected reverted height to be unavailable")
+	}
+
+	// re-applying the block spending the atomic swap contract should give the same result as before
+	sdb.processConsensusChange(modules.ConsensusChange{AppliedBlocks: blocks[3:4]})
+	breakdown, err = sdb.GetSupplyBreakdown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSupplyBreakdown(t, breakdown, 3, 240, 240, 0, 0, nil)
+}
+
+func checkSupplyBreakdown(t *testing.T, breakdown SupplyBreakdown, height types.BlockHeight, total, liquid, timeLocked, atomicSwapLocked uint64, unlocks []uint64) {
+	t.Helper()
+	if breakdown.Height != height {
+		t.Errorf("expected height %d, not %d", height, breakdown.Height)
+	}
+	if !breakdown.Total.Equals64(total) || !breakdown.Liquid.Equals64(liquid) ||
+		!breakdown.TimeLocked.Equals64(timeLocked) || !breakdown.AtomicSwapLocked.Equals64(atomicSwapLocked) {
+		t.Errorf("height %d: expected %d total = %d liquid + %d time-locked + %d atomic-swap-locked, not %v = %v + %v + %v",
+			height, total, liquid, timeLocked, atomicSwapLocked,
+			breakdown.Total, breakdown.Liquid, breakdown.TimeLocked, breakdown.AtomicSwapLocked)
+	}
+	if len(breakdown.Unlocks) != len(unlocks) {
+		t.Fatalf("height %d: expected %d unlocks, not %d", height, len(unlocks), len(breakdown.Unlocks))
+	}
+	for i, lockTime := range unlocks {
+		if breakdown.Unlocks[i].LockTime != lockTime {
+			t.Errorf("height %d: expected unlock #%d at lock time %d, not %d", height, i, lockTime, breakdown.Unlocks[i].LockTime)
+		}
+	}
+	if n := len(breakdown.Unlocks); n > 0 && !breakdown.Unlocks[n-1].Liquid.Equals(breakdown.Total.Sub(breakdown.AtomicSwapLocked)) {
+		t.Errorf("height %d: expected all time-locked coins to be liquid after the last unlock", height)
+	}
+}
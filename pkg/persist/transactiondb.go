@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"time"
 
 	"github.com/threefoldfoundation/tfchain/pkg/types"
 
@@ -28,7 +29,7 @@ const (
 // internal bucket database keys used for the transactionDB
 var (
 	bucketInternal         = []byte("internal")
-	bucketInternalKeyStats = []byte("stats") // stored as a single struct, see `transactionDBStats`
+	bucketInternalKeyStats = []byte("stats") // stored as a single struct, see `TransactionDBStats`
 
 	// getBucketMintConditionPerHeightRangeKey is used to compute the keys
 	// of the values in this bucket
@@ -49,9 +50,12 @@ type (
 		tg rivinesync.ThreadGroup
 
 		db    *persist.BoltDatabase
-		stats transactionDBStats
+		stats TransactionDBStats
 
 		subscriber *transactionDBCSSubscriber
+		// durationObserver is optional,
+		// and is called with the duration it took to apply or revert the blocks of a consensus change
+		durationObserver func(op TransactionDBOperation, blocks int, duration time.Duration)
 	}
 
 	// implements modules.ConsensusSetSubscriber,
@@ -63,13 +67,28 @@ type (
 		txdb *TransactionDB
 		cs   modules.ConsensusSet
 	}
-	transactionDBStats struct {
-		ConsensusChangeID modules.ConsensusChangeID
-		BlockHeight       rivinetypes.BlockHeight
-		Synced            bool
+
+	// TransactionDBStats contains the stats of the TransactionDB,
+	// tracking how far it has processed the consensus set.
+	TransactionDBStats struct {
+		ConsensusChangeID modules.ConsensusChangeID `json:"consensuschangeid"`
+		// BlockHeight is the amount of blocks applied, including the genesis block,
+		// and thus equals the consensus height plus one when in sync
+		BlockHeight rivinetypes.BlockHeight `json:"blockheight"`
+		Synced      bool                    `json:"synced"`
 	}
 )
 
+// TransactionDBOperation defines an operation of the TransactionDB,
+// for which the duration can be observed.
+type TransactionDBOperation string
+
+// All TransactionDB operations for which the duration can be observed.
+const (
+	TransactionDBOperationApply  TransactionDBOperation = "apply"
+	TransactionDBOperationRevert TransactionDBOperation = "revert"
+)
+
 var (
 	// ensure TransactionDB implements the MintConditionGetter interface
 	_ types.MintConditionGetter = (*TransactionDB)(nil)
@@ -115,6 +134,30 @@ func (txdb *TransactionDB) SubscribeToConsensusSet(cs modules.ConsensusSet) erro
 	return nil
 }
 
+// SetDurationObserver registers a callback which is called with the duration it took
+// to apply or revert the blocks of each processed consensus change, as well as the amount of blocks.
+// It has to be registered prior to subscribing to the consensus set.
+func (txdb *TransactionDB) SetDurationObserver(observer func(op TransactionDBOperation, blocks int, duration time.Duration)) {
+	txdb.durationObserver = observer
+}
+
+// Stats returns the stats of the TransactionDB, as last stored.
+func (txdb *TransactionDB) Stats() (TransactionDBStats, error) {
+	var stats TransactionDBStats
+	err := txdb.db.View(func(tx *bolt.Tx) error {
+		internalBucket := tx.Bucket(bucketInternal)
+		if internalBucket == nil {
+			return errors.New("corrupt transaction DB: internal bucket does not exist")
+		}
+		b := internalBucket.Get(bucketInternalKeyStats)
+		if len(b) == 0 {
+			return errors.New("corrupt transaction DB: structured stats value could not be found")
+		}
+		return encoding.Unmarshal(b, &stats)
+	})
+	return stats, err
+}
+
 // GetActiveMintCondition implements types.MintConditionGetter.GetActiveMintCondition
 func (txdb *TransactionDB) GetActiveMintCondition() (rivinetypes.UnlockConditionProxy, error) {
 	var b []byte
@@ -340,16 +383,20 @@ func (txdb *TransactionDB) processConsensusChange(css modules.ConsensusChange) {
 
 	txdb.db.Update(func(tx *bolt.Tx) (err error) {
 		// update reverted transactions in a block-defined order
+		start := time.Now()
 		err = txdb.revertBlocks(tx, css.RevertedBlocks)
 		if err != nil {
 			return fmt.Errorf("failed to revert blocks: %v", err)
 		}
+		txdb.observeDuration(TransactionDBOperationRevert, len(css.RevertedBlocks), start)
 
 		// update applied transactions in a block-defined order
+		start = time.Now()
 		err = txdb.applyBlocks(tx, css.AppliedBlocks)
 		if err != nil {
 			return fmt.Errorf("failed to apply blocks: %v", err)
 		}
+		txdb.observeDuration(TransactionDBOperationApply, len(css.AppliedBlocks), start)
 
 		// update the consensus change ID and synced status
 		txdb.stats.ConsensusChangeID, txdb.stats.Synced = css.ID, css.Synced
@@ -366,6 +413,14 @@ func (txdb *TransactionDB) processConsensusChange(css modules.ConsensusChange) {
 	})
 }
 
+// observeDuration reports the duration of the given operation, since the given start time,
+// to the registered duration observer, if any and only if blocks were processed at all
+func (txdb *TransactionDB) observeDuration(op TransactionDBOperation, blocks int, start time.Time) {
+	if txdb.durationObserver != nil && blocks > 0 {
+		txdb.durationObserver(op, blocks, time.Since(start))
+	}
+}
+
 // revert all the given blocks using the given writable bolt Transaction,
 // meaning the block height will be decreased per reverted block and
 // all reverted mint conditions will be deleted as well
@@ -438,7 +493,7 @@ func (txdb *TransactionDB) applyBlocks(tx *bolt.Tx, blocks []rivinetypes.Block)
 			}
 			mdtx, err := types.MinterDefinitionTransactionFromTransaction(*rtx)
 			if err != nil {
-				return fmt.Errorf("unexpected error while unpacking the minter def. tx type: %v" + err.Error())
+				return fmt.Errorf("unexpected error while unpacking the minter def. tx type: %v", err)
 			}
 			err = mintConditionsBucket.Put(encodeBlockheight(txdb.stats.BlockHeight), encoding.Marshal(mdtx.MintCondition))
 			if err != nil {
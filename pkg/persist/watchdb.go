@@ -0,0 +1,1017 @@
+package persist
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/build"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/persist"
+	rivinesync "github.com/rivine/rivine/sync"
+	rivinetypes "github.com/rivine/rivine/types"
+
+	bolt "github.com/rivine/bbolt"
+)
+
+// WatchDB I/O constants
+const (
+	WatchDBDir      = "watchdb"
+	WatchDBFilename = WatchDBDir + ".db"
+)
+
+// internal bucket database keys used for the watchDB
+var (
+	// bucketWatchEntries stores all watched entries,
+	// indexed by their binary-encoded unlock hash
+	bucketWatchEntries = []byte("entries")
+	// bucketWatchOutputs stores all (coin and block stake) outputs of the watched entries,
+	// indexed by the concatenation of the binary-encoded unlock hash, output kind and output ID
+	bucketWatchOutputs = []byte("outputs")
+	// bucketWatchOutputIndex maps the concatenation of the output kind and ID
+	// of a stored output to the binary-encoded unlock hash of its entry
+	bucketWatchOutputIndex = []byte("outputindex")
+	// bucketWatchTransactions stores all transactions relevant to the watched entries,
+	// indexed by the concatenation of the binary-encoded unlock hash,
+	// and the big-endian encoded block height and sequence number within the block
+	bucketWatchTransactions = []byte("transactions")
+	// bucketWatchBlockTimestamps stores the timestamp of each block,
+	// indexed by its (encoded) block height, such that the timestamp
+	// of the last applied block can be restored when blocks are reverted
+	bucketWatchBlockTimestamps = []byte("blocktimestamps")
+)
+
+// Errors returned by the WatchDB.
+var (
+	ErrWatchEntryNotFound = errors.New("watch entry not found")
+)
+
+// WatchOutputKind defines the kind of a watched output.
+type WatchOutputKind uint8
+
+// All possible watched output kinds.
+const (
+	WatchOutputKindCoin WatchOutputKind = iota + 1
+	WatchOutputKindBlockStake
+)
+
+type (
+	// WatchDB extends Rivine's ConsensusSet module,
+	// by keeping track of the outputs and transactions of watch-only entries,
+	// unlock hashes (optionally with the full condition) for which no keys are known,
+	// as to be able to report their balances and history.
+	//
+	// A new entry is rescanned in the background, starting at its start height,
+	// and is only kept in sync together with the other entries once it caught up.
+	WatchDB struct {
+		// The DB's ThreadGroup tells tracked functions to shut down and
+		// blocks until they have all exited before returning from Close.
+		tg rivinesync.ThreadGroup
+
+		db        *persist.BoltDatabase
+		stats     watchDBStats
+		constants rivinetypes.ChainConstants
+
+		// watched contains the unlock hashes of all entries which are in sync,
+		// while rescans contains the subscribers of all entries which are still being rescanned,
+		// both protected together with the stats by mu
+		watched map[rivinetypes.UnlockHash]struct{}
+		rescans map[rivinetypes.UnlockHash]*watchDBRescanSubscriber
+		mu      sync.RWMutex
+		// rescanMu ensures only one rescan happens at a time
+		rescanMu sync.Mutex
+
+		cs         modules.ConsensusSet
+		subscriber *watchDBCSSubscriber
+	}
+
+	// implements modules.ConsensusSetSubscriber,
+	// see transactionDBCSSubscriber for more information
+	watchDBCSSubscriber struct {
+		wdb *WatchDB
+	}
+	// watchDBRescanSubscriber implements modules.ConsensusSetSubscriber,
+	// rescanning the blockchain for a single new entry, until it caught up with the WatchDB
+	watchDBRescanSubscriber struct {
+		wdb         *WatchDB
+		watched     map[rivinetypes.UnlockHash]struct{}
+		uh          rivinetypes.UnlockHash
+		startHeight rivinetypes.BlockHeight
+		blockCount  uint64
+		// cancel is closed when the rescan is aborted,
+		// synced is closed once the entry caught up
+		cancel, synced chan struct{}
+	}
+	watchDBStats struct {
+		ConsensusChangeID modules.ConsensusChangeID
+		// BlockCount defines the amount of applied blocks,
+		// the genesis block included, such that the
+		// height of the last applied block equals BlockCount-1
+		BlockCount uint64
+		// BlockTimestamp is the timestamp of the last applied block,
+		// used to know whether or not time-locked outputs are spendable
+		BlockTimestamp rivinetypes.Timestamp
+		Synced         bool
+	}
+
+	// WatchEntry is a watch-only entry, for which the wallet doesn't own the keys.
+	WatchEntry struct {
+		UnlockHash rivinetypes.UnlockHash `json:"unlockhash"`
+		// Condition is optional, and only defined if it was given when adding the entry
+		Condition rivinetypes.UnlockConditionProxy `json:"condition"`
+		Label     string                           `json:"label,omitempty"`
+		Added     rivinetypes.Timestamp            `json:"added"`
+		// StartHeight is the block height from which the blockchain is scanned for the entry,
+		// outputs and transactions of prior blocks are ignored
+		StartHeight rivinetypes.BlockHeight `json:"startheight,omitempty"`
+		// Rescanning is true as long as the entry hasn't caught up with the blockchain yet
+		Rescanning bool `json:"rescanning,omitempty"`
+	}
+
+	// WatchOutput is a (coin or block stake) output of a watched entry.
+	WatchOutput struct {
+		Kind           WatchOutputKind                  `json:"kind"`
+		ID             rivinetypes.OutputID             `json:"id"`
+		Value          rivinetypes.Currency             `json:"value"`
+		Condition      rivinetypes.UnlockConditionProxy `json:"condition"`
+		TransactionID  rivinetypes.TransactionID        `json:"transactionid"`
+		CreationHeight rivinetypes.BlockHeight          `json:"creationheight"`
+		// MaturityHeight is only defined for miner payouts
+		MaturityHeight rivinetypes.BlockHeight `json:"maturityheight,omitempty"`
+		Spent          bool                    `json:"spent"`
+		// SpendTransactionID and SpendHeight are only defined for spent outputs
+		SpendTransactionID rivinetypes.TransactionID `json:"spendtransactionid,omitempty"`
+		SpendHeight        rivinetypes.BlockHeight   `json:"spendheight,omitempty"`
+	}
+
+	// WatchTransaction is a confirmed transaction (or the miner payouts of a block)
+	// relevant to a watched entry, reduced to its coin movements.
+	WatchTransaction struct {
+		// ID is the ID of the block in case of miner payouts
+		ID          rivinetypes.TransactionID      `json:"id"`
+		BlockHeight rivinetypes.BlockHeight        `json:"blockheight"`
+		Timestamp   rivinetypes.Timestamp          `json:"timestamp"`
+		Version     rivinetypes.TransactionVersion `json:"version"`
+		// MinerPayouts is true in case the outputs are the miner payouts of a block
+		MinerPayouts bool                   `json:"minerpayouts"`
+		AtomicSwap   bool                   `json:"atomicswap"`
+		Inputs       []WatchFund            `json:"inputs"`
+		Outputs      []WatchFund            `json:"outputs"`
+		MinerFees    []rivinetypes.Currency `json:"minerfees"`
+	}
+
+	// WatchFund is a single coin input or output of a watched transaction.
+	WatchFund struct {
+		Address rivinetypes.UnlockHash `json:"address"`
+		Value   rivinetypes.Currency   `json:"value"`
+	}
+)
+
+// NewWatchDB creates a new WatchDB, using the given file (path) to store the (single) persistent BoltDB file.
+// A new db will be created if it doesn't exist yet.
+func NewWatchDB(rootDir string, constants rivinetypes.ChainConstants) (*WatchDB, error) {
+	persistDir := path.Join(rootDir, WatchDBDir)
+	// Create the directory if it doesn't exist.
+	err := os.MkdirAll(persistDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	wdb := &WatchDB{
+		constants: constants,
+		watched:   make(map[rivinetypes.UnlockHash]struct{}),
+		rescans:   make(map[rivinetypes.UnlockHash]*watchDBRescanSubscriber),
+	}
+	err = wdb.openDB(path.Join(persistDir, WatchDBFilename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the watch DB: %v", err)
+	}
+	return wdb, nil
+}
+
+// SubscribeToConsensusSet subscribes the WatchDB to the given ConsensusSet,
+// allowing it to stay in sync with the blockchain, and also making it automatically unsubscribe
+// from the consensus set when the WatchDB is closed (using (*WatchDB).Close).
+func (wdb *WatchDB) SubscribeToConsensusSet(cs modules.ConsensusSet) error {
+	if wdb.subscriber != nil {
+		return errors.New("watchDB is already subscribed to a consensus set")
+	}
+	wdb.cs = cs
+	err := wdb.subscribe()
+	if err != nil {
+		return err
+	}
+	// continue the rescans which were interrupted when the WatchDB was closed
+	wdb.mu.RLock()
+	for _, sub := range wdb.rescans {
+		go wdb.rescan(sub)
+	}
+	wdb.mu.RUnlock()
+	return nil
+}
+
+// AddEntry adds a new watch-only entry, or updates the label and condition of an existing entry.
+// A new entry is rescanned in the background, starting at its start height,
+// and is reported as rescanning until it caught up with the blockchain.
+func (wdb *WatchDB) AddEntry(entry WatchEntry) error {
+	if err := wdb.tg.Add(); err != nil {
+		return err
+	}
+	defer wdb.tg.Done()
+	if wdb.subscriber == nil {
+		return errors.New("watchDB is not subscribed to a consensus set")
+	}
+	if entry.Condition.ConditionType() != rivinetypes.ConditionTypeNil && entry.Condition.UnlockHash().Cmp(entry.UnlockHash) != 0 {
+		return errors.New("condition does not match the unlock hash of the entry")
+	}
+
+	wdb.mu.Lock()
+	defer wdb.mu.Unlock()
+	key := encoding.Marshal(entry.UnlockHash)
+	_, watched := wdb.watched[entry.UnlockHash]
+	_, rescanning := wdb.rescans[entry.UnlockHash]
+	if watched || rescanning {
+		// no rescan required, only update the entry itself
+		return wdb.db.Update(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket(bucketWatchEntries)
+			var stored WatchEntry
+			err := encoding.Unmarshal(bucket.Get(key), &stored)
+			if err != nil {
+				return fmt.Errorf("corrupt watch DB: failed to decode entry %s: %v", entry.UnlockHash.String(), err)
+			}
+			stored.Label, stored.Condition = entry.Label, entry.Condition
+			return bucket.Put(key, encoding.Marshal(stored))
+		})
+	}
+
+	if entry.Added == 0 {
+		entry.Added = rivinetypes.CurrentTimestamp()
+	}
+	entry.Rescanning = true
+	err := wdb.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketWatchEntries).Put(key, encoding.Marshal(entry))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store watch entry %s: %v", entry.UnlockHash.String(), err)
+	}
+	sub := wdb.newRescanSubscriber(entry)
+	wdb.rescans[entry.UnlockHash] = sub
+	go wdb.rescan(sub)
+	return nil
+}
+
+// RemoveEntry removes a watch-only entry, together with all its outputs and transactions.
+func (wdb *WatchDB) RemoveEntry(uh rivinetypes.UnlockHash) error {
+	if err := wdb.tg.Add(); err != nil {
+		return err
+	}
+	defer wdb.tg.Done()
+
+	wdb.mu.Lock()
+	defer wdb.mu.Unlock()
+	_, watched := wdb.watched[uh]
+	sub, rescanning := wdb.rescans[uh]
+	if !watched && !rescanning {
+		return ErrWatchEntryNotFound
+	}
+	err := wdb.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(bucketWatchEntries).Delete(encoding.Marshal(uh))
+		if err != nil {
+			return err
+		}
+		return deleteWatchEntryData(tx, uh)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove watch entry %s: %v", uh.String(), err)
+	}
+	delete(wdb.watched, uh)
+	if rescanning {
+		close(sub.cancel)
+		delete(wdb.rescans, uh)
+	}
+	return nil
+}
+
+// Entries returns all watch-only entries.
+func (wdb *WatchDB) Entries() (entries []WatchEntry, err error) {
+	wdb.mu.RLock()
+	defer wdb.mu.RUnlock()
+	err = wdb.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketWatchEntries).ForEach(func(k, v []byte) error {
+			var entry WatchEntry
+			err := encoding.Unmarshal(v, &entry)
+			if err != nil {
+				return fmt.Errorf("corrupt watch DB: failed to decode entry %x: %v", k, err)
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	return
+}
+
+// Entry returns the watch-only entry for the given unlock hash,
+// returning ErrWatchEntryNotFound if it doesn't exist.
+func (wdb *WatchDB) Entry(uh rivinetypes.UnlockHash) (entry WatchEntry, err error) {
+	wdb.mu.RLock()
+	defer wdb.mu.RUnlock()
+	err = wdb.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketWatchEntries).Get(encoding.Marshal(uh))
+		if len(b) == 0 {
+			return ErrWatchEntryNotFound
+		}
+		return encoding.Unmarshal(b, &entry)
+	})
+	return
+}
+
+// Outputs returns all (coin and block stake) outputs of the given watched entry,
+// including the spent ones.
+func (wdb *WatchDB) Outputs(uh rivinetypes.UnlockHash) (outputs []WatchOutput, err error) {
+	wdb.mu.RLock()
+	defer wdb.mu.RUnlock()
+	prefix := encoding.Marshal(uh)
+	err = wdb.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(bucketWatchOutputs).Cursor()
+		for k, v := cursor.Seek(prefix); len(k) > 0 && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+			var output WatchOutput
+			err := encoding.Unmarshal(v, &output)
+			if err != nil {
+				return fmt.Errorf("corrupt watch DB: failed to decode output %x: %v", k, err)
+			}
+			outputs = append(outputs, output)
+		}
+		return nil
+	})
+	return
+}
+
+// Transactions returns all transactions relevant to the given watched entry,
+// starting at the given block height, in the order they were applied to the blockchain.
+func (wdb *WatchDB) Transactions(uh rivinetypes.UnlockHash, startHeight rivinetypes.BlockHeight) (txns []WatchTransaction, err error) {
+	wdb.mu.RLock()
+	defer wdb.mu.RUnlock()
+	prefix := encoding.Marshal(uh)
+	err = wdb.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(bucketWatchTransactions).Cursor()
+		for k, v := cursor.Seek(append(prefix, encodeBlockheight(startHeight)...)); len(k) > 0 && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+			var txn WatchTransaction
+			err := encoding.Unmarshal(v, &txn)
+			if err != nil {
+				return fmt.Errorf("corrupt watch DB: failed to decode transaction %x: %v", k, err)
+			}
+			txns = append(txns, txn)
+		}
+		return nil
+	})
+	return
+}
+
+// FulfillableContext returns the context of the last applied block,
+// which can be used to know whether or not outputs are spendable.
+func (wdb *WatchDB) FulfillableContext() rivinetypes.FulfillableContext {
+	wdb.mu.RLock()
+	defer wdb.mu.RUnlock()
+	ctx := rivinetypes.FulfillableContext{BlockTime: wdb.stats.BlockTimestamp}
+	if wdb.stats.BlockCount > 0 {
+		ctx.BlockHeight = rivinetypes.BlockHeight(wdb.stats.BlockCount - 1)
+	}
+	return ctx
+}
+
+// Close the watch DB,
+// meaning the db will be unsubscribed from the consensus set,
+// as well the threadgroup will be stopped and the internal bolt db will be closed.
+func (wdb *WatchDB) Close() error {
+	if wdb.db == nil {
+		return errors.New("watchDB is already closed or was never created")
+	}
+
+	// abort all rescans
+	wdb.mu.Lock()
+	for uh, sub := range wdb.rescans {
+		close(sub.cancel)
+		delete(wdb.rescans, uh)
+	}
+	wdb.mu.Unlock()
+	// unsubscribe from the consensus set, if subscribed at all
+	if wdb.subscriber != nil {
+		wdb.subscriber.unsubscribe()
+		wdb.subscriber = nil
+	}
+	// stop thread group
+	tgErr := wdb.tg.Stop()
+	if tgErr != nil {
+		tgErr = fmt.Errorf("failed to stop the threadgroup of WatchDB: %v", tgErr)
+	}
+	// close database
+	dbErr := wdb.db.Close()
+	if dbErr != nil {
+		dbErr = fmt.Errorf("failed to close the internal bolt db of WatchDB: %v", dbErr)
+	}
+	wdb.db = nil
+
+	return build.ComposeErrors(tgErr, dbErr)
+}
+
+// subscribe to the consensus set, starting from the last processed consensus change
+func (wdb *WatchDB) subscribe() error {
+	subscriber := &watchDBCSSubscriber{wdb: wdb}
+	err := wdb.cs.ConsensusSetSubscribe(
+		subscriber,
+		wdb.stats.ConsensusChangeID,
+		wdb.tg.StopChan(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to consensus set: %v", err)
+	}
+	wdb.subscriber = subscriber
+	return nil
+}
+
+// openDB loads the set database and populates it with the necessary buckets
+func (wdb *WatchDB) openDB(filename string) (err error) {
+	var (
+		dbMetadata = persist.Metadata{
+			Header:  "TFChain Watch Database",
+			Version: "1.0.0",
+		}
+	)
+
+	wdb.db, err = persist.OpenDatabase(dbMetadata, filename)
+	if err != nil {
+		return fmt.Errorf("error opening tfchain watch database: %v", err)
+	}
+	return wdb.db.Update(func(tx *bolt.Tx) (err error) {
+		internalBucket := tx.Bucket(bucketInternal)
+		if internalBucket != nil {
+			// db is already created, get the stored stats
+			b := internalBucket.Get(bucketInternalKeyStats)
+			if len(b) == 0 {
+				return errors.New("structured stats value could not be found in existing watch db")
+			}
+			err = encoding.Unmarshal(b, &wdb.stats)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal structured stats value from existing watch db: %v", err)
+			}
+			// load the unlock hashes of all watched entries,
+			// restarting the rescans of the entries which hadn't caught up yet
+			return tx.Bucket(bucketWatchEntries).ForEach(func(k, v []byte) error {
+				var entry WatchEntry
+				err := encoding.Unmarshal(v, &entry)
+				if err != nil {
+					return fmt.Errorf("corrupt watch DB: failed to decode entry %x: %v", k, err)
+				}
+				if !entry.Rescanning {
+					wdb.watched[entry.UnlockHash] = struct{}{}
+					return nil
+				}
+				err = deleteWatchEntryData(tx, entry.UnlockHash)
+				if err != nil {
+					return fmt.Errorf("failed to reset rescanned watch entry %s: %v", entry.UnlockHash.String(), err)
+				}
+				wdb.rescans[entry.UnlockHash] = wdb.newRescanSubscriber(entry)
+				return nil
+			})
+		}
+
+		// create the DB
+		buckets := [][]byte{
+			bucketInternal,
+			bucketWatchEntries,
+			bucketWatchOutputs,
+			bucketWatchOutputIndex,
+			bucketWatchTransactions,
+			bucketWatchBlockTimestamps,
+		}
+		for _, bucket := range buckets {
+			_, err = tx.CreateBucket(bucket)
+			if err != nil {
+				return fmt.Errorf("failed to create watchDB: %v", err)
+			}
+		}
+		wdb.stats.ConsensusChangeID = modules.ConsensusChangeBeginning
+		return wdb.storeStats(tx)
+	})
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber,
+// calling wdb.processConsensusChange, so that the WatchDB
+// does not expose its interface implementation outside this package.
+func (sub *watchDBCSSubscriber) ProcessConsensusChange(css modules.ConsensusChange) {
+	sub.wdb.processConsensusChange(css)
+}
+
+func (sub *watchDBCSSubscriber) unsubscribe() {
+	sub.wdb.cs.Unsubscribe(sub)
+}
+
+// processConsensusChange implements modules.ConsensusSetSubscriber,
+// used to apply/revert the outputs and transactions of watched entries in the internal persistent storage.
+func (wdb *WatchDB) processConsensusChange(css modules.ConsensusChange) {
+	if err := wdb.tg.Add(); err != nil {
+		// The WatchDB should gracefully reject updates from the consensus set
+		// that are sent after the WatchDB's Close method has closed its ThreadGroup.
+		return
+	}
+	defer wdb.tg.Done()
+
+	wdb.mu.Lock()
+	defer wdb.mu.Unlock()
+
+	coinOutputs := consensusChangeCoinOutputs(css)
+	err := wdb.db.Update(func(tx *bolt.Tx) (err error) {
+		timestamps := tx.Bucket(bucketWatchBlockTimestamps)
+		for _, block := range css.RevertedBlocks {
+			if wdb.stats.BlockCount == 0 {
+				return errors.New("cannot revert a block: no blocks are applied")
+			}
+			wdb.stats.BlockCount--
+			height := rivinetypes.BlockHeight(wdb.stats.BlockCount)
+			err = wdb.revertBlock(tx, wdb.watched, block, height)
+			if err != nil {
+				return fmt.Errorf("failed to revert block: %v", err)
+			}
+			err = timestamps.Delete(encodeBlockheight(height))
+			if err != nil {
+				return fmt.Errorf("failed to delete timestamp of block height %d: %v", height, err)
+			}
+		}
+		for _, block := range css.AppliedBlocks {
+			height := rivinetypes.BlockHeight(wdb.stats.BlockCount)
+			err = wdb.applyBlock(tx, wdb.watched, block, height, coinOutputs)
+			if err != nil {
+				return fmt.Errorf("failed to apply block: %v", err)
+			}
+			err = timestamps.Put(encodeBlockheight(height), encoding.Marshal(block.Timestamp))
+			if err != nil {
+				return fmt.Errorf("failed to store timestamp of block height %d: %v", height, err)
+			}
+			wdb.stats.BlockCount++
+		}
+		wdb.stats.BlockTimestamp, err = wdb.lastBlockTimestamp(tx)
+		if err != nil {
+			return err
+		}
+		wdb.stats.ConsensusChangeID, wdb.stats.Synced = css.ID, css.Synced
+		return wdb.storeStats(tx)
+	})
+	if err != nil {
+		build.Critical("watchDB failed to process consensus change:", err)
+	}
+}
+
+// newRescanSubscriber creates the subscriber used to rescan the blockchain for the given entry
+func (wdb *WatchDB) newRescanSubscriber(entry WatchEntry) *watchDBRescanSubscriber {
+	return &watchDBRescanSubscriber{
+		wdb:         wdb,
+		watched:     map[rivinetypes.UnlockHash]struct{}{entry.UnlockHash: {}},
+		uh:          entry.UnlockHash,
+		startHeight: entry.StartHeight,
+		cancel:      make(chan struct{}),
+		synced:      make(chan struct{}),
+	}
+}
+
+// rescan the blockchain for the entry of the given subscriber,
+// subscribing it from the beginning until it caught up with the WatchDB,
+// which happens at the latest when the consensus set replayed all its changes,
+// as the WatchDB cannot receive any changes in the meantime
+func (wdb *WatchDB) rescan(sub *watchDBRescanSubscriber) {
+	if err := wdb.tg.Add(); err != nil {
+		return
+	}
+	defer wdb.tg.Done()
+	wdb.rescanMu.Lock()
+	defer wdb.rescanMu.Unlock()
+
+	select {
+	case <-sub.cancel:
+		return
+	default:
+	}
+	err := wdb.cs.ConsensusSetSubscribe(sub, modules.ConsensusChangeBeginning, sub.cancel)
+	if err != nil {
+		select {
+		case <-sub.cancel:
+		default:
+			build.Critical(fmt.Sprintf("watchDB failed to rescan watch entry %s:", sub.uh.String()), err)
+		}
+		return
+	}
+	select {
+	case <-sub.synced:
+	case <-sub.cancel:
+	}
+	wdb.cs.Unsubscribe(sub)
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber,
+// used to apply/revert the outputs and transactions of the rescanned entry,
+// handing the entry over to the WatchDB as soon as it processed the same consensus change.
+func (sub *watchDBRescanSubscriber) ProcessConsensusChange(css modules.ConsensusChange) {
+	wdb := sub.wdb
+	if err := wdb.tg.Add(); err != nil {
+		return
+	}
+	defer wdb.tg.Done()
+
+	wdb.mu.Lock()
+	defer wdb.mu.Unlock()
+	if wdb.rescans[sub.uh] != sub {
+		return // the entry caught up already, or was removed
+	}
+
+	coinOutputs := consensusChangeCoinOutputs(css)
+	synced := css.ID == wdb.stats.ConsensusChangeID
+	err := wdb.db.Update(func(tx *bolt.Tx) (err error) {
+		for _, block := range css.RevertedBlocks {
+			if sub.blockCount == 0 {
+				return errors.New("cannot revert a block: no blocks are applied")
+			}
+			sub.blockCount--
+			if height := rivinetypes.BlockHeight(sub.blockCount); height >= sub.startHeight {
+				err = wdb.revertBlock(tx, sub.watched, block, height)
+				if err != nil {
+					return fmt.Errorf("failed to revert block: %v", err)
+				}
+			}
+		}
+		for _, block := range css.AppliedBlocks {
+			if height := rivinetypes.BlockHeight(sub.blockCount); height >= sub.startHeight {
+				err = wdb.applyBlock(tx, sub.watched, block, height, coinOutputs)
+				if err != nil {
+					return fmt.Errorf("failed to apply block: %v", err)
+				}
+			}
+			sub.blockCount++
+		}
+		if !synced {
+			return nil
+		}
+		// the entry caught up with all other entries
+		bucket := tx.Bucket(bucketWatchEntries)
+		key := encoding.Marshal(sub.uh)
+		var entry WatchEntry
+		err = encoding.Unmarshal(bucket.Get(key), &entry)
+		if err != nil {
+			return fmt.Errorf("corrupt watch DB: failed to decode entry %s: %v", sub.uh.String(), err)
+		}
+		entry.Rescanning = false
+		return bucket.Put(key, encoding.Marshal(entry))
+	})
+	if err != nil {
+		build.Critical(fmt.Sprintf("watchDB failed to rescan watch entry %s:", sub.uh.String()), err)
+		return
+	}
+	if synced {
+		delete(wdb.rescans, sub.uh)
+		wdb.watched[sub.uh] = struct{}{}
+		close(sub.synced)
+	}
+}
+
+// consensusChangeCoinOutputs collects all coin outputs that are created or spent by the given change,
+// as to know the address and value of the parent output of each coin input
+func consensusChangeCoinOutputs(css modules.ConsensusChange) map[rivinetypes.CoinOutputID]rivinetypes.CoinOutput {
+	coinOutputs := make(map[rivinetypes.CoinOutputID]rivinetypes.CoinOutput, len(css.CoinOutputDiffs))
+	for _, diff := range css.CoinOutputDiffs {
+		coinOutputs[diff.ID] = diff.CoinOutput
+	}
+	return coinOutputs
+}
+
+// applyBlock stores all outputs of the given watched entries created by the given block,
+// marks all outputs of the given watched entries spent by the given block as spent,
+// and stores all transactions relevant to the given watched entries
+func (wdb *WatchDB) applyBlock(tx *bolt.Tx, watched map[rivinetypes.UnlockHash]struct{}, block rivinetypes.Block, height rivinetypes.BlockHeight, coinOutputs map[rivinetypes.CoinOutputID]rivinetypes.CoinOutput) error {
+	if len(watched) == 0 {
+		return nil
+	}
+
+	// miner payouts
+	minerTxn := WatchTransaction{
+		ID:           rivinetypes.TransactionID(block.ID()),
+		BlockHeight:  height,
+		Timestamp:    block.Timestamp,
+		MinerPayouts: true,
+	}
+	relevant := make(map[rivinetypes.UnlockHash]struct{})
+	for i, mp := range block.MinerPayouts {
+		minerTxn.Outputs = append(minerTxn.Outputs, WatchFund{Address: mp.UnlockHash, Value: mp.Value})
+		if _, ok := watched[mp.UnlockHash]; !ok {
+			continue
+		}
+		relevant[mp.UnlockHash] = struct{}{}
+		err := wdb.storeOutput(tx, mp.UnlockHash, WatchOutput{
+			Kind:           WatchOutputKindCoin,
+			ID:             rivinetypes.OutputID(block.MinerPayoutID(uint64(i))),
+			Value:          mp.Value,
+			Condition:      rivinetypes.NewCondition(rivinetypes.NewUnlockHashCondition(mp.UnlockHash)),
+			TransactionID:  minerTxn.ID,
+			CreationHeight: height,
+			MaturityHeight: height + wdb.constants.MaturityDelay,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	err := wdb.storeTransaction(tx, relevant, minerTxn, 0)
+	if err != nil {
+		return err
+	}
+
+	// transactions
+	for ti, txn := range block.Transactions {
+		txnID := txn.ID()
+		watchTxn := WatchTransaction{
+			ID:          txnID,
+			BlockHeight: height,
+			Timestamp:   block.Timestamp,
+			Version:     txn.Version,
+			AtomicSwap:  types.IsAtomicSwapTransaction(txn),
+			MinerFees:   txn.MinerFees,
+		}
+		relevant := make(map[rivinetypes.UnlockHash]struct{})
+		spend := func(kind WatchOutputKind, id rivinetypes.OutputID) (*WatchOutput, error) {
+			output, uh, found, err := wdb.getOutput(tx, watched, kind, id)
+			if err != nil || !found {
+				return nil, err
+			}
+			relevant[uh] = struct{}{}
+			output.Spent, output.SpendTransactionID, output.SpendHeight = true, txnID, height
+			return &output, wdb.storeOutput(tx, uh, output)
+		}
+		for _, ci := range txn.CoinInputs {
+			output, err := spend(WatchOutputKindCoin, rivinetypes.OutputID(ci.ParentID))
+			if err != nil {
+				return err
+			}
+			fund := WatchFund{}
+			if output != nil {
+				fund.Address, fund.Value = output.Condition.UnlockHash(), output.Value
+			} else if co, ok := coinOutputs[ci.ParentID]; ok {
+				fund.Address, fund.Value = co.Condition.UnlockHash(), co.Value
+			}
+			watchTxn.Inputs = append(watchTxn.Inputs, fund)
+		}
+		for _, bsi := range txn.BlockStakeInputs {
+			_, err := spend(WatchOutputKindBlockStake, rivinetypes.OutputID(bsi.ParentID))
+			if err != nil {
+				return err
+			}
+		}
+		for i, co := range txn.CoinOutputs {
+			uh := co.Condition.UnlockHash()
+			watchTxn.Outputs = append(watchTxn.Outputs, WatchFund{Address: uh, Value: co.Value})
+			if _, ok := watched[uh]; !ok {
+				continue
+			}
+			relevant[uh] = struct{}{}
+			err := wdb.storeOutput(tx, uh, WatchOutput{
+				Kind:           WatchOutputKindCoin,
+				ID:             rivinetypes.OutputID(txn.CoinOutputID(uint64(i))),
+				Value:          co.Value,
+				Condition:      co.Condition,
+				TransactionID:  txnID,
+				CreationHeight: height,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for i, bso := range txn.BlockStakeOutputs {
+			uh := bso.Condition.UnlockHash()
+			if _, ok := watched[uh]; !ok {
+				continue
+			}
+			relevant[uh] = struct{}{}
+			err := wdb.storeOutput(tx, uh, WatchOutput{
+				Kind:           WatchOutputKindBlockStake,
+				ID:             rivinetypes.OutputID(txn.BlockStakeOutputID(uint64(i))),
+				Value:          bso.Value,
+				Condition:      bso.Condition,
+				TransactionID:  txnID,
+				CreationHeight: height,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err := wdb.storeTransaction(tx, relevant, watchTxn, uint64(ti)+1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revertBlock deletes all outputs and transactions of the given watched entries created by the given block,
+// and marks all outputs of the given watched entries spent by the given block as unspent
+func (wdb *WatchDB) revertBlock(tx *bolt.Tx, watched map[rivinetypes.UnlockHash]struct{}, block rivinetypes.Block, height rivinetypes.BlockHeight) error {
+	if len(watched) == 0 {
+		return nil
+	}
+
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		txn := &block.Transactions[i]
+		for j := range txn.CoinOutputs {
+			err := wdb.deleteOutput(tx, watched, WatchOutputKindCoin, rivinetypes.OutputID(txn.CoinOutputID(uint64(j))))
+			if err != nil {
+				return err
+			}
+		}
+		for j := range txn.BlockStakeOutputs {
+			err := wdb.deleteOutput(tx, watched, WatchOutputKindBlockStake, rivinetypes.OutputID(txn.BlockStakeOutputID(uint64(j))))
+			if err != nil {
+				return err
+			}
+		}
+		unspend := func(kind WatchOutputKind, id rivinetypes.OutputID) error {
+			output, uh, found, err := wdb.getOutput(tx, watched, kind, id)
+			if err != nil || !found {
+				return err
+			}
+			output.Spent, output.SpendTransactionID, output.SpendHeight = false, rivinetypes.TransactionID{}, 0
+			return wdb.storeOutput(tx, uh, output)
+		}
+		for _, ci := range txn.CoinInputs {
+			err := unspend(WatchOutputKindCoin, rivinetypes.OutputID(ci.ParentID))
+			if err != nil {
+				return err
+			}
+		}
+		for _, bsi := range txn.BlockStakeInputs {
+			err := unspend(WatchOutputKindBlockStake, rivinetypes.OutputID(bsi.ParentID))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for i := range block.MinerPayouts {
+		err := wdb.deleteOutput(tx, watched, WatchOutputKindCoin, rivinetypes.OutputID(block.MinerPayoutID(uint64(i))))
+		if err != nil {
+			return err
+		}
+	}
+
+	// delete all transactions stored for this block
+	txns := tx.Bucket(bucketWatchTransactions)
+	for uh := range watched {
+		prefix := append(encoding.Marshal(uh), encodeBlockheight(height)...)
+		for _, key := range prefixedKeys(txns, prefix) {
+			err := txns.Delete(key)
+			if err != nil {
+				return fmt.Errorf("failed to delete watched transaction %x: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// getOutput returns the stored output of the given kind and ID, together with the unlock hash of its entry,
+// found is false in case it isn't an output of one of the given watched entries
+func (wdb *WatchDB) getOutput(tx *bolt.Tx, watched map[rivinetypes.UnlockHash]struct{}, kind WatchOutputKind, id rivinetypes.OutputID) (output WatchOutput, uh rivinetypes.UnlockHash, found bool, err error) {
+	indexKey := watchOutputIndexKey(kind, id)
+	uhb := tx.Bucket(bucketWatchOutputIndex).Get(indexKey)
+	if len(uhb) == 0 {
+		return WatchOutput{}, rivinetypes.UnlockHash{}, false, nil // not a watched output
+	}
+	err = encoding.Unmarshal(uhb, &uh)
+	if err != nil {
+		return WatchOutput{}, rivinetypes.UnlockHash{}, false, fmt.Errorf("corrupt watch DB: failed to decode unlock hash %x: %v", uhb, err)
+	}
+	if _, ok := watched[uh]; !ok {
+		return WatchOutput{}, rivinetypes.UnlockHash{}, false, nil // output of another entry
+	}
+	b := tx.Bucket(bucketWatchOutputs).Get(append(append([]byte{}, uhb...), indexKey...))
+	if len(b) == 0 {
+		return WatchOutput{}, rivinetypes.UnlockHash{}, false, fmt.Errorf("corrupt watch DB: indexed output %x could not be found", indexKey)
+	}
+	err = encoding.Unmarshal(b, &output)
+	if err != nil {
+		return WatchOutput{}, rivinetypes.UnlockHash{}, false, fmt.Errorf("corrupt watch DB: failed to decode output %x: %v", indexKey, err)
+	}
+	return output, uh, true, nil
+}
+
+// storeOutput stores (or overwrites) the given output of the given entry, and indexes it
+func (wdb *WatchDB) storeOutput(tx *bolt.Tx, uh rivinetypes.UnlockHash, output WatchOutput) error {
+	uhb, indexKey := encoding.Marshal(uh), watchOutputIndexKey(output.Kind, output.ID)
+	err := tx.Bucket(bucketWatchOutputs).Put(append(append([]byte{}, uhb...), indexKey...), encoding.Marshal(output))
+	if err != nil {
+		return fmt.Errorf("failed to store watched output %x: %v", indexKey, err)
+	}
+	err = tx.Bucket(bucketWatchOutputIndex).Put(indexKey, uhb)
+	if err != nil {
+		return fmt.Errorf("failed to index watched output %x: %v", indexKey, err)
+	}
+	return nil
+}
+
+// deleteOutput deletes the output of the given kind and ID,
+// it is a no-op in case it isn't an output of one of the given watched entries
+func (wdb *WatchDB) deleteOutput(tx *bolt.Tx, watched map[rivinetypes.UnlockHash]struct{}, kind WatchOutputKind, id rivinetypes.OutputID) error {
+	_, uh, found, err := wdb.getOutput(tx, watched, kind, id)
+	if err != nil || !found {
+		return err
+	}
+	indexKey := watchOutputIndexKey(kind, id)
+	err = tx.Bucket(bucketWatchOutputs).Delete(append(encoding.Marshal(uh), indexKey...))
+	if err != nil {
+		return fmt.Errorf("failed to delete watched output %x: %v", indexKey, err)
+	}
+	return tx.Bucket(bucketWatchOutputIndex).Delete(indexKey)
+}
+
+// storeTransaction stores the given transaction for all given (relevant) entries
+func (wdb *WatchDB) storeTransaction(tx *bolt.Tx, relevant map[rivinetypes.UnlockHash]struct{}, txn WatchTransaction, sequence uint64) error {
+	if len(relevant) == 0 {
+		return nil
+	}
+	b := encoding.Marshal(txn)
+	txns := tx.Bucket(bucketWatchTransactions)
+	for uh := range relevant {
+		key := append(encoding.Marshal(uh), encodeBlockheight(txn.BlockHeight)...)
+		key = append(key, encodeBlockheight(rivinetypes.BlockHeight(sequence))...)
+		err := txns.Put(key, b)
+		if err != nil {
+			return fmt.Errorf("failed to store watched transaction %s: %v", txn.ID.String(), err)
+		}
+	}
+	return nil
+}
+
+// lastBlockTimestamp returns the timestamp of the last applied block,
+// or zero in case no blocks are applied
+func (wdb *WatchDB) lastBlockTimestamp(tx *bolt.Tx) (rivinetypes.Timestamp, error) {
+	if wdb.stats.BlockCount == 0 {
+		return 0, nil
+	}
+	height := rivinetypes.BlockHeight(wdb.stats.BlockCount - 1)
+	b := tx.Bucket(bucketWatchBlockTimestamps).Get(encodeBlockheight(height))
+	if len(b) == 0 {
+		return 0, fmt.Errorf("corrupt watch DB: no timestamp stored for block height %d", height)
+	}
+	var timestamp rivinetypes.Timestamp
+	err := encoding.Unmarshal(b, &timestamp)
+	if err != nil {
+		return 0, fmt.Errorf("corrupt watch DB: failed to decode timestamp of block height %d: %v", height, err)
+	}
+	return timestamp, nil
+}
+
+// storeStats stores the in-memory stats of the WatchDB in the internal bucket
+func (wdb *WatchDB) storeStats(tx *bolt.Tx) error {
+	err := tx.Bucket(bucketInternal).Put(bucketInternalKeyStats, encoding.Marshal(wdb.stats))
+	if err != nil {
+		return fmt.Errorf("failed to store watch db (blocks=%d; changeID=%x; synced=%v) as a stat: %v",
+			wdb.stats.BlockCount, wdb.stats.ConsensusChangeID, wdb.stats.Synced, err)
+	}
+	return nil
+}
+
+// deleteWatchEntryData deletes all outputs and transactions stored for the given entry
+func deleteWatchEntryData(tx *bolt.Tx, uh rivinetypes.UnlockHash) error {
+	prefix := encoding.Marshal(uh)
+	outputs, index := tx.Bucket(bucketWatchOutputs), tx.Bucket(bucketWatchOutputIndex)
+	for _, key := range prefixedKeys(outputs, prefix) {
+		err := index.Delete(key[len(prefix):])
+		if err != nil {
+			return err
+		}
+		err = outputs.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	txns := tx.Bucket(bucketWatchTransactions)
+	for _, key := range prefixedKeys(txns, prefix) {
+		err := txns.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchOutputIndexKey returns the key used to index an output
+func watchOutputIndexKey(kind WatchOutputKind, id rivinetypes.OutputID) []byte {
+	return append([]byte{byte(kind)}, id[:]...)
+}
+
+// prefixedKeys returns (copies of) all keys of the given bucket which start with the given prefix
+func prefixedKeys(bucket *bolt.Bucket, prefix []byte) [][]byte {
+	var keys [][]byte
+	cursor := bucket.Cursor()
+	for k, _ := cursor.Seek(prefix); len(k) > 0 && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+		keys = append(keys, append([]byte{}, k...))
+	}
+	return keys
+}
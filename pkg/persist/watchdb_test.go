@@ -0,0 +1,217 @@
+package persist
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rivine/rivine/modules"
+	"github.com/rivine/rivine/types"
+)
+
+func TestWatchDBRescan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watchdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wdb, err := NewWatchDB(dir, types.DefaultChainConstants())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wdb.Close()
+
+	var a, b types.UnlockHash
+	a.Type, a.Hash[0] = types.UnlockTypePubKey, 1
+	b.Type, b.Hash[0] = types.UnlockTypePubKey, 2
+	const t0 = types.Timestamp(1e9)
+	newBlock := func(height int, payout types.UnlockHash, value uint64) types.Block {
+		block := types.Block{
+			Timestamp:    t0 + types.Timestamp(height),
+			MinerPayouts: []types.MinerPayout{{Value: types.NewCurrency64(value), UnlockHash: payout}},
+		}
+		block.ParentID[0] = byte(height)
+		return block
+	}
+	blocks := []types.Block{
+		newBlock(0, a, 10),
+		newBlock(1, b, 20),
+		newBlock(2, b, 30),
+		newBlock(3, b, 40),
+	}
+	// block 2 also sends the miner payout of a (block 0) to b
+	parentID := blocks[0].MinerPayoutID(0)
+	blocks[2].Transactions = []types.Transaction{{
+		CoinInputs:  []types.CoinInput{{ParentID: parentID}},
+		CoinOutputs: []types.CoinOutput{{Value: types.NewCurrency64(10), Condition: types.NewCondition(types.NewUnlockHashCondition(b))}},
+	}}
+
+	cs := new(testConsensusSet)
+	cs.apply(modules.ConsensusChange{AppliedBlocks: blocks[:1]})
+	err = wdb.SubscribeToConsensusSet(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wdb.AddEntry(WatchEntry{UnlockHash: a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForWatchDBRescan(t, wdb, a)
+	cs.apply(modules.ConsensusChange{AppliedBlocks: blocks[1:2]})
+	cs.apply(modules.ConsensusChange{
+		AppliedBlocks: blocks[2:3],
+		CoinOutputDiffs: []modules.CoinOutputDiff{{
+			Direction:  modules.DiffRevert,
+			ID:         parentID,
+			CoinOutput: types.CoinOutput{Value: types.NewCurrency64(10), Condition: types.NewCondition(types.NewUnlockHashCondition(a))},
+		}},
+	})
+
+	// a new entry is rescanned in the background, while new blocks keep being applied,
+	// ignoring all blocks prior to its start height
+	err = wdb.AddEntry(WatchEntry{UnlockHash: b, StartHeight: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.apply(modules.ConsensusChange{AppliedBlocks: blocks[3:]})
+	waitForWatchDBRescan(t, wdb, b)
+	checkWatchOutputs(t, wdb, b, []uint64{30, 10, 40}, nil)
+	checkWatchTransactions(t, wdb, b, []types.BlockHeight{2, 2, 3})
+
+	// the outputs and transactions of the other entries are untouched
+	checkWatchOutputs(t, wdb, a, []uint64{10}, []bool{true})
+	checkWatchTransactions(t, wdb, a, []types.BlockHeight{0, 2})
+
+	// reverting a block without applying any should restore the timestamp of the new tip
+	if ctx := wdb.FulfillableContext(); ctx.BlockHeight != 3 || ctx.BlockTime != blocks[3].Timestamp {
+		t.Errorf("unexpected context: %+v", ctx)
+	}
+	cs.apply(modules.ConsensusChange{RevertedBlocks: blocks[3:]})
+	if ctx := wdb.FulfillableContext(); ctx.BlockHeight != 2 || ctx.BlockTime != blocks[2].Timestamp {
+		t.Errorf("unexpected context after reverting a block: %+v", ctx)
+	}
+	checkWatchOutputs(t, wdb, b, []uint64{30, 10}, nil)
+	checkWatchTransactions(t, wdb, b, []types.BlockHeight{2, 2})
+
+	// removing an entry leaves the other entries untouched
+	err = wdb.RemoveEntry(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkWatchOutputs(t, wdb, b, nil, nil)
+	checkWatchOutputs(t, wdb, a, []uint64{10}, []bool{true})
+}
+
+// waitForWatchDBRescan waits until the given entry caught up with the blockchain
+func waitForWatchDBRescan(t *testing.T, wdb *WatchDB, uh types.UnlockHash) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		entry, err := wdb.Entry(uh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !entry.Rescanning {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("entry %s is still being rescanned", uh.String())
+		}
+	}
+}
+
+// checkWatchOutputs checks the values of the coin outputs of the given entry, in any order,
+// as well as whether or not they are spent, in case spent is given
+func checkWatchOutputs(t *testing.T, wdb *WatchDB, uh types.UnlockHash, values []uint64, spent []bool) {
+	t.Helper()
+	outputs, err := wdb.Outputs(uh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != len(values) {
+		t.Fatalf("expected %d outputs, not %d", len(values), len(outputs))
+	}
+	found := make(map[uint64]bool)
+	for _, output := range outputs {
+		for idx, value := range values {
+			if output.Value.Equals64(value) {
+				found[value] = true
+				if spent != nil && output.Spent != spent[idx] {
+					t.Errorf("expected output of %d to have spent status %v", value, spent[idx])
+				}
+			}
+		}
+	}
+	for _, value := range values {
+		if !found[value] {
+			t.Errorf("expected an output of %d", value)
+		}
+	}
+}
+
+// checkWatchTransactions checks the block heights of the transactions of the given entry
+func checkWatchTransactions(t *testing.T, wdb *WatchDB, uh types.UnlockHash, heights []types.BlockHeight) {
+	t.Helper()
+	txns, err := wdb.Transactions(uh, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != len(heights) {
+		t.Fatalf("expected %d transactions, not %d", len(heights), len(txns))
+	}
+	for idx, txn := range txns {
+		if txn.BlockHeight != heights[idx] {
+			t.Errorf("transaction #%d: expected block height %d, not %d", idx, heights[idx], txn.BlockHeight)
+		}
+	}
+}
+
+// testConsensusSet delivers its consensus changes to its subscribers,
+// replaying all prior changes to new subscribers while locked, as the consensus set does
+type testConsensusSet struct {
+	modules.ConsensusSet
+	mu          sync.Mutex
+	changes     []modules.ConsensusChange
+	subscribers []modules.ConsensusSetSubscriber
+}
+
+func (cs *testConsensusSet) apply(cc modules.ConsensusChange) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cc.ID[0] = byte(len(cs.changes) + 2)
+	cs.changes = append(cs.changes, cc)
+	for _, subscriber := range cs.subscribers {
+		subscriber.ProcessConsensusChange(cc)
+	}
+}
+
+func (cs *testConsensusSet) ConsensusSetSubscribe(subscriber modules.ConsensusSetSubscriber, start modules.ConsensusChangeID, cancel <-chan struct{}) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if start != modules.ConsensusChangeBeginning {
+		return errors.New("can only subscribe from the beginning")
+	}
+	for _, cc := range cs.changes {
+		select {
+		case <-cancel:
+			return errors.New("subscription cancelled")
+		default:
+			subscriber.ProcessConsensusChange(cc)
+		}
+	}
+	cs.subscribers = append(cs.subscribers, subscriber)
+	return nil
+}
+
+func (cs *testConsensusSet) Unsubscribe(subscriber modules.ConsensusSetSubscriber) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for idx := range cs.subscribers {
+		if cs.subscribers[idx] == subscriber {
+			cs.subscribers = append(cs.subscribers[:idx], cs.subscribers[idx+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package signer
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/crypto"
+	rivinetypes "github.com/rivine/rivine/types"
+)
+
+// FileSigner is the reference implementation of an external signer Handler,
+// which signs using the ed25519 secret keys read from a keyfile.
+//
+// It is meant as an example and for testing purposes,
+// production signers are expected to keep their keys in an HSM or similar device.
+type FileSigner struct {
+	keys []crypto.SecretKey
+	// Review, if defined, is called prior to signing,
+	// and can reject the request by returning an error
+	Review func(request types.SignRequest) error
+}
+
+// NewFileSigner creates a file-based signer,
+// using the keys of the given keyfile (see ReadKeyFile).
+func NewFileSigner(path string) (*FileSigner, error) {
+	keys, err := ReadKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSigner{keys: keys}, nil
+}
+
+// PublicKeys implements Handler.PublicKeys
+func (fs *FileSigner) PublicKeys() ([]rivinetypes.SiaPublicKey, error) {
+	pks := make([]rivinetypes.SiaPublicKey, 0, len(fs.keys))
+	for _, sk := range fs.keys {
+		pks = append(pks, rivinetypes.Ed25519PublicKey(sk.PublicKey()))
+	}
+	return pks, nil
+}
+
+// SignHash implements Handler.SignHash
+func (fs *FileSigner) SignHash(request types.SignRequest) (crypto.Signature, error) {
+	for _, sk := range fs.keys {
+		pk := rivinetypes.Ed25519PublicKey(sk.PublicKey())
+		if pk.String() != request.PublicKey.String() {
+			continue
+		}
+		if fs.Review != nil {
+			err := fs.Review(request)
+			if err != nil {
+				return crypto.Signature{}, fmt.Errorf("sign request rejected: %v", err)
+			}
+		}
+		return crypto.SignHash(request.SigHash, sk), nil
+	}
+	return crypto.Signature{}, fmt.Errorf("no secret key found for public key %s", request.PublicKey.String())
+}
+
+// ReadKeyFile reads all hex-encoded ed25519 secret keys from the given file,
+// one key per line, ignoring empty lines and lines starting with '#'.
+func ReadKeyFile(path string) ([]crypto.SecretKey, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var keys []crypto.SecretKey
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		b, err := hex.DecodeString(line)
+		if err != nil || len(b) != crypto.SecretKeySize {
+			return nil, fmt.Errorf("line %d does not contain a hex-encoded ed25519 secret key", lineNumber)
+		}
+		var sk crypto.SecretKey
+		copy(sk[:], b)
+		keys = append(keys, sk)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys found in %s", path)
+	}
+	return keys, nil
+}
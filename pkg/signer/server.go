@@ -0,0 +1,131 @@
+package signer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/crypto"
+	rivinetypes "github.com/rivine/rivine/types"
+)
+
+// Handler handles the requests received by an external signer.
+type Handler interface {
+	// PublicKeys returns the public keys the handler can sign for.
+	PublicKeys() ([]rivinetypes.SiaPublicKey, error)
+	// SignHash signs the signature hash of the given request,
+	// using the secret key paired with the public key of the request.
+	SignHash(request types.SignRequest) (crypto.Signature, error)
+}
+
+// Handle handles a single request, using the given handler.
+// Errors are returned as part of the response.
+func Handle(h Handler, request Request) Response {
+	if request.Version != ProtocolVersion {
+		return Response{Error: fmt.Sprintf("unsupported protocol version %d", request.Version)}
+	}
+	switch request.Type {
+	case RequestTypePublicKeys:
+		pks, err := h.PublicKeys()
+		if err != nil {
+			return Response{Error: err.Error()}
+		}
+		return Response{PublicKeys: pks}
+	case RequestTypeSign:
+		if request.Sign == nil {
+			return Response{Error: "sign request is not defined"}
+		}
+		signature, err := h.SignHash(*request.Sign)
+		if err != nil {
+			return Response{Error: err.Error()}
+		}
+		return Response{Signature: rivinetypes.ByteSlice(signature[:])}
+	default:
+		return Response{Error: fmt.Sprintf("unsupported request type %q", request.Type)}
+	}
+}
+
+// Serve reads a single request from the given reader,
+// and writes the response of the given handler to the given writer.
+// It is used to implement an executable external signer, using STDIN and STDOUT.
+func Serve(r io.Reader, w io.Writer, h Handler) error {
+	var request Request
+	err := json.NewDecoder(r).Decode(&request)
+	if err != nil {
+		return fmt.Errorf("failed to decode request: %v", err)
+	}
+	return json.NewEncoder(w).Encode(Handle(h, request))
+}
+
+// Server serves requests received over a (unix socket) listener.
+type Server struct {
+	listener net.Listener
+	handler  Handler
+
+	handleMu sync.Mutex // serializes the handling of requests
+	mu       sync.Mutex
+	closed   bool
+}
+
+// NewServer creates a server, serving requests received over the given listener,
+// using the given handler. Requests are only served once Serve is called.
+func NewServer(listener net.Listener, h Handler) *Server {
+	return &Server{
+		listener: listener,
+		handler:  h,
+	}
+}
+
+// Serve accepts connections until the server is closed,
+// serving all newline-delimited requests received over each connection.
+// Requests are handled one at a time, such that a human reviewing them
+// never has to handle multiple requests at once.
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			scanner := bufio.NewScanner(conn)
+			encoder := json.NewEncoder(conn)
+			for scanner.Scan() {
+				var (
+					request Request
+					resp    Response
+				)
+				err := json.Unmarshal(scanner.Bytes(), &request)
+				if err != nil {
+					resp = Response{Error: fmt.Sprintf("failed to decode request: %v", err)}
+				} else {
+					s.handleMu.Lock()
+					resp = Handle(s.handler, request)
+					s.handleMu.Unlock()
+				}
+				if encoder.Encode(resp) != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Close stops the server from accepting new connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return s.listener.Close()
+}
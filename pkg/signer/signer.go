@@ -0,0 +1,265 @@
+// Package signer defines the external signer protocol,
+// which allows tfchain transactions to be signed by a process
+// other than the one creating (and signing) the transaction,
+// such that secret keys (e.g. the keys of the minters) never have to be loaded into that process.
+//
+// An external signer is either a local executable, which receives a single JSON-encoded request
+// over its STDIN and writes a single JSON-encoded response to its STDOUT,
+// or a process listening on a unix socket, which receives newline-delimited JSON-encoded requests
+// and answers each of them with a newline-delimited JSON-encoded response.
+//
+// Two types of requests are supported: listing the public keys the signer can sign for,
+// and signing a signature hash (using one of those keys), for which the signer also receives
+// a human-readable summary of the transaction, such that it can be reviewed prior to signing.
+package signer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/crypto"
+	rivinetypes "github.com/rivine/rivine/types"
+)
+
+// ProtocolVersion is the (only) supported version of the external signer protocol.
+const ProtocolVersion uint8 = 1
+
+// RequestType defines the type of a request sent to an external signer.
+type RequestType string
+
+// All supported request types.
+const (
+	// RequestTypePublicKeys requests the public keys the signer can sign for
+	RequestTypePublicKeys RequestType = "publickeys"
+	// RequestTypeSign requests the signer to sign a signature hash
+	RequestTypeSign RequestType = "sign"
+)
+
+// DefaultTimeout is the default time a client waits for a signer to respond,
+// which is long, as a signer might require human interaction prior to signing.
+const DefaultTimeout = 5 * time.Minute
+
+type (
+	// Request is a request sent to an external signer.
+	Request struct {
+		Version uint8       `json:"version"`
+		Type    RequestType `json:"type"`
+		// Sign is only defined for requests of type sign
+		Sign *types.SignRequest `json:"sign,omitempty"`
+	}
+
+	// Response is the response of an external signer to a request.
+	// The error is only defined in case the request failed,
+	// in which case no other fields are defined.
+	Response struct {
+		// PublicKeys is only defined as a response to a request of type publickeys
+		PublicKeys []rivinetypes.SiaPublicKey `json:"publickeys,omitempty"`
+		// Signature is only defined as a response to a request of type sign
+		Signature rivinetypes.ByteSlice `json:"signature,omitempty"`
+		Error     string                `json:"error,omitempty"`
+	}
+)
+
+// Client sends requests to an external signer.
+type Client struct {
+	// either a command or socket path is defined
+	command []string
+	socket  string
+	// Timeout defines how long the client waits for the signer to respond,
+	// no timeout is applied if it is 0
+	Timeout time.Duration
+}
+
+// NewExecClient creates a client for an external signer,
+// which is executed for each request, using the given command and arguments.
+func NewExecClient(command string, args ...string) *Client {
+	return &Client{
+		command: append([]string{command}, args...),
+		Timeout: DefaultTimeout,
+	}
+}
+
+// NewSocketClient creates a client for an external signer,
+// which listens on the given unix socket.
+func NewSocketClient(path string) *Client {
+	return &Client{
+		socket:  path,
+		Timeout: DefaultTimeout,
+	}
+}
+
+// Open creates a client for an external signer, defined as a string,
+// either as "unix:<socket path>" or as "exec:<command>".
+// The command is never split, its arguments are passed as args instead,
+// which can only be defined for an executable signer.
+func Open(str string, args ...string) (*Client, error) {
+	switch {
+	case strings.HasPrefix(str, "unix:"):
+		path := strings.TrimPrefix(strings.TrimPrefix(str, "unix:"), "//")
+		if path == "" {
+			return nil, errors.New("no unix socket path defined for external signer")
+		}
+		if len(args) > 0 {
+			return nil, errors.New("arguments can only be defined for an executable external signer")
+		}
+		return NewSocketClient(path), nil
+	case strings.HasPrefix(str, "exec:"):
+		command := strings.TrimPrefix(str, "exec:")
+		if command == "" {
+			return nil, errors.New("no command defined for external signer")
+		}
+		return NewExecClient(command, args...), nil
+	default:
+		return nil, fmt.Errorf("invalid external signer %q: expected unix:<socket path> or exec:<command>", str)
+	}
+}
+
+// PublicKeys returns the public keys the external signer can sign for.
+func (c *Client) PublicKeys() ([]rivinetypes.SiaPublicKey, error) {
+	resp, err := c.Do(Request{Type: RequestTypePublicKeys})
+	if err != nil {
+		return nil, err
+	}
+	return resp.PublicKeys, nil
+}
+
+// SignHash requests the external signer to sign the given request.
+func (c *Client) SignHash(request types.SignRequest) (crypto.Signature, error) {
+	resp, err := c.Do(Request{Type: RequestTypeSign, Sign: &request})
+	if err != nil {
+		return crypto.Signature{}, err
+	}
+	var signature crypto.Signature
+	if len(resp.Signature) != len(signature) {
+		return crypto.Signature{}, fmt.Errorf("external signer returned a signature of invalid size %d", len(resp.Signature))
+	}
+	copy(signature[:], resp.Signature)
+	return signature, nil
+}
+
+// Signers returns a types.Signer for each public key the external signer can sign for.
+func (c *Client) Signers() ([]types.Signer, error) {
+	pks, err := c.PublicKeys()
+	if err != nil {
+		return nil, err
+	}
+	signers := make([]types.Signer, 0, len(pks))
+	for _, pk := range pks {
+		signers = append(signers, &keySigner{client: c, pk: pk})
+	}
+	return signers, nil
+}
+
+// Do sends the given request to the external signer and returns its response,
+// returning the error of the response, if the signer returned one, as an error.
+func (c *Client) Do(request Request) (Response, error) {
+	request.Version = ProtocolVersion
+	var (
+		resp Response
+		err  error
+	)
+	if c.socket != "" {
+		resp, err = c.doSocket(request)
+	} else {
+		resp, err = c.doExec(request)
+	}
+	if err != nil {
+		return Response{}, fmt.Errorf("external signer failed: %v", err)
+	}
+	if resp.Error != "" {
+		return Response{}, fmt.Errorf("external signer returned an error: %s", resp.Error)
+	}
+	return resp, nil
+}
+
+// doExec executes the signer command, passing the request over its STDIN
+func (c *Client) doExec(request Request) (Response, error) {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return Response{}, err
+	}
+	cmd := exec.Command(c.command[0], c.command[1:]...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Start()
+	if err != nil {
+		return Response{}, err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	var timeout <-chan time.Time
+	if c.Timeout > 0 {
+		timeout = time.After(c.Timeout)
+	}
+	select {
+	case err = <-done:
+	case <-timeout:
+		cmd.Process.Kill()
+		<-done
+		return Response{}, errors.New("timed out waiting for a response")
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return Response{}, fmt.Errorf("%v: %s", err, msg)
+		}
+		return Response{}, err
+	}
+	var resp Response
+	err = json.Unmarshal(stdout.Bytes(), &resp)
+	if err != nil {
+		return Response{}, fmt.Errorf("invalid response: %v", err)
+	}
+	return resp, nil
+}
+
+// doSocket sends the request over a new connection to the unix socket of the signer
+func (c *Client) doSocket(request Request) (Response, error) {
+	conn, err := net.Dial("unix", c.socket)
+	if err != nil {
+		return Response{}, err
+	}
+	defer conn.Close()
+	if c.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+	err = json.NewEncoder(conn).Encode(request)
+	if err != nil {
+		return Response{}, err
+	}
+	var resp Response
+	err = json.NewDecoder(conn).Decode(&resp)
+	if err == io.EOF {
+		return Response{}, errors.New("connection closed without a response")
+	}
+	if err != nil {
+		return Response{}, fmt.Errorf("invalid response: %v", err)
+	}
+	return resp, nil
+}
+
+// keySigner implements types.Signer for a single key of an external signer
+type keySigner struct {
+	client *Client
+	pk     rivinetypes.SiaPublicKey
+}
+
+// PublicKey implements types.Signer.PublicKey
+func (s *keySigner) PublicKey() rivinetypes.SiaPublicKey {
+	return s.pk
+}
+
+// SignHash implements types.Signer.SignHash
+func (s *keySigner) SignHash(request types.SignRequest) (crypto.Signature, error) {
+	return s.client.SignHash(request)
+}
@@ -0,0 +1,266 @@
+package signer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/threefoldfoundation/tfchain/pkg/types"
+
+	"github.com/rivine/rivine/crypto"
+	rivinetypes "github.com/rivine/rivine/types"
+)
+
+// the test binary acts as an executable external signer,
+// in case this environment variable defines the keyfile to sign with
+const envHelperKeyFile = "TFCHAIN_TEST_SIGNER_KEYFILE"
+
+func TestMain(m *testing.M) {
+	if path := os.Getenv(envHelperKeyFile); path != "" {
+		fs, err := NewFileSigner(path)
+		if err == nil {
+			err = Serve(os.Stdin, os.Stdout, fs)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestSocketSignerCoinCreationTransaction(t *testing.T) {
+	rivinetypes.RegisterTransactionVersion(types.TransactionVersionCoinCreation, types.CoinCreationTransactionController{})
+	defer rivinetypes.RegisterTransactionVersion(types.TransactionVersionCoinCreation, nil)
+
+	dir, keys := testKeyFile(t, 2)
+	defer os.RemoveAll(dir)
+	fs, err := NewFileSigner(filepath.Join(dir, "keys"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summaries []string
+	fs.Review = func(request types.SignRequest) error {
+		summaries = append(summaries, request.Summary)
+		return nil
+	}
+	listener, err := net.Listen("unix", filepath.Join(dir, "signer.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(listener, fs)
+	go server.Serve()
+	defer server.Close()
+
+	client, err := Open("unix:" + filepath.Join(dir, "signer.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers, err := client.Signers()
+	if err != nil {
+		t.Fatal("failed to get signers: ", err)
+	}
+	if len(signers) != 2 {
+		t.Fatalf("expected 2 signers, but got %d", len(signers))
+	}
+
+	mintCondition := rivinetypes.NewCondition(rivinetypes.NewMultiSignatureCondition(rivinetypes.UnlockHashSlice{
+		testUnlockHash(keys[0]), testUnlockHash(keys[1]),
+	}, 2))
+	cctx := types.CoinCreationTransaction{
+		Nonce: types.RandomTransactionNonce(),
+		CoinOutputs: []rivinetypes.CoinOutput{
+			{
+				Value:     rivinetypes.NewCurrency64(42),
+				Condition: rivinetypes.NewCondition(rivinetypes.NewUnlockHashCondition(testUnlockHash(keys[0]))),
+			},
+		},
+		MinerFees: []rivinetypes.Currency{rivinetypes.NewCurrency64(1)},
+	}
+	pstx := types.NewPartiallySignedTransaction(cctx.Transaction())
+	pstx.MintCondition = &mintCondition
+	pstx.Description = "monthly minting"
+	for _, signer := range signers {
+		n, err := pstx.SignWith(signer)
+		if err != nil || n != 1 {
+			t.Fatalf("expected 1 signature to be added, but got %d (err: %v)", n, err)
+		}
+	}
+	txn, err := pstx.Finalize(rivinetypes.FulfillableContext{})
+	if err != nil {
+		t.Fatal("failed to finalize transaction: ", err)
+	}
+	err = mintCondition.Fulfill(txn.Extension.(*types.CoinCreationTransactionExtension).MintFulfillment, rivinetypes.FulfillContext{
+		Transaction: txn,
+	})
+	if err != nil {
+		t.Fatal("expected the signed transaction to fulfill the mint condition: ", err)
+	}
+
+	if len(summaries) != 2 {
+		t.Fatalf("expected 2 sign requests to be reviewed, but got %d", len(summaries))
+	}
+	for _, summary := range summaries {
+		for _, expected := range []string{"coin creation transaction", "mint fulfillment", "monthly minting", "0.000000042 TFT"} {
+			if !strings.Contains(summary, expected) {
+				t.Errorf("expected summary to contain %q, but it doesn't: %s", expected, summary)
+			}
+		}
+	}
+}
+
+func TestExecSignerRegularTransaction(t *testing.T) {
+	dir, keys := testKeyFile(t, 1)
+	defer os.RemoveAll(dir)
+	os.Setenv(envHelperKeyFile, filepath.Join(dir, "keys"))
+	defer os.Unsetenv(envHelperKeyFile)
+
+	client := NewExecClient(os.Args[0])
+	signers, err := client.Signers()
+	if err != nil {
+		t.Fatal("failed to get signers: ", err)
+	}
+	if len(signers) != 1 {
+		t.Fatalf("expected 1 signer, but got %d", len(signers))
+	}
+
+	uh := testUnlockHash(keys[0])
+	pstx := types.NewPartiallySignedTransaction(rivinetypes.Transaction{
+		Version: rivinetypes.TransactionVersionOne,
+		CoinInputs: []rivinetypes.CoinInput{
+			{ParentID: rivinetypes.CoinOutputID{1}},
+			{ParentID: rivinetypes.CoinOutputID{2}},
+		},
+		CoinOutputs: []rivinetypes.CoinOutput{
+			{
+				Value:     rivinetypes.NewCurrency64(19),
+				Condition: rivinetypes.NewCondition(rivinetypes.NewUnlockHashCondition(uh)),
+			},
+		},
+		MinerFees: []rivinetypes.Currency{rivinetypes.NewCurrency64(1)},
+	})
+	for range pstx.Transaction.CoinInputs {
+		pstx.CoinInputs = append(pstx.CoinInputs, types.PSTXParentOutput{
+			Value:     rivinetypes.NewCurrency64(10),
+			Condition: rivinetypes.NewCondition(rivinetypes.NewUnlockHashCondition(uh)),
+		})
+	}
+	n, err := pstx.SignWith(signers[0])
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 signatures to be added, but got %d (err: %v)", n, err)
+	}
+	_, err = pstx.Finalize(rivinetypes.FulfillableContext{})
+	if err != nil {
+		t.Fatal("failed to finalize transaction: ", err)
+	}
+}
+
+func TestExecSignerFailure(t *testing.T) {
+	os.Setenv(envHelperKeyFile, filepath.Join(os.TempDir(), "tfchain-signer-nonexisting-keyfile"))
+	defer os.Unsetenv(envHelperKeyFile)
+
+	_, err := NewExecClient(os.Args[0]).PublicKeys()
+	if err == nil {
+		t.Fatal("expected signer without keyfile to fail")
+	}
+}
+
+func TestInvalidSignatureIsRejected(t *testing.T) {
+	sk, pk := crypto.GenerateKeyPairDeterministic([crypto.EntropySize]byte{1})
+	uh := rivinetypes.NewPubKeyUnlockHash(rivinetypes.Ed25519PublicKey(pk))
+	pstx := types.NewPartiallySignedTransaction(rivinetypes.Transaction{
+		Version: rivinetypes.TransactionVersionOne,
+		CoinInputs: []rivinetypes.CoinInput{
+			{ParentID: rivinetypes.CoinOutputID{1}},
+		},
+	})
+	pstx.CoinInputs = []types.PSTXParentOutput{
+		{Condition: rivinetypes.NewCondition(rivinetypes.NewUnlockHashCondition(uh))},
+	}
+	_, err := pstx.SignWith(badSigner{sk: sk})
+	if err == nil {
+		t.Fatal("expected an invalid signature to be rejected")
+	}
+	if pstx.Transaction.CoinInputs[0].Fulfillment.FulfillmentType() != rivinetypes.FulfillmentTypeNil {
+		t.Fatal("expected the fulfillment to remain unsigned")
+	}
+}
+
+// badSigner signs the wrong hash
+type badSigner struct {
+	sk crypto.SecretKey
+}
+
+func (s badSigner) PublicKey() rivinetypes.SiaPublicKey {
+	return rivinetypes.Ed25519PublicKey(s.sk.PublicKey())
+}
+
+func (s badSigner) SignHash(request types.SignRequest) (crypto.Signature, error) {
+	return crypto.SignHash(crypto.HashObject(request.SigHash), s.sk), nil
+}
+
+// testKeyFile creates a temporary directory, containing a keyfile (named keys),
+// with the given amount of keys
+func testKeyFile(t *testing.T, n int) (string, []crypto.SecretKey) {
+	dir, err := ioutil.TempDir("", "tfchain-signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		keys  []crypto.SecretKey
+		lines = []string{"# test keys", ""}
+	)
+	for i := 0; i < n; i++ {
+		sk, _ := crypto.GenerateKeyPairDeterministic([crypto.EntropySize]byte{byte(i + 1)})
+		keys = append(keys, sk)
+		lines = append(lines, hex.EncodeToString(sk[:]))
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "keys"), []byte(strings.Join(lines, "\n")), 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return dir, keys
+}
+
+func testUnlockHash(sk crypto.SecretKey) rivinetypes.UnlockHash {
+	return rivinetypes.NewPubKeyUnlockHash(rivinetypes.Ed25519PublicKey(sk.PublicKey()))
+}
+
+func TestOpen(t *testing.T) {
+	// the command is never split, such that paths containing spaces are supported
+	client, err := Open("exec:/opt/my signer", "--keyfile", "minter keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"/opt/my signer", "--keyfile", "minter keys"}; !reflect.DeepEqual(client.command, expected) {
+		t.Errorf("expected command %q, but got %q", expected, client.command)
+	}
+	client, err = Open("unix:///tmp/signer.sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.socket != "/tmp/signer.sock" {
+		t.Errorf("expected socket /tmp/signer.sock, but got %q", client.socket)
+	}
+	for _, tc := range []struct {
+		str  string
+		args []string
+	}{
+		{"exec:", nil},
+		{"unix:", nil},
+		{"unix:/tmp/signer.sock", []string{"--keyfile"}},
+		{"/tmp/signer.sock", nil},
+	} {
+		if _, err = Open(tc.str, tc.args...); err == nil {
+			t.Errorf("expected opening external signer %q with args %q to fail", tc.str, tc.args)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/types"
+)
+
+type (
+	// TransactionSigHashes contains the signature hashes of all inputs (and the mint fulfillment) of a transaction,
+	// such that clients which cannot compute them themselves, such as hardware wallets and mobile apps,
+	// can sign the transaction using the hashes only.
+	TransactionSigHashes struct {
+		CoinInputs       []InputSigHashes `json:"coininputs"`
+		BlockStakeInputs []InputSigHashes `json:"blockstakeinputs"`
+		// MintFulfillment is only defined for coin creation and minter definition transactions
+		MintFulfillment *InputSigHashes `json:"mintfulfillment,omitempty"`
+	}
+
+	// InputSigHashes contains the signature hashes of a single input (or mint fulfillment).
+	InputSigHashes struct {
+		// SigHash is the hash signed by a single signature fulfillment,
+		// the only hash which can be computed for an input which isn't (partially) signed yet
+		SigHash crypto.Hash `json:"sighash"`
+		// PublicKeys contains the hash to be signed by each public key of the fulfillment,
+		// for fulfillments which include the public key in the signed hash,
+		// such as the multisig and atomic swap fulfillments
+		PublicKeys []PublicKeySigHash `json:"publickeys,omitempty"`
+	}
+
+	// PublicKeySigHash is the hash to be signed by a public key.
+	PublicKeySigHash struct {
+		PublicKey types.SiaPublicKey `json:"publickey"`
+		SigHash   crypto.Hash        `json:"sighash"`
+	}
+)
+
+// DecodeTransaction decodes a binary-encoded transaction of any registered version,
+// returning an error if not all bytes are consumed.
+func DecodeTransaction(b []byte) (types.Transaction, error) {
+	var txn types.Transaction
+	r := bytes.NewReader(b)
+	err := encoding.NewDecoder(r).Decode(&txn)
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	if r.Len() != 0 {
+		return types.Transaction{}, fmt.Errorf("%d trailing bytes after the encoded transaction", r.Len())
+	}
+	return txn, nil
+}
+
+// NewTransactionSigHashes computes the signature hashes of all inputs (and the mint fulfillment) of the given transaction,
+// using the registered controller of its version.
+func NewTransactionSigHashes(txn types.Transaction) (TransactionSigHashes, error) {
+	hashes := TransactionSigHashes{
+		CoinInputs:       make([]InputSigHashes, 0, len(txn.CoinInputs)),
+		BlockStakeInputs: make([]InputSigHashes, 0, len(txn.BlockStakeInputs)),
+	}
+	for idx, ci := range txn.CoinInputs {
+		h, err := newInputSigHashes(txn, uint64(idx), ci.Fulfillment)
+		if err != nil {
+			return TransactionSigHashes{}, fmt.Errorf("coin input #%d: %v", idx, err)
+		}
+		hashes.CoinInputs = append(hashes.CoinInputs, h)
+	}
+	for idx, bsi := range txn.BlockStakeInputs {
+		h, err := newInputSigHashes(txn, uint64(idx), bsi.Fulfillment)
+		if err != nil {
+			return TransactionSigHashes{}, fmt.Errorf("block stake input #%d: %v", idx, err)
+		}
+		hashes.BlockStakeInputs = append(hashes.BlockStakeInputs, h)
+	}
+	var mintFulfillment *types.UnlockFulfillmentProxy
+	switch ext := txn.Extension.(type) {
+	case *CoinCreationTransactionExtension:
+		mintFulfillment = &ext.MintFulfillment
+	case *MinterDefinitionTransactionExtension:
+		mintFulfillment = &ext.MintFulfillment
+	}
+	if mintFulfillment != nil {
+		h, err := newInputSigHashes(txn, 0, *mintFulfillment)
+		if err != nil {
+			return TransactionSigHashes{}, fmt.Errorf("mint fulfillment: %v", err)
+		}
+		hashes.MintFulfillment = &h
+	}
+	return hashes, nil
+}
+
+// newInputSigHashes computes the signature hashes of a single input,
+// in the same way as its fulfillment computes them when signing itself
+func newInputSigHashes(txn types.Transaction, idx uint64, fulfillment types.UnlockFulfillmentProxy) (InputSigHashes, error) {
+	var (
+		hashes InputSigHashes
+		err    error
+	)
+	hashes.SigHash, err = txn.InputSigHash(idx)
+	if err != nil {
+		return InputSigHashes{}, err
+	}
+	addPublicKey := func(pk types.SiaPublicKey, extraObjects ...interface{}) error {
+		sigHash, err := txn.InputSigHash(idx, extraObjects...)
+		if err != nil {
+			return err
+		}
+		hashes.PublicKeys = append(hashes.PublicKeys, PublicKeySigHash{PublicKey: pk, SigHash: sigHash})
+		return nil
+	}
+	switch tf := fulfillment.Fulfillment.(type) {
+	case *types.MultiSignatureFulfillment:
+		for _, pair := range tf.Pairs {
+			if err = addPublicKey(pair.PublicKey, pair.PublicKey); err != nil {
+				return InputSigHashes{}, err
+			}
+		}
+	case *types.AtomicSwapFulfillment:
+		err = addAtomicSwapSigHash(addPublicKey, tf.PublicKey, tf.Secret)
+	case *types.LegacyAtomicSwapFulfillment:
+		err = addAtomicSwapSigHash(addPublicKey, tf.PublicKey, tf.Secret)
+	}
+	if err != nil {
+		return InputSigHashes{}, err
+	}
+	return hashes, nil
+}
+
+// addAtomicSwapSigHash adds the signature hash of an atomic swap fulfillment,
+// which includes the secret when claiming
+func addAtomicSwapSigHash(add func(types.SiaPublicKey, ...interface{}) error, pk types.SiaPublicKey, secret types.AtomicSwapSecret) error {
+	if secret != (types.AtomicSwapSecret{}) {
+		return add(pk, pk, secret)
+	}
+	return add(pk, pk)
+}
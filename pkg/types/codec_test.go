@@ -0,0 +1,117 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/types"
+)
+
+func TestDecodeCoinCreationTransaction(t *testing.T) {
+	types.RegisterTransactionVersion(TransactionVersionCoinCreation, CoinCreationTransactionController{})
+	defer types.RegisterTransactionVersion(TransactionVersionCoinCreation, nil)
+
+	skA, pkA := crypto.GenerateKeyPairDeterministic([crypto.EntropySize]byte{1})
+	skB, pkB := crypto.GenerateKeyPairDeterministic([crypto.EntropySize]byte{2})
+	cctx := CoinCreationTransaction{
+		Nonce: RandomTransactionNonce(),
+		CoinOutputs: []types.CoinOutput{
+			{
+				Value:     types.NewCurrency64(42),
+				Condition: types.NewCondition(types.NewUnlockHashCondition(types.NewPubKeyUnlockHash(types.Ed25519PublicKey(pkA)))),
+			},
+		},
+		MinerFees: []types.Currency{types.NewCurrency64(1)},
+	}
+	fulfillment := types.NewMultiSignatureFulfillment(nil)
+	for _, kp := range []types.KeyPair{
+		{PublicKey: types.Ed25519PublicKey(pkA), PrivateKey: skA[:]},
+		{PublicKey: types.Ed25519PublicKey(pkB), PrivateKey: skB[:]},
+	} {
+		err := fulfillment.Sign(types.FulfillmentSignContext{Transaction: cctx.Transaction(), Key: kp})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	cctx.MintFulfillment = types.NewFulfillment(fulfillment)
+	txn := cctx.Transaction()
+
+	b := encoding.Marshal(txn)
+	decoded, err := DecodeTransaction(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ID() != txn.ID() {
+		t.Errorf("expected decoded transaction ID %v, not %v", txn.ID(), decoded.ID())
+	}
+	if _, err = DecodeTransaction(append(b, 0)); err == nil {
+		t.Error("expected transaction with trailing bytes to be invalid")
+	}
+	if _, err = DecodeTransaction(b[:len(b)-1]); err == nil {
+		t.Error("expected truncated transaction to be invalid")
+	}
+
+	// the signature hash of each public key verifies its signature
+	hashes, err := NewTransactionSigHashes(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes.CoinInputs) != 0 || hashes.MintFulfillment == nil || len(hashes.MintFulfillment.PublicKeys) != 2 {
+		t.Fatalf("unexpected signature hashes: %+v", hashes)
+	}
+	for idx, pair := range fulfillment.Pairs {
+		h := hashes.MintFulfillment.PublicKeys[idx]
+		if h.PublicKey.String() != pair.PublicKey.String() {
+			t.Errorf("expected public key #%d to be %v, not %v", idx, pair.PublicKey, h.PublicKey)
+		}
+		err = VerifySignature(pair.PublicKey, h.SigHash, toSignature(pair.Signature))
+		if err != nil {
+			t.Errorf("signature #%d doesn't match its signature hash: %v", idx, err)
+		}
+	}
+}
+
+func TestNewTransactionSigHashesRegularTransaction(t *testing.T) {
+	sk, pk := crypto.GenerateKeyPairDeterministic([crypto.EntropySize]byte{1})
+	spk := types.Ed25519PublicKey(pk)
+	txn := types.Transaction{
+		Version: types.TransactionVersionOne,
+		CoinInputs: []types.CoinInput{
+			{ParentID: types.CoinOutputID{1}},
+			{ParentID: types.CoinOutputID{2}},
+		},
+		CoinOutputs: []types.CoinOutput{
+			{
+				Value:     types.NewCurrency64(42),
+				Condition: types.NewCondition(types.NewUnlockHashCondition(types.NewPubKeyUnlockHash(spk))),
+			},
+		},
+		MinerFees: []types.Currency{types.NewCurrency64(1)},
+	}
+
+	// the hashes of an unsigned transaction are the single signature hashes
+	unsigned, err := NewTransactionSigHashes(txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unsigned.CoinInputs) != 2 || unsigned.MintFulfillment != nil || unsigned.CoinInputs[0].SigHash == unsigned.CoinInputs[1].SigHash {
+		t.Fatalf("unexpected signature hashes: %+v", unsigned)
+	}
+	for idx := range txn.CoinInputs {
+		fulfillment := &types.SingleSignatureFulfillment{PublicKey: spk}
+		err = fulfillment.Sign(types.FulfillmentSignContext{InputIndex: uint64(idx), Transaction: txn, Key: sk})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = VerifySignature(spk, unsigned.CoinInputs[idx].SigHash, toSignature(fulfillment.Signature))
+		if err != nil {
+			t.Errorf("signature of input #%d doesn't match its signature hash: %v", idx, err)
+		}
+	}
+}
+
+func toSignature(b types.ByteSlice) (signature crypto.Signature) {
+	copy(signature[:], b)
+	return
+}
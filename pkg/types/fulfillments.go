@@ -0,0 +1,300 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/encoding"
+	"github.com/rivine/rivine/types"
+)
+
+// TransactionFulfillments returns pointers to all fulfillments of the given transaction,
+// the ones of the coin inputs first, followed by the ones of the block stake inputs,
+// and finally the mint fulfillment in case the transaction is a
+// CoinCreationTransaction or MinterDefinitionTransaction.
+//
+// The extension of the given transaction is copied when it contains a mint fulfillment,
+// such that modifying the returned mint fulfillment does not affect copies of the transaction.
+func TransactionFulfillments(txn *types.Transaction) []*types.UnlockFulfillmentProxy {
+	fulfillments := make([]*types.UnlockFulfillmentProxy, 0, len(txn.CoinInputs)+len(txn.BlockStakeInputs)+1)
+	for i := range txn.CoinInputs {
+		fulfillments = append(fulfillments, &txn.CoinInputs[i].Fulfillment)
+	}
+	for i := range txn.BlockStakeInputs {
+		fulfillments = append(fulfillments, &txn.BlockStakeInputs[i].Fulfillment)
+	}
+	switch extension := txn.Extension.(type) {
+	case *CoinCreationTransactionExtension:
+		extensionCopy := *extension
+		txn.Extension = &extensionCopy
+		fulfillments = append(fulfillments, &extensionCopy.MintFulfillment)
+	case *MinterDefinitionTransactionExtension:
+		extensionCopy := *extension
+		txn.Extension = &extensionCopy
+		fulfillments = append(fulfillments, &extensionCopy.MintFulfillment)
+	}
+	return fulfillments
+}
+
+// TransactionsEqualUnsigned returns true if both transactions are equal,
+// ignoring the content of all their fulfillments.
+func TransactionsEqualUnsigned(a, b types.Transaction) bool {
+	a, b = copyTransactionInputs(a), copyTransactionInputs(b)
+	for _, txn := range []*types.Transaction{&a, &b} {
+		for _, fulfillment := range TransactionFulfillments(txn) {
+			*fulfillment = types.UnlockFulfillmentProxy{}
+		}
+	}
+	return bytes.Equal(encoding.Marshal(a), encoding.Marshal(b))
+}
+
+// MergeTransactionFulfillments merges the fulfillments of the other transaction
+// into a copy of the given transaction, returning that copy.
+// Both transactions have to be equal, ignoring their fulfillments.
+//
+// Multisignature fulfillments are merged by combining all unique public key-signature pairs,
+// while all other fulfillments are only merged if at least one of them is nil,
+// or if they are equal to one another.
+func MergeTransactionFulfillments(txn, other types.Transaction) (types.Transaction, error) {
+	if !TransactionsEqualUnsigned(txn, other) {
+		return types.Transaction{}, errors.New("transactions differ, ignoring their fulfillments, and cannot be merged")
+	}
+	txn, other = copyTransactionInputs(txn), copyTransactionInputs(other)
+	fulfillments, otherFulfillments := TransactionFulfillments(&txn), TransactionFulfillments(&other)
+	for i, fulfillment := range fulfillments {
+		merged, err := MergeFulfillments(*fulfillment, *otherFulfillments[i])
+		if err != nil {
+			return types.Transaction{}, fmt.Errorf("failed to merge fulfillment #%d: %v", i, err)
+		}
+		*fulfillment = merged
+	}
+	return txn, nil
+}
+
+// MergeFulfillments merges two fulfillments which are meant to fulfill the same condition.
+//
+// Multisignature fulfillments are merged by combining all unique public key-signature pairs,
+// while all other fulfillments are only merged if at least one of them is nil,
+// or if they are equal to one another.
+func MergeFulfillments(a, b types.UnlockFulfillmentProxy) (types.UnlockFulfillmentProxy, error) {
+	if b.FulfillmentType() == types.FulfillmentTypeNil {
+		return a, nil
+	}
+	if a.FulfillmentType() == types.FulfillmentTypeNil {
+		return b, nil
+	}
+	if a.FulfillmentType() != b.FulfillmentType() {
+		return types.UnlockFulfillmentProxy{}, fmt.Errorf(
+			"cannot merge fulfillments of different types %d and %d", a.FulfillmentType(), b.FulfillmentType())
+	}
+	msfA, okA := a.Fulfillment.(*types.MultiSignatureFulfillment)
+	msfB, okB := b.Fulfillment.(*types.MultiSignatureFulfillment)
+	if okA && okB {
+		merged := &types.MultiSignatureFulfillment{
+			Pairs: make([]types.PublicKeySignaturePair, 0, len(msfA.Pairs)+len(msfB.Pairs)),
+		}
+		known := make(map[string]struct{}, len(msfA.Pairs)+len(msfB.Pairs))
+		for _, pairs := range [][]types.PublicKeySignaturePair{msfA.Pairs, msfB.Pairs} {
+			for _, pair := range pairs {
+				key := pair.PublicKey.String()
+				if _, ok := known[key]; ok {
+					continue
+				}
+				known[key] = struct{}{}
+				merged.Pairs = append(merged.Pairs, pair)
+			}
+		}
+		return types.NewFulfillment(merged), nil
+	}
+	if !bytes.Equal(encoding.Marshal(a), encoding.Marshal(b)) {
+		return types.UnlockFulfillmentProxy{}, fmt.Errorf(
+			"cannot merge two different (non-nil) fulfillments of type %d", a.FulfillmentType())
+	}
+	return a, nil
+}
+
+// VerifyFulfillmentSignatures verifies all signatures of the given single or multisignature fulfillment,
+// which is meant to fulfill the given condition, for the input with the given index of the given transaction.
+// Each signature has to be made by a key which can sign the condition,
+// and has to be valid for the signature hash of that input,
+// while the fulfillment doesn't have to contain all required signatures yet.
+// Nil fulfillments are considered valid, while other fulfillment types are not supported.
+func VerifyFulfillmentSignatures(txn types.Transaction, idx uint64, fulfillment types.UnlockFulfillmentProxy, condition types.UnlockConditionProxy) error {
+	signers := make(map[types.UnlockHash]struct{})
+	for _, uh := range ConditionUnlockHashes(condition) {
+		signers[uh] = struct{}{}
+	}
+	verify := func(pk types.SiaPublicKey, signature []byte, extraObjects ...interface{}) error {
+		if _, ok := signers[types.NewPubKeyUnlockHash(pk)]; !ok {
+			return fmt.Errorf("public key %s cannot sign the condition", pk.String())
+		}
+		if len(signature) != crypto.SignatureSize {
+			return fmt.Errorf("invalid signature size for public key %s", pk.String())
+		}
+		sigHash, err := txn.InputSigHash(idx, extraObjects...)
+		if err != nil {
+			return err
+		}
+		var sig crypto.Signature
+		copy(sig[:], signature)
+		err = VerifySignature(pk, sigHash, sig)
+		if err != nil {
+			return fmt.Errorf("invalid signature for public key %s: %v", pk.String(), err)
+		}
+		return nil
+	}
+	switch tf := fulfillment.Fulfillment.(type) {
+	case *types.SingleSignatureFulfillment:
+		return verify(tf.PublicKey, tf.Signature)
+	case *types.MultiSignatureFulfillment:
+		for _, pair := range tf.Pairs {
+			// multisig signatures sign the public key as well
+			if err := verify(pair.PublicKey, pair.Signature, pair.PublicKey); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if fulfillment.FulfillmentType() == types.FulfillmentTypeNil {
+			return nil
+		}
+		return fmt.Errorf("unsupported fulfillment type %d", fulfillment.FulfillmentType())
+	}
+}
+
+// ConditionUnlockHashes returns the unlock hashes of all (public key) addresses
+// which can sign in order to fulfill the given condition. For a time lock condition
+// the unlock hashes of its internal condition are returned.
+// Nil is returned for conditions that cannot be fulfilled using signatures only.
+func ConditionUnlockHashes(condition types.UnlockCondition) []types.UnlockHash {
+	switch c := condition.(type) {
+	case types.UnlockConditionProxy:
+		if c.Condition == nil {
+			return nil
+		}
+		return ConditionUnlockHashes(c.Condition)
+	case *types.UnlockHashCondition:
+		return []types.UnlockHash{c.TargetUnlockHash}
+	case *types.TimeLockCondition:
+		return ConditionUnlockHashes(c.Condition)
+	case types.UnlockHashSliceGetter:
+		return c.UnlockHashSlice()
+	default:
+		return nil
+	}
+}
+
+// TransactionUnlockHashes returns the unlock hashes of all conditions the given transaction sends to or spends from,
+// including the unlock hashes which can sign those conditions (e.g. the owners of a multisig wallet),
+// as well as those of the mint condition defined by a MinterDefinitionTransaction.
+// The conditions of the spent outputs are looked up in the given outputs, spent outputs not found are ignored.
+func TransactionUnlockHashes(
+	txn types.Transaction,
+	coinOutputs map[types.CoinOutputID]types.CoinOutput,
+	blockStakeOutputs map[types.BlockStakeOutputID]types.BlockStakeOutput) map[types.UnlockHash]struct{} {
+	uhs := make(map[types.UnlockHash]struct{})
+	addCondition := func(condition types.UnlockConditionProxy) {
+		uhs[condition.UnlockHash()] = struct{}{}
+		for _, uh := range ConditionUnlockHashes(condition) {
+			uhs[uh] = struct{}{}
+		}
+	}
+	for _, ci := range txn.CoinInputs {
+		if co, ok := coinOutputs[ci.ParentID]; ok {
+			addCondition(co.Condition)
+		}
+	}
+	for _, co := range txn.CoinOutputs {
+		addCondition(co.Condition)
+	}
+	for _, bsi := range txn.BlockStakeInputs {
+		if bso, ok := blockStakeOutputs[bsi.ParentID]; ok {
+			addCondition(bso.Condition)
+		}
+	}
+	for _, bso := range txn.BlockStakeOutputs {
+		addCondition(bso.Condition)
+	}
+	if txn.Version == TransactionVersionMinterDefinition {
+		if mdtx, err := MinterDefinitionTransactionFromTransaction(txn); err == nil {
+			addCondition(mdtx.MintCondition)
+		}
+	}
+	return uhs
+}
+
+// IsAtomicSwapTransaction returns true if the given transaction creates,
+// redeems or refunds an atomic swap contract.
+func IsAtomicSwapTransaction(txn types.Transaction) bool {
+	for _, ci := range txn.CoinInputs {
+		if ci.Fulfillment.FulfillmentType() == types.FulfillmentTypeAtomicSwap {
+			return true
+		}
+	}
+	for _, co := range txn.CoinOutputs {
+		if co.Condition.ConditionType() == types.ConditionTypeAtomicSwap {
+			return true
+		}
+	}
+	return false
+}
+
+// TransactionHasNilFulfillment returns true if any fulfillment of the given transaction,
+// including the mint fulfillment of coin creation and minter definition transactions, is a nil fulfillment.
+// Such a transaction isn't signed yet, and has no binary encoding,
+// hence neither its ID nor its size can be computed (debug builds even panic when trying so).
+func TransactionHasNilFulfillment(txn types.Transaction) bool {
+	for _, ci := range txn.CoinInputs {
+		if ci.Fulfillment.FulfillmentType() == types.FulfillmentTypeNil {
+			return true
+		}
+	}
+	for _, bsi := range txn.BlockStakeInputs {
+		if bsi.Fulfillment.FulfillmentType() == types.FulfillmentTypeNil {
+			return true
+		}
+	}
+	switch txn.Version {
+	case TransactionVersionCoinCreation:
+		if cctx, err := CoinCreationTransactionFromTransaction(txn); err == nil {
+			return cctx.MintFulfillment.FulfillmentType() == types.FulfillmentTypeNil
+		}
+	case TransactionVersionMinterDefinition:
+		if mdtx, err := MinterDefinitionTransactionFromTransaction(txn); err == nil {
+			return mdtx.MintFulfillment.FulfillmentType() == types.FulfillmentTypeNil
+		}
+	}
+	return false
+}
+
+// FulfillmentPublicKeys returns the public keys of all signatures
+// that are part of the given fulfillment.
+func FulfillmentPublicKeys(fulfillment types.UnlockFulfillmentProxy) []types.SiaPublicKey {
+	switch f := fulfillment.Fulfillment.(type) {
+	case *types.SingleSignatureFulfillment:
+		return []types.SiaPublicKey{f.PublicKey}
+	case *types.MultiSignatureFulfillment:
+		keys := make([]types.SiaPublicKey, 0, len(f.Pairs))
+		for _, pair := range f.Pairs {
+			keys = append(keys, pair.PublicKey)
+		}
+		return keys
+	case *types.AtomicSwapFulfillment:
+		return []types.SiaPublicKey{f.PublicKey}
+	case *types.LegacyAtomicSwapFulfillment:
+		return []types.SiaPublicKey{f.PublicKey}
+	default:
+		return nil
+	}
+}
+
+// copyTransactionInputs returns a copy of the given transaction,
+// which has its own copy of the coin inputs and block stake inputs,
+// such that their fulfillments can be modified without affecting the original transaction.
+func copyTransactionInputs(txn types.Transaction) types.Transaction {
+	txn.CoinInputs = append([]types.CoinInput(nil), txn.CoinInputs...)
+	txn.BlockStakeInputs = append([]types.BlockStakeInput(nil), txn.BlockStakeInputs...)
+	return txn
+}
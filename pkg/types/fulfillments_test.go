@@ -0,0 +1,153 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/types"
+)
+
+func TestMergeMultiSignatureFulfillments(t *testing.T) {
+	pairA := types.PublicKeySignaturePair{
+		PublicKey: types.Ed25519PublicKey(crypto.PublicKey{1}),
+		Signature: []byte{1, 2, 3},
+	}
+	pairB := types.PublicKeySignaturePair{
+		PublicKey: types.Ed25519PublicKey(crypto.PublicKey{2}),
+		Signature: []byte{4, 5, 6},
+	}
+	txnA := types.Transaction{
+		Version: types.TransactionVersionOne,
+		CoinInputs: []types.CoinInput{
+			{
+				ParentID: types.CoinOutputID{1},
+				Fulfillment: types.NewFulfillment(&types.MultiSignatureFulfillment{
+					Pairs: []types.PublicKeySignaturePair{pairA},
+				}),
+			},
+		},
+		MinerFees: []types.Currency{types.NewCurrency64(1)},
+	}
+	txnB := txnA
+	txnB.CoinInputs = []types.CoinInput{
+		{
+			ParentID: types.CoinOutputID{1},
+			Fulfillment: types.NewFulfillment(&types.MultiSignatureFulfillment{
+				Pairs: []types.PublicKeySignaturePair{pairB, pairA},
+			}),
+		},
+	}
+
+	merged, err := MergeTransactionFulfillments(txnA, txnB)
+	if err != nil {
+		t.Fatal("failed to merge transactions: ", err)
+	}
+	keys := FulfillmentPublicKeys(merged.CoinInputs[0].Fulfillment)
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 unique signatures, but got %d", len(keys))
+	}
+	// the original transaction should not be modified
+	if n := len(FulfillmentPublicKeys(txnA.CoinInputs[0].Fulfillment)); n != 1 {
+		t.Fatalf("expected original transaction to still have 1 signature, but it has %d", n)
+	}
+
+	// merging a transaction with different content should fail
+	txnB.MinerFees = []types.Currency{types.NewCurrency64(2)}
+	_, err = MergeTransactionFulfillments(txnA, txnB)
+	if err == nil {
+		t.Fatal("expected merging of different transactions to fail")
+	}
+}
+
+func TestMergeMintFulfillments(t *testing.T) {
+	fulfillment := types.NewFulfillment(&types.SingleSignatureFulfillment{
+		PublicKey: types.Ed25519PublicKey(crypto.PublicKey{1}),
+		Signature: []byte{1, 2, 3},
+	})
+	unsigned := types.Transaction{
+		Version: TransactionVersionCoinCreation,
+		Extension: &CoinCreationTransactionExtension{
+			Nonce: RandomTransactionNonce(),
+		},
+		MinerFees: []types.Currency{types.NewCurrency64(1)},
+	}
+	signedExtension := *unsigned.Extension.(*CoinCreationTransactionExtension)
+	signedExtension.MintFulfillment = fulfillment
+	signed := unsigned
+	signed.Extension = &signedExtension
+
+	merged, err := MergeTransactionFulfillments(unsigned, signed)
+	if err != nil {
+		t.Fatal("failed to merge transactions: ", err)
+	}
+	if !merged.Extension.(*CoinCreationTransactionExtension).MintFulfillment.Equal(fulfillment) {
+		t.Fatal("expected merged transaction to contain the mint fulfillment")
+	}
+	if unsigned.Extension.(*CoinCreationTransactionExtension).MintFulfillment.FulfillmentType() != types.FulfillmentTypeNil {
+		t.Fatal("expected original transaction to remain unsigned")
+	}
+
+	// two different single signature fulfillments cannot be merged
+	otherExtension := signedExtension
+	otherExtension.MintFulfillment = types.NewFulfillment(&types.SingleSignatureFulfillment{
+		PublicKey: types.Ed25519PublicKey(crypto.PublicKey{2}),
+		Signature: []byte{4, 5, 6},
+	})
+	other := unsigned
+	other.Extension = &otherExtension
+	_, err = MergeTransactionFulfillments(signed, other)
+	if err == nil {
+		t.Fatal("expected merging of different single signature fulfillments to fail")
+	}
+}
+
+func TestConditionUnlockHashes(t *testing.T) {
+	uhA := types.NewPubKeyUnlockHash(types.Ed25519PublicKey(crypto.PublicKey{1}))
+	uhB := types.NewPubKeyUnlockHash(types.Ed25519PublicKey(crypto.PublicKey{2}))
+	testCases := []struct {
+		Condition types.UnlockConditionProxy
+		Expected  []types.UnlockHash
+	}{
+		{types.UnlockConditionProxy{}, nil},
+		{types.NewCondition(types.NewUnlockHashCondition(uhA)), []types.UnlockHash{uhA}},
+		{types.NewCondition(types.NewMultiSignatureCondition(types.UnlockHashSlice{uhA, uhB}, 1)), []types.UnlockHash{uhA, uhB}},
+		{types.NewCondition(types.NewTimeLockCondition(42, types.NewUnlockHashCondition(uhB))), []types.UnlockHash{uhB}},
+	}
+	for idx, testCase := range testCases {
+		uhs := ConditionUnlockHashes(testCase.Condition)
+		if len(uhs) != len(testCase.Expected) {
+			t.Errorf("#%d: expected %d unlock hashes, but got %d", idx, len(testCase.Expected), len(uhs))
+			continue
+		}
+		for i := range uhs {
+			if uhs[i].Cmp(testCase.Expected[i]) != 0 {
+				t.Errorf("#%d: unlock hash #%d: expected %s, but got %s", idx, i, testCase.Expected[i], uhs[i])
+			}
+		}
+	}
+}
+
+func TestTransactionUnlockHashes(t *testing.T) {
+	uhA := types.NewPubKeyUnlockHash(types.Ed25519PublicKey(crypto.PublicKey{1}))
+	uhB := types.NewPubKeyUnlockHash(types.Ed25519PublicKey(crypto.PublicKey{2}))
+	uhC := types.NewPubKeyUnlockHash(types.Ed25519PublicKey(crypto.PublicKey{3}))
+	multiSig := types.NewCondition(types.NewMultiSignatureCondition(types.UnlockHashSlice{uhB, uhC}, 2))
+	parentID := types.CoinOutputID{4}
+	txn := types.Transaction{
+		Version:     types.TransactionVersionOne,
+		CoinInputs:  []types.CoinInput{{ParentID: parentID}, {ParentID: types.CoinOutputID{5}}},
+		CoinOutputs: []types.CoinOutput{{Condition: types.NewCondition(types.NewUnlockHashCondition(uhA))}},
+	}
+	coinOutputs := map[types.CoinOutputID]types.CoinOutput{
+		parentID: {Condition: multiSig},
+	}
+	uhs := TransactionUnlockHashes(txn, coinOutputs, nil)
+	for _, uh := range []types.UnlockHash{uhA, uhB, uhC, multiSig.UnlockHash()} {
+		if _, ok := uhs[uh]; !ok {
+			t.Errorf("expected unlock hash %s to be part of the transaction unlock hashes", uh)
+		}
+	}
+	if len(uhs) != 4 {
+		t.Errorf("expected 4 unlock hashes, but got %d", len(uhs))
+	}
+}
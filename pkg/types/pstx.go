@@ -0,0 +1,434 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/types"
+)
+
+// PSTXVersion is the (only) supported version of the
+// partially signed transaction (PSTX) container format.
+const PSTXVersion uint8 = 1
+
+// PSTXFulfillmentKind defines the kind of (transaction) fulfillment
+// a PSTXFulfillment applies to.
+type PSTXFulfillmentKind string
+
+// All possible kinds of (transaction) fulfillments.
+const (
+	PSTXFulfillmentKindCoinInput       PSTXFulfillmentKind = "coininput"
+	PSTXFulfillmentKindBlockStakeInput PSTXFulfillmentKind = "blockstakeinput"
+	PSTXFulfillmentKindMint            PSTXFulfillmentKind = "mint"
+)
+
+type (
+	// PartiallySignedTransaction is a portable container format, which wraps
+	// an unsigned or partially signed transaction, together with all information
+	// required to sign it offline, and to inspect who can and who already did sign it.
+	//
+	// It contains the resolved parent outputs of all inputs,
+	// the mint condition for MinterDefinition and CoinCreation transactions,
+	// as well as optional (human-readable) labels for the signers and
+	// an optional description of the intent of the transaction.
+	//
+	// The container is JSON-encoded, as the transaction it wraps
+	// can contain nil fulfillments, which are not supported by the binary encoding.
+	PartiallySignedTransaction struct {
+		Version     uint8             `json:"version"`
+		Transaction types.Transaction `json:"transaction"`
+		Description string            `json:"description,omitempty"`
+		// CoinInputs contains the parent output for each coin input of the transaction
+		CoinInputs []PSTXParentOutput `json:"coininputs,omitempty"`
+		// BlockStakeInputs contains the parent output for each block stake input of the transaction
+		BlockStakeInputs []PSTXParentOutput `json:"blockstakeinputs,omitempty"`
+		// MintCondition is only defined for MinterDefinition and CoinCreation transactions
+		MintCondition *types.UnlockConditionProxy `json:"mintcondition,omitempty"`
+		// Signers can optionally label (some of) the unlock hashes of the signers
+		Signers []PSTXSigner `json:"signers,omitempty"`
+	}
+
+	// PSTXParentOutput is the resolved parent output of a (coin or block stake) input.
+	PSTXParentOutput struct {
+		Value     types.Currency             `json:"value"`
+		Condition types.UnlockConditionProxy `json:"condition"`
+	}
+
+	// PSTXSigner labels the unlock hash of a signer.
+	PSTXSigner struct {
+		UnlockHash types.UnlockHash `json:"unlockhash"`
+		Label      string           `json:"label"`
+	}
+
+	// PSTXFulfillment defines the state of a single fulfillment of a PSTX,
+	// listing who can sign it, who already signed it, and whether or not it is fulfilled.
+	PSTXFulfillment struct {
+		Kind PSTXFulfillmentKind `json:"kind"`
+		// Index of the input, always 0 for the mint fulfillment
+		Index     uint64                     `json:"index"`
+		Condition types.UnlockConditionProxy `json:"condition"`
+		Signers   []types.UnlockHash         `json:"signers"`
+		Signed    []types.UnlockHash         `json:"signed"`
+		Fulfilled bool                       `json:"fulfilled"`
+		// Error explains why the fulfillment isn't fulfilled (yet)
+		Error string `json:"error,omitempty"`
+	}
+)
+
+// NewPartiallySignedTransaction creates a new PSTX for the given transaction,
+// without any metadata. The parent outputs (and mint condition if required)
+// still have to be defined, prior to it being valid.
+func NewPartiallySignedTransaction(txn types.Transaction) PartiallySignedTransaction {
+	return PartiallySignedTransaction{
+		Version:     PSTXVersion,
+		Transaction: txn,
+	}
+}
+
+// DecodePartiallySignedTransaction decodes and validates a JSON-encoded PSTX.
+func DecodePartiallySignedTransaction(r io.Reader) (PartiallySignedTransaction, error) {
+	var pstx PartiallySignedTransaction
+	err := json.NewDecoder(r).Decode(&pstx)
+	if err != nil {
+		return PartiallySignedTransaction{}, fmt.Errorf("failed to decode PSTX: %v", err)
+	}
+	err = pstx.Validate()
+	if err != nil {
+		return PartiallySignedTransaction{}, err
+	}
+	return pstx, nil
+}
+
+// Encode validates and JSON-encodes the PSTX.
+func (pstx *PartiallySignedTransaction) Encode(w io.Writer) error {
+	err := pstx.Validate()
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(pstx)
+}
+
+// Validate ensures the PSTX is of a supported version,
+// and that it contains all metadata required for its transaction.
+func (pstx *PartiallySignedTransaction) Validate() error {
+	if pstx.Version != PSTXVersion {
+		return fmt.Errorf("unsupported PSTX version %d", pstx.Version)
+	}
+	if len(pstx.CoinInputs) != len(pstx.Transaction.CoinInputs) {
+		return fmt.Errorf("PSTX defines %d coin input parent outputs, while its transaction has %d coin inputs",
+			len(pstx.CoinInputs), len(pstx.Transaction.CoinInputs))
+	}
+	if len(pstx.BlockStakeInputs) != len(pstx.Transaction.BlockStakeInputs) {
+		return fmt.Errorf("PSTX defines %d block stake input parent outputs, while its transaction has %d block stake inputs",
+			len(pstx.BlockStakeInputs), len(pstx.Transaction.BlockStakeInputs))
+	}
+	switch pstx.Transaction.Version {
+	case TransactionVersionMinterDefinition, TransactionVersionCoinCreation:
+		if pstx.MintCondition == nil {
+			return fmt.Errorf("PSTX requires a mint condition for a transaction of version %d", pstx.Transaction.Version)
+		}
+	default:
+		if pstx.MintCondition != nil {
+			return fmt.Errorf("PSTX cannot define a mint condition for a transaction of version %d", pstx.Transaction.Version)
+		}
+	}
+	return nil
+}
+
+// Label returns the label of the given unlock hash, or an empty string if it has none.
+func (pstx *PartiallySignedTransaction) Label(uh types.UnlockHash) string {
+	for _, signer := range pstx.Signers {
+		if signer.UnlockHash.Cmp(uh) == 0 {
+			return signer.Label
+		}
+	}
+	return ""
+}
+
+// SetLabel labels the given unlock hash, overwriting an existing label if it exists.
+func (pstx *PartiallySignedTransaction) SetLabel(uh types.UnlockHash, label string) {
+	for idx := range pstx.Signers {
+		if pstx.Signers[idx].UnlockHash.Cmp(uh) == 0 {
+			pstx.Signers[idx].Label = label
+			return
+		}
+	}
+	pstx.Signers = append(pstx.Signers, PSTXSigner{UnlockHash: uh, Label: label})
+}
+
+// Fulfillments returns the state of all fulfillments of the PSTX,
+// in the same order as returned by TransactionFulfillments.
+// The given context is used to check if the conditions are fulfilled.
+func (pstx *PartiallySignedTransaction) Fulfillments(ctx types.FulfillableContext) ([]PSTXFulfillment, error) {
+	err := pstx.Validate()
+	if err != nil {
+		return nil, err
+	}
+	fulfillments := pstx.unfilledFulfillments()
+	txn := pstx.Transaction
+	for idx, fulfillment := range TransactionFulfillments(&txn) {
+		state := &fulfillments[idx]
+		for _, pk := range FulfillmentPublicKeys(*fulfillment) {
+			state.Signed = append(state.Signed, types.NewPubKeyUnlockHash(pk))
+		}
+		err = state.Condition.Fulfill(*fulfillment, types.FulfillContext{
+			InputIndex:  state.Index,
+			BlockHeight: ctx.BlockHeight,
+			BlockTime:   ctx.BlockTime,
+			Transaction: pstx.Transaction,
+		})
+		state.Fulfilled = err == nil
+		if err != nil {
+			state.Error = err.Error()
+		}
+	}
+	return fulfillments, nil
+}
+
+// Sign signs all fulfillments of the PSTX which can be signed using the given secret key,
+// and which are not yet signed by it. The amount of added signatures is returned.
+func (pstx *PartiallySignedTransaction) Sign(sk crypto.SecretKey) (uint64, error) {
+	return pstx.SignWith(keySigner{sk: sk})
+}
+
+// SignWith signs all fulfillments of the PSTX which can be signed using the given signer,
+// and which are not yet signed by it. The amount of added signatures is returned.
+//
+// The mint fulfillment is signed using the SignExtension logic of the tfchain transaction controllers,
+// using the mint condition of the PSTX, such that no access to the consensus is required.
+func (pstx *PartiallySignedTransaction) SignWith(signer Signer) (uint64, error) {
+	err := pstx.Validate()
+	if err != nil {
+		return 0, err
+	}
+	var signatures uint64
+	sign := func(kind PSTXFulfillmentKind, idx uint64, fulfillment *types.UnlockFulfillmentProxy, condition types.UnlockConditionProxy) error {
+		signed, err := signFulfillment(pstx.Transaction, idx, fulfillment, condition, signer, func() string {
+			return TransactionSummary(pstx.Transaction, kind, idx, pstx.Description)
+		})
+		if signed {
+			signatures++
+		}
+		return err
+	}
+
+	// sign all inputs
+	txn := copyTransactionInputs(pstx.Transaction)
+	for idx := range txn.CoinInputs {
+		err = sign(PSTXFulfillmentKindCoinInput, uint64(idx), &txn.CoinInputs[idx].Fulfillment, pstx.CoinInputs[idx].Condition)
+		if err != nil {
+			return 0, fmt.Errorf("failed to sign coin input #%d: %v", idx, err)
+		}
+	}
+	for idx := range txn.BlockStakeInputs {
+		err = sign(PSTXFulfillmentKindBlockStakeInput, uint64(idx), &txn.BlockStakeInputs[idx].Fulfillment, pstx.BlockStakeInputs[idx].Condition)
+		if err != nil {
+			return 0, fmt.Errorf("failed to sign block stake input #%d: %v", idx, err)
+		}
+	}
+
+	// sign the mint fulfillment if required
+	if pstx.MintCondition != nil {
+		signExtension := func(fulfillment *types.UnlockFulfillmentProxy, condition types.UnlockConditionProxy) error {
+			return sign(PSTXFulfillmentKindMint, 0, fulfillment, condition)
+		}
+		getter := staticMintConditionGetter{condition: *pstx.MintCondition}
+		// TransactionFulfillments copies the extension of the transaction,
+		// such that signing it does not modify the extension of the original transaction
+		TransactionFulfillments(&txn)
+		switch txn.Version {
+		case TransactionVersionMinterDefinition:
+			txn.Extension, err = MinterDefinitionTransactionController{MintConditionGetter: getter}.SignExtension(txn.Extension, signExtension)
+		case TransactionVersionCoinCreation:
+			txn.Extension, err = CoinCreationTransactionController{MintConditionGetter: getter}.SignExtension(txn.Extension, signExtension)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	pstx.Transaction = txn
+	return signatures, nil
+}
+
+// Combine merges the fulfillments and signer labels of the other PSTX into this PSTX.
+// Both containers have to wrap the same transaction (ignoring fulfillments) and define the same metadata.
+func (pstx *PartiallySignedTransaction) Combine(other PartiallySignedTransaction) error {
+	err := pstx.Validate()
+	if err != nil {
+		return err
+	}
+	err = other.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid PSTX to combine with: %v", err)
+	}
+	if !bytes.Equal(pstx.metadata(), other.metadata()) {
+		return errors.New("cannot combine PSTX containers which define different parent outputs or mint conditions")
+	}
+	txn, err := MergeTransactionFulfillments(pstx.Transaction, other.Transaction)
+	if err != nil {
+		return err
+	}
+	pstx.Transaction = txn
+	if pstx.Description == "" {
+		pstx.Description = other.Description
+	}
+	for _, signer := range other.Signers {
+		if pstx.Label(signer.UnlockHash) == "" {
+			pstx.SetLabel(signer.UnlockHash, signer.Label)
+		}
+	}
+	return nil
+}
+
+// Finalize returns the wrapped transaction, ready to be broadcasted,
+// in case all its fulfillments are fulfilled within the given context.
+func (pstx *PartiallySignedTransaction) Finalize(ctx types.FulfillableContext) (types.Transaction, error) {
+	fulfillments, err := pstx.Fulfillments(ctx)
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	for _, fulfillment := range fulfillments {
+		if !fulfillment.Fulfilled {
+			return types.Transaction{}, fmt.Errorf("%s fulfillment #%d is not fulfilled: %s",
+				fulfillment.Kind, fulfillment.Index, fulfillment.Error)
+		}
+	}
+	return pstx.Transaction, nil
+}
+
+// unfilledFulfillments returns the fulfillment states of the PSTX,
+// with only the kind, index, condition and signers defined
+func (pstx *PartiallySignedTransaction) unfilledFulfillments() []PSTXFulfillment {
+	fulfillments := make([]PSTXFulfillment, 0, len(pstx.CoinInputs)+len(pstx.BlockStakeInputs)+1)
+	for idx, parent := range pstx.CoinInputs {
+		fulfillments = append(fulfillments, PSTXFulfillment{
+			Kind:      PSTXFulfillmentKindCoinInput,
+			Index:     uint64(idx),
+			Condition: parent.Condition,
+		})
+	}
+	for idx, parent := range pstx.BlockStakeInputs {
+		fulfillments = append(fulfillments, PSTXFulfillment{
+			Kind:      PSTXFulfillmentKindBlockStakeInput,
+			Index:     uint64(idx),
+			Condition: parent.Condition,
+		})
+	}
+	if pstx.MintCondition != nil {
+		fulfillments = append(fulfillments, PSTXFulfillment{
+			Kind:      PSTXFulfillmentKindMint,
+			Condition: *pstx.MintCondition,
+		})
+	}
+	for idx := range fulfillments {
+		fulfillments[idx].Signers = ConditionUnlockHashes(fulfillments[idx].Condition)
+	}
+	return fulfillments
+}
+
+// metadata returns the JSON-encoded parent outputs and mint condition of the PSTX,
+// such that the metadata of two containers can be compared
+func (pstx *PartiallySignedTransaction) metadata() []byte {
+	b, _ := json.Marshal([]interface{}{pstx.CoinInputs, pstx.BlockStakeInputs, pstx.MintCondition})
+	return b
+}
+
+// signFulfillment signs the given fulfillment using the given signer,
+// in case its key can be used to fulfill the given condition,
+// and in case the fulfillment isn't yet signed by that key.
+// The summary is only created when a signature is requested.
+// True is returned in case a signature was added.
+func signFulfillment(txn types.Transaction, idx uint64, fulfillment *types.UnlockFulfillmentProxy, condition types.UnlockConditionProxy, signer Signer, summary func() string) (bool, error) {
+	pk := signer.PublicKey()
+	uh := types.NewPubKeyUnlockHash(pk)
+	var canSign bool
+	for _, candidate := range ConditionUnlockHashes(condition) {
+		if candidate.Cmp(uh) == 0 {
+			canSign = true
+			break
+		}
+	}
+	if !canSign {
+		return false, nil
+	}
+	for _, signed := range FulfillmentPublicKeys(*fulfillment) {
+		if types.NewPubKeyUnlockHash(signed).Cmp(uh) == 0 {
+			return false, nil // already signed by this key
+		}
+	}
+
+	// the signature hash is computed the same way as the fulfillments do it when signing themselves,
+	// such that the secret key does not have to be known by this process
+	var extraObjects []interface{}
+	switch condition.UnlockHash().Type {
+	case types.UnlockTypePubKey:
+		if fulfillment.FulfillmentType() != types.FulfillmentTypeNil {
+			return false, nil // already signed by another key
+		}
+	case types.UnlockTypeMultiSig:
+		extraObjects = append(extraObjects, pk)
+	default:
+		return false, fmt.Errorf("unsupported condition type %d", condition.ConditionType())
+	}
+	sigHash, err := txn.InputSigHash(idx, extraObjects...)
+	if err != nil {
+		return false, err
+	}
+	signature, err := signer.SignHash(SignRequest{
+		SigHash:   sigHash,
+		PublicKey: pk,
+		Summary:   summary(),
+	})
+	if err != nil {
+		return false, err
+	}
+	// never trust the signer blindly, as it might be an external process
+	err = VerifySignature(pk, sigHash, signature)
+	if err != nil {
+		return false, fmt.Errorf("signer returned an invalid signature: %v", err)
+	}
+
+	if condition.UnlockHash().Type == types.UnlockTypePubKey {
+		fulfillment.Fulfillment = &types.SingleSignatureFulfillment{
+			PublicKey: pk,
+			Signature: types.ByteSlice(signature[:]),
+		}
+		return true, nil
+	}
+	msf, ok := fulfillment.Fulfillment.(*types.MultiSignatureFulfillment)
+	if !ok {
+		if fulfillment.FulfillmentType() != types.FulfillmentTypeNil {
+			return false, fmt.Errorf("unexpected fulfillment type %d for a multisig condition", fulfillment.FulfillmentType())
+		}
+		msf = &types.MultiSignatureFulfillment{}
+		fulfillment.Fulfillment = msf
+	}
+	msf.Pairs = append(msf.Pairs, types.PublicKeySignaturePair{
+		PublicKey: pk,
+		Signature: types.ByteSlice(signature[:]),
+	})
+	return true, nil
+}
+
+// staticMintConditionGetter returns the same mint condition for any height,
+// used to sign mint fulfillments offline, using a known mint condition
+type staticMintConditionGetter struct {
+	condition types.UnlockConditionProxy
+}
+
+// GetActiveMintCondition implements MintConditionGetter.GetActiveMintCondition
+func (getter staticMintConditionGetter) GetActiveMintCondition() (types.UnlockConditionProxy, error) {
+	return getter.condition, nil
+}
+
+// GetMintConditionAt implements MintConditionGetter.GetMintConditionAt
+func (getter staticMintConditionGetter) GetMintConditionAt(types.BlockHeight) (types.UnlockConditionProxy, error) {
+	return getter.condition, nil
+}
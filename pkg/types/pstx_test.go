@@ -0,0 +1,135 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/types"
+)
+
+func TestPartiallySignedCoinCreationTransaction(t *testing.T) {
+	// InputSigHash of the coin creation transaction is defined by its controller
+	types.RegisterTransactionVersion(TransactionVersionCoinCreation, CoinCreationTransactionController{})
+	defer types.RegisterTransactionVersion(TransactionVersionCoinCreation, nil)
+
+	skA, pkA := crypto.GenerateKeyPairDeterministic([crypto.EntropySize]byte{1})
+	skB, pkB := crypto.GenerateKeyPairDeterministic([crypto.EntropySize]byte{2})
+	spkA, spkB := types.Ed25519PublicKey(pkA), types.Ed25519PublicKey(pkB)
+	uhA, uhB := types.NewPubKeyUnlockHash(spkA), types.NewPubKeyUnlockHash(spkB)
+	mintCondition := types.NewCondition(types.NewMultiSignatureCondition(types.UnlockHashSlice{uhA, uhB}, 2))
+
+	cctx := CoinCreationTransaction{
+		Nonce: RandomTransactionNonce(),
+		CoinOutputs: []types.CoinOutput{
+			{
+				Value:     types.NewCurrency64(42),
+				Condition: types.NewCondition(types.NewUnlockHashCondition(uhA)),
+			},
+		},
+		MinerFees: []types.Currency{types.NewCurrency64(1)},
+	}
+	pstx := NewPartiallySignedTransaction(cctx.Transaction())
+	if err := pstx.Validate(); err == nil {
+		t.Fatal("expected PSTX without mint condition to be invalid")
+	}
+	pstx.MintCondition = &mintCondition
+	pstx.SetLabel(uhA, "alice")
+	pstx.SetLabel(uhB, "bob")
+
+	// create a second PSTX, using an encode-decode roundtrip
+	var buf bytes.Buffer
+	err := pstx.Encode(&buf)
+	if err != nil {
+		t.Fatal("failed to encode PSTX: ", err)
+	}
+	other, err := DecodePartiallySignedTransaction(&buf)
+	if err != nil {
+		t.Fatal("failed to decode PSTX: ", err)
+	}
+	if label := other.Label(uhB); label != "bob" {
+		t.Fatalf("expected label bob, but got %q", label)
+	}
+
+	// sign both containers, each with a different key
+	n, err := pstx.Sign(skA)
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 signature to be added using key A, but got %d (err: %v)", n, err)
+	}
+	n, err = pstx.Sign(skA)
+	if err != nil || n != 0 {
+		t.Fatalf("expected no signature to be added when signing with key A again, but got %d (err: %v)", n, err)
+	}
+	ctx := types.FulfillableContext{}
+	if _, err = pstx.Finalize(ctx); err == nil {
+		t.Fatal("expected finalize to fail, as only one of two required signatures is present")
+	}
+	n, err = other.Sign(skB)
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 signature to be added using key B, but got %d (err: %v)", n, err)
+	}
+
+	// combine the containers, which should make the transaction fully signed
+	err = pstx.Combine(other)
+	if err != nil {
+		t.Fatal("failed to combine PSTX containers: ", err)
+	}
+	fulfillments, err := pstx.Fulfillments(ctx)
+	if err != nil {
+		t.Fatal("failed to get fulfillments of PSTX: ", err)
+	}
+	if len(fulfillments) != 1 || fulfillments[0].Kind != PSTXFulfillmentKindMint || len(fulfillments[0].Signed) != 2 {
+		t.Fatalf("unexpected fulfillments: %v", fulfillments)
+	}
+	txn, err := pstx.Finalize(ctx)
+	if err != nil {
+		t.Fatal("failed to finalize PSTX: ", err)
+	}
+	err = mintCondition.Fulfill(txn.Extension.(*CoinCreationTransactionExtension).MintFulfillment, types.FulfillContext{
+		Transaction: txn,
+	})
+	if err != nil {
+		t.Fatal("expected the finalized transaction to fulfill the mint condition: ", err)
+	}
+}
+
+func TestPartiallySignedRegularTransaction(t *testing.T) {
+	sk, pk := crypto.GenerateKeyPairDeterministic([crypto.EntropySize]byte{1})
+	otherSK, _ := crypto.GenerateKeyPairDeterministic([crypto.EntropySize]byte{2})
+	spk := types.Ed25519PublicKey(pk)
+	uh := types.NewPubKeyUnlockHash(spk)
+
+	pstx := NewPartiallySignedTransaction(types.Transaction{
+		Version: types.TransactionVersionOne,
+		CoinInputs: []types.CoinInput{
+			{ParentID: types.CoinOutputID{1}},
+		},
+		CoinOutputs: []types.CoinOutput{
+			{
+				Value:     types.NewCurrency64(9),
+				Condition: types.NewCondition(types.NewUnlockHashCondition(uh)),
+			},
+		},
+		MinerFees: []types.Currency{types.NewCurrency64(1)},
+	})
+	pstx.CoinInputs = []PSTXParentOutput{
+		{
+			Value:     types.NewCurrency64(10),
+			Condition: types.NewCondition(types.NewUnlockHashCondition(uh)),
+		},
+	}
+
+	// a key which isn't part of the condition cannot sign
+	n, err := pstx.Sign(otherSK)
+	if err != nil || n != 0 {
+		t.Fatalf("expected no signature to be added using an unrelated key, but got %d (err: %v)", n, err)
+	}
+	n, err = pstx.Sign(sk)
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 signature to be added, but got %d (err: %v)", n, err)
+	}
+	_, err = pstx.Finalize(types.FulfillableContext{})
+	if err != nil {
+		t.Fatal("failed to finalize PSTX: ", err)
+	}
+}
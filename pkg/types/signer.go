@@ -0,0 +1,135 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/threefoldfoundation/tfchain/pkg/config"
+
+	"github.com/rivine/rivine/crypto"
+	"github.com/rivine/rivine/types"
+)
+
+type (
+	// Signer signs signature hashes using the secret key paired with its public key.
+	// It allows the secret key to be kept out of the process that creates
+	// and signs the transaction, e.g. by delegating the signing to an external process or HSM.
+	Signer interface {
+		// PublicKey returns the public key paired with the secret key used to sign.
+		PublicKey() types.SiaPublicKey
+		// SignHash signs the signature hash of the given request.
+		SignHash(request SignRequest) (crypto.Signature, error)
+	}
+
+	// SignRequest is a request to sign a single signature hash,
+	// as received by a Signer.
+	SignRequest struct {
+		// SigHash is the signature hash to be signed
+		SigHash crypto.Hash `json:"sighash"`
+		// PublicKey identifies the key to sign with
+		PublicKey types.SiaPublicKey `json:"publickey"`
+		// Summary is a human-readable summary of the transaction
+		// and the fulfillment the signature is for, such that it can be reviewed prior to signing
+		Summary string `json:"summary"`
+	}
+)
+
+// NewKeySigner creates a Signer which signs using the given (in-memory) secret key.
+func NewKeySigner(sk crypto.SecretKey) Signer {
+	return keySigner{sk: sk}
+}
+
+type keySigner struct {
+	sk crypto.SecretKey
+}
+
+// PublicKey implements Signer.PublicKey
+func (s keySigner) PublicKey() types.SiaPublicKey {
+	return types.Ed25519PublicKey(s.sk.PublicKey())
+}
+
+// SignHash implements Signer.SignHash
+func (s keySigner) SignHash(request SignRequest) (crypto.Signature, error) {
+	return crypto.SignHash(request.SigHash, s.sk), nil
+}
+
+// VerifySignature verifies that the given signature is a valid ed25519 signature
+// of the given signature hash, using the given public key.
+func VerifySignature(pk types.SiaPublicKey, sigHash crypto.Hash, signature crypto.Signature) error {
+	if pk.Algorithm != types.SignatureEd25519 {
+		return fmt.Errorf("unsupported signature algorithm %v", pk.Algorithm)
+	}
+	if len(pk.Key) != crypto.PublicKeySize {
+		return errors.New("invalid ed25519 public key size")
+	}
+	var edPK crypto.PublicKey
+	copy(edPK[:], pk.Key)
+	return crypto.VerifyHash(sigHash, edPK, signature)
+}
+
+// TransactionSummary returns a human-readable summary of the given transaction,
+// used by signers to review what they are signing.
+// The fulfillment that is signed is described by its kind and index,
+// while the description, if given, describes the intent of the transaction.
+func TransactionSummary(txn types.Transaction, kind PSTXFulfillmentKind, index uint64, description string) string {
+	var buf bytes.Buffer
+	switch txn.Version {
+	case TransactionVersionCoinCreation:
+		fmt.Fprintf(&buf, "coin creation transaction (version %d)\n", txn.Version)
+	case TransactionVersionMinterDefinition:
+		fmt.Fprintf(&buf, "minter definition transaction (version %d)\n", txn.Version)
+	default:
+		fmt.Fprintf(&buf, "transaction (version %d)\n", txn.Version)
+	}
+	// the ID is only known once all fulfillments are signed
+	if !TransactionHasNilFulfillment(txn) {
+		fmt.Fprintf(&buf, "id: %s\n", txn.ID().String())
+	}
+	if kind == PSTXFulfillmentKindMint {
+		fmt.Fprintln(&buf, "signing: mint fulfillment")
+	} else {
+		fmt.Fprintf(&buf, "signing: %s #%d\n", kind, index)
+	}
+	if description != "" {
+		fmt.Fprintf(&buf, "description: %s\n", description)
+	}
+	if ext, ok := txn.Extension.(*MinterDefinitionTransactionExtension); ok {
+		fmt.Fprintf(&buf, "new mint condition: %s\n", conditionSummary(ext.MintCondition))
+	}
+	fmt.Fprintf(&buf, "coin inputs: %d\n", len(txn.CoinInputs))
+	for _, co := range txn.CoinOutputs {
+		fmt.Fprintf(&buf, "coin output: %s to %s\n", currencySummary(co.Value), conditionSummary(co.Condition))
+	}
+	if len(txn.BlockStakeInputs) > 0 {
+		fmt.Fprintf(&buf, "block stake inputs: %d\n", len(txn.BlockStakeInputs))
+	}
+	for _, bso := range txn.BlockStakeOutputs {
+		fmt.Fprintf(&buf, "block stake output: %s BS to %s\n", bso.Value.String(), conditionSummary(bso.Condition))
+	}
+	for _, fee := range txn.MinerFees {
+		fmt.Fprintf(&buf, "miner fee: %s\n", currencySummary(fee))
+	}
+	if len(txn.ArbitraryData) > 0 {
+		fmt.Fprintf(&buf, "arbitrary data: %q\n", txn.ArbitraryData)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// conditionSummary describes a condition using its unlock hash,
+// adding the time lock if the condition is time locked
+func conditionSummary(condition types.UnlockConditionProxy) string {
+	if tlc, ok := condition.Condition.(*types.TimeLockCondition); ok {
+		return fmt.Sprintf("%s (locked until %d)", tlc.UnlockHash().String(), tlc.LockTime)
+	}
+	return condition.UnlockHash().String()
+}
+
+// currencySummary formats a currency value in TFT
+func currencySummary(c types.Currency) string {
+	str := new(big.Rat).SetFrac(c.Big(), config.GetCurrencyUnits().OneCoin.Big()).FloatString(9)
+	str = strings.TrimRight(strings.TrimRight(str, "0"), ".")
+	return str + " TFT"
+}
@@ -499,7 +499,7 @@ func (mdtc MinterDefinitionTransactionController) ValidateTransaction(t types.Tr
 	//   * PubKey-UnlockHashCondtion
 	//   * MultiSigConditions
 	//   * TimeLockConditions (if the internal condition type is supported)
-	err = validateMintCondition(mdtx.MintCondition)
+	err = ValidateMintCondition(mdtx.MintCondition)
 	if err != nil {
 		return err
 	}
@@ -538,7 +538,10 @@ func (mdtc MinterDefinitionTransactionController) ValidateTransaction(t types.Tr
 	return
 }
 
-func validateMintCondition(condition types.UnlockCondition) error {
+// ValidateMintCondition validates if the given condition can be used as a mint condition,
+// which is only the case for PubKey UnlockHash and MultiSignature conditions,
+// optionally wrapped in a TimeLock condition.
+func ValidateMintCondition(condition types.UnlockCondition) error {
 	switch ct := condition.ConditionType(); ct {
 	case types.ConditionTypeMultiSignature:
 		// always valid
@@ -565,7 +568,7 @@ func validateMintCondition(condition types.UnlockCondition) error {
 			}
 			return err
 		}
-		return validateMintCondition(cg.GetMarshalableUnlockCondition())
+		return ValidateMintCondition(cg.GetMarshalableUnlockCondition())
 
 	default:
 		// all other types aren't allowed
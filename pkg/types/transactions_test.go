@@ -982,8 +982,8 @@ func TestSignMinterDefinitionTransactionExtension(t *testing.T) {
 		signCount := 1
 		mintCondition, _ := inMemoryMintConditionGetter.GetActiveMintCondition()
 
-		// validate condition first (free validateMintCondition test)
-		err := validateMintCondition(mintCondition)
+		// validate condition first (free ValidateMintCondition test)
+		err := ValidateMintCondition(mintCondition)
 		if err != nil {
 			return fmt.Errorf("invalid mint condition cannot be signed: %v", err)
 		}